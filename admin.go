@@ -0,0 +1,215 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/provider"
+	"tvreminder/bot/internal/store"
+)
+
+// parseAdminIDs parses a comma-separated list of user IDs, like ADMIN_USER_IDS.
+func parseAdminIDs(s string) (map[int64]bool, error) {
+	admins := make(map[int64]bool)
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user ID %q", field)
+		}
+		admins[id] = true
+	}
+	return admins, nil
+}
+
+// adminStats are the bot-wide numbers for /admin stats.
+type adminStats struct {
+	Users            int
+	Shows            int
+	DistinctShows    int
+	PendingReminders int
+	FailedReminders  int
+	Sent             int
+	SendFailures     int
+	DBSize           int64
+}
+
+func getAdminStats(ctx context.Context, db *store.DB, since time.Time) (*adminStats, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var stats adminStats
+	err := db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM (SELECT user_id FROM user_settings UNION SELECT user_id FROM shows)),
+			(SELECT COUNT(*) FROM shows WHERE deleted_at IS NULL),
+			(SELECT COUNT(DISTINCT provider || ':' || provider_show_id) FROM shows WHERE deleted_at IS NULL),
+			(SELECT COUNT(*) FROM reminders WHERE status = ? AND deleted_at IS NULL),
+			(SELECT COUNT(*) FROM reminders WHERE status = ? AND deleted_at IS NULL),
+			(SELECT COUNT(*) FROM delivery_log WHERE created_at >= ? AND failed = 0),
+			(SELECT COUNT(*) FROM delivery_log WHERE created_at >= ? AND failed = 1)
+	`, ReminderStatusPending, ReminderStatusFailed,
+		since.UTC().Format(time.RFC3339), since.UTC().Format(time.RFC3339),
+	).Scan(
+		&stats.Users, &stats.Shows, &stats.DistinctShows, &stats.PendingReminders,
+		&stats.FailedReminders, &stats.Sent, &stats.SendFailures,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var pageCount, pageSize int64
+	if err := db.QueryRowContext(ctx, `PRAGMA page_count`).Scan(&pageCount); err != nil {
+		return nil, err
+	}
+	if err := db.QueryRowContext(ctx, `PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return nil, err
+	}
+	stats.DBSize = pageCount * pageSize
+	return &stats, nil
+}
+
+func formatAdminStats(stats *adminStats, providers []provider.Summary, cleanup CacheCleanupSummary) string {
+	text := dedent(fmt.Sprintf(`
+	Users: %d
+	Shows tracked: %d (%d distinct)
+	Reminders pending: %d, dead-lettered: %d
+	Reminders in the last 24 hours: %d sent, %d failed
+	Database size: %.1f MB
+	%s
+	`, stats.Users, stats.Shows, stats.DistinctShows, stats.PendingReminders, stats.FailedReminders,
+		stats.Sent, stats.SendFailures, float64(stats.DBSize)/(1<<20), formatCacheCleanup(cleanup)))
+
+	text += "\n\nProvider requests in the last 24 hours:"
+	if len(providers) == 0 {
+		text += " none"
+	}
+	for _, p := range providers {
+		text += fmt.Sprintf(
+			"\n%s: %d, %.1f%% failed",
+			p.Provider, p.Requests, 100*float64(p.Failures)/float64(p.Requests),
+		)
+	}
+	return text
+}
+
+// ADMIN command
+
+// handleAdminCommand runs the admin tools. To everyone else the command doesn't exist.
+func (handler *Handler) handleAdminCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	if !handler.Admins[msg.From.ID] {
+		return NewUserError(
+			fmt.Errorf("user %d is not an admin", msg.From.ID),
+			"Unknown command: /admin. See /help for available commands.",
+		)
+	}
+
+	subcommand, arg, _ := strings.Cut(strings.TrimSpace(msg.CommandArguments()), " ")
+	switch strings.ToLower(subcommand) {
+	case "stats":
+		now := time.Now()
+		stats, err := getAdminStats(ctx, handler.DB, now.Add(-24*time.Hour))
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting admin stats: %w", err),
+				"Error reading stats",
+			)
+		}
+		handler.Bot.reply(ctx, chatID, formatAdminStats(stats, provider.RequestStats.Summary(now), handler.CacheCleanup.Summary()))
+	case "abuse":
+		summaries, err := listAbuse(ctx, handler.DB, time.Now().Add(-24*time.Hour), 20)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("listing abuse log: %w", err),
+				"Error reading the abuse log",
+			)
+		}
+		handler.Bot.reply(ctx, chatID, formatAbuse(summaries))
+	case "incident":
+		id := strings.TrimSpace(arg)
+		if id == "" {
+			handler.Bot.reply(ctx, chatID, "Usage: /admin incident <id>")
+			return nil
+		}
+		incident, err := getIncident(ctx, handler.DB, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			handler.Bot.reply(ctx, chatID, fmt.Sprintf("No incident %s, it may be older than %d days.", id, int(incidentRetention.Hours()/24)))
+			return nil
+		}
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting incident %s: %w", id, err),
+				"Error reading the incident",
+			)
+		}
+		handler.Bot.reply(ctx, chatID, formatIncident(incident))
+	case "jobs":
+		if retry, idArg, _ := strings.Cut(strings.TrimSpace(arg), " "); retry == "retry" {
+			jobID, err := strconv.ParseInt(strings.TrimSpace(idArg), 10, 64)
+			if err != nil {
+				handler.Bot.reply(ctx, chatID, "Usage: /admin jobs retry <id>")
+				return nil
+			}
+			found, err := retryJob(ctx, handler.DB, jobID)
+			if err != nil {
+				return NewUserError(
+					fmt.Errorf("retrying job %d: %w", jobID, err),
+					"Error retrying the job",
+				)
+			}
+			if !found {
+				handler.Bot.reply(ctx, chatID, fmt.Sprintf("No failed job #%d.", jobID))
+				return nil
+			}
+			handler.Bot.reply(ctx, chatID, fmt.Sprintf("Job #%d queued again.", jobID))
+			return nil
+		}
+		counts, err := countJobs(ctx, handler.DB)
+		var failed []Job
+		if err == nil {
+			failed, err = listFailedJobs(ctx, handler.DB, 10)
+		}
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("listing jobs: %w", err),
+				"Error reading the jobs",
+			)
+		}
+		handler.Bot.reply(ctx, chatID, formatJobs(counts, failed))
+	case "clock":
+		fake, ok := handler.Clock.(*clock.Fake)
+		if !ok {
+			handler.Bot.reply(ctx, chatID, "The clock only moves in simulation mode, see SIMULATION.")
+			return nil
+		}
+		if arg = strings.TrimSpace(arg); arg != "" {
+			if err := moveClock(fake, arg); err != nil {
+				handler.Bot.reply(ctx, chatID, "Usage: /admin clock [+duration|time], like +90m or 2026-03-01T20:00:00Z")
+				return nil
+			}
+		}
+		handler.Bot.reply(ctx, chatID, "Simulated time: "+fake.Now().Format(time.RFC3339))
+	default:
+		handler.Bot.reply(ctx, chatID, dedent(`
+		Usage:
+		/admin stats - users, shows, reminders and provider health
+		/admin abuse - users who hit the rate limits in the last 24 hours
+		/admin incident <id> - the full error behind an incident ID a user reported
+		/admin jobs - background jobs, and retry <id> to run a failed one again
+		/admin clock [+duration|time] - the simulated time, moved forward or set
+		`))
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAdminStats(t *testing.T) {
+	env := dueReminderEnv(t)
+	env.handler.Admins = map[int64]bool{testUserID: true}
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+
+	env.command("/admin stats")
+	text := env.telegram.lastMessage(t).Params.Get("text")
+	for _, want := range []string{
+		"Users: 1\n",
+		"Shows tracked: 1 (1 distinct)",
+		"Reminders in the last 24 hours: 1 sent, 0 failed",
+		"Database size: ",
+		"\ntvmaze: ",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("/admin stats = %q, want it to contain %q", text, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Shows without a next episode drop out of /shows on their own. Archiving does the
+// same by hand for shows the user finished or lost interest in while they're still
+// airing: archived shows are left out of /shows, /queue and /watchlist but stay in
+// /history. It only affects lists, reminders are still controlled by notifications.
+
+func setShowArchived(ctx context.Context, db *store.DB, showID int64, archived bool) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `UPDATE shows SET archived = ? WHERE id = ?`, archived, showID)
+	return err
+}
+
+// handleToggleArchivedCallback archives or unarchives a show from its card.
+func (handler *Handler) handleToggleArchivedCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleToggleArchivedCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	show, err := handler.validateAndGetShow(ctx, cb.From.ID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	if err := setShowArchived(ctx, handler.DB, show.InternalID, !show.Archived); err != nil {
+		return NewUserError(
+			fmt.Errorf("archiving show %d: %w", show.InternalID, err),
+			"Error updating the show",
+		)
+	}
+	// An archived show leaves the filtered lists, so its card moves to the history
+	return handler.refreshShowDetail(ctx, cb, show, listType)
+}
@@ -0,0 +1,39 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestArchiveShow(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	env.command("/shows")
+	env.press("selectShow:1:current")
+
+	env.press("toggleArchived:1:current")
+	card := env.telegram.lastMessage(t)
+	if !strings.Contains(card.Params.Get("text"), "Archived") || !slices.Contains(card.labels(t), "📤 Unarchive") {
+		t.Errorf("card after archiving = %q %v, want it marked archived", card.Params.Get("text"), card.labels(t))
+	}
+	if got := queryString(t, env, `SELECT notifications_enabled FROM shows`); got != "1" {
+		t.Errorf("notifications_enabled = %s, archiving shouldn't touch it", got)
+	}
+
+	env.command("/shows")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "You have no current shows") {
+		t.Errorf("/shows with the show archived = %q", got)
+	}
+	env.command("/history")
+	if labels := env.telegram.lastMessage(t).labels(t); !strings.HasPrefix(labels[0], "🗄 ") {
+		t.Errorf("history = %v, want the archived show marked", labels)
+	}
+
+	env.press("selectShow:1:history")
+	env.press("toggleArchived:1:history")
+	env.command("/shows")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "Your current shows:" {
+		t.Errorf("/shows after unarchiving = %q", got)
+	}
+}
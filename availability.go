@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+var countryCodeRe = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// replaceWatchOptions stores the latest streaming options for a show, dropping the
+// ones the provider no longer reports.
+func replaceWatchOptions(ctx context.Context, db *store.DB, provider, providerShowID string, options []WatchOption) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM watch_options WHERE provider = ? AND provider_show_id = ?
+	`, provider, providerShowID)
+	if err != nil {
+		return err
+	}
+	for _, option := range options {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO watch_options (provider, provider_show_id, country, service, url)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING
+		`, provider, providerShowID, option.Country, option.Service, option.URL)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func listWatchOptions(ctx context.Context, db *store.DB, provider, providerShowID string) ([]WatchOption, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT country, service, url FROM watch_options
+		WHERE provider = ? AND provider_show_id = ?
+		ORDER BY service, country
+	`, provider, providerShowID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var options []WatchOption
+	for rows.Next() {
+		var option WatchOption
+		if err := rows.Scan(&option.Country, &option.Service, &option.URL); err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+	}
+	return options, rows.Err()
+}
+
+// watchServices returns the names of the services carrying a show in country.
+// Without a country every service is listed, regional ones with their country code.
+func watchServices(options []WatchOption, country string) []string {
+	var services []string
+	for _, option := range options {
+		switch {
+		case option.Country == "" || option.Country == country:
+			services = append(services, option.Service)
+		case country == "":
+			services = append(services, fmt.Sprintf("%s (%s)", option.Service, option.Country))
+		}
+	}
+	return services
+}
+
+// refreshWatchOptions fetches and stores where a show streams, if the provider knows.
+// Availability is a nice-to-have, so failures are only logged.
+func (handler *Handler) refreshWatchOptions(ctx context.Context, providerShowID int) {
+	availability, ok := handler.Provider.(AvailabilityProvider)
+	if !ok {
+		return
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	options, err := availability.WatchOptions(fetchCtx, providerShowID)
+	if err != nil {
+		log.Printf("refreshWatchOptions: fetching options for show %d: %v", providerShowID, err)
+		return
+	}
+	err = replaceWatchOptions(ctx, handler.DB, handler.Provider.Name(), fmt.Sprint(providerShowID), options)
+	if err != nil {
+		log.Printf("refreshWatchOptions: storing options for show %d: %v", providerShowID, err)
+	}
+}
+
+func setUserCountry(ctx context.Context, db *store.DB, userID, chatID int64, country string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET country = ? WHERE user_id = ?`, country, userID)
+	return err
+}
+
+// COUNTRY command
+
+func (handler *Handler) handleCountryCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	arg := strings.ToUpper(strings.TrimSpace(msg.CommandArguments()))
+
+	switch {
+	case arg == "":
+		settings, err := getUserSettings(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting settings for user %d: %w", userID, err),
+				"Error reading your settings, please try again later.",
+			)
+		}
+		country := settings.Country
+		if country == "" {
+			country = "not set"
+		}
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf(
+			"Your country: %s. Use /country <code>, e.g. /country US, to see where shows stream for you.", country,
+		))
+		return nil
+	case arg == "OFF":
+		arg = ""
+	case !countryCodeRe.MatchString(arg):
+		return NewUserError(
+			fmt.Errorf("invalid country code %q", arg),
+			"Usage: /country <two-letter code>, e.g. /country US, or /country off",
+		)
+	}
+
+	if err := setUserCountry(ctx, handler.DB, userID, chatID, arg); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting country for user %d: %w", userID, err),
+			"Error saving your country, please try again later.",
+		)
+	}
+	if arg == "" {
+		handler.Bot.reply(ctx, chatID, "Country cleared, streaming services from all countries will be shown.")
+	} else {
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf("Country set to %s.", arg))
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWatchServices(t *testing.T) {
+	options := []WatchOption{
+		{Service: "Netflix"},
+		{Service: "Hulu", Country: "US"},
+		{Service: "Stan", Country: "AU"},
+	}
+	tests := []struct {
+		country string
+		want    []string
+	}{
+		{country: "US", want: []string{"Netflix", "Hulu"}},
+		{country: "DE", want: []string{"Netflix"}},
+		{country: "", want: []string{"Netflix", "Hulu (US)", "Stan (AU)"}},
+	}
+	for _, tt := range tests {
+		if got := watchServices(options, tt.country); !slices.Equal(got, tt.want) {
+			t.Errorf("watchServices(%q) = %v, want %v", tt.country, got, tt.want)
+		}
+	}
+}
+
+// streamingShows makes "Night Shift" a Netflix original available in the US only.
+func streamingShows() []fakeShow {
+	shows := testShows()
+	shows[0].Network = nil
+	shows[0].WebChannel = &Network{ID: 1, Name: "Netflix", Country: &Country{Name: "United States", Code: "US"}}
+	return shows
+}
+
+func TestWatchOptionsInShowDetail(t *testing.T) {
+	tests := []struct {
+		name    string
+		country string
+		want    string
+	}{
+		{name: "no country", want: "Where to watch: Netflix (US)"},
+		{name: "matching country", country: "us", want: "Where to watch: Netflix\n"},
+		{name: "other country", country: "DE", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, streamingShows()...)
+			if tt.country != "" {
+				env.command("/country " + tt.country)
+			}
+			trackShow(t, env, "2")
+			env.command("/history")
+			env.press("selectShow:1:history")
+
+			text := env.telegram.lastMessage(t).Params.Get("text")
+			if tt.want == "" && strings.Contains(text, "Where to watch") {
+				t.Errorf("card %q lists services, want none", text)
+			}
+			if tt.want != "" && !strings.Contains(text, tt.want) {
+				t.Errorf("card %q, want it to contain %q", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestReminderMentionsStreamingService(t *testing.T) {
+	env := newTestEnv(t, streamingShows()...)
+	env.command("/country US")
+	trackShow(t, env, "2")
+	env.handler.DB.Exec(`UPDATE reminders SET remind_at = datetime('now', '-1 minute')`)
+
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+
+	text := env.telegram.lastMessage(t).Params.Get("text")
+	if !strings.Contains(text, "Streaming on Netflix.") {
+		t.Errorf("reminder %q doesn't mention the streaming service", text)
+	}
+}
+
+func TestCountryCommand(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{arg: "gb", want: "Country set to GB."},
+		{arg: "off", want: "Country cleared, streaming services from all countries will be shown."},
+		{arg: "Germany", want: "Usage: /country <two-letter code>, e.g. /country US, or /country off"},
+		{arg: "", want: "Your country: not set."},
+	}
+	for _, tt := range tests {
+		env := newTestEnv(t)
+		env.command(strings.TrimSpace("/country " + tt.arg))
+		if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("/country %s replied %q, want %q", tt.arg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// BACKLOG command flow
+
+func (handler *Handler) handleBacklogCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+
+	shows, err := listShowsWithProgress(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing shows for user %d: %w", userID, err),
+			"Error: can't list shows at this time",
+		)
+	}
+
+	var backlog []ShowProgress
+	for _, show := range shows {
+		if show.EpisodesWaiting > 0 {
+			backlog = append(backlog, show)
+		}
+	}
+	if len(backlog) == 0 {
+		handler.Bot.reply(ctx, chatID, "Your backlog is empty, you're all caught up!")
+		return nil
+	}
+	sort.SliceStable(backlog, func(i, j int) bool {
+		return backlog[i].EpisodesWaiting > backlog[j].EpisodesWaiting
+	})
+
+	var text strings.Builder
+	text.WriteString("Your backlog:\n\n")
+	var rows [][][]string
+	for _, show := range backlog {
+		text.WriteString(fmt.Sprintf("%s — %s behind\n", show.Name, pluralize(show.EpisodesWaiting, "episode")))
+		label := fmt.Sprintf("Update progress: %s", trimString(show.Name, 30))
+		rows = append(rows, [][]string{{label, fmt.Sprintf("setProgress:%d", show.InternalID)}})
+	}
+	text.WriteString("\nTap a show to tell me where you are.")
+
+	handler.Bot.reply(ctx, chatID, text.String(), ReplyOptions{ReplyMarkup: makeKeyboardMarkup(rows)})
+	return nil
+}
+
+func (handler *Handler) handleSetProgressCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, err := strconv.ParseInt(callbackParam, 10, 64)
+	if err != nil {
+		log.Printf("handleSetProgressCallback: invalid show id: %s", callbackParam)
+		return nil
+	}
+
+	userID := cb.From.ID
+	msg := cb.Message
+
+	show, err := getShowByID(ctx, handler.DB, showID)
+	if err != nil || show.UserID != userID {
+		return NewUserError(
+			fmt.Errorf("getting show %d for user %d: %v", showID, userID, err),
+			"Show not found. It may have been removed.",
+		)
+	}
+	providerID, err := strconv.Atoi(show.ProviderShowID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("invalid provider show id %q: %w", show.ProviderShowID, err),
+			"Can't update progress for this show.",
+		)
+	}
+
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.SelectedInternalID = show.ID
+		ctx.SelectedProviderID = providerID
+	})
+
+	intro := fmt.Sprintf("Update progress for \"%s\".", show.Name)
+	if err := handler.askForProgress(ctx, userID, msg.Chat.ID, msg.MessageID, providerID, intro); err != nil {
+		return err
+	}
+
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
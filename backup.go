@@ -0,0 +1,203 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"tvreminder/bot/internal/provider"
+	"tvreminder/bot/internal/store"
+)
+
+const (
+	backupPrefix = "tvreminder-"
+	backupSuffix = ".db"
+	// backupTimeLayout sorts lexically in time order, so the newest backup has the
+	// greatest name.
+	backupTimeLayout = "20060102T150405Z"
+)
+
+type BackupConfig struct {
+	Dir      string
+	Interval time.Duration
+	// Keep is how many backups stay in Dir, older ones are deleted.
+	Keep int
+	// S3 uploads every backup when set.
+	S3 *S3Uploader
+}
+
+// backupConfigFromEnv reads the backup settings. Backups are off without BACKUP_DIR.
+func backupConfigFromEnv() (*BackupConfig, error) {
+	dir := os.Getenv("BACKUP_DIR")
+	if dir == "" {
+		return nil, nil
+	}
+	config := &BackupConfig{Dir: dir, Interval: 24 * time.Hour, Keep: 7}
+	if interval := os.Getenv("BACKUP_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid BACKUP_INTERVAL: %q", interval)
+		}
+		config.Interval = d
+	}
+	if keep := os.Getenv("BACKUP_KEEP"); keep != "" {
+		n, err := strconv.Atoi(keep)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid BACKUP_KEEP: %q", keep)
+		}
+		config.Keep = n
+	}
+	if bucket := os.Getenv("BACKUP_S3_BUCKET"); bucket != "" {
+		config.S3 = &S3Uploader{
+			Endpoint:  os.Getenv("BACKUP_S3_ENDPOINT"),
+			Region:    os.Getenv("BACKUP_S3_REGION"),
+			Bucket:    bucket,
+			AccessKey: os.Getenv("BACKUP_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("BACKUP_S3_SECRET_KEY"),
+			Client:    provider.HTTPClient,
+		}
+		if config.S3.Endpoint == "" || config.S3.AccessKey == "" || config.S3.SecretKey == "" {
+			return nil, fmt.Errorf("BACKUP_S3_BUCKET needs BACKUP_S3_ENDPOINT, BACKUP_S3_ACCESS_KEY and BACKUP_S3_SECRET_KEY")
+		}
+		if config.S3.Region == "" {
+			config.S3.Region = "us-east-1"
+		}
+	}
+	return config, nil
+}
+
+// createBackup writes a consistent snapshot of db into dir. VACUUM INTO runs as a
+// read transaction, so the bot keeps working while it runs.
+func createBackup(ctx context.Context, db *store.DB, dir string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, backupPrefix+now.UTC().Format(backupTimeLayout)+backupSuffix)
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		return "", fmt.Errorf("vacuum into %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// listBackups returns the paths of the backups in dir, oldest first.
+func listBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, filepath.Join(dir, name))
+		}
+	}
+	slices.Sort(backups)
+	return backups, nil
+}
+
+// rotateBackups deletes all but the newest keep backups in dir.
+func rotateBackups(dir string, keep int) error {
+	backups, err := listBackups(dir)
+	if err != nil {
+		return err
+	}
+	for len(backups) > keep {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// restoreLatestBackup replaces the database at dbPath with the newest backup in dir.
+// The current database is kept next to it with a .before-restore suffix. It must
+// run before the database is opened.
+func restoreLatestBackup(dir, dbPath string) (string, error) {
+	backups, err := listBackups(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(backups) == 0 {
+		return "", fmt.Errorf("no backups in %s", dir)
+	}
+	latest := backups[len(backups)-1]
+
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := os.Rename(dbPath, dbPath+".before-restore"); err != nil {
+			return "", err
+		}
+	}
+	// The WAL belongs to the old database and would corrupt the restored one
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	if err := copyFile(latest, dbPath); err != nil {
+		return "", err
+	}
+	return latest, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// runBackup takes one backup, uploads it if configured and rotates old ones.
+func runBackup(ctx context.Context, db *store.DB, config *BackupConfig) error {
+	path, err := createBackup(ctx, db, config.Dir, time.Now())
+	if err != nil {
+		return err
+	}
+	log.Printf("backupLoop: wrote %s", path)
+
+	if config.S3 != nil {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+		err := config.S3.UploadFile(ctx, filepath.Base(path), path)
+		cancel()
+		if err != nil {
+			// The local copy is still there, so keep rotating
+			log.Printf("backupLoop: uploading %s: %v", path, err)
+		}
+	}
+	return rotateBackups(config.Dir, config.Keep)
+}
+
+func backupLoop(db *store.DB, config *BackupConfig, ctx context.Context) {
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := runBackup(ctx, db, config); err != nil {
+				log.Printf("backupLoop: backup failed: %v", err)
+			}
+		case <-ctx.Done():
+			log.Println("backupLoop: context cancelled, exiting")
+			return
+		}
+	}
+}
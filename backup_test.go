@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tvreminder/bot/internal/store"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	db := newTestDB(t)
+	if _, err := addShow(t.Context(), db, testUserID, "Night Shift", "tvmaze", 1, "NBC", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := createBackup(t.Context(), db, dir, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "restored.db")
+	if err := os.WriteFile(dbPath, []byte("broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restoreLatestBackup(dir, dbPath); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := store.Open(t.Context(), store.Config{Path: dbPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+
+	var name string
+	if err := restored.QueryRow(`SELECT name FROM shows`).Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "Night Shift" {
+		t.Errorf("restored show %q, want Night Shift", name)
+	}
+	if data, _ := os.ReadFile(dbPath + ".before-restore"); string(data) != "broken" {
+		t.Errorf("previous database not kept, got %q", data)
+	}
+}
+
+func TestRotateBackups(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		name := backupPrefix + start.AddDate(0, 0, i).Format(backupTimeLayout) + backupSuffix
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Unrelated files are left alone
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rotateBackups(dir, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := listBackups(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"tvreminder-20260104T000000Z.db", "tvreminder-20260105T000000Z.db"}
+	if len(backups) != len(want) {
+		t.Fatalf("kept %v, want %v", backups, want)
+	}
+	for i := range want {
+		if filepath.Base(backups[i]) != want[i] {
+			t.Errorf("kept %v, want %v", backups, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("unrelated file removed: %v", err)
+	}
+}
+
+func TestS3Put(t *testing.T) {
+	var gotPath, gotAuth, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotPath, gotAuth, gotBody = r.URL.Path, r.Header.Get("Authorization"), string(body)
+	}))
+	defer server.Close()
+
+	s3 := &S3Uploader{
+		Endpoint: server.URL, Region: "eu-central-1", Bucket: "backups",
+		AccessKey: "AKID", SecretKey: "secret", Client: server.Client(),
+	}
+	now := time.Date(2026, 10, 17, 5, 0, 0, 0, time.UTC)
+	if err := s3.Put(context.Background(), "tvreminder-1.db", []byte("data"), now); err != nil {
+		t.Fatal(err)
+	}
+
+	if gotPath != "/backups/tvreminder-1.db" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotBody != "data" {
+		t.Errorf("body = %q", gotBody)
+	}
+	wantPrefix := "AWS4-HMAC-SHA256 Credential=AKID/20261017/eu-central-1/s3/aws4_request, " +
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="
+	if !strings.HasPrefix(gotAuth, wantPrefix) {
+		t.Errorf("authorization = %q, want prefix %q", gotAuth, wantPrefix)
+	}
+}
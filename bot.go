@@ -1,11 +1,22 @@
-package main
+package bot
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/provider"
+	"tvreminder/bot/internal/reminder"
+	"tvreminder/bot/internal/store"
+	"tvreminder/bot/internal/telegram"
 )
 
 type UserState int
@@ -15,6 +26,13 @@ const (
 	StateAwaitingShowName
 	StateAwaitingShowSelection
 	StateAwaitingSeasonEpisode
+	StateAwaitingImport
+	StateAwaitingNote
+	StateAwaitingMuteDate
+	StateAwaitingTemplate
+	StateAwaitingDropReason
+	StateAwaitingTag
+	StateAwaitingOffset
 )
 
 type UserContext struct {
@@ -24,25 +42,107 @@ type UserContext struct {
 	SelectedProviderID int
 	SelectedSeason     int
 	ShowsList          []ShowProgress
+	MovieResults       []Movie
+	Suggestions        []string
+	// BrowseResults and BrowseQuery hold the last /search, apart from /add's state.
+	BrowseResults []ShowSearchResult
+	BrowseQuery   string
+	// ShowsFilter is what /shows is narrowed to, see platforms.go.
+	ShowsFilter showsFilter
+	// ListTag is the tag whose shows the "tagged" list holds, see tags.go.
+	ListTag string
 }
 
+// TelegramAPI is the Telegram client, see internal/telegram.
+type TelegramAPI = telegram.API
+
 type Bot struct {
-	BotApi       *tgbotapi.BotAPI
-	DB           *sql.DB
+	BotApi TelegramAPI
+	// Username is the bot's @username, used to build t.me links.
+	Username string
+	// Token and FileEndpoint build download URLs for files users send, FileEndpoint
+	// being a format like tgbotapi.FileEndpoint.
+	Token        string
+	FileEndpoint string
+	DB           *store.DB
+	// Callbacks keeps callback data too long for a button. Without it keyboards
+	// with long data fail to send.
+	Callbacks *CallbackStore
+	// Signer signs callback data, so buttons can't be forged or replayed in
+	// another chat.
+	Signer *telegram.Signer
+	// Templates are the operator's reminder templates.
+	Templates    reminder.Templates
 	UserContexts map[int64]*UserContext
-	mu           sync.Mutex
+	// updateThreads holds the topics of received forum updates by update ID.
+	updateThreads map[int]int
+	// updateReactions holds the reactions of received updates by update ID.
+	updateReactions map[int]*MessageReactionUpdated
+	// chatAdmins caches getChatMember answers, see isChatAdmin.
+	chatAdmins map[chatUser]cachedChatAdmin
+	// cancelPrompts holds the last message with a Cancel button in each chat, see cancel.go.
+	cancelPrompts map[int64]int
+	mu            sync.Mutex
 }
 
 type ReplyOptions struct {
 	ReplyMarkup   any
 	ParseMode     string
 	EditMessageID int
+	// ThreadID sends the message to a forum topic. Without it the message goes to
+	// the topic of the update ctx handles, if it came from the same chat.
+	ThreadID int
+	// Silent delivers the message without a sound. Edits don't notify anyway.
+	Silent bool
+	// Lasting keyboards stay usable for telegram.LastingCallbackTTL instead of
+	// telegram.CallbackTTL, for notifications the loops send.
+	Lasting bool
+}
+
+// callbackExpiry is when the keyboard of a message sent at now expires.
+func (opt ReplyOptions) callbackExpiry(now time.Time) time.Time {
+	if opt.Lasting {
+		return now.Add(telegram.LastingCallbackTTL)
+	}
+	return now.Add(telegram.CallbackTTL)
 }
 
 func (bot *Bot) setCommands() {
 	commands := []tgbotapi.BotCommand{
 		{Command: "add", Description: "Add a TV show to track"},
+		{Command: "search", Description: "Look a show up without adding it"},
 		{Command: "shows", Description: "List your tracked shows"},
+		{Command: "queue", Description: "What to watch next"},
+		{Command: "pick", Description: "What to watch tonight"},
+		{Command: "trending", Description: "Shows other people track this week"},
+		{Command: "watchlist", Description: "Shows you follow without tracking"},
+		{Command: "tags", Description: "Your tags and the shows in them"},
+		{Command: "next", Description: "When the next episode airs"},
+		{Command: "upcoming", Description: "Episodes and movies coming soon"},
+		{Command: "addmovie", Description: "Add a movie to track"},
+		{Command: "movies", Description: "List your movies"},
+		{Command: "backlog", Description: "Aired episodes you haven't watched"},
+		{Command: "reminders", Description: "Manage your upcoming reminders"},
+		{Command: "quiet", Description: "Set quiet hours for reminders"},
+		{Command: "timezone", Description: "Set your time zone"},
+		{Command: "country", Description: "Set your country for streaming info"},
+		{Command: "autobackup", Description: "Monthly backup of your data"},
+		{Command: "webhook", Description: "Send events to your own server"},
+		{Command: "trakt", Description: "Sync watched episodes with Trakt"},
+		{Command: "discord", Description: "Get reminders in Discord"},
+		{Command: "digest", Description: "Daily or weekly digest of upcoming episodes"},
+		{Command: "tonight", Description: "Evening summary of what airs tonight"},
+		{Command: "settings", Description: "Show your settings"},
+		{Command: "export", Description: "Download your data"},
+		{Command: "import", Description: "Restore data from an export"},
+		{Command: "watchparty", Description: "Watch a show together in a group"},
+		{Command: "groupsettings", Description: "Who can manage a group's watch parties"},
+		{Command: "stats", Description: "Your ratings and stats"},
+		{Command: "recap", Description: "What you watched in a year"},
+		{Command: "trash", Description: "Restore deleted shows"},
+		{Command: "merge", Description: "Merge shows added twice"},
+		{Command: "invite", Description: "Invite friends to the bot"},
+		{Command: "cancel", Description: "Stop what you're in the middle of"},
 		{Command: "help", Description: "Show help information"},
 	}
 	if _, err := bot.BotApi.Request(tgbotapi.NewSetMyCommands(commands...)); err != nil {
@@ -50,34 +150,159 @@ func (bot *Bot) setCommands() {
 	}
 }
 
-func (bot *Bot) reply(chatID int64, text string, opts ...ReplyOptions) {
+func (bot *Bot) reply(ctx context.Context, chatID int64, text string, opts ...ReplyOptions) {
+	if _, err := bot.send(ctx, chatID, text, opts...); err != nil {
+		log.Printf("reply: sending to chat %d failed: %v", chatID, err)
+	}
+}
+
+// send is like reply but reports delivery errors to the caller. ctx bounds storing
+// long callback data of the keyboard, see signKeyboard.
+func (bot *Bot) send(ctx context.Context, chatID int64, text string, opts ...ReplyOptions) (tgbotapi.Message, error) {
 	var opt ReplyOptions
 	if len(opts) > 0 {
 		opt = opts[0]
 	}
+	markup, err := bot.signKeyboard(ctx, opt.ReplyMarkup, chatID, opt.callbackExpiry(time.Now()))
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
 
 	if opt.EditMessageID != 0 {
 		editMsg := tgbotapi.NewEditMessageText(chatID, opt.EditMessageID, text)
-		if opt.ReplyMarkup != nil {
-			if markup, ok := opt.ReplyMarkup.(*tgbotapi.InlineKeyboardMarkup); ok {
-				editMsg.ReplyMarkup = markup
-			}
+		if keyboard, ok := markup.(*tgbotapi.InlineKeyboardMarkup); ok {
+			editMsg.ReplyMarkup = keyboard
 		}
 		if opt.ParseMode != "" {
 			editMsg.ParseMode = opt.ParseMode
 		}
-		bot.BotApi.Send(editMsg)
+		sent, err := bot.BotApi.Send(editMsg)
+		if err == nil {
+			bot.trackCancelPrompt(chatID, opt.EditMessageID, opt.ReplyMarkup)
+		}
+		return sent, err
+	} else if threadID := replyThread(ctx, chatID, opt); threadID != 0 {
+		params := tgbotapi.Params{"text": text}
+		params.AddFirstValid("chat_id", chatID)
+		params.AddNonZero("message_thread_id", threadID)
+		params.AddNonEmpty("parse_mode", opt.ParseMode)
+		params.AddBool("disable_notification", opt.Silent)
+		if keyboard, ok := markup.(*tgbotapi.InlineKeyboardMarkup); ok {
+			if err := params.AddInterface("reply_markup", keyboard); err != nil {
+				return tgbotapi.Message{}, err
+			}
+		}
+		sent, err := bot.sendRaw("sendMessage", params)
+		if err == nil {
+			bot.trackCancelPrompt(chatID, sent.MessageID, opt.ReplyMarkup)
+		}
+		return sent, err
 	} else {
 		message := tgbotapi.NewMessage(chatID, text)
-		if opt.ReplyMarkup != nil {
-			if markup, ok := opt.ReplyMarkup.(*tgbotapi.InlineKeyboardMarkup); ok {
-				message.ReplyMarkup = markup
-			}
+		if keyboard, ok := markup.(*tgbotapi.InlineKeyboardMarkup); ok {
+			message.ReplyMarkup = keyboard
 		}
 		if opt.ParseMode != "" {
 			message.ParseMode = opt.ParseMode
 		}
-		bot.BotApi.Send(message)
+		message.DisableNotification = opt.Silent
+		sent, err := bot.BotApi.Send(message)
+		if err == nil {
+			bot.trackCancelPrompt(chatID, sent.MessageID, opt.ReplyMarkup)
+		}
+		return sent, err
+	}
+}
+
+// isChatUnreachable reports whether err means the bot can no longer message the chat
+// (blocked by the user, deactivated account, deleted chat), so retrying is pointless.
+func isChatUnreachable(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == 403 || (apiErr.Code == 400 && strings.Contains(apiErr.Message, "chat not found"))
+}
+
+// retryAfter returns the flood-control delay Telegram asked for, or zero.
+func retryAfter(err error) time.Duration {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return time.Duration(apiErr.RetryAfter) * time.Second
+	}
+	return 0
+}
+
+// sendPhoto sends the image at photoURL with an HTML caption. Telegram fetches the
+// URL itself, so a dead link fails the request with a 400.
+func (bot *Bot) sendPhoto(ctx context.Context, chatID int64, photoURL, caption string, opts ...ReplyOptions) (tgbotapi.Message, error) {
+	var opt ReplyOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	markup, err := bot.signKeyboard(ctx, opt.ReplyMarkup, chatID, opt.callbackExpiry(time.Now()))
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+	if threadID := replyThread(ctx, chatID, opt); threadID != 0 {
+		params := tgbotapi.Params{"photo": photoURL, "caption": caption, "parse_mode": "HTML"}
+		params.AddFirstValid("chat_id", chatID)
+		params.AddNonZero("message_thread_id", threadID)
+		params.AddBool("disable_notification", opt.Silent)
+		if keyboard, ok := markup.(*tgbotapi.InlineKeyboardMarkup); ok {
+			if err := params.AddInterface("reply_markup", keyboard); err != nil {
+				return tgbotapi.Message{}, err
+			}
+		}
+		return bot.sendRaw("sendPhoto", params)
+	}
+
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileURL(photoURL))
+	photo.Caption = caption
+	photo.ParseMode = "HTML"
+	if markup != nil {
+		photo.ReplyMarkup = markup
+	}
+	photo.DisableNotification = opt.Silent
+	return bot.BotApi.Send(photo)
+}
+
+func (bot *Bot) sendDocument(chatID int64, name string, data []byte, caption string) error {
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	doc.Caption = caption
+	_, err := bot.BotApi.Send(doc)
+	return err
+}
+
+// downloadFile fetches a file a user sent, refusing files larger than maxSize.
+func (bot *Bot) downloadFile(fileID string, maxSize int64) ([]byte, error) {
+	file, err := bot.BotApi.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		return nil, fmt.Errorf("getting file %s: %w", fileID, err)
+	}
+	resp, err := provider.HTTPClient.Get(fmt.Sprintf(bot.FileEndpoint, bot.Token, file.FilePath))
+	if err != nil {
+		return nil, fmt.Errorf("downloading file %s: %w", fileID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading file %s: status %d", fileID, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("downloading file %s: %w", fileID, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("file %s is larger than %d bytes", fileID, maxSize)
+	}
+	return data, nil
+}
+
+// sendChatAction shows a status like "typing…" in the chat until the next message
+// or for about five seconds.
+func (bot *Bot) sendChatAction(chatID int64, action string) {
+	if _, err := bot.BotApi.Request(tgbotapi.NewChatAction(chatID, action)); err != nil {
+		log.Printf("sendChatAction: chat %d: %v", chatID, err)
 	}
 }
 
@@ -86,6 +311,12 @@ func (bot *Bot) answerCallbackQuery(callbackQueryID string) (*tgbotapi.APIRespon
 	return bot.BotApi.Request(cb_response)
 }
 
+// answerCallbackAlert answers a callback query with text in an alert the user has
+// to dismiss.
+func (bot *Bot) answerCallbackAlert(callbackQueryID, text string) (*tgbotapi.APIResponse, error) {
+	return bot.BotApi.Request(tgbotapi.NewCallbackWithAlert(callbackQueryID, text))
+}
+
 func (bot *Bot) withUserContext(userID int64, fn func(*UserContext)) {
 	bot.mu.Lock()
 	defer bot.mu.Unlock()
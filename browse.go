@@ -0,0 +1,287 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// The episode browser lists a show's seasons and their episodes, with a ✅ on the
+// watched ones. Progress is a single "watched up to" episode, so an episode counts as
+// watched when it's at or before it, and tapping one moves the progress: to the
+// episode when it's unwatched, or to the one before it when it's watched. Episodes
+// kept in the backlog after a gap stay unwatched until tapped, which marks just them.
+
+// browsePageSize is the number of seasons or episodes on one page of the browser.
+const browsePageSize = 10
+
+// episodeWatched reports whether an episode is covered by the show's progress.
+// Specials are never part of it.
+func episodeWatched(show *ShowProgress, season, number int) bool {
+	if season == 0 || !show.Season.Valid || !show.Episode.Valid {
+		return false
+	}
+	watchedSeason := int(show.Season.Int32)
+	return season < watchedSeason || season == watchedSeason && number <= int(show.Episode.Int32)
+}
+
+// seasonWatched reports whether all of a season is covered by the show's progress.
+func seasonWatched(show *ShowProgress, season int) bool {
+	if !episodeWatched(show, season, 0) {
+		return false
+	}
+	// Progress within the season leaves its next episode in it
+	return !show.NextEpisodeSeason.Valid || int(show.NextEpisodeSeason.Int32) != season
+}
+
+// pageBounds returns the slice bounds of a page of n items, clamping page to the
+// last page.
+func pageBounds(n, page int) (start, end, lastPage int) {
+	lastPage = max(0, (n-1)/browsePageSize)
+	page = min(max(page, 0), lastPage)
+	start = page * browsePageSize
+	end = min(start+browsePageSize, n)
+	return start, end, lastPage
+}
+
+// pageNavRow returns the previous and next page buttons, nil for a single page.
+// callbackPrefix is completed with the page number.
+func pageNavRow(page, lastPage int, callbackPrefix string) [][]string {
+	var row [][]string
+	if page > 0 {
+		row = append(row, []string{"◀️ Previous", fmt.Sprintf("%s%d", callbackPrefix, page-1)})
+	}
+	if page < lastPage {
+		row = append(row, []string{"Next ▶️", fmt.Sprintf("%s%d", callbackPrefix, page+1)})
+	}
+	return row
+}
+
+// handleBrowseShowCallback lists a page of the show's seasons.
+func (handler *Handler) handleBrowseShowCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, args, ok := parseReleaseCallback(callbackParam, 1)
+	if !ok {
+		log.Printf("handleBrowseShowCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	page, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Printf("handleBrowseShowCallback: invalid page: %s", args[0])
+		return nil
+	}
+	show, err := handler.validateAndGetShow(ctx, cb.From.ID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	allSeasons, err := getSeasons(ctx, handler.DB, show.Provider, show.ProviderShowID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting seasons for show %s: %w", show.ProviderShowID, err),
+			"Error fetching seasons",
+		)
+	}
+	var seasons []int
+	for _, season := range allSeasons {
+		if season > 0 {
+			seasons = append(seasons, season)
+		}
+	}
+	if len(seasons) == 0 {
+		return NewUserError(
+			fmt.Errorf("no seasons for show %s", show.ProviderShowID),
+			"No episodes are known for this show yet.",
+		)
+	}
+
+	skipped, err := listSkippedEpisodes(ctx, handler.DB, show.InternalID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing skipped episodes of show %d: %w", show.InternalID, err),
+			"Error fetching seasons",
+		)
+	}
+	skippedSeasons := make(map[int]bool, len(skipped))
+	for _, season := range skipped {
+		skippedSeasons[season] = true
+	}
+
+	start, end, lastPage := pageBounds(len(seasons), page)
+	page = start / browsePageSize
+	var rows [][][]string
+	for _, season := range seasons[start:end] {
+		label := fmt.Sprintf("Season %d", season)
+		if seasonWatched(show, season) && !skippedSeasons[season] {
+			label = "✅ " + label
+		}
+		rows = append(rows, [][]string{{label, fmt.Sprintf("browseSeason:%d:%s:%d:0", showID, listType, season)}})
+	}
+	if nav := pageNavRow(page, lastPage, fmt.Sprintf("browseShow:%d:%s:", showID, listType)); nav != nil {
+		rows = append(rows, nav)
+	}
+	rows = append(rows, [][]string{{"<< Back", fmt.Sprintf("selectShow:%d:%s", showID, listType)}})
+
+	text := fmt.Sprintf("<b>%s</b>\nPick a season:", html.EscapeString(show.Name))
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, text, ReplyOptions{
+		ReplyMarkup: makeKeyboardMarkup(rows), ParseMode: "HTML", EditMessageID: cb.Message.MessageID,
+	})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleBrowseSeasonCallback lists a page of a season's episodes.
+func (handler *Handler) handleBrowseSeasonCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, args, ok := parseReleaseCallback(callbackParam, 2)
+	if !ok {
+		log.Printf("handleBrowseSeasonCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	season, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Printf("handleBrowseSeasonCallback: invalid season: %s", args[0])
+		return nil
+	}
+	page, err := strconv.Atoi(args[1])
+	if err != nil {
+		log.Printf("handleBrowseSeasonCallback: invalid page: %s", args[1])
+		return nil
+	}
+	show, err := handler.validateAndGetShow(ctx, cb.From.ID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+	return handler.renderSeasonBrowser(ctx, cb, show, showID, listType, season, page)
+}
+
+func (handler *Handler) renderSeasonBrowser(ctx context.Context, cb *tgbotapi.CallbackQuery, show *ShowProgress, showID int, listType string, season, page int) error {
+	episodes, err := getEpisodesBySeason(ctx, handler.DB, show.Provider, show.ProviderShowID, season)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting episodes for show %s season %d: %w", show.ProviderShowID, season, err),
+			"Error fetching episodes",
+		)
+	}
+
+	skipped, err := listSkippedEpisodes(ctx, handler.DB, show.InternalID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing skipped episodes of show %d: %w", show.InternalID, err),
+			"Error fetching episodes",
+		)
+	}
+
+	start, end, lastPage := pageBounds(len(episodes), page)
+	page = start / browsePageSize
+	var rows [][][]string
+	for _, episode := range episodes[start:end] {
+		label := fmt.Sprintf("%d. %s", episode.Number, trimString(episode.Title, 40))
+		if _, ok := skipped[episode.ID]; !ok && episodeWatched(show, season, episode.Number) {
+			label = "✅ " + label
+		}
+		rows = append(rows, [][]string{{
+			label, fmt.Sprintf("toggleWatched:%d:%s:%d:%d:%d", showID, listType, season, episode.Number, page),
+		}})
+	}
+	if nav := pageNavRow(page, lastPage, fmt.Sprintf("browseSeason:%d:%s:%d:", showID, listType, season)); nav != nil {
+		rows = append(rows, nav)
+	}
+	rows = append(rows, [][]string{
+		{"<< Seasons", fmt.Sprintf("browseShow:%d:%s:%d", showID, listType, (season-1)/browsePageSize)},
+		{"Show", fmt.Sprintf("selectShow:%d:%s", showID, listType)},
+	})
+
+	text := fmt.Sprintf(
+		"<b>%s</b>, season %d\nTap an episode to mark it watched, or a ✅ one to unmark it.",
+		html.EscapeString(show.Name), season,
+	)
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, text, ReplyOptions{
+		ReplyMarkup: makeKeyboardMarkup(rows), ParseMode: "HTML", EditMessageID: cb.Message.MessageID,
+	})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleToggleWatchedCallback moves the show's progress to the tapped episode, or to
+// the one before it if it was watched, and re-renders the season. A skipped episode
+// is only marked watched.
+func (handler *Handler) handleToggleWatchedCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, args, ok := parseReleaseCallback(callbackParam, 3)
+	if !ok {
+		log.Printf("handleToggleWatchedCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	var numbers [3]int
+	for i, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			log.Printf("handleToggleWatchedCallback: invalid callback parameter: %s", callbackParam)
+			return nil
+		}
+		numbers[i] = n
+	}
+	season, number, page := numbers[0], numbers[1], numbers[2]
+
+	userID := cb.From.ID
+	show, err := handler.validateAndGetShow(ctx, userID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+	episode, err := findEpisodeByNumber(ctx, handler.DB, show.Provider, show.ProviderShowID, season, number)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("finding episode S%02dE%02d of show %s: %w", season, number, show.ProviderShowID, err),
+			"I can't find this episode anymore, please open the show again.",
+		)
+	}
+
+	unskipped, err := unskipEpisode(ctx, handler.DB, show.InternalID, episode.ID, handler.Clock.Now())
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("marking skipped episode %d of show %d watched: %w", episode.ID, show.InternalID, err),
+			"Error updating progress",
+		)
+	}
+	if unskipped {
+		return handler.renderSeasonBrowser(ctx, cb, show, showID, listType, season, page)
+	}
+
+	watched := episodeWatched(show, season, number)
+	progress := episode
+	if watched {
+		progress, err = findPreviousEpisode(ctx, handler.DB, show.Provider, show.ProviderShowID, season, number)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return NewUserError(
+				fmt.Errorf("finding episode before S%02dE%02d of show %s: %w", season, number, show.ProviderShowID, err),
+				"Error updating progress",
+			)
+		}
+	}
+	var progressID int64
+	if progress != nil {
+		progressID = progress.ID
+	}
+	if err := updateLastWatchedEpisode(ctx, handler.DB, show.InternalID, progressID, handler.Clock.Now()); err != nil {
+		return NewUserError(
+			fmt.Errorf("updating last watched episode for show %d: %w", show.InternalID, err),
+			"Error updating progress",
+		)
+	}
+
+	show, listType, err = handler.reloadShowsList(ctx, userID, show, listType)
+	if err != nil {
+		return err
+	}
+	if err := handler.renderSeasonBrowser(ctx, cb, show, int(show.InternalID), listType, season, page); err != nil {
+		return err
+	}
+	if !watched {
+		handler.offerSeasonRating(ctx, cb.Message.Chat.ID, show.InternalID, show.Name, episode)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBrowseEpisodes(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	env.command("/history")
+	env.press("selectShow:1:history")
+
+	env.press("browseShow:1:history:0")
+	if got, want := env.telegram.lastMessage(t).labels(t), []string{"✅ Season 1", "Season 2", "<< Back"}; !slices.Equal(got, want) {
+		t.Errorf("seasons = %v, want %v", got, want)
+	}
+
+	env.press("browseSeason:1:history:2:0")
+	want := []string{"✅ 1. Episode 2.1", "✅ 2. Episode 2.2", "3. Episode 2.3", "<< Seasons", "Show"}
+	if got := env.telegram.lastMessage(t).labels(t); !slices.Equal(got, want) {
+		t.Errorf("episodes = %v, want %v", got, want)
+	}
+
+	// Unmarking a watched episode moves progress to the one before it
+	env.press("toggleWatched:1:history:2:1:0")
+	want = []string{"1. Episode 2.1", "2. Episode 2.2", "3. Episode 2.3", "<< Seasons", "Show"}
+	if got := env.telegram.lastMessage(t).labels(t); !slices.Equal(got, want) {
+		t.Errorf("episodes after unmarking S02E01 = %v, want %v", got, want)
+	}
+	progress := `SELECT e.season || 'x' || e.number FROM shows s JOIN episodes_cache e ON e.id = s.last_watched_episode_id`
+	if got := queryString(t, env, progress); got != "1x3" {
+		t.Errorf("progress after unmarking S02E01 = %s, want 1x3", got)
+	}
+
+	env.press("toggleWatched:1:history:2:3:0")
+	if got := queryString(t, env, progress); got != "2x3" {
+		t.Errorf("progress after marking S02E03 = %s, want 2x3", got)
+	}
+}
+
+func TestBrowseUnmarkFirstEpisode(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.command("/add night")
+	env.press("acceptShowName:1")
+	env.press("selectSeason:1")
+	env.press("selectEpisode:1")
+	env.command("/history")
+	env.press("selectShow:1:history")
+
+	env.press("toggleWatched:1:history:1:1:0")
+	if got := queryString(t, env, `SELECT last_watched_episode_id FROM shows`); got != "NULL" {
+		t.Errorf("progress after unmarking S01E01 = %s, want NULL", got)
+	}
+}
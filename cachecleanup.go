@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/store"
+)
+
+// Episodes are cached per provider show and shared by everyone tracking it, so
+// nothing removes them when the last user stops. cacheCleanupLoop purges the
+// episodes of shows nobody tracks anymore, counting trashed shows and watch parties
+// as tracking, and compacts the database once enough rows are gone. Episodes still
+// referenced from elsewhere, like the watch log, are kept. The loop also purges
+// the expired payloads of the callback store.
+
+// cacheCleanupInterval is how often the episode cache is cleaned up.
+const cacheCleanupInterval = 24 * time.Hour
+
+// minCompactRows is how many purged episodes make compacting the database worth
+// blocking writers while it runs.
+const minCompactRows = 1000
+
+// compactTimeout bounds compacting the database, which takes longer than a query.
+const compactTimeout = 10 * time.Minute
+
+// CacheCleanupSummary describes the episode cache cleanups since the bot started,
+// for /admin stats.
+type CacheCleanupSummary struct {
+	// LastRun is when the cache was last cleaned up, zero when it wasn't yet.
+	LastRun     time.Time
+	LastPurged  int64
+	TotalPurged int64
+	Compactions int
+}
+
+// CacheCleanupStats counts the cleanups of cacheCleanupLoop. The loop and /admin
+// stats share one; a nil CacheCleanupStats counts nothing.
+type CacheCleanupStats struct {
+	mu      sync.Mutex
+	summary CacheCleanupSummary
+}
+
+func (s *CacheCleanupStats) record(at time.Time, purged int64, compacted bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summary.LastRun = at
+	s.summary.LastPurged = purged
+	s.summary.TotalPurged += purged
+	if compacted {
+		s.summary.Compactions++
+	}
+}
+
+// Summary returns the cleanups so far.
+func (s *CacheCleanupStats) Summary() CacheCleanupSummary {
+	if s == nil {
+		return CacheCleanupSummary{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.summary
+}
+
+// purgeStaleEpisodes deletes the cached episodes of provider shows that no show or
+// watch party tracks and nothing refers to. It returns the number of episodes purged.
+func purgeStaleEpisodes(ctx context.Context, db *store.DB) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var referenced strings.Builder
+	for _, ref := range episodeRefs {
+		fmt.Fprintf(&referenced, "\n\t\tAND e.id NOT IN (SELECT %s FROM %s WHERE %[1]s IS NOT NULL)", ref.column, ref.table)
+	}
+	result, err := db.ExecContext(ctx, `
+		DELETE FROM episodes_cache AS e
+		WHERE NOT EXISTS (
+			SELECT 1 FROM shows s WHERE s.provider = e.provider AND s.provider_show_id = e.provider_show_id
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM group_shows g WHERE g.provider = e.provider AND g.provider_show_id = e.provider_show_id
+		)`+referenced.String())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// compactDB rebuilds the database file to give the space of deleted rows back.
+func compactDB(ctx context.Context, db *store.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, compactTimeout)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `VACUUM`)
+	return err
+}
+
+// cleanupEpisodeCache purges stale episodes and compacts the database if that
+// freed enough, one run of cacheCleanupLoop. The run is counted in stats.
+func cleanupEpisodeCache(ctx context.Context, db *store.DB, stats *CacheCleanupStats, now time.Time) error {
+	purged, err := purgeStaleEpisodes(ctx, db)
+	if err != nil {
+		return fmt.Errorf("purging stale episodes: %w", err)
+	}
+	compacted := false
+	if purged >= minCompactRows {
+		if err := compactDB(ctx, db); err != nil {
+			log.Printf("cacheCleanupLoop: compacting the database: %v", err)
+		} else {
+			compacted = true
+		}
+	}
+	stats.record(now, purged, compacted)
+	if purged > 0 {
+		log.Printf("cacheCleanupLoop: purged %d episodes of untracked shows, compacted: %t", purged, compacted)
+	}
+	return nil
+}
+
+// cacheCleanupLoop periodically cleans up the episode cache, counting the cleanups
+// in stats, and the callback store.
+func cacheCleanupLoop(db *store.DB, callbacks *CallbackStore, stats *CacheCleanupStats, clk clock.Clock, ctx context.Context) {
+	ticker := time.NewTicker(cacheCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Menus expire on the wall clock, like the callbacks they're pressed with
+			if purged, err := callbacks.Purge(ctx, time.Now()); err != nil {
+				log.Printf("cacheCleanupLoop: purging callback payloads: %v", err)
+			} else if purged > 0 {
+				log.Printf("cacheCleanupLoop: purged %d expired callback payloads", purged)
+			}
+			if err := cleanupEpisodeCache(ctx, db, stats, clk.Now()); err != nil {
+				log.Printf("cacheCleanupLoop: %v", err)
+			}
+		case <-ctx.Done():
+			log.Println("cacheCleanupLoop: context cancelled, exiting")
+			return
+		}
+	}
+}
+
+// formatCacheCleanup is the episode cache line of /admin stats.
+func formatCacheCleanup(s CacheCleanupSummary) string {
+	if s.LastRun.IsZero() {
+		return "Episode cache: not cleaned up since the bot started"
+	}
+	return fmt.Sprintf(
+		"Episode cache: %d purged at %s UTC, %d since the bot started, %s",
+		s.LastPurged, s.LastRun.UTC().Format("Jan 2 15:04"), s.TotalPurged, pluralize(s.Compactions, "compaction"),
+	)
+}
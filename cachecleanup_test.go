@@ -0,0 +1,39 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCleanupEpisodeCache(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.handler.Admins = map[int64]bool{testUserID: true}
+	trackShow(t, env, "1")
+	env.command("/add solo")
+	env.press("acceptShowName:1")
+	soloEpisodes := queryString(t, env, `SELECT COUNT(*) FROM episodes_cache WHERE provider_show_id = '2'`)
+	if soloEpisodes == "0" {
+		t.Fatal("no episodes cached for Solo")
+	}
+	// Nobody tracks Solo anymore once its show is gone for good
+	env.handler.DB.Exec(`DELETE FROM reminders WHERE show_id IN (SELECT id FROM shows WHERE provider_show_id = '2')`)
+	env.handler.DB.Exec(`DELETE FROM shows WHERE provider_show_id = '2'`)
+	nightShiftEpisodes := queryString(t, env, `SELECT COUNT(*) FROM episodes_cache WHERE provider_show_id = '1'`)
+
+	env.handler.CacheCleanup = &CacheCleanupStats{}
+	if err := cleanupEpisodeCache(t.Context(), env.handler.DB, env.handler.CacheCleanup, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM episodes_cache WHERE provider_show_id = '2'`); got != "0" {
+		t.Errorf("%s episodes of Solo left, want none", got)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM episodes_cache WHERE provider_show_id = '1'`); got != nightShiftEpisodes {
+		t.Errorf("%s episodes of Night Shift left, want all %s", got, nightShiftEpisodes)
+	}
+
+	env.command("/admin stats")
+	if text := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(text, "Episode cache: "+soloEpisodes+" purged at ") {
+		t.Errorf("/admin stats = %q, want the %s episodes purged", text, soloEpisodes)
+	}
+}
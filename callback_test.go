@@ -0,0 +1,40 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"tvreminder/bot/internal/telegram"
+)
+
+func TestExpiredMenu(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.command("/add night")
+	menu := env.telegram.lastMessage(t)
+	env.pressRaw(env.handler.Bot.Signer.SignCallback("acceptShowName:1", testUserID, time.Now().Add(-time.Minute)))
+
+	if got := env.telegram.lastAlert(t); got != "This menu expired, please open it again." {
+		t.Errorf("alert = %q, want the expired menu notice", got)
+	}
+	if last := env.telegram.lastMessage(t); last.Method != menu.Method || last.Params.Get("text") != menu.Params.Get("text") {
+		t.Errorf("last message = %s %q, want the menu left alone", last.Method, last.Params.Get("text"))
+	}
+	var shows int
+	env.handler.DB.QueryRow(`SELECT COUNT(*) FROM shows`).Scan(&shows)
+	if shows != 0 {
+		t.Errorf("expired menu added %d shows", shows)
+	}
+}
+
+func TestMenuFromAnotherChat(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.command("/add night")
+	env.pressRaw(env.handler.Bot.Signer.SignCallback("acceptShowName:1", testUserID+1, time.Now().Add(telegram.CallbackTTL)))
+
+	if got := env.telegram.lastAlert(t); got != "This menu expired, please open it again." {
+		t.Errorf("pressing a button signed for another chat replied %q", got)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM shows`); got != "0" {
+		t.Errorf("button signed for another chat added %s shows", got)
+	}
+}
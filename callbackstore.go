@@ -0,0 +1,169 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+	"tvreminder/bot/internal/telegram"
+)
+
+// Telegram caps the callback data of a button at 64 bytes. Buttons whose signed
+// data fits carry it themselves; the others carry a short random key, and their
+// data waits in the callback store until the menu expires and cacheCleanupLoop
+// purges it. Keyboards are built with plain data and signed for their chat when
+// sent, see signKeyboard, so no caller has to count bytes.
+
+// callbackKeyPrefix marks callback data that is a callback store key. Signed data
+// never starts with it, actions being words.
+const callbackKeyPrefix = "~"
+
+// CallbackStore keeps the callback data of buttons that is too long for Telegram.
+type CallbackStore struct {
+	db *store.DB
+}
+
+func NewCallbackStore(db *store.DB) *CallbackStore {
+	return &CallbackStore{db: db}
+}
+
+// callbackPayload is what the store keeps for a key.
+type callbackPayload struct {
+	Data   string `json:"data"`
+	ChatID int64  `json:"chat_id"`
+}
+
+// Put stores data for chatID until expires and returns the callback data to send
+// instead.
+func (s *CallbackStore) Put(ctx context.Context, data string, chatID int64, expires time.Time) (string, error) {
+	ctx, cancel := s.db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	key := make([]byte, 9)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(callbackPayload{Data: data, ChatID: chatID})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(key)
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO callback_payloads (key, payload, expires_at) VALUES (?, ?, ?)
+	`, encoded, string(payload), expires.UTC().Format(time.RFC3339))
+	if err != nil {
+		return "", err
+	}
+	return callbackKeyPrefix + encoded, nil
+}
+
+// Get returns the data stored for callback data from Put. Unknown keys, which
+// include purged ones, are expired, and keys pressed outside their chat invalid.
+func (s *CallbackStore) Get(ctx context.Context, key string, chatID int64, now time.Time) (string, error) {
+	ctx, cancel := s.db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var payload string
+	var expiresAt time.Time
+	err := s.db.QueryRowContext(ctx, `
+		SELECT payload, expires_at FROM callback_payloads WHERE key = ?
+	`, strings.TrimPrefix(key, callbackKeyPrefix)).Scan(&payload, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", telegram.ErrCallbackExpired
+	}
+	if err != nil {
+		return "", err
+	}
+	if !now.Before(expiresAt) {
+		return "", telegram.ErrCallbackExpired
+	}
+	var p callbackPayload
+	if err := json.Unmarshal([]byte(payload), &p); err != nil {
+		return "", err
+	}
+	if p.ChatID != chatID {
+		return "", telegram.ErrCallbackInvalid
+	}
+	return p.Data, nil
+}
+
+// Purge deletes the payloads expired at now, for cacheCleanupLoop, and returns how
+// many it deleted. A nil store has nothing to purge.
+func (s *CallbackStore) Purge(ctx context.Context, now time.Time) (int64, error) {
+	if s == nil {
+		return 0, nil
+	}
+	ctx, cancel := s.db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM callback_payloads WHERE expires_at <= ?`, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// signKeyboard returns a copy of markup, if it's an inline keyboard, with the
+// callback data of every button replaced by callbackData for chatID, usable until
+// expires.
+func (bot *Bot) signKeyboard(ctx context.Context, markup any, chatID int64, expires time.Time) (any, error) {
+	keyboard, ok := markup.(*tgbotapi.InlineKeyboardMarkup)
+	if !ok || keyboard == nil {
+		return markup, nil
+	}
+	signed := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: make([][]tgbotapi.InlineKeyboardButton, len(keyboard.InlineKeyboard))}
+	for i, row := range keyboard.InlineKeyboard {
+		signed.InlineKeyboard[i] = slices.Clone(row)
+		for j, button := range row {
+			if button.CallbackData == nil {
+				continue
+			}
+			data, err := bot.callbackData(ctx, *button.CallbackData, chatID, expires)
+			if err != nil {
+				return nil, err
+			}
+			signed.InlineKeyboard[i][j].CallbackData = &data
+		}
+	}
+	return &signed, nil
+}
+
+// callbackData is what a button with data sends in chatID: the signed data, or a
+// callback store key when that's too long for Telegram. Without a callback store
+// long data is an error, Telegram would refuse the whole message anyway.
+func (bot *Bot) callbackData(ctx context.Context, data string, chatID int64, expires time.Time) (string, error) {
+	signed := bot.Signer.SignCallback(data, chatID, expires)
+	if len(signed) <= telegram.MaxCallbackData {
+		return signed, nil
+	}
+	if bot.Callbacks == nil {
+		return "", fmt.Errorf("callback data %q is too long and there's no callback store", data)
+	}
+	key, err := bot.Callbacks.Put(ctx, data, chatID, expires)
+	if err != nil {
+		return "", fmt.Errorf("storing callback data %q: %w", data, err)
+	}
+	return key, nil
+}
+
+// resolveCallback returns the data behind a button's callback data pressed in
+// chatID, see callbackData.
+func (bot *Bot) resolveCallback(ctx context.Context, data string, chatID int64, now time.Time) (string, error) {
+	if !strings.HasPrefix(data, callbackKeyPrefix) {
+		return bot.Signer.VerifyCallback(data, chatID, now)
+	}
+	if bot.Callbacks == nil {
+		return "", telegram.ErrCallbackExpired
+	}
+	return bot.Callbacks.Get(ctx, data, chatID, now)
+}
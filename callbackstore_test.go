@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"tvreminder/bot/internal/telegram"
+)
+
+func TestCallbackStore(t *testing.T) {
+	env := newTestEnv(t)
+	bot := env.handler.Bot
+	now := time.Now()
+	expires := now.Add(telegram.CallbackTTL)
+
+	if data, err := bot.callbackData(t.Context(), "reminders", testUserID, expires); err != nil || strings.HasPrefix(data, callbackKeyPrefix) {
+		t.Errorf("callbackData(reminders) = %q, %v, want it signed", data, err)
+	}
+	long := "selectShow:1:history:" + strings.Repeat("x", 40)
+	data, err := bot.callbackData(t.Context(), long, testUserID, expires)
+	if err != nil || !strings.HasPrefix(data, callbackKeyPrefix) || len(data) > telegram.MaxCallbackData {
+		t.Fatalf("callbackData(%q) = %q, %v, want a store key", long, data, err)
+	}
+	if got, err := bot.resolveCallback(t.Context(), data, testUserID, now.Add(time.Minute)); err != nil || got != long {
+		t.Errorf("resolveCallback(%q) = %q, %v, want %q", data, got, err, long)
+	}
+	if _, err := bot.resolveCallback(t.Context(), data, testUserID+1, now); !errors.Is(err, telegram.ErrCallbackInvalid) {
+		t.Errorf("resolveCallback in another chat = %v, want invalid", err)
+	}
+	if _, err := bot.resolveCallback(t.Context(), data, testUserID, now.Add(telegram.CallbackTTL)); !errors.Is(err, telegram.ErrCallbackExpired) {
+		t.Errorf("resolveCallback after the TTL = %v, want expired", err)
+	}
+	if _, err := bot.resolveCallback(t.Context(), callbackKeyPrefix+"unknown", testUserID, now); !errors.Is(err, telegram.ErrCallbackExpired) {
+		t.Errorf("resolveCallback of an unknown key = %v, want expired", err)
+	}
+
+	bot.callbackData(t.Context(), long, testUserID, expires.Add(telegram.CallbackTTL))
+	if purged, err := bot.Callbacks.Purge(t.Context(), now.Add(telegram.CallbackTTL)); err != nil || purged != 1 {
+		t.Errorf("Purge = %d, %v, want the expired payload purged", purged, err)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM callback_payloads`); got != "1" {
+		t.Errorf("%s payloads stored, want only the live one", got)
+	}
+
+	env.pressRaw(callbackKeyPrefix + "unknown")
+	if got := env.telegram.lastAlert(t); got != "This menu expired, please open it again." {
+		t.Errorf("pressing an unknown key replied %q", got)
+	}
+}
+
+func TestLastingKeyboard(t *testing.T) {
+	env := newTestEnv(t)
+	bot := env.handler.Bot
+	now := time.Now()
+	later := now.Add(telegram.CallbackTTL + time.Hour)
+
+	long := "selectShow:1:history:" + strings.Repeat("x", 40)
+	for _, data := range []string{"checkin:1:1", long} {
+		signed, err := bot.callbackData(t.Context(), data, testUserID, ReplyOptions{Lasting: true}.callbackExpiry(now))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := bot.resolveCallback(t.Context(), signed, testUserID, later); err != nil || got != data {
+			t.Errorf("resolveCallback(%q) past the menu TTL = %q, %v, want %q", signed, got, err, data)
+		}
+		menu, err := bot.callbackData(t.Context(), data, testUserID, ReplyOptions{}.callbackExpiry(now))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := bot.resolveCallback(t.Context(), menu, testUserID, later); !errors.Is(err, telegram.ErrCallbackExpired) {
+			t.Errorf("resolveCallback(%q) of a menu past its TTL = %v, want expired", menu, err)
+		}
+	}
+}
+
+func TestLongCallbackWithoutStore(t *testing.T) {
+	env := newTestEnv(t)
+	env.handler.Bot.Callbacks = nil
+
+	keyboard := makeKeyboardMarkup([][][]string{{{"Long", "selectShow:1:history:" + strings.Repeat("x", 40)}}})
+	if _, err := env.handler.Bot.send(t.Context(), testUserID, "menu", ReplyOptions{ReplyMarkup: keyboard}); err == nil {
+		t.Error("sending long callback data without a store succeeded, want an error")
+	}
+	if n := len(env.telegram.messages()); n != 0 {
+		t.Errorf("%d messages sent, want none", n)
+	}
+}
@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// /cancel does what the ❌ Cancel button does, for when the button has scrolled
+// away. The bot remembers the last message in each chat whose keyboard has a
+// Cancel button, and forgets it once the message is edited into something else,
+// so /cancel can take the stale keyboard down without touching finished flows.
+
+// hasCancelButton reports whether markup is an inline keyboard with a Cancel button.
+func hasCancelButton(markup any) bool {
+	keyboard, ok := markup.(*tgbotapi.InlineKeyboardMarkup)
+	if !ok || keyboard == nil {
+		return false
+	}
+	for _, row := range keyboard.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData != nil && *button.CallbackData == "cancel" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// trackCancelPrompt records a message sent or edited in chatID with markup.
+func (bot *Bot) trackCancelPrompt(chatID int64, messageID int, markup any) {
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+	if hasCancelButton(markup) {
+		if bot.cancelPrompts == nil {
+			bot.cancelPrompts = make(map[int64]int)
+		}
+		bot.cancelPrompts[chatID] = messageID
+	} else if bot.cancelPrompts[chatID] == messageID {
+		delete(bot.cancelPrompts, chatID)
+	}
+}
+
+// takeCancelPrompt returns and forgets the chat's message with a Cancel button, 0
+// when there's none.
+func (bot *Bot) takeCancelPrompt(chatID int64) int {
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+	messageID := bot.cancelPrompts[chatID]
+	delete(bot.cancelPrompts, chatID)
+	return messageID
+}
+
+// CANCEL command
+
+func (handler *Handler) handleCancelCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	pending := handler.Bot.getState(userID) != StateNone
+	handler.Bot.clearState(userID)
+
+	promptID := handler.Bot.takeCancelPrompt(chatID)
+	if promptID != 0 {
+		handler.Bot.reply(ctx, chatID, "Operation cancelled.", ReplyOptions{EditMessageID: promptID})
+	}
+	if !pending && promptID == 0 {
+		handler.Bot.reply(ctx, chatID, "Nothing to cancel.")
+		return nil
+	}
+	handler.Bot.reply(ctx, chatID, "Cancelled.")
+	return nil
+}
@@ -0,0 +1,31 @@
+package bot
+
+import "testing"
+
+func TestCancelCommand(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.command("/add night")
+	if got := env.handler.Bot.getState(testUserID); got != StateAwaitingShowSelection {
+		t.Fatalf("state after /add = %d, want awaiting selection", got)
+	}
+
+	env.command("/cancel")
+	if got := env.handler.Bot.getState(testUserID); got != StateNone {
+		t.Errorf("state after /cancel = %d, want none", got)
+	}
+	edits := env.telegram.calls("editMessageText")
+	if len(edits) != 1 || edits[0].Params.Get("text") != "Operation cancelled." || edits[0].Params.Get("reply_markup") != "" {
+		t.Fatalf("edits = %v, want the results taken down", edits)
+	}
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "Cancelled." {
+		t.Errorf("reply = %q, want Cancelled.", got)
+	}
+
+	env.command("/cancel")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "Nothing to cancel." {
+		t.Errorf("second /cancel = %q, want Nothing to cancel.", got)
+	}
+	if got := len(env.telegram.calls("editMessageText")); got != 1 {
+		t.Errorf("second /cancel edited %d messages", got-1)
+	}
+}
@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Upgrading a group to a supergroup gives it a new chat ID, and the old one stops
+// working. Telegram posts a service message about it in both chats, and rejects
+// sends to the old ID with the new one attached; whichever the bot sees first moves
+// everything stored under the old ID over.
+
+// migratedChatID returns the chat ID a failed send says the chat moved to, or 0.
+func migratedChatID(err error) int64 {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.MigrateToChatID
+	}
+	return 0
+}
+
+// migrateChat moves everything stored for chat oldID to newID in one transaction.
+// Rows the new chat already has win over the old ones. Reminder messages are
+// dropped, as message IDs don't carry over to the new chat.
+func migrateChat(ctx context.Context, db *store.DB, oldID, newID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`UPDATE reminders SET chat_id = ? WHERE chat_id = ?`,
+		`UPDATE user_settings SET chat_id = ? WHERE chat_id = ?`,
+		`UPDATE followups SET chat_id = ? WHERE chat_id = ?`,
+		`UPDATE OR IGNORE group_shows SET chat_id = ? WHERE chat_id = ?`,
+		`UPDATE OR IGNORE group_settings SET chat_id = ? WHERE chat_id = ?`,
+		`UPDATE OR IGNORE user_chats SET chat_id = ? WHERE chat_id = ?`,
+		`UPDATE OR IGNORE reminder_targets SET chat_id = ? WHERE chat_id = ?`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, newID, oldID); err != nil {
+			return err
+		}
+	}
+	for _, table := range []string{"user_chats", "reminder_targets", "reminder_messages"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE chat_id = ?`, oldID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// forgetChatState drops what the bot keeps in memory about a chat that moved.
+func (bot *Bot) forgetChatState(chatID int64) {
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+	delete(bot.cancelPrompts, chatID)
+}
+
+// handleChatMigration applies the service messages Telegram posts when a group
+// becomes a supergroup. It reports whether msg was one of them.
+func (handler *Handler) handleChatMigration(ctx context.Context, msg *tgbotapi.Message) bool {
+	var oldID, newID int64
+	switch {
+	case msg.MigrateToChatID != 0:
+		oldID, newID = msg.Chat.ID, msg.MigrateToChatID
+	case msg.MigrateFromChatID != 0:
+		oldID, newID = msg.MigrateFromChatID, msg.Chat.ID
+	default:
+		return false
+	}
+	handler.Bot.forgetChatState(oldID)
+	if err := migrateChat(ctx, handler.DB, oldID, newID); err != nil {
+		log.Printf("handleChatMigration: moving chat %d to %d: %v", oldID, newID, err)
+		return true
+	}
+	log.Printf("handleChatMigration: chat %d is now %d", oldID, newID)
+	return true
+}
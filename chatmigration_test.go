@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestChatMigration(t *testing.T) {
+	env := dueReminderEnv(t)
+
+	// A reminder to a group that was upgraded is moved and sent to the supergroup
+	env.handler.DB.Exec(`UPDATE reminders SET chat_id = -100`)
+	env.telegram.upgradeChat(-100, -1001000)
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if got := queryString(t, env, `SELECT chat_id || ' ' || status FROM reminders`); got != "-1001000 sent" {
+		t.Errorf("reminder after sending to the upgraded group = %s, want -1001000 sent", got)
+	}
+	if got := env.telegram.lastMessage(t).Params.Get("chat_id"); got != "-1001000" {
+		t.Errorf("reminder sent to %s, want the supergroup", got)
+	}
+
+	// The service message moves the chat's settings before anything is sent
+	env.handler.DB.Exec(`INSERT INTO group_settings (chat_id, admins_only) VALUES (-200, 1)`)
+	env.handler.DB.Exec(`INSERT INTO user_chats (user_id, chat_id, title, seen_at) VALUES (?, -200, 'Couch', datetime('now'))`, testUserID)
+	env.handler.handleUpdate(t.Context(), tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID:       3,
+		From:            &tgbotapi.User{ID: testUserID},
+		Chat:            &tgbotapi.Chat{ID: -200, Type: "group"},
+		MigrateToChatID: -1002000,
+	}})
+	if got := queryString(t, env, `SELECT chat_id FROM group_settings`); got != "-1002000" {
+		t.Errorf("group settings chat = %s, want -1002000", got)
+	}
+	if got := queryString(t, env, `SELECT GROUP_CONCAT(chat_id) FROM user_chats`); got != "-1002000" {
+		t.Errorf("user chats = %s, want -1002000", got)
+	}
+}
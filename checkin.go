@@ -0,0 +1,345 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+	"tvreminder/bot/internal/telegram"
+)
+
+// defaultCheckinDelayHours is how long after an episode airs the user is asked
+// whether they watched it, unless they picked another delay with /checkin.
+const defaultCheckinDelayHours = 24
+
+// maxCheckinSnoozes is how many times "Not yet" postpones a check-in before the
+// bot stops asking about that episode.
+const maxCheckinSnoozes = 3
+
+// Followup is a scheduled "Did you watch it?" question about an episode the user
+// was reminded of.
+type Followup struct {
+	ID            int64
+	UserID        int64
+	ChatID        int64
+	ThreadID      int
+	ShowID        int64
+	EpisodeID     int64
+	Snoozes       int
+	ShowName      string
+	EpisodeSeason int
+	EpisodeNumber int
+	EpisodeTitle  string
+	// Watched is set when the user's progress already covers the episode.
+	Watched bool
+	// Asked is set once the check-in is sent. Its due time is then when its
+	// buttons expire, and it's asked again if it's still unanswered.
+	Asked bool
+}
+
+const followupColumns = `
+	f.id, f.user_id, f.chat_id, COALESCE(f.thread_id, 0), f.show_id, f.episode_id, f.snoozes,
+	s.name, e.season, e.number, e.title,
+	COALESCE(w.season > e.season OR (w.season = e.season AND w.number >= e.number), 0),
+	f.asked
+`
+
+const followupJoins = `
+	FROM followups f
+	JOIN shows s ON s.id = f.show_id AND s.deleted_at IS NULL
+	JOIN episodes_cache e ON e.id = f.episode_id
+	LEFT JOIN episodes_cache w ON w.id = s.last_watched_episode_id
+`
+
+func scanFollowup(row rowScanner) (*Followup, error) {
+	var f Followup
+	err := row.Scan(
+		&f.ID, &f.UserID, &f.ChatID, &f.ThreadID, &f.ShowID, &f.EpisodeID, &f.Snoozes,
+		&f.ShowName, &f.EpisodeSeason, &f.EpisodeNumber, &f.EpisodeTitle, &f.Watched,
+		&f.Asked,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// scheduleFollowup plans the check-in for the last episode a sent reminder covered,
+// unless the user turned check-ins off.
+func scheduleFollowup(ctx context.Context, db Execer, r DBReminder, now time.Time) error {
+	delayHours := r.CheckinDelayHours
+	// Events are archived once they're over instead
+	if delayHours <= 0 || r.ReminderMode != ReminderModeEpisode || r.OneOff {
+		return nil
+	}
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	// Reminders go out at air time, delayed ones later still: count from whichever
+	// comes last so the question never arrives before the episode is out.
+	dueAt := now.Add(time.Duration(delayHours) * time.Hour)
+	if r.RemindAt.After(now) {
+		dueAt = r.RemindAt.Add(time.Duration(delayHours) * time.Hour)
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO followups (user_id, chat_id, thread_id, show_id, episode_id, due_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, r.UserID, r.ChatID, r.ThreadID, r.ShowID, r.lastEpisodeID(), dueAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+func getCheckinDelayHours(ctx context.Context, db *store.DB, userID int64) (int, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	hours := defaultCheckinDelayHours
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(checkin_delay_hours, ?) FROM user_settings WHERE user_id = ?
+	`, defaultCheckinDelayHours, userID).Scan(&hours)
+	if errors.Is(err, sql.ErrNoRows) {
+		return defaultCheckinDelayHours, nil
+	}
+	return hours, err
+}
+
+func setCheckinDelayHours(ctx context.Context, db *store.DB, userID, chatID int64, hours int) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET checkin_delay_hours = ? WHERE user_id = ?`, hours, userID)
+	return err
+}
+
+// listDueFollowups returns the check-ins to ask at now, including the ones asked
+// whose buttons expired unanswered.
+func listDueFollowups(ctx context.Context, db *store.DB, now time.Time) ([]Followup, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+followupColumns+followupJoins+`
+		LEFT JOIN user_settings us ON us.user_id = f.user_id
+		WHERE f.due_at <= ? AND us.inactive_since IS NULL
+		ORDER BY f.due_at
+	`, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var followups []Followup
+	for rows.Next() {
+		f, err := scanFollowup(rows)
+		if err != nil {
+			return nil, err
+		}
+		followups = append(followups, *f)
+	}
+	return followups, rows.Err()
+}
+
+// getUserFollowup returns a followup of the user, sql.ErrNoRows when it's gone or
+// belongs to someone else.
+func getUserFollowup(ctx context.Context, db *store.DB, userID, followupID int64) (*Followup, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	return scanFollowup(db.QueryRowContext(ctx, `
+		SELECT `+followupColumns+followupJoins+`
+		WHERE f.id = ? AND f.user_id = ?
+	`, followupID, userID))
+}
+
+// markFollowupAsked records the check-in as sent with buttons that expire at
+// expires. Asking it again counts as a snooze, like "Not yet".
+func markFollowupAsked(ctx context.Context, db *store.DB, followupID int64, expires time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE followups SET snoozes = snoozes + asked, asked = 1, due_at = ? WHERE id = ?
+	`, expires.UTC().Format(time.RFC3339), followupID)
+	return err
+}
+
+func snoozeFollowup(ctx context.Context, db *store.DB, followupID int64, dueAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE followups SET asked = 0, snoozes = snoozes + 1, due_at = ? WHERE id = ?
+	`, dueAt.UTC().Format(time.RFC3339), followupID)
+	return err
+}
+
+func deleteFollowup(ctx context.Context, db *store.DB, followupID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `DELETE FROM followups WHERE id = ?`, followupID)
+	return err
+}
+
+func followupEpisode(f Followup) string {
+	return fmt.Sprintf("%s S%02dE%02d", f.ShowName, f.EpisodeSeason, f.EpisodeNumber)
+}
+
+// sendDueFollowups asks the check-ins that are due, one run of the reminder loop.
+// Episodes the user marked watched in the meantime are dropped silently, and so
+// are check-ins left unanswered as often as "Not yet" may be pressed.
+func sendDueFollowups(ctx context.Context, bot *Bot, db *store.DB, now time.Time) {
+	followups, err := listDueFollowups(ctx, db, now)
+	if err != nil {
+		log.Printf("reminderLoop: listDueFollowups error: %v", err)
+		return
+	}
+	for _, f := range followups {
+		if f.Watched || (f.Asked && f.Snoozes+1 >= maxCheckinSnoozes) {
+			if err := deleteFollowup(ctx, db, f.ID); err != nil {
+				log.Printf("reminderLoop: failed to delete followup %d: %v", f.ID, err)
+			}
+			continue
+		}
+
+		keyboard := makeKeyboardMarkup([][][]string{{
+			{"✅ Yes", fmt.Sprintf("checkin:%d:1", f.ID)},
+			{"⏳ Not yet", fmt.Sprintf("checkin:%d:0", f.ID)},
+		}})
+		text := fmt.Sprintf("Did you watch %s \"%s\"?", html.EscapeString(followupEpisode(f)), html.EscapeString(f.EpisodeTitle))
+		if _, err := bot.send(ctx, f.ChatID, text, ReplyOptions{ParseMode: "HTML", ReplyMarkup: keyboard, ThreadID: f.ThreadID, Lasting: true}); err != nil {
+			log.Printf("reminderLoop: failed to send followup %d: %v", f.ID, err)
+			if isChatUnreachable(err) {
+				deleteFollowup(ctx, db, f.ID)
+			}
+			continue
+		}
+		if err := markFollowupAsked(ctx, db, f.ID, now.Add(telegram.LastingCallbackTTL)); err != nil {
+			log.Printf("reminderLoop: failed to mark followup %d asked: %v", f.ID, err)
+		}
+	}
+}
+
+// handleCheckinCallback answers a check-in: "followupID:1" when the user watched
+// the episode, "followupID:0" to be asked again later.
+func (handler *Handler) handleCheckinCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	followupIDStr, answer, _ := strings.Cut(callbackParam, ":")
+	followupID, err := strconv.ParseInt(followupIDStr, 10, 64)
+	if err != nil {
+		log.Printf("handleCheckinCallback: invalid followup id: %s", followupIDStr)
+		return nil
+	}
+
+	userID := cb.From.ID
+	msg := cb.Message
+
+	f, err := getUserFollowup(ctx, handler.DB, userID, followupID)
+	if errors.Is(err, sql.ErrNoRows) {
+		handler.Bot.reply(ctx, msg.Chat.ID, "Already answered.", ReplyOptions{EditMessageID: msg.MessageID})
+		handler.Bot.answerCallbackQuery(cb.ID)
+		return nil
+	}
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting followup %d: %w", followupID, err),
+			"Error: can't record your answer at this time",
+		)
+	}
+
+	var text string
+	switch {
+	case answer == "1":
+		if !f.Watched {
+			if err := updateLastWatchedEpisode(ctx, handler.DB, f.ShowID, f.EpisodeID, handler.Clock.Now()); err != nil {
+				return NewUserError(
+					fmt.Errorf("updating progress of show %d: %w", f.ShowID, err),
+					"Error: can't update your progress at this time",
+				)
+			}
+		}
+		if err := deleteFollowup(ctx, handler.DB, f.ID); err != nil {
+			log.Printf("handleCheckinCallback: deleting followup %d: %v", f.ID, err)
+		}
+		text = fmt.Sprintf("✅ Marked %s as watched.", followupEpisode(*f))
+
+	case f.Snoozes+1 >= maxCheckinSnoozes:
+		if err := deleteFollowup(ctx, handler.DB, f.ID); err != nil {
+			log.Printf("handleCheckinCallback: deleting followup %d: %v", f.ID, err)
+		}
+		text = fmt.Sprintf("OK, I won't ask about %s again. Update your progress in /shows when you watch it.", followupEpisode(*f))
+
+	default:
+		delayHours, err := getCheckinDelayHours(ctx, handler.DB, userID)
+		if err != nil || delayHours <= 0 {
+			delayHours = defaultCheckinDelayHours
+		}
+		if err := snoozeFollowup(ctx, handler.DB, f.ID, handler.Clock.Now().Add(time.Duration(delayHours)*time.Hour)); err != nil {
+			return NewUserError(
+				fmt.Errorf("snoozing followup %d: %w", f.ID, err),
+				"Error: can't record your answer at this time",
+			)
+		}
+		text = fmt.Sprintf("OK, I'll ask about %s again in %s.", followupEpisode(*f), pluralize(delayHours, "hour"))
+	}
+
+	handler.Bot.reply(ctx, msg.Chat.ID, text, ReplyOptions{EditMessageID: msg.MessageID})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// CHECKIN command
+
+func (handler *Handler) handleCheckinCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	arg := strings.TrimSpace(msg.CommandArguments())
+
+	if arg == "" {
+		hours, err := getCheckinDelayHours(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting check-in delay of user %d: %w", userID, err),
+				"Error: can't get your check-in setting at this time",
+			)
+		}
+		status := "Check-ins are off."
+		if hours > 0 {
+			status = fmt.Sprintf("I ask whether you watched an episode %s after it airs.", pluralize(hours, "hour"))
+		}
+		handler.Bot.reply(ctx, chatID, status+"\n\nUse /checkin <hours> to change the delay or /checkin off to stop asking.")
+		return nil
+	}
+
+	hours := 0
+	if arg != "off" {
+		var err error
+		hours, err = strconv.Atoi(strings.TrimSuffix(arg, "h"))
+		if err != nil || hours <= 0 || hours > 24*14 {
+			handler.Bot.reply(ctx, chatID, "Usage: /checkin <hours>, from 1 to 336, or /checkin off")
+			return nil
+		}
+	}
+	if err := setCheckinDelayHours(ctx, handler.DB, userID, chatID, hours); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting check-in delay of user %d: %w", userID, err),
+			"Error: can't save your check-in setting at this time",
+		)
+	}
+	if hours == 0 {
+		handler.Bot.reply(ctx, chatID, "Check-ins are off.")
+	} else {
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf("I'll ask whether you watched an episode %s after it airs.", pluralize(hours, "hour")))
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"tvreminder/bot/internal/telegram"
+)
+
+// sendCheckin sends the due reminder and, a day later, the check-in about it.
+func sendCheckin(t *testing.T, env *testEnv) string {
+	t.Helper()
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	sendDueFollowups(t.Context(), env.handler.Bot, env.handler.DB, time.Now())
+	if got := env.telegram.lastMessage(t).Params.Get("text"); strings.HasPrefix(got, "Did you watch") {
+		t.Fatalf("check-in sent right away: %q", got)
+	}
+
+	sendDueFollowups(t.Context(), env.handler.Bot, env.handler.DB, time.Now().Add(25*time.Hour))
+	msg := env.telegram.lastMessage(t)
+	if got := msg.Params.Get("text"); got != `Did you watch Night Shift S02E03 "Episode 2.3"?` {
+		t.Fatalf("check-in = %q", got)
+	}
+	return queryString(t, env, `SELECT id FROM followups`)
+}
+
+func TestCheckinYes(t *testing.T) {
+	env := dueReminderEnv(t)
+	id := sendCheckin(t, env)
+
+	env.press("checkin:" + id + ":1")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "✅ Marked Night Shift S02E03 as watched." {
+		t.Errorf("answer = %q", got)
+	}
+	if got := queryString(t, env, `
+		SELECT e.number FROM shows s JOIN episodes_cache e ON e.id = s.last_watched_episode_id
+	`); got != "3" {
+		t.Errorf("last watched episode = %s, want 3", got)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM followups`); got != "0" {
+		t.Errorf("%s followups left, want 0", got)
+	}
+}
+
+func TestCheckinNotYet(t *testing.T) {
+	env := dueReminderEnv(t)
+	id := sendCheckin(t, env)
+
+	env.press("checkin:" + id + ":0")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "OK, I'll ask about Night Shift S02E03 again in 24 hours") {
+		t.Errorf("answer = %q", got)
+	}
+	if got := queryString(t, env, `SELECT asked || ':' || snoozes FROM followups`); got != "0:1" {
+		t.Errorf("followup after snoozing = %s, want 0:1", got)
+	}
+	if got := queryString(t, env, `
+		SELECT COALESCE(s.last_watched_episode_id, 0) = e.id FROM shows s, episodes_cache e WHERE e.season = 2 AND e.number = 3
+	`); got != "0" {
+		t.Errorf("progress advanced on Not yet")
+	}
+}
+
+func TestUnansweredCheckinAskedAgain(t *testing.T) {
+	env := dueReminderEnv(t)
+	sendCheckin(t, env)
+	asked := len(env.telegram.messages())
+
+	later := time.Now().Add(25 * time.Hour)
+	for i := 1; i < maxCheckinSnoozes; i++ {
+		later = later.Add(telegram.LastingCallbackTTL + time.Minute)
+		sendDueFollowups(t.Context(), env.handler.Bot, env.handler.DB, later)
+		if got := len(env.telegram.messages()); got != asked+i {
+			t.Fatalf("%d messages after the buttons expired %d times, want the check-in asked again", got, i)
+		}
+		if got := queryString(t, env, `SELECT asked || ':' || snoozes FROM followups`); got != fmt.Sprintf("1:%d", i) {
+			t.Errorf("followup asked again = %s, want 1:%d", got, i)
+		}
+	}
+
+	later = later.Add(telegram.LastingCallbackTTL + time.Minute)
+	sendDueFollowups(t.Context(), env.handler.Bot, env.handler.DB, later)
+	if got := queryString(t, env, `SELECT COUNT(*) FROM followups`); got != "0" {
+		t.Errorf("%s followups left after going unanswered %d times, want 0", got, maxCheckinSnoozes)
+	}
+}
+
+func TestCheckinOff(t *testing.T) {
+	env := dueReminderEnv(t)
+	env.command("/checkin off")
+
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if got := queryString(t, env, `SELECT COUNT(*) FROM followups`); got != "0" {
+		t.Errorf("%s followups scheduled with check-ins off", got)
+	}
+}
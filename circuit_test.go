@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"tvreminder/bot/internal/provider"
+)
+
+func TestProviderOutage(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	// Someone else tracks the show, so it's known locally
+	if _, err := env.handler.DB.Exec(`UPDATE shows SET user_id = ?`, testUserID+1); err != nil {
+		t.Fatal(err)
+	}
+
+	env.tvmaze.setDown(true)
+	for range provider.BreakerThreshold {
+		env.handler.Provider.SearchShow(t.Context(), "night")
+	}
+	if _, err := env.handler.Provider.SearchShow(t.Context(), "night"); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("search after %d failures: %v, want ErrProviderUnavailable", provider.BreakerThreshold, err)
+	}
+
+	env.command("/add night")
+	last := env.telegram.lastMessage(t)
+	if got := last.Params.Get("text"); !strings.HasPrefix(got, "My show database isn't answering right now") {
+		t.Errorf("/add during an outage = %q", got)
+	}
+	if got := last.keyboard(t); !slices.Equal(got, []string{"acceptShowName:1", "cancel"}) {
+		t.Errorf("keyboard = %v, want the known show", got)
+	}
+	env.press("acceptShowName:1")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, `TV show "Night Shift" added.`) {
+		t.Errorf("adding a known show during an outage = %q", got)
+	}
+
+	env.command("/add solo")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "can't search for new shows") {
+		t.Errorf("/add of an unknown show during an outage = %q", got)
+	}
+
+	if deferred := syncAllShows(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Provider, env.handler.Clock); !slices.Equal(deferred, []string{"1"}) {
+		t.Errorf("shows deferred during the outage = %v, want [1]", deferred)
+	}
+}
@@ -0,0 +1,227 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"tvreminder/bot/internal/clock"
+)
+
+// Maintenance commands run against the same database and environment as the bot,
+// and exit when they're done. They're safe to run next to a serving bot: SQLite
+// serializes the writes.
+
+type Command struct {
+	Name    string
+	Usage   string
+	Summary string
+	Run     func(args []string) error
+}
+
+var commands = []Command{
+	{"serve", "serve [-db path] [-restore]", "run the bot (the default)", runServe},
+	{"migrate", "migrate [-db path]", "create or upgrade the database schema", runMigrate},
+	{"backup", "backup [-db path] [-dir dir]", "take a backup now, like BACKUP_INTERVAL does", runBackupCommand},
+	{"import-export", "import-export export|import -user id [flags]", "export or import a user's data as JSON", runImportExport},
+	{"sync", "sync [-db path] -show id", "refresh a show from the metadata provider now", runSyncCommand},
+}
+
+func findCommand(name string) *Command {
+	for i := range commands {
+		if commands[i].Name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, command := range commands {
+		fmt.Fprintf(os.Stderr, "  %-45s %s\n", command.Usage, command.Summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> -h for the flags of a command.\n", os.Args[0])
+}
+
+// runMigrate brings the schema up to date. openDB migrates, so serve does it too;
+// this runs it on its own, before a new version starts.
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dbPath := dbPathFlag(flags)
+	flags.Parse(args)
+
+	cfg, err := configFromEnv()
+	if err != nil {
+		return err
+	}
+	db, err := cfg.openDB(context.Background(), *dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open db: %w", err)
+	}
+	defer db.Close()
+	log.Printf("Database %s is up to date", *dbPath)
+	return nil
+}
+
+// runBackupCommand takes one backup with the BACKUP_* settings. It works without
+// BACKUP_DIR when -dir is given.
+func runBackupCommand(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	dbPath := dbPathFlag(flags)
+	dir := flags.String("dir", "", "directory to write the backup to (BACKUP_DIR)")
+	flags.Parse(args)
+
+	cfg, err := configFromEnv()
+	if err != nil {
+		return err
+	}
+	config, err := backupConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		config = &BackupConfig{Keep: 7}
+	}
+	if *dir != "" {
+		config.Dir = *dir
+	}
+	if config.Dir == "" {
+		return fmt.Errorf("backup needs -dir or BACKUP_DIR")
+	}
+
+	db, err := cfg.openDB(context.Background(), *dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open db: %w", err)
+	}
+	defer db.Close()
+	return runBackup(context.Background(), db, config)
+}
+
+// runImportExport writes a user's data in the /export format, or imports such a
+// file for a user, for moving users between instances or restoring one user.
+func runImportExport(args []string) error {
+	if len(args) == 0 || args[0] != "export" && args[0] != "import" {
+		return fmt.Errorf("usage: import-export export -user id [-o file] | import -user id [-chat id] file")
+	}
+	mode := args[0]
+	flags := flag.NewFlagSet("import-export "+mode, flag.ExitOnError)
+	dbPath := dbPathFlag(flags)
+	userID := flags.Int64("user", 0, "Telegram user ID")
+	var out *string
+	var chatID *int64
+	if mode == "export" {
+		out = flags.String("o", "", "file to write, standard output when empty")
+	} else {
+		chatID = flags.Int64("chat", 0, "chat the user's reminders go to, their private chat when 0")
+	}
+	flags.Parse(args[1:])
+	if *userID == 0 {
+		return fmt.Errorf("-user is required")
+	}
+
+	cfg, err := configFromEnv()
+	if err != nil {
+		return err
+	}
+	db, err := cfg.openDB(context.Background(), *dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open db: %w", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	if mode == "export" {
+		export, err := buildUserExport(ctx, db, *userID, time.Now())
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding export: %w", err)
+		}
+		if *out == "" {
+			_, err = os.Stdout.Write(append(data, '\n'))
+			return err
+		}
+		return os.WriteFile(*out, data, 0o600)
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("import needs the export file")
+	}
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	export, err := parseUserExport(data)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", flags.Arg(0), err)
+	}
+	provider, err := newProviderFromEnv()
+	if err != nil {
+		return err
+	}
+	if *chatID == 0 {
+		*chatID = *userID
+	}
+	// Importing sends nothing, so it doesn't need Telegram
+	handler := &Handler{Bot: &Bot{UserContexts: make(map[int64]*UserContext)}, DB: db, Provider: provider, Clock: clock.System{}}
+	result, err := handler.importUserData(ctx, *userID, *chatID, export)
+	if err != nil {
+		return err
+	}
+	log.Printf("Imported %s for user %d, %d skipped", pluralize(result.Imported, "show"), *userID, result.Skipped)
+	return nil
+}
+
+// runSyncCommand syncs one show the way syncLoop does, announcing its new episodes
+// and schedule changes, so it needs TELEGRAM_BOT_TOKEN.
+func runSyncCommand(args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	dbPath := dbPathFlag(flags)
+	showID := flags.String("show", "", "provider ID of the show")
+	flags.Parse(args)
+	if _, err := strconv.Atoi(*showID); err != nil {
+		return fmt.Errorf("-show needs the provider ID of a show")
+	}
+
+	cfg, err := configFromEnv()
+	if err != nil {
+		return err
+	}
+	provider, err := newProviderFromEnv()
+	if err != nil {
+		return err
+	}
+	db, err := cfg.openDB(context.Background(), *dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open db: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	tracked, err := listTrackedProviderShows(ctx, db, provider.Name())
+	if err != nil {
+		return fmt.Errorf("listing tracked shows: %w", err)
+	}
+	if !slices.Contains(tracked, *showID) {
+		return fmt.Errorf("nobody tracks %s show %s", provider.Name(), *showID)
+	}
+	bot, err := newBotFromEnv(cfg)
+	if err != nil {
+		return err
+	}
+	if deferred := syncShows(ctx, bot, db, provider, []string{*showID}, clock.System{}); len(deferred) > 0 {
+		return fmt.Errorf("syncing show %s: %w", *showID, ErrProviderUnavailable)
+	}
+	log.Printf("Synced show %s", *showID)
+	return nil
+}
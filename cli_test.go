@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tvreminder/bot/internal/store"
+)
+
+func TestImportExportCommandMovesUser(t *testing.T) {
+	tvmaze := newFakeTVMaze(t, testShows()...)
+	t.Setenv("TVMAZE_API_URL", tvmaze.server.URL)
+	t.Setenv("METADATA_PROVIDER", "")
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.db")
+	target := filepath.Join(dir, "target.db")
+	exportPath := filepath.Join(dir, "export.json")
+
+	if err := runMigrate([]string{"-db", source}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := store.Open(t.Context(), store.Config{Path: source})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = addShow(t.Context(), db, testUserID, "Solo", "tvmaze", 2, "", "")
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runImportExport([]string{"export", "-db", source, "-user", "1001", "-o", exportPath}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if err := runImportExport([]string{"import", "-db", target, "-user", "1001", exportPath}); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	imported, err := store.Open(t.Context(), store.Config{Path: target})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imported.Close()
+	var name string
+	if err := imported.QueryRow(`SELECT name FROM shows WHERE user_id = ?`, testUserID).Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "Solo" {
+		t.Errorf("imported show = %q, want Solo", name)
+	}
+}
+
+func TestBackupCommand(t *testing.T) {
+	t.Setenv("BACKUP_DIR", "")
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "bot.db")
+	backups := filepath.Join(dir, "backups")
+
+	if err := runBackupCommand([]string{"-db", dbPath}); err == nil {
+		t.Error("backup without a directory succeeded")
+	}
+	if err := runBackupCommand([]string{"-db", dbPath, "-dir", backups}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(backups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("backup wrote %d files, want 1", len(entries))
+	}
+}
@@ -0,0 +1,13 @@
+// Command tvreminderbot runs the TV reminder Telegram bot. See package bot for the
+// commands and the environment it reads.
+package main
+
+import (
+	"os"
+
+	"tvreminder/bot"
+)
+
+func main() {
+	bot.Main(os.Args[1:])
+}
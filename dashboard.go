@@ -0,0 +1,343 @@
+package bot
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+	"tvreminder/bot/internal/telegram"
+)
+
+// The HTTP listener also serves a small dashboard at /dashboard. Users sign in with
+// the Telegram Login widget, whose data is signed with the bot token, and get a
+// session cookie signed with the bot's callback signer. Sessions therefore end on
+// restart unless CALLBACK_SECRET is set, like menus do. The dashboard lists the
+// user's shows and the coming weeks and edits the settings that fit a form;
+// everything else stays in the chat. The widget only works once the bot's domain
+// is set to PUBLIC_URL's with BotFather's /setdomain.
+
+const sessionCookie = "tvr_session"
+
+// sessionTTL is how long a dashboard login lasts.
+const sessionTTL = 7 * 24 * time.Hour
+
+// loginMaxAge rejects Telegram logins older than this, so a leaked login URL
+// can't be replayed for long.
+const loginMaxAge = 24 * time.Hour
+
+// dashboardWindow is how far ahead the dashboard calendar looks.
+const dashboardWindow = 14 * 24 * time.Hour
+
+var errLoginInvalid = errors.New("invalid Telegram login")
+
+// settingsErrors are the messages of the settings form's ?error= codes.
+var settingsErrors = map[string]string{
+	"timezone": "Unknown time zone, use a name like Europe/Berlin.",
+	"quiet":    "Quiet hours look like 23:00-08:00.",
+}
+
+// verifyTelegramLogin checks the data the Telegram Login widget redirected with and
+// returns the user's ID. See https://core.telegram.org/widgets/login#checking-authorization.
+func verifyTelegramLogin(query url.Values, botToken string, now time.Time) (int64, error) {
+	hash := query.Get("hash")
+	var fields []string
+	for key := range query {
+		if key != "hash" {
+			fields = append(fields, key+"="+query.Get(key))
+		}
+	}
+	sort.Strings(fields)
+
+	secret := sha256.Sum256([]byte(botToken))
+	h := hmac.New(sha256.New, secret[:])
+	h.Write([]byte(strings.Join(fields, "\n")))
+	if !hmac.Equal([]byte(hex.EncodeToString(h.Sum(nil))), []byte(hash)) {
+		return 0, errLoginInvalid
+	}
+	authDate, err := strconv.ParseInt(query.Get("auth_date"), 10, 64)
+	if err != nil || now.Sub(time.Unix(authDate, 0)) > loginMaxAge {
+		return 0, errLoginInvalid
+	}
+	userID, err := strconv.ParseInt(query.Get("id"), 10, 64)
+	if err != nil {
+		return 0, errLoginInvalid
+	}
+	return userID, nil
+}
+
+func (d *dashboard) sessionMAC(payload string) string {
+	return base64.RawURLEncoding.EncodeToString(d.signer.MAC("session|" + payload))
+}
+
+// signSession returns a session cookie value for the user, "userID.expires.mac".
+func (d *dashboard) signSession(userID int64, now time.Time) string {
+	payload := fmt.Sprintf("%d.%d", userID, now.Add(sessionTTL).Unix())
+	return payload + "." + d.sessionMAC(payload)
+}
+
+// verifySession returns the user of a session cookie value.
+func (d *dashboard) verifySession(value string, now time.Time) (int64, bool) {
+	i := strings.LastIndex(value, ".")
+	if i < 0 {
+		return 0, false
+	}
+	payload, mac := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(mac), []byte(d.sessionMAC(payload))) {
+		return 0, false
+	}
+	userStr, expiresStr, _ := strings.Cut(payload, ".")
+	userID, err := strconv.ParseInt(userStr, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || now.After(time.Unix(expires, 0)) {
+		return 0, false
+	}
+	return userID, true
+}
+
+// csrfToken ties the settings form to the session it was rendered for.
+func (d *dashboard) csrfToken(session string) string {
+	return d.sessionMAC("csrf|" + session)
+}
+
+type dashboardPage struct {
+	BotUsername string
+	LoggedIn    bool
+	Saved       bool
+	Error       string
+	CSRF        string
+	Shows       []ShowProgress
+	Upcoming    []dashboardEpisode
+	Settings    *UserSettings
+}
+
+type dashboardEpisode struct {
+	When string
+	UpcomingEpisode
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>TV Reminder</title>
+<style>
+body { font-family: sans-serif; max-width: 44rem; margin: 2rem auto; padding: 0 1rem; }
+table { border-collapse: collapse; width: 100%; }
+td, th { text-align: left; padding: .25rem .5rem .25rem 0; border-bottom: 1px solid #ddd; }
+.note { color: #070; } .error { color: #a00; }
+</style>
+</head>
+<body>
+<h1>TV Reminder</h1>
+{{if not .LoggedIn}}
+<p>Sign in with the Telegram account you use with <a href="https://t.me/{{.BotUsername}}">@{{.BotUsername}}</a>.</p>
+<script async src="https://telegram.org/js/telegram-widget.js?22" data-telegram-login="{{.BotUsername}}" data-size="large" data-auth-url="/dashboard/login"></script>
+{{else}}
+<form method="post" action="/dashboard/logout"><input type="hidden" name="csrf" value="{{.CSRF}}"><button>Sign out</button></form>
+
+<h2>Your shows</h2>
+{{if .Shows}}<table>
+<tr><th>Show</th><th>Progress</th><th>Waiting</th><th>Next</th></tr>
+{{range .Shows}}<tr>
+<td>{{.Name}}</td>
+<td>{{if .Season.Valid}}S{{printf "%02d" .Season.Int32}}E{{printf "%02d" .Episode.Int32}}{{else}}not started{{end}}</td>
+<td>{{.EpisodesWaiting}}</td>
+<td>{{if .NextEpisodeSeason.Valid}}S{{printf "%02d" .NextEpisodeSeason.Int32}}E{{printf "%02d" .NextEpisodeNumber.Int32}} {{.NextEpisodeTitle}}{{end}}</td>
+</tr>{{end}}
+</table>{{else}}<p>You don't track any shows yet. Add some with /add in the chat.</p>{{end}}
+
+<h2>Coming up</h2>
+{{if .Upcoming}}<table>
+{{range .Upcoming}}<tr><td>{{.When}}</td><td>{{.ShowName}} S{{printf "%02d" .Season}}E{{printf "%02d" .Number}} {{.Title}}</td></tr>{{end}}
+</table>{{else}}<p>Nothing airs in the next two weeks.</p>{{end}}
+
+<h2>Settings</h2>
+{{if .Saved}}<p class="note">Saved.</p>{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="/dashboard/settings">
+<input type="hidden" name="csrf" value="{{.CSRF}}">
+<p><label>Time zone <input name="timezone" value="{{.Settings.Timezone}}"></label></p>
+<p><label>Quiet hours <input name="quiet_hours" value="{{.Settings.QuietHours}}" placeholder="23:00-08:00"></label> (empty for none)</p>
+<p><label><input type="checkbox" name="summaries" {{if .Settings.ShowSummaries}}checked{{end}}> Episode summaries in reminders</label></p>
+<p><label><input type="checkbox" name="airtime_alerts" {{if .Settings.AirtimeAlerts}}checked{{end}}> Tell me when an episode is rescheduled</label></p>
+<p><button>Save</button></p>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+// dashboard serves /dashboard and its login, settings and logout endpoints.
+type dashboard struct {
+	db          *store.DB
+	botUsername string
+	botToken    string
+	signer      *telegram.Signer
+}
+
+// session returns the signed-in user and their cookie value.
+func (d *dashboard) session(r *http.Request) (int64, string, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return 0, "", false
+	}
+	userID, ok := d.verifySession(cookie.Value, time.Now())
+	return userID, cookie.Value, ok
+}
+
+func (d *dashboard) render(w http.ResponseWriter, page dashboardPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, page); err != nil {
+		log.Printf("dashboard: rendering page: %v", err)
+	}
+}
+
+func (d *dashboard) serveIndex(w http.ResponseWriter, r *http.Request) {
+	page := dashboardPage{BotUsername: d.botUsername}
+	userID, session, ok := d.session(r)
+	if !ok {
+		d.render(w, page)
+		return
+	}
+
+	settings, err := getUserSettings(r.Context(), d.db, userID)
+	var shows []ShowProgress
+	if err == nil {
+		shows, err = listShowsWithProgress(r.Context(), d.db, userID)
+	}
+	now := time.Now()
+	var episodes []UpcomingEpisode
+	if err == nil {
+		episodes, err = listUpcomingEpisodes(r.Context(), d.db, userID, now, now.Add(dashboardWindow))
+	}
+	if err != nil {
+		log.Printf("dashboard: loading user %d: %v", userID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	page.LoggedIn = true
+	page.CSRF = d.csrfToken(session)
+	page.Saved = r.URL.Query().Get("saved") == "1"
+	page.Error = settingsErrors[r.URL.Query().Get("error")]
+	page.Shows = shows
+	page.Settings = settings
+	for _, episode := range episodes {
+		page.Upcoming = append(page.Upcoming, dashboardEpisode{
+			When:            episode.AiredAt.In(settings.Location()).Format("Mon Jan 2, 15:04"),
+			UpcomingEpisode: episode,
+		})
+	}
+	d.render(w, page)
+}
+
+func (d *dashboard) serveLogin(w http.ResponseWriter, r *http.Request) {
+	userID, err := verifyTelegramLogin(r.URL.Query(), d.botToken, time.Now())
+	if err != nil {
+		http.Error(w, "This login link is invalid or has expired.", http.StatusForbidden)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    d.signSession(userID, time.Now()),
+		Path:     "/dashboard",
+		MaxAge:   int(sessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// checkForm authorizes a dashboard form post, returning the signed-in user.
+func (d *dashboard) checkForm(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userID, session, ok := d.session(r)
+	if !ok || !hmac.Equal([]byte(r.PostFormValue("csrf")), []byte(d.csrfToken(session))) {
+		http.Error(w, "Please sign in again.", http.StatusForbidden)
+		return 0, false
+	}
+	return userID, true
+}
+
+// serveSettings saves the settings form. Settings that don't validate are left as
+// they were and reported back.
+func (d *dashboard) serveSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := d.checkForm(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+	// Private chats share the user's ID
+	chatID := userID
+
+	loc, err := time.LoadLocation(strings.TrimSpace(r.PostFormValue("timezone")))
+	if err != nil {
+		http.Redirect(w, r, "/dashboard?error=timezone", http.StatusSeeOther)
+		return
+	}
+	quietHours := strings.TrimSpace(r.PostFormValue("quiet_hours"))
+	if quietHours != "" {
+		if _, _, err := parseQuietHours(quietHours); err != nil {
+			http.Redirect(w, r, "/dashboard?error=quiet", http.StatusSeeOther)
+			return
+		}
+	}
+
+	err = setUserTimezone(ctx, d.db, userID, chatID, loc.String())
+	if err == nil {
+		err = setQuietHours(ctx, d.db, userID, chatID, quietHours)
+	}
+	if err == nil {
+		err = setShowSummaries(ctx, d.db, userID, chatID, r.PostFormValue("summaries") != "")
+	}
+	if err == nil {
+		err = setAirtimeAlerts(ctx, d.db, userID, chatID, r.PostFormValue("airtime_alerts") != "")
+	}
+	if err != nil {
+		log.Printf("dashboard: saving settings of user %d: %v", userID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/dashboard?saved=1", http.StatusSeeOther)
+}
+
+func (d *dashboard) serveLogout(w http.ResponseWriter, r *http.Request) {
+	if _, ok := d.checkForm(w, r); !ok {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/dashboard", MaxAge: -1})
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// DASHBOARD command
+
+func (handler *Handler) handleDashboardCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	if handler.FeedBaseURL == "" {
+		handler.Bot.reply(ctx, msg.Chat.ID, "The web dashboard isn't available on this bot.")
+		return nil
+	}
+	handler.Bot.reply(ctx, msg.Chat.ID, fmt.Sprintf(
+		"See your shows, what's coming up and your settings on the web:\n%s\n\nSign in with Telegram there.",
+		strings.TrimRight(handler.FeedBaseURL, "/")+"/dashboard",
+	))
+	return nil
+}
@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// telegramLogin signs login data like the Telegram Login widget does.
+func telegramLogin(userID int64, botToken string, authDate time.Time) url.Values {
+	query := url.Values{
+		"id":         {strconv.FormatInt(userID, 10)},
+		"first_name": {"Test"},
+		"auth_date":  {strconv.FormatInt(authDate.Unix(), 10)},
+	}
+	var fields []string
+	for key := range query {
+		fields = append(fields, key+"="+query.Get(key))
+	}
+	sort.Strings(fields)
+	secret := sha256.Sum256([]byte(botToken))
+	h := hmac.New(sha256.New, secret[:])
+	h.Write([]byte(strings.Join(fields, "\n")))
+	query.Set("hash", hex.EncodeToString(h.Sum(nil)))
+	return query
+}
+
+func TestDashboard(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	server := httptest.NewServer(newHTTPHandler(env.handler.DB, "test_bot", "test-token", env.handler.Bot.Signer, env.handler.Clock))
+	t.Cleanup(server.Close)
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if _, body := get("/dashboard"); !strings.Contains(body, `data-telegram-login="test_bot"`) {
+		t.Fatalf("dashboard before login = %q, want the login widget", body)
+	}
+	forged := telegramLogin(testUserID, "other-token", time.Now())
+	if status, _ := get("/dashboard/login?" + forged.Encode()); status != http.StatusForbidden {
+		t.Errorf("forged login status = %d, want 403", status)
+	}
+	stale := telegramLogin(testUserID, "test-token", time.Now().Add(-2*loginMaxAge))
+	if status, _ := get("/dashboard/login?" + stale.Encode()); status != http.StatusForbidden {
+		t.Errorf("stale login status = %d, want 403", status)
+	}
+
+	_, body := get("/dashboard/login?" + telegramLogin(testUserID, "test-token", time.Now()).Encode())
+	for _, want := range []string{"Night Shift", "S02E02", "S02E03 Episode 2.3", `value="UTC"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard lacks %q:\n%s", want, body)
+		}
+	}
+
+	// Forms need the page's CSRF token
+	resp, err := client.PostForm(server.URL+"/dashboard/settings", url.Values{"timezone": {"Europe/Berlin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("settings without CSRF token status = %d, want 403", resp.StatusCode)
+	}
+
+	csrf := regexp.MustCompile(`name="csrf" value="([^"]+)"`).FindStringSubmatch(body)
+	if csrf == nil {
+		t.Fatal("no CSRF token on the dashboard")
+	}
+	resp, err = client.PostForm(server.URL+"/dashboard/settings", url.Values{
+		"csrf": {csrf[1]}, "timezone": {"Europe/Berlin"}, "quiet_hours": {"23:00-07:00"}, "airtime_alerts": {"on"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(saved), "Saved.") {
+		t.Errorf("page after saving = %q", saved)
+	}
+	got := queryString(t, env, `SELECT timezone || ' ' || quiet_hours || ' ' || show_summaries || airtime_alerts FROM user_settings`)
+	if want := "Europe/Berlin 23:00-07:00 01"; got != want {
+		t.Errorf("settings = %q, want %q", got, want)
+	}
+}
@@ -1,15 +1,40 @@
-package main
+package bot
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"log"
+	"strconv"
 	"time"
 
-	_ "modernc.org/sqlite"
+	"tvreminder/bot/internal/reminder"
+	"tvreminder/bot/internal/store"
 )
 
 // Database models - separate from API models
 
+const (
+	ReminderStatusPending = "pending"
+	// ReminderStatusFailed marks a dead-lettered reminder that exhausted its retries
+	// or targets a chat the bot can no longer reach.
+	ReminderStatusFailed = "failed"
+	// ReminderStatusSent marks a reminder that was delivered or cancelled. It's kept
+	// so the episode isn't reminded about again.
+	ReminderStatusSent = "sent"
+)
+
+const (
+	// ReminderModeEpisode reminds about every new episode.
+	ReminderModeEpisode = "episode"
+	// ReminderModeSeason waits for a season's finale so the whole season can be binged.
+	ReminderModeSeason = "season"
+	// ReminderModeWatchlist is for shows followed without tracking progress: only
+	// season premieres are reminded about.
+	ReminderModeWatchlist = "watchlist"
+)
+
 type DBShow struct {
 	ID                   int64
 	UserID               int64
@@ -19,6 +44,10 @@ type DBShow struct {
 	Timezone             string
 	LastWatchedEpisodeID *string
 	NotificationsEnabled bool
+	Network              string
+	ReminderMode         string
+	ReleaseService       string
+	ReleaseDelayHours    int
 	CreatedAt            time.Time
 }
 
@@ -34,19 +63,30 @@ type DBEpisode struct {
 	Airtime           string
 	AiredAtUTC        time.Time
 	FetchedAt         time.Time
+	Summary           string
 }
 
+// DBReminder is a due reminder with the episodes and chats it covers besides
+// its own.
 type DBReminder struct {
-	ID            int64
-	UserID        int64
-	ShowID        int64
-	EpisodeID     int64
-	RemindAt      time.Time
-	ChatID        int64
-	ShowName      string
-	EpisodeTitle  string
-	EpisodeNumber int
-	EpisodeSeason int
+	store.DueReminder
+	// Batch holds the later episodes airing together with this one, which the
+	// reminder covers too.
+	Batch []DBEpisode
+	// Targets are the other chats the show's reminders go to, see targets.go.
+	Targets []ReminderTarget
+	// Templates are the operator's, for reminders whose show and user have no
+	// template, see templates.go.
+	Templates reminder.Templates
+}
+
+// lastEpisodeID is the last episode the reminder covers, the reminded one unless
+// it's a batch.
+func (r DBReminder) lastEpisodeID() int64 {
+	if n := len(r.Batch); n > 0 {
+		return r.Batch[n-1].ID
+	}
+	return r.EpisodeID
 }
 
 type ShowProgress struct {
@@ -58,80 +98,65 @@ type ShowProgress struct {
 	NextEpisodeSeason    sql.NullInt32
 	NextEpisodeNumber    sql.NullInt32
 	NextEpisodeTitle     string
+	NextEpisodeSummary   string
 	NotificationsEnabled bool
+	Network              string
+	WebChannel           string
+	Pinned               bool
+	LastWatchedAt        sql.NullTime
+	EpisodesWaiting      int
+	ReminderMode         string
+	PosterURL            string
+	Note                 string
+	Provider             string
+	ProviderShowID       string
+	// Rating is the average of the user's season ratings, 0 when unrated.
+	Rating float64
+	// MutedUntil is when the show's reminders resume, see mute.go.
+	MutedUntil sql.NullTime
+	// Silent reminders arrive without a sound, see silent.go.
+	Silent bool
+	// ReminderTemplate is the show's own reminder text, see templates.go.
+	ReminderTemplate string
+	// Archived shows are only listed in the history, see archive.go.
+	Archived bool
+	// DroppedAt is when the user gave up on the show, see drop.go.
+	DroppedAt  sql.NullTime
+	DropReason string
+	// Tags are the user's labels on the show, see tags.go.
+	Tags []string
 }
 
-func openDB() (*sql.DB, error) {
-	db, err := sql.Open("sqlite", "tvreminder.db")
-	if err != nil {
-		return nil, err
-	}
+// The database layer's plumbing lives in internal/store.
+type (
+	Querier     = store.Querier
+	Execer      = store.Execer
+	ExecQuerier = store.ExecQuerier
+	QueryExecer = store.QueryExecer
+)
 
-	_, err = db.Exec(`
-		BEGIN;
-
-		CREATE TABLE IF NOT EXISTS shows (
-		  id INTEGER PRIMARY KEY AUTOINCREMENT,
-		  user_id INTEGER NOT NULL,
-		  name TEXT NOT NULL,
-		  provider TEXT NOT NULL DEFAULT 'local',
-		  provider_show_id TEXT,
-		  timezone TEXT DEFAULT 'UTC',
-		  last_watched_episode_id TEXT,
-		  notifications_enabled INTEGER DEFAULT 1,
-		  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		  UNIQUE(user_id, provider, provider_show_id)
-		);
-
-		CREATE TABLE IF NOT EXISTS episodes_cache (
-		  id INTEGER PRIMARY KEY AUTOINCREMENT,
-		  provider TEXT NOT NULL,
-		  provider_show_id TEXT NOT NULL,
-		  provider_episode_id TEXT NOT NULL,
-		  season INTEGER,
-		  number INTEGER,
-		  title TEXT,
-		  airdate DATE,       -- yyyy-mm-dd
-		  airtime TEXT,       -- hh:mm (provider may supply)
-		  aired_at_utc DATETIME,  -- normalized UTC timestamp if available
-		  fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		  UNIQUE(provider, provider_episode_id)
-		);
-
-		CREATE TABLE IF NOT EXISTS reminders (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			show_id INTEGER NOT NULL,
-			episode_id INTEGER,
-			remind_at DATETIME NOT NULL,
-			chat_id INTEGER NOT NULL,
-			FOREIGN KEY (show_id) REFERENCES shows(id),
-			FOREIGN KEY (episode_id) REFERENCES episodes_cache(id),
-			UNIQUE(user_id, show_id)
-		);
-
-		CREATE INDEX IF NOT EXISTS idx_shows_user ON shows(user_id);
-		CREATE INDEX IF NOT EXISTS idx_episodes_show
-			ON episodes_cache(provider, provider_show_id);
-
-		COMMIT;
-	`)
+// Shows
 
+func addShow(ctx context.Context,
+	db ExecQuerier, userID int64, name, provider string, showID int, network, posterURL string,
+) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	// Adding a show that's in the trash brings it back, progress and all
+	_, err := db.ExecContext(ctx, `
+		UPDATE shows SET deleted_at = NULL
+		WHERE user_id = ? AND provider = ? AND provider_show_id = ? AND deleted_at IS NOT NULL
+	`, userID, provider, showID)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	return db, nil
-}
-
-// Shows
-
-func addShow(db *sql.DB, userID int64, name, provider string, showID int) (int64, error) {
-	result, err := db.Exec(`
-		INSERT INTO shows (user_id, name, provider, provider_show_id)
-		VALUES (?, ?, ?, ?)
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO shows (user_id, name, provider, provider_show_id, network, poster_url)
+		VALUES (?, ?, ?, ?, ?, ?)
 		ON CONFLICT DO NOTHING
-	`, userID, name, provider, showID)
+	`, userID, name, provider, showID, network, posterURL)
 	if err != nil {
 		return 0, err
 	}
@@ -146,7 +171,7 @@ func addShow(db *sql.DB, userID int64, name, provider string, showID int) (int64
 	}
 
 	var internalID int64
-	err = db.QueryRow(`
+	err = db.QueryRowContext(ctx, `
 		SELECT id FROM shows 
 		WHERE user_id = ? AND provider = ? AND provider_show_id = ?
 	`, userID, provider, showID).Scan(&internalID)
@@ -157,14 +182,77 @@ func addShow(db *sql.DB, userID int64, name, provider string, showID int) (int64
 	return internalID, nil
 }
 
-func listShowsWithProgress(db *sql.DB, userID int64) ([]ShowProgress, error) {
-	rows, err := db.Query(`
-		SELECT s.id, s.name, e.season, e.number, s.provider_show_id, s.notifications_enabled
+// addShowWithEpisodes adds a show for the user together with its episodes in one
+// transaction, so a failure part way doesn't leave a show without episodes behind.
+// progress is called after every chunk of episodeChunkSize episodes and may be nil.
+func addShowWithEpisodes(ctx context.Context,
+	db *store.DB, userID int64, name, provider string, showID int, network, posterURL string,
+	episodes []Episode, progress func(done, total int),
+) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	internalID, err := addShow(ctx, tx, userID, name, provider, showID, network, posterURL)
+	if err != nil {
+		return 0, fmt.Errorf("adding show: %w", err)
+	}
+	for start := 0; start < len(episodes); start += episodeChunkSize {
+		end := min(start+episodeChunkSize, len(episodes))
+		if err := writeEpisodes(ctx, tx, provider, strconv.Itoa(showID), episodes[start:end]); err != nil {
+			return 0, fmt.Errorf("storing episodes: %w", err)
+		}
+		if progress != nil {
+			progress(end, len(episodes))
+		}
+	}
+	return internalID, tx.Commit()
+}
+
+func listShowsWithProgress(ctx context.Context, db *store.DB, userID int64) ([]ShowProgress, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			s.id, s.name, e.season, e.number, s.provider, s.provider_show_id, s.notifications_enabled, s.network,
+			s.web_channel, s.pinned, s.last_watched_at, s.reminder_mode, s.poster_url, COALESCE(s.note, ''), s.muted_until, s.silent,
+			s.reminder_template, s.archived, s.dropped_at, s.drop_reason,
+			(SELECT COALESCE(AVG(r.rating), 0) FROM season_ratings r WHERE r.show_id = s.id),
+			(
+				SELECT COUNT(*) FROM episodes_cache w
+				WHERE w.provider = s.provider AND w.provider_show_id = s.provider_show_id
+				AND (
+					(w.season = COALESCE(e.season, 1) AND w.number > COALESCE(e.number, 0)) OR
+					(w.season > COALESCE(e.season, 1))
+				)
+				AND w.aired_at_utc <= strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+			) + (
+				SELECT COUNT(*) FROM skipped_episodes k JOIN episodes_cache w ON w.id = k.episode_id
+				WHERE k.show_id = s.id AND w.aired_at_utc <= strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+			) AS episodes_waiting,
+			n.season, n.number, COALESCE(n.title, ''), COALESCE(n.summary, ''), COALESCE(n.aired_at_utc, ''),
+			(
+				SELECT COALESCE(group_concat(t.name, ','), '') FROM show_tags st JOIN tags t ON t.id = st.tag_id
+				WHERE st.show_id = s.id
+			)
 		FROM shows s
 		LEFT JOIN episodes_cache e ON e.id = s.last_watched_episode_id
-		WHERE s.user_id = ?
-		ORDER BY s.name
-	`, userID)
+		-- The next episode, as findNextEpisode picks it; watchlisted shows have none
+		LEFT JOIN episodes_cache n ON s.reminder_mode != ? AND n.id = (
+			SELECT x.id FROM episodes_cache x
+			WHERE x.provider = s.provider AND x.provider_show_id = s.provider_show_id
+			AND (
+				(x.season = COALESCE(e.season, 1) AND x.number > COALESCE(e.number, 0)) OR
+				(x.season > COALESCE(e.season, 1))
+			)
+			ORDER BY x.season, x.number
+			LIMIT 1
+		)
+		WHERE s.user_id = ? AND s.deleted_at IS NULL
+		ORDER BY `+showListOrder, ReminderModeWatchlist, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -173,40 +261,48 @@ func listShowsWithProgress(db *sql.DB, userID int64) ([]ShowProgress, error) {
 	var shows []ShowProgress
 	for rows.Next() {
 		var show ShowProgress
-		var providerShowID string
-		var notificationsEnabled int
-		err := rows.Scan(&show.InternalID, &show.Name, &show.Season, &show.Episode, &providerShowID, &notificationsEnabled)
+		var notificationsEnabled, pinned, silent, archived int
+		var nextAiredAt, tags string
+		err := rows.Scan(
+			&show.InternalID, &show.Name, &show.Season, &show.Episode, &show.Provider, &show.ProviderShowID,
+			&notificationsEnabled, &show.Network, &show.WebChannel, &pinned, &show.LastWatchedAt, &show.ReminderMode,
+			&show.PosterURL, &show.Note, &show.MutedUntil, &silent, &show.ReminderTemplate, &archived, &show.DroppedAt, &show.DropReason, &show.Rating, &show.EpisodesWaiting,
+			&show.NextEpisodeSeason, &show.NextEpisodeNumber, &show.NextEpisodeTitle, &show.NextEpisodeSummary,
+			&nextAiredAt, &tags,
+		)
 		if err != nil {
 			return nil, err
 		}
 		show.NotificationsEnabled = notificationsEnabled == 1
-
-		// Always check for next episode (if there's a next episode, the show is ongoing)
-		nextEpisode, err := findNextEpisode(db, providerShowID, show.Season, show.Episode)
-		if err == nil {
-			show.NextEpisodeSeason = sql.NullInt32{Int32: int32(nextEpisode.Season), Valid: true}
-			show.NextEpisodeNumber = sql.NullInt32{Int32: int32(nextEpisode.Number), Valid: true}
-			show.NextEpisodeTitle = nextEpisode.Title
-			if !nextEpisode.AiredAtUTC.IsZero() {
-				show.NextAirDate = sql.NullTime{Time: nextEpisode.AiredAtUTC, Valid: true}
-			}
+		show.Pinned = pinned == 1
+		show.Silent = silent == 1
+		show.Archived = archived == 1
+		show.Tags = splitTags(tags)
+
+		if show.ReminderMode == ReminderModeWatchlist {
+			// Watchlisted shows have no progress, so nothing is waiting
+			show.EpisodesWaiting = 0
+		}
+		// A next episode means the show is ongoing
+		if airedAt, err := time.Parse(time.RFC3339, nextAiredAt); err == nil && !airedAt.IsZero() {
+			show.NextAirDate = sql.NullTime{Time: airedAt, Valid: true}
 		}
 
 		shows = append(shows, show)
 	}
 
-	return shows, nil
+	return shows, rows.Err()
 }
 
-func listCurrentShowsWithProgress(db *sql.DB, userID int64) ([]ShowProgress, error) {
-	shows, err := listShowsWithProgress(db, userID)
+func listCurrentShowsWithProgress(ctx context.Context, db *store.DB, userID int64) ([]ShowProgress, error) {
+	shows, err := listShowsWithProgress(ctx, db, userID)
 	if err != nil {
 		return nil, err
 	}
 
 	var currentShows []ShowProgress
 	for _, show := range shows {
-		if show.NextEpisodeSeason.Valid {
+		if show.NextEpisodeSeason.Valid && !show.Archived && !show.DroppedAt.Valid {
 			currentShows = append(currentShows, show)
 		}
 	}
@@ -214,111 +310,267 @@ func listCurrentShowsWithProgress(db *sql.DB, userID int64) ([]ShowProgress, err
 	return currentShows, nil
 }
 
-func getShowByUserAndName(db *sql.DB, userID int64, name string) (int64, string, error) {
-	var showID int64
-	var providerShowID string
-	err := db.QueryRow(`
-		SELECT id, provider_show_id FROM shows
-		WHERE user_id = ? AND name = ?
-	`, userID, name).Scan(&showID, &providerShowID)
+func getShowByID(ctx context.Context, db *store.DB, showID int64) (*DBShow, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var show DBShow
+	var notificationsEnabled int
+	err := db.QueryRowContext(ctx, `
+		SELECT
+			id, user_id, name, provider, provider_show_id, timezone, last_watched_episode_id,
+			notifications_enabled, network, reminder_mode, release_service, release_delay_hours
+		FROM shows
+		WHERE id = ?
+	`, showID).Scan(
+		&show.ID, &show.UserID, &show.Name, &show.Provider, &show.ProviderShowID, &show.Timezone,
+		&show.LastWatchedEpisodeID, &notificationsEnabled, &show.Network, &show.ReminderMode,
+		&show.ReleaseService, &show.ReleaseDelayHours,
+	)
 	if err != nil {
-		return 0, "", err
+		return nil, err
 	}
-	return showID, providerShowID, nil
+	show.NotificationsEnabled = notificationsEnabled == 1
+	return &show, nil
 }
 
-func getShowNameByID(db *sql.DB, showID int64) (string, error) {
+func getShowNameByID(ctx context.Context, db *store.DB, showID int64) (string, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	var name string
-	err := db.QueryRow(`SELECT name FROM shows WHERE id = ?`, showID).Scan(&name)
+	err := db.QueryRowContext(ctx, `SELECT name FROM shows WHERE id = ?`, showID).Scan(&name)
 	return name, err
 }
 
-func toggleShowNotifications(db *sql.DB, showID int64) error {
-	_, err := db.Exec(`
+func toggleShowNotifications(ctx context.Context, db *store.DB, showID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE shows
+		SET notifications_enabled = CASE WHEN notifications_enabled = 1 THEN 0 ELSE 1 END,
+			notifications_auto_disabled = 0
+		WHERE id = ?
+	`, showID)
+	return err
+}
+
+func setShowReminderMode(ctx context.Context, db *store.DB, showID int64, mode string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `UPDATE shows SET reminder_mode = ? WHERE id = ?`, mode, showID)
+	return err
+}
+
+// moveShowToWatchlist switches a show to watchlist mode and forgets its progress.
+func moveShowToWatchlist(ctx context.Context, db *store.DB, showID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE shows SET reminder_mode = ?, last_watched_episode_id = NULL WHERE id = ?
+	`, ReminderModeWatchlist, showID)
+	return err
+}
+
+func toggleShowPinned(ctx context.Context, db *store.DB, showID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
 		UPDATE shows
-		SET notifications_enabled = CASE WHEN notifications_enabled = 1 THEN 0 ELSE 1 END
+		SET pinned = CASE WHEN pinned = 1 THEN 0 ELSE 1 END
 		WHERE id = ?
 	`, showID)
 	return err
 }
 
+func setShowNote(ctx context.Context, db *store.DB, showID int64, note string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `UPDATE shows SET note = ? WHERE id = ?`, note, showID)
+	return err
+}
+
 // Episodes & Seasons
 
-func upsertEpisode(
-	db *sql.DB,
+func upsertEpisode(ctx context.Context,
+	db Execer,
 	provider, showID, episodeID, title string,
 	season, number int,
 	airdate, airtime string,
 	airedAtUTC time.Time,
+	summary, imageURL string,
+	runtime int,
 ) error {
-	_, err := db.Exec(`
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
         INSERT INTO episodes_cache
         (provider, provider_show_id, provider_episode_id, season, number, title, airdate,
-		airtime, aired_at_utc, fetched_at)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		airtime, aired_at_utc, summary, image_url, runtime, fetched_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
         ON CONFLICT(provider, provider_episode_id) DO UPDATE SET
+            provider_show_id=excluded.provider_show_id,
             title=excluded.title,
             season=excluded.season,
             number=excluded.number,
             airdate=excluded.airdate,
             airtime=excluded.airtime,
             aired_at_utc=excluded.aired_at_utc,
+            summary=excluded.summary,
+            image_url=excluded.image_url,
+            runtime=excluded.runtime,
             fetched_at=CURRENT_TIMESTAMP
 	`, provider, showID, episodeID, season, number, title, airdate, airtime,
-		airedAtUTC.UTC().Format(time.RFC3339))
+		airedAtUTC.UTC().Format(time.RFC3339), summary, imageURL, runtime)
 	return err
 }
 
-func findEpisodeByNumber(db *sql.DB, providerShowId string, season, number int) (*DBEpisode, error) {
-	var episode DBEpisode
-	var airedAtStr string
-	var fetchedAtStr string
+// episodeColumns lists the episodes_cache columns in the order scanEpisode expects.
+const episodeColumns = `
+	id, provider, provider_show_id, provider_episode_id, season, number,
+	title, airdate, airtime, aired_at_utc, fetched_at, summary`
 
-	err := db.QueryRow(`
-		SELECT
-			id, provider, provider_show_id, provider_episode_id, season, number, 
-			title, airdate, airtime, aired_at_utc, fetched_at
-		FROM episodes_cache
-		WHERE provider_show_id = ? and season = ? and number = ?
-	`, providerShowId, season, number).Scan(
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEpisode(row rowScanner) (*DBEpisode, error) {
+	var episode DBEpisode
+	var airedAtStr, fetchedAtStr string
+	err := row.Scan(
 		&episode.ID, &episode.Provider, &episode.ProviderShowID, &episode.ProviderEpisodeID,
 		&episode.Season, &episode.Number, &episode.Title, &episode.Airdate, &episode.Airtime,
-		&airedAtStr, &fetchedAtStr,
+		&airedAtStr, &fetchedAtStr, &episode.Summary,
 	)
-
-	if err == sql.ErrNoRows {
-		return nil, errors.New("episode not found")
-	}
 	if err != nil {
 		return nil, err
 	}
-
 	if airedAtStr != "" {
 		episode.AiredAtUTC, _ = time.Parse(time.RFC3339, airedAtStr)
 	}
 	if fetchedAtStr != "" {
 		episode.FetchedAt, _ = time.Parse(time.RFC3339, fetchedAtStr)
 	}
-
 	return &episode, nil
 }
 
-func updateLastWatchedEpisode(db *sql.DB, showID int64, episodeID int64) error {
-	_, err := db.Exec(`
+func findEpisodeByNumber(ctx context.Context, db *store.DB, provider, providerShowId string, season, number int) (*DBEpisode, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	episode, err := scanEpisode(db.QueryRowContext(ctx, `
+		SELECT `+episodeColumns+`
+		FROM episodes_cache
+		WHERE provider = ? AND provider_show_id = ? AND season = ? AND number = ?
+	`, provider, providerShowId, season, number))
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("episode not found: %w", sql.ErrNoRows)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return episode, nil
+}
+
+// updateLastWatchedEpisode records that the user just watched the episode: it sets
+// the show's progress, logs the watch for the yearly recap and queues it for the
+// user's Trakt history. A zero episodeID clears the progress.
+func updateLastWatchedEpisode(ctx context.Context, db *store.DB, showID int64, episodeID int64, now time.Time) error {
+	previousID, err := getLastWatchedEpisodeID(ctx, db, showID)
+	if err != nil {
+		return err
+	}
+	if err := setLastWatchedEpisode(ctx, db, showID, episodeID, now, now); err != nil {
+		return err
+	}
+	if err := logWatchedEpisodes(ctx, db, showID, previousID, episodeID, now); err != nil {
+		log.Printf("updateLastWatchedEpisode: logging watched episodes of show %d: %v", showID, err)
+	}
+	if err := dropSkippedEpisodes(ctx, db, showID, false); err != nil {
+		log.Printf("updateLastWatchedEpisode: dropping skipped episodes of show %d: %v", showID, err)
+	}
+	if episodeID == 0 {
+		return nil
+	}
+	if err := enqueueTraktPush(ctx, db, showID, episodeID, now); err != nil {
+		log.Printf("updateLastWatchedEpisode: queueing Trakt push for show %d: %v", showID, err)
+	}
+	return nil
+}
+
+// setLastWatchedEpisode sets the show's progress and reconciles its reminder with
+// it, so moving progress back doesn't leave a reminder for a later episode behind.
+// A zero episodeID clears the progress.
+func setLastWatchedEpisode(ctx context.Context, db *store.DB, showID, episodeID int64, watchedAt, now time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var lastWatched any
+	if episodeID != 0 {
+		lastWatched = episodeID
+	}
+	_, err := db.ExecContext(ctx, `
 		UPDATE shows
-		SET last_watched_episode_id = ?
+		SET last_watched_episode_id = ?, last_watched_at = ?
 		WHERE id = ?
-	`, episodeID, showID)
-	return err
+	`, lastWatched, watchedAt.UTC().Format(time.RFC3339), showID)
+	if err != nil {
+		return err
+	}
+
+	userID, chatID, threadID, err := getShowReminderChat(ctx, db, showID)
+	if err != nil {
+		return err
+	}
+	if _, err := rebuildShowReminder(ctx, db, userID, showID, chatID, threadID, now); err != nil {
+		return err
+	}
+	if err := enqueueProgressWebhook(ctx, db, showID); err != nil {
+		log.Printf("setLastWatchedEpisode: queueing webhook for show %d: %v", showID, err)
+	}
+	return nil
+}
+
+// getShowReminderChat returns the owner of a show and the chat its reminders go to:
+// the one of the current reminder, else the user's chat, else their private chat.
+// The forum topic is kept only along with the current reminder's chat.
+func getShowReminderChat(ctx context.Context, db *store.DB, showID int64) (userID, chatID int64, threadID int, err error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	err = db.QueryRowContext(ctx, `
+		SELECT s.user_id, COALESCE(
+			(SELECT r.chat_id FROM reminders r WHERE r.show_id = s.id AND r.user_id = s.user_id ORDER BY r.id DESC LIMIT 1),
+			(SELECT us.chat_id FROM user_settings us WHERE us.user_id = s.user_id),
+			s.user_id
+		), COALESCE(
+			(SELECT r.thread_id FROM reminders r WHERE r.show_id = s.id AND r.user_id = s.user_id ORDER BY r.id DESC LIMIT 1),
+			0
+		)
+		FROM shows s
+		WHERE s.id = ?
+	`, showID).Scan(&userID, &chatID, &threadID)
+	return userID, chatID, threadID, err
 }
 
-func getSeasons(db *sql.DB, providerShowID string) ([]int, error) {
-	rows, err := db.Query(`
+func getSeasons(ctx context.Context, db *store.DB, provider, providerShowID string) ([]int, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
 		SELECT DISTINCT season
 		FROM episodes_cache
-		WHERE provider_show_id = ?
+		WHERE provider = ? AND provider_show_id = ?
 		ORDER BY season
-	`, providerShowID)
+	`, provider, providerShowID)
 	if err != nil {
 		return nil, err
 	}
@@ -335,15 +587,16 @@ func getSeasons(db *sql.DB, providerShowID string) ([]int, error) {
 	return seasons, nil
 }
 
-func getEpisodesBySeason(db *sql.DB, providerShowID string, season int) ([]DBEpisode, error) {
-	rows, err := db.Query(`
-		SELECT
-			id, provider, provider_show_id, provider_episode_id, season, number,
-			title, airdate, airtime, aired_at_utc, fetched_at
+func getEpisodesBySeason(ctx context.Context, db *store.DB, provider, providerShowID string, season int) ([]DBEpisode, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+episodeColumns+`
 		FROM episodes_cache
-		WHERE provider_show_id = ? AND season = ?
+		WHERE provider = ? AND provider_show_id = ? AND season = ?
 		ORDER BY number
-	`, providerShowID, season)
+	`, provider, providerShowID, season)
 	if err != nil {
 		return nil, err
 	}
@@ -351,72 +604,139 @@ func getEpisodesBySeason(db *sql.DB, providerShowID string, season int) ([]DBEpi
 
 	var episodes []DBEpisode
 	for rows.Next() {
-		var episode DBEpisode
-		var airedAtStr, fetchedAtStr string
-		err := rows.Scan(
-			&episode.ID, &episode.Provider, &episode.ProviderShowID, &episode.ProviderEpisodeID,
-			&episode.Season, &episode.Number, &episode.Title, &episode.Airdate, &episode.Airtime,
-			&airedAtStr, &fetchedAtStr,
-		)
+		episode, err := scanEpisode(rows)
 		if err != nil {
 			return nil, err
 		}
-		if airedAtStr != "" {
-			episode.AiredAtUTC, _ = time.Parse(time.RFC3339, airedAtStr)
-		}
-		if fetchedAtStr != "" {
-			episode.FetchedAt, _ = time.Parse(time.RFC3339, fetchedAtStr)
-		}
-		episodes = append(episodes, episode)
+		episodes = append(episodes, *episode)
 	}
 	return episodes, nil
 }
 
-type Querier interface {
-	QueryRow(query string, args ...interface{}) *sql.Row
-}
+func findNextEpisodeByProviderID(ctx context.Context, q Querier, provider, providerShowID string, season, episode int) (*DBEpisode, error) {
+	ctx, cancel := q.WithQueryTimeout(ctx)
+	defer cancel()
 
-func findNextEpisodeByProviderID(q Querier, providerShowID string, season, episode int) (*DBEpisode, error) {
-	var nextEpisode DBEpisode
-	var airedAtStr string
-	var fetchedAtStr string
-
-	err := q.QueryRow(`
-		SELECT
-			id, provider, provider_show_id, provider_episode_id, season, number,
-			title, airdate, airtime, aired_at_utc, fetched_at
+	nextEpisode, err := scanEpisode(q.QueryRowContext(ctx, `
+		SELECT `+episodeColumns+`
 		FROM episodes_cache
-		WHERE provider_show_id = ?
+		WHERE provider = ? AND provider_show_id = ?
 		AND (
 			(season = ? AND number > ?) OR
 			(season > ?)
 		)
 		ORDER BY season, number
 		LIMIT 1
-	`, providerShowID, season, episode, season).Scan(
-		&nextEpisode.ID, &nextEpisode.Provider, &nextEpisode.ProviderShowID, &nextEpisode.ProviderEpisodeID,
-		&nextEpisode.Season, &nextEpisode.Number, &nextEpisode.Title, &nextEpisode.Airdate, &nextEpisode.Airtime,
-		&airedAtStr, &fetchedAtStr,
-	)
+	`, provider, providerShowID, season, episode, season))
 
 	if err == sql.ErrNoRows {
-		return nil, errors.New("no next episode found")
+		return nil, fmt.Errorf("no next episode found: %w", sql.ErrNoRows)
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	if airedAtStr != "" {
-		nextEpisode.AiredAtUTC, _ = time.Parse(time.RFC3339, airedAtStr)
+	return nextEpisode, nil
+}
+
+// findPreviousEpisode returns the regular episode before season/number, or
+// sql.ErrNoRows for the first one.
+func findPreviousEpisode(ctx context.Context, db *store.DB, provider, providerShowID string, season, number int) (*DBEpisode, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	return scanEpisode(db.QueryRowContext(ctx, `
+		SELECT `+episodeColumns+`
+		FROM episodes_cache
+		WHERE provider = ? AND provider_show_id = ? AND season > 0
+		AND (
+			(season = ? AND number < ?) OR
+			(season < ?)
+		)
+		ORDER BY season DESC, number DESC
+		LIMIT 1
+	`, provider, providerShowID, season, number, season))
+}
+
+// findLatestAiredEpisode returns the most recent episode that has already aired.
+// Season 0 specials are skipped, as they are never part of regular progress.
+func findLatestAiredEpisode(ctx context.Context, db *store.DB, provider, providerShowID string) (*DBEpisode, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	episode, err := scanEpisode(db.QueryRowContext(ctx, `
+		SELECT `+episodeColumns+`
+		FROM episodes_cache
+		WHERE provider = ? AND provider_show_id = ?
+		AND season > 0
+		AND aired_at_utc <= strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		ORDER BY season DESC, number DESC
+		LIMIT 1
+	`, provider, providerShowID))
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no aired episodes found: %w", sql.ErrNoRows)
 	}
-	if fetchedAtStr != "" {
-		nextEpisode.FetchedAt, _ = time.Parse(time.RFC3339, fetchedAtStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return episode, nil
+}
+
+func findSeasonFinale(ctx context.Context, q Querier, provider, providerShowID string, season int) (*DBEpisode, error) {
+	ctx, cancel := q.WithQueryTimeout(ctx)
+	defer cancel()
+
+	finale, err := scanEpisode(q.QueryRowContext(ctx, `
+		SELECT `+episodeColumns+`
+		FROM episodes_cache
+		WHERE provider = ? AND provider_show_id = ? AND season = ?
+		ORDER BY number DESC
+		LIMIT 1
+	`, provider, providerShowID, season))
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("season finale not found: %w", sql.ErrNoRows)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return &nextEpisode, nil
+	return finale, nil
 }
 
-func findNextEpisode(db *sql.DB, providerShowID string, lastSeason sql.NullInt32, lastEpisode sql.NullInt32) (*DBEpisode, error) {
+// findNextPremiere returns the first upcoming season premiere at or after the next
+// episode. It returns sql.ErrNoRows when no premiere is scheduled.
+func findNextPremiere(ctx context.Context, q Querier, provider, providerShowID string, next *DBEpisode) (*DBEpisode, error) {
+	ctx, cancel := q.WithQueryTimeout(ctx)
+	defer cancel()
+
+	return scanEpisode(q.QueryRowContext(ctx, `
+		SELECT `+episodeColumns+`
+		FROM episodes_cache
+		WHERE provider = ? AND provider_show_id = ? AND number = 1
+		AND (season > ? OR (season = ? AND ? <= 1))
+		AND aired_at_utc > strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		ORDER BY season
+		LIMIT 1
+	`, provider, providerShowID, next.Season, next.Season, next.Number))
+}
+
+// reminderTargetEpisode returns the episode a reminder should fire for, given the
+// next unwatched episode and the show's reminder mode.
+func reminderTargetEpisode(ctx context.Context, q Querier, provider, providerShowID string, next *DBEpisode, mode string) (*DBEpisode, error) {
+	switch mode {
+	case ReminderModeSeason:
+		return findSeasonFinale(ctx, q, provider, providerShowID, next.Season)
+	case ReminderModeWatchlist:
+		return findNextPremiere(ctx, q, provider, providerShowID, next)
+	default:
+		return next, nil
+	}
+}
+
+func findNextEpisode(ctx context.Context, db *store.DB, provider, providerShowID string, lastSeason sql.NullInt32, lastEpisode sql.NullInt32) (*DBEpisode, error) {
 	var season, episode int
 	if lastSeason.Valid && lastEpisode.Valid {
 		season = int(lastSeason.Int32)
@@ -427,96 +747,311 @@ func findNextEpisode(db *sql.DB, providerShowID string, lastSeason sql.NullInt32
 		episode = 0
 	}
 
-	return findNextEpisodeByProviderID(db, providerShowID, season, episode)
+	return findNextEpisodeByProviderID(ctx, db, provider, providerShowID, season, episode)
 }
 
 // Reminders
 
-func createReminder(db *sql.DB, userID int64, showID int, episodeID int64, remindAt time.Time, chatID int64) error {
-	_, err := db.Exec(`
-		INSERT INTO reminders (user_id, show_id, episode_id, remind_at, chat_id)
-		VALUES (?, ?, ?, ?, ?)
-		ON CONFLICT DO NOTHING
-	`, userID, showID, episodeID, remindAt, chatID)
+// createReminder schedules the show's reminder, replacing any existing one. Retry
+// state is reset so a previously dead-lettered reminder becomes pending again.
+// threadID is the forum topic of the chat the reminder goes to, 0 for none.
+func createReminder(ctx context.Context, db *store.DB, userID int64, showID int, episodeID int64, remindAt time.Time, chatID int64, threadID int) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
+	if err := scheduleEpisodeReminder(ctx, tx, userID, showID, episodeID, remindAt, chatID, threadID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// scheduleEpisodeReminder makes the reminder for the episode the show's pending one.
+// An episode that was already reminded about is left alone, so nothing is ever
+// reminded about twice; the show then has no pending reminder.
+func scheduleEpisodeReminder(ctx context.Context, db Execer, userID int64, showID int, episodeID int64, remindAt time.Time, chatID int64, threadID int) error {
+	_, err := db.ExecContext(ctx, `
+		DELETE FROM reminders
+		WHERE user_id = ? AND show_id = ? AND episode_id != ? AND sent_at IS NULL
+	`, userID, showID, episodeID)
+	if err != nil {
+		return err
+	}
+	// Dropped shows get no reminders, whichever way one is scheduled
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO reminders (user_id, show_id, episode_id, remind_at, chat_id, thread_id)
+		SELECT ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM shows WHERE id = ? AND dropped_at IS NOT NULL)
+		ON CONFLICT(user_id, show_id, episode_id) DO UPDATE SET
+			remind_at = excluded.remind_at,
+			chat_id = excluded.chat_id,
+			thread_id = excluded.thread_id,
+			status = 'pending',
+			attempts = 0,
+			next_attempt_at = NULL,
+			last_error = NULL,
+			deleted_at = NULL
+		WHERE reminders.sent_at IS NULL
+	`, userID, showID, episodeID, remindAt.UTC().Format(time.RFC3339), chatID, threadID, showID)
 	return err
 }
 
-func getDueReminders(db *sql.DB) ([]DBReminder, error) {
-	rows, err := db.Query(`
-		SELECT
-			r.id, r.user_id, r.show_id, r.episode_id, r.remind_at, r.chat_id,
-			s.name, e.title, e.number, e.season
-		FROM reminders r
-		LEFT JOIN shows s ON s.id = r.show_id
-		LEFT JOIN episodes_cache e ON e.id = r.episode_id
-		WHERE r.remind_at <= DATETIME('now', '+5 minutes')
-		AND s.notifications_enabled = 1
-		`)
+func getDueReminders(ctx context.Context, db *store.DB, now time.Time) ([]DBReminder, error) {
+	due, err := db.DueReminders(ctx, now)
 	if err != nil {
 		return nil, err
 	}
+
+	// Users usually have several reminders due at once, so the quiet hours check
+	// (time zone lookup and window parsing) is done once per user.
+	quietUsers := make(map[int64]bool)
 	var reminders []DBReminder
-	for rows.Next() {
-		var reminder DBReminder
-		if err := rows.Scan(
-			&reminder.ID, &reminder.UserID, &reminder.ShowID, &reminder.EpisodeID,
-			&reminder.RemindAt, &reminder.ChatID, &reminder.ShowName,
-			&reminder.EpisodeTitle, &reminder.EpisodeNumber, &reminder.EpisodeSeason,
-		); err != nil {
-			return nil, err
+	for _, r := range due {
+		settings := UserSettings{Timezone: r.Timezone, QuietHours: r.QuietHours}
+		if !r.AiredAt.IsZero() {
+			r.AiredAt = r.AiredAt.In(settings.Location())
+		}
+		// Reminders inside the user's quiet hours stay in the table and are picked
+		// up by the first run of the reminder loop after the window closes.
+		quiet, seen := quietUsers[r.UserID]
+		if !seen {
+			quiet = settings.inQuietHours(now)
+			quietUsers[r.UserID] = quiet
 		}
-		reminders = append(reminders, reminder)
+		if quiet {
+			continue
+		}
+		reminders = append(reminders, DBReminder{DueReminder: r})
 	}
-
 	return reminders, nil
 }
 
-func markReminderSent(db *sql.DB, reminder DBReminder) error {
-	tx, err := db.Begin()
+// markReminderSent records the reminder as sent, along with the episodes batched
+// into it, and schedules the show's next reminder. Cancelling a reminder goes
+// through here too: the episode then counts as reminded about.
+func markReminderSent(ctx context.Context, db *store.DB, reminder DBReminder, now time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Get current episode details to find the next one
+	var chatID int64
+	var threadID int
+	err = tx.QueryRowContext(ctx, `
+		SELECT chat_id, COALESCE(thread_id, 0) FROM reminders WHERE id = ?
+	`, reminder.ID).Scan(&chatID, &threadID)
+	if err != nil {
+		return err
+	}
+	sentAt := now.UTC().Format(time.RFC3339)
+	_, err = tx.ExecContext(ctx, `
+		UPDATE reminders SET status = ?, sent_at = ?, next_attempt_at = NULL, last_error = NULL
+		WHERE id = ?
+	`, ReminderStatusSent, sentAt, reminder.ID)
+	if err != nil {
+		return err
+	}
+	for _, episode := range reminder.Batch {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO reminders (user_id, show_id, episode_id, remind_at, chat_id, thread_id, status, sent_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(user_id, show_id, episode_id) DO UPDATE SET status = excluded.status, sent_at = excluded.sent_at
+		`, reminder.UserID, reminder.ShowID, episode.ID, reminder.RemindAt.UTC().Format(time.RFC3339), chatID, threadID, ReminderStatusSent, sentAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Get current episode details to find the next one. A batched reminder moves on
+	// from the last episode it covered. The user's progress is left alone: being
+	// reminded of an episode doesn't mean it was watched.
+	episodeID := reminder.lastEpisodeID()
 	var currentSeason, currentNumber int
-	err = tx.QueryRow(`
+	err = tx.QueryRowContext(ctx, `
 		SELECT season, number FROM episodes_cache WHERE id = ?
-	`, reminder.EpisodeID).Scan(&currentSeason, &currentNumber)
+	`, episodeID).Scan(&currentSeason, &currentNumber)
 	if err != nil {
 		return err
 	}
 
-	var providerShowID string
-	err = tx.QueryRow(`SELECT provider_show_id FROM shows WHERE id = ?`, reminder.ShowID).Scan(&providerShowID)
+	var provider, providerShowID, reminderMode string
+	var releaseDelayHours int
+	err = tx.QueryRowContext(ctx, `
+		SELECT provider, provider_show_id, reminder_mode, release_delay_hours FROM shows WHERE id = ?
+	`, reminder.ShowID).Scan(&provider, &providerShowID, &reminderMode, &releaseDelayHours)
 	if err != nil {
 		return err
 	}
 
-	nextEpisode, err := findNextEpisodeByProviderID(tx, providerShowID, currentSeason, currentNumber)
-	if err != nil {
-		// No next episode found, delete the reminder
-		_, err = tx.Exec(`DELETE FROM reminders WHERE id = ?`, reminder.ID)
+	nextEpisode, err := findNextEpisodeByProviderID(ctx, tx, provider, providerShowID, currentSeason, currentNumber)
+	if err == nil {
+		nextEpisode, err = reminderTargetEpisode(ctx, tx, provider, providerShowID, nextEpisode, reminderMode)
+	}
+	// Without a next episode or its air date there's nothing to schedule yet
+	if err == nil && !nextEpisode.AiredAtUTC.IsZero() {
+		err = scheduleEpisodeReminder(
+			ctx, tx, reminder.UserID, int(reminder.ShowID), nextEpisode.ID,
+			releaseTime(nextEpisode.AiredAtUTC, releaseDelayHours), chatID, threadID,
+		)
 		if err != nil {
 			return err
 		}
-		return tx.Commit()
 	}
 
-	if !nextEpisode.AiredAtUTC.IsZero() {
-		_, err = tx.Exec(`
-			UPDATE reminders SET episode_id = ?, remind_at = ? WHERE id = ?
-		`, nextEpisode.ID, nextEpisode.AiredAtUTC, reminder.ID)
-		if err != nil {
-			return err
-		}
-	} else {
-		// No air date, delete the reminder
-		_, err = tx.Exec(`DELETE FROM reminders WHERE id = ?`, reminder.ID)
+	return tx.Commit()
+}
+
+// episodeBatchWindow is how close air times must be for episodes to share a reminder.
+const episodeBatchWindow = time.Hour
+
+// findEpisodesAiringWith returns the episodes of the same show that come after the
+// given one and air within episodeBatchWindow of it, like double episodes or a
+// season dropped at once.
+func findEpisodesAiringWith(ctx context.Context, db QueryExecer, episodeID int64) ([]DBEpisode, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	first, err := scanEpisode(db.QueryRowContext(ctx, `
+		SELECT `+episodeColumns+` FROM episodes_cache WHERE id = ?
+	`, episodeID))
+	if err != nil {
+		return nil, err
+	}
+	if first.AiredAtUTC.IsZero() {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+episodeColumns+`
+		FROM episodes_cache
+		WHERE provider = ? AND provider_show_id = ?
+		AND (season > ? OR (season = ? AND number > ?))
+		AND aired_at_utc >= ? AND aired_at_utc < ?
+		ORDER BY season, number
+	`, first.Provider, first.ProviderShowID, first.Season, first.Season, first.Number,
+		first.AiredAtUTC.UTC().Format(time.RFC3339),
+		first.AiredAtUTC.Add(episodeBatchWindow).UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var episodes []DBEpisode
+	for rows.Next() {
+		episode, err := scanEpisode(rows)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		episodes = append(episodes, *episode)
+	}
+	return episodes, rows.Err()
+}
+
+// rebuildShowReminder replaces the user's pending reminder for a show with one for
+// the episode their current progress and reminder mode point at. It returns the
+// episode the new reminder fires for, or nil when nothing is left to remind about.
+func rebuildShowReminder(ctx context.Context, db *store.DB, userID, showID, chatID int64, threadID int, now time.Time) (*DBEpisode, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var provider, providerShowID, reminderMode string
+	var releaseDelayHours int
+	var season, number sql.NullInt32
+	err := db.QueryRowContext(ctx, `
+		SELECT s.provider, s.provider_show_id, s.reminder_mode, s.release_delay_hours, e.season, e.number
+		FROM shows s
+		LEFT JOIN episodes_cache e ON e.id = s.last_watched_episode_id
+		WHERE s.id = ?
+	`, showID).Scan(&provider, &providerShowID, &reminderMode, &releaseDelayHours, &season, &number)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.ExecContext(ctx, `DELETE FROM reminders WHERE user_id = ? AND show_id = ? AND sent_at IS NULL`, userID, showID); err != nil {
+		return nil, err
+	}
+
+	nextEpisode, err := findNextEpisode(ctx, db, provider, providerShowID, season, number)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Nothing left to remind about
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	target, err := reminderTargetEpisode(ctx, db, provider, providerShowID, nextEpisode, reminderMode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	remindAt := releaseTime(target.AiredAtUTC, releaseDelayHours)
+	if target.AiredAtUTC.IsZero() || !remindAt.After(now) {
+		return nil, nil
+	}
+
+	if err := createReminder(ctx, db, userID, int(showID), target.ID, remindAt, chatID, threadID); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// markReminderAttemptFailed records a failed delivery and schedules a retry, or
+// dead-letters the reminder once it ran out of attempts.
+func markReminderAttemptFailed(ctx context.Context, db *store.DB, reminderID int64, attempts int, nextAttemptAt time.Time, lastErr string, dead bool) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	status := ReminderStatusPending
+	if dead {
+		status = ReminderStatusFailed
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE reminders
+		SET attempts = ?, next_attempt_at = ?, last_error = ?, status = ?
+		WHERE id = ?
+	`, attempts, nextAttemptAt.UTC().Format(time.RFC3339), lastErr, status, reminderID)
+	return err
+}
+
+// disableChatNotifications dead-letters all pending reminders for a chat the bot
+// can no longer reach and turns off notifications for the shows behind them. The
+// shows are flagged as auto-disabled so markUserActive can tell them apart from
+// shows the user muted on purpose.
+func disableChatNotifications(ctx context.Context, db *store.DB, chatID int64, reason string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE shows SET notifications_enabled = 0, notifications_auto_disabled = 1
+		WHERE notifications_enabled = 1
+		AND id IN (SELECT show_id FROM reminders WHERE chat_id = ?)
+	`, chatID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE reminders SET status = ?, last_error = ?
+		WHERE chat_id = ? AND status = ?
+	`, ReminderStatusFailed, reason, chatID, ReminderStatusPending)
+	if err != nil {
+		return err
 	}
 
 	return tx.Commit()
@@ -0,0 +1,169 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tvreminder/bot/internal/store"
+)
+
+func TestQueryTimeoutOnLockedDatabase(t *testing.T) {
+	db, err := store.Open(t.Context(), store.Config{
+		Path:         filepath.Join(t.TempDir(), "test.db"),
+		QueryTimeout: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Another connection holds the write lock and never lets go
+	conn, err := db.Conn(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(t.Context(), `BEGIN IMMEDIATE`); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.ExecContext(context.Background(), `ROLLBACK`)
+
+	start := time.Now()
+	err = setQuietHours(t.Context(), db, testUserID, testUserID, "23:00-07:00")
+	if err == nil {
+		t.Fatal("write succeeded while the database was locked")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("write took %s, want it bounded by the query timeout", elapsed)
+	}
+}
+
+func TestAddShowWithEpisodesRollsBack(t *testing.T) {
+	db := newTestDB(t)
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	// The connection goes away after the first chunk of episodes is written
+	episodes := makeEpisodes(1, 1, 2*episodeChunkSize, time.Now().AddDate(-1, 0, 0))
+	_, err := addShowWithEpisodes(
+		ctx, db, testUserID, "Night Shift", "tvmaze", 1, "NBC", "", episodes,
+		func(done, total int) { cancel() },
+	)
+	if err == nil {
+		t.Fatal("adding the show succeeded after its context was cancelled")
+	}
+
+	for _, table := range []string{"shows", "episodes_cache"} {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("%d rows left in %s, want the whole add rolled back", count, table)
+		}
+	}
+}
+
+func TestConnectionPragmas(t *testing.T) {
+	db := newTestDB(t)
+
+	// Every connection in the pool gets the pragmas, not just the first one
+	var conns []*sql.Conn
+	for range store.MaxConns {
+		conn, err := db.Conn(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	for i, conn := range conns {
+		var journalMode string
+		var foreignKeys int
+		if err := conn.QueryRowContext(t.Context(), `PRAGMA journal_mode`).Scan(&journalMode); err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.QueryRowContext(t.Context(), `PRAGMA foreign_keys`).Scan(&foreignKeys); err != nil {
+			t.Fatal(err)
+		}
+		if journalMode != "wal" || foreignKeys != 1 {
+			t.Errorf("connection %d: journal_mode = %s, foreign_keys = %d, want wal and 1", i, journalMode, foreignKeys)
+		}
+	}
+}
+
+func TestForeignKeysEnforced(t *testing.T) {
+	db := newTestDB(t)
+	ctx := t.Context()
+
+	err := createReminder(ctx, db, testUserID, 42, 1, time.Now(), testUserID, 0)
+	if err == nil {
+		t.Error("created a reminder for a show that doesn't exist")
+	}
+
+	showID, err := addShowWithEpisodes(
+		ctx, db, testUserID, "Night Shift", "tvmaze", 1, "NBC", "",
+		makeEpisodes(1, 1, 1, time.Now().AddDate(0, 0, 1)), nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var episodeID int64
+	if err := db.QueryRow(`SELECT id FROM episodes_cache`).Scan(&episodeID); err != nil {
+		t.Fatal(err)
+	}
+	if err := createReminder(ctx, db, testUserID, int(showID), episodeID, time.Now(), testUserID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`DELETE FROM shows WHERE id = ?`, showID); err == nil {
+		t.Error("deleted a show that still has a reminder")
+	}
+}
+
+func TestEpisodeQueriesScopedByProvider(t *testing.T) {
+	db := newTestDB(t)
+	aired := time.Now().AddDate(0, -1, 0)
+	_, err := addShowWithEpisodes(
+		t.Context(), db, testUserID, "Night Shift", "tvmaze", 1, "NBC", "", makeEpisodes(1, 1, 2, aired), nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A different show that happens to have the same ID on another provider
+	if err := storeEpisodes(t.Context(), db, "thetvdb", "1", makeEpisodes(1, 4, 2, aired)); err != nil {
+		t.Fatal(err)
+	}
+
+	seasons, err := getSeasons(t.Context(), db, "tvmaze", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seasons) != 1 || seasons[0] != 1 {
+		t.Errorf("seasons = %v, want [1]", seasons)
+	}
+	latest, err := findLatestAiredEpisode(t.Context(), db, "tvmaze", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Provider != "tvmaze" || latest.Season != 1 || latest.Number != 2 {
+		t.Errorf("latest aired = %s S%02dE%02d, want tvmaze S01E02", latest.Provider, latest.Season, latest.Number)
+	}
+	if _, err := findNextEpisode(t.Context(), db, "tvmaze", "1", sql.NullInt32{Int32: 1, Valid: true}, sql.NullInt32{Int32: 2, Valid: true}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("next episode after the finale: err = %v, want sql.ErrNoRows", err)
+	}
+
+	shows, err := listShowsWithProgress(t.Context(), db, testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shows) != 1 {
+		t.Fatalf("listed %d shows, want 1", len(shows))
+	}
+	if got := shows[0]; got.EpisodesWaiting != 2 || got.NextEpisodeSeason.Int32 != 1 {
+		t.Errorf("waiting = %d, next season = %d, want 2 waiting from season 1", got.EpisodesWaiting, got.NextEpisodeSeason.Int32)
+	}
+}
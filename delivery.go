@@ -0,0 +1,226 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// A sent reminder keeps the ID of the Telegram message it was delivered as, and the
+// time the user first did something with that message: pressed a button on it,
+// reacted to it or replied to it. Reminders that ran out of retries are listed in
+// /reminders to be retried or dismissed, and go in the user's next digest.
+
+// setReminderMessageID records the Telegram message a reminder was delivered as.
+func setReminderMessageID(ctx context.Context, db *store.DB, reminderID int64, messageID int) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `UPDATE reminders SET message_id = ? WHERE id = ?`, messageID, reminderID)
+	return err
+}
+
+// markReminderInteracted records the first interaction with a reminder message. Other
+// messages are left alone.
+func markReminderInteracted(ctx context.Context, db *store.DB, chatID int64, messageID int, at time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE reminders SET interacted_at = ?
+		WHERE chat_id = ? AND message_id = ? AND interacted_at IS NULL
+	`, at.UTC().Format(time.RFC3339), chatID, messageID)
+	return err
+}
+
+// noteReminderInteraction records that the update's user did something with the
+// message in chatID, which may be a reminder.
+func (handler *Handler) noteReminderInteraction(ctx context.Context, chatID int64, messageID int) {
+	if err := markReminderInteracted(ctx, handler.DB, chatID, messageID, handler.Clock.Now()); err != nil {
+		log.Printf("handleUpdate: recording interaction with message %d in chat %d: %v", messageID, chatID, err)
+	}
+}
+
+// UndeliveredReminder is a reminder that ran out of retries.
+type UndeliveredReminder struct {
+	DBReminder
+	LastError string
+}
+
+// listUndeliveredReminders returns the user's dead-lettered reminders, oldest first.
+// With unescalated set only the ones no digest mentioned yet are returned.
+func listUndeliveredReminders(ctx context.Context, db *store.DB, userID int64, unescalated bool) ([]UndeliveredReminder, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+pendingReminderColumns+`, COALESCE(r.last_error, '')
+		FROM reminders r
+		JOIN shows s ON s.id = r.show_id
+		JOIN episodes_cache e ON e.id = r.episode_id
+		WHERE r.user_id = ? AND r.status = ? AND r.deleted_at IS NULL AND s.deleted_at IS NULL
+		AND (? = 0 OR r.escalated_at IS NULL)
+		ORDER BY r.remind_at, s.name
+	`, userID, ReminderStatusFailed, unescalated)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []UndeliveredReminder
+	for rows.Next() {
+		var r UndeliveredReminder
+		err := rows.Scan(
+			&r.ID, &r.UserID, &r.ShowID, &r.EpisodeID, &r.RemindAt,
+			&r.ChatID, &r.ShowName, &r.EpisodeTitle, &r.EpisodeNumber,
+			&r.EpisodeSeason, &r.ReminderMode, &r.LastError,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, r)
+	}
+	return reminders, rows.Err()
+}
+
+// markRemindersEscalated records that a digest told the user about the reminders.
+func markRemindersEscalated(ctx context.Context, db *store.DB, reminders []UndeliveredReminder, at time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	for _, r := range reminders {
+		_, err := db.ExecContext(ctx, `UPDATE reminders SET escalated_at = ? WHERE id = ?`, at.UTC().Format(time.RFC3339), r.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retryUndeliveredReminder puts a dead-lettered reminder of the user back in line
+// to be sent right away. It reports false when there's no such reminder.
+func retryUndeliveredReminder(ctx context.Context, db *store.DB, userID, reminderID int64, now time.Time) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, `
+		UPDATE reminders
+		SET status = ?, attempts = 0, next_attempt_at = NULL, last_error = NULL, escalated_at = NULL, remind_at = ?
+		WHERE id = ? AND user_id = ? AND status = ? AND deleted_at IS NULL
+	`, ReminderStatusPending, now.UTC().Format(time.RFC3339), reminderID, userID, ReminderStatusFailed)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// formatUndelivered lists reminders that couldn't be delivered for /reminders.
+func formatUndelivered(reminders []UndeliveredReminder, loc *time.Location) string {
+	var b strings.Builder
+	b.WriteString("<b>⚠️ Not delivered</b>\n")
+	for i, r := range reminders[:min(maxListedReminders, len(reminders))] {
+		fmt.Fprintf(
+			&b, "%d. %s - %s\n",
+			i+1, r.RemindAt.In(loc).Format("Mon Jan 2, 15:04"), html.EscapeString(reminderSubject(r.DBReminder)),
+		)
+		if r.LastError != "" {
+			fmt.Fprintf(&b, "   <i>%s</i>\n", html.EscapeString(trimString(r.LastError, 100)))
+		}
+	}
+	return b.String()
+}
+
+func makeUndeliveredKeyboardRows(reminders []UndeliveredReminder) [][][]string {
+	var rows [][][]string
+	for i, r := range reminders[:min(maxListedReminders, len(reminders))] {
+		rows = append(rows, [][]string{
+			{fmt.Sprintf("⚠️ %d. 🔁 Retry", i+1), fmt.Sprintf("retryReminder:%d", r.ID)},
+			{fmt.Sprintf("⚠️ %d. ✖️ Dismiss", i+1), fmt.Sprintf("dismissReminder:%d", r.ID)},
+		})
+	}
+	return rows
+}
+
+// formatEscalation is the digest section about reminders that never arrived.
+func formatEscalation(reminders []UndeliveredReminder, loc *time.Location) string {
+	var b strings.Builder
+	b.WriteString("<b>Reminders I couldn't deliver</b>\n\n")
+	for _, r := range reminders[:min(maxListedReminders, len(reminders))] {
+		fmt.Fprintf(
+			&b, "%s - %s\n",
+			r.RemindAt.In(loc).Format("Mon Jan 2, 15:04"), html.EscapeString(reminderSubject(r.DBReminder)),
+		)
+	}
+	b.WriteString("\nRetry or dismiss them in /reminders.\n")
+	return b.String()
+}
+
+func (handler *Handler) handleRetryReminderCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	reminderID, err := strconv.ParseInt(callbackParam, 10, 64)
+	if err != nil {
+		log.Printf("handleRetryReminderCallback: invalid reminder id: %s", callbackParam)
+		return nil
+	}
+	userID := cb.From.ID
+
+	ok, err := retryUndeliveredReminder(ctx, handler.DB, userID, reminderID, handler.Clock.Now())
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("retrying reminder %d: %w", reminderID, err),
+			"Error retrying the reminder",
+		)
+	}
+	if !ok {
+		return NewUserError(
+			fmt.Errorf("undelivered reminder %d of user %d not found", reminderID, userID),
+			"This reminder has already been sent or changed. See /reminders.",
+		)
+	}
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return handler.showReminders(ctx, userID, cb.Message.Chat.ID, cb.Message.MessageID)
+}
+
+// handleDismissReminderCallback gives up on an undelivered reminder. Like a sent
+// one, it moves on to the show's next episode.
+func (handler *Handler) handleDismissReminderCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	reminderID, err := strconv.ParseInt(callbackParam, 10, 64)
+	if err != nil {
+		log.Printf("handleDismissReminderCallback: invalid reminder id: %s", callbackParam)
+		return nil
+	}
+	userID := cb.From.ID
+
+	undelivered, err := listUndeliveredReminders(ctx, handler.DB, userID, false)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing undelivered reminders of user %d: %w", userID, err),
+			"Error dismissing the reminder",
+		)
+	}
+	for _, r := range undelivered {
+		if r.ID != reminderID {
+			continue
+		}
+		if err := markReminderSent(ctx, handler.DB, r.DBReminder, handler.Clock.Now()); err != nil {
+			return NewUserError(
+				fmt.Errorf("dismissing reminder %d: %w", reminderID, err),
+				"Error dismissing the reminder",
+			)
+		}
+		handler.Bot.answerCallbackQuery(cb.ID)
+		return handler.showReminders(ctx, userID, cb.Message.Chat.ID, cb.Message.MessageID)
+	}
+	return NewUserError(
+		fmt.Errorf("undelivered reminder %d of user %d not found", reminderID, userID),
+		"This reminder has already been sent or changed. See /reminders.",
+	)
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestReminderDeliveryTracking(t *testing.T) {
+	env := dueReminderEnv(t)
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+
+	messageID := queryString(t, env, `SELECT COALESCE(message_id, 0) FROM reminders WHERE sent_at IS NOT NULL`)
+	if messageID == "0" {
+		t.Fatal("message ID of the sent reminder wasn't stored")
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM reminders WHERE interacted_at IS NOT NULL`); got != "0" {
+		t.Fatalf("%s reminders marked interacted before any interaction", got)
+	}
+
+	id, err := strconv.Atoi(messageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.handler.handleUpdate(t.Context(), tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID:      2,
+		From:           &tgbotapi.User{ID: testUserID},
+		Chat:           &tgbotapi.Chat{ID: testUserID},
+		Text:           "can't wait",
+		ReplyToMessage: &tgbotapi.Message{MessageID: id, Chat: &tgbotapi.Chat{ID: testUserID}},
+	}})
+	if got := queryString(t, env, `SELECT COUNT(*) FROM reminders WHERE interacted_at IS NOT NULL`); got != "1" {
+		t.Errorf("%s reminders marked interacted after a reply, want 1", got)
+	}
+}
+
+func TestUndeliveredReminders(t *testing.T) {
+	env := dueReminderEnv(t)
+	env.command("/digest weekly")
+	env.handler.DB.Exec(`UPDATE reminders SET status = 'failed', attempts = 5, last_error = 'Bad Gateway'`)
+
+	env.command("/reminders")
+	msg := env.telegram.lastMessage(t)
+	if text := msg.Params.Get("text"); !strings.Contains(text, "Not delivered") || !strings.Contains(text, "Night Shift S02E03") {
+		t.Errorf("/reminders = %q, want the undelivered reminder", text)
+	}
+	if labels := msg.labels(t); !slices.Contains(labels, "⚠️ 1. 🔁 Retry") {
+		t.Errorf("keyboard = %v, want a retry button", labels)
+	}
+
+	// The next digest mentions it, once
+	settings, err := getUserSettings(t.Context(), env.handler.DB, testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := sendDigest(t.Context(), env.handler.Bot, env.handler.DB, nil, *settings, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := 0
+	for _, m := range env.telegram.messages() {
+		if strings.Contains(m.Params.Get("text"), "Reminders I couldn't deliver") {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("%d digests mentioned the undelivered reminder, want 1", n)
+	}
+
+	id := queryString(t, env, `SELECT id FROM reminders`)
+	env.press("retryReminder:" + id)
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if got := countSent(env); got != 1 {
+		t.Errorf("%d reminders sent after the retry, want 1", got)
+	}
+	if got := queryString(t, env, `SELECT status FROM reminders WHERE id = `+id); got != ReminderStatusSent {
+		t.Errorf("status after the retry = %s, want sent", got)
+	}
+}
@@ -0,0 +1,366 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/store"
+)
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DeliveryEmail sends digests to the user's confirmed email address only.
+const DeliveryEmail = "email"
+
+// digestHour is the local hour from which the digest of the day is sent. Weekly
+// digests go out on Mondays.
+const digestHour = 9
+
+// digestPeriod is how far ahead a digest looks.
+func digestPeriod(digest string) time.Duration {
+	if digest == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// digestDue reports whether the user's digest should be sent at now: past
+// digestHour on a digest day, and not sent yet that day.
+func digestDue(s UserSettings, now time.Time) bool {
+	loc := s.Location()
+	local := now.In(loc)
+	if local.Hour() < digestHour || (s.Digest == DigestWeekly && local.Weekday() != time.Monday) {
+		return false
+	}
+	if !s.LastDigestAt.Valid {
+		return true
+	}
+	last := s.LastDigestAt.Time.In(loc)
+	return last.Year() != local.Year() || last.YearDay() != local.YearDay()
+}
+
+func listDigestSubscribers(ctx context.Context, db *store.DB) ([]UserSettings, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			user_id, chat_id, timezone, digest, digest_delivery, email, email_verified, last_digest_at, digest_silent,
+			digest_tag
+		FROM user_settings
+		WHERE digest != '' AND inactive_since IS NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscribers []UserSettings
+	for rows.Next() {
+		var settings UserSettings
+		var emailVerified, digestSilent int
+		if err := rows.Scan(
+			&settings.UserID, &settings.ChatID, &settings.Timezone, &settings.Digest, &settings.DigestDelivery,
+			&settings.Email, &emailVerified, &settings.LastDigestAt, &digestSilent, &settings.DigestTag,
+		); err != nil {
+			return nil, err
+		}
+		settings.EmailVerified = emailVerified == 1
+		settings.DigestSilent = digestSilent == 1
+		subscribers = append(subscribers, settings)
+	}
+	return subscribers, rows.Err()
+}
+
+func setDigest(ctx context.Context, db *store.DB, userID, chatID int64, digest, delivery string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE user_settings SET digest = ?, digest_delivery = ? WHERE user_id = ?
+	`, digest, delivery, userID)
+	return err
+}
+
+func setDigestSilent(ctx context.Context, db *store.DB, userID, chatID int64, silent bool) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET digest_silent = ? WHERE user_id = ?`, silent, userID)
+	return err
+}
+
+func setDigestTag(ctx context.Context, db *store.DB, userID, chatID int64, tag string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET digest_tag = ? WHERE user_id = ?`, tag, userID)
+	return err
+}
+
+func updateLastDigestAt(ctx context.Context, db *store.DB, userID int64, sentAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET last_digest_at = ? WHERE user_id = ?`, sentAt, userID)
+	return err
+}
+
+// sendDigest sends the episodes airing in the user's digest period to Telegram,
+// email or both. Nothing is sent when nothing airs, but the day still counts as
+// done.
+func sendDigest(ctx context.Context, bot *Bot, db *store.DB, mailer Mailer, s UserSettings, now time.Time) error {
+	to := now.Add(digestPeriod(s.Digest))
+	episodes, err := listUpcomingEpisodes(ctx, db, s.UserID, now, to)
+	if err != nil {
+		return fmt.Errorf("listing upcoming episodes: %w", err)
+	}
+	if s.DigestTag != "" {
+		tagged, err := listTaggedShowIDs(ctx, db, s.UserID, s.DigestTag)
+		if err != nil {
+			return fmt.Errorf("listing shows tagged %q: %w", s.DigestTag, err)
+		}
+		episodes = slices.DeleteFunc(episodes, func(e UpcomingEpisode) bool { return !tagged[e.ShowID] })
+	}
+
+	undelivered, err := listUndeliveredReminders(ctx, db, s.UserID, true)
+	if err != nil {
+		return fmt.Errorf("listing undelivered reminders: %w", err)
+	}
+
+	text := formatUpcoming(episodes, nil, now, to, s.Location())
+	if len(undelivered) > 0 {
+		if text != "" {
+			text += "\n"
+		}
+		text += formatEscalation(undelivered, s.Location())
+	}
+	if text != "" {
+		toEmail := mailer != nil && s.EmailVerified && s.DigestDelivery != DeliveryTelegram
+		if !toEmail || s.DigestDelivery == DeliveryBoth {
+			if _, err := bot.send(ctx, s.ChatID, text, ReplyOptions{ParseMode: "HTML", Silent: s.DigestSilent}); err != nil {
+				return fmt.Errorf("sending digest: %w", err)
+			}
+		}
+		if toEmail {
+			subject := fmt.Sprintf("Your %s TV digest", s.Digest)
+			body := stripHTML(text) + "\n\nChange or stop this digest with /digest in Telegram.\n"
+			if err := mailer.SendMail(s.Email, subject, body); err != nil {
+				return fmt.Errorf("emailing digest: %w", err)
+			}
+		}
+		if err := markRemindersEscalated(ctx, db, undelivered, now); err != nil {
+			return fmt.Errorf("marking undelivered reminders escalated: %w", err)
+		}
+	}
+	return updateLastDigestAt(ctx, db, s.UserID, now)
+}
+
+// digestLoop sends daily and weekly digests of upcoming episodes to users who
+// subscribed with /digest, evening summaries to those who turned on /tonight, the
+// yearly recap and premiere heads-ups.
+func digestLoop(bot *Bot, db *store.DB, mailer Mailer, clk clock.Clock, ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := clk.Now()
+			sendDueDigests(ctx, bot, db, mailer, now)
+			sendDueTonightSummaries(ctx, bot, db, now)
+			sendDueRecaps(ctx, bot, db, now)
+			sendDuePremiereHypes(ctx, bot, db, now)
+		case <-ctx.Done():
+			log.Println("digestLoop: context cancelled, exiting")
+			return
+		}
+	}
+}
+
+func sendDueDigests(ctx context.Context, bot *Bot, db *store.DB, mailer Mailer, now time.Time) {
+	subscribers, err := listDigestSubscribers(ctx, db)
+	if err != nil {
+		log.Printf("digestLoop: listDigestSubscribers error: %v", err)
+		return
+	}
+	for _, s := range subscribers {
+		if !digestDue(s, now) {
+			continue
+		}
+		if err := sendDigest(ctx, bot, db, mailer, s, now); err != nil {
+			log.Printf("digestLoop: failed to send digest to user %d: %v", s.UserID, err)
+			handleDigestSendError(ctx, db, s, err, now)
+		}
+	}
+}
+
+// handleDigestSendError turns off digests for chats that reject the bot, like
+// handleExportSendError does for exports.
+func handleDigestSendError(ctx context.Context, db *store.DB, s UserSettings, sendErr error, now time.Time) {
+	if !isChatUnreachable(sendErr) {
+		return
+	}
+	if s.ChatID == s.UserID {
+		if err := markUserInactive(ctx, db, s.UserID, s.ChatID, now); err != nil {
+			log.Printf("digestLoop: failed to mark user %d inactive: %v", s.UserID, err)
+		}
+		return
+	}
+	if err := setDigest(ctx, db, s.UserID, s.ChatID, "", s.DigestDelivery); err != nil {
+		log.Printf("digestLoop: failed to disable digest for user %d: %v", s.UserID, err)
+	}
+}
+
+func describeDigest(settings *UserSettings) string {
+	if settings.Digest == "" {
+		return "Digest: off"
+	}
+	where := "here"
+	if settings.EmailVerified && settings.DigestDelivery == DeliveryEmail {
+		where = "by email"
+	} else if settings.EmailVerified && settings.DigestDelivery == DeliveryBoth {
+		where = "here and by email"
+	}
+	description := fmt.Sprintf("Digest: %s, %s", settings.Digest, where)
+	if settings.DigestSilent && where != "by email" {
+		description += ", silently"
+	}
+	if settings.DigestTag != "" {
+		description += ", shows tagged " + settings.DigestTag
+	}
+	return description
+}
+
+// DIGEST command
+
+func (handler *Handler) handleDigestCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments()))
+	arg, tagArg, _ := strings.Cut(arg, " ")
+
+	settings, err := getUserSettings(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting settings for user %d: %w", userID, err),
+			"Error: can't update your digest at this time",
+		)
+	}
+
+	digest, delivery := settings.Digest, settings.DigestDelivery
+	switch arg {
+	case "":
+		handler.Bot.reply(ctx, chatID, describeDigest(settings)+"\n\n"+dedent(`
+		/digest daily - every morning, the episodes airing that day
+		/digest weekly - every Monday, the episodes airing that week
+		/digest off - no digest
+		/digest email|both|telegram - where to send it, see /email
+		/digest silent|sound - whether it buzzes your phone
+		/digest tag <tag>|off - only shows with a tag, see /tags
+		`))
+		return nil
+	case DigestDaily, DigestWeekly:
+		digest = arg
+	case "off":
+		digest = ""
+	case DeliveryEmail, DeliveryBoth:
+		if handler.Mailer == nil || !settings.EmailVerified {
+			handler.Bot.reply(ctx, chatID, "Set and confirm your email with /email first.")
+			return nil
+		}
+		delivery = arg
+	case DeliveryTelegram:
+		delivery = arg
+	case "silent", "sound":
+		if err := setDigestSilent(ctx, handler.DB, userID, chatID, arg == "silent"); err != nil {
+			return NewUserError(
+				fmt.Errorf("setting digest sound for user %d: %w", userID, err),
+				"Error: can't update your digest at this time",
+			)
+		}
+		settings.DigestSilent = arg == "silent"
+		handler.Bot.reply(ctx, chatID, describeDigest(settings))
+		return nil
+	case "tag":
+		return handler.setDigestTag(ctx, msg, settings, strings.TrimSpace(tagArg))
+	default:
+		handler.Bot.reply(ctx, chatID, "Usage: /digest daily|weekly|off, /digest email|both|telegram, /digest silent|sound or /digest tag <tag>|off")
+		return nil
+	}
+
+	if err := setDigest(ctx, handler.DB, userID, chatID, digest, delivery); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting digest for user %d: %w", userID, err),
+			"Error: can't update your digest at this time",
+		)
+	}
+	settings.Digest, settings.DigestDelivery = digest, delivery
+	handler.Bot.reply(ctx, chatID, describeDigest(settings))
+	return nil
+}
+
+// SETTINGS command
+
+func (handler *Handler) handleSettingsCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	userID := msg.From.ID
+	settings, err := getUserSettings(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting settings for user %d: %w", userID, err),
+			"Error: can't get your settings at this time",
+		)
+	}
+
+	quietHours := settings.QuietHours
+	if quietHours == "" {
+		quietHours = "off"
+	}
+	lines := []string{
+		fmt.Sprintf("Time zone: %s (/timezone)", settings.Timezone),
+		fmt.Sprintf("Quiet hours: %s (/quiet)", quietHours),
+		fmt.Sprintf("Episode summaries: %s (/summaries)", onOff(settings.ShowSummaries)),
+		fmt.Sprintf("Air time alerts: %s (/airalerts)", onOff(settings.AirtimeAlerts)),
+		fmt.Sprintf("Auto-advance: %s (/autoadvance)", onOff(settings.AutoAdvance)),
+		describeReminderLinks(settings.ReminderLinks) + " (/links)",
+		describeDigest(settings) + " (/digest)",
+		describeTonight(settings) + " (/tonight)",
+		fmt.Sprintf("Premieres a week ahead: %s (/hype)", onOff(settings.PremiereHype)),
+		fmt.Sprintf("Counted in trending shows: %s (/trending)", onOff(settings.ShareStats)),
+	}
+	if settings.ReminderTemplate != "" {
+		lines = append(lines, "Reminder text: your own (/template)")
+	}
+	if handler.Mailer != nil {
+		lines = append(lines, describeEmail(settings)+" (/email)")
+	}
+	lines = append(lines, describeDelivery(settings)+" (/discord)")
+	handler.Bot.reply(ctx, msg.Chat.ID, "Your settings\n\n"+strings.Join(lines, "\n"))
+	return nil
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,134 @@
+package bot
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sentMail struct {
+	To, Subject, Body string
+}
+
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []sentMail
+}
+
+func (m *fakeMailer) SendMail(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, sentMail{to, subject, body})
+	return nil
+}
+
+func (m *fakeMailer) last(t *testing.T) sentMail {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sent) == 0 {
+		t.Fatal("no email sent")
+	}
+	return m.sent[len(m.sent)-1]
+}
+
+var emailCodeRe = regexp.MustCompile(`\d{6}`)
+
+// confirmTestEmail registers and confirms address for the test user.
+func confirmTestEmail(t *testing.T, env *testEnv, mailer *fakeMailer, address string) {
+	t.Helper()
+	env.command("/email " + address)
+	code := emailCodeRe.FindString(mailer.last(t).Body)
+	env.command("/email " + code)
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "Email confirmed") {
+		t.Fatalf("/email <code> = %q", got)
+	}
+}
+
+func TestEmailCommand(t *testing.T) {
+	env := newTestEnv(t)
+	mailer := &fakeMailer{}
+	env.handler.Mailer = mailer
+
+	env.command("/email not-an-address")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "That code doesn't match") {
+		t.Errorf("/email with a bad code = %q", got)
+	}
+
+	env.command("/email fan@example.com")
+	if got := mailer.last(t); got.To != "fan@example.com" || !emailCodeRe.MatchString(got.Body) {
+		t.Errorf("confirmation email = %+v", got)
+	}
+	env.command("/digest email")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "Set and confirm your email") {
+		t.Errorf("/digest email before confirming = %q", got)
+	}
+
+	confirmTestEmail(t, env, mailer, "fan@example.com")
+	env.command("/digest email")
+	if got := queryString(t, env, `SELECT digest_delivery FROM user_settings`); got != DeliveryEmail {
+		t.Errorf("digest_delivery = %s, want %s", got, DeliveryEmail)
+	}
+}
+
+func TestSendDigest(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	mailer := &fakeMailer{}
+	env.handler.Mailer = mailer
+	trackShow(t, env, "2")
+	confirmTestEmail(t, env, mailer, "fan@example.com")
+	env.command("/digest weekly")
+	env.command("/digest both")
+
+	settings, err := getUserSettings(t.Context(), env.handler.DB, testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sendDigest(t.Context(), env.handler.Bot, env.handler.DB, mailer, *settings, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "Night Shift S02E03") {
+		t.Errorf("Telegram digest = %q", got)
+	}
+	got := mailer.last(t)
+	if got.Subject != "Your weekly TV digest" || !strings.Contains(got.Body, `Night Shift S02E03 "Episode 2.3"`) {
+		t.Errorf("email digest = %+v", got)
+	}
+	if got := queryString(t, env, `SELECT last_digest_at IS NOT NULL FROM user_settings`); got != "1" {
+		t.Errorf("last_digest_at not recorded")
+	}
+}
+
+func TestDigestDue(t *testing.T) {
+	monday := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	sunday := monday.AddDate(0, 0, -1)
+	tests := []struct {
+		name     string
+		settings UserSettings
+		now      time.Time
+		want     bool
+	}{
+		{"daily, never sent", UserSettings{Digest: DigestDaily}, sunday, true},
+		{"daily, before the hour", UserSettings{Digest: DigestDaily}, monday.Add(-2 * time.Hour), false},
+		{"daily, sent today", UserSettings{
+			Digest: DigestDaily, LastDigestAt: sql.NullTime{Time: monday.Add(-time.Hour), Valid: true},
+		}, monday, false},
+		{"daily, sent yesterday", UserSettings{
+			Digest: DigestDaily, LastDigestAt: sql.NullTime{Time: sunday, Valid: true},
+		}, monday, true},
+		{"weekly on Sunday", UserSettings{Digest: DigestWeekly}, sunday, false},
+		{"weekly on Monday", UserSettings{Digest: DigestWeekly}, monday, true},
+		{"local time zone", UserSettings{Digest: DigestDaily, Timezone: "America/New_York"}, monday, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := digestDue(tt.settings, tt.now); got != tt.want {
+				t.Errorf("digestDue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
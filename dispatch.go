@@ -0,0 +1,71 @@
+package bot
+
+import (
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// updateDispatcher runs updates concurrently while keeping each user's updates
+// in order. Every routing key gets its own FIFO queue drained by at most one
+// goroutine, and a semaphore caps how many keys are processed at once.
+//
+// dispatch never blocks, so a user flooding the bot or a slow handler can't hold
+// up everyone else the way a fixed-size shared queue would. The price is that
+// queues are unbounded: a burst is buffered in memory instead of pushing back on
+// the Telegram long-poll.
+type updateDispatcher struct {
+	handle func(tgbotapi.Update)
+	sem    chan struct{}
+
+	mu     sync.Mutex
+	queues map[uint64][]tgbotapi.Update
+	// draining counts the goroutines draining a queue, for wait
+	draining sync.WaitGroup
+}
+
+func newUpdateDispatcher(workers int, handle func(tgbotapi.Update)) *updateDispatcher {
+	return &updateDispatcher{
+		handle: handle,
+		sem:    make(chan struct{}, workers),
+		queues: make(map[uint64][]tgbotapi.Update),
+	}
+}
+
+func (d *updateDispatcher) dispatch(key uint64, update tgbotapi.Update) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	queue, running := d.queues[key]
+	d.queues[key] = append(queue, update)
+	if !running {
+		d.draining.Go(func() { d.drain(key) })
+	}
+}
+
+// wait blocks until every dispatched update has been handled. Nothing may be
+// dispatched once it's called.
+func (d *updateDispatcher) wait() {
+	d.draining.Wait()
+}
+
+// drain processes the key's queue until it is empty and then forgets the key.
+func (d *updateDispatcher) drain(key uint64) {
+	d.sem <- struct{}{}
+	defer func() { <-d.sem }()
+
+	for {
+		d.mu.Lock()
+		queue := d.queues[key]
+		if len(queue) == 0 {
+			delete(d.queues, key)
+			d.mu.Unlock()
+			return
+		}
+		update := queue[0]
+		d.queues[key] = queue[1:]
+		d.mu.Unlock()
+
+		d.handle(update)
+	}
+}
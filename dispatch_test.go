@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestUpdateDispatcherKeepsPerKeyOrder(t *testing.T) {
+	const keys, perKey = 5, 50
+
+	var mu sync.Mutex
+	seen := make(map[uint64][]int)
+	var wg sync.WaitGroup
+	wg.Add(keys * perKey)
+
+	d := newUpdateDispatcher(2, func(update tgbotapi.Update) {
+		defer wg.Done()
+		key := uint64(update.Message.From.ID)
+		mu.Lock()
+		seen[key] = append(seen[key], update.UpdateID)
+		mu.Unlock()
+	})
+	for i := range perKey {
+		for key := range keys {
+			update := tgbotapi.Update{
+				UpdateID: i,
+				Message:  &tgbotapi.Message{From: &tgbotapi.User{ID: int64(key)}},
+			}
+			d.dispatch(uint64(key), update)
+		}
+	}
+	wg.Wait()
+
+	for key, ids := range seen {
+		for i, id := range ids {
+			if id != i {
+				t.Fatalf("key %d: update %d handled at position %d", key, id, i)
+			}
+		}
+	}
+}
+
+func TestUpdateDispatcherDoesNotBlockOnSlowKey(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan uint64, 1)
+
+	d := newUpdateDispatcher(2, func(update tgbotapi.Update) {
+		key := uint64(update.Message.From.ID)
+		if key == 1 {
+			<-release
+			return
+		}
+		done <- key
+	})
+	defer close(release)
+
+	slow := tgbotapi.Update{Message: &tgbotapi.Message{From: &tgbotapi.User{ID: 1}}}
+	for range 100 {
+		d.dispatch(1, slow)
+	}
+	d.dispatch(2, tgbotapi.Update{Message: &tgbotapi.Message{From: &tgbotapi.User{ID: 2}}})
+
+	select {
+	case key := <-done:
+		if key != 2 {
+			t.Fatalf("handled key %d, want 2", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("update for another user is stuck behind a slow user")
+	}
+}
+
+func TestUpdateDispatcherWaitsForDispatchedUpdates(t *testing.T) {
+	var mu sync.Mutex
+	handled := 0
+	d := newUpdateDispatcher(2, func(update tgbotapi.Update) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		handled++
+		mu.Unlock()
+	})
+	for i := range 20 {
+		d.dispatch(uint64(i%3), tgbotapi.Update{UpdateID: i})
+	}
+	d.wait()
+
+	if handled != 20 {
+		t.Fatalf("handled %d updates before wait returned, want 20", handled)
+	}
+}
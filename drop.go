@@ -0,0 +1,222 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Dropping a show is giving up on it: unlike archiving, it also stops the show's
+// reminders, announcements and digest entries. Dropped shows are listed last in
+// /history under their own header, with the reason the user gave, and /stats counts
+// the shows dropped this year.
+
+// maxDropReasonLength keeps reasons short enough for the show card.
+const maxDropReasonLength = 200
+
+// dropShow marks a show dropped and removes its pending reminder.
+func dropShow(ctx context.Context, db *store.DB, showID int64, reason string, now time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE shows SET dropped_at = ?, drop_reason = ? WHERE id = ?
+	`, now.UTC().Format(time.RFC3339), reason, showID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM reminders WHERE show_id = ? AND sent_at IS NULL`, showID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func resumeShow(ctx context.Context, db *store.DB, showID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `UPDATE shows SET dropped_at = NULL, drop_reason = '' WHERE id = ?`, showID)
+	return err
+}
+
+// DroppedShow is a show the user dropped, for /stats.
+type DroppedShow struct {
+	Name   string
+	Reason string
+}
+
+// listDroppedShows returns the user's shows dropped since since, latest first.
+func listDroppedShows(ctx context.Context, db *store.DB, userID int64, since time.Time) ([]DroppedShow, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT name, drop_reason FROM shows
+		WHERE user_id = ? AND deleted_at IS NULL AND dropped_at >= ?
+		ORDER BY dropped_at DESC
+	`, userID, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shows []DroppedShow
+	for rows.Next() {
+		var show DroppedShow
+		if err := rows.Scan(&show.Name, &show.Reason); err != nil {
+			return nil, err
+		}
+		shows = append(shows, show)
+	}
+	return shows, rows.Err()
+}
+
+// formatDropped describes when and why a show was dropped, for its card.
+func formatDropped(show *ShowProgress) string {
+	text := "🚫 Dropped on " + show.DroppedAt.Time.Format("Jan 2, 2006")
+	if show.DropReason != "" {
+		text += fmt.Sprintf(": <i>%s</i>", html.EscapeString(show.DropReason))
+	}
+	return text + "\n"
+}
+
+// handleDropShowCallback asks why the user is dropping the show; the reason is
+// optional, Skip drops it right away.
+func (handler *Handler) handleDropShowCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleDropShowCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	userID := cb.From.ID
+	chatID := cb.Message.Chat.ID
+	show, err := handler.validateAndGetShow(ctx, userID, chatID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.State = StateAwaitingDropReason
+		ctx.SelectedInternalID = show.InternalID
+	})
+	keyboard := makeKeyboardMarkup([][][]string{
+		{{"Skip", fmt.Sprintf("confirmDrop:%d:%s", showID, listType)}},
+		{{"❌ Cancel", "cancel"}},
+	})
+	handler.Bot.reply(ctx,
+		chatID,
+		fmt.Sprintf("Why are you dropping \"%s\"? Send me a reason, or press Skip.", show.Name),
+		ReplyOptions{ReplyMarkup: keyboard, EditMessageID: cb.Message.MessageID},
+	)
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleConfirmDropCallback drops the show without a reason.
+func (handler *Handler) handleConfirmDropCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleConfirmDropCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	show, err := handler.validateAndGetShow(ctx, cb.From.ID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+	handler.Bot.clearState(cb.From.ID)
+
+	if err := dropShow(ctx, handler.DB, show.InternalID, "", handler.Clock.Now()); err != nil {
+		return NewUserError(
+			fmt.Errorf("dropping show %d: %w", show.InternalID, err),
+			"Error dropping the show",
+		)
+	}
+	return handler.refreshShowDetail(ctx, cb, show, listType)
+}
+
+func (handler *Handler) acceptDropReason(ctx context.Context, msg *tgbotapi.Message) error {
+	userID := msg.From.ID
+	chatID := msg.Chat.ID
+
+	reason := strings.TrimSpace(msg.Text)
+	if reason == "" {
+		return NewUserError(
+			fmt.Errorf("empty drop reason from user %d", userID),
+			"Please send the reason as text, or press Skip.",
+		)
+	}
+	if utf8.RuneCountInString(reason) > maxDropReasonLength {
+		return NewUserError(
+			fmt.Errorf("drop reason from user %d is too long", userID),
+			fmt.Sprintf("That's a bit long, please keep it under %d characters.", maxDropReasonLength),
+		)
+	}
+
+	userCtx := handler.Bot.getUserContext(userID)
+	if userCtx == nil || userCtx.SelectedInternalID == 0 {
+		handler.Bot.clearState(userID)
+		return NewUserError(
+			fmt.Errorf("no show selected for drop reason from user %d", userID),
+			"No show selected. Please start over with /shows",
+		)
+	}
+	showID := userCtx.SelectedInternalID
+	showName := "the show"
+	for _, show := range userCtx.ShowsList {
+		if show.InternalID == showID {
+			showName = fmt.Sprintf("\"%s\"", show.Name)
+			break
+		}
+	}
+
+	if err := dropShow(ctx, handler.DB, showID, reason, handler.Clock.Now()); err != nil {
+		return NewUserError(
+			fmt.Errorf("dropping show %d: %w", showID, err),
+			"Error dropping the show, please try again later.",
+		)
+	}
+	handler.Bot.clearState(userID)
+	handler.Bot.reply(ctx, chatID, fmt.Sprintf("Dropped %s, you won't get reminders for it anymore. See /history.", showName))
+	return nil
+}
+
+// handleResumeShowCallback takes a dropped show back and schedules its reminder again.
+func (handler *Handler) handleResumeShowCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleResumeShowCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	chatID := cb.Message.Chat.ID
+	show, err := handler.validateAndGetShow(ctx, cb.From.ID, chatID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	if err := resumeShow(ctx, handler.DB, show.InternalID); err != nil {
+		return NewUserError(
+			fmt.Errorf("resuming show %d: %w", show.InternalID, err),
+			"Error updating the show",
+		)
+	}
+	if _, err := rebuildShowReminder(ctx, handler.DB, cb.From.ID, show.InternalID, chatID, updateThread(ctx, chatID), handler.Clock.Now()); err != nil {
+		return NewUserError(
+			fmt.Errorf("rebuilding reminder for show %d: %w", show.InternalID, err),
+			"Error updating reminder",
+		)
+	}
+	return handler.refreshShowDetail(ctx, cb, show, listType)
+}
@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDropShow(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	env.command("/shows")
+	env.press("selectShow:1:current")
+
+	env.press("dropShow:1:current")
+	env.text("Too slow")
+	if got, want := env.telegram.lastMessage(t).Params.Get("text"), "Dropped \"Night Shift\", you won't get reminders for it anymore. See /history."; got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM reminders WHERE sent_at IS NULL`); got != "0" {
+		t.Errorf("%s pending reminders left after dropping", got)
+	}
+
+	env.command("/shows")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "You have no current shows") {
+		t.Errorf("/shows with the show dropped = %q", got)
+	}
+	env.command("/history")
+	if labels := env.telegram.lastMessage(t).labels(t); len(labels) < 2 || labels[0] != "🚫 Dropped" || !strings.Contains(labels[1], "Night Shift") {
+		t.Errorf("history = %v, want the show under the dropped header", labels)
+	}
+	env.command("/stats")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "Shows you dropped this year: 1\n• Night Shift: <i>Too slow</i>") {
+		t.Errorf("stats = %q, want the dropped show", got)
+	}
+
+	env.press("selectShow:1:history")
+	card := env.telegram.lastMessage(t)
+	if !strings.Contains(card.Params.Get("text"), "🚫 Dropped on") || !slices.Contains(card.labels(t), "↩️ Resume") {
+		t.Errorf("card = %q %v, want it marked dropped", card.Params.Get("text"), card.labels(t))
+	}
+	env.press("resumeShow:1:history")
+	if got := queryString(t, env, `SELECT COUNT(*) FROM reminders WHERE sent_at IS NULL`); got != "1" {
+		t.Errorf("%s pending reminders after resuming, want 1", got)
+	}
+}
@@ -0,0 +1,31 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// commandEditWindow is how long after sending a command editing it runs it again.
+// Later edits are more likely tidying up the chat than fixing a typo.
+const commandEditWindow = 2 * time.Minute
+
+// handleEditedMessage runs an edited command again, as a correction of the
+// original: commands start their flow over, so the corrected one replaces
+// whatever the original started, like the results of a misspelled /add. Edits of
+// anything but a command are ignored, so fixing a progress update or a note
+// doesn't apply it twice.
+func (handler *Handler) handleEditedMessage(ctx context.Context, msg *tgbotapi.Message) {
+	if msg.From == nil || !msg.IsCommand() {
+		return
+	}
+	edited := time.Unix(int64(msg.EditDate), 0)
+	if edited.Sub(msg.Time()) > commandEditWindow {
+		log.Printf("handleEditedMessage: ignoring /%s from user %d edited %s later",
+			msg.Command(), msg.From.ID, edited.Sub(msg.Time()).Round(time.Second))
+		return
+	}
+	handler.handleCommand(ctx, msg)
+}
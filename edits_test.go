@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestEditedCommandRunsAgain(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	sent := time.Now().Add(-time.Minute)
+	edit := func(text string, editedAt time.Time) {
+		var entities []tgbotapi.MessageEntity
+		if text[0] == '/' {
+			entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/add")}}
+		}
+		env.handler.handleUpdate(t.Context(), tgbotapi.Update{EditedMessage: &tgbotapi.Message{
+			MessageID: 1,
+			From:      &tgbotapi.User{ID: testUserID},
+			Chat:      &tgbotapi.Chat{ID: testUserID},
+			Date:      int(sent.Unix()),
+			EditDate:  int(editedAt.Unix()),
+			Text:      text,
+			Entities:  entities,
+		}})
+		env.handler.addJobs.wait()
+	}
+
+	env.command("/add slo")
+	if got, want := env.telegram.lastMessage(t).Params.Get("text"), "No shows found for: slo"; got != want {
+		t.Fatalf("text = %q, want %q", got, want)
+	}
+
+	edit("/add solo", time.Now())
+	if got, want := env.telegram.lastMessage(t).Params.Get("text"), "Pick the show you want to add:"; got != want {
+		t.Errorf("after fixing the command text = %q, want %q", got, want)
+	}
+
+	n := len(env.telegram.messages())
+	edit("/add night", sent.Add(commandEditWindow+time.Second))
+	edit("not a command", time.Now())
+	if got := len(env.telegram.messages()); got != n {
+		t.Errorf("late and non-command edits sent %d messages", got-n)
+	}
+}
@@ -0,0 +1,224 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// emailCodeDigits is the length of the code that confirms an email address.
+const emailCodeDigits = 6
+
+// Mailer sends plain text emails.
+type Mailer interface {
+	SendMail(to, subject, body string) error
+}
+
+// SMTPMailer sends emails through an SMTP server, authenticating when a username
+// is configured.
+type SMTPMailer struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// smtpMailerFromEnv reads the SMTP settings. Email is off without SMTP_HOST.
+func smtpMailerFromEnv() (*SMTPMailer, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil, nil
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	mailer := &SMTPMailer{
+		Addr:     net.JoinHostPort(host, port),
+		From:     os.Getenv("SMTP_FROM"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}
+	if _, err := mail.ParseAddress(mailer.From); err != nil {
+		return nil, fmt.Errorf("invalid SMTP_FROM: %q", mailer.From)
+	}
+	return mailer, nil
+}
+
+func (m *SMTPMailer) SendMail(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, _ := net.SplitHostPort(m.Addr)
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, []string{to}, formatEmail(m.From, to, subject, body))
+}
+
+// formatEmail builds an RFC 5322 message with a UTF-8 plain text body.
+func formatEmail(from, to, subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+func generateEmailCode() (string, error) {
+	var limit big.Int
+	limit.Exp(big.NewInt(10), big.NewInt(emailCodeDigits), nil)
+	n, err := rand.Int(rand.Reader, &limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", emailCodeDigits, n), nil
+}
+
+// setPendingEmail stores an unconfirmed address with its confirmation code. Digests
+// go back to Telegram until the new address is confirmed.
+func setPendingEmail(ctx context.Context, db *store.DB, userID, chatID int64, email, code string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE user_settings
+		SET email = ?, email_code = ?, email_verified = 0, digest_delivery = ?
+		WHERE user_id = ?
+	`, email, code, DeliveryTelegram, userID)
+	return err
+}
+
+// confirmEmail marks the user's address as confirmed when code matches the one sent
+// to it.
+func confirmEmail(ctx context.Context, db *store.DB, userID int64, code string) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE user_settings SET email_verified = 1, email_code = ''
+		WHERE user_id = ? AND email != '' AND email_code != '' AND email_code = ?
+	`, userID, code)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// EMAIL command
+
+func (handler *Handler) handleEmailCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	arg := strings.TrimSpace(msg.CommandArguments())
+
+	if handler.Mailer == nil {
+		handler.Bot.reply(ctx, chatID, "Email isn't available on this bot.")
+		return nil
+	}
+
+	switch {
+	case arg == "":
+		settings, err := getUserSettings(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting settings for user %d: %w", userID, err),
+				"Error: can't get your email at this time",
+			)
+		}
+		handler.Bot.reply(ctx, chatID, describeEmail(settings)+"\n\n"+dedent(`
+		/email <address> - set the address for your digest
+		/email <code> - confirm it with the code I send you
+		/email off - forget your address
+		`))
+		return nil
+
+	case arg == "off":
+		if err := setPendingEmail(ctx, handler.DB, userID, chatID, "", ""); err != nil {
+			return NewUserError(
+				fmt.Errorf("removing email of user %d: %w", userID, err),
+				"Error: can't remove your email at this time",
+			)
+		}
+		handler.Bot.reply(ctx, chatID, "Email removed. Digests will come here.")
+		return nil
+
+	case !strings.Contains(arg, "@"):
+		confirmed, err := confirmEmail(ctx, handler.DB, userID, arg)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("confirming email of user %d: %w", userID, err),
+				"Error: can't confirm your email at this time",
+			)
+		}
+		if !confirmed {
+			handler.Bot.reply(ctx, chatID, "That code doesn't match. Check the email or send /email <address> again.")
+			return nil
+		}
+		handler.Bot.reply(ctx, chatID, "Email confirmed! Use /digest email to get your digest there.")
+		return nil
+	}
+
+	address, err := mail.ParseAddress(arg)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("invalid email %q: %w", arg, err),
+			"That doesn't look like an email address.",
+		)
+	}
+	code, err := generateEmailCode()
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("generating email code: %w", err),
+			"Error: can't set your email at this time",
+		)
+	}
+	if err := setPendingEmail(ctx, handler.DB, userID, chatID, address.Address, code); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting email of user %d: %w", userID, err),
+			"Error: can't set your email at this time",
+		)
+	}
+	body := fmt.Sprintf(
+		"Your TV Reminder confirmation code is %s.\n\nSend /email %s to the bot to confirm this address.\n",
+		code, code,
+	)
+	if err := handler.Mailer.SendMail(address.Address, "Confirm your email", body); err != nil {
+		return NewUserError(
+			fmt.Errorf("sending confirmation to user %d: %w", userID, err),
+			"I couldn't send an email to this address. Please check it and try again.",
+		)
+	}
+	handler.Bot.reply(ctx, chatID, fmt.Sprintf("I've sent a code to %s. Reply with /email <code> to confirm it.", address.Address))
+	return nil
+}
+
+func describeEmail(settings *UserSettings) string {
+	switch {
+	case settings.Email == "":
+		return "No email set."
+	case !settings.EmailVerified:
+		return fmt.Sprintf("Your email: %s (waiting for confirmation)", settings.Email)
+	default:
+		return fmt.Sprintf("Your email: %s", settings.Email)
+	}
+}
@@ -0,0 +1,127 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/store"
+)
+
+// One-off events, like award shows and live specials, come with a single episode
+// and no seasons to follow. Adding one skips the progress questions and schedules
+// its one reminder, which reads like an event's and isn't followed by a check-in.
+// eventArchiveLoop moves events to /history once they're over.
+
+// eventArchiveDelay is how long an event stays in /shows after it airs.
+const eventArchiveDelay = 12 * time.Hour
+
+// eventArchiveInterval is how often aired events are archived.
+const eventArchiveInterval = time.Hour
+
+// isOneOffEvent reports whether a show with these episodes is a one-off event.
+func isOneOffEvent(episodes []Episode) bool {
+	return len(episodes) == 1
+}
+
+func setShowOneOff(ctx context.Context, db *store.DB, showID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `UPDATE shows SET one_off = 1 WHERE id = ?`, showID)
+	return err
+}
+
+// archiveAiredEvents archives the events that aired before cutoff. It returns the
+// number of events archived.
+func archiveAiredEvents(ctx context.Context, db *store.DB, cutoff time.Time) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	cutoffStr := cutoff.UTC().Format(time.RFC3339)
+	result, err := db.ExecContext(ctx, `
+		UPDATE shows SET archived = 1
+		WHERE one_off = 1 AND archived = 0 AND deleted_at IS NULL
+		AND EXISTS (
+			SELECT 1 FROM episodes_cache e
+			WHERE e.provider = shows.provider AND e.provider_show_id = shows.provider_show_id
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM episodes_cache e
+			WHERE e.provider = shows.provider AND e.provider_show_id = shows.provider_show_id
+			AND (e.aired_at_utc IS NULL OR e.aired_at_utc > ?)
+		)
+	`, cutoffStr)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// eventArchiveLoop periodically archives the events that are over.
+func eventArchiveLoop(db *store.DB, clk clock.Clock, ctx context.Context) {
+	ticker := time.NewTicker(eventArchiveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			archived, err := archiveAiredEvents(ctx, db, clk.Now().Add(-eventArchiveDelay))
+			if err != nil {
+				log.Printf("eventArchiveLoop: archiveAiredEvents error: %v", err)
+				continue
+			}
+			if archived > 0 {
+				log.Printf("eventArchiveLoop: archived %d aired events", archived)
+			}
+		case <-ctx.Done():
+			log.Println("eventArchiveLoop: context cancelled, exiting")
+			return
+		}
+	}
+}
+
+// addOneOffEvent finishes adding an event instead of asking for progress: it
+// schedules the event's reminder, or archives it right away when it already aired.
+func (handler *Handler) addOneOffEvent(ctx context.Context, userID, chatID int64, messageID int, showID int64, name string, event Episode) error {
+	handler.Bot.clearState(userID)
+	if err := setShowOneOff(ctx, handler.DB, showID); err != nil {
+		return NewUserError(
+			fmt.Errorf("marking show %d as an event: %w", showID, err),
+			"Error adding the event, please try again later.",
+		)
+	}
+
+	var text string
+	airedAt, err := time.Parse(time.RFC3339, event.Airstamp)
+	switch {
+	case err != nil:
+		text = fmt.Sprintf("🎟 \"%s\" is a one-off event without an air date yet.", name)
+	case !airedAt.After(handler.Clock.Now()):
+		if err := setShowArchived(ctx, handler.DB, showID, true); err != nil {
+			log.Printf("addOneOffEvent: archiving show %d: %v", showID, err)
+		}
+		text = fmt.Sprintf("🎟 \"%s\" is a one-off event that already aired, so it went straight to your /history.", name)
+	default:
+		if _, err := rebuildShowReminder(ctx, handler.DB, userID, showID, chatID, updateThread(ctx, chatID), handler.Clock.Now()); err != nil {
+			return NewUserError(
+				fmt.Errorf("scheduling the reminder of event %d: %w", showID, err),
+				"The event was added, but scheduling its reminder failed.",
+			)
+		}
+		loc := time.UTC
+		if settings, err := getUserSettings(ctx, handler.DB, userID); err != nil {
+			log.Printf("addOneOffEvent: getting settings for user %d: %v", userID, err)
+		} else {
+			loc = settings.Location()
+		}
+		text = fmt.Sprintf(
+			"🎟 \"%s\" is a one-off event airing %s. I'll remind you when it starts.",
+			name, airedAt.In(loc).Format("Mon Jan 2, 15:04"),
+		)
+	}
+	handler.Bot.reply(ctx, chatID, text, ReplyOptions{EditMessageID: messageID})
+	return nil
+}
@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"testing"
+
+	"tvreminder/bot/internal/provider"
+)
+
+func TestOneOffEvent(t *testing.T) {
+	env := newSimulationEnv(t)
+	event := func(id int, name, airstamp string) provider.FixtureShow {
+		return provider.FixtureShow{
+			ShowSearchResult: provider.ShowSearchResult{ID: id, Name: name, Type: "Award Show"},
+			Episodes:         []provider.Episode{{ID: id * 100, Season: 2026, Name: name, Airstamp: airstamp}},
+		}
+	}
+	env.handler.Provider = provider.NewFixture(
+		event(7, "The Awards", "2026-03-15T23:00:00Z"),
+		event(8, "Winter Gala", "2026-02-01T19:00:00Z"),
+	)
+
+	env.command("/add awards")
+	env.press("acceptShowName:1")
+	if got, want := env.telegram.lastMessage(t).Params.Get("text"), `🎟 "The Awards" is a one-off event airing Sun Mar 15, 23:00. I'll remind you when it starts.`; got != want {
+		t.Errorf("adding the event replied %q, want %q", got, want)
+	}
+	env.command("/add gala")
+	env.press("acceptShowName:1")
+	if got, want := env.telegram.lastMessage(t).Params.Get("text"), `🎟 "Winter Gala" is a one-off event that already aired, so it went straight to your /history.`; got != want {
+		t.Errorf("adding an aired event replied %q, want %q", got, want)
+	}
+
+	env.command("/admin clock 2026-03-15T23:00:00Z")
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if got, want := env.telegram.lastMessage(t).Params.Get("text"), `🎟 "The Awards" is on today!`; got != want {
+		t.Errorf("event reminder = %q, want %q", got, want)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM followups`); got != "0" {
+		t.Errorf("%s check-ins scheduled for an event", got)
+	}
+
+	// The event stays in /shows for a while after it airs
+	if n, err := archiveAiredEvents(t.Context(), env.handler.DB, env.handler.Clock.Now().Add(-eventArchiveDelay)); err != nil || n != 0 {
+		t.Errorf("archiveAiredEvents right after airing = %d, %v, want 0", n, err)
+	}
+	env.command("/admin clock 13h")
+	if n, err := archiveAiredEvents(t.Context(), env.handler.DB, env.handler.Clock.Now().Add(-eventArchiveDelay)); err != nil || n != 1 {
+		t.Errorf("archiveAiredEvents the next morning = %d, %v, want 1", n, err)
+	}
+	if got := queryString(t, env, `SELECT group_concat(name || ' ' || archived || ' ' || one_off) FROM shows`); got != "The Awards 1 1,Winter Gala 1 1" {
+		t.Errorf("shows = %s", got)
+	}
+}
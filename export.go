@@ -0,0 +1,318 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/store"
+)
+
+// exportFormatVersion 2 added settings, reminders and per-show preferences.
+const exportFormatVersion = 2
+
+const monthlyExportCaption = "Your monthly TV Reminder backup. Keep this file to restore your shows later."
+
+const exportCaption = "Your TV Reminder data. Send this file to /import to restore it, here or on another instance of the bot."
+
+type UserExport struct {
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Settings   *ExportedSettings `json:"settings,omitempty"`
+	Shows      []ExportedShow    `json:"shows"`
+	// Reminders are informational: importing recreates them from show progress.
+	Reminders []ExportedReminder `json:"reminders,omitempty"`
+}
+
+type ExportedSettings struct {
+	Timezone             string `json:"timezone"`
+	QuietHours           string `json:"quiet_hours,omitempty"`
+	ShowSummaries        bool   `json:"show_summaries"`
+	Country              string `json:"country,omitempty"`
+	AirtimeAlerts        bool   `json:"airtime_alerts"`
+	MonthlyExportEnabled bool   `json:"monthly_export_enabled"`
+}
+
+type ExportedShow struct {
+	Name                 string     `json:"name"`
+	Provider             string     `json:"provider"`
+	ProviderShowID       string     `json:"provider_show_id"`
+	Season               *int       `json:"season,omitempty"`
+	Episode              *int       `json:"episode,omitempty"`
+	NotificationsEnabled bool       `json:"notifications_enabled"`
+	Network              string     `json:"network,omitempty"`
+	PosterURL            string     `json:"poster_url,omitempty"`
+	Pinned               bool       `json:"pinned,omitempty"`
+	ReminderMode         string     `json:"reminder_mode,omitempty"`
+	Note                 string     `json:"note,omitempty"`
+	Silent               bool       `json:"silent,omitempty"`
+	ReminderTemplate     string     `json:"reminder_template,omitempty"`
+	SortOrder            *int       `json:"sort_order,omitempty"`
+	Archived             bool       `json:"archived,omitempty"`
+	DroppedAt            *time.Time `json:"dropped_at,omitempty"`
+	DropReason           string     `json:"drop_reason,omitempty"`
+	OneOff               bool       `json:"one_off,omitempty"`
+}
+
+type ExportedReminder struct {
+	Show           string    `json:"show"`
+	Provider       string    `json:"provider"`
+	ProviderShowID string    `json:"provider_show_id"`
+	Season         int       `json:"season"`
+	Episode        int       `json:"episode"`
+	RemindAt       time.Time `json:"remind_at"`
+}
+
+func listShowsForExport(ctx context.Context, db *store.DB, userID int64) ([]ExportedShow, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			s.name, s.provider, s.provider_show_id, e.season, e.number, s.notifications_enabled,
+			s.network, s.poster_url, s.pinned, s.reminder_mode, COALESCE(s.note, ''), s.silent,
+			s.reminder_template, s.sort_order, s.archived, s.dropped_at, s.drop_reason, s.one_off
+		FROM shows s
+		LEFT JOIN episodes_cache e ON e.id = s.last_watched_episode_id
+		WHERE s.user_id = ? AND s.deleted_at IS NULL
+		ORDER BY `+showListOrder, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shows []ExportedShow
+	for rows.Next() {
+		var show ExportedShow
+		var season, episode, sortOrder sql.NullInt32
+		var droppedAt sql.NullTime
+		var notificationsEnabled, pinned, silent, archived, oneOff int
+		err := rows.Scan(
+			&show.Name, &show.Provider, &show.ProviderShowID, &season, &episode, &notificationsEnabled,
+			&show.Network, &show.PosterURL, &pinned, &show.ReminderMode, &show.Note, &silent,
+			&show.ReminderTemplate, &sortOrder, &archived, &droppedAt, &show.DropReason, &oneOff,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if season.Valid && episode.Valid {
+			s, e := int(season.Int32), int(episode.Int32)
+			show.Season, show.Episode = &s, &e
+		}
+		show.NotificationsEnabled = notificationsEnabled == 1
+		show.Pinned = pinned == 1
+		show.Silent = silent == 1
+		show.Archived = archived == 1
+		show.OneOff = oneOff == 1
+		if droppedAt.Valid {
+			show.DroppedAt = &droppedAt.Time
+		}
+		if sortOrder.Valid {
+			order := int(sortOrder.Int32)
+			show.SortOrder = &order
+		}
+		shows = append(shows, show)
+	}
+	return shows, rows.Err()
+}
+
+func listRemindersForExport(ctx context.Context, db *store.DB, userID int64) ([]ExportedReminder, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.name, s.provider, s.provider_show_id, e.season, e.number, r.remind_at
+		FROM reminders r
+		JOIN shows s ON s.id = r.show_id
+		JOIN episodes_cache e ON e.id = r.episode_id
+		WHERE r.user_id = ? AND r.status = ? AND r.deleted_at IS NULL
+		ORDER BY r.remind_at
+	`, userID, ReminderStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []ExportedReminder
+	for rows.Next() {
+		var reminder ExportedReminder
+		err := rows.Scan(
+			&reminder.Show, &reminder.Provider, &reminder.ProviderShowID,
+			&reminder.Season, &reminder.Episode, &reminder.RemindAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reminder.RemindAt = reminder.RemindAt.UTC()
+		reminders = append(reminders, reminder)
+	}
+	return reminders, rows.Err()
+}
+
+func buildUserExport(ctx context.Context, db *store.DB, userID int64, now time.Time) (*UserExport, error) {
+	settings, err := getUserSettings(ctx, db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("getting settings for export: %w", err)
+	}
+	shows, err := listShowsForExport(ctx, db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing shows for export: %w", err)
+	}
+	reminders, err := listRemindersForExport(ctx, db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing reminders for export: %w", err)
+	}
+	return &UserExport{
+		Version:    exportFormatVersion,
+		ExportedAt: now.UTC(),
+		Settings: &ExportedSettings{
+			Timezone:             settings.Timezone,
+			QuietHours:           settings.QuietHours,
+			ShowSummaries:        settings.ShowSummaries,
+			Country:              settings.Country,
+			AirtimeAlerts:        settings.AirtimeAlerts,
+			MonthlyExportEnabled: settings.MonthlyExportEnabled,
+		},
+		Shows:     shows,
+		Reminders: reminders,
+	}, nil
+}
+
+func sendUserExport(ctx context.Context, bot *Bot, db *store.DB, userID, chatID int64, caption string, now time.Time) error {
+	export, err := buildUserExport(ctx, db, userID, now)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding export: %w", err)
+	}
+	name := fmt.Sprintf("tvreminder-export-%s.json", export.ExportedAt.Format("2006-01-02"))
+	if err := bot.sendDocument(chatID, name, data, caption); err != nil {
+		return fmt.Errorf("sending export document: %w", err)
+	}
+	return updateLastExportAt(ctx, db, userID, export.ExportedAt)
+}
+
+// handleExportSendError stops exporting to chats that reject the bot, the same way
+// the reminder loop deals with them, so a blocked bot isn't retried every hour.
+func handleExportSendError(ctx context.Context, db *store.DB, userID, chatID int64, sendErr error, now time.Time) {
+	if !isChatUnreachable(sendErr) {
+		return
+	}
+	if chatID == userID {
+		if err := markUserInactive(ctx, db, userID, chatID, now); err != nil {
+			log.Printf("exportLoop: failed to mark user %d inactive: %v", userID, err)
+		}
+		return
+	}
+	if err := setMonthlyExportEnabled(ctx, db, userID, chatID, false); err != nil {
+		log.Printf("exportLoop: failed to disable exports for user %d: %v", userID, err)
+	}
+}
+
+// exportLoop sends monthly export snapshots to users who opted in, so they keep
+// a personal copy of their data even if the bot's database is lost.
+func exportLoop(bot *Bot, db *store.DB, clk clock.Clock, ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			subscribers, err := listMonthlyExportSubscribers(ctx, db)
+			if err != nil {
+				log.Printf("exportLoop: listMonthlyExportSubscribers error: %v", err)
+				continue
+			}
+			now := clk.Now()
+			for _, s := range subscribers {
+				if s.LastExportAt.Valid && s.LastExportAt.Time.AddDate(0, 1, 0).After(now) {
+					continue
+				}
+				log.Printf("exportLoop: sending monthly export user=%d chat=%d", s.UserID, s.ChatID)
+				if err := sendUserExport(ctx, bot, db, s.UserID, s.ChatID, monthlyExportCaption, now); err != nil {
+					log.Printf("exportLoop: failed to send export to user %d: %v", s.UserID, err)
+					handleExportSendError(ctx, db, s.UserID, s.ChatID, err, now)
+				}
+			}
+		case <-ctx.Done():
+			log.Println("exportLoop: context cancelled, exiting")
+			return
+		}
+	}
+}
+
+// EXPORT command
+
+func (handler *Handler) handleExportCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	userID := msg.From.ID
+	handler.Bot.sendChatAction(msg.Chat.ID, tgbotapi.ChatUploadDocument)
+	if err := sendUserExport(ctx, handler.Bot, handler.DB, userID, msg.Chat.ID, exportCaption, handler.Clock.Now()); err != nil {
+		return NewUserError(
+			fmt.Errorf("sending export to user %d: %w", userID, err),
+			"Error exporting your data, please try again later.",
+		)
+	}
+	return nil
+}
+
+// AUTOBACKUP command
+
+func (handler *Handler) handleAutoBackupCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments()))
+
+	switch arg {
+	case "on", "off":
+		enabled := arg == "on"
+		if err := setMonthlyExportEnabled(ctx, handler.DB, userID, chatID, enabled); err != nil {
+			return NewUserError(
+				fmt.Errorf("setting monthly export for user %d: %w", userID, err),
+				"Error updating backup settings, please try again later.",
+			)
+		}
+		if enabled {
+			handler.Bot.reply(ctx, chatID, "Monthly backups enabled. Here is your first one:")
+			if err := sendUserExport(ctx, handler.Bot, handler.DB, userID, chatID, monthlyExportCaption, handler.Clock.Now()); err != nil {
+				// exportLoop picks the user up again on its next tick
+				log.Printf("handleAutoBackupCommand: sending first export to user %d: %v", userID, err)
+				handleExportSendError(ctx, handler.DB, userID, chatID, err, handler.Clock.Now())
+				handler.Bot.reply(ctx, chatID, "Sending the first backup failed, I'll try again within the hour.")
+			}
+		} else {
+			handler.Bot.reply(ctx, chatID, "Monthly backups disabled.")
+		}
+		return nil
+	case "":
+		settings, err := getUserSettings(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting settings for user %d: %w", userID, err),
+				"Error reading backup settings, please try again later.",
+			)
+		}
+		status := "disabled"
+		if settings.MonthlyExportEnabled {
+			status = "enabled"
+		}
+		text := fmt.Sprintf(
+			"Monthly backups are %s. Use /autobackup on or /autobackup off to change it.", status,
+		)
+		handler.Bot.reply(ctx, chatID, text)
+		return nil
+	default:
+		return NewUserError(
+			fmt.Errorf("invalid autobackup argument: %s", arg),
+			"Usage: /autobackup on|off",
+		)
+	}
+}
@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// exportFile runs /export as userID and returns the document that was sent.
+func exportFile(t *testing.T, env *testEnv, userID int64) []byte {
+	t.Helper()
+	env.commandFrom(userID, "/export")
+	docs := env.telegram.calls("sendDocument")
+	if len(docs) == 0 {
+		t.Fatal("no export document sent")
+	}
+	return docs[len(docs)-1].Files["document"]
+}
+
+func TestExport(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	env.command("/timezone Europe/Berlin")
+
+	export, err := parseUserExport(exportFile(t, env, testUserID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Version != exportFormatVersion {
+		t.Errorf("version = %d, want %d", export.Version, exportFormatVersion)
+	}
+	if export.Settings == nil || export.Settings.Timezone != "Europe/Berlin" {
+		t.Errorf("settings = %+v, want Europe/Berlin time zone", export.Settings)
+	}
+	if len(export.Shows) != 1 || *export.Shows[0].Season != 2 || *export.Shows[0].Episode != 2 {
+		t.Fatalf("shows = %+v, want Night Shift at S02E02", export.Shows)
+	}
+	if len(export.Reminders) != 1 || export.Reminders[0].Episode != 3 {
+		t.Errorf("reminders = %+v, want one for S02E03", export.Reminders)
+	}
+}
+
+func TestImport(t *testing.T) {
+	const newUserID int64 = 2002
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	env.command("/timezone Europe/Berlin")
+	data := exportFile(t, env, testUserID)
+
+	env.commandFrom(newUserID, "/import")
+	env.document(newUserID, "export.json", data)
+
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "Imported 1 show. See /shows." {
+		t.Errorf("text = %q", got)
+	}
+	export, err := parseUserExport(exportFile(t, env, newUserID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var original UserExport
+	if err := json.Unmarshal(data, &original); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mustJSON(t, export.Shows), mustJSON(t, original.Shows); got != want {
+		t.Errorf("imported shows = %s, want %s", got, want)
+	}
+	if got, want := mustJSON(t, export.Settings), mustJSON(t, original.Settings); got != want {
+		t.Errorf("imported settings = %s, want %s", got, want)
+	}
+	if len(export.Reminders) != 1 {
+		t.Errorf("imported user has %d reminders, want 1", len(export.Reminders))
+	}
+}
+
+func TestImportRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "not json", data: "hello"},
+		{name: "future version", data: `{"version": 99, "shows": []}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, testShows()...)
+			env.command("/import")
+			env.document(testUserID, "export.json", []byte(tt.data))
+
+			if got := env.telegram.lastMessage(t).Params.Get("text"); got != "This doesn't look like a TV Reminder export." {
+				t.Errorf("text = %q", got)
+			}
+		})
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// Shows are stored under their provider's ID. Their IDs on IMDb, TheTVDB and TMDB
+// are kept next to them in show_external_ids, saved when a show is added and filled
+// in by syncLoop for older shows. Those outlive a switch of providers, link the show
+// on IMDb and tell the same show from another provider's copy of it.
+
+// storeExternalIDs saves a show's IDs on other services, replacing what was known.
+func storeExternalIDs(ctx context.Context, db Execer, providerName, providerShowID string, ids ExternalIDs) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO show_external_ids (provider, provider_show_id, tvdb, imdb, tmdb) VALUES (?, ?, ?, ?, ?)
+	`, providerName, providerShowID, ids.TVDB, ids.IMDB, ids.TMDB)
+	return err
+}
+
+// findTrackedDuplicate returns the name of a show the user tracks under another
+// provider or ID that shares an external ID with ids, "" when there's none.
+func findTrackedDuplicate(ctx context.Context, db Querier, userID int64, providerName, providerShowID string, ids ExternalIDs) (string, error) {
+	if ids == (ExternalIDs{}) {
+		return "", nil
+	}
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var name string
+	err := db.QueryRowContext(ctx, `
+		SELECT s.name FROM shows s
+		JOIN show_external_ids x ON x.provider = s.provider AND x.provider_show_id = s.provider_show_id
+		WHERE s.user_id = ? AND s.deleted_at IS NULL
+		AND NOT (s.provider = ? AND s.provider_show_id = ?)
+		AND ((x.tvdb != 0 AND x.tvdb = ?) OR (x.imdb != '' AND x.imdb = ?) OR (x.tmdb != 0 AND x.tmdb = ?))
+		ORDER BY s.id
+		LIMIT 1
+	`, userID, providerName, providerShowID, ids.TVDB, ids.IMDB, ids.TMDB).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return name, err
+}
+
+// imdbShowURL returns the show's IMDb page.
+func imdbShowURL(imdbID string) string {
+	return fmt.Sprintf("https://www.imdb.com/title/%s/", url.PathEscape(imdbID))
+}
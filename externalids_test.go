@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExternalIDsDeduplicateAcrossProviders(t *testing.T) {
+	shows := testShows()
+	shows[0].Externals = &Externals{TheTVDB: 555, IMDB: "tt0000001"}
+	env := newTestEnv(t, shows...)
+	// The same show, added back when the bot ran on TheTVDB
+	env.handler.DB.Exec(`INSERT INTO shows (user_id, name, provider, provider_show_id) VALUES (?, 'Night Shift (2014)', 'thetvdb', '555')`, testUserID)
+	env.handler.DB.Exec(`INSERT INTO show_external_ids (provider, provider_show_id, tvdb) VALUES ('thetvdb', '555', 555)`)
+
+	env.command("/search night")
+	env.press("searchInfo:1")
+	card := env.telegram.lastMessage(t)
+	if text := card.Params.Get("text"); !strings.Contains(text, `IMDb: <a href="https://www.imdb.com/title/tt0000001/">tt0000001</a>`) {
+		t.Errorf("card = %q, want an IMDb link", text)
+	}
+	if got := card.labels(t); !slices.Contains(got, "✅ Already on your list") {
+		t.Errorf("card of a show tracked under another provider = %v", got)
+	}
+
+	env.command("/add night")
+	env.press("acceptShowName:1")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != `You already track this show as "Night Shift (2014)".` {
+		t.Errorf("adding a duplicate replied %q", got)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM shows`); got != "1" {
+		t.Fatalf("%s shows after adding a duplicate, want 1", got)
+	}
+
+	// Once the old copy is gone the show is added, its IDs saved right away
+	env.handler.DB.Exec(`UPDATE shows SET deleted_at = datetime('now')`)
+	env.command("/add night")
+	env.press("acceptShowName:1")
+	ids := `SELECT tvdb || ' ' || imdb FROM show_external_ids WHERE provider = 'tvmaze' AND provider_show_id = '1'`
+	if got := queryString(t, env, ids); got != "555 tt0000001" {
+		t.Errorf("external IDs after adding = %q, want 555 tt0000001", got)
+	}
+}
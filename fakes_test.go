@@ -0,0 +1,572 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/provider"
+	"tvreminder/bot/internal/store"
+	"tvreminder/bot/internal/telegram"
+)
+
+const testUserID int64 = 1001
+
+// sentRequest is a Bot API call received by fakeTelegram.
+type sentRequest struct {
+	Method string
+	Params url.Values
+	// Files holds the contents of uploaded files by form field.
+	Files  map[string][]byte
+	Failed bool
+	// bot resolves the callback data in keyboard.
+	bot *Bot
+}
+
+// fakeTelegram is an httptest server speaking enough of the Bot API for the bot.
+// It records every call and can be told to reject messages to specific chats.
+type fakeTelegram struct {
+	server *httptest.Server
+
+	mu           sync.Mutex
+	requests     []sentRequest
+	chatErrors   map[int64]tgbotapi.APIResponse
+	methodErrors map[string]tgbotapi.APIResponse
+	nextID       int
+	// files are served for download by file ID.
+	files map[string][]byte
+	// memberStatuses are the getChatMember statuses by user ID, "member" if unset.
+	memberStatuses map[int64]string
+	// bot is the bot talking to the server, see sentRequest.keyboard.
+	bot *Bot
+}
+
+func newFakeTelegram(t *testing.T) *fakeTelegram {
+	t.Helper()
+	f := &fakeTelegram{
+		chatErrors:     make(map[int64]tgbotapi.APIResponse),
+		methodErrors:   make(map[string]tgbotapi.APIResponse),
+		files:          make(map[string][]byte),
+		memberStatuses: make(map[int64]string),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// endpoint is the API endpoint format for tgbotapi.NewBotAPIWithAPIEndpoint.
+func (f *fakeTelegram) endpoint() string {
+	return f.server.URL + "/bot%s/%s"
+}
+
+// fileEndpoint is the file download URL format for Bot.FileEndpoint.
+func (f *fakeTelegram) fileEndpoint() string {
+	return f.server.URL + "/file/bot%s/%s"
+}
+
+// addFile makes data downloadable as if a user had uploaded it with fileID.
+func (f *fakeTelegram) addFile(fileID string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[fileID] = data
+}
+
+func (f *fakeTelegram) serve(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if strings.HasPrefix(r.URL.Path, "/file/") {
+		f.mu.Lock()
+		data, ok := f.files[method]
+		f.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+		return
+	}
+	if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	files := make(map[string][]byte)
+	if r.MultipartForm != nil {
+		for field, headers := range r.MultipartForm.File {
+			file, err := headers[0].Open()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			files[field], _ = io.ReadAll(file)
+			file.Close()
+		}
+	}
+
+	f.mu.Lock()
+	chatID, _ := strconv.ParseInt(r.Form.Get("chat_id"), 10, 64)
+	failure, fail := f.chatErrors[chatID]
+	if !fail {
+		failure, fail = f.methodErrors[method]
+	}
+	f.requests = append(f.requests, sentRequest{Method: method, Params: r.Form, Files: files, Failed: fail, bot: f.bot})
+	f.nextID++
+	messageID := f.nextID
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if fail {
+		json.NewEncoder(w).Encode(failure)
+		return
+	}
+
+	var result any
+	switch method {
+	case "getMe":
+		result = tgbotapi.User{ID: 1, IsBot: true, UserName: "test_bot"}
+	case "answerCallbackQuery", "setMyCommands":
+		result = true
+	case "getChatMember":
+		userID, _ := strconv.ParseInt(r.Form.Get("user_id"), 10, 64)
+		f.mu.Lock()
+		status := f.memberStatuses[userID]
+		f.mu.Unlock()
+		if status == "" {
+			status = "member"
+		}
+		result = tgbotapi.ChatMember{User: &tgbotapi.User{ID: userID}, Status: status}
+	case "getFile":
+		fileID := r.Form.Get("file_id")
+		result = tgbotapi.File{FileID: fileID, FilePath: "documents/" + fileID}
+	default:
+		result = tgbotapi.Message{
+			MessageID: messageID,
+			Chat:      &tgbotapi.Chat{ID: chatID},
+			Date:      int(time.Now().Unix()),
+			Text:      r.Form.Get("text"),
+		}
+	}
+	raw, _ := json.Marshal(result)
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: raw})
+}
+
+// setMemberStatus sets the status getChatMember returns for the user in any chat.
+func (f *fakeTelegram) setMemberStatus(userID int64, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.memberStatuses[userID] = status
+}
+
+// failChat makes every call addressed to chatID fail with the given API error.
+func (f *fakeTelegram) failChat(chatID int64, code int, description string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.chatErrors[chatID] = tgbotapi.APIResponse{Ok: false, ErrorCode: code, Description: description}
+}
+
+// unfailChat lets calls addressed to chatID through again.
+func (f *fakeTelegram) unfailChat(chatID int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.chatErrors, chatID)
+}
+
+// upgradeChat makes calls addressed to chatID fail the way they do after the group
+// was upgraded to the supergroup newID.
+func (f *fakeTelegram) upgradeChat(chatID, newID int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.chatErrors[chatID] = tgbotapi.APIResponse{
+		Ok: false, ErrorCode: 400, Description: "Bad Request: group chat was upgraded to a supergroup chat",
+		Parameters: &tgbotapi.ResponseParameters{MigrateToChatID: newID},
+	}
+}
+
+// failMethod makes every call to the Bot API method fail with the given API error.
+func (f *fakeTelegram) failMethod(method string, code int, description string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.methodErrors[method] = tgbotapi.APIResponse{Ok: false, ErrorCode: code, Description: description}
+}
+
+// calls returns the delivered calls to the Bot API method.
+func (f *fakeTelegram) calls(method string) []sentRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []sentRequest
+	for _, req := range f.requests {
+		if !req.Failed && req.Method == method {
+			out = append(out, req)
+		}
+	}
+	return out
+}
+
+// messages returns the sendMessage and editMessageText calls delivered so far.
+func (f *fakeTelegram) messages() []sentRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []sentRequest
+	for _, req := range f.requests {
+		if !req.Failed && (req.Method == "sendMessage" || req.Method == "editMessageText") {
+			out = append(out, req)
+		}
+	}
+	return out
+}
+
+// lastAlert returns the text of the last callback answer shown as an alert.
+func (f *fakeTelegram) lastAlert(t *testing.T) string {
+	t.Helper()
+	answers := f.calls("answerCallbackQuery")
+	for i := len(answers) - 1; i >= 0; i-- {
+		if answers[i].Params.Get("show_alert") == "true" {
+			return answers[i].Params.Get("text")
+		}
+	}
+	t.Fatal("no alert shown")
+	return ""
+}
+
+func (f *fakeTelegram) lastMessage(t *testing.T) sentRequest {
+	t.Helper()
+	messages := f.messages()
+	if len(messages) == 0 {
+		t.Fatal("no messages sent")
+	}
+	return messages[len(messages)-1]
+}
+
+// keyboard decodes the inline keyboard of a sent message into its verified,
+// unsigned callback data.
+func (req sentRequest) keyboard(t *testing.T) []string {
+	t.Helper()
+	raw := req.Params.Get("reply_markup")
+	if raw == "" {
+		return nil
+	}
+	var markup tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(raw), &markup); err != nil {
+		t.Fatalf("decoding reply_markup %q: %v", raw, err)
+	}
+	chatID, err := strconv.ParseInt(req.Params.Get("chat_id"), 10, 64)
+	if err != nil {
+		t.Fatalf("decoding chat_id %q: %v", req.Params.Get("chat_id"), err)
+	}
+	var data []string
+	for _, row := range markup.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData == nil {
+				continue
+			}
+			unsigned, err := req.bot.resolveCallback(context.Background(), *button.CallbackData, chatID, time.Now())
+			if err != nil {
+				t.Fatalf("verifying callback %q: %v", *button.CallbackData, err)
+			}
+			data = append(data, unsigned)
+		}
+	}
+	return data
+}
+
+// labels decodes the texts of a sent message's inline keyboard buttons.
+func (req sentRequest) labels(t *testing.T) []string {
+	t.Helper()
+	raw := req.Params.Get("reply_markup")
+	if raw == "" {
+		return nil
+	}
+	var markup tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(raw), &markup); err != nil {
+		t.Fatalf("decoding reply_markup %q: %v", raw, err)
+	}
+	var labels []string
+	for _, row := range markup.InlineKeyboard {
+		for _, button := range row {
+			labels = append(labels, button.Text)
+		}
+	}
+	return labels
+}
+
+// fakeShow is a show served by fakeTVMaze.
+type fakeShow struct {
+	ShowSearchResult
+	Episodes []Episode
+}
+
+// fakeTVMaze serves the TVmaze search and episode endpoints from fixtures.
+type fakeTVMaze struct {
+	server *httptest.Server
+
+	mu    sync.Mutex
+	shows []fakeShow
+	// down makes every request fail like an outage would.
+	down bool
+	// updated is when shows were last changed with updateShow, for /updates/shows.
+	updated map[int]time.Time
+}
+
+func (f *fakeTVMaze) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+// updateShow changes a served show in place, e.g. to reschedule an episode.
+func (f *fakeTVMaze) updateShow(id int, update func(show *fakeShow)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.updated == nil {
+		f.updated = make(map[int]time.Time)
+	}
+	for i := range f.shows {
+		if f.shows[i].ID == id {
+			update(&f.shows[i])
+			// Changing the ID reports both, like a merge would
+			f.updated[id] = time.Now()
+			f.updated[f.shows[i].ID] = time.Now()
+		}
+	}
+}
+
+func newFakeTVMaze(t *testing.T, shows ...fakeShow) *fakeTVMaze {
+	t.Helper()
+	f := &fakeTVMaze{shows: shows}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /search/shows", func(w http.ResponseWriter, r *http.Request) {
+		query := strings.ToLower(r.URL.Query().Get("q"))
+		type hit struct {
+			Score float64          `json:"score"`
+			Show  ShowSearchResult `json:"show"`
+		}
+		hits := []hit{}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, show := range f.shows {
+			if strings.Contains(strings.ToLower(show.Name), query) {
+				hits = append(hits, hit{Score: 1, Show: show.ShowSearchResult})
+			}
+		}
+		json.NewEncoder(w).Encode(hits)
+	})
+	mux.HandleFunc("GET /shows/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(r.PathValue("id"))
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, show := range f.shows {
+			if show.ID == id {
+				json.NewEncoder(w).Encode(show.ShowSearchResult)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("GET /lookup/shows", func(w http.ResponseWriter, r *http.Request) {
+		tvdb, _ := strconv.Atoi(r.URL.Query().Get("thetvdb"))
+		imdb := r.URL.Query().Get("imdb")
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, show := range f.shows {
+			if ext := show.Externals; ext != nil && ((tvdb != 0 && ext.TheTVDB == tvdb) || (imdb != "" && ext.IMDB == imdb)) {
+				http.Redirect(w, r, fmt.Sprintf("/shows/%d", show.ID), http.StatusMovedPermanently)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("GET /shows/{id}/episodes", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(r.PathValue("id"))
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, show := range f.shows {
+			if show.ID == id {
+				json.NewEncoder(w).Encode(show.Episodes)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("GET /updates/shows", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		updated := map[string]int64{}
+		for id, at := range f.updated {
+			updated[strconv.Itoa(id)] = at.Unix()
+		}
+		json.NewEncoder(w).Encode(updated)
+	})
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		down := f.down
+		f.mu.Unlock()
+		if down {
+			http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// makeEpisodes builds a season of episodes airing a week apart, with the first
+// one airing at firstAired.
+func makeEpisodes(showID, season, count int, firstAired time.Time) []Episode {
+	var episodes []Episode
+	for i := range count {
+		aired := firstAired.AddDate(0, 0, 7*i).UTC()
+		episodes = append(episodes, Episode{
+			ID:       showID*1000 + season*100 + i + 1,
+			Season:   season,
+			Number:   i + 1,
+			Name:     fmt.Sprintf("Episode %d.%d", season, i+1),
+			Airdate:  aired.Format("2006-01-02"),
+			Airtime:  aired.Format("15:04"),
+			Airstamp: aired.Format(time.RFC3339),
+			Summary:  fmt.Sprintf("<p>Things happen in %d.%d</p>", season, i+1),
+		})
+	}
+	return episodes
+}
+
+// newTestDB opens a fresh, fully migrated database in the test's temp dir.
+func newTestDB(t *testing.T) *store.DB {
+	t.Helper()
+	db, err := store.Open(t.Context(), store.Config{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("openDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type testEnv struct {
+	handler  *Handler
+	telegram *fakeTelegram
+	tvmaze   *fakeTVMaze
+	// ctx is what updates are handled with, e.g. carrying a forum topic.
+	ctx context.Context
+}
+
+// newTestEnv wires a Handler to a fresh database and fake Telegram and TVmaze servers.
+func newTestEnv(t *testing.T, shows ...fakeShow) *testEnv {
+	t.Helper()
+	fake := newFakeTelegram(t)
+	tvmaze := newFakeTVMaze(t, shows...)
+
+	botApi, err := tgbotapi.NewBotAPIWithAPIEndpoint("test-token", fake.endpoint())
+	if err != nil {
+		t.Fatalf("creating bot: %v", err)
+	}
+	db := newTestDB(t)
+	bot := &Bot{
+		BotApi:       botApi,
+		Username:     botApi.Self.UserName,
+		Token:        "test-token",
+		FileEndpoint: fake.fileEndpoint(),
+		Callbacks:    NewCallbackStore(db),
+		Signer:       telegram.NewSigner(nil),
+		UserContexts: make(map[int64]*UserContext),
+	}
+	fake.bot = bot
+	return &testEnv{
+		handler:  &Handler{Bot: bot, DB: db, Provider: provider.NewTVMaze(tvmaze.server.URL), Clock: clock.System{}},
+		telegram: fake,
+		tvmaze:   tvmaze,
+		ctx:      context.Background(),
+	}
+}
+
+// command delivers a command message from the test user.
+func (env *testEnv) command(text string) {
+	env.commandFrom(testUserID, text)
+}
+
+// commandFrom delivers a command message from userID in their private chat.
+func (env *testEnv) commandFrom(userID int64, text string) {
+	command, _, _ := strings.Cut(text, " ")
+	env.handler.handleUpdate(env.ctx, tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: userID},
+		Chat:      &tgbotapi.Chat{ID: userID},
+		Text:      text,
+		Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+	}})
+	env.handler.addJobs.wait()
+}
+
+// document delivers a message from userID with an uploaded file, which the fake
+// Telegram serves for download.
+func (env *testEnv) document(userID int64, name string, data []byte) {
+	fileID := fmt.Sprintf("file-%d-%s", userID, name)
+	env.telegram.addFile(fileID, data)
+	env.handler.handleUpdate(env.ctx, tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: userID},
+		Chat:      &tgbotapi.Chat{ID: userID},
+		Document:  &tgbotapi.Document{FileID: fileID, FileName: name, FileSize: len(data)},
+	}})
+	// Imports run as jobs
+	env.handler.runDueJobs(context.Background())
+}
+
+// text delivers a plain text message from the test user.
+func (env *testEnv) text(text string) {
+	env.handler.handleUpdate(env.ctx, tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: testUserID},
+		Chat:      &tgbotapi.Chat{ID: testUserID},
+		Text:      text,
+	}})
+}
+
+// press delivers a callback query as if the test user pressed an inline button
+// with the given unsigned callback data.
+func (env *testEnv) press(data string) {
+	env.pressRaw(env.handler.Bot.Signer.SignCallback(data, testUserID, time.Now().Add(telegram.CallbackTTL)))
+}
+
+func (env *testEnv) pressRaw(data string) {
+	env.handler.handleUpdate(env.ctx, tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "cb",
+		From:    &tgbotapi.User{ID: testUserID},
+		Message: &tgbotapi.Message{MessageID: 42, Chat: &tgbotapi.Chat{ID: testUserID}},
+		Data:    data,
+	}})
+	env.handler.addJobs.wait()
+}
+
+// groupCommand delivers a command message from user in the group chat chatID.
+func (env *testEnv) groupCommand(chatID int64, user *tgbotapi.User, text string) {
+	command, _, _ := strings.Cut(text, " ")
+	env.handler.handleUpdate(env.ctx, tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 1,
+		From:      user,
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "group"},
+		Text:      text,
+		Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+	}})
+}
+
+// groupPress delivers a callback query as if user pressed an inline button in the
+// group chat chatID.
+func (env *testEnv) groupPress(chatID int64, user *tgbotapi.User, data string) {
+	env.handler.handleUpdate(env.ctx, tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "cb",
+		From:    user,
+		Message: &tgbotapi.Message{MessageID: 42, Chat: &tgbotapi.Chat{ID: chatID, Type: "group"}},
+		Data:    env.handler.Bot.Signer.SignCallback(data, chatID, time.Now().Add(telegram.CallbackTTL)),
+	}})
+}
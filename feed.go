@@ -0,0 +1,204 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/store"
+)
+
+// feedPast and feedAhead bound the episodes in a feed: recently aired ones and the
+// ones coming up.
+const (
+	feedPast  = 7 * 24 * time.Hour
+	feedAhead = 30 * 24 * time.Hour
+)
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Description string  `xml:"description"`
+	PubDate     string  `xml:"pubDate"`
+	GUID        rssGUID `xml:"guid"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink string `xml:"isPermaLink,attr"`
+}
+
+func setFeedToken(ctx context.Context, db *store.DB, userID, chatID int64, token string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET feed_token = ? WHERE user_id = ?`, token, userID)
+	return err
+}
+
+func getFeedToken(ctx context.Context, db *store.DB, userID int64) (string, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var token string
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(feed_token, '') FROM user_settings WHERE user_id = ?
+	`, userID).Scan(&token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return token, err
+}
+
+// getFeedUser returns the user a feed token belongs to, sql.ErrNoRows for unknown ones.
+func getFeedUser(ctx context.Context, db *store.DB, token string) (*UserSettings, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var userID int64
+	err := db.QueryRowContext(ctx, `SELECT user_id FROM user_settings WHERE feed_token = ?`, token).Scan(&userID)
+	if err != nil {
+		return nil, err
+	}
+	return getUserSettings(ctx, db, userID)
+}
+
+// buildFeed renders the user's episodes airing between from and to as RSS, newest
+// first as feed readers expect.
+func buildFeed(episodes []UpcomingEpisode, botUsername string, now time.Time, loc *time.Location) rssFeed {
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         "TV Reminder: your episodes",
+			Link:          "https://t.me/" + botUsername,
+			Description:   "Upcoming and recently aired episodes of the shows you track",
+			LastBuildDate: now.UTC().Format(time.RFC1123Z),
+		},
+	}
+	for i := len(episodes) - 1; i >= 0; i-- {
+		e := episodes[i]
+		verb := "airs"
+		if !e.AiredAt.After(now) {
+			verb = "aired"
+		}
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title: fmt.Sprintf("%s S%02dE%02d \"%s\"", e.ShowName, e.Season, e.Number, e.Title),
+			Description: fmt.Sprintf(
+				"%s S%02dE%02d %s %s", e.ShowName, e.Season, e.Number, verb,
+				e.AiredAt.In(loc).Format("Mon Jan 2, 15:04 MST"),
+			),
+			PubDate: e.AiredAt.UTC().Format(time.RFC1123Z),
+			GUID: rssGUID{
+				Value:       fmt.Sprintf("%s-S%02dE%02d", e.ShowName, e.Season, e.Number),
+				IsPermaLink: "false",
+			},
+		})
+	}
+	return feed
+}
+
+// feedHandler serves the RSS feed of the user whose token is in the URL, with the
+// episodes around the time clk tells.
+func feedHandler(db *store.DB, botUsername string, clk clock.Clock) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.PathValue("token")
+		settings, err := getFeedUser(r.Context(), db, token)
+		if errors.Is(err, sql.ErrNoRows) || token == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Printf("feedHandler: getting feed user: %v", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		now := clk.Now()
+		episodes, err := listUpcomingEpisodes(r.Context(), db, settings.UserID, now.Add(-feedPast), now.Add(feedAhead))
+		if err != nil {
+			log.Printf("feedHandler: listing episodes for user %d: %v", settings.UserID, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		w.Write([]byte(xml.Header))
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		if err := enc.Encode(buildFeed(episodes, botUsername, now, settings.Location())); err != nil {
+			log.Printf("feedHandler: writing feed for user %d: %v", settings.UserID, err)
+		}
+	})
+}
+
+func feedURL(baseURL, token string) string {
+	return strings.TrimRight(baseURL, "/") + "/feed/" + token
+}
+
+// FEED command
+
+func (handler *Handler) handleFeedCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+
+	if handler.FeedBaseURL == "" {
+		handler.Bot.reply(ctx, chatID, "Feeds aren't available on this bot.")
+		return nil
+	}
+
+	reset := strings.TrimSpace(msg.CommandArguments()) == "reset"
+	token, err := getFeedToken(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting feed token of user %d: %w", userID, err),
+			"Error: can't get your feed at this time",
+		)
+	}
+	if token == "" || reset {
+		if token, err = randomToken(); err == nil {
+			err = setFeedToken(ctx, handler.DB, userID, chatID, token)
+		}
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("setting feed token of user %d: %w", userID, err),
+				"Error: can't create your feed at this time",
+			)
+		}
+	}
+
+	text := fmt.Sprintf(
+		"Your RSS feed of upcoming and recently aired episodes:\n%s\n\n"+
+			"Anyone with this link can see your shows. Use /feed reset to replace it.",
+		feedURL(handler.FeedBaseURL, token),
+	)
+	if reset {
+		text = "Your old feed link no longer works. " + text
+	}
+	handler.Bot.reply(ctx, chatID, text)
+	return nil
+}
@@ -0,0 +1,52 @@
+package bot
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFeed(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.handler.FeedBaseURL = "https://bot.example.com/"
+	server := httptest.NewServer(newHTTPHandler(env.handler.DB, "test_bot", "test-token", env.handler.Bot.Signer, env.handler.Clock))
+	t.Cleanup(server.Close)
+	trackShow(t, env, "2")
+
+	env.command("/feed")
+	text := env.telegram.lastMessage(t).Params.Get("text")
+	token := queryString(t, env, `SELECT feed_token FROM user_settings`)
+	if !strings.Contains(text, "https://bot.example.com/feed/"+token) {
+		t.Fatalf("/feed = %q, want the link with token %s", text, token)
+	}
+
+	resp, err := http.Get(server.URL + "/feed/" + token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var feed rssFeed
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, item := range feed.Channel.Items {
+		titles = append(titles, item.Title)
+	}
+	want := []string{`Night Shift S02E03 "Episode 2.3"`, `Night Shift S02E02 "Episode 2.2"`}
+	if strings.Join(titles, "|") != strings.Join(want, "|") {
+		t.Errorf("feed items = %q, want %q", titles, want)
+	}
+
+	env.command("/feed reset")
+	resp, err = http.Get(server.URL + "/feed/" + token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("old feed link status = %d, want 404", resp.StatusCode)
+	}
+}
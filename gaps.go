@@ -0,0 +1,266 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Progress is a single "watched up to" episode, so jumping it into a later season
+// counts the rest of the earlier seasons as watched. When that happens the user is
+// warned about the gap and can keep those episodes in their backlog instead: they
+// are stored as skipped, counted as waiting and shown unwatched in the episode
+// browser until the user marks them there, catches up or moves progress back
+// before them.
+
+// gapEpisode is an episode progress jumped over.
+type gapEpisode struct {
+	ID      int64
+	Season  int
+	Number  int
+	AiredAt time.Time
+}
+
+// findProgressGap returns the regular episodes progress skipped moving from
+// previousID into a later season with episodeID: the ones after previousID in the
+// seasons before episodeID's. There's no gap without previous progress.
+func findProgressGap(ctx context.Context, db *store.DB, previousID, episodeID int64) ([]gapEpisode, error) {
+	if previousID == 0 || episodeID == 0 {
+		return nil, nil
+	}
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT g.id, g.season, g.number, COALESCE(g.aired_at_utc, '')
+		FROM episodes_cache g
+		JOIN episodes_cache p ON p.id = ?
+		JOIN episodes_cache n ON n.id = ?
+		WHERE g.provider = n.provider AND g.provider_show_id = n.provider_show_id
+			AND g.season > 0 AND g.season < n.season
+			AND (g.season > p.season OR (g.season = p.season AND g.number > p.number))
+		ORDER BY g.season, g.number
+	`, previousID, episodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var gap []gapEpisode
+	for rows.Next() {
+		var episode gapEpisode
+		var airedAt string
+		if err := rows.Scan(&episode.ID, &episode.Season, &episode.Number, &airedAt); err != nil {
+			return nil, err
+		}
+		episode.AiredAt, _ = time.Parse(time.RFC3339, airedAt)
+		gap = append(gap, episode)
+	}
+	return gap, rows.Err()
+}
+
+// skipEpisodes keeps the episodes in the show's backlog even though its progress
+// is past them, and takes them out of the watch log.
+func skipEpisodes(ctx context.Context, db *store.DB, showID int64, episodes []gapEpisode) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, episode := range episodes {
+		_, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO skipped_episodes (show_id, episode_id) VALUES (?, ?)
+		`, showID, episode.ID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM watch_log WHERE show_id = ? AND episode_id = ?`, showID, episode.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// listSkippedEpisodes maps the show's skipped episodes to their season.
+func listSkippedEpisodes(ctx context.Context, db *store.DB, showID int64) (map[int64]int, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT e.id, e.season FROM skipped_episodes k JOIN episodes_cache e ON e.id = k.episode_id
+		WHERE k.show_id = ?
+	`, showID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skipped := make(map[int64]int)
+	for rows.Next() {
+		var episodeID int64
+		var season int
+		if err := rows.Scan(&episodeID, &season); err != nil {
+			return nil, err
+		}
+		skipped[episodeID] = season
+	}
+	return skipped, rows.Err()
+}
+
+// unskipEpisode marks a skipped episode watched now, reporting whether it was skipped.
+func unskipEpisode(ctx context.Context, db *store.DB, showID, episodeID int64, now time.Time) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, `
+		DELETE FROM skipped_episodes WHERE show_id = ? AND episode_id = ?
+	`, showID, episodeID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO watch_log (show_id, episode_id, user_id, watched_at)
+		SELECT id, ?, user_id, ? FROM shows WHERE id = ?
+	`, episodeID, now.UTC().Format(time.RFC3339), showID)
+	return err == nil, err
+}
+
+// dropSkippedEpisodes forgets the skipped episodes progress no longer covers, or all
+// of them when it's the show's whole list, like after catching up.
+func dropSkippedEpisodes(ctx context.Context, db *store.DB, showID int64, all bool) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if all {
+		_, err := db.ExecContext(ctx, `DELETE FROM skipped_episodes WHERE show_id = ?`, showID)
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		DELETE FROM skipped_episodes
+		WHERE show_id = ? AND episode_id NOT IN (
+			SELECT e.id FROM shows s
+			JOIN episodes_cache w ON w.id = s.last_watched_episode_id
+			JOIN episodes_cache e ON e.provider = w.provider AND e.provider_show_id = w.provider_show_id
+			WHERE s.id = ? AND (e.season < w.season OR (e.season = w.season AND e.number < w.number))
+		)
+	`, showID, showID)
+	return err
+}
+
+// formatGapWarning describes the episodes progress jumped over.
+func formatGapWarning(showName string, gap []gapEpisode, now time.Time) string {
+	first, last := gap[0], gap[len(gap)-1]
+	span := fmt.Sprintf("S%02dE%02d", first.Season, first.Number)
+	if len(gap) > 1 {
+		span += fmt.Sprintf(" to S%02dE%02d", last.Season, last.Number)
+	}
+	unaired := 0
+	for _, episode := range gap {
+		if episode.AiredAt.IsZero() || episode.AiredAt.After(now) {
+			unaired++
+		}
+	}
+	text := fmt.Sprintf("Heads up: that skips %s of \"%s\" (%s)", pluralize(len(gap), "episode"), showName, span)
+	switch {
+	case unaired == len(gap) && unaired == 1:
+		text += ", which hasn't aired yet"
+	case unaired == len(gap):
+		text += ", which haven't aired yet"
+	case unaired > 0:
+		text += fmt.Sprintf(", %d of them not aired yet", unaired)
+	}
+	return text + ". Did you watch them?"
+}
+
+// warnAboutGap offers to keep the episodes progress jumped over in the backlog when
+// moving it from previousID to episodeID left a gap.
+func (handler *Handler) warnAboutGap(ctx context.Context, chatID, showID int64, showName string, previousID, episodeID int64) {
+	gap, err := findProgressGap(ctx, handler.DB, previousID, episodeID)
+	if err != nil {
+		log.Printf("warnAboutGap: finding gap in show %d: %v", showID, err)
+		return
+	}
+	if len(gap) == 0 {
+		return
+	}
+	keyboard := makeKeyboardMarkup([][][]string{
+		{{"✅ I watched them", "gapWatched:"}},
+		{{"📥 Keep them in my backlog", fmt.Sprintf("gapKeep:%d:%d:%d", showID, previousID, episodeID)}},
+	})
+	handler.Bot.reply(ctx, chatID, formatGapWarning(showName, gap, handler.Clock.Now()), ReplyOptions{ReplyMarkup: keyboard})
+}
+
+// handleGapWatchedCallback confirms the skipped episodes count as watched, which
+// they already do.
+func (handler *Handler) handleGapWatchedCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) error {
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, "Got it, they count as watched.", ReplyOptions{EditMessageID: cb.Message.MessageID})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleGapKeepCallback keeps the episodes of a gap in the backlog, as long as the
+// progress is still where the gap left it.
+func (handler *Handler) handleGapKeepCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	parts := strings.Split(callbackParam, ":")
+	var ids [3]int64
+	for i := range ids {
+		var err error
+		if len(parts) != len(ids) {
+			err = fmt.Errorf("%d parts", len(parts))
+		} else {
+			ids[i], err = strconv.ParseInt(parts[i], 10, 64)
+		}
+		if err != nil {
+			log.Printf("handleGapKeepCallback: invalid callback parameter %s: %v", callbackParam, err)
+			return nil
+		}
+	}
+	showID, previousID, episodeID := ids[0], ids[1], ids[2]
+	userID := cb.From.ID
+
+	show, err := getShowByID(ctx, handler.DB, showID)
+	if err != nil || show.UserID != userID {
+		return NewUserError(
+			fmt.Errorf("getting show %d for user %d: %v", showID, userID, err),
+			"Show not found. It may have been removed.",
+		)
+	}
+	if show.LastWatchedEpisodeID == nil || *show.LastWatchedEpisodeID != strconv.FormatInt(episodeID, 10) {
+		handler.Bot.reply(ctx, cb.Message.Chat.ID, "Your progress has changed since, so I left it as it is.",
+			ReplyOptions{EditMessageID: cb.Message.MessageID})
+		handler.Bot.answerCallbackQuery(cb.ID)
+		return nil
+	}
+	gap, err := findProgressGap(ctx, handler.DB, previousID, episodeID)
+	if err == nil {
+		err = skipEpisodes(ctx, handler.DB, showID, gap)
+	}
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("keeping the gap of show %d in the backlog: %w", showID, err),
+			"Error updating your backlog",
+		)
+	}
+
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, fmt.Sprintf(
+		"Kept %s of \"%s\" in your /backlog. Mark them in the episode browser once you've watched them.",
+		pluralize(len(gap), "episode"), show.Name,
+	), ReplyOptions{EditMessageID: cb.Message.MessageID})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestProgressGapKeptInBacklog(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "1")
+
+	// Moving back leaves no gap
+	env.text("watched night shift s1e1")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); strings.HasPrefix(got, "Heads up") {
+		t.Fatalf("moving progress back warned: %q", got)
+	}
+	previousID := queryString(t, env, `SELECT last_watched_episode_id FROM shows`)
+
+	env.text("watched night shift s2e2")
+	want := `Heads up: that skips 2 episodes of "Night Shift" (S01E02 to S01E03). Did you watch them?`
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != want {
+		t.Fatalf("warning = %q, want %q", got, want)
+	}
+	showID := queryString(t, env, `SELECT id FROM shows`)
+	episodeID := queryString(t, env, `SELECT last_watched_episode_id FROM shows`)
+	env.press("gapKeep:" + showID + ":" + previousID + ":" + episodeID)
+	if got := queryString(t, env, `SELECT COUNT(*) FROM skipped_episodes`); got != "2" {
+		t.Fatalf("skipped episodes = %s, want 2", got)
+	}
+
+	env.command("/backlog")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "Night Shift — 2 episodes behind") {
+		t.Errorf("backlog = %q, want the skipped episodes counted", got)
+	}
+
+	env.command("/history")
+	env.press("selectShow:1:history")
+	env.press("browseShow:1:history:0")
+	if got, want := env.telegram.lastMessage(t).labels(t), []string{"Season 1", "Season 2", "<< Back"}; !slices.Equal(got, want) {
+		t.Errorf("seasons = %v, want %v", got, want)
+	}
+	env.press("browseSeason:1:history:1:0")
+	wantLabels := []string{"✅ 1. Episode 1.1", "2. Episode 1.2", "3. Episode 1.3", "<< Seasons", "Show"}
+	if got := env.telegram.lastMessage(t).labels(t); !slices.Equal(got, wantLabels) {
+		t.Errorf("episodes = %v, want %v", got, wantLabels)
+	}
+
+	// Marking a skipped episode leaves progress where it is
+	env.press("toggleWatched:1:history:1:2:0")
+	wantLabels = []string{"✅ 1. Episode 1.1", "✅ 2. Episode 1.2", "3. Episode 1.3", "<< Seasons", "Show"}
+	if got := env.telegram.lastMessage(t).labels(t); !slices.Equal(got, wantLabels) {
+		t.Errorf("episodes after marking S01E02 = %v, want %v", got, wantLabels)
+	}
+	if got := queryString(t, env, `SELECT last_watched_episode_id FROM shows`); got != episodeID {
+		t.Errorf("progress = %s, want %s", got, episodeID)
+	}
+
+	// Moving progress back before them forgets the rest
+	env.text("watched night shift s1e1")
+	if got := queryString(t, env, `SELECT COUNT(*) FROM skipped_episodes`); got != "0" {
+		t.Errorf("skipped episodes after moving back = %s, want 0", got)
+	}
+}
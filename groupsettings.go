@@ -0,0 +1,202 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// By default anyone in a group can add, remove and advance its watch parties. With
+// /groupsettings a group admin can restrict that to admins; everyone can still view
+// the parties and join, leave or catch up themselves. Admin status comes from
+// getChatMember and is cached for chatAdminTTL, so a promotion or demotion can take
+// that long to count.
+
+const chatAdminTTL = 10 * time.Minute
+
+type chatUser struct {
+	ChatID int64
+	UserID int64
+}
+
+type cachedChatAdmin struct {
+	IsAdmin   bool
+	CheckedAt time.Time
+}
+
+func getGroupAdminsOnly(ctx context.Context, db *store.DB, chatID int64) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var adminsOnly bool
+	err := db.QueryRowContext(ctx, `SELECT admins_only FROM group_settings WHERE chat_id = ?`, chatID).Scan(&adminsOnly)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return adminsOnly, err
+}
+
+func setGroupAdminsOnly(ctx context.Context, db *store.DB, chatID int64, adminsOnly bool) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO group_settings (chat_id, admins_only) VALUES (?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET admins_only = excluded.admins_only
+	`, chatID, adminsOnly)
+	return err
+}
+
+// isChatAdmin reports whether the user is the creator or an administrator of the chat.
+func (bot *Bot) isChatAdmin(chatID, userID int64) (bool, error) {
+	key := chatUser{ChatID: chatID, UserID: userID}
+	bot.mu.Lock()
+	cached, ok := bot.chatAdmins[key]
+	bot.mu.Unlock()
+	if ok && time.Since(cached.CheckedAt) < chatAdminTTL {
+		return cached.IsAdmin, nil
+	}
+
+	resp, err := bot.BotApi.Request(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID},
+	})
+	if err != nil {
+		return false, fmt.Errorf("getting member %d of chat %d: %w", userID, chatID, err)
+	}
+	var member tgbotapi.ChatMember
+	if err := json.Unmarshal(resp.Result, &member); err != nil {
+		return false, fmt.Errorf("decoding member %d of chat %d: %w", userID, chatID, err)
+	}
+	isAdmin := member.IsCreator() || member.IsAdministrator()
+
+	bot.mu.Lock()
+	if bot.chatAdmins == nil {
+		bot.chatAdmins = make(map[chatUser]cachedChatAdmin)
+	}
+	bot.chatAdmins[key] = cachedChatAdmin{IsAdmin: isAdmin, CheckedAt: time.Now()}
+	bot.mu.Unlock()
+	return isAdmin, nil
+}
+
+// isAnonymousAdmin reports whether the message was sent by an admin posting as the
+// group, who has no user to look up.
+func isAnonymousAdmin(msg *tgbotapi.Message) bool {
+	return msg.SenderChat != nil && msg.SenderChat.ID == msg.Chat.ID
+}
+
+// requireGroupManager returns a user error when the group only lets admins manage
+// its watch parties and the user isn't one.
+func (handler *Handler) requireGroupManager(ctx context.Context, chatID, userID int64) error {
+	adminsOnly, err := getGroupAdminsOnly(ctx, handler.DB, chatID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting group settings for chat %d: %w", chatID, err),
+			"Error checking the group settings, please try again later.",
+		)
+	}
+	if !adminsOnly {
+		return nil
+	}
+	isAdmin, err := handler.Bot.isChatAdmin(chatID, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("checking admin status of user %d in chat %d: %w", userID, chatID, err),
+			"I couldn't check whether you're an admin here, please try again later.",
+		)
+	}
+	if !isAdmin {
+		return NewUserError(
+			fmt.Errorf("user %d isn't an admin of chat %d", userID, chatID),
+			"Only group admins can add, remove or advance watch parties here.",
+		)
+	}
+	return nil
+}
+
+func formatGroupSettings(adminsOnly bool) (string, *tgbotapi.InlineKeyboardMarkup) {
+	who := "everyone"
+	if adminsOnly {
+		who = "only admins"
+	}
+	text := fmt.Sprintf(
+		"Who can add, remove and advance watch parties: %s.\nEveryone can view them and join.", who,
+	)
+	everyone, admins := "Everyone", "Only admins"
+	if adminsOnly {
+		admins = "✅ " + admins
+	} else {
+		everyone = "✅ " + everyone
+	}
+	keyboard := makeKeyboardMarkup([][][]string{{
+		{everyone, "groupPerm:everyone"},
+		{admins, "groupPerm:admins"},
+	}})
+	return text, keyboard
+}
+
+// GROUPSETTINGS command
+
+func (handler *Handler) handleGroupSettingsCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	if !msg.Chat.IsGroup() && !msg.Chat.IsSuperGroup() {
+		return NewUserError(
+			fmt.Errorf("groupsettings in non-group chat %d", chatID),
+			"/groupsettings is for group chats.",
+		)
+	}
+	adminsOnly, err := getGroupAdminsOnly(ctx, handler.DB, chatID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting group settings for chat %d: %w", chatID, err),
+			"Error getting the group settings, please try again later.",
+		)
+	}
+	text, keyboard := formatGroupSettings(adminsOnly)
+	handler.Bot.reply(ctx, chatID, text, ReplyOptions{ReplyMarkup: keyboard})
+	return nil
+}
+
+// handleGroupPermCallback changes who can manage watch parties. Only admins can,
+// whatever the current setting.
+func (handler *Handler) handleGroupPermCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	if callbackParam != "everyone" && callbackParam != "admins" {
+		log.Printf("handleGroupPermCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	chatID := cb.Message.Chat.ID
+	userID := cb.From.ID
+
+	isAdmin, err := handler.Bot.isChatAdmin(chatID, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("checking admin status of user %d in chat %d: %w", userID, chatID, err),
+			"I couldn't check whether you're an admin here, please try again later.",
+		)
+	}
+	if !isAdmin {
+		return NewUserError(
+			fmt.Errorf("user %d isn't an admin of chat %d", userID, chatID),
+			"Only group admins can change the group settings.",
+		)
+	}
+
+	adminsOnly := callbackParam == "admins"
+	if err := setGroupAdminsOnly(ctx, handler.DB, chatID, adminsOnly); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting group settings for chat %d: %w", chatID, err),
+			"Error updating the group settings, please try again later.",
+		)
+	}
+	text, keyboard := formatGroupSettings(adminsOnly)
+	handler.Bot.reply(ctx, chatID, text, ReplyOptions{ReplyMarkup: keyboard, EditMessageID: cb.Message.MessageID})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
@@ -1,71 +1,336 @@
-package main
+package bot
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
+	"html"
 	"log"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/reminder"
+	"tvreminder/bot/internal/store"
 )
 
 type Handler struct {
-	Bot *Bot
-	DB  *sql.DB
+	Bot      *Bot
+	DB       *store.DB
+	Provider Provider
+	// Movies is nil when movie tracking isn't configured.
+	Movies MovieProvider
+	// Mailer is nil when email isn't configured.
+	Mailer Mailer
+	// Trakt is nil when Trakt sync isn't configured.
+	Trakt *Trakt
+	// FeedBaseURL is the public URL of the HTTP listener, empty when it's off.
+	FeedBaseURL string
+
+	// UpdateLimiter and SearchLimiter throttle users flooding the bot, see
+	// ratelimit.go. Nil limiters don't limit.
+	UpdateLimiter *RateLimiter
+	SearchLimiter *RateLimiter
+	// Admins are the users allowed to run /admin, and who are alerted when handling
+	// an update panics.
+	Admins map[int64]bool
+	// Clock tells the time users see passing: the system clock, or in simulation
+	// mode a clock.Fake admins move with /admin clock. See simulation.go.
+	Clock clock.Clock
+	// Reminders is the scheduler of the reminder loop, woken once the reminders and
+	// check-ins an update or job may have changed are saved. Nil doesn't wake.
+	Reminders *reminder.Scheduler
+	// CacheCleanup counts the runs of cacheCleanupLoop, for /admin stats.
+	CacheCleanup *CacheCleanupStats
+
+	// addJobs are the shows being added in the background, see startAddShow.
+	addJobs jobTracker
+	// traktJobs are the Trakt connections waiting for the user's approval.
+	traktJobs jobTracker
 }
 
-func (handler *Handler) processUpdatesForever() {
+// updateWorkers is the number of users whose updates are processed concurrently.
+const updateWorkers = 8
+
+// processUpdates handles updates until ctx is cancelled, then waits for the ones
+// already received to be handled and the background jobs they started. Handlers
+// get ctx, so their queries and jobs stop early on shutdown.
+func (handler *Handler) processUpdates(ctx context.Context) {
 	updateConfig := tgbotapi.NewUpdate(0)
 	updateConfig.Timeout = 30
-	updates := handler.Bot.BotApi.GetUpdatesChan(updateConfig)
-
-	for update := range updates {
-		if update.CallbackQuery != nil {
-			handler.handleCallback(update.CallbackQuery)
-			continue
+	// Reactions are only sent when asked for
+	updateConfig.AllowedUpdates = []string{"message", "edited_message", "callback_query", "message_reaction"}
+
+	// Jobs outlive the updates that start them, so they're waited for last
+	defer handler.traktJobs.wait()
+	defer handler.addJobs.wait()
+	dispatcher := newUpdateDispatcher(updateWorkers, func(update tgbotapi.Update) {
+		handler.handleUpdate(ctx, update)
+	})
+	defer dispatcher.wait()
+	for {
+		updates, raws, err := handler.Bot.pollUpdates(ctx, updateConfig)
+		if ctx.Err() != nil {
+			log.Println("processUpdates: context cancelled, exiting")
+			return
 		}
-
-		if update.Message == nil {
-			log.Printf("processUpdatesForever: message is nil")
+		if err != nil {
+			log.Printf("processUpdates: getting updates: %v", err)
+			select {
+			case <-time.After(3 * time.Second):
+			case <-ctx.Done():
+			}
 			continue
 		}
+		for i, update := range updates {
+			updateConfig.Offset = update.UpdateID + 1
+			key := updateRoutingKey(update)
+			if raws[i].forum() {
+				handler.Bot.setUpdateThread(update.UpdateID, raws[i].threadID())
+			}
+			if reaction := raws[i].MessageReaction; reaction != nil {
+				if reaction.User != nil {
+					key = uint64(reaction.User.ID)
+				}
+				handler.Bot.setUpdateReaction(update.UpdateID, reaction)
+			}
+			dispatcher.dispatch(key, update)
+		}
+	}
+}
 
-		msg := update.Message
-		userID := msg.From.ID
-		state := handler.Bot.getState(userID)
+// updateRoutingKey returns the key updates are serialized by: the sender's user ID,
+// falling back to the chat ID for updates without a sender.
+func updateRoutingKey(update tgbotapi.Update) uint64 {
+	if user := update.SentFrom(); user != nil {
+		return uint64(user.ID)
+	}
+	if chat := update.FromChat(); chat != nil {
+		return uint64(chat.ID)
+	}
+	return 0
+}
 
-		switch {
-		case msg.IsCommand():
-			handler.handleCommand(msg)
-		case state == StateAwaitingShowName:
-			if err := handler.acceptShowName(msg); err != nil {
-				handler.Bot.reply(msg.Chat.ID, getUserMessage(err))
+func (handler *Handler) handleUpdate(ctx context.Context, update tgbotapi.Update) {
+	if threadID := handler.Bot.takeUpdateThread(update.UpdateID); threadID != 0 {
+		ctx = withUpdateThread(ctx, update.FromChat().ID, threadID)
+	}
+	// Nearly every change to reminders, progress or quiet hours comes from an
+	// update, so the reminder loop looks at its schedule again after each one
+	defer handler.Reminders.Wake()
+	// Deferred after the topic is set, so the apology still goes to the topic
+	defer handler.recoverUpdate(ctx, update)
+	if user, chat := update.SentFrom(), update.FromChat(); user != nil && chat != nil {
+		if !handler.checkRateLimit(ctx, handler.UpdateLimiter, user, chat.ID) {
+			if update.CallbackQuery != nil {
+				handler.Bot.answerCallbackQuery(update.CallbackQuery.ID)
 			}
-		default:
-			handler.Bot.reply(msg.Chat.ID, "Unexpected message received, see /help for available commands.")
+			return
+		}
+	}
+	if user := update.SentFrom(); user != nil {
+		reactivated, err := markUserActive(ctx, handler.DB, user.ID, handler.Clock.Now())
+		if err != nil {
+			log.Printf("handleUpdate: marking user %d active: %v", user.ID, err)
+		}
+		if reactivated {
+			handler.Bot.reply(ctx, user.ID, "Welcome back! Your reminders are switched on again.")
+		}
+	}
+
+	if update.CallbackQuery != nil {
+		if cb := update.CallbackQuery; cb.Message != nil {
+			handler.noteReminderInteraction(ctx, cb.Message.Chat.ID, cb.Message.MessageID)
+		}
+		handler.handleCallback(ctx, update.CallbackQuery)
+		return
+	}
+
+	if reaction := handler.Bot.takeUpdateReaction(update.UpdateID); reaction != nil {
+		if reaction.User != nil {
+			handler.noteReminderInteraction(ctx, reaction.Chat.ID, reaction.MessageID)
+		}
+		handler.handleReaction(ctx, reaction)
+		return
+	}
+
+	if update.EditedMessage != nil {
+		handler.handleEditedMessage(ctx, update.EditedMessage)
+		return
+	}
+
+	if update.Message == nil {
+		log.Printf("handleUpdate: message is nil")
+		return
+	}
+
+	msg := update.Message
+	if handler.handleChatMigration(ctx, msg) {
+		return
+	}
+	userID := msg.From.ID
+	handler.rememberGroupSender(ctx, msg)
+	if msg.ReplyToMessage != nil {
+		handler.noteReminderInteraction(ctx, msg.Chat.ID, msg.ReplyToMessage.MessageID)
+	}
+	state := handler.Bot.getState(userID)
+
+	switch {
+	case msg.IsCommand():
+		handler.handleCommand(ctx, msg)
+	case state == StateAwaitingShowName:
+		if err := handler.acceptShowName(ctx, msg); err != nil {
+			handler.replyError(ctx, userID, msg.Chat.ID, err)
+		}
+	case state == StateAwaitingImport:
+		if err := handler.acceptImport(ctx, msg); err != nil {
+			handler.replyError(ctx, userID, msg.Chat.ID, err)
+		}
+	case state == StateAwaitingNote:
+		if err := handler.acceptNote(ctx, msg); err != nil {
+			handler.replyError(ctx, userID, msg.Chat.ID, err)
+		}
+	case state == StateAwaitingMuteDate:
+		if err := handler.acceptMuteDate(ctx, msg); err != nil {
+			handler.replyError(ctx, userID, msg.Chat.ID, err)
+		}
+	case state == StateAwaitingTemplate:
+		if err := handler.acceptShowTemplate(ctx, msg); err != nil {
+			handler.replyError(ctx, userID, msg.Chat.ID, err)
+		}
+	case state == StateAwaitingDropReason:
+		if err := handler.acceptDropReason(ctx, msg); err != nil {
+			handler.replyError(ctx, userID, msg.Chat.ID, err)
+		}
+	case state == StateAwaitingTag:
+		if err := handler.acceptTag(ctx, msg); err != nil {
+			handler.replyError(ctx, userID, msg.Chat.ID, err)
+		}
+	case state == StateAwaitingOffset:
+		if err := handler.acceptReminderOffset(ctx, msg); err != nil {
+			handler.replyError(ctx, userID, msg.Chat.ID, err)
+		}
+	case state == StateAwaitingSeasonEpisode:
+		if err := handler.acceptEpisodeInput(ctx, msg); err != nil {
+			handler.replyError(ctx, userID, msg.Chat.ID, err)
+		}
+	default:
+		handled, err := handler.acceptProgressUpdate(ctx, msg)
+		if err != nil {
+			handler.replyError(ctx, userID, msg.Chat.ID, err)
+		} else if !handled {
+			handler.Bot.reply(ctx, msg.Chat.ID, "Unexpected message received, see /help for available commands.")
 		}
 	}
 }
 
-func (handler *Handler) handleCommand(msg *tgbotapi.Message) {
+func (handler *Handler) handleCommand(ctx context.Context, msg *tgbotapi.Message) {
 	chatID := msg.Chat.ID
 	command := msg.Command()
 
 	var err error
 	switch command {
 	case "start":
-		err = handler.handleStartCommand(msg)
+		err = handler.handleStartCommand(ctx, msg)
 	case "help":
-		err = handler.handleHelpCommand(msg)
+		err = handler.handleHelpCommand(ctx, msg)
 	case "add":
-		err = handler.handleAddCommand(msg)
+		err = handler.handleAddCommand(ctx, msg)
+	case "search":
+		err = handler.handleSearchCommand(ctx, msg)
 	case "shows":
-		err = handler.handleShowsCommand(msg)
+		err = handler.handleShowsCommand(ctx, msg)
 	case "history":
-		err = handler.handleHistoryCommand(msg)
+		err = handler.handleHistoryCommand(ctx, msg)
+	case "backlog":
+		err = handler.handleBacklogCommand(ctx, msg)
+	case "queue":
+		err = handler.handleQueueCommand(ctx, msg)
+	case "pick":
+		err = handler.handlePickCommand(ctx, msg)
+	case "trending":
+		err = handler.handleTrendingCommand(ctx, msg)
+	case "tags":
+		err = handler.handleTagsCommand(ctx, msg)
+	case "timezone":
+		err = handler.handleTimezoneCommand(ctx, msg)
+	case "quiet":
+		err = handler.handleQuietCommand(ctx, msg)
+	case "links":
+		err = handler.handleLinksCommand(ctx, msg)
+	case "summaries":
+		err = handler.handleSummariesCommand(ctx, msg)
+	case "autobackup":
+		err = handler.handleAutoBackupCommand(ctx, msg)
+	case "export":
+		err = handler.handleExportCommand(ctx, msg)
+	case "import":
+		err = handler.handleImportCommand(ctx, msg)
+	case "country":
+		err = handler.handleCountryCommand(ctx, msg)
+	case "airalerts":
+		err = handler.handleAirAlertsCommand(ctx, msg)
+	case "hype":
+		err = handler.handleHypeCommand(ctx, msg)
+	case "template":
+		err = handler.handleTemplateCommand(ctx, msg)
+	case "dashboard":
+		err = handler.handleDashboardCommand(ctx, msg)
+	case "cancel":
+		err = handler.handleCancelCommand(ctx, msg)
+	case "watchparty":
+		err = handler.handleWatchPartyCommand(ctx, msg)
+	case "groupsettings":
+		err = handler.handleGroupSettingsCommand(ctx, msg)
+	case "stats":
+		err = handler.handleStatsCommand(ctx, msg)
+	case "invite":
+		err = handler.handleInviteCommand(ctx, msg)
+	case "reminders":
+		err = handler.handleRemindersCommand(ctx, msg)
+	case "webhook":
+		err = handler.handleWebhookCommand(ctx, msg)
+	case "trakt":
+		err = handler.handleTraktCommand(ctx, msg)
+	case "discord":
+		err = handler.handleDiscordCommand(ctx, msg)
+	case "digest":
+		err = handler.handleDigestCommand(ctx, msg)
+	case "tonight":
+		err = handler.handleTonightCommand(ctx, msg)
+	case "recap":
+		err = handler.handleRecapCommand(ctx, msg)
+	case "email":
+		err = handler.handleEmailCommand(ctx, msg)
+	case "settings":
+		err = handler.handleSettingsCommand(ctx, msg)
+	case "feed":
+		err = handler.handleFeedCommand(ctx, msg)
+	case "checkin":
+		err = handler.handleCheckinCommand(ctx, msg)
+	case "autoadvance":
+		err = handler.handleAutoAdvanceCommand(ctx, msg)
+	case "watchlist":
+		err = handler.handleWatchlistCommand(ctx, msg)
+	case "next":
+		err = handler.handleNextCommand(ctx, msg)
+	case "upcoming":
+		err = handler.handleUpcomingCommand(ctx, msg)
+	case "addmovie":
+		err = handler.handleAddMovieCommand(ctx, msg)
+	case "admin":
+		err = handler.handleAdminCommand(ctx, msg)
+	case "movies":
+		err = handler.handleMoviesCommand(ctx, msg)
+	case "trash":
+		err = handler.handleTrashCommand(ctx, msg)
+	case "merge":
+		err = handler.handleMergeCommand(ctx, msg)
 	default:
 		err = NewUserError(
 			fmt.Errorf("unknown command: %s", command),
@@ -74,73 +339,239 @@ func (handler *Handler) handleCommand(msg *tgbotapi.Message) {
 	}
 
 	if err != nil {
-		handler.Bot.reply(chatID, getUserMessage(err))
+		handler.replyError(ctx, msg.From.ID, chatID, err)
 	}
 }
 
-func (handler *Handler) handleCallback(cb *tgbotapi.CallbackQuery) {
-	action, callbackParam, found := strings.Cut(cb.Data, ":")
-	if !found {
-		log.Printf("handleCallback: invalid callback data: %s", cb.Data)
+func (handler *Handler) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
+	var chatID int64
+	if cb.Message != nil {
+		chatID = cb.Message.Chat.ID
+	}
+	data, err := handler.Bot.resolveCallback(ctx, cb.Data, chatID, time.Now())
+	if err != nil {
+		log.Printf("handleCallback: rejecting callback %q from user %d: %v", cb.Data, cb.From.ID, err)
+		handler.Bot.clearState(cb.From.ID)
+		// The message may be a reminder or check-in, whose text is worth keeping
+		handler.Bot.answerCallbackAlert(cb.ID, "This menu expired, please open it again.")
 		return
 	}
+	action, callbackParam, _ := strings.Cut(data, ":")
 
-	var err error
 	switch action {
 	case "acceptShowName":
-		err = handler.handleShowNameCallback(cb, callbackParam)
+		err = handler.handleShowNameCallback(ctx, cb, callbackParam)
+	case "onboardTimezone":
+		err = handler.handleOnboardTimezoneCallback(ctx, cb, callbackParam)
+	case "onboardQuiet":
+		err = handler.handleOnboardQuietCallback(ctx, cb, callbackParam)
+	case "onboardDone":
+		err = handler.handleOnboardDoneCallback(ctx, cb)
+	case "reminders":
+		err = handler.handleRemindersCallback(ctx, cb)
+	case "retryReminder":
+		err = handler.handleRetryReminderCallback(ctx, cb, callbackParam)
+	case "dismissReminder":
+		err = handler.handleDismissReminderCallback(ctx, cb, callbackParam)
+	case "cancelReminder":
+		err = handler.handleCancelReminderCallback(ctx, cb, callbackParam)
+	case "rescheduleReminder":
+		err = handler.handleRescheduleReminderCallback(ctx, cb, callbackParam)
+	case "searchInfo":
+		err = handler.handleSearchInfoCallback(ctx, cb, callbackParam)
+	case "searchResults":
+		err = handler.handleSearchResultsCallback(ctx, cb)
+	case "searchAdd":
+		err = handler.handleSearchAddCallback(ctx, cb, callbackParam)
+	case "gapWatched":
+		err = handler.handleGapWatchedCallback(ctx, cb)
+	case "gapKeep":
+		err = handler.handleGapKeepCallback(ctx, cb, callbackParam)
+	case "didYouMean":
+		err = handler.handleDidYouMeanCallback(ctx, cb, callbackParam)
 	case "selectSeason":
-		err = handler.handleSeasonCallback(cb, callbackParam)
+		err = handler.handleSeasonCallback(ctx, cb, callbackParam)
 	case "selectEpisode":
-		err = handler.handleEpisodeCallback(cb, callbackParam)
+		err = handler.handleEpisodeCallback(ctx, cb, callbackParam)
 	case "selectShow":
-		err = handler.handleSelectShowCallback(cb, callbackParam)
+		err = handler.handleSelectShowCallback(ctx, cb, callbackParam)
 	case "backToShows":
-		err = handler.handleBackToShowsCallback(cb, callbackParam)
+		err = handler.handleBackToShowsCallback(ctx, cb, callbackParam)
+	case "showsFilter":
+		err = handler.handleShowsFilterCallback(ctx, cb, callbackParam)
+	case "showsTag":
+		err = handler.handleShowsTagCallback(ctx, cb, callbackParam)
+	case "tagList":
+		err = handler.handleTagListCallback(ctx, cb, callbackParam)
+	case "deleteTag":
+		err = handler.handleDeleteTagCallback(ctx, cb, callbackParam)
+	case "editTags":
+		err = handler.handleEditTagsCallback(ctx, cb, callbackParam)
+	case "toggleTag":
+		err = handler.handleToggleTagCallback(ctx, cb, callbackParam)
+	case "newTag":
+		err = handler.handleNewTagCallback(ctx, cb, callbackParam)
 	case "toggleNotifications":
-		err = handler.handleToggleNotificationsCallback(cb, callbackParam)
+		err = handler.handleToggleNotificationsCallback(ctx, cb, callbackParam)
 	case "markNextWatched":
-		err = handler.handleMarkNextWatchedCallback(cb, callbackParam)
+		err = handler.handleMarkNextWatchedCallback(ctx, cb, callbackParam)
+	case "releaseSchedule":
+		err = handler.handleReleaseScheduleCallback(ctx, cb, callbackParam)
+	case "notifyChats":
+		err = handler.handleNotifyChatsCallback(ctx, cb, callbackParam)
+	case "notifyChat":
+		err = handler.handleNotifyChatCallback(ctx, cb, callbackParam)
+	case "releaseDelay":
+		err = handler.handleReleaseDelayCallback(ctx, cb, callbackParam)
+	case "setRelease":
+		err = handler.handleSetReleaseCallback(ctx, cb, callbackParam)
+	case "toggleReminderMode":
+		err = handler.handleToggleReminderModeCallback(ctx, cb, callbackParam)
+	case "markCaughtUp":
+		err = handler.handleMarkCaughtUpCallback(ctx, cb, callbackParam)
+	case "browseShow":
+		err = handler.handleBrowseShowCallback(ctx, cb, callbackParam)
+	case "browseSeason":
+		err = handler.handleBrowseSeasonCallback(ctx, cb, callbackParam)
+	case "toggleWatched":
+		err = handler.handleToggleWatchedCallback(ctx, cb, callbackParam)
+	case "setProgress":
+		err = handler.handleSetProgressCallback(ctx, cb, callbackParam)
+	case "moveShow":
+		err = handler.handleMoveShowCallback(ctx, cb, callbackParam)
+	case "togglePinned":
+		err = handler.handleTogglePinnedCallback(ctx, cb, callbackParam)
+	case "dropShow":
+		err = handler.handleDropShowCallback(ctx, cb, callbackParam)
+	case "confirmDrop":
+		err = handler.handleConfirmDropCallback(ctx, cb, callbackParam)
+	case "resumeShow":
+		err = handler.handleResumeShowCallback(ctx, cb, callbackParam)
+	case "toggleArchived":
+		err = handler.handleToggleArchivedCallback(ctx, cb, callbackParam)
+	case "toggleSilent":
+		err = handler.handleToggleSilentCallback(ctx, cb, callbackParam)
+	case "shareShow":
+		err = handler.handleShareShowCallback(ctx, cb, callbackParam)
+	case "muteShow":
+		err = handler.handleMuteShowCallback(ctx, cb, callbackParam)
+	case "setMute":
+		err = handler.handleSetMuteCallback(ctx, cb, callbackParam)
+	case "muteCustom":
+		err = handler.handleMuteCustomCallback(ctx, cb, callbackParam)
+	case "deleteShow":
+		err = handler.handleDeleteShowCallback(ctx, cb, callbackParam)
+	case "restoreShow":
+		err = handler.handleRestoreShowCallback(ctx, cb, callbackParam)
+	case "mergeShows":
+		err = handler.handleMergeShowsCallback(ctx, cb, callbackParam)
+	case "editNote":
+		err = handler.handleEditNoteCallback(ctx, cb, callbackParam)
+	case "refreshShow":
+		err = handler.handleRefreshShowCallback(ctx, cb, callbackParam)
+	case "editTemplate":
+		err = handler.handleEditTemplateCallback(ctx, cb, callbackParam)
+	case "reminderOffsets":
+		err = handler.handleReminderOffsetsCallback(ctx, cb, callbackParam)
+	case "addOffset":
+		err = handler.handleAddOffsetCallback(ctx, cb, callbackParam)
+	case "deleteOffset":
+		err = handler.handleDeleteOffsetCallback(ctx, cb, callbackParam)
+	case "customOffset":
+		err = handler.handleCustomOffsetCallback(ctx, cb, callbackParam)
+	case "clearNote":
+		err = handler.handleClearNoteCallback(ctx, cb, callbackParam)
+	case "rateSeason":
+		err = handler.handleRateSeasonCallback(ctx, cb, callbackParam)
+	case "watchlistShow":
+		err = handler.handleWatchlistShowCallback(ctx, cb)
+	case "startTracking":
+		err = handler.handleStartTrackingCallback(ctx, cb, callbackParam)
+	case "acceptMovie":
+		err = handler.handleAcceptMovieCallback(ctx, cb, callbackParam)
+	case "toggleMovieWatched":
+		err = handler.handleToggleMovieWatchedCallback(ctx, cb, callbackParam)
+	case "movieWatched":
+		err = handler.handleMovieWatchedCallback(ctx, cb, callbackParam)
+	case "partyCreate":
+		err = handler.handlePartyCreateCallback(ctx, cb, callbackParam)
+	case "partyShow":
+		err = handler.handlePartyShowCallback(ctx, cb, callbackParam)
+	case "partyJoin":
+		err = handler.handlePartyJoinCallback(ctx, cb, callbackParam)
+	case "partyLeave":
+		err = handler.handlePartyLeaveCallback(ctx, cb, callbackParam)
+	case "partyNext":
+		err = handler.handlePartyNextCallback(ctx, cb, callbackParam)
+	case "partyCaughtUp":
+		err = handler.handlePartyCaughtUpCallback(ctx, cb, callbackParam)
+	case "partyRemove":
+		err = handler.handlePartyRemoveCallback(ctx, cb, callbackParam)
+	case "partyRemoveYes":
+		err = handler.handlePartyRemoveYesCallback(ctx, cb, callbackParam)
+	case "groupPerm":
+		err = handler.handleGroupPermCallback(ctx, cb, callbackParam)
+	case "checkin":
+		err = handler.handleCheckinCallback(ctx, cb, callbackParam)
+	case "whatsNext":
+		err = handler.handleWhatsNextCallback(ctx, cb)
 	case "cancel":
-		err = handler.handleCancelCallback(cb)
+		err = handler.handleCancelCallback(ctx, cb)
+	case "noop":
+		// Section headers in lists
+		handler.Bot.answerCallbackQuery(cb.ID)
 	}
 
 	if err != nil {
-		handler.Bot.reply(cb.Message.Chat.ID, getUserMessage(err))
+		handler.replyError(ctx, cb.From.ID, cb.Message.Chat.ID, err)
 		handler.Bot.answerCallbackQuery(cb.ID)
 	}
 }
 
 // ADD command flow
 
-func (handler *Handler) handleAddCommand(msg *tgbotapi.Message) error {
+func (handler *Handler) handleAddCommand(ctx context.Context, msg *tgbotapi.Message) error {
 	chatID := msg.Chat.ID
 	args := strings.TrimSpace(msg.CommandArguments())
 	if args == "" {
-		handler.Bot.reply(chatID, "Enter show name:")
+		handler.Bot.reply(ctx, chatID, "Enter show name:")
 		handler.Bot.setState(msg.From.ID, StateAwaitingShowName)
 		return nil
 	}
-	return handler.searchAndSelectShow(args, msg.From.ID, chatID)
+	return handler.searchAndSelectShow(ctx, args, msg.From, chatID)
 }
 
-func (handler *Handler) acceptShowName(msg *tgbotapi.Message) error {
-	query := msg.Text
-	chatID := msg.Chat.ID
-	userID := msg.From.ID
-	return handler.searchAndSelectShow(query, userID, chatID)
+func (handler *Handler) acceptShowName(ctx context.Context, msg *tgbotapi.Message) error {
+	return handler.searchAndSelectShow(ctx, msg.Text, msg.From, msg.Chat.ID)
 }
 
-func (handler *Handler) searchAndSelectShow(query string, userID int64, chatID int64) error {
+func (handler *Handler) searchAndSelectShow(ctx context.Context, text string, user *tgbotapi.User, chatID int64) error {
+	userID := user.ID
+	query, filters := parseSearchQuery(text)
 	if query == "" {
-		handler.Bot.reply(chatID, "Enter show name")
+		handler.Bot.reply(ctx, chatID, "Enter show name")
 		return nil
 	}
+	if !handler.checkRateLimit(ctx, handler.SearchLimiter, user, chatID) {
+		return nil
+	}
+
+	handler.Bot.sendChatAction(chatID, tgbotapi.ChatTyping)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	results, err := SearchShow(ctx, query)
+	results, usedQuery, err := handler.searchShowWithFallback(ctx, query)
+	known := false
+	if errors.Is(err, ErrProviderUnavailable) {
+		// Shows other users track can still be added from the episode cache
+		results, err = searchKnownShows(ctx, handler.DB, handler.Provider.Name(), query, 5)
+		if err == nil && len(results) == 0 {
+			handler.Bot.reply(ctx, chatID, "My show database isn't answering right now, so I can't search for new shows. Please try again in a few minutes.")
+			return nil
+		}
+		known = true
+	}
 	if err != nil {
 		return NewUserError(
 			fmt.Errorf("searching show %q: %w", query, err),
@@ -149,7 +580,11 @@ func (handler *Handler) searchAndSelectShow(query string, userID int64, chatID i
 	}
 
 	if len(results) == 0 {
-		handler.Bot.reply(chatID, "No shows found for: "+query)
+		handler.offerSuggestions(ctx, userID, chatID, query)
+		return nil
+	}
+	if results = applySearchFilters(results, filters); len(results) == 0 {
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf("No shows found for %s matching %s", query, filters))
 		return nil
 	}
 
@@ -158,10 +593,8 @@ func (handler *Handler) searchAndSelectShow(query string, userID int64, chatID i
 
 	var rows [][][]string
 	for i := range max {
-		trimmed := trimString(results[i].Name, 25)
-		label := fmt.Sprintf("%d. %s (%s)", i+1, trimmed, safeString(results[i].Premiered))
 		cb := fmt.Sprintf("acceptShowName:%d", i+1)
-		rows = append(rows, [][]string{{label, cb}})
+		rows = append(rows, [][]string{{searchResultLabel(i+1, results[i]), cb}})
 	}
 	rows = append(rows, [][]string{{"❌ Cancel", "cancel"}})
 	inlineMarkup := makeKeyboardMarkup(rows)
@@ -172,11 +605,17 @@ func (handler *Handler) searchAndSelectShow(query string, userID int64, chatID i
 	})
 
 	listText := "Pick the show you want to add:"
-	handler.Bot.reply(chatID, listText, ReplyOptions{ReplyMarkup: inlineMarkup})
+	if usedQuery != query {
+		listText = fmt.Sprintf("Showing results for \"%s\". %s", usedQuery, listText)
+	}
+	if known {
+		listText = "My show database isn't answering right now, so these are shows other people track. " + listText
+	}
+	handler.Bot.reply(ctx, chatID, listText, ReplyOptions{ReplyMarkup: inlineMarkup})
 	return nil
 }
 
-func (handler *Handler) handleShowNameCallback(cb *tgbotapi.CallbackQuery, callbackParam string) error {
+func (handler *Handler) handleShowNameCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
 	searchResultIdx, err := strconv.Atoi(callbackParam)
 	if err != nil {
 		log.Printf("handleShowNameCallback: invalid callback parameter: %s", callbackParam)
@@ -196,16 +635,85 @@ func (handler *Handler) handleShowNameCallback(cb *tgbotapi.CallbackQuery, callb
 		)
 	}
 
-	showSearchResult := userCtx.SearchResults[searchResultIdx-1]
+	if searchResultIdx < 1 || searchResultIdx > len(userCtx.SearchResults) {
+		log.Printf("handleShowNameCallback: invalid search result index: %d", searchResultIdx)
+		return nil
+	}
+
+	if err := handler.startAddShow(ctx, userID, chatID, msg.MessageID, userCtx.SearchResults[searchResultIdx-1]); err != nil {
+		return err
+	}
+
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// startAddShow adds a show in the background, so fetching the episodes of a long
+// show doesn't hold up the user's other updates. The message is edited into a
+// "Fetching episodes…" note right away and into the set-progress keyboard once the
+// show is saved. A zero messageID sends the note as a new message.
+func (handler *Handler) startAddShow(ctx context.Context, userID, chatID int64, messageID int, show ShowSearchResult) error {
+	if !handler.addJobs.begin(userID) {
+		return NewUserError(
+			fmt.Errorf("user %d is already adding a show", userID),
+			"I'm still adding your previous show, please wait a moment.",
+		)
+	}
+	messageID = handler.notifyFetching(ctx, chatID, messageID)
+
+	go func() {
+		defer handler.addJobs.done(userID)
+		defer handler.Reminders.Wake()
+		if err := handler.addShowAndAskProgress(ctx, userID, chatID, messageID, show); err != nil {
+			log.Printf("startAddShow: adding show %d for user %d: %v", show.ID, userID, err)
+			handler.replyError(ctx, userID, chatID, err, ReplyOptions{EditMessageID: messageID})
+		}
+	}()
+	return nil
+}
+
+// addShowAndAskProgress adds a show for the user, caches its episodes and starts the
+// set-progress flow. A zero messageID sends a new message instead of editing one.
+// The show and its episodes are saved together, so a failure leaves nothing behind.
+func (handler *Handler) addShowAndAskProgress(ctx context.Context, userID, chatID int64, messageID int, showSearchResult ShowSearchResult) error {
+	providerShowID := strconv.Itoa(showSearchResult.ID)
+	externalIDs := showSearchResult.ExternalIDs()
+	duplicate, err := findTrackedDuplicate(ctx, handler.DB, userID, handler.Provider.Name(), providerShowID, externalIDs)
+	if err != nil {
+		log.Printf("addShowAndAskProgress: looking for duplicates of show %d: %v", showSearchResult.ID, err)
+	}
+	if duplicate != "" {
+		handler.Bot.clearState(userID)
+		return NewUserError(
+			fmt.Errorf("user %d already tracks show %d as %q", userID, showSearchResult.ID, duplicate),
+			fmt.Sprintf("You already track this show as \"%s\".", duplicate),
+		)
+	}
 
-	internalID, err := addShow(
-		handler.DB, userID, showSearchResult.Name, "tvmaze", showSearchResult.ID,
+	episodes, err := handler.fetchEpisodes(ctx, showSearchResult.ID)
+	if errors.Is(err, ErrProviderUnavailable) && handler.episodesCached(ctx, showSearchResult.ID) {
+		// The cached episodes are kept fresh by syncLoop once the provider is back
+		err = nil
+	}
+	if err != nil {
+		handler.Bot.clearState(userID)
+		return err
+	}
+
+	internalID, err := addShowWithEpisodes(
+		ctx, handler.DB, userID, showSearchResult.Name, handler.Provider.Name(), showSearchResult.ID,
+		showSearchResult.NetworkName(), showSearchResult.Image.URL(),
+		episodes, handler.episodeProgress(ctx, chatID, &messageID, len(episodes)),
 	)
 	if err != nil {
 		log.Printf("Error adding show: %s\n", err)
+		handler.Bot.clearState(userID)
 		return NewUserError(
-			fmt.Errorf("adding show for user %d provider tvmaze id %d: %w", userID, showSearchResult.ID, err),
-			"Error adding show, please try again later.",
+			fmt.Errorf(
+				"adding show for user %d provider %s id %d: %w",
+				userID, handler.Provider.Name(), showSearchResult.ID, err,
+			),
+			"Error adding show, nothing was saved. Please try again later.",
 		)
 	}
 
@@ -214,39 +722,143 @@ func (handler *Handler) handleShowNameCallback(cb *tgbotapi.CallbackQuery, callb
 		ctx.SelectedProviderID = showSearchResult.ID
 	})
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if showSearchResult.WebChannel != nil {
+		if err := setShowWebChannel(ctx, handler.DB, internalID, showSearchResult.WebChannel.Name); err != nil {
+			log.Printf("addShowAndAskProgress: storing web channel of show %d: %v", showSearchResult.ID, err)
+		}
+	}
+	if externalIDs != (ExternalIDs{}) {
+		if err := storeExternalIDs(ctx, handler.DB, handler.Provider.Name(), providerShowID, externalIDs); err != nil {
+			log.Printf("addShowAndAskProgress: storing external IDs of show %d: %v", showSearchResult.ID, err)
+		}
+	}
+	handler.refreshWatchOptions(ctx, showSearchResult.ID)
+
+	if isOneOffEvent(episodes) {
+		return handler.addOneOffEvent(ctx, userID, chatID, messageID, internalID, showSearchResult.Name, episodes[0])
+	}
+	intro := fmt.Sprintf("TV show \"%s\" added.", showSearchResult.Name)
+	watchlistRow := [][]string{{"👀 Just add to watchlist", "watchlistShow:"}}
+	return handler.askForProgress(ctx, userID, chatID, messageID, showSearchResult.ID, intro, watchlistRow)
+}
+
+// notifyFetching tells the user episodes are being fetched, by editing messageID or,
+// when it's zero, in a new message. It returns the ID of the message to edit next,
+// zero if sending failed.
+func (handler *Handler) notifyFetching(ctx context.Context, chatID int64, messageID int) int {
+	handler.Bot.sendChatAction(chatID, tgbotapi.ChatTyping)
+	if messageID != 0 {
+		handler.Bot.reply(ctx, chatID, "Fetching episodes…", ReplyOptions{EditMessageID: messageID})
+		return messageID
+	}
+	msg, err := handler.Bot.send(ctx, chatID, "Fetching episodes…")
+	if err != nil {
+		log.Printf("notifyFetching: sending to chat %d: %v", chatID, err)
+		return 0
+	}
+	return msg.MessageID
+}
+
+// fetchEpisodes fetches a show's episodes from the provider.
+func (handler *Handler) fetchEpisodes(ctx context.Context, providerShowID int) ([]Episode, error) {
+	fetchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	episodes, err := FetchEpisodes(ctx, showSearchResult.ID)
+	episodes, err := handler.Provider.FetchEpisodes(fetchCtx, providerShowID)
+	if errors.Is(err, ErrProviderUnavailable) {
+		return nil, NewUserError(
+			fmt.Errorf("fetching episodes for show %d: %w", providerShowID, err),
+			"My show database isn't answering right now. Please try again in a few minutes.",
+		)
+	}
 	if err != nil {
-		return NewUserError(
-			fmt.Errorf("fetching episodes for show %d: %w", showSearchResult.ID, err),
-			fmt.Sprintf("Episode fetching failed: %s", err),
+		return nil, NewUserError(
+			fmt.Errorf("fetching episodes for show %d: %w", providerShowID, err),
+			"Fetching episodes failed, please try again later.",
 		)
 	}
+	return episodes, nil
+}
 
-	for _, episode := range episodes {
-		showIdStr := strconv.Itoa(showSearchResult.ID)
-		episodeIdStr := strconv.Itoa(episode.ID)
-		airstampTime, err := time.Parse(time.RFC3339, episode.Airstamp)
-		if err != nil {
-			return nil
+// episodesCached reports whether a show's episodes are cached, e.g. because another
+// user tracks it.
+func (handler *Handler) episodesCached(ctx context.Context, providerShowID int) bool {
+	seasons, err := getSeasons(ctx, handler.DB, handler.Provider.Name(), strconv.Itoa(providerShowID))
+	if err != nil {
+		log.Printf("episodesCached: getting seasons for show %d: %v", providerShowID, err)
+		return false
+	}
+	return len(seasons) > 0
+}
+
+// episodeProgress returns the progress callback for storing total episodes: for
+// shows too long to store in one go, a progress message edited after every chunk.
+// The message is *messageID when set, otherwise it is sent and *messageID updated.
+// It returns nil for short shows.
+func (handler *Handler) episodeProgress(ctx context.Context, chatID int64, messageID *int, count int) func(done, total int) {
+	if count <= episodeChunkSize {
+		return nil
+	}
+	return func(done, total int) {
+		text := fmt.Sprintf("Saving episodes… %d/%d", done, total)
+		if *messageID != 0 {
+			handler.Bot.reply(ctx, chatID, text, ReplyOptions{EditMessageID: *messageID})
+			return
 		}
-		err = upsertEpisode(
-			handler.DB, "tvmaze", showIdStr, episodeIdStr, episode.Name, episode.Season,
-			episode.Number, episode.Airdate, episode.Airtime, airstampTime)
+		msg, err := handler.Bot.send(ctx, chatID, text)
 		if err != nil {
-			return nil
+			log.Printf("episodeProgress: sending progress to chat %d: %v", chatID, err)
+			return
 		}
+		*messageID = msg.MessageID
 	}
+}
 
-	seasons, err := getSeasons(handler.DB, strconv.Itoa(showSearchResult.ID))
+// cacheEpisodes fetches and stores a show's episodes while keeping the user posted,
+// see notifyFetching and episodeProgress. It returns the ID of the message the caller
+// should edit with the result, zero if there is none.
+func (handler *Handler) cacheEpisodes(ctx context.Context, chatID int64, messageID int, providerShowID int) (int, error) {
+	if messageID != 0 {
+		handler.notifyFetching(ctx, chatID, messageID)
+	} else {
+		handler.Bot.sendChatAction(chatID, tgbotapi.ChatTyping)
+	}
+	episodes, err := handler.fetchEpisodes(ctx, providerShowID)
+	if err != nil {
+		return messageID, err
+	}
+	err = storeEpisodesWithProgress(
+		ctx, handler.DB, handler.Provider.Name(), strconv.Itoa(providerShowID), episodes,
+		handler.episodeProgress(ctx, chatID, &messageID, len(episodes)),
+	)
+	if err != nil {
+		return messageID, NewUserError(
+			fmt.Errorf("storing episodes for show %d: %w", providerShowID, err),
+			"Error saving episodes, please try again later.",
+		)
+	}
+	return messageID, nil
+}
+
+// askForProgress starts the set-progress flow for the show selected in the user's
+// context: a season keyboard, or the episode keyboard directly for single-season shows.
+// A zero messageID sends a new message instead of editing an existing one. extraRows
+// are added to the keyboard above Cancel.
+func (handler *Handler) askForProgress(ctx context.Context, userID, chatID int64, messageID int, providerShowID int, intro string, extraRows ...[][]string) error {
+	seasons, err := getSeasons(ctx, handler.DB, handler.Provider.Name(), strconv.Itoa(providerShowID))
 	if err != nil {
 		return NewUserError(
-			fmt.Errorf("getting seasons for show %d: %w", showSearchResult.ID, err),
+			fmt.Errorf("getting seasons for show %d: %w", providerShowID, err),
 			"Error fetching seasons",
 		)
 	}
+	if len(seasons) == 0 {
+		handler.Bot.clearState(userID)
+		return NewUserError(
+			fmt.Errorf("no seasons for show %d", providerShowID),
+			"No episodes are known for this show yet.",
+		)
+	}
 
 	if len(seasons) == 1 {
 		// Skip season selection, go directly to episode selection
@@ -254,41 +866,38 @@ func (handler *Handler) handleShowNameCallback(cb *tgbotapi.CallbackQuery, callb
 			ctx.SelectedSeason = seasons[0]
 			ctx.State = StateAwaitingSeasonEpisode
 		})
-		episodeKeyboard, err := handler.makeEpisodeKeyboard(strconv.Itoa(showSearchResult.ID), seasons[0])
+		episodeKeyboard, err := handler.makeEpisodeKeyboard(ctx, strconv.Itoa(providerShowID), seasons[0], extraRows...)
 		if err != nil {
 			return NewUserError(
-				fmt.Errorf("making episode keyboard for show %d season %d: %w", showSearchResult.ID, seasons[0], err),
+				fmt.Errorf("making episode keyboard for show %d season %d: %w", providerShowID, seasons[0], err),
 				"Error fetching episodes",
 			)
 		}
-		text := fmt.Sprintf(
-			"TV show \"%s\" added. Which episode of season %d are you on?",
-			showSearchResult.Name, seasons[0],
-		)
-		handler.Bot.reply(chatID, text, ReplyOptions{ReplyMarkup: episodeKeyboard, EditMessageID: msg.MessageID})
-	} else {
-		var rows [][][]string
-		for _, season := range seasons {
-			label := fmt.Sprintf("Season %d", season)
-			cbData := fmt.Sprintf("selectSeason:%d", season)
-
-			rows = append(rows, [][]string{{label, cbData}})
-		}
-		rows = append(rows, [][]string{{"❌ Cancel", "cancel"}})
-		inlineMarkup := makeKeyboardMarkup(rows)
-		log.Printf("inlineMarkup: %+v", inlineMarkup)
-		handler.Bot.withUserContext(userID, func(ctx *UserContext) {
-			ctx.State = StateAwaitingSeasonEpisode
-		})
-		text := fmt.Sprintf("TV show \"%s\" added. Which season are you on?", showSearchResult.Name)
-		handler.Bot.reply(chatID, text, ReplyOptions{ReplyMarkup: inlineMarkup, EditMessageID: msg.MessageID})
+		text := fmt.Sprintf("%s Which episode of season %d are you on? You can also type its number.", intro, seasons[0])
+		handler.Bot.reply(ctx, chatID, text, ReplyOptions{ReplyMarkup: episodeKeyboard, EditMessageID: messageID})
+		return nil
 	}
 
-	handler.Bot.answerCallbackQuery(cb.ID)
+	var rows [][][]string
+	for _, season := range seasons {
+		label := fmt.Sprintf("Season %d", season)
+		cbData := fmt.Sprintf("selectSeason:%d", season)
+
+		rows = append(rows, [][]string{{label, cbData}})
+	}
+	rows = append(rows, extraRows...)
+	rows = append(rows, [][]string{{"❌ Cancel", "cancel"}})
+	inlineMarkup := makeKeyboardMarkup(rows)
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.SelectedSeason = 0
+		ctx.State = StateAwaitingSeasonEpisode
+	})
+	text := fmt.Sprintf("%s Which season are you on?", intro)
+	handler.Bot.reply(ctx, chatID, text, ReplyOptions{ReplyMarkup: inlineMarkup, EditMessageID: messageID})
 	return nil
 }
 
-func (handler *Handler) handleSeasonCallback(cb *tgbotapi.CallbackQuery, callbackParam string) error {
+func (handler *Handler) handleSeasonCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
 	season, err := strconv.Atoi(callbackParam)
 	if err != nil {
 		log.Printf("handleSeasonCallback: invalid season: %s", callbackParam)
@@ -312,7 +921,7 @@ func (handler *Handler) handleSeasonCallback(cb *tgbotapi.CallbackQuery, callbac
 		ctx.SelectedSeason = season
 	})
 
-	episodeKeyboard, err := handler.makeEpisodeKeyboard(strconv.Itoa(userCtx.SelectedProviderID), season)
+	episodeKeyboard, err := handler.makeEpisodeKeyboard(ctx, strconv.Itoa(userCtx.SelectedProviderID), season)
 	if err != nil {
 		return NewUserError(
 			fmt.Errorf("making episode keyboard for show %d season %d: %w", userCtx.SelectedProviderID, season, err),
@@ -320,15 +929,15 @@ func (handler *Handler) handleSeasonCallback(cb *tgbotapi.CallbackQuery, callbac
 		)
 	}
 
-	text := fmt.Sprintf("Which episode of season %d are you on?", season)
-	handler.Bot.reply(chatID, text, ReplyOptions{ReplyMarkup: episodeKeyboard, EditMessageID: msg.MessageID})
+	text := fmt.Sprintf("Which episode of season %d are you on? You can also type its number.", season)
+	handler.Bot.reply(ctx, chatID, text, ReplyOptions{ReplyMarkup: episodeKeyboard, EditMessageID: msg.MessageID})
 
 	handler.Bot.answerCallbackQuery(cb.ID)
 	return nil
 }
 
-func (handler *Handler) makeEpisodeKeyboard(providerShowID string, season int) (*tgbotapi.InlineKeyboardMarkup, error) {
-	episodes, err := getEpisodesBySeason(handler.DB, providerShowID, season)
+func (handler *Handler) makeEpisodeKeyboard(ctx context.Context, providerShowID string, season int, extraRows ...[][]string) (*tgbotapi.InlineKeyboardMarkup, error) {
+	episodes, err := getEpisodesBySeason(ctx, handler.DB, handler.Provider.Name(), providerShowID, season)
 	if err != nil {
 		return nil, err
 	}
@@ -339,12 +948,13 @@ func (handler *Handler) makeEpisodeKeyboard(providerShowID string, season int) (
 
 		rows = append(rows, [][]string{{label, cbData}})
 	}
+	rows = append(rows, extraRows...)
 	rows = append(rows, [][]string{{"❌ Cancel", "cancel"}})
 	inlineMarkup := makeKeyboardMarkup(rows)
 	return inlineMarkup, nil
 }
 
-func (handler *Handler) handleEpisodeCallback(cb *tgbotapi.CallbackQuery, callbackParam string) error {
+func (handler *Handler) handleEpisodeCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
 	episodeNumber, err := strconv.Atoi(callbackParam)
 	if err != nil {
 		log.Printf("handleEpisodeCallback: invalid episode number: %s", callbackParam)
@@ -352,9 +962,30 @@ func (handler *Handler) handleEpisodeCallback(cb *tgbotapi.CallbackQuery, callba
 	}
 
 	userID := cb.From.ID
-	msg := cb.Message
-	chatID := msg.Chat.ID
+	userCtx := handler.Bot.getUserContext(userID)
+	if userCtx == nil {
+		handler.Bot.clearState(userID)
+		return NewUserError(
+			fmt.Errorf("session expired for user %d", userID),
+			"Session expired. Please start over with /add.",
+		)
+	}
 
+	if err := handler.setSelectedEpisode(ctx, userID, cb.Message.Chat.ID, cb.Message.MessageID, userCtx, userCtx.SelectedSeason, episodeNumber); err != nil {
+		return err
+	}
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// episodeInputRe matches an episode typed while the episode keyboard is showing:
+// "7" for the season on the keyboard, or "S02E07" and "2x7" for any season.
+var episodeInputRe = regexp.MustCompile(`(?i)^\s*(?:(\d+)|s(\d+)\s*e(\d+)|(\d+)x(\d+))\s*$`)
+
+// acceptEpisodeInput is the typed alternative to the season and episode keyboards,
+// handy for seasons too long to scroll through.
+func (handler *Handler) acceptEpisodeInput(ctx context.Context, msg *tgbotapi.Message) error {
+	userID := msg.From.ID
 	userCtx := handler.Bot.getUserContext(userID)
 	if userCtx == nil {
 		handler.Bot.clearState(userID)
@@ -364,13 +995,52 @@ func (handler *Handler) handleEpisodeCallback(cb *tgbotapi.CallbackQuery, callba
 		)
 	}
 
+	m := episodeInputRe.FindStringSubmatch(msg.Text)
+	if m == nil {
+		return NewUserError(
+			fmt.Errorf("invalid episode input %q from user %d", msg.Text, userID),
+			"Pick the episode on the keyboard, or send its number like 7 or S02E07.",
+		)
+	}
 	season := userCtx.SelectedSeason
+	var episodeNumber int
+	switch {
+	case m[1] != "":
+		episodeNumber, _ = strconv.Atoi(m[1])
+	case m[2] != "":
+		season, _ = strconv.Atoi(m[2])
+		episodeNumber, _ = strconv.Atoi(m[3])
+	default:
+		season, _ = strconv.Atoi(m[4])
+		episodeNumber, _ = strconv.Atoi(m[5])
+	}
+	if season == 0 {
+		return NewUserError(
+			fmt.Errorf("episode number %q from user %d without a season", msg.Text, userID),
+			"Pick a season first, or send the episode with its season like S02E07.",
+		)
+	}
+
+	// Unlike a button, a typed episode may not exist, so it's checked before the
+	// flow ends
+	if _, err := findEpisodeByNumber(ctx, handler.DB, handler.Provider.Name(), strconv.Itoa(userCtx.SelectedProviderID), season, episodeNumber); err != nil {
+		return NewUserError(
+			fmt.Errorf("finding typed episode S%02dE%02d of show %d: %w", season, episodeNumber, userCtx.SelectedProviderID, err),
+			fmt.Sprintf("I can't find S%02dE%02d, please send another episode.", season, episodeNumber),
+		)
+	}
+	return handler.setSelectedEpisode(ctx, userID, msg.Chat.ID, 0, userCtx, season, episodeNumber)
+}
 
+// setSelectedEpisode finishes the set-progress flow: it marks the show selected in
+// userCtx as watched up to the episode and schedules the reminder for the next one.
+// A zero messageID sends the result as a new message instead of editing one.
+func (handler *Handler) setSelectedEpisode(ctx context.Context, userID, chatID int64, messageID int, userCtx *UserContext, season, episodeNumber int) error {
 	var resultText string
 
 	// Find the current episode
 	currentEpisode, err := findEpisodeByNumber(
-		handler.DB, strconv.Itoa(userCtx.SelectedProviderID), season, episodeNumber,
+		ctx, handler.DB, handler.Provider.Name(), strconv.Itoa(userCtx.SelectedProviderID), season, episodeNumber,
 	)
 	if err != nil {
 		handler.Bot.clearState(userID)
@@ -382,24 +1052,50 @@ func (handler *Handler) handleEpisodeCallback(cb *tgbotapi.CallbackQuery, callba
 
 	// Find the next episode
 	nextEpisode, _ := findEpisodeByNumber(
-		handler.DB, strconv.Itoa(userCtx.SelectedProviderID), season, episodeNumber+1,
+		ctx, handler.DB, handler.Provider.Name(), strconv.Itoa(userCtx.SelectedProviderID), season, episodeNumber+1,
 	)
 
-	err = updateLastWatchedEpisode(handler.DB, userCtx.SelectedInternalID, currentEpisode.ID)
+	previousID, err := getLastWatchedEpisodeID(ctx, handler.DB, userCtx.SelectedInternalID)
+	if err != nil {
+		log.Printf("setSelectedEpisode: getting progress of show %d: %v", userCtx.SelectedInternalID, err)
+	}
+	var gapShowName string
+	err = updateLastWatchedEpisode(ctx, handler.DB, userCtx.SelectedInternalID, currentEpisode.ID, handler.Clock.Now())
 	if err != nil {
 		resultText = "Failed to update progress"
 	} else {
-		showName, err := getShowNameByID(handler.DB, userCtx.SelectedInternalID)
+		show, err := getShowByID(ctx, handler.DB, userCtx.SelectedInternalID)
+		if err == nil {
+			gapShowName = show.Name
+		}
 		if err != nil {
 			resultText = "Failed to get show name"
 		} else {
+			showName := show.Name
 			if nextEpisode == nil {
 				resultText = fmt.Sprintf("Marked \"%s\" as watched up to S%02dE%02d.", showName, season, episodeNumber)
+			} else if show.ReminderMode == ReminderModeSeason {
+				finale, err := rebuildShowReminder(ctx, handler.DB, userID, show.ID, chatID, updateThread(ctx, chatID), handler.Clock.Now())
+				if err != nil {
+					resultText = "Failed to create reminder"
+				} else if finale != nil {
+					resultText = fmt.Sprintf(
+						"Marked \"%s\" as watched up to S%02dE%02d. "+
+							"The season %d finale is expected to air on %s. I'll notify you when the season is complete.",
+						showName, season, episodeNumber, finale.Season, finale.AiredAtUTC.Format("Mon Jan 2, 15:04"),
+					)
+				} else {
+					resultText = fmt.Sprintf(
+						"Marked \"%s\" as watched up to S%02dE%02d. Season %d is already complete.",
+						showName, season, episodeNumber, nextEpisode.Season,
+					)
+				}
 			} else {
-				if !nextEpisode.AiredAtUTC.IsZero() && nextEpisode.AiredAtUTC.After(time.Now()) {
+				remindAt := releaseTime(nextEpisode.AiredAtUTC, show.ReleaseDelayHours)
+				if !nextEpisode.AiredAtUTC.IsZero() && remindAt.After(handler.Clock.Now()) {
 					err = createReminder(
-						handler.DB, userID, int(userCtx.SelectedInternalID), nextEpisode.ID,
-						nextEpisode.AiredAtUTC, msg.Chat.ID,
+						ctx, handler.DB, userID, int(userCtx.SelectedInternalID), nextEpisode.ID,
+						remindAt, chatID, updateThread(ctx, chatID),
 					)
 					if err != nil {
 						resultText = "Failed to create reminder"
@@ -421,17 +1117,19 @@ func (handler *Handler) handleEpisodeCallback(cb *tgbotapi.CallbackQuery, callba
 		}
 	}
 
-	handler.Bot.reply(chatID, resultText, ReplyOptions{EditMessageID: msg.MessageID})
+	handler.Bot.reply(ctx, chatID, resultText, ReplyOptions{EditMessageID: messageID})
 	handler.Bot.clearState(userID)
-	handler.Bot.answerCallbackQuery(cb.ID)
+	if gapShowName != "" {
+		handler.warnAboutGap(ctx, chatID, userCtx.SelectedInternalID, gapShowName, previousID, currentEpisode.ID)
+	}
 	return nil
 }
 
 // SHOWS/HISTORY command flow
 
-func (handler *Handler) handleShowsCommand(msg *tgbotapi.Message) error {
+func (handler *Handler) handleShowsCommand(ctx context.Context, msg *tgbotapi.Message) error {
 	chatID := msg.Chat.ID
-	shows, err := listCurrentShowsWithProgress(handler.DB, msg.From.ID)
+	shows, err := listCurrentShowsWithProgress(ctx, handler.DB, msg.From.ID)
 	if err != nil {
 		return NewUserError(
 			fmt.Errorf("listing current shows for user %d: %w", msg.From.ID, err),
@@ -439,20 +1137,39 @@ func (handler *Handler) handleShowsCommand(msg *tgbotapi.Message) error {
 		)
 	}
 	if len(shows) == 0 {
-		handler.Bot.reply(chatID, "You have no current shows. Use /add <show> to add one, or /history to see all shows.")
+		handler.Bot.reply(ctx, chatID, "You have no current shows. Use /add <show> to add one, or /history to see all shows.")
 		return nil
 	}
+	filter := showsFilter{Platforms: listPlatforms(shows), Tags: listShowTags(shows)}
+	if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+		// Tags come first, the user named them
+		if i := slices.IndexFunc(filter.Tags, func(t string) bool { return strings.EqualFold(t, arg) }); i != -1 {
+			filter.Tag = filter.Tags[i]
+		} else {
+			filter.Platform = arg
+		}
+		shows = filter.apply(shows)
+		if len(shows) == 0 {
+			handler.Bot.reply(ctx, chatID, fmt.Sprintf("You have no current shows on %s.", arg))
+			return nil
+		}
+		// The button spells it the way the show's data does
+		if i := slices.IndexFunc(filter.Platforms, func(p string) bool { return strings.EqualFold(p, arg) }); i != -1 && filter.Tag == "" {
+			filter.Platform = filter.Platforms[i]
+		}
+	}
 	handler.Bot.withUserContext(msg.From.ID, func(ctx *UserContext) {
 		ctx.ShowsList = shows
+		ctx.ShowsFilter = filter
 	})
-	inlineMarkup := handler.makeShowsKeyboard(shows, "current")
-	handler.Bot.reply(chatID, "Your current shows:", ReplyOptions{ReplyMarkup: inlineMarkup})
+	inlineMarkup := handler.makeCurrentShowsKeyboard(shows, filter)
+	handler.Bot.reply(ctx, chatID, currentShowsTitle(filter), ReplyOptions{ReplyMarkup: inlineMarkup})
 	return nil
 }
 
-func (handler *Handler) handleHistoryCommand(msg *tgbotapi.Message) error {
+func (handler *Handler) handleHistoryCommand(ctx context.Context, msg *tgbotapi.Message) error {
 	chatID := msg.Chat.ID
-	shows, err := listShowsWithProgress(handler.DB, msg.From.ID)
+	shows, err := listShowsWithProgress(ctx, handler.DB, msg.From.ID)
 	if err != nil {
 		return NewUserError(
 			fmt.Errorf("listing shows for user %d: %w", msg.From.ID, err),
@@ -460,50 +1177,70 @@ func (handler *Handler) handleHistoryCommand(msg *tgbotapi.Message) error {
 		)
 	}
 	if len(shows) == 0 {
-		handler.Bot.reply(chatID, "You have no shows yet. Use /add <show> to add one.")
+		handler.Bot.reply(ctx, chatID, "You have no shows yet. Use /add <show> to add one.")
 		return nil
 	}
 	handler.Bot.withUserContext(msg.From.ID, func(ctx *UserContext) {
 		ctx.ShowsList = shows
 	})
 	inlineMarkup := handler.makeShowsKeyboard(shows, "history")
-	handler.Bot.reply(chatID, "Your show history:", ReplyOptions{ReplyMarkup: inlineMarkup})
+	handler.Bot.reply(ctx, chatID, "Your show history:", ReplyOptions{ReplyMarkup: inlineMarkup})
 	return nil
 }
 
 func (handler *Handler) makeShowsKeyboard(shows []ShowProgress, listType string) *tgbotapi.InlineKeyboardMarkup {
 	var rows [][][]string
 	for i, show := range shows {
+		if show.DroppedAt.Valid && (i == 0 || !shows[i-1].DroppedAt.Valid) {
+			rows = append(rows, [][]string{{"🚫 Dropped", "noop:"}})
+		}
 		line := show.Name
-		if show.NotificationsEnabled && show.NextAirDate.Valid && show.NextAirDate.Time.After(time.Now()) {
+		if show.NotificationsEnabled && show.NextAirDate.Valid && show.NextAirDate.Time.After(handler.Clock.Now()) {
 			line = "🔔 " + line
 		}
 		if show.Season.Valid && show.Episode.Valid {
 			line += fmt.Sprintf(" (S%02dE%02d)", show.Season.Int32, show.Episode.Int32)
 		}
-		if show.NextEpisodeSeason.Valid && show.NextEpisodeNumber.Valid {
-			if show.NextAirDate.Valid && show.NextAirDate.Time.After(time.Now()) {
+		if show.ReminderMode == ReminderModeWatchlist && listType != "watchlist" {
+			line = "👀 " + line
+		}
+		if show.Pinned {
+			line = "📌 " + line
+		}
+		if show.Archived {
+			line = "🗄 " + line
+		}
+		if listType == "history" && show.Rating > 0 {
+			line += " " + formatRating(show.Rating)
+		}
+		if listType == "queue" && show.EpisodesWaiting == 0 {
+			line += " - nothing aired yet"
+		} else if listType == "queue" {
+			line += fmt.Sprintf(" - %d waiting", show.EpisodesWaiting)
+		} else if show.NextEpisodeSeason.Valid && show.NextEpisodeNumber.Valid {
+			if show.NextAirDate.Valid && show.NextAirDate.Time.After(handler.Clock.Now()) {
 				line += fmt.Sprintf(" - Next Ep %s", show.NextAirDate.Time.Format("Jan 2 (Mon)"))
 			} else {
 				line += " - Next Ep Out ✅"
 			}
 		}
-		cbData := fmt.Sprintf("selectShow:%d:%s", i, listType)
+		cbData := fmt.Sprintf("selectShow:%d:%s", show.InternalID, listType)
 		rows = append(rows, [][]string{{line, cbData}})
 	}
+	rows = append(rows, [][]string{{"▶️ What's next?", "whatsNext:"}})
 
 	return makeKeyboardMarkup(rows)
 }
 
-func (handler *Handler) handleSelectShowCallback(cb *tgbotapi.CallbackQuery, callbackParam string) error {
-	showIdxStr, listType, found := strings.Cut(callbackParam, ":")
+func (handler *Handler) handleSelectShowCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showIDStr, listType, found := strings.Cut(callbackParam, ":")
 	if !found {
 		log.Printf("handleSelectShowCallback: invalid callback parameter: %s", callbackParam)
 		return nil
 	}
-	showIdx, err := strconv.Atoi(showIdxStr)
+	showID, err := strconv.Atoi(showIDStr)
 	if err != nil {
-		log.Printf("handleSelectShowCallback: invalid show index: %s", showIdxStr)
+		log.Printf("handleSelectShowCallback: invalid show ID: %s", showIDStr)
 		return nil
 	}
 
@@ -511,217 +1248,514 @@ func (handler *Handler) handleSelectShowCallback(cb *tgbotapi.CallbackQuery, cal
 	msg := cb.Message
 	chatID := msg.Chat.ID
 
-	show, err := handler.validateAndGetShow(userID, chatID, showIdx, listType)
+	show, err := handler.validateAndGetShow(ctx, userID, chatID, showID, listType)
 	if err != nil {
 		return err
 	}
 
 	var infoText string
-	infoText += fmt.Sprintf("<b>%s</b>\n\n", show.Name)
-	if show.Season.Valid && show.Episode.Valid {
-		infoText += fmt.Sprintf("Current episode: S%02dE%02d\n", show.Season.Int32, show.Episode.Int32)
-	} else {
-		infoText += "Current episode: Not set\n"
+	if show.PosterURL != "" {
+		// The card is edited in place, which can't turn it into a photo message, so the
+		// poster is attached as the link preview of an invisible link instead.
+		infoText += fmt.Sprintf("<a href=\"%s\">\u200b</a>", html.EscapeString(show.PosterURL))
 	}
-	if show.NextAirDate.Valid {
-		infoText += fmt.Sprintf(
-			"Next episode air date: %s\n",
-			show.NextAirDate.Time.Format("Mon Jan 2, 15:04"),
-		)
+	infoText += fmt.Sprintf("<b>%s</b>\n", html.EscapeString(show.Name))
+	if show.Network != "" {
+		infoText += fmt.Sprintf("Network: %s\n", html.EscapeString(show.Network))
+	}
+	if show.WebChannel != "" && !strings.EqualFold(show.WebChannel, show.Network) {
+		infoText += fmt.Sprintf("Streaming: %s\n", html.EscapeString(show.WebChannel))
+	}
+	if show.Note != "" {
+		infoText += fmt.Sprintf("📝 <i>%s</i>\n", html.EscapeString(show.Note))
+	}
+	if len(show.Tags) > 0 {
+		infoText += fmt.Sprintf("🏷 %s\n", html.EscapeString(strings.Join(show.Tags, ", ")))
+	}
+	if show.Archived {
+		infoText += "🗄 Archived, only listed in /history\n"
+	}
+	if show.DroppedAt.Valid {
+		infoText += formatDropped(show)
+	}
+	infoText += "\n"
+	release, err := getShowByID(ctx, handler.DB, show.InternalID)
+	if err != nil {
+		log.Printf("handleSelectShowCallback: getting show %d: %v", show.InternalID, err)
+		release = &DBShow{}
+	}
+	watchlisted := show.ReminderMode == ReminderModeWatchlist
+	if watchlisted {
+		infoText += "👀 On your watchlist\n"
+		infoText += handler.formatPremiere(ctx, show.Provider, show.ProviderShowID)
 	} else {
-		infoText += "Next episode air date: N/A\n"
+		if show.Season.Valid && show.Episode.Valid {
+			infoText += fmt.Sprintf("Current episode: S%02dE%02d\n", show.Season.Int32, show.Episode.Int32)
+		} else {
+			infoText += "Current episode: Not set\n"
+		}
+		if show.NextEpisodeSeason.Valid && show.NextEpisodeNumber.Valid {
+			infoText += fmt.Sprintf(
+				"Next episode: S%02dE%02d \"%s\"\n",
+				show.NextEpisodeSeason.Int32, show.NextEpisodeNumber.Int32, html.EscapeString(show.NextEpisodeTitle),
+			)
+		}
+		if show.NextAirDate.Valid {
+			releaseAt := releaseTime(show.NextAirDate.Time, release.ReleaseDelayHours)
+			airDate := releaseAt.Format("Mon Jan 2, 15:04")
+			if untilAir := releaseAt.Sub(handler.Clock.Now()); untilAir > 0 {
+				airDate += fmt.Sprintf(" (airs in %s)", formatCountdown(untilAir))
+			}
+			infoText += fmt.Sprintf("Next episode air date: %s\n", airDate)
+		} else {
+			infoText += "Next episode air date: N/A\n"
+		}
+		if show.EpisodesWaiting > 0 {
+			infoText += fmt.Sprintf("Episodes waiting: %d\n", show.EpisodesWaiting)
+		}
+	}
+	if show.Rating > 0 {
+		infoText += fmt.Sprintf("Your rating: %s\n", formatRating(show.Rating))
+	}
+	settings, err := getUserSettings(ctx, handler.DB, userID)
+	if err != nil {
+		log.Printf("handleSelectShowCallback: getting settings for user %d: %v", userID, err)
+	} else if settings.ShowSummaries && show.NextEpisodeSummary != "" {
+		infoText += "\n" + spoiler(trimString(show.NextEpisodeSummary, 500)) + "\n\n"
+	}
+	watchOptions, err := listWatchOptions(ctx, handler.DB, show.Provider, show.ProviderShowID)
+	if err != nil {
+		log.Printf("handleSelectShowCallback: listing watch options for show %d: %v", show.InternalID, err)
+	} else if settings != nil {
+		if services := watchServices(watchOptions, settings.Country); len(services) > 0 {
+			infoText += fmt.Sprintf("Where to watch: %s\n", html.EscapeString(strings.Join(services, ", ")))
+		}
 	}
 	notificationsStatus := "Enabled"
 	if !show.NotificationsEnabled {
 		notificationsStatus = "Disabled"
 	}
 	infoText += fmt.Sprintf("Notifications: %s\n", notificationsStatus)
+	muted := show.MutedUntil.Valid && show.MutedUntil.Time.After(handler.Clock.Now())
+	if muted {
+		loc := time.UTC
+		if settings != nil {
+			loc = settings.Location()
+		}
+		infoText += fmt.Sprintf("🔇 Muted until %s\n", formatMuteDate(show.MutedUntil.Time, loc))
+	}
+	if show.ReminderMode == ReminderModeSeason {
+		infoText += "Reminders: when the season is complete\n"
+	}
+	if show.Silent {
+		infoText += "🔕 Reminders arrive silently\n"
+	}
+	if show.ReminderTemplate != "" {
+		infoText += fmt.Sprintf("✏️ Reminder text: %s\n", html.EscapeString(show.ReminderTemplate))
+	}
+	if release.ReleaseService != "" {
+		infoText += fmt.Sprintf(
+			"Release schedule: %s\n",
+			html.EscapeString(describeRelease(release.Network, release.ReleaseService, release.ReleaseDelayHours)),
+		)
+	}
 
 	var rows [][][]string
 	toggleText := "Disable Notifications"
 	if !show.NotificationsEnabled {
 		toggleText = "Enable Notifications"
 	}
-	rows = append(rows, [][]string{{toggleText, fmt.Sprintf("toggleNotifications:%d:%s", showIdx, listType)}})
-	rows = append(rows, [][]string{{"Mark next as watched", fmt.Sprintf("markNextWatched:%d:%s", showIdx, listType)}})
+	rows = append(rows, [][]string{{toggleText, fmt.Sprintf("toggleNotifications:%d:%s", showID, listType)}})
+	if watchlisted {
+		rows = append(rows, [][]string{{"▶️ Start tracking", fmt.Sprintf("startTracking:%d:%s", showID, listType)}})
+	} else {
+		rows = append(rows, [][]string{{"Mark next as watched", fmt.Sprintf("markNextWatched:%d:%s", showID, listType)}})
+		if show.EpisodesWaiting > 0 {
+			rows = append(rows, [][]string{{"✅ Mark all caught up", fmt.Sprintf("markCaughtUp:%d:%s", showID, listType)}})
+		}
+		rows = append(rows, [][]string{{"📖 Browse episodes", fmt.Sprintf("browseShow:%d:%s:0", showID, listType)}})
+		bingeText := "Binge mode: notify when season is complete"
+		if show.ReminderMode == ReminderModeSeason {
+			bingeText = "Notify about every episode"
+		}
+		rows = append(rows, [][]string{{bingeText, fmt.Sprintf("toggleReminderMode:%d:%s", showID, listType)}})
+		rows = append(rows, [][]string{{"🗓 Release schedule", fmt.Sprintf("releaseSchedule:%d:%s", showID, listType)}})
+		rows = append(rows, [][]string{{"🔄 Refresh data", fmt.Sprintf("refreshShow:%d:%s", showID, listType)}})
+		rows = append(rows, [][]string{{"📣 Also notify in…", fmt.Sprintf("notifyChats:%d:%s", showID, listType)}})
+		rows = append(rows, [][]string{{"✏️ Reminder text", fmt.Sprintf("editTemplate:%d:%s", showID, listType)}})
+		rows = append(rows, [][]string{{"⏰ Extra reminders", fmt.Sprintf("reminderOffsets:%d:%s", showID, listType)}})
+	}
+	if muted {
+		rows = append(rows, [][]string{{"🔔 Unmute", fmt.Sprintf("setMute:%d:%s:0", showID, listType)}})
+	} else {
+		rows = append(rows, [][]string{{"🔇 Mute until…", fmt.Sprintf("muteShow:%d:%s", showID, listType)}})
+	}
+	silentText := "🔕 Silent reminders"
+	if show.Silent {
+		silentText = "🔔 Reminders with sound"
+	}
+	rows = append(rows, [][]string{{silentText, fmt.Sprintf("toggleSilent:%d:%s", showID, listType)}})
+	pinText := "📌 Pin"
+	if show.Pinned {
+		pinText = "Unpin"
+	}
+	rows = append(rows, [][]string{
+		{pinText, fmt.Sprintf("togglePinned:%d:%s", showID, listType)},
+		{"🔗 Share", fmt.Sprintf("shareShow:%d:%s", showID, listType)},
+	})
+	// The queue has its own order, so moving shows there would do nothing visible
+	if listType != "queue" {
+		shows := handler.Bot.getUserContext(userID).ShowsList
+		idx := findShowIndex(shows, show.InternalID)
+		var moveRow [][]string
+		if showNeighbor(shows, idx, -1) != -1 {
+			moveRow = append(moveRow, []string{"⬆️ Move up", fmt.Sprintf("moveShow:%d:%s:up", showID, listType)})
+		}
+		if showNeighbor(shows, idx, 1) != -1 {
+			moveRow = append(moveRow, []string{"⬇️ Move down", fmt.Sprintf("moveShow:%d:%s:down", showID, listType)})
+		}
+		if len(moveRow) > 0 {
+			rows = append(rows, moveRow)
+		}
+	}
+	if show.Note == "" {
+		rows = append(rows, [][]string{{"📝 Add note", fmt.Sprintf("editNote:%d:%s", showID, listType)}})
+	} else {
+		rows = append(rows, [][]string{
+			{"📝 Edit note", fmt.Sprintf("editNote:%d:%s", showID, listType)},
+			{"Remove note", fmt.Sprintf("clearNote:%d:%s", showID, listType)},
+		})
+	}
+	rows = append(rows, [][]string{{"🏷 Tags", fmt.Sprintf("editTags:%d:%s", showID, listType)}})
+	archiveText := "🗄 Archive"
+	if show.Archived {
+		archiveText = "📤 Unarchive"
+	}
+	rows = append(rows, [][]string{{archiveText, fmt.Sprintf("toggleArchived:%d:%s", showID, listType)}})
+	if show.DroppedAt.Valid {
+		rows = append(rows, [][]string{{"↩️ Resume", fmt.Sprintf("resumeShow:%d:%s", showID, listType)}})
+	} else {
+		rows = append(rows, [][]string{{"🚫 Drop…", fmt.Sprintf("dropShow:%d:%s", showID, listType)}})
+	}
+	rows = append(rows, [][]string{{"🗑 Delete", fmt.Sprintf("deleteShow:%d:%s", showID, listType)}})
 	rows = append(rows, [][]string{{"<< Back to shows list", fmt.Sprintf("backToShows:%s", listType)}})
 	keyboard := makeKeyboardMarkup(rows)
 
-	handler.Bot.reply(
+	handler.Bot.reply(ctx,
 		msg.Chat.ID, infoText, ReplyOptions{ReplyMarkup: keyboard, ParseMode: "HTML", EditMessageID: msg.MessageID})
 
 	handler.Bot.answerCallbackQuery(cb.ID)
 	return nil
 }
 
-func (handler *Handler) validateAndGetShow(userID int64, chatID int64, showIdx int, listType string) (*ShowProgress, error) {
-	userCtx := handler.Bot.getUserContext(userID)
-	if userCtx == nil || len(userCtx.ShowsList) == 0 {
-		handler.Bot.clearState(userID)
-		if listType == "current" {
-			return nil, NewUserError(
-				fmt.Errorf("no shows in context for user %d", userID),
-				"No shows found. Please start over with /shows",
-			)
+// validateAndGetShow returns the user's show with the internal ID showID from the
+// list a menu was opened from. Menus carry show IDs rather than list positions, so
+// a stale menu can't act on whichever show took the position since. A show the
+// list in memory doesn't have, like after a restart, is looked up in the list
+// reloaded from the user's own shows, so other users' IDs are never found.
+func (handler *Handler) validateAndGetShow(ctx context.Context, userID int64, chatID int64, showID int, listType string) (*ShowProgress, error) {
+	if userCtx := handler.Bot.getUserContext(userID); userCtx != nil {
+		if i := findShowIndex(userCtx.ShowsList, int64(showID)); i != -1 {
+			return &userCtx.ShowsList[i], nil
 		}
+	}
+
+	shows, err := handler.listShowsByType(ctx, userID, listType)
+	if err != nil {
 		return nil, NewUserError(
-			fmt.Errorf("no shows in context for user %d", userID),
-			"No shows found. Please start over with /history",
+			fmt.Errorf("loading shows list for user %d: %w", userID, err),
+			"Error loading your shows",
 		)
 	}
-	if showIdx < 0 || showIdx >= len(userCtx.ShowsList) {
+	i := findShowIndex(shows, int64(showID))
+	if i == -1 {
+		handler.Bot.clearState(userID)
+		command := "history"
+		switch listType {
+		case "current":
+			command = "shows"
+		case "queue":
+			command = "queue"
+		case "watchlist":
+			command = "watchlist"
+		case "tagged":
+			command = "tags"
+		}
 		return nil, NewUserError(
-			fmt.Errorf("invalid show index %d for user %d", showIdx, userID),
-			"Invalid show selection.",
+			fmt.Errorf("show %d not in the %s list of user %d", showID, listType, userID),
+			"No shows found. Please start over with /"+command,
 		)
 	}
-	return &userCtx.ShowsList[showIdx], nil
+	var show *ShowProgress
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.ShowsList = shows
+		show = &ctx.ShowsList[i]
+	})
+	return show, nil
 }
 
-func findShowIndex(shows []ShowProgress, show ShowProgress) int {
+func findShowIndex(shows []ShowProgress, showID int64) int {
 	for i, s := range shows {
-		if s.InternalID == show.InternalID {
+		if s.InternalID == showID {
 			return i
 		}
 	}
 	return -1
 }
 
-func (handler *Handler) handleToggleNotificationsCallback(cb *tgbotapi.CallbackQuery, callbackParam string) error {
-	showIdxStr, listType, found := strings.Cut(callbackParam, ":")
+func (handler *Handler) listShowsByType(ctx context.Context, userID int64, listType string) ([]ShowProgress, error) {
+	switch listType {
+	case "current":
+		shows, err := listCurrentShowsWithProgress(ctx, handler.DB, userID)
+		if err != nil {
+			return nil, err
+		}
+		if userCtx := handler.Bot.getUserContext(userID); userCtx != nil {
+			shows = userCtx.ShowsFilter.apply(shows)
+		}
+		return shows, nil
+	case "queue":
+		return listQueue(ctx, handler.DB, userID, handler.Clock.Now())
+	case "watchlist":
+		return listWatchlist(ctx, handler.DB, userID)
+	case "tagged":
+		shows, err := listShowsWithProgress(ctx, handler.DB, userID)
+		if err != nil {
+			return nil, err
+		}
+		tag := ""
+		if userCtx := handler.Bot.getUserContext(userID); userCtx != nil {
+			tag = userCtx.ListTag
+		}
+		return filterShowsByTag(shows, tag), nil
+	default:
+		return listShowsWithProgress(ctx, handler.DB, userID)
+	}
+}
+
+// refreshShowDetail reloads the user's list after a change to show and re-renders its
+// detail card. Shows that dropped out of a filtered list are looked up in the history.
+func (handler *Handler) refreshShowDetail(ctx context.Context, cb *tgbotapi.CallbackQuery, show *ShowProgress, listType string) error {
+	show, listType, err := handler.reloadShowsList(ctx, cb.From.ID, show, listType)
+	if err != nil {
+		return err
+	}
+	return handler.handleSelectShowCallback(ctx, cb, fmt.Sprintf("%d:%s", show.InternalID, listType))
+}
+
+// reloadShowsList reloads the user's list after a change to show and returns the
+// show as reloaded, and the list it's in, which is the history when the show
+// dropped out of a filtered list.
+func (handler *Handler) reloadShowsList(ctx context.Context, userID int64, show *ShowProgress, listType string) (*ShowProgress, string, error) {
+	shows, err := handler.listShowsByType(ctx, userID, listType)
+	if err != nil {
+		return nil, "", NewUserError(
+			fmt.Errorf("refreshing shows list for user %d: %w", userID, err),
+			"Error refreshing shows list",
+		)
+	}
+
+	newIdx := findShowIndex(shows, show.InternalID)
+	if newIdx == -1 && listType != "history" {
+		listType = "history"
+		shows, err = listShowsWithProgress(ctx, handler.DB, userID)
+		if err != nil {
+			return nil, "", NewUserError(
+				fmt.Errorf("refreshing shows list for user %d: %w", userID, err),
+				"Error refreshing shows list",
+			)
+		}
+		newIdx = findShowIndex(shows, show.InternalID)
+	}
+	if newIdx == -1 {
+		return nil, "", NewUserError(
+			fmt.Errorf("show %d not found in refreshed list for user %d", show.InternalID, userID),
+			"Error refreshing shows list",
+		)
+	}
+
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.ShowsList = shows
+		show = &ctx.ShowsList[newIdx]
+	})
+	return show, listType, nil
+}
+
+func (handler *Handler) handleToggleNotificationsCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showIDStr, listType, found := strings.Cut(callbackParam, ":")
 	if !found {
 		log.Printf("handleToggleNotificationsCallback: invalid callback parameter: %s", callbackParam)
 		return nil
 	}
-	showIdx, err := strconv.Atoi(showIdxStr)
+	showID, err := strconv.Atoi(showIDStr)
 	if err != nil {
-		log.Printf("handleToggleNotificationsCallback: invalid show index: %s", showIdxStr)
+		log.Printf("handleToggleNotificationsCallback: invalid show ID: %s", showIDStr)
 		return nil
 	}
 
 	userID := cb.From.ID
 	msg := cb.Message
 
-	show, err := handler.validateAndGetShow(userID, msg.Chat.ID, showIdx, listType)
+	show, err := handler.validateAndGetShow(ctx, userID, msg.Chat.ID, showID, listType)
 	if err != nil {
 		return err
 	}
 
-	showID, _, err := getShowByUserAndName(handler.DB, userID, show.Name)
+	err = toggleShowNotifications(ctx, handler.DB, show.InternalID)
 	if err != nil {
 		return NewUserError(
-			fmt.Errorf("getting show %q for user %d: %w", show.Name, userID, err),
+			fmt.Errorf("toggling notifications for show %d: %w", show.InternalID, err),
 			"Error toggling notifications",
 		)
 	}
 
-	err = toggleShowNotifications(handler.DB, showID)
+	return handler.refreshShowDetail(ctx, cb, show, listType)
+}
+
+func (handler *Handler) handleToggleReminderModeCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showIDStr, listType, found := strings.Cut(callbackParam, ":")
+	if !found {
+		log.Printf("handleToggleReminderModeCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	showID, err := strconv.Atoi(showIDStr)
+	if err != nil {
+		log.Printf("handleToggleReminderModeCallback: invalid show ID: %s", showIDStr)
+		return nil
+	}
+
+	userID := cb.From.ID
+	msg := cb.Message
+
+	show, err := handler.validateAndGetShow(ctx, userID, msg.Chat.ID, showID, listType)
 	if err != nil {
-		return NewUserError(
-			fmt.Errorf("toggling notifications for show %d: %w", showID, err),
-			"Error toggling notifications",
-		)
+		return err
 	}
 
-	var shows []ShowProgress
-	if listType == "current" {
-		shows, err = listCurrentShowsWithProgress(handler.DB, userID)
-	} else {
-		shows, err = listShowsWithProgress(handler.DB, userID)
+	mode := ReminderModeSeason
+	if show.ReminderMode == ReminderModeSeason {
+		mode = ReminderModeEpisode
 	}
-	if err != nil {
+	if err := setShowReminderMode(ctx, handler.DB, show.InternalID, mode); err != nil {
 		return NewUserError(
-			fmt.Errorf("refreshing shows list for user %d: %w", userID, err),
-			"Error refreshing shows list",
+			fmt.Errorf("setting reminder mode %q for show %d: %w", mode, show.InternalID, err),
+			"Error changing reminder mode",
 		)
 	}
-	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
-		ctx.ShowsList = shows
-	})
-
-	newIdx := findShowIndex(shows, *show)
-	if newIdx == -1 {
+	if _, err := rebuildShowReminder(ctx, handler.DB, userID, show.InternalID, msg.Chat.ID, updateThread(ctx, msg.Chat.ID), handler.Clock.Now()); err != nil {
 		return NewUserError(
-			fmt.Errorf("show %d not found in refreshed list for user %d", show.InternalID, userID),
-			"Error refreshing shows list",
+			fmt.Errorf("rebuilding reminder for show %d: %w", show.InternalID, err),
+			"Error updating reminder",
 		)
 	}
 
-	return handler.handleSelectShowCallback(cb, fmt.Sprintf("%d:%s", newIdx, listType))
+	return handler.refreshShowDetail(ctx, cb, show, listType)
 }
 
-func (handler *Handler) handleMarkNextWatchedCallback(cb *tgbotapi.CallbackQuery, callbackParam string) error {
-	showIdxStr, listType, found := strings.Cut(callbackParam, ":")
+func (handler *Handler) handleMarkNextWatchedCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showIDStr, listType, found := strings.Cut(callbackParam, ":")
 	if !found {
 		log.Printf("handleMarkNextWatchedCallback: invalid callback parameter: %s", callbackParam)
 		return nil
 	}
-	showIdx, err := strconv.Atoi(showIdxStr)
+	showID, err := strconv.Atoi(showIDStr)
 	if err != nil {
-		log.Printf("handleMarkNextWatchedCallback: invalid show index: %s", showIdxStr)
+		log.Printf("handleMarkNextWatchedCallback: invalid show ID: %s", showIDStr)
 		return nil
 	}
 
 	userID := cb.From.ID
 	msg := cb.Message
 
-	show, err := handler.validateAndGetShow(userID, msg.Chat.ID, showIdx, listType)
+	show, err := handler.validateAndGetShow(ctx, userID, msg.Chat.ID, showID, listType)
 	if err != nil {
 		return err
 	}
 
-	showID, providerShowID, err := getShowByUserAndName(handler.DB, userID, show.Name)
+	nextEpisode, err := findNextEpisode(ctx, handler.DB, show.Provider, show.ProviderShowID, show.Season, show.Episode)
 	if err != nil {
 		return NewUserError(
-			fmt.Errorf("getting show %q for user %d: %w", show.Name, userID, err),
-			"Error finding show",
+			fmt.Errorf("finding next episode for show %s: %w", show.ProviderShowID, err),
+			"No next episode found.",
 		)
 	}
 
-	nextEpisode, err := findNextEpisode(handler.DB, providerShowID, show.Season, show.Episode)
+	err = updateLastWatchedEpisode(ctx, handler.DB, show.InternalID, nextEpisode.ID, handler.Clock.Now())
 	if err != nil {
 		return NewUserError(
-			fmt.Errorf("finding next episode for show %s: %w", providerShowID, err),
-			"No next episode found.",
+			fmt.Errorf("updating last watched episode for show %d: %w", show.InternalID, err),
+			"Error updating progress",
 		)
 	}
 
-	err = updateLastWatchedEpisode(handler.DB, showID, nextEpisode.ID)
+	if err := handler.refreshShowDetail(ctx, cb, show, listType); err != nil {
+		return err
+	}
+	handler.offerSeasonRating(ctx, msg.Chat.ID, show.InternalID, show.Name, nextEpisode)
+	return nil
+}
+
+func (handler *Handler) handleMarkCaughtUpCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showIDStr, listType, found := strings.Cut(callbackParam, ":")
+	if !found {
+		log.Printf("handleMarkCaughtUpCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	showID, err := strconv.Atoi(showIDStr)
+	if err != nil {
+		log.Printf("handleMarkCaughtUpCallback: invalid show ID: %s", showIDStr)
+		return nil
+	}
+
+	userID := cb.From.ID
+	msg := cb.Message
+
+	show, err := handler.validateAndGetShow(ctx, userID, msg.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	dbShow, err := getShowByID(ctx, handler.DB, show.InternalID)
 	if err != nil {
 		return NewUserError(
-			fmt.Errorf("updating last watched episode for show %d: %w", showID, err),
-			"Error updating progress",
+			fmt.Errorf("getting show %d: %w", show.InternalID, err),
+			"Error finding show",
 		)
 	}
 
-	var shows []ShowProgress
-	if listType == "current" {
-		shows, err = listCurrentShowsWithProgress(handler.DB, userID)
-	} else {
-		shows, err = listShowsWithProgress(handler.DB, userID)
-	}
+	latest, err := findLatestAiredEpisode(ctx, handler.DB, dbShow.Provider, dbShow.ProviderShowID)
 	if err != nil {
 		return NewUserError(
-			fmt.Errorf("refreshing shows list for user %d: %w", userID, err),
-			"Error refreshing shows list",
+			fmt.Errorf("finding latest aired episode for show %s: %w", dbShow.ProviderShowID, err),
+			"No aired episodes found.",
 		)
 	}
-	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
-		ctx.ShowsList = shows
-	})
 
-	newIdx := findShowIndex(shows, *show)
-	if newIdx == -1 {
+	if err := updateLastWatchedEpisode(ctx, handler.DB, dbShow.ID, latest.ID, handler.Clock.Now()); err != nil {
 		return NewUserError(
-			fmt.Errorf("show %d not found in refreshed list for user %d", show.InternalID, userID),
-			"Error refreshing shows list",
+			fmt.Errorf("updating last watched episode for show %d: %w", dbShow.ID, err),
+			"Error updating progress",
+		)
+	}
+	// Caught up includes the episodes kept in the backlog
+	if err := dropSkippedEpisodes(ctx, handler.DB, dbShow.ID, true); err != nil {
+		log.Printf("handleMarkCaughtUpCallback: dropping skipped episodes of show %d: %v", dbShow.ID, err)
+	}
+	if _, err := rebuildShowReminder(ctx, handler.DB, userID, dbShow.ID, msg.Chat.ID, updateThread(ctx, msg.Chat.ID), handler.Clock.Now()); err != nil {
+		return NewUserError(
+			fmt.Errorf("rebuilding reminder for show %d: %w", dbShow.ID, err),
+			"Error updating reminder",
 		)
 	}
 
-	return handler.handleSelectShowCallback(cb, fmt.Sprintf("%d:%s", newIdx, listType))
+	if err := handler.refreshShowDetail(ctx, cb, show, listType); err != nil {
+		return err
+	}
+	handler.offerSeasonRating(ctx, msg.Chat.ID, dbShow.ID, dbShow.Name, latest)
+	return nil
 }
 
-func (handler *Handler) handleBackToShowsCallback(cb *tgbotapi.CallbackQuery, callbackParam string) error {
+func (handler *Handler) handleBackToShowsCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
 	listType := callbackParam
 
 	userID := cb.From.ID
@@ -740,25 +1774,33 @@ func (handler *Handler) handleBackToShowsCallback(cb *tgbotapi.CallbackQuery, ca
 	inlineMarkup := handler.makeShowsKeyboard(shows, listType)
 
 	text := "Your shows:"
-	if listType == "current" {
-		text = "Your current shows:"
-	} else {
+	switch listType {
+	case "current":
+		text = currentShowsTitle(userCtx.ShowsFilter)
+		inlineMarkup = handler.makeCurrentShowsKeyboard(shows, userCtx.ShowsFilter)
+	case "queue":
+		text = queueTitle
+	case "watchlist":
+		text = watchlistTitle
+	case "tagged":
+		text = taggedShowsTitle(userCtx.ListTag)
+	default:
 		text = "Your show history:"
 	}
 
-	handler.Bot.reply(msg.Chat.ID, text, ReplyOptions{ReplyMarkup: inlineMarkup, EditMessageID: msg.MessageID})
+	handler.Bot.reply(ctx, msg.Chat.ID, text, ReplyOptions{ReplyMarkup: inlineMarkup, EditMessageID: msg.MessageID})
 	handler.Bot.answerCallbackQuery(cb.ID)
 	return nil
 }
 
 // CANCEL callback
 
-func (handler *Handler) handleCancelCallback(cb *tgbotapi.CallbackQuery) error {
+func (handler *Handler) handleCancelCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) error {
 	userID := cb.From.ID
 	msg := cb.Message
 
 	handler.Bot.clearState(userID)
-	handler.Bot.reply(msg.Chat.ID, "Operation cancelled.", ReplyOptions{EditMessageID: msg.MessageID})
+	handler.Bot.reply(ctx, msg.Chat.ID, "Operation cancelled.", ReplyOptions{EditMessageID: msg.MessageID})
 
 	cb_response := tgbotapi.NewCallback(cb.ID, "")
 	handler.Bot.BotApi.Request(cb_response)
@@ -767,29 +1809,85 @@ func (handler *Handler) handleCancelCallback(cb *tgbotapi.CallbackQuery) error {
 
 // START/HELP commands
 
-func (handler *Handler) handleStartCommand(msg *tgbotapi.Message) error {
+func (handler *Handler) handleStartCommand(ctx context.Context, msg *tgbotapi.Message) error {
 	chatID := msg.Chat.ID
+	payload := msg.CommandArguments()
+	if strings.HasPrefix(payload, sharePayloadPrefix) {
+		return handler.handleSharedShowStart(ctx, msg, payload)
+	}
+	newUser, err := isNewUser(ctx, handler.DB, msg.From.ID)
+	if err != nil {
+		log.Printf("handleStartCommand: checking user %d: %v", msg.From.ID, err)
+	}
+	if newUser {
+		return handler.startOnboarding(ctx, msg, payload)
+	}
 	startText := dedent(`
 	Hello! I'm a bot that helps you track your TV shows and notify you when new episodes air.
 
 	/add - Add a TV show to track
 	/shows - List your current shows
 	/history - List all your shows
+	/queue - What to watch next
 	`)
-	handler.Bot.reply(chatID, startText)
+	handler.Bot.reply(ctx, chatID, startText)
 	return nil
 }
 
-func (handler *Handler) handleHelpCommand(msg *tgbotapi.Message) error {
+func (handler *Handler) handleHelpCommand(ctx context.Context, msg *tgbotapi.Message) error {
 	chatID := msg.Chat.ID
 	helpText := dedent(`
 	Commands:
 
-	/add <show>
-	/shows - list your current shows
+	/add <show> [year:2005] [lang:en] [type:animation] [country:us]
+	/search <show> - look a show up without adding it
+	/shows [platform|tag] - list your current shows, like /shows netflix
 	/history - list all your shows
+	/queue - what to watch next, by priority
+	/pick - what to watch tonight, short and nearly finished shows first
+	/trending [on|off] - what other people track, counting you if you opt in
+	/watchlist - shows you follow without tracking episodes
+	/tags - your tags, lists of shows you tag from their card
+	/next [show] - when the next episode of a show, or of any of your shows, airs
+	/upcoming - episodes and movies coming out soon
+	/feed - RSS feed of your episodes for feed readers
+	/dashboard - your shows and settings on the web
+	/addmovie <title> - track a movie's release
+	/movies - your movies
+	/backlog - aired episodes you haven't watched yet
+	/reminders - see, cancel or reschedule your reminders
+	/timezone <name> - set your time zone
+	/quiet HH:MM-HH:MM|off - don't send reminders at night
+	/summaries on|off - episode summaries (hidden as spoilers)
+	/links on|reddit|off - episode links under reminders
+	/country <code> - your country, for where shows stream
+	/airalerts on|off - tell me when an episode is rescheduled
+	/hype on|off - tell me a week before a season premieres
+	/template <text>|off - write your own reminder text
+	/checkin <hours>|off - ask whether you watched an episode after it airs
+	/autoadvance on|off - mark episodes watched once I remind you
+	/autobackup on|off - monthly backup of your data
+	/webhook <url>|off - post reminders and progress to your own server
+	/trakt connect|off - sync watched episodes with Trakt
+	/discord <webhook URL>|only|mirror|off - get reminders in a Discord channel
+	/digest daily|weekly|off - get a digest of upcoming episodes
+	/email <address> - get your digest by email
+	/tonight on|HH|off - every evening, what airs tonight
+	/settings - all your settings at a glance
+	/export - download your data as a file
+	/import - restore data from an export file
+	/watchparty <show> - watch a show together in a group
+	/groupsettings - who can manage a group's watch parties
+	/invite - invite friends to the bot
+	/stats - your ratings and other numbers
+	/recap [year] - what you watched in a year
+	/trash - restore shows you deleted
+	/merge - merge shows you added twice
+	/cancel - stop what you're in the middle of
 	/help - show this help
+
+	You can also just tell me what you watched, like "watched severance s2e4" or "finished the bear season 3", or react 👍 to a reminder.
 	`)
-	handler.Bot.reply(chatID, helpText)
+	handler.Bot.reply(ctx, chatID, helpText)
 	return nil
 }
@@ -0,0 +1,445 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/telegram"
+)
+
+// testShows returns the shows served by the fake TVmaze. "Night Shift" has two
+// seasons with the third episode of season 2 still to air; "Solo" has one season.
+func testShows() []fakeShow {
+	now := time.Now()
+	nightShift := fakeShow{ShowSearchResult: ShowSearchResult{
+		ID: 1, Name: "Night Shift", Network: &Network{ID: 1, Name: "NBC"},
+	}}
+	nightShift.Episodes = append(
+		makeEpisodes(1, 1, 3, now.AddDate(0, -3, 0)),
+		makeEpisodes(1, 2, 3, now.AddDate(0, 0, -10))...,
+	)
+	solo := fakeShow{ShowSearchResult: ShowSearchResult{ID: 2, Name: "Solo"}}
+	solo.Episodes = makeEpisodes(2, 1, 2, now.AddDate(0, 0, -3))
+	return []fakeShow{nightShift, solo}
+}
+
+// trackShow runs the /add flow for "Night Shift" up to the given season 2 episode.
+func trackShow(t *testing.T, env *testEnv, episode string) {
+	t.Helper()
+	env.command("/add night")
+	env.press("acceptShowName:1")
+	env.press("selectSeason:2")
+	env.press("selectEpisode:" + episode)
+}
+
+func TestAddCommand(t *testing.T) {
+	tests := []struct {
+		name         string
+		messages     []string
+		wantText     string
+		wantKeyboard []string
+	}{
+		{
+			name:         "search from arguments",
+			messages:     []string{"/add night"},
+			wantText:     "Pick the show you want to add:",
+			wantKeyboard: []string{"acceptShowName:1", "cancel"},
+		},
+		{
+			name:     "prompt without arguments",
+			messages: []string{"/add"},
+			wantText: "Enter show name:",
+		},
+		{
+			name:         "show name as follow-up message",
+			messages:     []string{"/add", "solo"},
+			wantText:     "Pick the show you want to add:",
+			wantKeyboard: []string{"acceptShowName:1", "cancel"},
+		},
+		{
+			name:     "no results",
+			messages: []string{"/add nothing like this"},
+			wantText: "No shows found for: nothing like this",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, testShows()...)
+			for _, message := range tt.messages {
+				if strings.HasPrefix(message, "/") {
+					env.command(message)
+				} else {
+					env.text(message)
+				}
+			}
+
+			last := env.telegram.lastMessage(t)
+			if got := last.Params.Get("text"); got != tt.wantText {
+				t.Errorf("text = %q, want %q", got, tt.wantText)
+			}
+			if got := last.keyboard(t); !slices.Equal(got, tt.wantKeyboard) {
+				t.Errorf("keyboard = %v, want %v", got, tt.wantKeyboard)
+			}
+		})
+	}
+}
+
+func TestAddFlow(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		presses      []string
+		wantText     string
+		wantKeyboard []string
+		wantReminder bool
+	}{
+		{
+			name:         "multi-season show asks for the season",
+			query:        "night",
+			presses:      []string{"acceptShowName:1"},
+			wantText:     "TV show \"Night Shift\" added. Which season are you on?",
+			wantKeyboard: []string{"selectSeason:1", "selectSeason:2", "watchlistShow:", "cancel"},
+		},
+		{
+			name:         "single-season show skips to episodes",
+			query:        "solo",
+			presses:      []string{"acceptShowName:1"},
+			wantText:     "TV show \"Solo\" added. Which episode of season 1 are you on? You can also type its number.",
+			wantKeyboard: []string{"selectEpisode:1", "selectEpisode:2", "watchlistShow:", "cancel"},
+		},
+		{
+			name:         "season lists its episodes",
+			query:        "night",
+			presses:      []string{"acceptShowName:1", "selectSeason:2"},
+			wantText:     "Which episode of season 2 are you on? You can also type its number.",
+			wantKeyboard: []string{"selectEpisode:1", "selectEpisode:2", "selectEpisode:3", "cancel"},
+		},
+		{
+			name:     "next episode already aired",
+			query:    "night",
+			presses:  []string{"acceptShowName:1", "selectSeason:2", "selectEpisode:1"},
+			wantText: "Marked \"Night Shift\" as watched up to S02E01. Next episode \"Episode 2.2\" is already available.",
+		},
+		{
+			name:         "next episode in the future gets a reminder",
+			query:        "night",
+			presses:      []string{"acceptShowName:1", "selectSeason:2", "selectEpisode:2"},
+			wantText:     "Next episode \"Episode 2.3\" is expected to air on",
+			wantReminder: true,
+		},
+		{
+			name:     "caught up with the last episode",
+			query:    "night",
+			presses:  []string{"acceptShowName:1", "selectSeason:2", "selectEpisode:3"},
+			wantText: "Marked \"Night Shift\" as watched up to S02E03.",
+		},
+		{
+			name:     "watchlist without an announced season",
+			query:    "night",
+			presses:  []string{"acceptShowName:1", "watchlistShow:"},
+			wantText: "Added \"Night Shift\" to your /watchlist. I'll let you know when a new season is announced.",
+		},
+		{
+			name:     "stale callback without a search",
+			presses:  []string{"acceptShowName:1"},
+			wantText: "No search results found. Please start over with /add.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, testShows()...)
+			if tt.query != "" {
+				env.command("/add " + tt.query)
+			}
+			for _, data := range tt.presses {
+				env.press(data)
+			}
+
+			last := env.telegram.lastMessage(t)
+			if got := last.Params.Get("text"); !strings.Contains(got, tt.wantText) {
+				t.Errorf("text = %q, want it to contain %q", got, tt.wantText)
+			}
+			if tt.wantKeyboard != nil {
+				if got := last.keyboard(t); !slices.Equal(got, tt.wantKeyboard) {
+					t.Errorf("keyboard = %v, want %v", got, tt.wantKeyboard)
+				}
+			}
+
+			var reminders int
+			if err := env.handler.DB.QueryRow(`SELECT COUNT(*) FROM reminders`).Scan(&reminders); err != nil {
+				t.Fatal(err)
+			}
+			if got := reminders == 1; got != tt.wantReminder {
+				t.Errorf("reminders = %d, want reminder: %v", reminders, tt.wantReminder)
+			}
+		})
+	}
+}
+
+func TestAddFlowTypedEpisode(t *testing.T) {
+	tests := []struct {
+		name     string
+		presses  []string
+		input    string
+		wantText string
+	}{
+		{
+			name:     "number in the selected season",
+			presses:  []string{"acceptShowName:1", "selectSeason:2"},
+			input:    "1",
+			wantText: "Marked \"Night Shift\" as watched up to S02E01.",
+		},
+		{
+			name:     "season and episode instead of picking a season",
+			presses:  []string{"acceptShowName:1"},
+			input:    "s1e3",
+			wantText: "Marked \"Night Shift\" as watched up to S01E03.",
+		},
+		{
+			name:     "number without a season",
+			presses:  []string{"acceptShowName:1"},
+			input:    "3",
+			wantText: "Pick a season first, or send the episode with its season like S02E07.",
+		},
+		{
+			name:     "unknown episode",
+			presses:  []string{"acceptShowName:1", "selectSeason:2"},
+			input:    "S02E24",
+			wantText: "I can't find S02E24, please send another episode.",
+		},
+		{
+			name:     "not an episode",
+			presses:  []string{"acceptShowName:1", "selectSeason:2"},
+			input:    "the one with the fire",
+			wantText: "Pick the episode on the keyboard, or send its number like 7 or S02E07.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, testShows()...)
+			env.command("/add night")
+			for _, data := range tt.presses {
+				env.press(data)
+			}
+			env.text(tt.input)
+
+			if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, tt.wantText) {
+				t.Errorf("reply to %q = %q, want %q", tt.input, got, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestShowDetailCallbacks(t *testing.T) {
+	tests := []struct {
+		name    string
+		episode string
+		press   string
+		query   string
+		want    string
+	}{
+		{
+			name:    "toggle notifications",
+			episode: "2",
+			press:   "toggleNotifications:1:history",
+			query:   `SELECT notifications_enabled FROM shows`,
+			want:    "0",
+		},
+		{
+			name:    "pin",
+			episode: "2",
+			press:   "togglePinned:1:history",
+			query:   `SELECT pinned FROM shows`,
+			want:    "1",
+		},
+		{
+			name:    "binge mode",
+			episode: "2",
+			press:   "toggleReminderMode:1:history",
+			query:   `SELECT reminder_mode FROM shows`,
+			want:    ReminderModeSeason,
+		},
+		{
+			name:    "mark next watched",
+			episode: "1",
+			press:   "markNextWatched:1:history",
+			query:   `SELECT e.number FROM shows s JOIN episodes_cache e ON e.id = s.last_watched_episode_id`,
+			want:    "2",
+		},
+		{
+			name:    "mark caught up",
+			episode: "1",
+			press:   "markCaughtUp:1:history",
+			query:   `SELECT e.number FROM shows s JOIN episodes_cache e ON e.id = s.last_watched_episode_id`,
+			want:    "2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, testShows()...)
+			trackShow(t, env, tt.episode)
+			env.command("/history")
+			env.press("selectShow:1:history")
+			env.press(tt.press)
+
+			var got string
+			if err := env.handler.DB.QueryRow(tt.query).Scan(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.query, got, tt.want)
+			}
+			// Every action re-renders the show card in place
+			last := env.telegram.lastMessage(t)
+			if last.Method != "editMessageText" || !strings.Contains(last.Params.Get("text"), "Night Shift") {
+				t.Errorf("last message = %s %q, want the refreshed show card", last.Method, last.Params.Get("text"))
+			}
+		})
+	}
+}
+
+func TestShowDetailCaughtUpButton(t *testing.T) {
+	tests := []struct {
+		episode string
+		want    bool
+	}{
+		{episode: "1", want: true},
+		{episode: "2", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run("episode "+tt.episode, func(t *testing.T) {
+			env := newTestEnv(t, testShows()...)
+			trackShow(t, env, tt.episode)
+			env.command("/history")
+			env.press("selectShow:1:history")
+
+			keyboard := env.telegram.lastMessage(t).keyboard(t)
+			if got := slices.Contains(keyboard, "markCaughtUp:1:history"); got != tt.want {
+				t.Errorf("caught-up button shown = %v, want %v (keyboard %v)", got, tt.want, keyboard)
+			}
+		})
+	}
+}
+
+func TestShowCallbackOfAnotherUser(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "1")
+
+	other := int64(testUserID + 1)
+	env.handler.handleUpdate(t.Context(), tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "cb",
+		From:    &tgbotapi.User{ID: other},
+		Message: &tgbotapi.Message{MessageID: 42, Chat: &tgbotapi.Chat{ID: other}},
+		Data:    env.handler.Bot.Signer.SignCallback("deleteShow:1:history", other, time.Now().Add(telegram.CallbackTTL)),
+	}})
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "No shows found. Please start over with /history" {
+		t.Errorf("pressing another user's show replied %q", got)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM shows WHERE deleted_at IS NULL`); got != "1" {
+		t.Errorf("%s shows left, want another user's press to leave the show alone", got)
+	}
+}
+
+func TestAddLongShowReportsProgress(t *testing.T) {
+	long := fakeShow{ShowSearchResult: ShowSearchResult{ID: 3, Name: "Long Runner"}}
+	long.Episodes = makeEpisodes(3, 1, 2*episodeChunkSize+20, time.Now().AddDate(-3, 0, 0))
+	env := newTestEnv(t, long)
+	env.command("/add long")
+	env.press("acceptShowName:1")
+
+	if len(env.telegram.calls("sendChatAction")) == 0 {
+		t.Error("no typing indicator sent")
+	}
+	var progress []string
+	for _, req := range env.telegram.messages() {
+		if text := req.Params.Get("text"); strings.HasPrefix(text, "Saving episodes") {
+			progress = append(progress, text)
+		}
+	}
+	want := []string{"Saving episodes… 50/120", "Saving episodes… 100/120", "Saving episodes… 120/120"}
+	if !slices.Equal(progress, want) {
+		t.Errorf("progress messages = %q, want %q", progress, want)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM episodes_cache`); got != "120" {
+		t.Errorf("cached %s episodes, want 120", got)
+	}
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "TV show \"Long Runner\" added.") {
+		t.Errorf("last message = %q", got)
+	}
+}
+
+func TestShowNote(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "1")
+	env.command("/history")
+	env.press("selectShow:1:history")
+	env.press("editNote:1:history")
+	env.text("  friend's recommendation  ")
+
+	if got := queryString(t, env, `SELECT note FROM shows`); got != "friend's recommendation" {
+		t.Errorf("note = %q, want %q", got, "friend's recommendation")
+	}
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != `Note saved for "Night Shift". See /shows.` {
+		t.Errorf("last message = %q", got)
+	}
+
+	env.command("/history")
+	env.press("selectShow:1:history")
+	card := env.telegram.lastMessage(t)
+	if !strings.Contains(card.Params.Get("text"), "friend&#39;s recommendation") {
+		t.Errorf("show card = %q, want the note", card.Params.Get("text"))
+	}
+	if !slices.Contains(card.keyboard(t), "clearNote:1:history") {
+		t.Errorf("keyboard = %v, want a remove note button", card.keyboard(t))
+	}
+
+	env.press("clearNote:1:history")
+	if got := queryString(t, env, `SELECT note FROM shows`); got != "" {
+		t.Errorf("note after removing = %q, want empty", got)
+	}
+}
+
+func TestShowNoteTooLong(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "1")
+	env.command("/history")
+	env.press("selectShow:1:history")
+	env.press("editNote:1:history")
+	env.text(strings.Repeat("a", maxNoteLength+1))
+
+	if got := queryString(t, env, `SELECT note FROM shows`); got != "" {
+		t.Errorf("note = %q, want it rejected", got)
+	}
+	// The user can still send a shorter one
+	env.text("short")
+	if got := queryString(t, env, `SELECT note FROM shows`); got != "short" {
+		t.Errorf("note = %q, want %q", got, "short")
+	}
+}
+
+func TestAddShowWhileAnotherIsBeingAdded(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.command("/add night")
+	if !env.handler.addJobs.begin(testUserID) {
+		t.Fatal("job slot taken before any add")
+	}
+	err := env.handler.startAddShow(t.Context(), testUserID, testUserID, 42, ShowSearchResult{ID: 1, Name: "Night Shift"})
+	if got := getUserMessage(err); got != "I'm still adding your previous show, please wait a moment." {
+		t.Errorf("second add = %q", got)
+	}
+	env.handler.addJobs.done(testUserID)
+
+	env.press("acceptShowName:1")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "TV show \"Night Shift\" added.") {
+		t.Errorf("last message = %q", got)
+	}
+}
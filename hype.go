@@ -0,0 +1,179 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Users who turn on /hype hear about a tracked show's season premiere a week ahead,
+// in time to catch up on the season before. Premieres scheduled on shorter notice are
+// announced too, as long as they're more than a day away. Each premiere is announced
+// once, from digestLoop, outside the user's quiet hours.
+
+// premiereHypeLead is how long before a premiere it's announced.
+const premiereHypeLead = 7 * 24 * time.Hour
+
+// PremiereHype is an upcoming season premiere to announce.
+type PremiereHype struct {
+	UserID    int64
+	ChatID    int64
+	ShowID    int64
+	EpisodeID int64
+	ShowName  string
+	Season    int
+	AiredAt   time.Time
+	Settings  UserSettings
+}
+
+// listDuePremiereHypes returns the premieres airing within premiereHypeLead of now
+// that weren't announced yet, for shows with notifications on.
+func listDuePremiereHypes(ctx context.Context, db *store.DB, now time.Time) ([]PremiereHype, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.user_id, us.chat_id, s.id, e.id, s.name, e.season, e.aired_at_utc, us.timezone, us.quiet_hours
+		FROM shows s
+		JOIN user_settings us ON us.user_id = s.user_id
+		JOIN episodes_cache e ON e.provider = s.provider AND e.provider_show_id = s.provider_show_id
+		WHERE us.premiere_hype = 1 AND us.inactive_since IS NULL
+			AND s.deleted_at IS NULL AND s.notifications_enabled = 1 AND s.dropped_at IS NULL
+			AND (s.muted_until IS NULL OR s.muted_until <= ?)
+			AND e.season > 0 AND e.number = 1
+			AND e.aired_at_utc > ? AND e.aired_at_utc <= ?
+			AND NOT EXISTS (SELECT 1 FROM premiere_hypes h WHERE h.show_id = s.id AND h.episode_id = e.id)
+		ORDER BY e.aired_at_utc
+	`, now.UTC().Format(time.RFC3339), now.Add(24*time.Hour).UTC().Format(time.RFC3339),
+		now.Add(premiereHypeLead).UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hypes []PremiereHype
+	for rows.Next() {
+		var hype PremiereHype
+		var airedAt string
+		if err := rows.Scan(
+			&hype.UserID, &hype.ChatID, &hype.ShowID, &hype.EpisodeID, &hype.ShowName, &hype.Season, &airedAt,
+			&hype.Settings.Timezone, &hype.Settings.QuietHours,
+		); err != nil {
+			return nil, err
+		}
+		if hype.AiredAt, err = time.Parse(time.RFC3339, airedAt); err != nil {
+			continue
+		}
+		hypes = append(hypes, hype)
+	}
+	return hypes, rows.Err()
+}
+
+func markPremiereHyped(ctx context.Context, db *store.DB, showID, episodeID int64, sentAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO premiere_hypes (show_id, episode_id, sent_at) VALUES (?, ?, ?)
+	`, showID, episodeID, sentAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+func setPremiereHype(ctx context.Context, db *store.DB, userID, chatID int64, enabled bool) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET premiere_hype = ? WHERE user_id = ?`, enabled, userID)
+	return err
+}
+
+func formatPremiereHype(hype PremiereHype, now time.Time) string {
+	when := "in a week"
+	if days := int(math.Round(hype.AiredAt.Sub(now).Hours() / 24)); days < 7 {
+		when = "in " + pluralize(days, "day")
+	}
+	return fmt.Sprintf(
+		"📣 Season %d of <b>%s</b> premieres %s, on %s. Time to catch up!",
+		hype.Season, html.EscapeString(hype.ShowName), when,
+		hype.AiredAt.In(hype.Settings.Location()).Format("Mon Jan 2, 15:04"),
+	)
+}
+
+// sendDuePremiereHypes announces upcoming premieres. Announcements held back by quiet
+// hours go out on a later run.
+func sendDuePremiereHypes(ctx context.Context, bot *Bot, db *store.DB, now time.Time) {
+	hypes, err := listDuePremiereHypes(ctx, db, now)
+	if err != nil {
+		log.Printf("digestLoop: listDuePremiereHypes error: %v", err)
+		return
+	}
+	for _, hype := range hypes {
+		if hype.Settings.inQuietHours(now) {
+			continue
+		}
+		chatID := hype.ChatID
+		if chatID == 0 {
+			chatID = hype.UserID
+		}
+		if _, err := bot.send(ctx, chatID, formatPremiereHype(hype, now), ReplyOptions{ParseMode: "HTML"}); err != nil {
+			log.Printf("digestLoop: failed to announce premiere of show %d to user %d: %v", hype.ShowID, hype.UserID, err)
+			if !isChatUnreachable(err) {
+				continue
+			}
+		}
+		if err := markPremiereHyped(ctx, db, hype.ShowID, hype.EpisodeID, now); err != nil {
+			log.Printf("digestLoop: marking premiere of show %d announced: %v", hype.ShowID, err)
+		}
+	}
+}
+
+// HYPE command
+
+func (handler *Handler) handleHypeCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+
+	switch arg := msg.CommandArguments(); arg {
+	case "on", "off":
+		enabled := arg == "on"
+		if err := setPremiereHype(ctx, handler.DB, userID, chatID, enabled); err != nil {
+			return NewUserError(
+				fmt.Errorf("setting premiere announcements for user %d: %w", userID, err),
+				"Error saving your settings, please try again later.",
+			)
+		}
+		if enabled {
+			handler.Bot.reply(ctx, chatID, "I'll tell you a week before a season of your shows premieres.")
+		} else {
+			handler.Bot.reply(ctx, chatID, "Premiere announcements disabled. You still get the usual reminders.")
+		}
+		return nil
+	case "":
+		settings, err := getUserSettings(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting settings for user %d: %w", userID, err),
+				"Error reading your settings, please try again later.",
+			)
+		}
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf(
+			"Premiere announcements are %s. Use /hype on or /hype off to change it.", onOff(settings.PremiereHype),
+		))
+		return nil
+	default:
+		return NewUserError(
+			fmt.Errorf("invalid hype argument: %s", arg),
+			"Usage: /hype on|off",
+		)
+	}
+}
@@ -0,0 +1,39 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPremiereHype(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	premiere := time.Now().UTC().Add(premiereHypeLead - time.Hour).Format(time.RFC3339)
+	_, err := env.handler.DB.Exec(`
+		INSERT INTO episodes_cache (provider, provider_show_id, provider_episode_id, season, number, title, aired_at_utc)
+		SELECT provider, provider_show_id, 'premiere', 3, 1, 'Episode 3.1', ? FROM episodes_cache LIMIT 1
+	`, premiere)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Off by default
+	sendDuePremiereHypes(t.Context(), env.handler.Bot, env.handler.DB, time.Now())
+	if got := env.telegram.lastMessage(t).Params.Get("text"); strings.Contains(got, "premieres") {
+		t.Fatalf("announced without /hype: %q", got)
+	}
+
+	env.command("/hype on")
+	sendDuePremiereHypes(t.Context(), env.handler.Bot, env.handler.DB, time.Now())
+	want := `📣 Season 3 of <b>Night Shift</b> premieres in a week, on `
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, want) {
+		t.Fatalf("announcement = %q, want prefix %q", got, want)
+	}
+
+	n := len(env.telegram.messages())
+	sendDuePremiereHypes(t.Context(), env.handler.Bot, env.handler.DB, time.Now())
+	if got := len(env.telegram.messages()); got != n {
+		t.Errorf("premiere announced again")
+	}
+}
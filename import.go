@@ -0,0 +1,274 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/reminder"
+	"tvreminder/bot/internal/store"
+)
+
+// maxImportSize is far above any real export; it only guards against random uploads.
+const maxImportSize = 1 << 20
+
+// importResult counts what an import restored.
+type importResult struct {
+	Imported int
+	// Skipped are shows from another provider or that couldn't be fetched.
+	Skipped int
+}
+
+func parseUserExport(data []byte) (*UserExport, error) {
+	var export UserExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("decoding export: %w", err)
+	}
+	if export.Version < 1 || export.Version > exportFormatVersion {
+		return nil, fmt.Errorf("export version %d: %w", export.Version, errors.ErrUnsupported)
+	}
+	return &export, nil
+}
+
+// restoreUserSettings applies exported settings, keeping the current value of any
+// setting that doesn't validate.
+func restoreUserSettings(ctx context.Context, db *store.DB, userID, chatID int64, settings *ExportedSettings) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	current, err := getUserSettings(ctx, db, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(settings.Timezone); err == nil && settings.Timezone != "" {
+		current.Timezone = settings.Timezone
+	}
+	if _, _, err := parseQuietHours(settings.QuietHours); err == nil || settings.QuietHours == "" {
+		current.QuietHours = settings.QuietHours
+	}
+	if countryCodeRe.MatchString(settings.Country) || settings.Country == "" {
+		current.Country = settings.Country
+	}
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		UPDATE user_settings
+		SET timezone = ?, quiet_hours = ?, show_summaries = ?, country = ?, airtime_alerts = ?,
+			monthly_export_enabled = ?
+		WHERE user_id = ?
+	`, current.Timezone, current.QuietHours, settings.ShowSummaries, current.Country, settings.AirtimeAlerts,
+		settings.MonthlyExportEnabled, userID)
+	return err
+}
+
+func restoreShowPreferences(ctx context.Context, db *store.DB, showID int64, show ExportedShow) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	mode := show.ReminderMode
+	if mode != ReminderModeSeason && mode != ReminderModeWatchlist {
+		mode = ReminderModeEpisode
+	}
+	// Templates are checked like when they're set, and dropped when they don't pass
+	tmpl := show.ReminderTemplate
+	if tmpl != "" {
+		if _, err := reminder.ParseTemplate(tmpl); err != nil {
+			tmpl = ""
+		}
+	}
+	var droppedAt any
+	if show.DroppedAt != nil {
+		droppedAt = show.DroppedAt.UTC().Format(time.RFC3339)
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE shows SET notifications_enabled = ?, pinned = ?, reminder_mode = ?, note = ?, silent = ?,
+			reminder_template = ?, sort_order = ?, archived = ?, dropped_at = ?, drop_reason = ?, one_off = ?
+		WHERE id = ?
+	`, show.NotificationsEnabled, show.Pinned, mode, trimString(show.Note, maxNoteLength), show.Silent, tmpl,
+		show.SortOrder, show.Archived, droppedAt, trimString(show.DropReason, maxDropReasonLength), show.OneOff, showID)
+	return err
+}
+
+// importShow adds an exported show for the user with its progress and preferences
+// and schedules its reminder. Episodes are fetched if this instance hasn't cached
+// the show yet.
+func (handler *Handler) importShow(ctx context.Context, userID, chatID int64, show ExportedShow) error {
+	providerShowID, err := strconv.Atoi(show.ProviderShowID)
+	if err != nil {
+		return fmt.Errorf("invalid provider show id %q: %w", show.ProviderShowID, err)
+	}
+
+	seasons, err := getSeasons(ctx, handler.DB, show.Provider, show.ProviderShowID)
+	if err != nil {
+		return err
+	}
+	if len(seasons) == 0 {
+		fetchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		episodes, err := handler.Provider.FetchEpisodes(fetchCtx, providerShowID)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("fetching episodes: %w", err)
+		}
+		if err := storeEpisodes(ctx, handler.DB, show.Provider, show.ProviderShowID, episodes); err != nil {
+			return fmt.Errorf("storing episodes: %w", err)
+		}
+	}
+
+	showID, err := addShow(
+		ctx, handler.DB, userID, show.Name, show.Provider, providerShowID, show.Network, show.PosterURL,
+	)
+	if err != nil {
+		return fmt.Errorf("adding show: %w", err)
+	}
+	if err := restoreShowPreferences(ctx, handler.DB, showID, show); err != nil {
+		return fmt.Errorf("restoring preferences: %w", err)
+	}
+	now := handler.Clock.Now()
+	if show.Season != nil && show.Episode != nil {
+		episode, err := findEpisodeByNumber(ctx, handler.DB, show.Provider, show.ProviderShowID, *show.Season, *show.Episode)
+		if err != nil {
+			return fmt.Errorf("finding S%02dE%02d: %w", *show.Season, *show.Episode, err)
+		}
+		// Restored progress isn't a new watch, so it's not pushed to Trakt
+		if err := setLastWatchedEpisode(ctx, handler.DB, showID, episode.ID, now, now); err != nil {
+			return fmt.Errorf("restoring progress: %w", err)
+		}
+	}
+	if _, err := rebuildShowReminder(ctx, handler.DB, userID, showID, chatID, updateThread(ctx, chatID), now); err != nil {
+		return fmt.Errorf("scheduling reminder: %w", err)
+	}
+	return nil
+}
+
+// importUserData restores an export on top of the user's current data: shows are
+// added or updated, shows missing from the export are kept.
+func (handler *Handler) importUserData(ctx context.Context, userID, chatID int64, export *UserExport) (*importResult, error) {
+	if export.Settings != nil {
+		if err := restoreUserSettings(ctx, handler.DB, userID, chatID, export.Settings); err != nil {
+			return nil, fmt.Errorf("restoring settings: %w", err)
+		}
+	}
+
+	var result importResult
+	for _, show := range export.Shows {
+		if show.Provider != handler.Provider.Name() {
+			result.Skipped++
+			continue
+		}
+		if err := handler.importShow(ctx, userID, chatID, show); err != nil {
+			log.Printf("importUserData: importing show %s/%s for user %d: %v",
+				show.Provider, show.ProviderShowID, userID, err)
+			result.Skipped++
+			continue
+		}
+		result.Imported++
+	}
+	return &result, nil
+}
+
+// IMPORT command
+
+func (handler *Handler) handleImportCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	handler.Bot.setState(msg.From.ID, StateAwaitingImport)
+	keyboard := makeKeyboardMarkup([][][]string{{{"❌ Cancel", "cancel"}}})
+	handler.Bot.reply(ctx, msg.Chat.ID,
+		"Send me the JSON file you got from /export, or an export from Simkl, MyShows or Serializd.",
+		ReplyOptions{ReplyMarkup: keyboard})
+	return nil
+}
+
+func (handler *Handler) acceptImport(ctx context.Context, msg *tgbotapi.Message) error {
+	userID := msg.From.ID
+	chatID := msg.Chat.ID
+
+	if msg.Document == nil {
+		return NewUserError(
+			fmt.Errorf("import message from user %d has no document", userID),
+			"Please send the export as a file, or press Cancel.",
+		)
+	}
+	if msg.Document.FileSize > maxImportSize {
+		handler.Bot.clearState(userID)
+		return NewUserError(
+			fmt.Errorf("import file from user %d is %d bytes", userID, msg.Document.FileSize),
+			"This file is too big to be an export.",
+		)
+	}
+
+	data, err := handler.Bot.downloadFile(msg.Document.FileID, maxImportSize)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("downloading import for user %d: %w", userID, err),
+			"I couldn't download the file, please send it again.",
+		)
+	}
+	handler.Bot.clearState(userID)
+
+	// Checked here so a wrong file is told apart right away; the job parses it again
+	if _, err := parseUserExport(data); err != nil {
+		if _, trackerErr := parseTrackerExport(data); trackerErr != nil {
+			return NewUserError(
+				fmt.Errorf("parsing import for user %d: %w", userID, err),
+				"This doesn't look like a TV Reminder export.",
+			)
+		}
+	}
+	if err := enqueueJob(ctx, handler.DB, JobKindImport, userID, importJob{UserID: userID, ChatID: chatID, Data: data}); err != nil {
+		return NewUserError(
+			fmt.Errorf("queueing import for user %d: %w", userID, err),
+			"Error importing your data, please try again later.",
+		)
+	}
+	handler.Bot.reply(ctx, chatID, "Importing your shows, I'll message you when it's done.")
+	return nil
+}
+
+// importJob is the payload of a JobKindImport job.
+type importJob struct {
+	UserID int64  `json:"user_id"`
+	ChatID int64  `json:"chat_id"`
+	Data   []byte `json:"data"`
+}
+
+// runImportJob imports an uploaded file. Shows already imported by an earlier
+// attempt are imported again over themselves, which leaves them as they were.
+func (handler *Handler) runImportJob(ctx context.Context, job Job) error {
+	var args importJob
+	if err := json.Unmarshal([]byte(job.Payload), &args); err != nil {
+		return fmt.Errorf("decoding payload: %v: %w", err, errJobPermanent)
+	}
+	userID, chatID := args.UserID, args.ChatID
+
+	export, err := parseUserExport(args.Data)
+	if err != nil {
+		entries, trackerErr := parseTrackerExport(args.Data)
+		if trackerErr != nil {
+			return fmt.Errorf("parsing import: %v: %w", err, errJobPermanent)
+		}
+		result := handler.importTrackerEntries(ctx, userID, chatID, entries)
+		handler.Bot.reply(ctx, chatID, formatTrackerResult(result))
+		return nil
+	}
+	result, err := handler.importUserData(ctx, userID, chatID, export)
+	if err != nil {
+		if job.Attempts >= maxJobAttempts {
+			handler.Bot.reply(ctx, chatID, "Error importing your data, please try again later.")
+		}
+		return fmt.Errorf("importing data for user %d: %w", userID, err)
+	}
+
+	text := fmt.Sprintf("Imported %s.", pluralize(result.Imported, "show"))
+	if result.Skipped > 0 {
+		text += fmt.Sprintf(" %s couldn't be imported.", pluralize(result.Skipped, "show"))
+	}
+	handler.Bot.reply(ctx, chatID, text+" See /shows.")
+	return nil
+}
@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"tvreminder/bot/internal/store"
+)
+
+// When handling an update fails for a reason on our side, the user gets a short
+// incident ID with the error message. The full error is logged and stored under
+// that ID, so when a user reports it an admin can look it up with /admin incident.
+
+// incidentRetention is how long incidents are kept.
+const incidentRetention = 30 * 24 * time.Hour
+
+// Incident is an error a user was shown, with its internal cause.
+type Incident struct {
+	ID        string
+	UserID    int64
+	ChatID    int64
+	Error     string
+	UserMsg   string
+	CreatedAt time.Time
+}
+
+// newIncidentID returns a short random ID users can read out or copy.
+func newIncidentID() string {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%08x", time.Now().UnixNano()&0xffffffff)
+	}
+	return hex.EncodeToString(id)
+}
+
+// isInternalError reports whether err is our failure rather than the user's. User
+// errors caused by bad input have no wrapped cause, or wrap a lookup or parse
+// failure; internal ones wrap the error that failed.
+func isInternalError(err error) bool {
+	var userErr *UserError
+	if !errors.As(err, &userErr) {
+		return true
+	}
+	cause := errors.Unwrap(userErr.Err)
+	if cause == nil {
+		return false
+	}
+	var numErr *strconv.NumError
+	var timeErr *time.ParseError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(cause, sql.ErrNoRows), errors.Is(cause, ErrShowNotFound), errors.Is(cause, errors.ErrUnsupported),
+		errors.As(cause, &numErr), errors.As(cause, &timeErr), errors.As(cause, &syntaxErr), errors.As(cause, &typeErr):
+		return false
+	}
+	return true
+}
+
+func recordIncident(ctx context.Context, db *store.DB, incident Incident) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO incidents (id, user_id, chat_id, error, user_message, created_at) VALUES (?, ?, ?, ?, ?, ?)
+	`, incident.ID, incident.UserID, incident.ChatID, incident.Error, incident.UserMsg,
+		incident.CreatedAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		DELETE FROM incidents WHERE created_at < ?
+	`, incident.CreatedAt.Add(-incidentRetention).UTC().Format(time.RFC3339))
+	return err
+}
+
+// getIncident returns sql.ErrNoRows for unknown or expired IDs.
+func getIncident(ctx context.Context, db *store.DB, id string) (Incident, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var incident Incident
+	err := db.QueryRowContext(ctx, `
+		SELECT id, user_id, chat_id, error, user_message, created_at FROM incidents WHERE id = ?
+	`, strings.ToLower(id)).Scan(
+		&incident.ID, &incident.UserID, &incident.ChatID, &incident.Error, &incident.UserMsg, &incident.CreatedAt,
+	)
+	return incident, err
+}
+
+// reportIncident logs and stores an internal error shown to a user and returns its ID.
+func (handler *Handler) reportIncident(ctx context.Context, userID, chatID int64, err error, userMsg string) string {
+	incident := Incident{
+		ID:        newIncidentID(),
+		UserID:    userID,
+		ChatID:    chatID,
+		Error:     err.Error(),
+		UserMsg:   userMsg,
+		CreatedAt: time.Now(),
+	}
+	log.Printf("incident id=%s user=%d chat=%d error=%q", incident.ID, userID, chatID, incident.Error)
+	if err := recordIncident(ctx, handler.DB, incident); err != nil {
+		log.Printf("reportIncident: storing incident %s: %v", incident.ID, err)
+	}
+	return incident.ID
+}
+
+// replyError tells the user that handling their update failed. Internal errors get
+// an incident ID, see reportIncident.
+func (handler *Handler) replyError(ctx context.Context, userID, chatID int64, err error, opts ...ReplyOptions) {
+	text := getUserMessage(err)
+	if isInternalError(err) {
+		id := handler.reportIncident(ctx, userID, chatID, err, text)
+		text += incidentNote(id)
+	}
+	handler.Bot.reply(ctx, chatID, text, opts...)
+}
+
+// incidentNote is appended to error messages with an incident ID.
+func incidentNote(id string) string {
+	return fmt.Sprintf("\n\nIf this keeps happening, please mention incident %s.", id)
+}
+
+func formatIncident(incident Incident) string {
+	return fmt.Sprintf(
+		"Incident %s\nAt: %s\nUser: %d\nChat: %d\nShown: %s\nError: %s",
+		incident.ID, incident.CreatedAt.UTC().Format(time.RFC3339), incident.UserID, incident.ChatID,
+		incident.UserMsg, incident.Error,
+	)
+}
@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestInternalErrorGetsIncidentID(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.handler.Admins = map[int64]bool{testUserID + 1: true}
+	if _, err := env.handler.DB.Exec(`DROP TABLE shows`); err != nil {
+		t.Fatal(err)
+	}
+
+	env.command("/history")
+	text := env.telegram.lastMessage(t).Params.Get("text")
+	match := regexp.MustCompile(`incident ([0-9a-f]{8})`).FindStringSubmatch(text)
+	if !strings.HasPrefix(text, "Error: can't list shows") || match == nil {
+		t.Fatalf("/history with a broken database = %q, want an incident ID", text)
+	}
+
+	env.commandFrom(testUserID+1, "/admin incident "+match[1])
+	got := env.telegram.lastMessage(t).Params.Get("text")
+	for _, want := range []string{"Incident " + match[1], "User: 1001", "listing shows for user 1001", "no such table: shows"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("/admin incident = %q, want it to contain %q", got, want)
+		}
+	}
+	env.commandFrom(testUserID+1, "/admin incident 00000000")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "No incident 00000000") {
+		t.Errorf("/admin incident for an unknown ID = %q", got)
+	}
+}
+
+func TestInputErrorHasNoIncidentID(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+
+	env.text("watched night shift s5e1")
+	if text := env.telegram.lastMessage(t).Params.Get("text"); strings.Contains(text, "incident") {
+		t.Errorf("reply to a missing episode = %q, want no incident ID", text)
+	}
+}
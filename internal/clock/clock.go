@@ -0,0 +1,56 @@
+// Package clock is the bot's notion of the current time. It is the system clock,
+// except in simulation mode and tests, where a Fake clock is moved by hand so
+// episodes air and reminders come due without waiting for them. main picks the
+// clock and hands it to the handler and the loops that need it.
+//
+// Only what users see as time passing follows the clock: air dates, reminders,
+// mutes, check-ins and the like. Timeouts, rate limits, signatures and anything
+// else talking to the outside world keep using the time package.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the clock of the machine.
+type System struct{}
+
+func (System) Now() time.Time { return time.Now() }
+
+// Fake is a clock that only moves when told to.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake returns a Fake clock stopped at now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance moves the clock forward by d and returns the new time.
+func (f *Fake) Advance(d time.Duration) time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	return f.now
+}
+
+// Set moves the clock to now, which may be in its past.
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
@@ -0,0 +1,22 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+
+	if got := fake.Now(); !got.Equal(start) {
+		t.Errorf("Now() = %s, want %s", got, start)
+	}
+	if got := fake.Advance(90 * time.Minute); !got.Equal(start.Add(90*time.Minute)) || !fake.Now().Equal(got) {
+		t.Errorf("Advance = %s, Now() = %s, want %s", got, fake.Now(), start.Add(90*time.Minute))
+	}
+	fake.Set(start)
+	if got := fake.Now(); !got.Equal(start) {
+		t.Errorf("Now() after Set = %s, want %s", got, start)
+	}
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// When a provider is down, every request to it would wait for a timeout and fail
+// anyway. After BreakerThreshold failures in a row the provider's circuit breaker
+// opens and requests fail right away with ErrProviderUnavailable. Once
+// breakerCooldown has passed one request is let through to probe the provider: its
+// success closes the breaker, its failure keeps it open for another cooldown.
+//
+// Callers check for ErrProviderUnavailable to fall back on what they have: /add
+// searches the shows other users already track, and syncs are deferred until the
+// provider is back.
+
+// ErrProviderUnavailable is returned for requests refused by an open breaker.
+var ErrProviderUnavailable = errors.New("provider unavailable")
+
+const (
+	BreakerThreshold = 5
+	breakerCooldown  = time.Minute
+)
+
+type CircuitBreaker struct {
+	Name string
+
+	mu       sync.Mutex
+	failures int
+	// openedAt is when the breaker last opened, zero while it's closed.
+	openedAt time.Time
+	probing  bool
+}
+
+// allow reports whether a request may go through at now.
+func (b *CircuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return true
+	}
+	if b.probing || now.Sub(b.openedAt) < breakerCooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record updates the breaker with the outcome of a request that went through.
+func (b *CircuitBreaker) record(failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		if !b.openedAt.IsZero() {
+			log.Printf("circuit breaker: %s is back, closing", b.Name)
+		}
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return
+	}
+	b.failures++
+	if !b.openedAt.IsZero() {
+		b.openedAt = now
+	} else if b.failures >= BreakerThreshold {
+		log.Printf("circuit breaker: %s failed %d times in a row, opening", b.Name, b.failures)
+		b.openedAt = now
+	}
+}
+
+// breakerTransport refuses requests while its breaker is open. Network errors and
+// server errors count as failures; rate limiting doesn't, the provider is up.
+type breakerTransport struct {
+	breaker *CircuitBreaker
+	base    http.RoundTripper
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.breaker.allow(time.Now()) {
+		return nil, fmt.Errorf("%s: %w", t.breaker.Name, ErrProviderUnavailable)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if errors.Is(err, context.Canceled) {
+		// The caller gave up, which says nothing about the provider
+		t.breaker.mu.Lock()
+		t.breaker.probing = false
+		t.breaker.mu.Unlock()
+		return resp, err
+	}
+	t.breaker.record(err != nil || resp.StatusCode >= 500, time.Now())
+	return resp, err
+}
@@ -0,0 +1,39 @@
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := &CircuitBreaker{Name: "test"}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for range BreakerThreshold - 1 {
+		b.record(true, now)
+	}
+	if !b.allow(now) {
+		t.Fatal("breaker opened before the threshold")
+	}
+	b.record(true, now)
+	if b.allow(now) {
+		t.Fatal("breaker still closed after the threshold")
+	}
+
+	later := now.Add(breakerCooldown)
+	if !b.allow(later) {
+		t.Fatal("no probe after the cooldown")
+	}
+	if b.allow(later) {
+		t.Error("a second request went through while probing")
+	}
+	b.record(true, later)
+	if b.allow(later.Add(time.Second)) {
+		t.Error("failed probe closed the breaker")
+	}
+
+	b.allow(later.Add(breakerCooldown))
+	b.record(false, later.Add(breakerCooldown))
+	if !b.allow(later.Add(breakerCooldown)) {
+		t.Error("successful probe didn't close the breaker")
+	}
+}
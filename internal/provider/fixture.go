@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Fixture is a provider serving shows from a local dataset instead of a service,
+// for simulation mode and tests. It never goes over the network.
+type Fixture struct {
+	// Start is when the dataset expects the simulation to begin, zero when it
+	// doesn't say.
+	Start time.Time
+	shows []FixtureShow
+}
+
+// FixtureShow is a show of a Fixture with all its episodes.
+type FixtureShow struct {
+	ShowSearchResult
+	Episodes []Episode `json:"episodes"`
+}
+
+// LoadFixture reads a dataset like
+//
+//	{"start": "2026-03-01T18:00:00Z", "shows": [{"id": 1, "name": "Night Shift",
+//	  "episodes": [{"id": 101, "season": 1, "number": 1, "airstamp": "2026-03-01T20:00:00Z"}]}]}
+//
+// Shows take the fields of a TVmaze show and episodes those of a TVmaze episode.
+// An episode's airdate and airtime are filled in from its airstamp, in UTC, when
+// the dataset leaves them out.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		Start *time.Time    `json:"start"`
+		Shows []FixtureShow `json:"shows"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for _, show := range raw.Shows {
+		for _, ep := range show.Episodes {
+			if ep.Airstamp == "" {
+				continue
+			}
+			if _, err := time.Parse(time.RFC3339, ep.Airstamp); err != nil {
+				return nil, fmt.Errorf("show %d episode %d: invalid airstamp: %w", show.ID, ep.ID, err)
+			}
+		}
+	}
+	fixture := NewFixture(raw.Shows...)
+	if raw.Start != nil {
+		fixture.Start = *raw.Start
+	}
+	return fixture, nil
+}
+
+// NewFixture returns a Fixture serving shows.
+func NewFixture(shows ...FixtureShow) *Fixture {
+	f := &Fixture{shows: make([]FixtureShow, len(shows))}
+	for i, show := range shows {
+		episodes := make([]Episode, len(show.Episodes))
+		for j, ep := range show.Episodes {
+			if aired, err := time.Parse(time.RFC3339, ep.Airstamp); err == nil && ep.Airdate == "" {
+				ep.Airdate = aired.UTC().Format(time.DateOnly)
+				ep.Airtime = aired.UTC().Format("15:04")
+			}
+			episodes[j] = ep
+		}
+		show.Episodes = episodes
+		f.shows[i] = show
+	}
+	return f
+}
+
+func (f *Fixture) Name() string {
+	return "fixture"
+}
+
+// SearchShow returns the shows whose name contains the query, ignoring case.
+func (f *Fixture) SearchShow(ctx context.Context, query string) ([]ShowSearchResult, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	var results []ShowSearchResult
+	for _, show := range f.shows {
+		if strings.Contains(strings.ToLower(show.Name), query) {
+			results = append(results, show.ShowSearchResult)
+		}
+	}
+	return results, nil
+}
+
+func (f *Fixture) FetchEpisodes(ctx context.Context, showID int) ([]Episode, error) {
+	show, err := f.show(showID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]Episode(nil), show.Episodes...), nil
+}
+
+// WatchOptions reports the show's web channel as its streaming option, like TVmaze.
+func (f *Fixture) WatchOptions(ctx context.Context, showID int) ([]WatchOption, error) {
+	show, err := f.show(showID)
+	if err != nil {
+		return nil, err
+	}
+	if show.WebChannel == nil {
+		return nil, nil
+	}
+	return []WatchOption{{Service: show.WebChannel.Name, URL: show.WebChannel.OfficialSite}}, nil
+}
+
+func (f *Fixture) ExternalIDs(ctx context.Context, showID int) (ExternalIDs, error) {
+	show, err := f.show(showID)
+	if err != nil {
+		return ExternalIDs{}, err
+	}
+	return show.ShowSearchResult.ExternalIDs(), nil
+}
+
+func (f *Fixture) show(showID int) (*FixtureShow, error) {
+	for i := range f.shows {
+		if f.shows[i].ID == showID {
+			return &f.shows[i], nil
+		}
+	}
+	return nil, fmt.Errorf("fixture show %d: %w", showID, ErrShowNotFound)
+}
@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFixture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	os.WriteFile(path, []byte(`{"start": "2026-03-01T12:00:00Z", "shows": [
+		{"id": 7, "name": "Harbor Lights", "webChannel": {"name": "Netflix"}, "episodes": [
+			{"id": 701, "season": 1, "number": 1, "airstamp": "2026-03-01T20:00:00Z"}
+		]}
+	]}`), 0o644)
+	fixture, err := LoadFixture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fixture.Start.Format("2006-01-02 15:04"); got != "2026-03-01 12:00" {
+		t.Errorf("Start = %s", got)
+	}
+
+	results, err := fixture.SearchShow(t.Context(), "HARBOR")
+	if err != nil || len(results) != 1 || results[0].ID != 7 {
+		t.Fatalf("SearchShow = %+v, %v", results, err)
+	}
+	episodes, err := fixture.FetchEpisodes(t.Context(), 7)
+	if err != nil || len(episodes) != 1 {
+		t.Fatalf("FetchEpisodes = %+v, %v", episodes, err)
+	}
+	if ep := episodes[0]; ep.Airdate != "2026-03-01" || ep.Airtime != "20:00" {
+		t.Errorf("airdate and airtime = %s %s, want them from the airstamp", ep.Airdate, ep.Airtime)
+	}
+	if options, err := fixture.WatchOptions(t.Context(), 7); err != nil || len(options) != 1 || options[0].Service != "Netflix" {
+		t.Errorf("WatchOptions = %+v, %v", options, err)
+	}
+	if _, err := fixture.FetchEpisodes(t.Context(), 8); !errors.Is(err, ErrShowNotFound) {
+		t.Errorf("FetchEpisodes of a missing show: err = %v, want ErrShowNotFound", err)
+	}
+
+	os.WriteFile(path, []byte(`{"shows": [{"id": 1, "episodes": [{"id": 1, "airstamp": "tomorrow"}]}]}`), 0o644)
+	if _, err := LoadFixture(path); err == nil {
+		t.Error("LoadFixture accepted an invalid airstamp")
+	}
+}
@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// providerWindow is how far back provider requests are counted.
+const providerWindow = 24 * time.Hour
+
+// providerHour counts a provider's requests in one hour.
+type providerHour struct {
+	Hour     time.Time
+	Requests int
+	Failures int
+}
+
+// Stats counts requests to metadata providers per hour.
+type Stats struct {
+	mu    sync.Mutex
+	hours map[string][]providerHour
+}
+
+// RequestStats counts the requests of the clients made by Client.
+var RequestStats = &Stats{hours: make(map[string][]providerHour)}
+
+func (s *Stats) record(provider string, failed bool, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hour := now.Truncate(time.Hour)
+	hours := s.hours[provider]
+	if n := len(hours); n == 0 || !hours[n-1].Hour.Equal(hour) {
+		hours = append(hours, providerHour{Hour: hour})
+	}
+	hours[len(hours)-1].Requests++
+	if failed {
+		hours[len(hours)-1].Failures++
+	}
+	// Drop the hours that fell out of the window
+	for len(hours) > 0 && now.Sub(hours[0].Hour) > providerWindow {
+		hours = hours[1:]
+	}
+	s.hours[provider] = hours
+}
+
+// Summary is a provider's request count over the last providerWindow.
+type Summary struct {
+	Provider string
+	Requests int
+	Failures int
+}
+
+// Summary returns the request counts of the providers with requests lately, the
+// busiest first.
+func (s *Stats) Summary(now time.Time) []Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []Summary
+	for provider, hours := range s.hours {
+		sum := Summary{Provider: provider}
+		for _, h := range hours {
+			if now.Sub(h.Hour) <= providerWindow {
+				sum.Requests += h.Requests
+				sum.Failures += h.Failures
+			}
+		}
+		if sum.Requests > 0 {
+			out = append(out, sum)
+		}
+	}
+	slices.SortFunc(out, func(a, b Summary) int {
+		return b.Requests - a.Requests
+	})
+	return out
+}
+
+// countingTransport counts a provider's requests, treating network errors, server
+// errors and rate limiting as failures.
+type countingTransport struct {
+	provider string
+	stats    *Stats
+	base     http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	failed := err != nil || resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	t.stats.record(t.provider, failed, time.Now())
+	return resp, err
+}
+
+// Client returns the shared HTTP client with requests counted for provider, behind
+// a circuit breaker of its own, see circuit.go.
+func Client(provider string) *http.Client {
+	client := *HTTPClient
+	client.Transport = &breakerTransport{
+		breaker: &CircuitBreaker{Name: provider},
+		base:    &countingTransport{provider: provider, stats: RequestStats, base: HTTPClient.Transport},
+	}
+	return &client
+}
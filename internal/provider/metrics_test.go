@@ -0,0 +1,26 @@
+package provider
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestProviderStats(t *testing.T) {
+	stats := &Stats{hours: make(map[string][]providerHour)}
+	now := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	stats.record("tvmaze", false, now.Add(-30*time.Hour))
+	stats.record("tvmaze", false, now.Add(-2*time.Hour))
+	stats.record("tvmaze", true, now.Add(-time.Hour))
+	stats.record("tvmaze", false, now)
+	stats.record("tmdb", true, now)
+
+	got := stats.Summary(now)
+	want := []Summary{
+		{Provider: "tvmaze", Requests: 3, Failures: 1},
+		{Provider: "tmdb", Requests: 1, Failures: 1},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("summary = %+v, want %+v", got, want)
+	}
+}
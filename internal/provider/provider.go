@@ -0,0 +1,90 @@
+// Package provider talks to the services the bot gets its data from: TVmaze and
+// TheTVDB for shows and episodes, TMDB for movies, or a local Fixture in simulation
+// mode. Requests go through Client, which counts them and puts each service behind
+// a circuit breaker.
+package provider
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrShowNotFound is returned by providers for shows they no longer have, e.g.
+// after merging duplicates. See relocateShow.
+var ErrShowNotFound = errors.New("show not found at provider")
+
+// Provider is a source of TV show metadata. Shows and episodes are stored under
+// the provider's Name, so it must stay stable once data has been written.
+type Provider interface {
+	Name() string
+	SearchShow(ctx context.Context, query string) ([]ShowSearchResult, error)
+	FetchEpisodes(ctx context.Context, showID int) ([]Episode, error)
+}
+
+// WatchOption is a streaming service carrying a show. An empty Country means the
+// service carries it everywhere.
+type WatchOption struct {
+	Country string
+	Service string
+	URL     string
+}
+
+// AvailabilityProvider is implemented by providers that know where shows stream.
+type AvailabilityProvider interface {
+	WatchOptions(ctx context.Context, showID int) ([]WatchOption, error)
+}
+
+// ExternalIDs are a show's IDs on other services, zero when unknown.
+type ExternalIDs struct {
+	TVDB int
+	IMDB string
+	TMDB int
+}
+
+// ExternalIDProvider is implemented by providers that know a show's IDs elsewhere,
+// which Trakt sync needs to match shows.
+type ExternalIDProvider interface {
+	ExternalIDs(ctx context.Context, showID int) (ExternalIDs, error)
+}
+
+// UpdatesProvider is implemented by providers that can tell which shows changed
+// lately, so syncs can skip the ones that didn't.
+type UpdatesProvider interface {
+	// UpdatedShows maps the IDs of the shows changed within the past week to
+	// when they last changed.
+	UpdatedShows(ctx context.Context) (map[string]time.Time, error)
+}
+
+// ShowLookupProvider is implemented by providers that can find a show by its IDs
+// elsewhere, which locates shows the provider moved to a new ID.
+type ShowLookupProvider interface {
+	// LookupShow returns the provider's ID of the show, 0 when it has none.
+	LookupShow(ctx context.Context, ids ExternalIDs) (int, error)
+}
+
+// Movie is a film as reported by a MovieProvider. ReleaseDate is yyyy-mm-dd, empty
+// when the movie has no date yet.
+type Movie struct {
+	ID          int
+	Title       string
+	ReleaseDate string
+	PosterURL   string
+	Overview    string
+}
+
+// Year returns the release year for telling remakes apart, or "" when unknown.
+func (m Movie) Year() string {
+	if len(m.ReleaseDate) < 4 {
+		return ""
+	}
+	return m.ReleaseDate[:4]
+}
+
+// MovieProvider is a source of movie metadata. Movies are tracked separately from
+// shows, so it can be configured next to any Provider.
+type MovieProvider interface {
+	Name() string
+	SearchMovie(ctx context.Context, query string) ([]Movie, error)
+	FetchMovie(ctx context.Context, movieID int) (*Movie, error)
+}
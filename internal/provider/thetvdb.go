@@ -0,0 +1,251 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const theTVDBBaseURL = "https://api4.thetvdb.com/v4"
+
+// TheTVDB is the Provider backed by TheTVDB v4 API. Unlike TVmaze it needs an API
+// key, which is exchanged for a bearer token on first use.
+type TheTVDB struct {
+	BaseURL string
+	APIKey  string
+	Client  *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+// NewTheTVDB returns a TheTVDB provider using apiKey. An empty baseURL means the
+// public API.
+func NewTheTVDB(baseURL, apiKey string) *TheTVDB {
+	if baseURL == "" {
+		baseURL = theTVDBBaseURL
+	}
+	return &TheTVDB{BaseURL: strings.TrimRight(baseURL, "/"), APIKey: apiKey, Client: Client("thetvdb")}
+}
+
+func (t *TheTVDB) Name() string {
+	return "thetvdb"
+}
+
+type theTVDBSearchResult struct {
+	TVDBID       string `json:"tvdb_id"`
+	Name         string `json:"name"`
+	FirstAirTime string `json:"first_air_time"`
+	Network      string `json:"network"`
+	ImageURL     string `json:"image_url"`
+	PrimaryLang  string `json:"primary_language"`
+	OfficialSite string `json:"official_site"`
+	RemoteIDs    []struct {
+		ID         string `json:"id"`
+		SourceName string `json:"sourceName"`
+	} `json:"remote_ids"`
+}
+
+// externals returns the result's IDs on other sites, which TheTVDB lists by the
+// site's name.
+func (r theTVDBSearchResult) externals(tvdbID int) *Externals {
+	externals := &Externals{TheTVDB: tvdbID}
+	for _, remote := range r.RemoteIDs {
+		switch remote.SourceName {
+		case "IMDB":
+			externals.IMDB = remote.ID
+		case "TheMovieDB.com":
+			externals.TMDB, _ = strconv.Atoi(remote.ID)
+		}
+	}
+	return externals
+}
+
+type theTVDBEpisode struct {
+	ID           int    `json:"id"`
+	SeasonNumber int    `json:"seasonNumber"`
+	Number       int    `json:"number"`
+	Name         string `json:"name"`
+	Aired        string `json:"aired"`
+	Overview     string `json:"overview"`
+	Image        string `json:"image"`
+	Runtime      int    `json:"runtime"`
+}
+
+func (t *TheTVDB) SearchShow(ctx context.Context, q string) ([]ShowSearchResult, error) {
+	var results []theTVDBSearchResult
+	if err := t.get(ctx, "/search?type=series&query="+url.QueryEscape(q), &results); err != nil {
+		return nil, fmt.Errorf("thetvdb search: %w", err)
+	}
+
+	out := make([]ShowSearchResult, 0, len(results))
+	for _, r := range results {
+		id, err := strconv.Atoi(r.TVDBID)
+		if err != nil {
+			continue
+		}
+		show := ShowSearchResult{
+			ID: id, Name: r.Name, Language: r.PrimaryLang, OfficialSite: r.OfficialSite, Externals: r.externals(id),
+		}
+		if r.FirstAirTime != "" {
+			premiered := r.FirstAirTime
+			show.Premiered = &premiered
+		}
+		if r.Network != "" {
+			show.Network = &Network{Name: r.Network}
+		}
+		if r.ImageURL != "" {
+			show.Image = &Image{Original: r.ImageURL}
+		}
+		out = append(out, show)
+	}
+	return out, nil
+}
+
+func (t *TheTVDB) FetchEpisodes(ctx context.Context, showID int) ([]Episode, error) {
+	var episodes []Episode
+	for page := 0; ; page++ {
+		var data struct {
+			Series struct {
+				AirsTime string `json:"airsTime"`
+			} `json:"series"`
+			Episodes []theTVDBEpisode `json:"episodes"`
+		}
+		path := fmt.Sprintf("/series/%d/episodes/default?page=%d", showID, page)
+		if err := t.get(ctx, path, &data); err != nil {
+			return nil, fmt.Errorf("thetvdb episodes: %w", err)
+		}
+		if len(data.Episodes) == 0 {
+			return episodes, nil
+		}
+		for _, e := range data.Episodes {
+			// Specials live in season 0 and episodes without a date can't be reminded about
+			if e.SeasonNumber == 0 || e.Aired == "" {
+				continue
+			}
+			// TheTVDB only has the air date and the usual local air time of the
+			// series, so the time is treated as UTC. Without one the episode is
+			// date-only, see episodeAirTime.
+			airTime, stampTime := data.Series.AirsTime, data.Series.AirsTime
+			if stampTime == "" {
+				stampTime = "00:00"
+			}
+			airstamp, err := time.Parse("2006-01-02 15:04", e.Aired+" "+stampTime)
+			if err != nil {
+				continue
+			}
+			episode := Episode{
+				ID:       e.ID,
+				Season:   e.SeasonNumber,
+				Number:   e.Number,
+				Name:     e.Name,
+				Airdate:  e.Aired,
+				Airtime:  airTime,
+				Airstamp: airstamp.Format(time.RFC3339),
+				Summary:  e.Overview,
+				Runtime:  e.Runtime,
+			}
+			if e.Image != "" {
+				episode.Image = &Image{Original: e.Image}
+			}
+			episodes = append(episodes, episode)
+		}
+	}
+}
+
+// get fetches path and decodes the "data" member of the response into out. An
+// expired token is dropped and the request retried once with a fresh one.
+func (t *TheTVDB) get(ctx context.Context, path string, out any) error {
+	status, err := t.getOnce(ctx, path, out)
+	if status == http.StatusUnauthorized {
+		t.mu.Lock()
+		t.token = ""
+		t.mu.Unlock()
+		status, err = t.getOnce(ctx, path, out)
+	}
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && status != http.StatusNotFound {
+		// The episodes endpoint answers 404 past the last page
+		return fmt.Errorf("status %d", status)
+	}
+	return nil
+}
+
+func (t *TheTVDB) getOnce(ctx context.Context, path string, out any) (int, error) {
+	token, err := t.authToken(ctx)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.BaseURL+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+
+	var body struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return resp.StatusCode, err
+	}
+	if len(body.Data) == 0 || string(body.Data) == "null" {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.Unmarshal(body.Data, out)
+}
+
+func (t *TheTVDB) authToken(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" {
+		return t.token, nil
+	}
+
+	payload, err := json.Marshal(map[string]string{"apikey": t.APIKey})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.BaseURL+"/login", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("thetvdb login: status %d", resp.StatusCode)
+	}
+	var body struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Data.Token == "" {
+		return "", fmt.Errorf("thetvdb login: empty token")
+	}
+	t.token = body.Data.Token
+	return t.token, nil
+}
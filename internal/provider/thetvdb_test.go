@@ -0,0 +1,156 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newFakeTheTVDB serves login, search and two pages of episodes, and counts logins.
+// POST /expire invalidates the current token.
+func newFakeTheTVDB(t *testing.T) (*httptest.Server, *int) {
+	t.Helper()
+	logins := 0
+	token := ""
+	mux := http.NewServeMux()
+	reply := func(w http.ResponseWriter, data any) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "success", "data": data})
+	}
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			APIKey string `json:"apikey"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.APIKey != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		logins++
+		token = fmt.Sprintf("token-%d", logins)
+		reply(w, map[string]string{"token": token})
+	})
+	mux.HandleFunc("GET /search", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		reply(w, []map[string]any{{
+			"tvdb_id": "81189", "name": "Breaking Bad", "first_air_time": "2008-01-20",
+			"network": "AMC", "image_url": "https://artworks.example/poster.jpg",
+			"remote_ids": []map[string]string{
+				{"id": "tt0903747", "sourceName": "IMDB"},
+				{"id": "1396", "sourceName": "TheMovieDB.com"},
+			},
+		}})
+	})
+	mux.HandleFunc("GET /series/81189/episodes/default", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		episodes := []map[string]any{}
+		switch r.URL.Query().Get("page") {
+		case "0":
+			episodes = []map[string]any{
+				{"id": 1, "seasonNumber": 0, "number": 1, "name": "Special", "aired": "2008-01-01"},
+				{"id": 2, "seasonNumber": 1, "number": 1, "name": "Pilot", "aired": "2008-01-20",
+					"image": "https://artworks.example/still.jpg", "overview": "A teacher turns cook."},
+			}
+		case "1":
+			episodes = []map[string]any{
+				{"id": 3, "seasonNumber": 1, "number": 2, "name": "Cat's in the Bag...", "aired": "2008-01-27"},
+				{"id": 4, "seasonNumber": 2, "number": 1, "name": "TBA", "aired": ""},
+			}
+		}
+		reply(w, map[string]any{"series": map[string]string{"airsTime": "22:00"}, "episodes": episodes})
+	})
+	mux.HandleFunc("POST /expire", func(w http.ResponseWriter, r *http.Request) {
+		token = "rotated"
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &logins
+}
+
+func TestTheTVDBSearchShow(t *testing.T) {
+	server, _ := newFakeTheTVDB(t)
+	provider := NewTheTVDB(server.URL, "secret")
+
+	results, err := provider.SearchShow(context.Background(), "breaking")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	got := results[0]
+	if got.ID != 81189 || got.Name != "Breaking Bad" || got.NetworkName() != "AMC" {
+		t.Errorf("result = %+v", got)
+	}
+	if got.Image.URL() != "https://artworks.example/poster.jpg" {
+		t.Errorf("poster = %q", got.Image.URL())
+	}
+	if got.Premiered == nil || *got.Premiered != "2008-01-20" {
+		t.Errorf("premiered = %v", got.Premiered)
+	}
+	if ids := got.ExternalIDs(); ids != (ExternalIDs{TVDB: 81189, IMDB: "tt0903747", TMDB: 1396}) {
+		t.Errorf("external IDs = %+v", ids)
+	}
+}
+
+func TestTheTVDBFetchEpisodes(t *testing.T) {
+	server, _ := newFakeTheTVDB(t)
+	provider := NewTheTVDB(server.URL, "secret")
+
+	episodes, err := provider.FetchEpisodes(context.Background(), 81189)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The special and the undated episode are dropped
+	if len(episodes) != 2 {
+		t.Fatalf("got %d episodes, want 2: %+v", len(episodes), episodes)
+	}
+	pilot := episodes[0]
+	if pilot.Season != 1 || pilot.Number != 1 || pilot.Airstamp != "2008-01-20T22:00:00Z" {
+		t.Errorf("pilot = %+v", pilot)
+	}
+	if pilot.Image.URL() != "https://artworks.example/still.jpg" {
+		t.Errorf("still = %q", pilot.Image.URL())
+	}
+	if episodes[1].Name != "Cat's in the Bag..." {
+		t.Errorf("second page episode = %+v", episodes[1])
+	}
+}
+
+func TestTheTVDBRenewsExpiredToken(t *testing.T) {
+	server, logins := newFakeTheTVDB(t)
+	provider := NewTheTVDB(server.URL, "secret")
+
+	if _, err := provider.SearchShow(context.Background(), "breaking"); err != nil {
+		t.Fatal(err)
+	}
+	http.Post(server.URL+"/expire", "", nil)
+	if _, err := provider.SearchShow(context.Background(), "breaking"); err != nil {
+		t.Fatalf("search after token expiry: %v", err)
+	}
+	if *logins != 2 {
+		t.Errorf("logged in %d times, want 2", *logins)
+	}
+}
+
+func TestTheTVDBBadAPIKey(t *testing.T) {
+	server, _ := newFakeTheTVDB(t)
+	provider := NewTheTVDB(server.URL, "wrong")
+
+	if _, err := provider.SearchShow(context.Background(), "breaking"); err == nil {
+		t.Error("search with a bad API key succeeded")
+	}
+}
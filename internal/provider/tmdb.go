@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	tmdbBaseURL = "https://api.themoviedb.org/3"
+	// tmdbImageBaseURL serves posters at a size that works well in Telegram.
+	tmdbImageBaseURL = "https://image.tmdb.org/t/p/w500"
+)
+
+// TMDB is the MovieProvider backed by The Movie Database API, authenticated with an
+// API read access token.
+type TMDB struct {
+	BaseURL string
+	Token   string
+	Client  *http.Client
+}
+
+// NewTMDB returns a TMDB provider using token. An empty baseURL means the public API.
+func NewTMDB(baseURL, token string) *TMDB {
+	if baseURL == "" {
+		baseURL = tmdbBaseURL
+	}
+	return &TMDB{BaseURL: strings.TrimRight(baseURL, "/"), Token: token, Client: Client("tmdb")}
+}
+
+func (t *TMDB) Name() string {
+	return "tmdb"
+}
+
+type tmdbMovie struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	ReleaseDate string `json:"release_date"`
+	PosterPath  string `json:"poster_path"`
+	Overview    string `json:"overview"`
+}
+
+func (m tmdbMovie) movie() Movie {
+	movie := Movie{ID: m.ID, Title: m.Title, ReleaseDate: m.ReleaseDate, Overview: m.Overview}
+	if m.PosterPath != "" {
+		movie.PosterURL = tmdbImageBaseURL + m.PosterPath
+	}
+	return movie
+}
+
+func (t *TMDB) SearchMovie(ctx context.Context, q string) ([]Movie, error) {
+	var data struct {
+		Results []tmdbMovie `json:"results"`
+	}
+	if err := t.get(ctx, "/search/movie?query="+url.QueryEscape(q), &data); err != nil {
+		return nil, fmt.Errorf("tmdb search: %w", err)
+	}
+
+	movies := make([]Movie, 0, len(data.Results))
+	for _, m := range data.Results {
+		movies = append(movies, m.movie())
+	}
+	return movies, nil
+}
+
+func (t *TMDB) FetchMovie(ctx context.Context, movieID int) (*Movie, error) {
+	var data tmdbMovie
+	if err := t.get(ctx, fmt.Sprintf("/movie/%d", movieID), &data); err != nil {
+		return nil, fmt.Errorf("tmdb movie %d: %w", movieID, err)
+	}
+	movie := data.movie()
+	return &movie, nil
+}
+
+func (t *TMDB) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.Token)
+	req.Header.Set("Accept", "application/json")
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
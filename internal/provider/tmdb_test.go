@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newFakeTMDB serves search and details for movies, checking the bearer token.
+func newFakeTMDB(t *testing.T, movies ...tmdbMovie) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer tmdb-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	mux.HandleFunc("GET /search/movie", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		query := strings.ToLower(r.URL.Query().Get("query"))
+		results := []tmdbMovie{}
+		for _, m := range movies {
+			if strings.Contains(strings.ToLower(m.Title), query) {
+				results = append(results, m)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"results": results})
+	})
+	mux.HandleFunc("GET /movie/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		for _, m := range movies {
+			if fmt.Sprint(m.ID) == r.PathValue("id") {
+				json.NewEncoder(w).Encode(m)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTMDBSearchMovie(t *testing.T) {
+	server := newFakeTMDB(t, tmdbMovie{
+		ID: 693134, Title: "Dune: Part Two", ReleaseDate: "2024-02-27", PosterPath: "/poster.jpg",
+	})
+	provider := NewTMDB(server.URL, "tmdb-token")
+
+	movies, err := provider.SearchMovie(t.Context(), "dune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movies) != 1 {
+		t.Fatalf("got %d movies, want 1", len(movies))
+	}
+	got := movies[0]
+	if got.ID != 693134 || got.Year() != "2024" || got.PosterURL != tmdbImageBaseURL+"/poster.jpg" {
+		t.Errorf("movie = %+v", got)
+	}
+}
+
+func TestTMDBFetchMovie(t *testing.T) {
+	server := newFakeTMDB(t, tmdbMovie{ID: 1, Title: "Untitled", ReleaseDate: ""})
+	provider := NewTMDB(server.URL, "tmdb-token")
+
+	movie, err := provider.FetchMovie(t.Context(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.Title != "Untitled" || movie.Year() != "" || movie.PosterURL != "" {
+		t.Errorf("movie = %+v", movie)
+	}
+	if _, err := NewTMDB(server.URL, "wrong").FetchMovie(t.Context(), 1); err == nil {
+		t.Error("fetch with a bad token succeeded")
+	}
+}
@@ -0,0 +1,310 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type ShowSearchResult struct {
+	ID           int        `json:"id"`
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	Language     string     `json:"language"`
+	Status       string     `json:"status"`
+	Summary      string     `json:"summary"`
+	OfficialSite string     `json:"officialSite"`
+	Ended        *string    `json:"ended"`
+	Premiered    *string    `json:"premiered"`
+	Network      *Network   `json:"network"`
+	WebChannel   *Network   `json:"webChannel"`
+	Image        *Image     `json:"image"`
+	Externals    *Externals `json:"externals,omitempty"`
+}
+
+// Externals are the show's IDs on other sites. TVmaze sends null for unknown ones
+// and doesn't know TMDB IDs, which only TheTVDB fills in.
+type Externals struct {
+	TheTVDB int    `json:"thetvdb"`
+	IMDB    string `json:"imdb"`
+	TMDB    int    `json:"themoviedb,omitempty"`
+}
+
+// ExternalIDs returns the result's IDs on other sites, zero when the provider sent
+// none.
+func (s ShowSearchResult) ExternalIDs() ExternalIDs {
+	if s.Externals == nil {
+		return ExternalIDs{}
+	}
+	return ExternalIDs{TVDB: s.Externals.TheTVDB, IMDB: s.Externals.IMDB, TMDB: s.Externals.TMDB}
+}
+
+type Network struct {
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	Country      *Country `json:"country"`
+	OfficialSite string   `json:"officialSite"`
+}
+
+type Country struct {
+	Name string `json:"name"`
+	Code string `json:"code"`
+}
+
+// Image is a piece of artwork: a show poster or an episode still.
+type Image struct {
+	Medium   string `json:"medium"`
+	Original string `json:"original"`
+}
+
+// URL returns the medium-sized image, which is plenty for Telegram, or the
+// original when that's all there is. It is safe to call on a nil Image.
+func (i *Image) URL() string {
+	if i == nil {
+		return ""
+	}
+	if i.Medium != "" {
+		return i.Medium
+	}
+	return i.Original
+}
+
+// NetworkName returns the broadcast network, falling back to the streaming
+// platform for web-only shows.
+func (s ShowSearchResult) NetworkName() string {
+	if s.Network != nil {
+		return s.Network.Name
+	}
+	if s.WebChannel != nil {
+		return s.WebChannel.Name
+	}
+	return ""
+}
+
+type Episode struct {
+	ID       int    `json:"id"`
+	Season   int    `json:"season"`
+	Number   int    `json:"number"`
+	Name     string `json:"name"`
+	Airdate  string `json:"airdate"`
+	Airtime  string `json:"airtime"`
+	Airstamp string `json:"airstamp"`
+	Summary  string `json:"summary"`
+	Image    *Image `json:"image"`
+	// Runtime is the length in minutes, 0 when unknown.
+	Runtime int `json:"runtime"`
+}
+
+// HTTPClient is the HTTP client shared by the bot, with the timeouts every outside
+// call should have.
+var HTTPClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext:  (&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+		MaxIdleConns: 10,
+	},
+}
+
+const tvmazeBaseURL = "https://api.tvmaze.com"
+
+// TVMaze is the Provider backed by the public TVmaze API.
+type TVMaze struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewTVMaze returns a TVmaze provider talking to baseURL, or to the public API when
+// baseURL is empty. Pointing it at a local server allows running against a fake.
+func NewTVMaze(baseURL string) *TVMaze {
+	if baseURL == "" {
+		baseURL = tvmazeBaseURL
+	}
+	return &TVMaze{BaseURL: strings.TrimRight(baseURL, "/"), Client: Client("tvmaze")}
+}
+
+func (t *TVMaze) Name() string {
+	return "tvmaze"
+}
+
+func (t *TVMaze) SearchShow(ctx context.Context, q string) ([]ShowSearchResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		t.BaseURL+"/search/shows?q="+
+			urlQueryEscape(q), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("tvmaze search: status %d", resp.StatusCode)
+	}
+
+	var raw []struct {
+		Score float64          `json:"score"`
+		Show  ShowSearchResult `json:"show"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	out := make([]ShowSearchResult, 0, len(raw))
+	for _, r := range raw {
+		out = append(out, r.Show)
+	}
+	return out, nil
+}
+
+// FetchEpisodes returns the show's regular episodes, or its specials when it has
+// nothing else, like award shows and other one-off events.
+func (t *TVMaze) FetchEpisodes(ctx context.Context, showID int) ([]Episode, error) {
+	eps, err := t.fetchEpisodes(ctx, fmt.Sprintf("%s/shows/%d/episodes", t.BaseURL, showID), showID)
+	if err != nil || len(eps) > 0 {
+		return eps, err
+	}
+	return t.fetchEpisodes(ctx, fmt.Sprintf("%s/shows/%d/episodes?specials=1", t.BaseURL, showID), showID)
+}
+
+func (t *TVMaze) fetchEpisodes(ctx context.Context, url string, showID int) ([]Episode, error) {
+	log.Printf("Fetching episodes: %s", url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return nil, fmt.Errorf("tvmaze show %d: %w", showID, ErrShowNotFound)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var eps []Episode
+	if err := json.NewDecoder(resp.Body).Decode(&eps); err != nil {
+		return nil, err
+	}
+	return eps, nil
+}
+
+// WatchOptions reports the show's web channel (Netflix, Prime Video, ...) as its
+// streaming option. TVmaze leaves the country out for worldwide services.
+func (t *TVMaze) WatchOptions(ctx context.Context, showID int) ([]WatchOption, error) {
+	show, err := t.fetchShow(ctx, showID)
+	if err != nil {
+		return nil, err
+	}
+	if show.WebChannel == nil {
+		return nil, nil
+	}
+	option := WatchOption{Service: show.WebChannel.Name, URL: show.WebChannel.OfficialSite}
+	if show.WebChannel.Country != nil {
+		option.Country = show.WebChannel.Country.Code
+	}
+	return []WatchOption{option}, nil
+}
+
+func (t *TVMaze) ExternalIDs(ctx context.Context, showID int) (ExternalIDs, error) {
+	show, err := t.fetchShow(ctx, showID)
+	if err != nil {
+		return ExternalIDs{}, err
+	}
+	return show.ExternalIDs(), nil
+}
+
+// LookupShow finds a show by its TheTVDB or IMDb ID. TVmaze answers with a redirect
+// to the show, which the client follows.
+func (t *TVMaze) LookupShow(ctx context.Context, ids ExternalIDs) (int, error) {
+	var queries []string
+	if ids.TVDB != 0 {
+		queries = append(queries, fmt.Sprintf("thetvdb=%d", ids.TVDB))
+	}
+	if ids.IMDB != "" {
+		queries = append(queries, "imdb="+url.QueryEscape(ids.IMDB))
+	}
+	for _, query := range queries {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.BaseURL+"/lookup/shows?"+query, nil)
+		if err != nil {
+			return 0, err
+		}
+		resp, err := t.Client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		var show ShowSearchResult
+		switch resp.StatusCode {
+		case http.StatusOK:
+			err = json.NewDecoder(resp.Body).Decode(&show)
+		case http.StatusNotFound:
+		default:
+			err = fmt.Errorf("tvmaze lookup: status %d", resp.StatusCode)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return 0, err
+		}
+		if show.ID != 0 {
+			return show.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// UpdatedShows lists the shows TVmaze changed in the past week, including changes
+// to their episodes.
+func (t *TVMaze) UpdatedShows(ctx context.Context) (map[string]time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.BaseURL+"/updates/shows?since=week", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("tvmaze updates: status %d", resp.StatusCode)
+	}
+	var raw map[string]int64
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	updated := make(map[string]time.Time, len(raw))
+	for id, at := range raw {
+		updated[id] = time.Unix(at, 0)
+	}
+	return updated, nil
+}
+
+func (t *TVMaze) fetchShow(ctx context.Context, showID int) (*ShowSearchResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/shows/%d", t.BaseURL, showID), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("tvmaze show: status %d", resp.StatusCode)
+	}
+	var show ShowSearchResult
+	if err := json.NewDecoder(resp.Body).Decode(&show); err != nil {
+		return nil, err
+	}
+	return &show, nil
+}
+
+func urlQueryEscape(s string) string {
+	return (&url.URL{Path: s}).EscapedPath()
+}
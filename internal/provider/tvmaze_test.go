@@ -0,0 +1,37 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTVMazeFetchEpisodesFallsBackToSpecials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/shows/1/episodes" && r.URL.Query().Get("specials") == "1":
+			fmt.Fprint(w, `[{"id": 11, "season": 1, "number": 1}, {"id": 12, "season": 1, "number": null, "name": "Special"}]`)
+		case r.URL.Path == "/shows/1/episodes":
+			fmt.Fprint(w, `[{"id": 11, "season": 1, "number": 1}]`)
+		case r.URL.Path == "/shows/2/episodes" && r.URL.Query().Get("specials") == "1":
+			fmt.Fprint(w, `[{"id": 21, "season": 2026, "number": null, "name": "The Awards", "airstamp": "2026-03-15T23:00:00Z"}]`)
+		case r.URL.Path == "/shows/2/episodes":
+			fmt.Fprint(w, `[]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	tvmaze := NewTVMaze(server.URL)
+
+	episodes, err := tvmaze.FetchEpisodes(context.Background(), 1)
+	if err != nil || len(episodes) != 1 {
+		t.Errorf("regular show episodes = %+v, %v, want the one regular episode", episodes, err)
+	}
+	episodes, err = tvmaze.FetchEpisodes(context.Background(), 2)
+	if err != nil || len(episodes) != 1 || episodes[0].Name != "The Awards" {
+		t.Errorf("specials-only show episodes = %+v, %v, want its special", episodes, err)
+	}
+}
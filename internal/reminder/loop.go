@@ -0,0 +1,91 @@
+package reminder
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"tvreminder/bot/internal/clock"
+)
+
+// Store tells Loop when reminders are due.
+type Store interface {
+	// NextDue returns when the next reminder after now is due, the zero time when
+	// none is.
+	NextDue(ctx context.Context, now time.Time) (time.Time, error)
+}
+
+// Sender sends the reminders due at now.
+type Sender interface {
+	SendDue(ctx context.Context, now time.Time)
+}
+
+// Loop sends reminders as they come due on clk, until ctx is cancelled. Whoever
+// changes reminders wakes scheduler.
+func Loop(ctx context.Context, clk clock.Clock, scheduler *Scheduler, store Store, sender Sender) {
+	scheduler.Run(ctx, clk, store.NextDue, sender.SendDue)
+}
+
+// Scheduler runs a job when something is due: it asks for the next due time,
+// sleeps until then and runs the job. Whoever changes what's scheduled calls Wake,
+// so an earlier due time is noticed right away. It wakes at least every maxSleep,
+// in case next misses a due time.
+type Scheduler struct {
+	name     string
+	maxSleep time.Duration
+	wakeups  chan struct{}
+}
+
+func NewScheduler(name string, maxSleep time.Duration) *Scheduler {
+	return &Scheduler{
+		name:     name,
+		maxSleep: maxSleep,
+		// One pending wakeup is enough, they all mean "look again"
+		wakeups: make(chan struct{}, 1),
+	}
+}
+
+// Wake makes the scheduler look for the next due time again. It never blocks, and
+// does nothing on a nil Scheduler.
+func (s *Scheduler) Wake() {
+	if s == nil {
+		return
+	}
+	select {
+	case s.wakeups <- struct{}{}:
+	default:
+	}
+}
+
+// Run alternates between run and sleeping until next reports something is due on
+// clk, until ctx is cancelled. next returns the zero time when nothing is scheduled.
+func (s *Scheduler) Run(
+	ctx context.Context,
+	clk clock.Clock,
+	next func(ctx context.Context, now time.Time) (time.Time, error),
+	run func(ctx context.Context, now time.Time),
+) {
+	for {
+		run(ctx, clk.Now())
+
+		now := clk.Now()
+		sleep := s.maxSleep
+		due, err := next(ctx, now)
+		if err != nil {
+			log.Printf("%s: finding the next due time: %v", s.name, err)
+		} else if !due.IsZero() {
+			sleep = min(sleep, max(due.Sub(now), 0))
+		}
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-timer.C:
+		case <-s.wakeups:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			log.Printf("%s: context cancelled, exiting", s.name)
+			return
+		}
+	}
+}
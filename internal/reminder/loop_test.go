@@ -0,0 +1,46 @@
+package reminder
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"tvreminder/bot/internal/clock"
+)
+
+func TestSchedulerSleepsUntilDue(t *testing.T) {
+	s := NewScheduler("test", time.Hour)
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	start := time.Now()
+	due := start.Add(50 * time.Millisecond)
+	runs := make(chan time.Time, 10)
+	go s.Run(ctx, clock.System{},
+		func(ctx context.Context, now time.Time) (time.Time, error) {
+			if now.Before(due) {
+				return due, nil
+			}
+			return time.Time{}, nil
+		},
+		func(ctx context.Context, now time.Time) { runs <- now },
+	)
+
+	<-runs // Every run starts by looking for due work
+	if at := <-runs; at.Before(due) || at.Sub(due) > time.Second {
+		t.Errorf("ran %s after the start, want %s", at.Sub(start), due.Sub(start))
+	}
+
+	// Nothing is due any more, so only a wakeup runs it again
+	select {
+	case <-runs:
+		t.Fatal("ran with nothing due")
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.Wake()
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatal("Wake didn't run the job")
+	}
+}
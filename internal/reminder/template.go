@@ -0,0 +1,136 @@
+// Package reminder runs the loop sending reminders as they come due, and renders
+// the first line of episode reminders from the templates users and operators
+// write, like "{show} S{season}E{episode} is out at {air_time_local}". Templates
+// are checked when they're set and turned into text/template sources, with the
+// text around placeholders escaped for HTML, so nothing a user writes is run as
+// template code. What's due and how it's sent is up to the bot, see Store and
+// Sender.
+package reminder
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// MaxTemplateLength keeps templates to a line or two.
+const MaxTemplateLength = 300
+
+// DefaultSeasonPackTemplate is the text of reminders about a season released all
+// at once, unless the operator sets another.
+const DefaultSeasonPackTemplate = "Season {season} of {show} ({episodes} episodes) drops {day}!"
+
+// Templates are the operator's templates, from REMINDER_TEMPLATE and
+// SEASON_PACK_TEMPLATE.
+type Templates struct {
+	// Episode rewrites the first line of reminders whose show and user have no
+	// template. Empty keeps the bot's wording.
+	Episode string
+	// SeasonPack is the text of season pack reminders, DefaultSeasonPackTemplate
+	// when empty.
+	SeasonPack string
+}
+
+// placeholders maps placeholders to the TemplateData field they show.
+var placeholders = map[string]string{
+	"show":           "Show",
+	"season":         "Season",
+	"episode":        "Episode",
+	"title":          "Title",
+	"air_time_local": "AirTimeLocal",
+}
+
+// seasonPackPlaceholders are the placeholders of season pack templates.
+var seasonPackPlaceholders = map[string]string{
+	"show":           "Show",
+	"season":         "Season",
+	"episodes":       "Episodes",
+	"day":            "Day",
+	"air_time_local": "AirTimeLocal",
+}
+
+// TemplateData is what placeholders are filled with. Render escapes it for HTML.
+type TemplateData struct {
+	Show         string
+	Season       int
+	Episode      int
+	Title        string
+	AirTimeLocal string
+	// Episodes and Day, like "tomorrow", only fill season pack templates.
+	Episodes int
+	Day      string
+}
+
+var placeholderRe = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+var templateBraces = strings.NewReplacer("{", `{{"{"}}`, "}", `{{"}"}}`)
+
+// ParseTemplate checks a template and compiles it.
+func ParseTemplate(text string) (*template.Template, error) {
+	return parse(text, placeholders)
+}
+
+// ParseSeasonPackTemplate checks a season pack template and compiles it.
+func ParseSeasonPackTemplate(text string) (*template.Template, error) {
+	return parse(text, seasonPackPlaceholders)
+}
+
+func parse(text string, placeholders map[string]string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, errors.New("the template is empty")
+	}
+	if utf8.RuneCountInString(text) > MaxTemplateLength {
+		return nil, fmt.Errorf("the template is longer than %d characters", MaxTemplateLength)
+	}
+
+	var source strings.Builder
+	literal := func(s string) {
+		// Braces are quoted so they can't open template actions
+		source.WriteString(templateBraces.Replace(html.EscapeString(s)))
+	}
+	last := 0
+	for _, m := range placeholderRe.FindAllStringSubmatchIndex(text, -1) {
+		name := text[m[2]:m[3]]
+		field, ok := placeholders[name]
+		if !ok {
+			return nil, fmt.Errorf("{%s} isn't a placeholder I know", name)
+		}
+		literal(text[last:m[0]])
+		source.WriteString("{{." + field + "}}")
+		last = m[1]
+	}
+	literal(text[last:])
+	return template.New("reminder").Parse(source.String())
+}
+
+// Render fills a template with data.
+func Render(text string, data TemplateData) (string, error) {
+	tmpl, err := ParseTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	return execute(tmpl, data)
+}
+
+// RenderSeasonPack fills a season pack template with data.
+func RenderSeasonPack(text string, data TemplateData) (string, error) {
+	tmpl, err := ParseSeasonPackTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	return execute(tmpl, data)
+}
+
+func execute(tmpl *template.Template, data TemplateData) (string, error) {
+	data.Show = html.EscapeString(data.Show)
+	data.Title = html.EscapeString(data.Title)
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
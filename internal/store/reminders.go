@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SeasonPackLead is how early season pack reminders go out: the reminder for the
+// first episode of a season released all at once covers the whole season.
+const SeasonPackLead = 24 * time.Hour
+
+// seasonPackCondition holds for a reminder whose episode e, of show s, opens a
+// season of several episodes airing all at once.
+const seasonPackCondition = `(
+	s.reminder_mode = 'episode' AND e.season > 0 AND e.aired_at_utc IS NOT NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM episodes_cache p
+		WHERE p.provider = e.provider AND p.provider_show_id = e.provider_show_id AND p.season = e.season
+		AND (p.number < e.number OR p.aired_at_utc IS NULL OR p.aired_at_utc != e.aired_at_utc)
+	)
+	AND EXISTS (
+		SELECT 1 FROM episodes_cache p
+		WHERE p.provider = e.provider AND p.provider_show_id = e.provider_show_id AND p.season = e.season
+		AND p.id != e.id
+	)
+)`
+
+// DueReminder is a pending reminder that is due, with everything its message needs.
+type DueReminder struct {
+	ID             int64
+	UserID         int64
+	ShowID         int64
+	EpisodeID      int64
+	RemindAt       time.Time
+	ChatID         int64
+	ThreadID       int
+	ShowName       string
+	EpisodeTitle   string
+	EpisodeNumber  int
+	EpisodeSeason  int
+	ReminderMode   string
+	EpisodeSummary string
+	// EpisodeRuntime is the episode length in minutes, 0 when unknown.
+	EpisodeRuntime int
+	// SeasonEpisodes is the number of the season's last known episode, its finale.
+	SeasonEpisodes int
+	Attempts       int
+	// ImageURL is the episode still, or the show poster when there is none.
+	ImageURL string
+	// StreamingOn lists the services carrying the show in the user's country.
+	StreamingOn string
+	Note        string
+	// DeliveryMode and DiscordWebhookURL pick the notifiers the reminder goes out through.
+	DeliveryMode      string
+	DiscordWebhookURL string
+	// CheckinDelayHours is how long after airing to ask whether the user watched
+	// the episode, 0 when they don't want to be asked.
+	CheckinDelayHours int
+	// AutoAdvance marks the reminded episode watched once the reminder is sent.
+	AutoAdvance bool
+	// Muted reminders are skipped instead of sent.
+	Muted bool
+	// Silent reminders are sent without a sound.
+	Silent bool
+	// Provider, ProviderEpisodeID and IMDBID build the reminder's links.
+	Provider          string
+	ProviderEpisodeID string
+	IMDBID            string
+	Links             string
+	// Template is the template of the reminder's first line: the show's, else the
+	// user's.
+	Template string
+	// AiredAt is when the episode airs, zero when unknown.
+	AiredAt time.Time
+	// SeasonPack reminders cover a season released all at once, see SeasonPackLead.
+	SeasonPack bool
+	// OneOff reminders are about an event rather than an episode.
+	OneOff bool
+	// Timezone and QuietHours are the user's settings, quiet hours holding the
+	// reminder back.
+	Timezone   string
+	QuietHours string
+}
+
+// DueReminders returns the pending reminders due at now, including retries that
+// are due and season packs due within SeasonPackLead, of active users whose show
+// is neither muted for notifications nor dropped.
+func (db *DB) DueReminders(ctx context.Context, now time.Time) ([]DueReminder, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			r.id, r.user_id, r.show_id, r.episode_id, r.remind_at, r.chat_id, COALESCE(r.thread_id, 0), r.attempts,
+			s.name, e.title, e.number, e.season, s.reminder_mode,
+			COALESCE(us.timezone, 'UTC'), COALESCE(us.quiet_hours, ''),
+			CASE WHEN COALESCE(us.show_summaries, 1) = 1 THEN COALESCE(e.summary, '') ELSE '' END,
+			COALESCE(NULLIF(e.image_url, ''), s.poster_url, ''), COALESCE(e.runtime, 0),
+			(
+				SELECT COALESCE(MAX(f.number), 0) FROM episodes_cache f
+				WHERE f.provider = e.provider AND f.provider_show_id = e.provider_show_id AND f.season = e.season
+			),
+			(
+				SELECT COALESCE(group_concat(w.service, ', '), '') FROM watch_options w
+				WHERE w.provider = s.provider AND w.provider_show_id = s.provider_show_id
+				AND (w.country = '' OR COALESCE(us.country, '') IN ('', w.country))
+			),
+			COALESCE(s.note, ''),
+			COALESCE(us.delivery_mode, 'telegram'), COALESCE(us.discord_webhook_url, ''),
+			COALESCE(us.checkin_delay_hours, 24), COALESCE(us.auto_advance, 0),
+			s.provider, e.provider_episode_id, COALESCE(x.imdb, ''), COALESCE(us.reminder_links, 'on'),
+			COALESCE(s.muted_until > ?, 0), COALESCE(s.silent, 0),
+			COALESCE(NULLIF(s.reminder_template, ''), us.reminder_template, ''), COALESCE(e.aired_at_utc, ''),
+			COALESCE(`+seasonPackCondition+`, 0), COALESCE(s.one_off, 0)
+		FROM reminders r
+		LEFT JOIN shows s ON s.id = r.show_id
+		LEFT JOIN episodes_cache e ON e.id = r.episode_id
+		LEFT JOIN user_settings us ON us.user_id = r.user_id
+		LEFT JOIN show_external_ids x ON x.provider = s.provider AND x.provider_show_id = s.provider_show_id
+		WHERE (r.remind_at <= ? OR (r.remind_at <= ? AND `+seasonPackCondition+`))
+		AND s.notifications_enabled = 1
+		AND s.dropped_at IS NULL
+		AND r.status = 'pending'
+		AND r.deleted_at IS NULL
+		AND us.inactive_since IS NULL
+		AND (r.next_attempt_at IS NULL OR r.next_attempt_at <= ?)
+		`, now.UTC().Format(time.RFC3339), now.UTC().Format(time.RFC3339),
+		now.Add(SeasonPackLead).UTC().Format(time.RFC3339), now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []DueReminder
+	for rows.Next() {
+		var r DueReminder
+		var airedAt string
+		if err := rows.Scan(
+			&r.ID, &r.UserID, &r.ShowID, &r.EpisodeID,
+			&r.RemindAt, &r.ChatID, &r.ThreadID, &r.Attempts, &r.ShowName,
+			&r.EpisodeTitle, &r.EpisodeNumber, &r.EpisodeSeason,
+			&r.ReminderMode, &r.Timezone, &r.QuietHours, &r.EpisodeSummary,
+			&r.ImageURL, &r.EpisodeRuntime, &r.SeasonEpisodes, &r.StreamingOn, &r.Note,
+			&r.DeliveryMode, &r.DiscordWebhookURL, &r.CheckinDelayHours,
+			&r.AutoAdvance, &r.Provider, &r.ProviderEpisodeID, &r.IMDBID,
+			&r.Links, &r.Muted, &r.Silent, &r.Template, &airedAt,
+			&r.SeasonPack, &r.OneOff,
+		); err != nil {
+			return nil, err
+		}
+		if t, err := time.Parse(time.RFC3339, airedAt); err == nil {
+			r.AiredAt = t
+		}
+		reminders = append(reminders, r)
+	}
+	return reminders, rows.Err()
+}
+
+// NextReminderDue returns when the next pending reminder, season pack, retry or
+// check-in after now is due, the zero time when there's none.
+func (db *DB) NextReminderDue(ctx context.Context, now time.Time) (time.Time, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var next time.Time
+	for _, query := range []struct {
+		sql string
+		arg any
+		// lead is how much earlier than the time selected the reminder is due
+		lead time.Duration
+	}{
+		{`
+			SELECT remind_at FROM reminders
+			WHERE status = 'pending' AND deleted_at IS NULL AND next_attempt_at IS NULL AND remind_at > ?
+			ORDER BY remind_at LIMIT 1
+		`, now.UTC().Format(time.RFC3339), 0},
+		{`
+			SELECT r.remind_at FROM reminders r
+			JOIN shows s ON s.id = r.show_id
+			JOIN episodes_cache e ON e.id = r.episode_id
+			WHERE r.status = 'pending' AND r.deleted_at IS NULL AND r.next_attempt_at IS NULL AND r.remind_at > ?
+			AND ` + seasonPackCondition + `
+			ORDER BY r.remind_at LIMIT 1
+		`, now.Add(SeasonPackLead).UTC().Format(time.RFC3339), SeasonPackLead},
+		{`
+			SELECT next_attempt_at FROM reminders
+			WHERE status = 'pending' AND deleted_at IS NULL AND next_attempt_at > ?
+			ORDER BY next_attempt_at LIMIT 1
+		`, now.UTC().Format(time.RFC3339), 0},
+		{`
+			SELECT due_at FROM followups
+			WHERE due_at > ?
+			ORDER BY due_at LIMIT 1
+		`, now.UTC().Format(time.RFC3339), 0},
+	} {
+		var due time.Time
+		err := db.QueryRowContext(ctx, query.sql, query.arg).Scan(&due)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		if due = due.Add(-query.lead); next.IsZero() || due.Before(next) {
+			next = due
+		}
+	}
+	return next, nil
+}
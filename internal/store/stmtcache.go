@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+)
+
+// QueryExecer is implemented by *DB, *Tx and *StmtCache.
+type QueryExecer interface {
+	ExecQuerier
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// StmtCache runs queries through statements prepared on first use, so loops that
+// run the same queries for every item only parse them once. Close it when done.
+type StmtCache struct {
+	db *DB
+
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// NewStmtCache returns an empty cache preparing statements on db.
+func NewStmtCache(db *DB) *StmtCache {
+	return &StmtCache{db: db, stmts: make(map[string]*sql.Stmt)}
+}
+
+// WithQueryTimeout limits ctx to the query timeout of the cache's database.
+func (c *StmtCache) WithQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return c.db.WithQueryTimeout(ctx)
+}
+
+func (c *StmtCache) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt, nil
+	}
+	// Statements outlive the query's deadline, which only bounds preparing
+	stmt, err := c.db.PrepareContext(context.WithoutCancel(ctx), query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+func (c *StmtCache) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.ExecContext(ctx, args...)
+}
+
+func (c *StmtCache) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.QueryContext(ctx, args...)
+}
+
+func (c *StmtCache) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		// A *sql.Row can't be made from an error; the unprepared query fails the same way
+		return c.db.QueryRowContext(ctx, query, args...)
+	}
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+// Close closes the prepared statements.
+func (c *StmtCache) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for query, stmt := range c.stmts {
+		if err := stmt.Close(); err != nil {
+			log.Printf("closing prepared statement: %v", err)
+		}
+		delete(c.stmts, query)
+	}
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestStmtCacheReusesStatements(t *testing.T) {
+	db, err := Open(t.Context(), Config{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	stmts := NewStmtCache(db)
+	defer stmts.Close()
+
+	for i := range 3 {
+		if _, err := stmts.ExecContext(t.Context(),
+			`INSERT INTO delivery_log (user_id, failed, created_at) VALUES (?, ?, CURRENT_TIMESTAMP)`, 1001, i == 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var failed int
+	if err := stmts.QueryRowContext(t.Context(), `SELECT COUNT(*) FROM delivery_log WHERE failed = 1`).Scan(&failed); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(stmts.stmts); n != 2 {
+		t.Errorf("prepared %d statements for three inserts and a count, want 2", n)
+	}
+	if failed != 1 {
+		t.Errorf("failed deliveries = %d, want 1", failed)
+	}
+}
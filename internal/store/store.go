@@ -0,0 +1,567 @@
+// Package store opens the bot's SQLite database and keeps its schema up to date,
+// and runs the queries of the reminder loop. The queries of the other features
+// live with them.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// DefaultQueryTimeout is the query timeout of a Config without one.
+const DefaultQueryTimeout = 10 * time.Second
+
+// MaxConns is the size of the database connection pool.
+const MaxConns = 4
+
+// Config says which database to open and how.
+type Config struct {
+	Path string
+	// QueryTimeout bounds every database helper, so a wedged SQLite lock fails the
+	// request instead of hanging the update loop. Zero means DefaultQueryTimeout.
+	QueryTimeout time.Duration
+}
+
+// DB is an open database and the timeout of its queries.
+type DB struct {
+	*sql.DB
+	queryTimeout time.Duration
+}
+
+// WithQueryTimeout limits ctx to the query timeout for one database helper.
+func (db *DB) WithQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, db.queryTimeout)
+}
+
+// BeginTx starts a transaction whose helpers run under the database's query timeout.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, queryTimeout: db.queryTimeout}, nil
+}
+
+// Tx is a transaction of a DB.
+type Tx struct {
+	*sql.Tx
+	queryTimeout time.Duration
+}
+
+// WithQueryTimeout limits ctx to the query timeout for one database helper.
+func (tx *Tx) WithQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, tx.queryTimeout)
+}
+
+// Open opens the database file at cfg.Path, creating and migrating it as needed.
+func Open(ctx context.Context, cfg Config) (*DB, error) {
+	if cfg.QueryTimeout == 0 {
+		cfg.QueryTimeout = DefaultQueryTimeout
+	}
+	// Updates are handled concurrently, so writers wait for the lock instead of
+	// failing with SQLITE_BUSY, and WAL lets readers run alongside a writer. SQLite
+	// doesn't notice a cancelled context while waiting for a lock, so the wait is
+	// capped at the query timeout too. Transactions take the write lock up front:
+	// one that started reading and then tries to write can't wait for the lock and
+	// fails right away if another writer got in between. Foreign keys are enforced
+	// per connection, so they're turned on in the DSN rather than with a PRAGMA.
+	dsn := fmt.Sprintf(
+		"file:%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate",
+		cfg.Path, cfg.QueryTimeout.Milliseconds(),
+	)
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// There's only ever one writer, so more connections just mean more readers
+	// waiting on it. Keep them open: each new one has to run the pragmas again.
+	conn.SetMaxOpenConns(MaxConns)
+	conn.SetMaxIdleConns(MaxConns)
+	db := &DB{DB: conn, queryTimeout: cfg.QueryTimeout}
+
+	_, err = db.ExecContext(ctx, `
+		BEGIN;
+
+		CREATE TABLE IF NOT EXISTS shows (
+		  id INTEGER PRIMARY KEY AUTOINCREMENT,
+		  user_id INTEGER NOT NULL,
+		  name TEXT NOT NULL,
+		  provider TEXT NOT NULL DEFAULT 'local',
+		  provider_show_id TEXT,
+		  timezone TEXT DEFAULT 'UTC',
+		  last_watched_episode_id TEXT,
+		  notifications_enabled INTEGER DEFAULT 1,
+		  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		  UNIQUE(user_id, provider, provider_show_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS episodes_cache (
+		  id INTEGER PRIMARY KEY AUTOINCREMENT,
+		  provider TEXT NOT NULL,
+		  provider_show_id TEXT NOT NULL,
+		  provider_episode_id TEXT NOT NULL,
+		  season INTEGER,
+		  number INTEGER,
+		  title TEXT,
+		  airdate DATE,       -- yyyy-mm-dd
+		  airtime TEXT,       -- hh:mm (provider may supply)
+		  aired_at_utc DATETIME,  -- normalized UTC timestamp if available
+		  fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		  UNIQUE(provider, provider_episode_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS reminders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			show_id INTEGER NOT NULL,
+			episode_id INTEGER,
+			remind_at DATETIME NOT NULL,
+			chat_id INTEGER NOT NULL,
+			FOREIGN KEY (show_id) REFERENCES shows(id),
+			FOREIGN KEY (episode_id) REFERENCES episodes_cache(id),
+			UNIQUE(user_id, show_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS user_settings (
+			user_id INTEGER PRIMARY KEY,
+			chat_id INTEGER NOT NULL,
+			monthly_export_enabled INTEGER DEFAULT 0,
+			last_export_at DATETIME
+		);
+
+		CREATE TABLE IF NOT EXISTS watch_options (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			provider TEXT NOT NULL,
+			provider_show_id TEXT NOT NULL,
+			country TEXT NOT NULL DEFAULT '',
+			service TEXT NOT NULL,
+			url TEXT DEFAULT '',
+			fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(provider, provider_show_id, country, service)
+		);
+
+		CREATE TABLE IF NOT EXISTS group_shows (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			provider TEXT NOT NULL,
+			provider_show_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			last_watched_episode_id INTEGER,
+			last_announced_episode_id INTEGER,
+			created_by INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			UNIQUE(chat_id, provider, provider_show_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS group_members (
+			group_show_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			username TEXT DEFAULT '',
+			first_name TEXT DEFAULT '',
+			last_watched_episode_id INTEGER,
+			joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (group_show_id) REFERENCES group_shows(id),
+			PRIMARY KEY (group_show_id, user_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS season_ratings (
+			show_id INTEGER NOT NULL,
+			season INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			rating INTEGER NOT NULL,
+			rated_at DATETIME NOT NULL,
+			FOREIGN KEY (show_id) REFERENCES shows(id),
+			PRIMARY KEY (show_id, season)
+		);
+
+		CREATE TABLE IF NOT EXISTS movies (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			provider TEXT NOT NULL,
+			provider_movie_id TEXT NOT NULL,
+			title TEXT NOT NULL,
+			release_date TEXT DEFAULT '',  -- yyyy-mm-dd
+			poster_url TEXT DEFAULT '',
+			overview TEXT DEFAULT '',
+			watched INTEGER DEFAULT 0,
+			reminded INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, provider, provider_movie_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			event TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			attempts INTEGER DEFAULT 0,
+			next_attempt_at DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS followups (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			chat_id INTEGER NOT NULL,
+			show_id INTEGER NOT NULL,
+			episode_id INTEGER NOT NULL,
+			due_at DATETIME NOT NULL,
+			asked INTEGER DEFAULT 0,
+			snoozes INTEGER DEFAULT 0,
+			FOREIGN KEY (show_id) REFERENCES shows(id),
+			UNIQUE(show_id, episode_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS abuse_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			username TEXT NOT NULL DEFAULT '',
+			kind TEXT NOT NULL,
+			created_at DATETIME NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS delivery_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			failed INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS show_external_ids (
+			provider TEXT NOT NULL,
+			provider_show_id TEXT NOT NULL,
+			tvdb INTEGER NOT NULL DEFAULT 0,
+			imdb TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (provider, provider_show_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS trakt_pushes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			show_id INTEGER NOT NULL,
+			episode_id INTEGER NOT NULL,
+			watched_at DATETIME NOT NULL,
+			attempts INTEGER DEFAULT 0,
+			next_attempt_at DATETIME NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS incidents (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			chat_id INTEGER NOT NULL,
+			error TEXT NOT NULL,
+			user_message TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS group_settings (
+			chat_id INTEGER PRIMARY KEY,
+			admins_only BOOLEAN NOT NULL DEFAULT 0
+		);
+
+		CREATE TABLE IF NOT EXISTS reminder_messages (
+			chat_id INTEGER NOT NULL,
+			message_id INTEGER NOT NULL,
+			thread_id INTEGER NOT NULL DEFAULT 0,
+			user_id INTEGER NOT NULL,
+			show_id INTEGER NOT NULL,
+			episode_id INTEGER NOT NULL,
+			sent_at DATETIME NOT NULL,
+			PRIMARY KEY (chat_id, message_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS watch_log (
+			show_id INTEGER NOT NULL,
+			episode_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			watched_at DATETIME NOT NULL,
+			PRIMARY KEY (show_id, episode_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS user_chats (
+			user_id INTEGER NOT NULL,
+			chat_id INTEGER NOT NULL,
+			title TEXT NOT NULL DEFAULT '',
+			thread_id INTEGER NOT NULL DEFAULT 0,
+			seen_at DATETIME NOT NULL,
+			PRIMARY KEY (user_id, chat_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS reminder_targets (
+			show_id INTEGER NOT NULL,
+			chat_id INTEGER NOT NULL,
+			thread_id INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (show_id, chat_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS show_syncs (
+			provider TEXT NOT NULL,
+			provider_show_id TEXT NOT NULL,
+			synced_at DATETIME NOT NULL,
+			PRIMARY KEY (provider, provider_show_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS skipped_episodes (
+			show_id INTEGER NOT NULL,
+			episode_id INTEGER NOT NULL,
+			PRIMARY KEY (show_id, episode_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS premiere_hypes (
+			show_id INTEGER NOT NULL,
+			episode_id INTEGER NOT NULL,
+			sent_at DATETIME NOT NULL,
+			PRIMARY KEY (show_id, episode_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS jobs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL,
+			user_id INTEGER NOT NULL DEFAULT 0,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			run_at DATETIME NOT NULL,
+			locked_at DATETIME,
+			last_error TEXT,
+			created_at DATETIME NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs(status, run_at);
+
+		CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL COLLATE NOCASE,
+			UNIQUE (user_id, name)
+		);
+
+		CREATE TABLE IF NOT EXISTS show_tags (
+			show_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			PRIMARY KEY (show_id, tag_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS reminder_offsets (
+			show_id INTEGER NOT NULL,
+			offset_minutes INTEGER NOT NULL,
+			template TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (show_id, offset_minutes),
+			FOREIGN KEY (show_id) REFERENCES shows(id)
+		);
+
+		CREATE TABLE IF NOT EXISTS offset_reminders (
+			show_id INTEGER NOT NULL,
+			episode_id INTEGER NOT NULL,
+			offset_minutes INTEGER NOT NULL,
+			sent_at DATETIME NOT NULL,
+			PRIMARY KEY (show_id, episode_id, offset_minutes)
+		);
+
+		CREATE TABLE IF NOT EXISTS callback_payloads (
+			key TEXT PRIMARY KEY,
+			payload TEXT NOT NULL,
+			expires_at DATETIME NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_callback_payloads_expiry ON callback_payloads(expires_at);
+
+		CREATE INDEX IF NOT EXISTS idx_shows_user ON shows(user_id);
+		CREATE INDEX IF NOT EXISTS idx_episodes_show
+			ON episodes_cache(provider, provider_show_id);
+
+		COMMIT;
+	`)
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := migrate(ctx, db); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("migrating db: %w", err)
+	}
+
+	return db, nil
+}
+
+// Migrations are applied in order on top of the base schema above. The number
+// of applied migrations is tracked in PRAGMA user_version, so entries must
+// only ever be appended.
+var Migrations = []string{
+	`ALTER TABLE episodes_cache ADD COLUMN summary TEXT DEFAULT ''`,
+	`ALTER TABLE shows ADD COLUMN network TEXT DEFAULT ''`,
+	`ALTER TABLE shows ADD COLUMN pinned INTEGER DEFAULT 0`,
+	`ALTER TABLE shows ADD COLUMN last_watched_at DATETIME`,
+	`ALTER TABLE shows ADD COLUMN reminder_mode TEXT DEFAULT 'episode'`,
+	`ALTER TABLE user_settings ADD COLUMN timezone TEXT DEFAULT 'UTC'`,
+	`ALTER TABLE user_settings ADD COLUMN quiet_hours TEXT DEFAULT ''`,
+	`ALTER TABLE user_settings ADD COLUMN show_summaries INTEGER DEFAULT 1`,
+	`ALTER TABLE reminders ADD COLUMN status TEXT DEFAULT 'pending'`,
+	`ALTER TABLE reminders ADD COLUMN attempts INTEGER DEFAULT 0`,
+	`ALTER TABLE reminders ADD COLUMN next_attempt_at DATETIME`,
+	`ALTER TABLE reminders ADD COLUMN last_error TEXT`,
+	`ALTER TABLE user_settings ADD COLUMN inactive_since DATETIME`,
+	`ALTER TABLE shows ADD COLUMN notifications_auto_disabled INTEGER DEFAULT 0`,
+	`ALTER TABLE shows ADD COLUMN poster_url TEXT DEFAULT ''`,
+	`ALTER TABLE episodes_cache ADD COLUMN image_url TEXT DEFAULT ''`,
+	`ALTER TABLE user_settings ADD COLUMN country TEXT DEFAULT ''`,
+	`ALTER TABLE user_settings ADD COLUMN airtime_alerts INTEGER DEFAULT 1`,
+	`ALTER TABLE shows ADD COLUMN note TEXT DEFAULT ''`,
+	`ALTER TABLE user_settings ADD COLUMN referred_by INTEGER`,
+	`ALTER TABLE user_settings ADD COLUMN webhook_url TEXT DEFAULT ''`,
+	`ALTER TABLE user_settings ADD COLUMN webhook_secret TEXT DEFAULT ''`,
+	`ALTER TABLE user_settings ADD COLUMN discord_webhook_url TEXT DEFAULT ''`,
+	`ALTER TABLE user_settings ADD COLUMN delivery_mode TEXT DEFAULT 'telegram'`,
+	`ALTER TABLE user_settings ADD COLUMN email TEXT DEFAULT ''`,
+	`ALTER TABLE user_settings ADD COLUMN email_code TEXT DEFAULT ''`,
+	`ALTER TABLE user_settings ADD COLUMN email_verified INTEGER DEFAULT 0`,
+	`ALTER TABLE user_settings ADD COLUMN digest TEXT DEFAULT ''`,
+	`ALTER TABLE user_settings ADD COLUMN digest_delivery TEXT DEFAULT 'telegram'`,
+	`ALTER TABLE user_settings ADD COLUMN last_digest_at DATETIME`,
+	`ALTER TABLE user_settings ADD COLUMN feed_token TEXT`,
+	`CREATE UNIQUE INDEX idx_user_settings_feed_token ON user_settings(feed_token)`,
+	`ALTER TABLE user_settings ADD COLUMN checkin_delay_hours INTEGER DEFAULT 24`,
+	`ALTER TABLE user_settings ADD COLUMN auto_advance INTEGER DEFAULT 0`,
+	`ALTER TABLE shows ADD COLUMN release_service TEXT DEFAULT ''`,
+	`ALTER TABLE shows ADD COLUMN release_delay_hours INTEGER DEFAULT 0`,
+	`ALTER TABLE reminders ADD COLUMN thread_id INTEGER DEFAULT 0`,
+	`ALTER TABLE followups ADD COLUMN thread_id INTEGER DEFAULT 0`,
+	`ALTER TABLE episodes_cache ADD COLUMN runtime INTEGER DEFAULT 0`,
+	`ALTER TABLE shows ADD COLUMN deleted_at DATETIME`,
+	`ALTER TABLE reminders ADD COLUMN deleted_at DATETIME`,
+	// Foreign keys used to be declared but not enforced, so older databases can have
+	// rows pointing at shows that are gone
+	`DELETE FROM reminders WHERE show_id NOT IN (SELECT id FROM shows)
+		OR episode_id NOT IN (SELECT id FROM episodes_cache)`,
+	`DELETE FROM followups WHERE show_id NOT IN (SELECT id FROM shows)`,
+	`DELETE FROM season_ratings WHERE show_id NOT IN (SELECT id FROM shows)`,
+	`DELETE FROM group_members WHERE group_show_id NOT IN (SELECT id FROM group_shows)`,
+	`CREATE INDEX idx_reminders_due ON reminders(status, remind_at)`,
+	`CREATE INDEX idx_reminders_retry ON reminders(status, next_attempt_at)`,
+	`CREATE INDEX idx_followups_due ON followups(asked, due_at)`,
+	// A reminder is kept per episode once sent, so the same episode can't be
+	// reminded about twice. SQLite can't change a table's constraints in place.
+	`
+		CREATE TABLE reminders_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			show_id INTEGER NOT NULL,
+			episode_id INTEGER,
+			remind_at DATETIME NOT NULL,
+			chat_id INTEGER NOT NULL,
+			status TEXT DEFAULT 'pending',
+			attempts INTEGER DEFAULT 0,
+			next_attempt_at DATETIME,
+			last_error TEXT,
+			thread_id INTEGER DEFAULT 0,
+			deleted_at DATETIME,
+			sent_at DATETIME,
+			FOREIGN KEY (show_id) REFERENCES shows(id),
+			FOREIGN KEY (episode_id) REFERENCES episodes_cache(id),
+			UNIQUE(user_id, show_id, episode_id)
+		);
+		INSERT INTO reminders_new (
+			id, user_id, show_id, episode_id, remind_at, chat_id, status, attempts, next_attempt_at,
+			last_error, thread_id, deleted_at
+		)
+		SELECT
+			id, user_id, show_id, episode_id, remind_at, chat_id, status, attempts, next_attempt_at,
+			last_error, thread_id, deleted_at
+		FROM reminders;
+		DROP TABLE reminders;
+		ALTER TABLE reminders_new RENAME TO reminders;
+		CREATE INDEX idx_reminders_due ON reminders(status, remind_at);
+		CREATE INDEX idx_reminders_retry ON reminders(status, next_attempt_at);
+	`,
+	`ALTER TABLE user_settings ADD COLUMN trakt_access_token TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE user_settings ADD COLUMN trakt_refresh_token TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE user_settings ADD COLUMN trakt_expires_at DATETIME`,
+	`ALTER TABLE user_settings ADD COLUMN trakt_synced_at DATETIME`,
+	`ALTER TABLE user_settings ADD COLUMN reminder_links TEXT NOT NULL DEFAULT 'on'`,
+	`ALTER TABLE shows ADD COLUMN muted_until DATETIME`,
+	`ALTER TABLE shows ADD COLUMN provider_missing_at DATETIME`,
+	`ALTER TABLE user_settings ADD COLUMN tonight_hour INTEGER`,
+	`ALTER TABLE user_settings ADD COLUMN last_tonight_at DATETIME`,
+	`ALTER TABLE user_settings ADD COLUMN last_recap_year INTEGER`,
+	`ALTER TABLE shows ADD COLUMN silent INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE user_settings ADD COLUMN digest_silent INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE user_settings ADD COLUMN premiere_hype INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE user_settings ADD COLUMN reminder_template TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE shows ADD COLUMN reminder_template TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE shows ADD COLUMN sort_order INTEGER`,
+	`ALTER TABLE shows ADD COLUMN archived INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE shows ADD COLUMN dropped_at DATETIME`,
+	`ALTER TABLE shows ADD COLUMN drop_reason TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE reminders ADD COLUMN message_id INTEGER`,
+	`ALTER TABLE reminders ADD COLUMN interacted_at DATETIME`,
+	`ALTER TABLE reminders ADD COLUMN escalated_at DATETIME`,
+	`CREATE INDEX idx_reminders_message ON reminders(chat_id, message_id)`,
+	`ALTER TABLE show_external_ids ADD COLUMN tmdb INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE shows ADD COLUMN web_channel TEXT NOT NULL DEFAULT ''`,
+	// Reminder times were written in Go's time.String format, like "2024-03-10
+	// 21:00:00 -0400 EDT", which doesn't compare with the RFC3339 UTC times they're
+	// checked against. The offset after the second space moves them to UTC.
+	`UPDATE reminders SET remind_at = strftime('%Y-%m-%dT%H:%M:%SZ',
+		substr(remind_at, 1, 19) ||
+		substr(remind_at, 12 + instr(substr(remind_at, 12), ' '), 3) || ':' ||
+		substr(remind_at, 15 + instr(substr(remind_at, 12), ' '), 2)
+	) WHERE substr(remind_at, 11, 1) = ' ' AND instr(substr(remind_at, 12), ' ') > 0`,
+	`ALTER TABLE user_settings ADD COLUMN digest_tag TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE user_settings ADD COLUMN share_stats INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE shows ADD COLUMN one_off INTEGER NOT NULL DEFAULT 0`,
+}
+
+func migrate(ctx context.Context, db *DB) error {
+	var version int
+	if err := db.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(Migrations); i++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, Migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Timeouter is implemented by *DB, *Tx and *StmtCache, which know the timeout of
+// their queries.
+type Timeouter interface {
+	WithQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc)
+}
+
+// Querier is implemented by *DB, *Tx and *StmtCache.
+type Querier interface {
+	Timeouter
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Execer is implemented by *DB, *Tx and *StmtCache.
+type Execer interface {
+	Timeouter
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ExecQuerier is implemented by *DB, *Tx and *StmtCache.
+type ExecQuerier interface {
+	Execer
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
@@ -0,0 +1,102 @@
+// Package telegram holds the bot's Telegram plumbing that doesn't depend on its
+// features: the API it talks to and the signing of inline keyboard callbacks.
+package telegram
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// API is the part of the Telegram Bot API client the bot uses.
+type API interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error)
+	GetFile(config tgbotapi.FileConfig) (tgbotapi.File, error)
+}
+
+// MaxCallbackData is Telegram's limit for the callback data of a button, in bytes.
+const MaxCallbackData = 64
+
+// CallbackTTL is how long an inline keyboard stays usable. Menus are a snapshot
+// of the user's shows and settings, so old ones would offer stale choices.
+const CallbackTTL = 2 * time.Hour
+
+// LastingCallbackTTL is how long the keyboards of notifications stay usable, like
+// check-ins. They ask about one thing rather than show a snapshot, and users
+// answer them whenever they read them.
+const LastingCallbackTTL = 30 * 24 * time.Hour
+
+var (
+	ErrCallbackInvalid = errors.New("invalid callback signature")
+	ErrCallbackExpired = errors.New("callback expired")
+)
+
+// Signer signs callback data, and whatever else the bot hands out and wants back
+// unchanged, like dashboard sessions.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner returns a Signer with key. Without a key it makes a random one, so
+// everything it signed expires when the process restarts.
+func NewSigner(key []byte) *Signer {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+	}
+	return &Signer{key: key}
+}
+
+// MAC is the HMAC of data.
+func (s *Signer) MAC(data string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// SignCallback appends the expiry time and a truncated HMAC to callback data,
+// giving "action:param|expires|mac", 19 bytes longer than data. The MAC covers the
+// chat the keyboard is sent to, the user in private chats, so a button can't be
+// replayed from another chat. Data too long to fit in MaxCallbackData once signed
+// has to be stored server side.
+func (s *Signer) SignCallback(data string, chatID int64, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 36)
+	return data + "|" + exp + "|" + s.callbackMAC(data, chatID, exp)
+}
+
+// VerifyCallback checks a callback signed for chatID and returns the original data.
+func (s *Signer) VerifyCallback(signed string, chatID int64, now time.Time) (string, error) {
+	parts := strings.Split(signed, "|")
+	if len(parts) != 3 {
+		return "", ErrCallbackInvalid
+	}
+	data, exp, mac := parts[0], parts[1], parts[2]
+	if !hmac.Equal([]byte(mac), []byte(s.callbackMAC(data, chatID, exp))) {
+		return "", ErrCallbackInvalid
+	}
+	expires, err := strconv.ParseInt(exp, 36, 64)
+	if err != nil {
+		return "", ErrCallbackInvalid
+	}
+	if now.After(time.Unix(expires, 0)) {
+		return "", ErrCallbackExpired
+	}
+	return data, nil
+}
+
+// callbackMAC is the truncated HMAC of data, its chat and its expiry time.
+func (s *Signer) callbackMAC(data string, chatID int64, expires string) string {
+	mac := s.MAC(strconv.FormatInt(chatID, 10) + "|" + data + "|" + expires)
+	return base64.RawURLEncoding.EncodeToString(mac[:8])
+}
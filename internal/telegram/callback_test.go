@@ -0,0 +1,59 @@
+package telegram
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyCallback(t *testing.T) {
+	signer := NewSigner(nil)
+	now := time.Now()
+	signed := signer.SignCallback("selectShow:3:current", 1001, now.Add(CallbackTTL))
+
+	tests := []struct {
+		name    string
+		data    string
+		chatID  int64
+		at      time.Time
+		want    string
+		wantErr error
+	}{
+		{name: "valid", data: signed, chatID: 1001, at: now, want: "selectShow:3:current"},
+		{name: "near expiry", data: signed, chatID: 1001, at: now.Add(CallbackTTL - time.Minute), want: "selectShow:3:current"},
+		{name: "expired", data: signed, chatID: 1001, at: now.Add(CallbackTTL + time.Minute), wantErr: ErrCallbackExpired},
+		{name: "unsigned", data: "selectShow:3:current", chatID: 1001, at: now, wantErr: ErrCallbackInvalid},
+		{name: "tampered", data: "selectShow:4:current" + signed[len("selectShow:3:current"):], chatID: 1001, at: now, wantErr: ErrCallbackInvalid},
+		{name: "other chat", data: signed, chatID: 1002, at: now, wantErr: ErrCallbackInvalid},
+		{name: "garbage", data: "a|b|c|d", chatID: 1001, at: now, wantErr: ErrCallbackInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := signer.VerifyCallback(tt.data, tt.chatID, tt.at)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("data = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallbackFromAnotherSigner(t *testing.T) {
+	now := time.Now()
+	signed := NewSigner([]byte("one secret")).SignCallback("reminders", 1001, now.Add(CallbackTTL))
+	if _, err := NewSigner([]byte("another secret")).VerifyCallback(signed, 1001, now); !errors.Is(err, ErrCallbackInvalid) {
+		t.Errorf("err = %v, want %v", err, ErrCallbackInvalid)
+	}
+	if got, err := NewSigner([]byte("one secret")).VerifyCallback(signed, 1001, now); err != nil || got != "reminders" {
+		t.Errorf("verifying with the same secret = %q, %v", got, err)
+	}
+}
+
+func TestSignedCallbackFitsTelegramLimit(t *testing.T) {
+	if got := NewSigner(nil).SignCallback("toggleReminderMode:9999:history", -1001234567890, time.Now().Add(LastingCallbackTTL)); len(got) > 64 {
+		t.Errorf("signed callback %q is %d bytes, Telegram allows 64", got, len(got))
+	}
+}
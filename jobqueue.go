@@ -0,0 +1,336 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Work that must survive a restart, like importing a user's file, goes through the
+// jobs table instead of a goroutine. A pool of workers claims due jobs in a
+// transaction, so each job runs on one worker at a time, and deletes them once
+// they're done. Failed jobs are retried with backoff and kept as failed after
+// maxJobAttempts for /admin jobs. A job whose worker died is claimed again once
+// its lease runs out, so jobs must be safe to run twice.
+
+const (
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusFailed  = "failed"
+)
+
+// Job kinds, each run by its entry in Handler.jobFuncs.
+const (
+	JobKindImport = "import"
+)
+
+const maxJobAttempts = 5
+
+// jobWorkers is how many jobs run at once.
+const jobWorkers = 4
+
+// jobLease is how long a running job is left to its worker before another one
+// claims it again.
+const jobLease = 30 * time.Minute
+
+// failedJobRetention is how long failed jobs are kept for /admin jobs.
+const failedJobRetention = 30 * 24 * time.Hour
+
+// errJobPermanent marks job errors retrying can't fix, like a payload that doesn't decode.
+var errJobPermanent = errors.New("permanent job failure")
+
+type Job struct {
+	ID     int64
+	Kind   string
+	UserID int64
+	// Payload is the job's JSON arguments.
+	Payload string
+	Status  string
+	// Attempts counts the runs so far, including the current one.
+	Attempts  int
+	LastError string
+	RunAt     time.Time
+	CreatedAt time.Time
+}
+
+// jobFunc runs one job, returning an error to have it retried.
+type jobFunc func(ctx context.Context, job Job) error
+
+// enqueueJob queues a job of kind with payload as its arguments, to run as soon as
+// a worker is free. userID is 0 for jobs that don't belong to a user.
+func enqueueJob(ctx context.Context, db Execer, kind string, userID int64, payload any) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO jobs (kind, user_id, payload, status, run_at, created_at) VALUES (?, ?, ?, ?, ?, ?)
+	`, kind, userID, string(data), JobStatusPending, now, now)
+	return err
+}
+
+// claimJob marks the next due job running and returns it, nil when none is due.
+// Transactions take the write lock when they begin (_txlock=immediate), so two
+// workers never claim the same job.
+func claimJob(ctx context.Context, db *store.DB, now time.Time) (*Job, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var job Job
+	var runAt, createdAt string
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, kind, user_id, payload, attempts, run_at, created_at FROM jobs
+		WHERE (status = ? AND run_at <= ?) OR (status = ? AND locked_at <= ?)
+		ORDER BY run_at, id
+		LIMIT 1
+	`, JobStatusPending, now.UTC().Format(time.RFC3339),
+		JobStatusRunning, now.Add(-jobLease).UTC().Format(time.RFC3339),
+	).Scan(&job.ID, &job.Kind, &job.UserID, &job.Payload, &job.Attempts, &runAt, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job.RunAt, _ = time.Parse(time.RFC3339, runAt)
+	job.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	job.Attempts++
+	job.Status = JobStatusRunning
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE jobs SET status = ?, attempts = ?, locked_at = ? WHERE id = ?
+	`, JobStatusRunning, job.Attempts, now.UTC().Format(time.RFC3339), job.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &job, tx.Commit()
+}
+
+// finishJob removes a job that ran successfully.
+func finishJob(ctx context.Context, db *store.DB, jobID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `DELETE FROM jobs WHERE id = ?`, jobID)
+	return err
+}
+
+// failJob schedules a failed job's retry, or marks it failed for good once it's
+// out of attempts or the error is permanent.
+func failJob(ctx context.Context, db *store.DB, job Job, runErr error, now time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	status, runAt := JobStatusPending, now.Add(reminderBackoff(job.Attempts))
+	if job.Attempts >= maxJobAttempts || errors.Is(runErr, errJobPermanent) {
+		status, runAt = JobStatusFailed, now
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE jobs SET status = ?, run_at = ?, locked_at = NULL, last_error = ? WHERE id = ?
+	`, status, runAt.UTC().Format(time.RFC3339), trimString(runErr.Error(), 500), job.ID)
+	return err
+}
+
+// retryJob queues a failed job again with fresh attempts, reporting whether there
+// was such a job.
+func retryJob(ctx context.Context, db *store.DB, jobID int64) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE jobs SET status = ?, attempts = 0, run_at = ? WHERE id = ? AND status = ?
+	`, JobStatusPending, time.Now().UTC().Format(time.RFC3339), jobID, JobStatusFailed)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func purgeFailedJobs(ctx context.Context, db *store.DB, cutoff time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		DELETE FROM jobs WHERE status = ? AND run_at <= ?
+	`, JobStatusFailed, cutoff.UTC().Format(time.RFC3339))
+	return err
+}
+
+// jobFuncs maps each job kind to what runs it.
+func (handler *Handler) jobFuncs() map[string]jobFunc {
+	return map[string]jobFunc{
+		JobKindImport: handler.runImportJob,
+	}
+}
+
+// runJob runs one claimed job and records the outcome.
+func (handler *Handler) runJob(ctx context.Context, job Job) {
+	run, ok := handler.jobFuncs()[job.Kind]
+	var runErr error
+	if !ok {
+		runErr = fmt.Errorf("unknown job kind %q: %w", job.Kind, errJobPermanent)
+	} else {
+		runErr = run(ctx, job)
+	}
+	if runErr == nil {
+		if err := finishJob(ctx, handler.DB, job.ID); err != nil {
+			log.Printf("jobLoop: finishing job %d: %v", job.ID, err)
+		}
+		return
+	}
+	log.Printf("jobLoop: %s job %d failed (attempt %d): %v", job.Kind, job.ID, job.Attempts, runErr)
+	if err := failJob(ctx, handler.DB, job, runErr, time.Now()); err != nil {
+		log.Printf("jobLoop: recording job %d failure: %v", job.ID, err)
+	}
+}
+
+// runDueJobs runs jobs until none is due, returning how many ran.
+func (handler *Handler) runDueJobs(ctx context.Context) int {
+	n := 0
+	for ctx.Err() == nil {
+		job, err := claimJob(ctx, handler.DB, time.Now())
+		if err != nil {
+			log.Printf("jobLoop: claiming job: %v", err)
+			return n
+		}
+		if job == nil {
+			return n
+		}
+		handler.runJob(ctx, *job)
+		handler.Reminders.Wake()
+		n++
+	}
+	return n
+}
+
+func jobLoop(handler *Handler, workers int, ctx context.Context) {
+	// Workers finish the job they're running before jobLoop returns
+	var running sync.WaitGroup
+	defer running.Wait()
+	for range workers {
+		running.Go(func() {
+			ticker := time.NewTicker(5 * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					handler.runDueJobs(ctx)
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := purgeFailedJobs(ctx, handler.DB, time.Now().Add(-failedJobRetention)); err != nil {
+				log.Printf("jobLoop: purging failed jobs: %v", err)
+			}
+		case <-ctx.Done():
+			log.Println("jobLoop: context cancelled, exiting")
+			return
+		}
+	}
+}
+
+// jobCount is how many jobs of a kind are in a status, for /admin jobs.
+type jobCount struct {
+	Kind   string
+	Status string
+	Count  int
+}
+
+func countJobs(ctx context.Context, db *store.DB) ([]jobCount, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT kind, status, COUNT(*) FROM jobs GROUP BY kind, status ORDER BY kind, status
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []jobCount
+	for rows.Next() {
+		var c jobCount
+		if err := rows.Scan(&c.Kind, &c.Status, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// listFailedJobs returns the latest failed jobs, newest first.
+func listFailedJobs(ctx context.Context, db *store.DB, limit int) ([]Job, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, kind, user_id, attempts, COALESCE(last_error, ''), run_at FROM jobs
+		WHERE status = ?
+		ORDER BY run_at DESC, id DESC
+		LIMIT ?
+	`, JobStatusFailed, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		job := Job{Status: JobStatusFailed}
+		var runAt string
+		if err := rows.Scan(&job.ID, &job.Kind, &job.UserID, &job.Attempts, &job.LastError, &runAt); err != nil {
+			return nil, err
+		}
+		job.RunAt, _ = time.Parse(time.RFC3339, runAt)
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func formatJobs(counts []jobCount, failed []Job) string {
+	if len(counts) == 0 {
+		return "No jobs queued."
+	}
+	var b strings.Builder
+	b.WriteString("Jobs:")
+	for _, c := range counts {
+		fmt.Fprintf(&b, "\n%s %s: %d", c.Kind, c.Status, c.Count)
+	}
+	if len(failed) > 0 {
+		b.WriteString("\n\nLatest failures (/admin jobs retry <id> to run one again):")
+		for _, job := range failed {
+			fmt.Fprintf(&b, "\n#%d %s, user %d, %s after %d attempts: %s",
+				job.ID, job.Kind, job.UserID, job.RunAt.Format("Jan 2 15:04"), job.Attempts,
+				trimString(job.LastError, 200))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobRetries(t *testing.T) {
+	env := newTestEnv(t)
+	env.handler.Admins = map[int64]bool{testUserID: true}
+	db := env.handler.DB
+
+	// A payload that doesn't decode fails for good on the first run
+	if err := enqueueJob(t.Context(), db, JobKindImport, testUserID, "not an import"); err != nil {
+		t.Fatal(err)
+	}
+	if n := env.handler.runDueJobs(t.Context()); n != 1 {
+		t.Fatalf("ran %d jobs, want 1", n)
+	}
+	if got := queryString(t, env, `SELECT status || ':' || attempts FROM jobs`); got != "failed:1" {
+		t.Fatalf("job = %s, want failed after 1 attempt", got)
+	}
+
+	env.command("/admin jobs")
+	text := env.telegram.lastMessage(t).Params.Get("text")
+	if !strings.Contains(text, "import failed: 1") || !strings.Contains(text, "permanent job failure") {
+		t.Errorf("/admin jobs = %q", text)
+	}
+
+	env.command("/admin jobs retry " + queryString(t, env, `SELECT id FROM jobs`))
+	if got := queryString(t, env, `SELECT status || ':' || attempts FROM jobs`); got != "pending:0" {
+		t.Fatalf("retried job = %s, want pending", got)
+	}
+
+	// Running jobs are left to their worker until the lease runs out
+	job, err := claimJob(t.Context(), db, time.Now())
+	if err != nil || job == nil {
+		t.Fatalf("claimJob = %v, %v", job, err)
+	}
+	if again, err := claimJob(t.Context(), db, time.Now()); err != nil || again != nil {
+		t.Errorf("claimJob of a running job = %v, %v, want nil", again, err)
+	}
+	again, err := claimJob(t.Context(), db, time.Now().Add(jobLease+time.Minute))
+	if err != nil || again == nil || again.Attempts != 2 {
+		t.Fatalf("claimJob after the lease = %+v, %v, want attempt 2", again, err)
+	}
+
+	// Other failures are retried with backoff until they're out of attempts
+	for attempts := 2; attempts < maxJobAttempts; attempts++ {
+		job := Job{ID: again.ID, Attempts: attempts}
+		if err := failJob(t.Context(), db, job, errNoShowMatch, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if got := queryString(t, env, `SELECT status FROM jobs`); got != JobStatusPending {
+			t.Fatalf("status after attempt %d = %s, want pending", attempts, got)
+		}
+	}
+	if err := failJob(t.Context(), db, Job{ID: again.ID, Attempts: maxJobAttempts}, errNoShowMatch, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := queryString(t, env, `SELECT status FROM jobs`); got != JobStatusFailed {
+		t.Errorf("status after the last attempt = %s, want failed", got)
+	}
+}
@@ -0,0 +1,41 @@
+package bot
+
+import "sync"
+
+// jobTracker keeps track of background jobs, at most one per user at a time.
+// Updates of one user are handled in order, but a job outlives the update that
+// started it, so without the tracker a user could e.g. add two shows at once and
+// have them race for the same set-progress flow.
+type jobTracker struct {
+	mu      sync.Mutex
+	running map[int64]bool
+	wg      sync.WaitGroup
+}
+
+// begin reserves the user's job slot, reporting false if a job is already running.
+// Each successful begin must be paired with a done.
+func (t *jobTracker) begin(userID int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.running[userID] {
+		return false
+	}
+	if t.running == nil {
+		t.running = make(map[int64]bool)
+	}
+	t.running[userID] = true
+	t.wg.Add(1)
+	return true
+}
+
+func (t *jobTracker) done(userID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.running, userID)
+	t.wg.Done()
+}
+
+// wait blocks until every running job has finished.
+func (t *jobTracker) wait() {
+	t.wg.Wait()
+}
@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Reminders carry URL buttons to read about and discuss the episode, depending on
+// the user's /links setting.
+const (
+	ReminderLinksOff = "off"
+	// ReminderLinksOn links the episode's TVmaze page and the show on IMDb.
+	ReminderLinksOn = "on"
+	// ReminderLinksReddit adds a search for Reddit's episode discussion threads.
+	ReminderLinksReddit = "reddit"
+)
+
+// reminderLinksKeyboard returns the link buttons of a reminder, nil when it has none.
+// A batch reminder links its first episode.
+func reminderLinksKeyboard(r DBReminder) *tgbotapi.InlineKeyboardMarkup {
+	if r.Links == ReminderLinksOff || r.Links == "" {
+		return nil
+	}
+	var row []tgbotapi.InlineKeyboardButton
+	if r.Provider == "tvmaze" && r.ProviderEpisodeID != "" {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonURL(
+			"TVmaze", "https://www.tvmaze.com/episodes/"+r.ProviderEpisodeID,
+		))
+	}
+	if r.IMDBID != "" {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonURL(
+			"IMDb", fmt.Sprintf("https://www.imdb.com/title/%s/episodes/?season=%d", url.PathEscape(r.IMDBID), r.EpisodeSeason),
+		))
+	}
+	if r.Links == ReminderLinksReddit {
+		query := fmt.Sprintf("%s S%02dE%02d discussion", r.ShowName, r.EpisodeSeason, r.EpisodeNumber)
+		row = append(row, tgbotapi.NewInlineKeyboardButtonURL(
+			"Reddit", "https://www.reddit.com/search/?q="+url.QueryEscape(query),
+		))
+	}
+	if len(row) == 0 {
+		return nil
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(row)
+	return &markup
+}
+
+func setReminderLinks(ctx context.Context, db *store.DB, userID, chatID int64, links string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET reminder_links = ? WHERE user_id = ?`, links, userID)
+	return err
+}
+
+func describeReminderLinks(links string) string {
+	switch links {
+	case ReminderLinksOff:
+		return "Reminder links: off"
+	case ReminderLinksReddit:
+		return "Reminder links: TVmaze, IMDb and Reddit"
+	default:
+		return "Reminder links: TVmaze and IMDb"
+	}
+}
+
+// LINKS command
+
+func (handler *Handler) handleLinksCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments()))
+
+	switch arg {
+	case ReminderLinksOff, ReminderLinksOn, ReminderLinksReddit:
+		if err := setReminderLinks(ctx, handler.DB, userID, chatID, arg); err != nil {
+			return NewUserError(
+				fmt.Errorf("setting reminder links for user %d: %w", userID, err),
+				"Error saving your settings, please try again later.",
+			)
+		}
+		handler.Bot.reply(ctx, chatID, describeReminderLinks(arg)+".")
+		return nil
+	case "":
+		settings, err := getUserSettings(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting settings for user %d: %w", userID, err),
+				"Error reading your settings, please try again later.",
+			)
+		}
+		handler.Bot.reply(ctx, chatID, describeReminderLinks(settings.ReminderLinks)+
+			".\nUse /links on, /links reddit to add Reddit discussions, or /links off.")
+		return nil
+	default:
+		return NewUserError(
+			fmt.Errorf("invalid links argument: %s", arg),
+			"Usage: /links on|reddit|off",
+		)
+	}
+}
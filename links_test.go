@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+func TestReminderLinks(t *testing.T) {
+	env := dueReminderEnv(t)
+	if _, err := env.handler.DB.Exec(`
+		INSERT INTO show_external_ids (provider, provider_show_id, tvdb, imdb) VALUES ('tvmaze', '1', 0, 'tt0000001')
+	`); err != nil {
+		t.Fatal(err)
+	}
+	env.command("/links reddit")
+
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+
+	var urls []string
+	for _, req := range env.telegram.messages() {
+		if !strings.Contains(req.Params.Get("text"), "is coming out today") {
+			continue
+		}
+		var markup tgbotapi.InlineKeyboardMarkup
+		if err := json.Unmarshal([]byte(req.Params.Get("reply_markup")), &markup); err != nil {
+			t.Fatalf("decoding reply_markup: %v", err)
+		}
+		for _, button := range markup.InlineKeyboard[0] {
+			urls = append(urls, *button.URL)
+		}
+	}
+	want := []string{
+		"https://www.tvmaze.com/episodes/",
+		"https://www.imdb.com/title/tt0000001/episodes/?season=2",
+		"https://www.reddit.com/search/?q=Night+Shift+S02E03+discussion",
+	}
+	if len(urls) != len(want) {
+		t.Fatalf("reminder links = %v, want %v", urls, want)
+	}
+	for i := range want {
+		if !strings.HasPrefix(urls[i], want[i]) {
+			t.Errorf("link %d = %s, want %s", i, urls[i], want[i])
+		}
+	}
+}
+
+func TestReminderLinksOff(t *testing.T) {
+	r := DBReminder{DueReminder: store.DueReminder{Provider: "tvmaze", ProviderEpisodeID: "7", IMDBID: "tt0000001", Links: ReminderLinksOff}}
+	if markup := reminderLinksKeyboard(r); markup != nil {
+		t.Errorf("links with /links off = %+v, want none", markup)
+	}
+}
@@ -1,42 +1,308 @@
-package main
+// Package bot is the TV reminder Telegram bot: its commands and menus, reminders
+// and the background loops keeping shows in sync. The binary in cmd/tvreminderbot
+// runs it with "serve", which is also what it does without a command, and has
+// maintenance commands for operators, see cli.go. Configuration comes from the
+// environment; flags override it where a command has them.
+//
+// Metadata providers, the database, the Telegram plumbing and reminder texts live
+// in the packages under internal/.
+package bot
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	_ "time/tzdata"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/provider"
+	"tvreminder/bot/internal/reminder"
+	"tvreminder/bot/internal/store"
+	"tvreminder/bot/internal/telegram"
 )
 
-func main() {
-	token := os.Getenv("TELEGRAM_BOT_TOKEN")
-	if token == "" {
-		log.Fatal("TELEGRAM_BOT_TOKEN not set")
+// Main runs the command named in args, the arguments without the program name.
+func Main(args []string) {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		printUsage()
+		return
+	}
+	command := findCommand(name)
+	if command == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printUsage()
+		os.Exit(2)
 	}
+	if err := command.Run(args); err != nil {
+		log.Fatalf("%s: %v", name, err)
+	}
+}
+
+// runServe runs the bot until the process is stopped.
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	dbPath := dbPathFlag(flags)
+	restore := flags.Bool("restore", false, "restore the database from the latest backup in BACKUP_DIR before starting")
+	flags.Parse(args)
 
-	botApi, err := tgbotapi.NewBotAPI(token)
+	cfg, err := configFromEnv()
 	if err != nil {
-		log.Fatalf("failted to create bot: %v", err)
+		return err
+	}
+	bot, err := newBotFromEnv(cfg)
+	if err != nil {
+		return err
 	}
-	log.Printf("Authorized on account %s", botApi.Self.UserName)
 
-	db, err := openDB()
+	mailer, err := smtpMailerFromEnv()
 	if err != nil {
-		log.Fatalf("failed to open db: %v", err)
+		return err
 	}
-	defer db.Close()
 
-	bot := &Bot{
-		BotApi:       botApi,
-		UserContexts: make(map[int64]*UserContext),
+	backupConfig, err := backupConfigFromEnv()
+	if err != nil {
+		return err
 	}
+	if *restore {
+		if backupConfig == nil {
+			return fmt.Errorf("-restore needs BACKUP_DIR")
+		}
+		restored, err := restoreLatestBackup(backupConfig.Dir, *dbPath)
+		if err != nil {
+			return fmt.Errorf("failed to restore backup: %w", err)
+		}
+		log.Printf("Restored database from %s", restored)
+	}
+
+	var retention time.Duration
+	if days := os.Getenv("INACTIVE_USER_RETENTION_DAYS"); days != "" {
+		retentionDays, err := strconv.Atoi(days)
+		if err != nil || retentionDays <= 0 {
+			return fmt.Errorf("invalid INACTIVE_USER_RETENTION_DAYS: %q", days)
+		}
+		retention = time.Duration(retentionDays) * 24 * time.Hour
+	}
+
+	fixture, fakeClock, err := simulationFromEnv()
+	if err != nil {
+		return err
+	}
+	metadata, err := newProviderFromEnv()
+	if err != nil {
+		return err
+	}
+	if fixture != nil {
+		metadata = fixture
+	}
+
+	admins, err := parseAdminIDs(os.Getenv("ADMIN_USER_IDS"))
+	if err != nil {
+		return fmt.Errorf("invalid ADMIN_USER_IDS: %w", err)
+	}
+	var publicURL string
+	addr := os.Getenv("HTTP_ADDR")
+	if addr != "" {
+		publicURL = os.Getenv("PUBLIC_URL")
+		if publicURL == "" {
+			return fmt.Errorf("HTTP_ADDR needs PUBLIC_URL, the address the listener is reachable at")
+		}
+	}
+	var trakt *Trakt
+	if clientID := os.Getenv("TRAKT_CLIENT_ID"); clientID != "" {
+		clientSecret := os.Getenv("TRAKT_CLIENT_SECRET")
+		if clientSecret == "" {
+			return fmt.Errorf("TRAKT_CLIENT_ID needs TRAKT_CLIENT_SECRET")
+		}
+		trakt = NewTrakt(os.Getenv("TRAKT_API_URL"), clientID, clientSecret)
+	}
+
+	// Everything is validated above: returning once the loops run would close the
+	// database under them. SIGTERM from systemd or docker stops the loops, which
+	// finish what they're doing.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := cfg.openDB(ctx, *dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open db: %w", err)
+	}
+	defer db.Close()
+	bot.Callbacks = NewCallbackStore(db)
+
 	bot.setCommands()
 
-	go reminderLoop(bot, db, context.Background())
+	var clk clock.Clock = clock.System{}
+	if fixture != nil {
+		log.Printf("Simulation mode: %s, clock at %s", os.Getenv("SIMULATION"), fakeClock.Now().Format(time.RFC3339))
+		clk = fakeClock
+	}
 
+	reminders := reminder.NewScheduler("reminderLoop", reminderMaxSleep)
 	handler := &Handler{
-		Bot: bot,
-		DB:  db,
+		Bot:      bot,
+		DB:       db,
+		Provider: metadata,
+		// Enough for quick tapping through menus, not for a script.
+		UpdateLimiter: NewRateLimiter(RateLimitUpdates, 30, time.Second),
+		SearchLimiter: NewRateLimiter(RateLimitSearches, 10, 6*time.Second),
+		Admins:        admins,
+		Clock:         clk,
+		Reminders:     reminders,
+		CacheCleanup:  &CacheCleanupStats{},
+		FeedBaseURL:   publicURL,
+		Trakt:         trakt,
+	}
+	if mailer != nil {
+		handler.Mailer = mailer
+	}
+	if token := os.Getenv("TMDB_API_TOKEN"); token != "" {
+		handler.Movies = provider.NewTMDB(os.Getenv("TMDB_API_URL"), token)
+	}
+
+	var loops sync.WaitGroup
+	loops.Go(func() { reminder.Loop(ctx, clk, reminders, reminderQueue{db}, reminderSender{bot, db}) })
+	loops.Go(func() { exportLoop(bot, db, clk, ctx) })
+	loops.Go(func() { watchPartyLoop(bot, db, clk, ctx) })
+	loops.Go(func() { trashLoop(db, clk, ctx) })
+	loops.Go(func() { eventArchiveLoop(db, clk, ctx) })
+	loops.Go(func() { webhookLoop(db, newWebhookClient(10*time.Second), ctx) })
+	if retention > 0 {
+		loops.Go(func() { retentionLoop(db, retention, clk, ctx) })
+	}
+	if backupConfig != nil {
+		loops.Go(func() { backupLoop(db, backupConfig, ctx) })
+	}
+	loops.Go(func() { syncLoop(bot, db, metadata, reminders, clk, ctx) })
+	loops.Go(func() { cacheCleanupLoop(db, bot.Callbacks, handler.CacheCleanup, clk, ctx) })
+	loops.Go(func() { digestLoop(bot, db, handler.Mailer, clk, ctx) })
+	loops.Go(func() { jobLoop(handler, jobWorkers, ctx) })
+	if addr != "" {
+		loops.Go(func() { httpServerLoop(addr, newHTTPHandler(db, bot.Username, bot.Token, bot.Signer, clk), ctx) })
+	}
+	if handler.Movies != nil {
+		loops.Go(func() { movieSyncLoop(db, handler.Movies, ctx) })
+	}
+	if trakt != nil {
+		loops.Go(func() { traktLoop(bot, db, trakt, metadata, reminders, clk, ctx) })
+	}
+	handler.processUpdates(ctx)
+	log.Println("Shutting down, waiting for the loops to finish")
+	loops.Wait()
+	return nil
+}
+
+// dbPathFlag adds the -db flag, which defaults to DB_PATH.
+func dbPathFlag(flags *flag.FlagSet) *string {
+	dbPath := os.Getenv("DB_PATH")
+	if dbPath == "" {
+		dbPath = "tvreminder.db"
+	}
+	return flags.String("db", dbPath, "path of the SQLite database (DB_PATH)")
+}
+
+// envConfig holds the settings every command shares.
+type envConfig struct {
+	// CallbackSecret signs callback data and dashboard sessions. Empty picks a
+	// random key, so menus and sessions end on restart.
+	CallbackSecret []byte
+	// QueryTimeout bounds each database query, zero keeping the store's default.
+	QueryTimeout time.Duration
+	Templates    reminder.Templates
+}
+
+// configFromEnv reads the settings every command shares.
+func configFromEnv() (envConfig, error) {
+	var cfg envConfig
+	if secret := os.Getenv("CALLBACK_SECRET"); secret != "" {
+		// A fixed secret keeps menus working across restarts
+		cfg.CallbackSecret = []byte(secret)
+	}
+	if timeout := os.Getenv("DB_QUERY_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			return envConfig{}, fmt.Errorf("invalid DB_QUERY_TIMEOUT: %q", timeout)
+		}
+		cfg.QueryTimeout = d
+	}
+	if tmpl := os.Getenv("REMINDER_TEMPLATE"); tmpl != "" {
+		if _, err := reminder.ParseTemplate(tmpl); err != nil {
+			return envConfig{}, fmt.Errorf("invalid REMINDER_TEMPLATE: %w", err)
+		}
+		cfg.Templates.Episode = tmpl
+	}
+	if tmpl := os.Getenv("SEASON_PACK_TEMPLATE"); tmpl != "" {
+		if _, err := reminder.ParseSeasonPackTemplate(tmpl); err != nil {
+			return envConfig{}, fmt.Errorf("invalid SEASON_PACK_TEMPLATE: %w", err)
+		}
+		cfg.Templates.SeasonPack = tmpl
+	}
+	return cfg, nil
+}
+
+// openDB opens the database at path with the configured query timeout.
+func (cfg envConfig) openDB(ctx context.Context, path string) (*store.DB, error) {
+	return store.Open(ctx, store.Config{Path: path, QueryTimeout: cfg.QueryTimeout})
+}
+
+// newBotFromEnv logs in to Telegram with TELEGRAM_BOT_TOKEN.
+func newBotFromEnv(cfg envConfig) (*Bot, error) {
+	token := os.Getenv("TELEGRAM_BOT_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN not set")
+	}
+
+	apiEndpoint := tgbotapi.APIEndpoint
+	if endpoint := os.Getenv("TELEGRAM_API_ENDPOINT"); endpoint != "" {
+		apiEndpoint = endpoint
+	}
+	botApi, err := tgbotapi.NewBotAPIWithAPIEndpoint(token, apiEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bot: %w", err)
+	}
+	log.Printf("Authorized on account %s", botApi.Self.UserName)
+
+	fileEndpoint := tgbotapi.FileEndpoint
+	if endpoint := os.Getenv("TELEGRAM_FILE_ENDPOINT"); endpoint != "" {
+		fileEndpoint = endpoint
+	}
+	return &Bot{
+		BotApi:       botApi,
+		Username:     botApi.Self.UserName,
+		Token:        token,
+		FileEndpoint: fileEndpoint,
+		Signer:       telegram.NewSigner(cfg.CallbackSecret),
+		Templates:    cfg.Templates,
+		UserContexts: make(map[int64]*UserContext),
+	}, nil
+}
+
+// newProviderFromEnv picks the metadata provider from METADATA_PROVIDER, defaulting
+// to TVmaze.
+func newProviderFromEnv() (Provider, error) {
+	switch name := os.Getenv("METADATA_PROVIDER"); name {
+	case "", "tvmaze":
+		return provider.NewTVMaze(os.Getenv("TVMAZE_API_URL")), nil
+	case "thetvdb":
+		apiKey := os.Getenv("THETVDB_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("THETVDB_API_KEY not set")
+		}
+		return provider.NewTheTVDB(os.Getenv("THETVDB_API_URL"), apiKey), nil
+	default:
+		return nil, fmt.Errorf("unknown METADATA_PROVIDER %q", name)
 	}
-	handler.processUpdatesForever()
 }
@@ -0,0 +1,318 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// A show added twice, from two providers or two search results, can be merged with
+// /merge. It offers the user's shows that share a name, an IMDb ID or a TheTVDB ID.
+// Merging keeps the show the user is further along in and moves the other's watch
+// history, sent reminders, ratings, tags and settings onto it, matching episodes by
+// season and number. Whatever has no counterpart goes away with the duplicate.
+
+// DuplicateShow is one side of a pair of duplicate shows.
+type DuplicateShow struct {
+	ID       int64
+	Name     string
+	Provider string
+	// Season and Number are the last watched episode, zero when none is.
+	Season int
+	Number int
+}
+
+// progress describes how far the user is in the show.
+func (s DuplicateShow) progress() string {
+	if s.Number == 0 {
+		return "not started"
+	}
+	return fmt.Sprintf("at S%02dE%02d", s.Season, s.Number)
+}
+
+// DuplicatePair is two of a user's shows that look like the same show.
+type DuplicatePair struct {
+	A, B DuplicateShow
+}
+
+// mergeOrder returns the show of the pair to keep and the one to merge into it: the
+// one with the furthest progress, or the one added first.
+func (p DuplicatePair) mergeOrder() (keep, drop DuplicateShow) {
+	if p.B.Season > p.A.Season || (p.B.Season == p.A.Season && p.B.Number > p.A.Number) {
+		return p.B, p.A
+	}
+	return p.A, p.B
+}
+
+// mergeEpisodeTables are the tables holding a show's rows per episode, and
+// mergeShowTables those holding them per show.
+var (
+	mergeEpisodeTables = []string{"watch_log", "skipped_episodes", "premiere_hypes", "offset_reminders", "reminders", "followups", "trakt_pushes", "reminder_messages"}
+	mergeShowTables    = []string{"season_ratings", "reminder_targets", "show_tags", "reminder_offsets"}
+)
+
+// findDuplicateShows returns the pairs of the user's shows with the same name or
+// external IDs.
+func findDuplicateShows(ctx context.Context, db *store.DB, userID int64) ([]DuplicatePair, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			a.id, a.name, a.provider, COALESCE(ea.season, 0), COALESCE(ea.number, 0),
+			b.id, b.name, b.provider, COALESCE(eb.season, 0), COALESCE(eb.number, 0)
+		FROM shows a
+		JOIN shows b ON b.user_id = a.user_id AND b.id > a.id
+		LEFT JOIN show_external_ids xa ON xa.provider = a.provider AND xa.provider_show_id = a.provider_show_id
+		LEFT JOIN show_external_ids xb ON xb.provider = b.provider AND xb.provider_show_id = b.provider_show_id
+		LEFT JOIN episodes_cache ea ON ea.id = a.last_watched_episode_id
+		LEFT JOIN episodes_cache eb ON eb.id = b.last_watched_episode_id
+		WHERE a.user_id = ? AND a.deleted_at IS NULL AND b.deleted_at IS NULL
+		AND (
+			lower(trim(a.name)) = lower(trim(b.name))
+			OR (xa.imdb != '' AND xa.imdb = xb.imdb)
+			OR (xa.tvdb != 0 AND xa.tvdb = xb.tvdb)
+		)
+		ORDER BY lower(a.name), a.id, b.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pairs []DuplicatePair
+	for rows.Next() {
+		var p DuplicatePair
+		if err := rows.Scan(
+			&p.A.ID, &p.A.Name, &p.A.Provider, &p.A.Season, &p.A.Number,
+			&p.B.ID, &p.B.Name, &p.B.Provider, &p.B.Season, &p.B.Number,
+		); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, rows.Err()
+}
+
+// mergeShows merges the user's show dropID into keepID and deletes it. The furthest
+// progress of the two wins. It returns sql.ErrNoRows when either show isn't the
+// user's or is in the trash.
+func mergeShows(ctx context.Context, db *store.DB, userID, keepID, dropID int64) error {
+	if keepID == dropID {
+		return sql.ErrNoRows
+	}
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	type mergedShow struct {
+		provider, providerShowID string
+		season, number           int
+	}
+	load := func(showID int64) (mergedShow, error) {
+		var s mergedShow
+		err := tx.QueryRowContext(ctx, `
+			SELECT s.provider, s.provider_show_id, COALESCE(e.season, 0), COALESCE(e.number, 0)
+			FROM shows s
+			LEFT JOIN episodes_cache e ON e.id = s.last_watched_episode_id
+			WHERE s.id = ? AND s.user_id = ? AND s.deleted_at IS NULL
+		`, showID, userID).Scan(&s.provider, &s.providerShowID, &s.season, &s.number)
+		return s, err
+	}
+	keep, err := load(keepID)
+	if err != nil {
+		return err
+	}
+	drop, err := load(dropID)
+	if err != nil {
+		return err
+	}
+
+	// The duplicate's episodes, by the keeper's episode with the same number
+	rows, err := tx.QueryContext(ctx, `
+		SELECT o.id, n.id
+		FROM episodes_cache o
+		JOIN episodes_cache n ON n.provider = ? AND n.provider_show_id = ?
+			AND n.season = o.season AND n.number = o.number
+		WHERE o.provider = ? AND o.provider_show_id = ?
+	`, keep.provider, keep.providerShowID, drop.provider, drop.providerShowID)
+	if err != nil {
+		return err
+	}
+	moved := make(map[int64]int64)
+	for rows.Next() {
+		var from, to int64
+		if err := rows.Scan(&from, &to); err != nil {
+			rows.Close()
+			return err
+		}
+		moved[from] = to
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if drop.season > keep.season || (drop.season == keep.season && drop.number > keep.number) {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE shows SET
+				last_watched_episode_id = (
+					SELECT n.id FROM episodes_cache n
+					WHERE n.provider = ? AND n.provider_show_id = ? AND n.season = ? AND n.number = ?
+				),
+				last_watched_at = (SELECT last_watched_at FROM shows WHERE id = ?)
+			WHERE id = ?
+		`, keep.provider, keep.providerShowID, drop.season, drop.number, dropID, keepID)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE shows SET note = (SELECT note FROM shows WHERE id = ?)
+		WHERE id = ? AND COALESCE(note, '') = ''
+	`, dropID, keepID)
+	if err != nil {
+		return err
+	}
+
+	for from, to := range moved {
+		for _, table := range mergeEpisodeTables {
+			update := `UPDATE OR IGNORE ` + table + ` SET show_id = ?, episode_id = ? WHERE show_id = ? AND episode_id = ?`
+			if table == "reminders" {
+				// The pending reminder is rebuilt from the merged progress
+				update += ` AND sent_at IS NOT NULL`
+			}
+			if _, err := tx.ExecContext(ctx, update, keepID, to, dropID, from); err != nil {
+				return fmt.Errorf("merging %s: %w", table, err)
+			}
+		}
+	}
+	for _, table := range mergeShowTables {
+		if _, err := tx.ExecContext(ctx, `UPDATE OR IGNORE `+table+` SET show_id = ? WHERE show_id = ?`, keepID, dropID); err != nil {
+			return fmt.Errorf("merging %s: %w", table, err)
+		}
+	}
+
+	// Left behind because the keeper had its own, or there's no counterpart
+	for _, table := range append(mergeEpisodeTables, mergeShowTables...) {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE show_id = ?`, dropID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM shows WHERE id = ?`, dropID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// formatDuplicates lists the pairs /merge offers.
+func formatDuplicates(pairs []DuplicatePair) string {
+	if len(pairs) == 0 {
+		return "You have no duplicate shows. Shows count as duplicates when they have the same name, IMDb ID or TheTVDB ID."
+	}
+	var b strings.Builder
+	b.WriteString("These shows look like duplicates. Merging keeps the one you're further along in and moves the other's history onto it:\n")
+	for _, p := range pairs {
+		fmt.Fprintf(&b, "\n• <b>%s</b> (%s, %s) and <b>%s</b> (%s, %s)",
+			html.EscapeString(p.A.Name), p.A.Provider, p.A.progress(),
+			html.EscapeString(p.B.Name), p.B.Provider, p.B.progress(),
+		)
+	}
+	return b.String()
+}
+
+func duplicatesKeyboard(pairs []DuplicatePair) *tgbotapi.InlineKeyboardMarkup {
+	var rows [][][]string
+	for _, p := range pairs {
+		keep, drop := p.mergeOrder()
+		label := fmt.Sprintf("🔀 Merge %s (%s) into %s (%s)", drop.Name, drop.Provider, keep.Name, keep.Provider)
+		rows = append(rows, [][]string{{label, fmt.Sprintf("mergeShows:%d:%d", keep.ID, drop.ID)}})
+	}
+	return makeKeyboardMarkup(rows)
+}
+
+// MERGE command
+
+func (handler *Handler) handleMergeCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	pairs, err := findDuplicateShows(ctx, handler.DB, msg.From.ID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("finding duplicate shows of user %d: %w", msg.From.ID, err),
+			"Error looking for duplicate shows",
+		)
+	}
+	opts := ReplyOptions{ParseMode: "HTML"}
+	if len(pairs) > 0 {
+		opts.ReplyMarkup = duplicatesKeyboard(pairs)
+	}
+	handler.Bot.reply(ctx, msg.Chat.ID, formatDuplicates(pairs), opts)
+	return nil
+}
+
+// handleMergeShowsCallback merges a pair of duplicate shows from /merge.
+func (handler *Handler) handleMergeShowsCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	keepStr, dropStr, _ := strings.Cut(callbackParam, ":")
+	keepID, err := strconv.ParseInt(keepStr, 10, 64)
+	if err != nil {
+		log.Printf("handleMergeShowsCallback: invalid show ID: %s", keepStr)
+		return nil
+	}
+	dropID, err := strconv.ParseInt(dropStr, 10, 64)
+	if err != nil {
+		log.Printf("handleMergeShowsCallback: invalid show ID: %s", dropStr)
+		return nil
+	}
+	userID := cb.From.ID
+
+	err = mergeShows(ctx, handler.DB, userID, keepID, dropID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return NewUserError(
+			fmt.Errorf("shows %d and %d of user %d can't be merged", keepID, dropID, userID),
+			"These shows can't be merged anymore, see /merge.",
+		)
+	} else if err != nil {
+		return NewUserError(
+			fmt.Errorf("merging show %d into %d: %w", dropID, keepID, err),
+			"Error merging the shows",
+		)
+	}
+	if _, reminderChatID, threadID, err := getShowReminderChat(ctx, handler.DB, keepID); err != nil {
+		log.Printf("handleMergeShowsCallback: getting reminder chat of show %d: %v", keepID, err)
+	} else if _, err := rebuildShowReminder(ctx, handler.DB, userID, keepID, reminderChatID, threadID, handler.Clock.Now()); err != nil {
+		log.Printf("handleMergeShowsCallback: rebuilding reminder of show %d: %v", keepID, err)
+	}
+	// The list in memory still has the merged show, menus reload it instead
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.ShowsList = nil
+	})
+
+	name, err := getShowNameByID(ctx, handler.DB, keepID)
+	if err != nil {
+		log.Printf("handleMergeShowsCallback: getting name of show %d: %v", keepID, err)
+	}
+	text := fmt.Sprintf("Merged into <b>%s</b>.", html.EscapeString(name))
+	opts := ReplyOptions{ParseMode: "HTML", EditMessageID: cb.Message.MessageID}
+	if pairs, err := findDuplicateShows(ctx, handler.DB, userID); err != nil {
+		log.Printf("handleMergeShowsCallback: finding duplicate shows of user %d: %v", userID, err)
+	} else if len(pairs) > 0 {
+		text += "\n\n" + formatDuplicates(pairs)
+		opts.ReplyMarkup = duplicatesKeyboard(pairs)
+	}
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, text, opts)
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"tvreminder/bot/internal/provider"
+)
+
+func TestMergeShows(t *testing.T) {
+	env := newSimulationEnv(t)
+	harbor := func(id int) provider.FixtureShow {
+		show := provider.FixtureShow{ShowSearchResult: provider.ShowSearchResult{ID: id, Name: "Harbor Lights", Status: "Running"}}
+		for n := 1; n <= 3; n++ {
+			show.Episodes = append(show.Episodes, provider.Episode{
+				ID: id*100 + n, Season: 1, Number: n, Airstamp: fmt.Sprintf("2026-03-%02dT20:00:00Z", 7*n-6),
+			})
+		}
+		return show
+	}
+	env.handler.Provider = provider.NewFixture(harbor(1), harbor(4))
+
+	env.command("/merge")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "You have no duplicate shows.") {
+		t.Errorf("/merge without duplicates = %q", got)
+	}
+
+	env.command("/add harbor")
+	env.press("acceptShowName:1")
+	env.press("selectEpisode:1")
+	env.command("/add harbor")
+	env.press("acceptShowName:2")
+	env.press("selectEpisode:2")
+	first := queryString(t, env, `SELECT id FROM shows WHERE provider_show_id = '1'`)
+	second := queryString(t, env, `SELECT id FROM shows WHERE provider_show_id = '4'`)
+	if _, err := env.handler.DB.Exec(`INSERT INTO season_ratings (show_id, season, user_id, rating, rated_at) VALUES (?, 1, ?, 5, '2026-03-01T21:00:00Z')`, first, testUserID); err != nil {
+		t.Fatal(err)
+	}
+
+	env.command("/merge")
+	msg := env.telegram.lastMessage(t)
+	if got := msg.Params.Get("text"); !strings.Contains(got, "<b>Harbor Lights</b> (fixture, at S01E01) and <b>Harbor Lights</b> (fixture, at S01E02)") {
+		t.Errorf("/merge = %q", got)
+	}
+	// The show further along is kept
+	want := fmt.Sprintf("mergeShows:%s:%s", second, first)
+	if got := msg.keyboard(t); len(got) != 1 || got[0] != want {
+		t.Fatalf("/merge keyboard = %q, want %q", got, want)
+	}
+	env.press(want)
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "Merged into <b>Harbor Lights</b>." {
+		t.Errorf("merging replied %q", got)
+	}
+
+	for query, want := range map[string]string{
+		`SELECT group_concat(id) FROM shows`: second,
+		`SELECT e.provider_episode_id FROM shows s JOIN episodes_cache e ON e.id = s.last_watched_episode_id`:                                                          "402",
+		`SELECT group_concat(x) FROM (SELECT w.show_id || ' ' || e.provider_episode_id AS x FROM watch_log w JOIN episodes_cache e ON e.id = w.episode_id ORDER BY 1)`: second + " 401," + second + " 402",
+		`SELECT show_id || ' ' || rating FROM season_ratings`:                                                                                                          second + " 5",
+		`SELECT group_concat(e.provider_episode_id) FROM reminders r JOIN episodes_cache e ON e.id = r.episode_id WHERE r.sent_at IS NULL`:                             "403",
+	} {
+		if got := queryString(t, env, query); got != want {
+			t.Errorf("%s = %s, want %s", query, got, want)
+		}
+	}
+
+	env.press(want)
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "These shows can't be merged anymore, see /merge." {
+		t.Errorf("merging again replied %q", got)
+	}
+}
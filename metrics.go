@@ -0,0 +1,31 @@
+package bot
+
+import (
+	"context"
+	"time"
+)
+
+// Metrics for /admin stats. Reminder deliveries are logged to the database, since
+// they matter across restarts; provider requests are only counted in memory, see
+// provider.RequestStats.
+
+// deliveryLogRetention is how long delivery log entries are kept.
+const deliveryLogRetention = 7 * 24 * time.Hour
+
+// logDelivery records a reminder delivery attempt.
+func logDelivery(ctx context.Context, db Execer, userID int64, failed bool) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	now := time.Now().UTC()
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO delivery_log (user_id, failed, created_at) VALUES (?, ?, ?)
+	`, userID, failed, now.Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		DELETE FROM delivery_log WHERE created_at < ?
+	`, now.Add(-deliveryLogRetention).Format(time.RFC3339))
+	return err
+}
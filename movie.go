@@ -0,0 +1,503 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// movieReminderHour is the local hour of the release day movie reminders go out at.
+const movieReminderHour = 9
+
+const releaseDateLayout = "2006-01-02"
+
+// DBMovie is a movie a user tracks.
+type DBMovie struct {
+	ID              int64
+	UserID          int64
+	ChatID          int64
+	Provider        string
+	ProviderMovieID string
+	Title           string
+	// ReleaseDate is yyyy-mm-dd, empty while the movie has no date.
+	ReleaseDate string
+	PosterURL   string
+	Overview    string
+	Watched     bool
+	Timezone    string
+}
+
+// releaseTime returns midnight of the release day in loc, and false when the movie
+// has no release date.
+func (m DBMovie) releaseTime(loc *time.Location) (time.Time, bool) {
+	date, err := time.ParseInLocation(releaseDateLayout, m.ReleaseDate, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// reminderDue returns when the movie's reminder is due: movieReminderHour on its
+// release day, in the user's time zone.
+func (m DBMovie) reminderDue() (time.Time, bool) {
+	settings := UserSettings{Timezone: m.Timezone}
+	release, ok := m.releaseTime(settings.Location())
+	if !ok {
+		return time.Time{}, false
+	}
+	return release.Add(movieReminderHour * time.Hour), true
+}
+
+// nextMovieReminderDue returns when the next movie reminder after now is due, the
+// zero time when none is due by tomorrow.
+func nextMovieReminderDue(ctx context.Context, db *store.DB, now time.Time) (time.Time, error) {
+	movies, err := listUnremindedMovies(ctx, db, now.UTC().AddDate(0, 0, 1).Format(releaseDateLayout))
+	if err != nil {
+		return time.Time{}, err
+	}
+	var next time.Time
+	for _, m := range movies {
+		due, ok := m.reminderDue()
+		if ok && due.After(now) && (next.IsZero() || due.Before(next)) {
+			next = due
+		}
+	}
+	return next, nil
+}
+
+// addMovie starts tracking movie for the user, or refreshes its details if it is
+// already tracked. Movies already out by today don't get a reminder.
+func addMovie(ctx context.Context, db *store.DB, userID int64, provider string, movie *Movie, today string) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	reminded := movie.ReleaseDate != "" && movie.ReleaseDate <= today
+	var id int64
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO movies (user_id, provider, provider_movie_id, title, release_date, poster_url, overview, reminded)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, provider, provider_movie_id) DO UPDATE SET
+			title = excluded.title, release_date = excluded.release_date,
+			poster_url = excluded.poster_url, overview = excluded.overview
+		RETURNING id
+	`, userID, provider, strconv.Itoa(movie.ID), movie.Title, movie.ReleaseDate, movie.PosterURL, movie.Overview,
+		reminded).Scan(&id)
+	return id, err
+}
+
+const movieColumns = `
+	m.id, m.user_id, COALESCE(us.chat_id, m.user_id), m.provider, m.provider_movie_id, m.title,
+	m.release_date, m.poster_url, m.overview, m.watched, COALESCE(us.timezone, 'UTC')`
+
+func scanMovies(rows *sql.Rows) ([]DBMovie, error) {
+	defer rows.Close()
+	var movies []DBMovie
+	for rows.Next() {
+		var movie DBMovie
+		var watched int
+		err := rows.Scan(
+			&movie.ID, &movie.UserID, &movie.ChatID, &movie.Provider, &movie.ProviderMovieID, &movie.Title,
+			&movie.ReleaseDate, &movie.PosterURL, &movie.Overview, &watched, &movie.Timezone,
+		)
+		if err != nil {
+			return nil, err
+		}
+		movie.Watched = watched == 1
+		movies = append(movies, movie)
+	}
+	return movies, rows.Err()
+}
+
+// listMovies returns the user's movies, unwatched first, then by release date with
+// undated movies last.
+func listMovies(ctx context.Context, db *store.DB, userID int64) ([]DBMovie, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+movieColumns+`
+		FROM movies m
+		LEFT JOIN user_settings us ON us.user_id = m.user_id
+		WHERE m.user_id = ?
+		ORDER BY m.watched, m.release_date = '', m.release_date, m.title
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanMovies(rows)
+}
+
+// listUnremindedMovies returns unwatched movies released by until that haven't been
+// reminded about, for users that are still active.
+func listUnremindedMovies(ctx context.Context, db *store.DB, until string) ([]DBMovie, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+movieColumns+`
+		FROM movies m
+		LEFT JOIN user_settings us ON us.user_id = m.user_id
+		WHERE m.watched = 0 AND m.reminded = 0
+		AND m.release_date != '' AND m.release_date <= ?
+		AND us.inactive_since IS NULL
+	`, until)
+	if err != nil {
+		return nil, err
+	}
+	return scanMovies(rows)
+}
+
+func markMovieReminded(ctx context.Context, db *store.DB, movieID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `UPDATE movies SET reminded = 1 WHERE id = ?`, movieID)
+	return err
+}
+
+// setMovieWatched updates the watched flag of one of the user's movies and returns
+// its title.
+func setMovieWatched(ctx context.Context, db *store.DB, userID, movieID int64, watched bool) (string, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var title string
+	err := db.QueryRowContext(ctx, `
+		UPDATE movies SET watched = ? WHERE id = ? AND user_id = ? RETURNING title
+	`, watched, movieID, userID).Scan(&title)
+	return title, err
+}
+
+func toggleMovieWatched(ctx context.Context, db *store.DB, userID, movieID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE movies SET watched = 1 - watched WHERE id = ? AND user_id = ?
+	`, movieID, userID)
+	return err
+}
+
+// listUnreleasedMovieIDs returns the provider IDs of movies that still await a
+// reminder, whose release dates may move.
+func listUnreleasedMovieIDs(ctx context.Context, db *store.DB, provider string) ([]int, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT provider_movie_id FROM movies
+		WHERE provider = ? AND reminded = 0 AND watched = 0
+	`, provider)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var idStr string
+		if err := rows.Scan(&idStr); err != nil {
+			return nil, err
+		}
+		if id, err := strconv.Atoi(idStr); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+func updateMovieDetails(ctx context.Context, db *store.DB, provider string, movie *Movie) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE movies SET title = ?, release_date = ?, poster_url = ?, overview = ?
+		WHERE provider = ? AND provider_movie_id = ? AND reminded = 0
+	`, movie.Title, movie.ReleaseDate, movie.PosterURL, movie.Overview, provider, strconv.Itoa(movie.ID))
+	return err
+}
+
+func formatReleaseDate(date string) string {
+	t, err := time.Parse(releaseDateLayout, date)
+	if err != nil {
+		return date
+	}
+	return t.Format("Mon Jan 2, 2006")
+}
+
+func formatMovieReminder(m DBMovie) string {
+	var text string
+	if m.PosterURL != "" {
+		// Shown as the link preview, like the poster on show cards
+		text += fmt.Sprintf("<a href=\"%s\">\u200b</a>", html.EscapeString(m.PosterURL))
+	}
+	text += fmt.Sprintf("🎬 <b>%s</b> is out today!", html.EscapeString(m.Title))
+	if m.Overview != "" {
+		text += "\n\n" + spoiler(trimString(m.Overview, 700))
+	}
+	return text
+}
+
+// sendDueMovieReminders reminds users about movies whose release day has reached
+// movieReminderHour in their time zone.
+func sendDueMovieReminders(ctx context.Context, bot *Bot, db *store.DB, now time.Time) {
+	// The release day starts up to 14 hours earlier east of UTC
+	until := now.UTC().AddDate(0, 0, 1).Format(releaseDateLayout)
+	movies, err := listUnremindedMovies(ctx, db, until)
+	if err != nil {
+		log.Printf("reminderLoop: listing due movies: %v", err)
+		return
+	}
+	for _, m := range movies {
+		due, ok := m.reminderDue()
+		if !ok || now.Before(due) {
+			continue
+		}
+
+		keyboard := makeKeyboardMarkup([][][]string{{{"✅ Watched", fmt.Sprintf("movieWatched:%d", m.ID)}}})
+		_, err := bot.send(ctx, m.ChatID, formatMovieReminder(m), ReplyOptions{ReplyMarkup: keyboard, ParseMode: "HTML", Lasting: true})
+		if err != nil && !isChatUnreachable(err) {
+			// Retried on the next run of the reminder loop
+			log.Printf("reminderLoop: sending movie reminder %d to chat %d: %v", m.ID, m.ChatID, err)
+			continue
+		}
+		if err := markMovieReminded(ctx, db, m.ID); err != nil {
+			log.Printf("reminderLoop: marking movie %d reminded: %v", m.ID, err)
+		}
+	}
+}
+
+// syncMovies refreshes the details of movies that haven't come out yet.
+func syncMovies(ctx context.Context, db *store.DB, movies MovieProvider) {
+	ids, err := listUnreleasedMovieIDs(ctx, db, movies.Name())
+	if err != nil {
+		log.Printf("movieSyncLoop: listing movies: %v", err)
+		return
+	}
+	for _, id := range ids {
+		fetchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		movie, err := movies.FetchMovie(fetchCtx, id)
+		cancel()
+		if err != nil {
+			log.Printf("movieSyncLoop: fetching movie %d: %v", id, err)
+			continue
+		}
+		if err := updateMovieDetails(ctx, db, movies.Name(), movie); err != nil {
+			log.Printf("movieSyncLoop: updating movie %d: %v", id, err)
+		}
+	}
+}
+
+func movieSyncLoop(db *store.DB, movies MovieProvider, ctx context.Context) {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			syncMovies(ctx, db, movies)
+		case <-ctx.Done():
+			log.Println("movieSyncLoop: context cancelled, exiting")
+			return
+		}
+	}
+}
+
+// ADDMOVIE command flow
+
+func (handler *Handler) handleAddMovieCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+
+	if handler.Movies == nil {
+		return NewUserError(
+			fmt.Errorf("movie tracking is not configured"),
+			"Movie tracking isn't enabled on this bot.",
+		)
+	}
+	query := msg.CommandArguments()
+	if query == "" {
+		handler.Bot.reply(ctx, chatID, "Send /addmovie <title> to add a movie.")
+		return nil
+	}
+
+	handler.Bot.sendChatAction(chatID, tgbotapi.ChatTyping)
+	searchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	results, err := handler.Movies.SearchMovie(searchCtx, query)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("searching movie %q: %w", query, err),
+			fmt.Sprintf("Error searching movie %s", query),
+		)
+	}
+	if len(results) == 0 {
+		handler.Bot.reply(ctx, chatID, "No movies found for: "+query)
+		return nil
+	}
+	results = results[:min(5, len(results))]
+
+	var rows [][][]string
+	for i, movie := range results {
+		label := fmt.Sprintf("%d. %s", i+1, trimString(movie.Title, 25))
+		if year := movie.Year(); year != "" {
+			label += fmt.Sprintf(" (%s)", year)
+		}
+		rows = append(rows, [][]string{{label, fmt.Sprintf("acceptMovie:%d", i+1)}})
+	}
+	rows = append(rows, [][]string{{"❌ Cancel", "cancel"}})
+
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.MovieResults = results
+	})
+	handler.Bot.reply(ctx, chatID, "Pick the movie you want to add:", ReplyOptions{ReplyMarkup: makeKeyboardMarkup(rows)})
+	return nil
+}
+
+func (handler *Handler) handleAcceptMovieCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	resultIdx, err := strconv.Atoi(callbackParam)
+	if err != nil {
+		log.Printf("handleAcceptMovieCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+
+	userID := cb.From.ID
+	msg := cb.Message
+
+	userCtx := handler.Bot.getUserContext(userID)
+	if userCtx == nil || len(userCtx.MovieResults) == 0 || handler.Movies == nil {
+		handler.Bot.clearState(userID)
+		return NewUserError(
+			fmt.Errorf("no movie search results for user %d", userID),
+			"No search results found. Please start over with /addmovie.",
+		)
+	}
+	if resultIdx < 1 || resultIdx > len(userCtx.MovieResults) {
+		log.Printf("handleAcceptMovieCallback: invalid search result index: %d", resultIdx)
+		return nil
+	}
+	movie := userCtx.MovieResults[resultIdx-1]
+
+	today := handler.Clock.Now().UTC().Format(releaseDateLayout)
+	if _, err := addMovie(ctx, handler.DB, userID, handler.Movies.Name(), &movie, today); err != nil {
+		return NewUserError(
+			fmt.Errorf("adding movie %d for user %d: %w", movie.ID, userID, err),
+			"Error adding movie, please try again later.",
+		)
+	}
+	handler.Bot.clearState(userID)
+
+	text := fmt.Sprintf("Movie \"%s\" added. ", movie.Title)
+	switch {
+	case movie.ReleaseDate == "":
+		text += "It has no release date yet, I'll remind you once it comes out."
+	case movie.ReleaseDate <= today:
+		text += "It's already out, enjoy! See /movies."
+	default:
+		text += fmt.Sprintf("It comes out on %s, I'll remind you.", formatReleaseDate(movie.ReleaseDate))
+	}
+	handler.Bot.reply(ctx, msg.Chat.ID, text, ReplyOptions{EditMessageID: msg.MessageID})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// MOVIES command
+
+func (handler *Handler) makeMoviesKeyboard(movies []DBMovie) *tgbotapi.InlineKeyboardMarkup {
+	var rows [][][]string
+	for _, movie := range movies {
+		line := "🎬 " + movie.Title
+		if movie.Watched {
+			line = "✅ " + movie.Title
+		} else if movie.ReleaseDate != "" {
+			line += " - " + formatReleaseDate(movie.ReleaseDate)
+		}
+		rows = append(rows, [][]string{{line, fmt.Sprintf("toggleMovieWatched:%d", movie.ID)}})
+	}
+	return makeKeyboardMarkup(rows)
+}
+
+const moviesTitle = "Your movies (tap one to mark it watched or unwatched):"
+
+func (handler *Handler) handleMoviesCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	movies, err := listMovies(ctx, handler.DB, msg.From.ID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing movies for user %d: %w", msg.From.ID, err),
+			"Error: can't list movies at this time",
+		)
+	}
+	if len(movies) == 0 {
+		handler.Bot.reply(ctx, chatID, "You have no movies yet. Use /addmovie <title> to add one.")
+		return nil
+	}
+	handler.Bot.reply(ctx, chatID, moviesTitle, ReplyOptions{ReplyMarkup: handler.makeMoviesKeyboard(movies)})
+	return nil
+}
+
+func (handler *Handler) handleToggleMovieWatchedCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	movieID, err := strconv.ParseInt(callbackParam, 10, 64)
+	if err != nil {
+		log.Printf("handleToggleMovieWatchedCallback: invalid movie id: %s", callbackParam)
+		return nil
+	}
+
+	userID := cb.From.ID
+	msg := cb.Message
+
+	if err := toggleMovieWatched(ctx, handler.DB, userID, movieID); err != nil {
+		return NewUserError(
+			fmt.Errorf("toggling movie %d watched for user %d: %w", movieID, userID, err),
+			"Error updating movie",
+		)
+	}
+
+	movies, err := listMovies(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing movies for user %d: %w", userID, err),
+			"Error updating movie",
+		)
+	}
+	handler.Bot.reply(ctx,
+		msg.Chat.ID, moviesTitle,
+		ReplyOptions{ReplyMarkup: handler.makeMoviesKeyboard(movies), EditMessageID: msg.MessageID},
+	)
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleMovieWatchedCallback handles the button on a movie reminder.
+func (handler *Handler) handleMovieWatchedCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	movieID, err := strconv.ParseInt(callbackParam, 10, 64)
+	if err != nil {
+		log.Printf("handleMovieWatchedCallback: invalid movie id: %s", callbackParam)
+		return nil
+	}
+
+	userID := cb.From.ID
+	msg := cb.Message
+
+	title, err := setMovieWatched(ctx, handler.DB, userID, movieID, true)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("marking movie %d watched for user %d: %w", movieID, userID, err),
+			"Error updating movie",
+		)
+	}
+	handler.Bot.reply(ctx,
+		msg.Chat.ID, fmt.Sprintf("🎬 Marked \"%s\" as watched.", title), ReplyOptions{EditMessageID: msg.MessageID},
+	)
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
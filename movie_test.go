@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeMovies is a MovieProvider serving a fixed set of movies. The TMDB client
+// itself is tested against a fake server in internal/provider.
+type fakeMovies []Movie
+
+func (m fakeMovies) Name() string { return "tmdb" }
+
+func (m fakeMovies) SearchMovie(ctx context.Context, query string) ([]Movie, error) {
+	var results []Movie
+	for _, movie := range m {
+		if strings.Contains(strings.ToLower(movie.Title), strings.ToLower(query)) {
+			results = append(results, movie)
+		}
+	}
+	return results, nil
+}
+
+func (m fakeMovies) FetchMovie(ctx context.Context, movieID int) (*Movie, error) {
+	for _, movie := range m {
+		if movie.ID == movieID {
+			return &movie, nil
+		}
+	}
+	return nil, fmt.Errorf("movie %d not found", movieID)
+}
+
+// movieEnv is a test env with movie tracking backed by a fake TMDB knowing a movie
+// released in ten days.
+func movieEnv(t *testing.T) *testEnv {
+	t.Helper()
+	env := newTestEnv(t, testShows()...)
+	release := time.Now().UTC().AddDate(0, 0, 10).Format(releaseDateLayout)
+	env.handler.Movies = fakeMovies{
+		{ID: 7, Title: "Space Heist", ReleaseDate: release, Overview: "They steal a moon."},
+		{ID: 8, Title: "Space Heist Classic", ReleaseDate: "1999-05-01"},
+	}
+	return env
+}
+
+func TestAddMovie(t *testing.T) {
+	env := movieEnv(t)
+	env.command("/addmovie space heist")
+	if got := env.telegram.lastMessage(t).keyboard(t); len(got) != 3 {
+		t.Fatalf("keyboard = %v, want two movies and cancel", got)
+	}
+	env.press("acceptMovie:1")
+
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "It comes out on") {
+		t.Errorf("last message = %q, want the release date", got)
+	}
+	if got := queryString(t, env, `SELECT reminded FROM movies WHERE provider_movie_id = '7'`); got != "0" {
+		t.Errorf("reminded = %s, want an upcoming reminder", got)
+	}
+
+	// Old movies are already out and get no reminder
+	env.command("/addmovie classic")
+	env.press("acceptMovie:1")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "already out") {
+		t.Errorf("last message = %q", got)
+	}
+	if got := queryString(t, env, `SELECT reminded FROM movies WHERE provider_movie_id = '8'`); got != "1" {
+		t.Errorf("reminded = %s, want 1", got)
+	}
+
+	env.command("/upcoming")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "🎬 Space Heist") || strings.Contains(got, "Classic") {
+		t.Errorf("/upcoming = %q, want only the unreleased movie", got)
+	}
+}
+
+func TestAddMovieNotConfigured(t *testing.T) {
+	env := newTestEnv(t)
+	env.command("/addmovie dune")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "Movie tracking isn't enabled on this bot." {
+		t.Errorf("last message = %q", got)
+	}
+}
+
+func TestMovieReminder(t *testing.T) {
+	env := movieEnv(t)
+	env.command("/addmovie space heist")
+	env.press("acceptMovie:1")
+	release := time.Now().UTC().AddDate(0, 0, 10)
+
+	// Not yet: the day before and early on the release day
+	sendDueMovieReminders(t.Context(), env.handler.Bot, env.handler.DB, release.AddDate(0, 0, -1))
+	day := time.Date(release.Year(), release.Month(), release.Day(), 0, 0, 0, 0, time.UTC)
+	sendDueMovieReminders(t.Context(), env.handler.Bot, env.handler.DB, day.Add(8*time.Hour))
+	if got := queryString(t, env, `SELECT reminded FROM movies`); got != "0" {
+		t.Fatalf("reminded early")
+	}
+
+	sendDueMovieReminders(t.Context(), env.handler.Bot, env.handler.DB, day.Add(10*time.Hour))
+	reminder := env.telegram.lastMessage(t)
+	if text := reminder.Params.Get("text"); !strings.HasPrefix(text, "🎬 <b>Space Heist</b> is out today!") {
+		t.Errorf("reminder = %q", text)
+	}
+	if got := queryString(t, env, `SELECT reminded FROM movies`); got != "1" {
+		t.Errorf("reminded = %s, want 1", got)
+	}
+
+	env.press(reminder.keyboard(t)[0])
+	if got := queryString(t, env, `SELECT watched FROM movies`); got != "1" {
+		t.Errorf("watched = %s, want 1", got)
+	}
+	// Toggling from /movies flips it back
+	env.command("/movies")
+	env.press(env.telegram.lastMessage(t).keyboard(t)[0])
+	if got := queryString(t, env, `SELECT watched FROM movies`); got != "0" {
+		t.Errorf("watched after toggling = %s, want 0", got)
+	}
+}
+
+func TestSyncMoviesUpdatesReleaseDate(t *testing.T) {
+	env := movieEnv(t)
+	env.command("/addmovie space heist")
+	env.press("acceptMovie:1")
+	if _, err := env.handler.DB.Exec(`UPDATE movies SET release_date = '2030-01-01'`); err != nil {
+		t.Fatal(err)
+	}
+
+	syncMovies(t.Context(), env.handler.DB, env.handler.Movies)
+
+	want := time.Now().UTC().AddDate(0, 0, 10).Format(releaseDateLayout)
+	if got := queryString(t, env, `SELECT release_date FROM movies`); got != want {
+		t.Errorf("release_date = %s, want %s", got, want)
+	}
+}
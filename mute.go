@@ -0,0 +1,203 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// A muted show keeps its progress and reminder, but reminders that come due before
+// muted_until are skipped instead of sent. Once the date passes reminders resume on
+// their own, starting with the next episode.
+
+// mutePresets are the mute durations offered on the show card.
+var mutePresets = []struct {
+	Label string
+	Days  int
+}{
+	{"1 week", 7},
+	{"1 month", 30},
+}
+
+// maxMuteDays bounds custom mute dates, longer breaks are what disabling
+// notifications is for.
+const maxMuteDays = 365
+
+// setShowMutedUntil mutes the show until the given time, the zero time unmutes it.
+func setShowMutedUntil(ctx context.Context, db *store.DB, showID int64, until time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var value any
+	if !until.IsZero() {
+		value = until.UTC().Format(time.RFC3339)
+	}
+	_, err := db.ExecContext(ctx, `UPDATE shows SET muted_until = ? WHERE id = ?`, value, showID)
+	return err
+}
+
+func formatMuteDate(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("Mon, Jan 2 2006")
+}
+
+// handleMuteShowCallback offers the mute durations for a show.
+func (handler *Handler) handleMuteShowCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleMuteShowCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	show, err := handler.validateAndGetShow(ctx, cb.From.ID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	var rows [][][]string
+	for _, preset := range mutePresets {
+		rows = append(rows, [][]string{{preset.Label, fmt.Sprintf("setMute:%s:%d", callbackParam, preset.Days)}})
+	}
+	rows = append(rows, [][]string{{"📅 Pick a date", "muteCustom:" + callbackParam}})
+	rows = append(rows, [][]string{{"<< Back", "selectShow:" + callbackParam}})
+
+	text := fmt.Sprintf(
+		"Mute <b>%s</b> for how long? Episodes airing meanwhile are skipped, and reminders resume on their own afterwards.",
+		html.EscapeString(show.Name),
+	)
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, text, ReplyOptions{
+		ReplyMarkup: makeKeyboardMarkup(rows), ParseMode: "HTML", EditMessageID: cb.Message.MessageID,
+	})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleSetMuteCallback mutes a show for a preset number of days, 0 unmutes it.
+func (handler *Handler) handleSetMuteCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, args, ok := parseReleaseCallback(callbackParam, 1)
+	if !ok {
+		log.Printf("handleSetMuteCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	days, err := strconv.Atoi(args[0])
+	if err != nil || days < 0 || days > maxMuteDays {
+		log.Printf("handleSetMuteCallback: invalid days: %s", args[0])
+		return nil
+	}
+	show, err := handler.validateAndGetShow(ctx, cb.From.ID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	var until time.Time
+	if days > 0 {
+		until = handler.Clock.Now().AddDate(0, 0, days)
+	}
+	if err := setShowMutedUntil(ctx, handler.DB, show.InternalID, until); err != nil {
+		return NewUserError(
+			fmt.Errorf("muting show %d: %w", show.InternalID, err),
+			"Error saving the mute",
+		)
+	}
+	return handler.refreshShowDetail(ctx, cb, show, listType)
+}
+
+// handleMuteCustomCallback asks for the date to mute a show until.
+func (handler *Handler) handleMuteCustomCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleMuteCustomCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	userID := cb.From.ID
+	chatID := cb.Message.Chat.ID
+	show, err := handler.validateAndGetShow(ctx, userID, chatID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.State = StateAwaitingMuteDate
+		ctx.SelectedInternalID = show.InternalID
+	})
+	keyboard := makeKeyboardMarkup([][][]string{{{"❌ Cancel", "cancel"}}})
+	handler.Bot.reply(ctx,
+		chatID,
+		fmt.Sprintf("Until when should \"%s\" stay muted? Send a date like %s.", show.Name, handler.Clock.Now().AddDate(0, 1, 0).Format(time.DateOnly)),
+		ReplyOptions{ReplyMarkup: keyboard},
+	)
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// acceptMuteDate mutes the selected show until the start of the date the user sent,
+// in their time zone.
+func (handler *Handler) acceptMuteDate(ctx context.Context, msg *tgbotapi.Message) error {
+	userID := msg.From.ID
+	chatID := msg.Chat.ID
+
+	settings, err := getUserSettings(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting settings for user %d: %w", userID, err),
+			"Error reading your settings, please try again later.",
+		)
+	}
+	loc := settings.Location()
+	until, err := time.ParseInLocation(time.DateOnly, strings.TrimSpace(msg.Text), loc)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("invalid mute date %q from user %d: %w", msg.Text, userID, err),
+			"Please send the date as YYYY-MM-DD, or press Cancel.",
+		)
+	}
+	now := handler.Clock.Now()
+	if !until.After(now) {
+		return NewUserError(
+			fmt.Errorf("mute date %q from user %d is in the past", msg.Text, userID),
+			"That date has already passed, please send a later one.",
+		)
+	}
+	if until.After(now.AddDate(0, 0, maxMuteDays)) {
+		return NewUserError(
+			fmt.Errorf("mute date %q from user %d is too far away", msg.Text, userID),
+			"Shows can be muted for up to a year. To stop reminders for longer, disable notifications instead.",
+		)
+	}
+
+	userCtx := handler.Bot.getUserContext(userID)
+	if userCtx == nil || userCtx.SelectedInternalID == 0 {
+		handler.Bot.clearState(userID)
+		return NewUserError(
+			fmt.Errorf("no show selected for mute from user %d", userID),
+			"No show selected. Please start over with /shows",
+		)
+	}
+	showID := userCtx.SelectedInternalID
+	showName := "the show"
+	for _, show := range userCtx.ShowsList {
+		if show.InternalID == showID {
+			showName = fmt.Sprintf("\"%s\"", show.Name)
+			break
+		}
+	}
+
+	if err := setShowMutedUntil(ctx, handler.DB, showID, until); err != nil {
+		return NewUserError(
+			fmt.Errorf("muting show %d: %w", showID, err),
+			"Error saving the mute, please try again later.",
+		)
+	}
+	handler.Bot.clearState(userID)
+	handler.Bot.reply(ctx, chatID, fmt.Sprintf(
+		"%s is muted until %s. Reminders resume on their own after that.",
+		showName, formatMuteDate(until, loc),
+	))
+	return nil
+}
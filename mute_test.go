@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMutedShowSkipsReminders(t *testing.T) {
+	env := dueReminderEnv(t)
+	env.command("/history")
+	env.press("selectShow:1:history")
+	env.press("muteShow:1:history")
+	env.press("setMute:1:history:7")
+
+	if text := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(text, "Muted until") {
+		t.Errorf("show card after muting = %q, want the mute date", text)
+	}
+
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if n := countSent(env); n != 0 {
+		t.Errorf("sent %d reminders for a muted show, want 0", n)
+	}
+	// The skipped episode counts as reminded about, so it isn't sent after the mute
+	if got := queryString(t, env, `SELECT COUNT(*) FROM reminders WHERE sent_at IS NOT NULL`); got != "1" {
+		t.Errorf("%s reminders marked sent after skipping, want 1", got)
+	}
+
+	env.press("setMute:1:history:0")
+	if got := queryString(t, env, `SELECT muted_until FROM shows`); got != "NULL" {
+		t.Errorf("muted_until after unmuting = %s, want NULL", got)
+	}
+}
+
+func TestMuteUntilCustomDate(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	env.command("/history")
+	env.press("selectShow:1:history")
+	env.press("muteCustom:1:history")
+
+	env.text("someday")
+	if text := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(text, "YYYY-MM-DD") {
+		t.Errorf("reply to an invalid date = %q", text)
+	}
+
+	date := time.Now().AddDate(0, 0, 10).Format(time.DateOnly)
+	env.text(date)
+	if text := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(text, "is muted until") {
+		t.Errorf("reply to %s = %q", date, text)
+	}
+	if got := queryString(t, env, `SELECT muted_until FROM shows`); !strings.HasPrefix(got, date) {
+		t.Errorf("muted_until = %s, want %s", got, date)
+	}
+}
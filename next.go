@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// nextEpisodeWindow is how far ahead /next looks for an episode.
+const nextEpisodeWindow = 2 * 365 * 24 * time.Hour
+
+// formatNextEpisode answers /next with an episode, in loc.
+func formatNextEpisode(e UpcomingEpisode, now time.Time, loc *time.Location) string {
+	text := fmt.Sprintf("Next: <b>%s</b> S%02dE%02d", html.EscapeString(e.ShowName), e.Season, e.Number)
+	if e.Title != "" {
+		text += fmt.Sprintf(" \"%s\"", html.EscapeString(e.Title))
+	}
+	return text + fmt.Sprintf(" airs %s, in %s.", e.AiredAt.In(loc).Format("Mon Jan 2, 15:04"), formatCountdown(e.AiredAt.Sub(now)))
+}
+
+// NEXT command
+
+// handleNextCommand tells when the next episode of a show airs, or without a show
+// the soonest episode of all the user's shows.
+func (handler *Handler) handleNextCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	now := handler.Clock.Now()
+
+	settings, err := getUserSettings(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting settings for user %d: %w", userID, err),
+			"Error: can't look up the next episode at this time",
+		)
+	}
+	episodes, err := listUpcomingEpisodes(ctx, handler.DB, userID, now, now.Add(nextEpisodeWindow))
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing upcoming episodes for user %d: %w", userID, err),
+			"Error: can't look up the next episode at this time",
+		)
+	}
+
+	query := strings.TrimSpace(msg.CommandArguments())
+	if query == "" {
+		if len(episodes) == 0 {
+			handler.Bot.reply(ctx, chatID, "None of your shows has an episode scheduled yet. See /shows.")
+			return nil
+		}
+		handler.Bot.reply(ctx, chatID, formatNextEpisode(episodes[0], now, settings.Location()), ReplyOptions{ParseMode: "HTML"})
+		return nil
+	}
+
+	shows, err := listShowsWithProgress(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing shows for user %d: %w", userID, err),
+			"Error: can't look up the next episode at this time",
+		)
+	}
+	show, err := matchShow(shows, query)
+	if errors.Is(err, errAmbiguousShowMatch) {
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf("Several of your shows match \"%s\", please use the full name.", query))
+		return nil
+	}
+	if err != nil {
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf("You don't track a show called \"%s\". See /shows.", query))
+		return nil
+	}
+	for _, e := range episodes {
+		if e.ShowID == show.InternalID {
+			handler.Bot.reply(ctx, chatID, formatNextEpisode(e, now, settings.Location()), ReplyOptions{ParseMode: "HTML"})
+			return nil
+		}
+	}
+	handler.Bot.reply(ctx, chatID, fmt.Sprintf("No upcoming episode of \"%s\" is scheduled yet.", show.Name))
+	return nil
+}
@@ -0,0 +1,35 @@
+package bot
+
+import "testing"
+
+func TestNextCommand(t *testing.T) {
+	env := newSimulationEnv(t)
+	env.command("/next")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "None of your shows has an episode scheduled yet. See /shows." {
+		t.Errorf("/next without shows = %q", got)
+	}
+
+	env.command("/add harbor")
+	env.press("acceptShowName:1")
+	env.press("selectEpisode:1")
+	env.command("/add orbit")
+	env.press("acceptShowName:1")
+	env.press("selectEpisode:1")
+
+	env.command("/next")
+	if got, want := env.telegram.lastMessage(t).Params.Get("text"), `Next: <b>Harbor Lights</b> S01E01 "Pilot" airs Sun Mar 1, 20:00, in 8h 0m.`; got != want {
+		t.Errorf("/next = %q, want %q", got, want)
+	}
+	env.command("/next harbor lights")
+	if got, want := env.telegram.lastMessage(t).Params.Get("text"), `Next: <b>Harbor Lights</b> S01E01 "Pilot" airs Sun Mar 1, 20:00, in 8h 0m.`; got != want {
+		t.Errorf("/next harbor lights = %q, want %q", got, want)
+	}
+	env.command("/next orbit")
+	if got, want := env.telegram.lastMessage(t).Params.Get("text"), `No upcoming episode of "Orbit" is scheduled yet.`; got != want {
+		t.Errorf("/next orbit = %q, want %q", got, want)
+	}
+	env.command("/next nebula")
+	if got, want := env.telegram.lastMessage(t).Params.Get("text"), `You don't track a show called "nebula". See /shows.`; got != want {
+		t.Errorf("/next nebula = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxNoteLength keeps notes short enough to fit in a reminder caption.
+const maxNoteLength = 200
+
+func (handler *Handler) handleEditNoteCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showIDStr, listType, found := strings.Cut(callbackParam, ":")
+	if !found {
+		log.Printf("handleEditNoteCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	showID, err := strconv.Atoi(showIDStr)
+	if err != nil {
+		log.Printf("handleEditNoteCallback: invalid show ID: %s", showIDStr)
+		return nil
+	}
+
+	userID := cb.From.ID
+	chatID := cb.Message.Chat.ID
+
+	show, err := handler.validateAndGetShow(ctx, userID, chatID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.State = StateAwaitingNote
+		ctx.SelectedInternalID = show.InternalID
+	})
+	keyboard := makeKeyboardMarkup([][][]string{{{"❌ Cancel", "cancel"}}})
+	handler.Bot.reply(ctx,
+		chatID,
+		fmt.Sprintf("Send me a note for \"%s\" (up to %d characters).", show.Name, maxNoteLength),
+		ReplyOptions{ReplyMarkup: keyboard},
+	)
+
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+func (handler *Handler) handleClearNoteCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showIDStr, listType, found := strings.Cut(callbackParam, ":")
+	if !found {
+		log.Printf("handleClearNoteCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	showID, err := strconv.Atoi(showIDStr)
+	if err != nil {
+		log.Printf("handleClearNoteCallback: invalid show ID: %s", showIDStr)
+		return nil
+	}
+
+	userID := cb.From.ID
+	msg := cb.Message
+
+	show, err := handler.validateAndGetShow(ctx, userID, msg.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	if err := setShowNote(ctx, handler.DB, show.InternalID, ""); err != nil {
+		return NewUserError(
+			fmt.Errorf("clearing note for show %d: %w", show.InternalID, err),
+			"Error removing the note",
+		)
+	}
+
+	return handler.refreshShowDetail(ctx, cb, show, listType)
+}
+
+func (handler *Handler) acceptNote(ctx context.Context, msg *tgbotapi.Message) error {
+	userID := msg.From.ID
+	chatID := msg.Chat.ID
+
+	note := strings.TrimSpace(msg.Text)
+	if note == "" {
+		return NewUserError(
+			fmt.Errorf("empty note from user %d", userID),
+			"Please send the note as text, or press Cancel.",
+		)
+	}
+	if utf8.RuneCountInString(note) > maxNoteLength {
+		return NewUserError(
+			fmt.Errorf("note from user %d is too long", userID),
+			fmt.Sprintf("That's a bit long, please keep the note under %d characters.", maxNoteLength),
+		)
+	}
+
+	userCtx := handler.Bot.getUserContext(userID)
+	if userCtx == nil || userCtx.SelectedInternalID == 0 {
+		handler.Bot.clearState(userID)
+		return NewUserError(
+			fmt.Errorf("no show selected for note from user %d", userID),
+			"No show selected. Please start over with /shows",
+		)
+	}
+	showID := userCtx.SelectedInternalID
+	showName := "the show"
+	for _, show := range userCtx.ShowsList {
+		if show.InternalID == showID {
+			showName = fmt.Sprintf("\"%s\"", show.Name)
+			break
+		}
+	}
+
+	if err := setShowNote(ctx, handler.DB, showID, note); err != nil {
+		return NewUserError(
+			fmt.Errorf("saving note for show %d: %w", showID, err),
+			"Error saving the note, please try again later.",
+		)
+	}
+	handler.Bot.clearState(userID)
+	handler.Bot.reply(ctx, chatID, fmt.Sprintf("Note saved for %s. See /shows.", showName))
+	return nil
+}
@@ -0,0 +1,286 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+const (
+	// DeliveryTelegram sends reminders to the user's Telegram chat only.
+	DeliveryTelegram = "telegram"
+	// DeliveryDiscord sends reminders to the user's Discord webhook only.
+	DeliveryDiscord = "discord"
+	// DeliveryBoth sends reminders to Telegram and mirrors them to Discord.
+	DeliveryBoth = "both"
+)
+
+// maxDiscordMessageLength is Discord's limit for a message's content.
+const maxDiscordMessageLength = 2000
+
+// discordTimeout bounds one Discord webhook call.
+const discordTimeout = 10 * time.Second
+
+// Notifier delivers due reminders through one channel.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, r DBReminder, now time.Time) error
+}
+
+// TelegramNotifier sends reminders to the chat they were created in, or to Target
+// when set. Episode reminders are recorded, so reacting to them marks the episode
+// watched.
+type TelegramNotifier struct {
+	Bot    *Bot
+	DB     *store.DB
+	Target *ReminderTarget
+}
+
+func (n TelegramNotifier) Name() string { return DeliveryTelegram }
+
+func (n TelegramNotifier) Notify(ctx context.Context, r DBReminder, now time.Time) error {
+	if n.Target != nil {
+		r.ChatID, r.ThreadID = n.Target.ChatID, n.Target.ThreadID
+	}
+	msg, err := sendReminder(ctx, n.Bot, r, now)
+	if newID := migratedChatID(err); newID != 0 {
+		// The group became a supergroup: move it over and send there instead
+		if err := migrateChat(ctx, n.DB, r.ChatID, newID); err != nil {
+			log.Printf("reminderLoop: moving chat %d to %d: %v", r.ChatID, newID, err)
+		}
+		r.ChatID = newID
+		msg, err = sendReminder(ctx, n.Bot, r, now)
+	}
+	if n.Target != nil && isChatUnreachable(err) {
+		// The group removed the bot: stop offering and sending to it
+		if err := forgetChat(ctx, n.DB, r.ChatID); err != nil {
+			log.Printf("reminderLoop: forgetting chat %d: %v", r.ChatID, err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if n.Target == nil {
+		if err := setReminderMessageID(ctx, n.DB, r.ID, msg.MessageID); err != nil {
+			log.Printf("reminderLoop: recording message ID of reminder %d: %v", r.ID, err)
+		}
+	}
+	if r.ReminderMode == ReminderModeEpisode {
+		if err := recordReminderMessage(ctx, n.DB, r, msg, now); err != nil {
+			log.Printf("reminderLoop: recording message of reminder %d: %v", r.ID, err)
+		}
+	}
+	return nil
+}
+
+// DiscordNotifier posts reminders to the user's Discord webhook.
+type DiscordNotifier struct {
+	Client *http.Client
+}
+
+func (n DiscordNotifier) Name() string { return DeliveryDiscord }
+
+func (n DiscordNotifier) Notify(ctx context.Context, r DBReminder, now time.Time) error {
+	return n.send(ctx, r.DiscordWebhookURL, htmlToDiscord(formatReminderText(r, now)), r.ImageURL)
+}
+
+type discordEmbed struct {
+	Image struct {
+		URL string `json:"url"`
+	} `json:"image"`
+}
+
+type discordMessage struct {
+	Content string         `json:"content"`
+	Embeds  []discordEmbed `json:"embeds,omitempty"`
+}
+
+func (n DiscordNotifier) send(ctx context.Context, webhookURL, content, imageURL string) error {
+	if webhookURL == "" {
+		return errors.New("no Discord webhook set")
+	}
+	ctx, cancel := context.WithTimeout(ctx, discordTimeout)
+	defer cancel()
+
+	message := discordMessage{Content: trimString(content, maxDiscordMessageLength)}
+	if imageURL != "" {
+		var embed discordEmbed
+		embed.Image.URL = imageURL
+		message.Embeds = []discordEmbed{embed}
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("discord responded %s: %s", resp.Status, detail)
+	}
+	return nil
+}
+
+// discordReplacer maps the Telegram HTML used in reminders to Discord markdown.
+var discordReplacer = strings.NewReplacer(
+	"<b>", "**", "</b>", "**",
+	"<i>", "*", "</i>", "*",
+	"<tg-spoiler>", "||", "</tg-spoiler>", "||",
+)
+
+// htmlToDiscord converts a reminder's Telegram HTML into Discord markdown. Tags
+// without an equivalent, like the invisible poster links, are dropped.
+func htmlToDiscord(s string) string {
+	s = discordReplacer.Replace(s)
+	s = htmlTagRe.ReplaceAllString(s, "")
+	return strings.TrimSpace(html.UnescapeString(strings.ReplaceAll(s, "\u200b", "")))
+}
+
+// reminderNotifiers returns the notifier a reminder must be delivered through and
+// the ones it is only mirrored to, following the user's delivery setting and the
+// show's extra chats.
+func reminderNotifiers(bot *Bot, db *store.DB, r DBReminder) (primary Notifier, mirrors []Notifier) {
+	telegram := TelegramNotifier{Bot: bot, DB: db}
+	discord := DiscordNotifier{}
+	switch {
+	case r.DiscordWebhookURL == "":
+		primary = telegram
+	case r.DeliveryMode == DeliveryDiscord:
+		primary = discord
+	case r.DeliveryMode == DeliveryBoth:
+		primary, mirrors = telegram, []Notifier{discord}
+	default:
+		primary = telegram
+	}
+	for _, target := range r.Targets {
+		if target.ChatID != r.ChatID {
+			mirrors = append(mirrors, TelegramNotifier{Bot: bot, DB: db, Target: &target})
+		}
+	}
+	return primary, mirrors
+}
+
+func validateDiscordWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || (u.Host != "discord.com" && u.Host != "discordapp.com") ||
+		!strings.HasPrefix(u.Path, "/api/webhooks/") {
+		return errors.New("not a Discord webhook URL")
+	}
+	return nil
+}
+
+func setDiscordDelivery(ctx context.Context, db *store.DB, userID, chatID int64, webhookURL, mode string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE user_settings SET discord_webhook_url = ?, delivery_mode = ? WHERE user_id = ?
+	`, webhookURL, mode, userID)
+	return err
+}
+
+func describeDelivery(settings *UserSettings) string {
+	switch {
+	case settings.DiscordWebhookURL == "":
+		return "Reminders go to Telegram."
+	case settings.DeliveryMode == DeliveryDiscord:
+		return "Reminders go to Discord only."
+	default:
+		return "Reminders go to Telegram and are mirrored to Discord."
+	}
+}
+
+// DISCORD command
+
+func (handler *Handler) handleDiscordCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	arg := strings.TrimSpace(msg.CommandArguments())
+
+	settings, err := getUserSettings(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting settings for user %d: %w", userID, err),
+			"Error: can't update Discord delivery at this time",
+		)
+	}
+
+	webhookURL, mode := settings.DiscordWebhookURL, settings.DeliveryMode
+	switch arg {
+	case "":
+		handler.Bot.reply(ctx, chatID, describeDelivery(settings)+"\n\n"+dedent(`
+		/discord <webhook URL> - mirror reminders to a Discord channel
+		/discord only - send reminders to Discord only
+		/discord mirror - send reminders to both
+		/discord off - stop using Discord
+		`))
+		return nil
+	case "off":
+		webhookURL, mode = "", DeliveryTelegram
+	case "only", "mirror":
+		if webhookURL == "" {
+			handler.Bot.reply(ctx, chatID, "Set a Discord webhook first: /discord <webhook URL>")
+			return nil
+		}
+		mode = DeliveryDiscord
+		if arg == "mirror" {
+			mode = DeliveryBoth
+		}
+	default:
+		if err := validateDiscordWebhookURL(arg); err != nil {
+			return NewUserError(
+				fmt.Errorf("invalid Discord webhook %q: %w", arg, err),
+				"That's not a Discord webhook URL. Create one in the channel settings under Integrations → Webhooks.",
+			)
+		}
+		if err := (DiscordNotifier{}).send(ctx, arg, "✅ TV show reminders will show up here.", ""); err != nil {
+			return NewUserError(
+				fmt.Errorf("testing Discord webhook of user %d: %w", userID, err),
+				"I couldn't post to this Discord webhook. Please check the URL.",
+			)
+		}
+		webhookURL, mode = arg, DeliveryBoth
+	}
+
+	if err := setDiscordDelivery(ctx, handler.DB, userID, chatID, webhookURL, mode); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting Discord delivery for user %d: %w", userID, err),
+			"Error: can't update Discord delivery at this time",
+		)
+	}
+	settings.DiscordWebhookURL, settings.DeliveryMode = webhookURL, mode
+	handler.Bot.reply(ctx, chatID, describeDelivery(settings))
+	return nil
+}
@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newDiscordServer records the messages posted to a fake Discord webhook.
+func newDiscordServer(t *testing.T) (*httptest.Server, func() []discordMessage) {
+	t.Helper()
+	var mu sync.Mutex
+	var messages []discordMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message discordMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("decoding Discord message: %v", err)
+		}
+		mu.Lock()
+		messages = append(messages, message)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []discordMessage {
+		mu.Lock()
+		defer mu.Unlock()
+		return messages
+	}
+}
+
+func setDeliveryForTest(t *testing.T, env *testEnv, webhookURL, mode string) {
+	t.Helper()
+	if err := setDiscordDelivery(t.Context(), env.handler.DB, testUserID, testUserID, webhookURL, mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHTMLToDiscord(t *testing.T) {
+	got := htmlToDiscord(`<a href="https://img/x.jpg">` + "\u200b" + `</a><b>Night &amp; Day</b>` +
+		"\n\n<tg-spoiler>Twist</tg-spoiler> <i>note</i>")
+	want := "**Night & Day**\n\n||Twist|| *note*"
+	if got != want {
+		t.Errorf("htmlToDiscord = %q, want %q", got, want)
+	}
+}
+
+func TestDiscordDelivery(t *testing.T) {
+	tests := []struct {
+		mode         string
+		wantTelegram int
+		wantDiscord  int
+	}{
+		{DeliveryTelegram, 1, 0},
+		{DeliveryBoth, 1, 1},
+		{DeliveryDiscord, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			env := dueReminderEnv(t)
+			server, messages := newDiscordServer(t)
+			setDeliveryForTest(t, env, server.URL, tt.mode)
+
+			sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+
+			if got := countSent(env); got != tt.wantTelegram {
+				t.Errorf("sent %d Telegram reminders, want %d", got, tt.wantTelegram)
+			}
+			got := messages()
+			if len(got) != tt.wantDiscord {
+				t.Fatalf("sent %d Discord reminders, want %d", len(got), tt.wantDiscord)
+			}
+			if tt.wantDiscord > 0 && !strings.Contains(got[0].Content, `of "Night Shift" (season 2) is coming out today`) {
+				t.Errorf("Discord content = %q", got[0].Content)
+			}
+			if left := queryString(t, env, `SELECT COUNT(*) FROM reminders WHERE sent_at IS NULL`); left != "0" {
+				t.Errorf("%s reminders left, want the sent one gone", left)
+			}
+		})
+	}
+}
+
+func TestDiscordCommand(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.command("/discord https://example.com/api/webhooks/1/abc")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "That's not a Discord webhook URL") {
+		t.Errorf("/discord with a foreign URL = %q", got)
+	}
+
+	env.command("/discord only")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "Set a Discord webhook first") {
+		t.Errorf("/discord only without a webhook = %q", got)
+	}
+
+	setDeliveryForTest(t, env, "https://discord.com/api/webhooks/1/abc", DeliveryBoth)
+	env.command("/discord only")
+	if got := queryString(t, env, `SELECT delivery_mode FROM user_settings`); got != DeliveryDiscord {
+		t.Errorf("delivery_mode = %s, want %s", got, DeliveryDiscord)
+	}
+
+	env.command("/discord off")
+	if got := queryString(t, env, `SELECT discord_webhook_url || ':' || delivery_mode FROM user_settings`); got != ":telegram" {
+		t.Errorf("settings after off = %q", got)
+	}
+}
@@ -0,0 +1,507 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/reminder"
+	"tvreminder/bot/internal/store"
+)
+
+// Besides the reminder when an episode comes out, a show can have extra reminders
+// at offsets from it: a heads-up the day before, a nudge two hours after. Each is a
+// row in reminder_offsets with a text of its own. Extra reminders follow the show's
+// reminder: the ones before it go out while it's pending, the ones after once it
+// was sent. They're best effort: a failed send isn't retried, and one that's late
+// by more than offsetReminderWindow, say after quiet hours, is dropped.
+
+// maxReminderOffsets is how many extra reminders a show can have.
+const maxReminderOffsets = 4
+
+// maxReminderOffset is how far from the reminder an extra one can be.
+const maxReminderOffset = 7 * 24 * time.Hour
+
+// offsetReminderWindow is how late an extra reminder can still go out.
+const offsetReminderWindow = 12 * time.Hour
+
+// reminderOffsetPresets are the offsets, in minutes, the menu offers.
+var reminderOffsetPresets = []int{-24 * 60, -60, 2 * 60}
+
+// ReminderOffset is an extra reminder of a show, Minutes from its reminder.
+type ReminderOffset struct {
+	Minutes int
+	// Template is the reminder's text, empty for the default one.
+	Template string
+}
+
+// formatOffset describes an offset, like "1 day before" or "2 hours after".
+func formatOffset(minutes int) string {
+	when := "after"
+	if minutes < 0 {
+		minutes, when = -minutes, "before"
+	}
+	switch {
+	case minutes%(24*60) == 0:
+		return pluralize(minutes/(24*60), "day") + " " + when
+	case minutes%60 == 0:
+		return pluralize(minutes/60, "hour") + " " + when
+	default:
+		return pluralize(minutes, "minute") + " " + when
+	}
+}
+
+// parseOffset parses an offset like "-24h", "+2h", "-90m" or "-1d" into minutes.
+func parseOffset(s string) (int, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("%q isn't an offset like -24h or +2h", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("%q isn't an offset like -24h or +2h", s)
+		}
+	}
+	if d == 0 {
+		return 0, errors.New("the reminder when the episode comes out is always sent")
+	}
+	if d%time.Minute != 0 {
+		return 0, errors.New("offsets are in whole minutes")
+	}
+	if d > maxReminderOffset || d < -maxReminderOffset {
+		return 0, fmt.Errorf("offsets can be up to %s", pluralize(int(maxReminderOffset.Hours()/24), "day"))
+	}
+	return int(d / time.Minute), nil
+}
+
+func listReminderOffsets(ctx context.Context, db *store.DB, showID int64) ([]ReminderOffset, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT offset_minutes, template FROM reminder_offsets WHERE show_id = ? ORDER BY offset_minutes
+	`, showID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var offsets []ReminderOffset
+	for rows.Next() {
+		var offset ReminderOffset
+		if err := rows.Scan(&offset.Minutes, &offset.Template); err != nil {
+			return nil, err
+		}
+		offsets = append(offsets, offset)
+	}
+	return offsets, rows.Err()
+}
+
+// setReminderOffset adds an extra reminder to a show, or changes the text of the
+// one at the same offset.
+func setReminderOffset(ctx context.Context, db *store.DB, showID int64, offset ReminderOffset) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO reminder_offsets (show_id, offset_minutes, template) VALUES (?, ?, ?)
+		ON CONFLICT(show_id, offset_minutes) DO UPDATE SET template = excluded.template
+	`, showID, offset.Minutes, offset.Template)
+	return err
+}
+
+func deleteReminderOffset(ctx context.Context, db *store.DB, showID int64, minutes int) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `DELETE FROM reminder_offsets WHERE show_id = ? AND offset_minutes = ?`, showID, minutes)
+	return err
+}
+
+// OffsetReminder is a due extra reminder.
+type OffsetReminder struct {
+	UserID        int64
+	ChatID        int64
+	ThreadID      int
+	ShowID        int64
+	EpisodeID     int64
+	ShowName      string
+	EpisodeTitle  string
+	EpisodeSeason int
+	EpisodeNumber int
+	// AiredAt is when the episode airs in the user's time zone, zero when unknown.
+	AiredAt time.Time
+	Silent  bool
+	Offset  ReminderOffset
+	// Due is when the reminder was due, which the episodes of a batch share.
+	Due      time.Time
+	Settings UserSettings
+}
+
+// listDueOffsetReminders returns the extra reminders due by now that weren't sent,
+// leaving out the ones more than offsetReminderWindow late.
+func listDueOffsetReminders(ctx context.Context, db *store.DB, now time.Time) ([]OffsetReminder, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	nowStr := now.UTC().Format(time.RFC3339)
+	rows, err := db.QueryContext(ctx, `
+		SELECT * FROM (
+			SELECT
+				r.user_id, r.chat_id, COALESCE(r.thread_id, 0), s.id, e.id, s.name, e.title, e.season, e.number,
+				COALESCE(e.aired_at_utc, ''), COALESCE(s.silent, 0), o.offset_minutes, o.template,
+				strftime('%Y-%m-%dT%H:%M:%SZ', r.remind_at, o.offset_minutes || ' minutes') AS due,
+				COALESCE(us.timezone, 'UTC'), COALESCE(us.quiet_hours, '')
+			FROM reminder_offsets o
+			JOIN shows s ON s.id = o.show_id
+			JOIN reminders r ON r.show_id = s.id AND r.user_id = s.user_id
+			JOIN episodes_cache e ON e.id = r.episode_id
+			LEFT JOIN user_settings us ON us.user_id = s.user_id
+			WHERE s.deleted_at IS NULL AND s.dropped_at IS NULL AND s.notifications_enabled = 1
+			AND (s.muted_until IS NULL OR s.muted_until <= ?)
+			AND us.inactive_since IS NULL
+			AND r.deleted_at IS NULL
+			AND ((o.offset_minutes < 0 AND r.status = ?) OR (o.offset_minutes > 0 AND r.status = ?))
+			AND NOT EXISTS (
+				SELECT 1 FROM offset_reminders x
+				WHERE x.show_id = s.id AND x.episode_id = e.id AND x.offset_minutes = o.offset_minutes
+			)
+		)
+		WHERE due <= ? AND due > ?
+		ORDER BY due, 4, 8, 9
+	`, nowStr, ReminderStatusPending, ReminderStatusSent, nowStr, now.Add(-offsetReminderWindow).UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []OffsetReminder
+	for rows.Next() {
+		var r OffsetReminder
+		var airedAt, due string
+		if err := rows.Scan(
+			&r.UserID, &r.ChatID, &r.ThreadID, &r.ShowID, &r.EpisodeID, &r.ShowName, &r.EpisodeTitle,
+			&r.EpisodeSeason, &r.EpisodeNumber, &airedAt, &r.Silent, &r.Offset.Minutes, &r.Offset.Template,
+			&due, &r.Settings.Timezone, &r.Settings.QuietHours,
+		); err != nil {
+			return nil, err
+		}
+		if t, err := time.Parse(time.RFC3339, airedAt); err == nil {
+			r.AiredAt = t.In(r.Settings.Location())
+		}
+		if r.Due, err = time.Parse(time.RFC3339, due); err != nil {
+			continue
+		}
+		reminders = append(reminders, r)
+	}
+	return reminders, rows.Err()
+}
+
+// nextOffsetReminderDue returns when the next extra reminder after now is due, the
+// zero time when there's none.
+func nextOffsetReminderDue(ctx context.Context, db *store.DB, now time.Time) (time.Time, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var due sql.NullString
+	err := db.QueryRowContext(ctx, `
+		SELECT MIN(due) FROM (
+			SELECT strftime('%Y-%m-%dT%H:%M:%SZ', r.remind_at, o.offset_minutes || ' minutes') AS due
+			FROM reminder_offsets o
+			JOIN shows s ON s.id = o.show_id
+			JOIN reminders r ON r.show_id = s.id AND r.user_id = s.user_id
+			WHERE s.deleted_at IS NULL AND s.dropped_at IS NULL AND s.notifications_enabled = 1
+			AND r.deleted_at IS NULL
+			AND ((o.offset_minutes < 0 AND r.status = ?) OR (o.offset_minutes > 0 AND r.status = ?))
+		)
+		WHERE due > ?
+	`, ReminderStatusPending, ReminderStatusSent, now.UTC().Format(time.RFC3339)).Scan(&due)
+	if err != nil || !due.Valid {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, due.String)
+}
+
+func markOffsetReminderSent(ctx context.Context, db *store.DB, r OffsetReminder, sentAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO offset_reminders (show_id, episode_id, offset_minutes, sent_at) VALUES (?, ?, ?, ?)
+	`, r.ShowID, r.EpisodeID, r.Offset.Minutes, sentAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+// formatOffsetReminder renders an extra reminder with its template, or else with
+// the default text for reminders before or after the episode comes out.
+func formatOffsetReminder(r OffsetReminder) string {
+	if r.Offset.Template != "" {
+		data := reminder.TemplateData{
+			Show:    r.ShowName,
+			Season:  r.EpisodeSeason,
+			Episode: r.EpisodeNumber,
+			Title:   r.EpisodeTitle,
+		}
+		if !r.AiredAt.IsZero() {
+			data.AirTimeLocal = r.AiredAt.Format("15:04")
+		}
+		text, err := reminder.Render(r.Offset.Template, data)
+		if err == nil {
+			return text
+		}
+		log.Printf("reminderLoop: rendering the %d minute reminder of show %d: %v", r.Offset.Minutes, r.ShowID, err)
+	}
+
+	episode := fmt.Sprintf("<b>%s</b> S%02dE%02d", html.EscapeString(r.ShowName), r.EpisodeSeason, r.EpisodeNumber)
+	if r.EpisodeTitle != "" {
+		episode += fmt.Sprintf(" \"%s\"", html.EscapeString(r.EpisodeTitle))
+	}
+	lead := strings.TrimSuffix(strings.TrimSuffix(formatOffset(r.Offset.Minutes), " before"), " after")
+	if r.Offset.Minutes < 0 {
+		return fmt.Sprintf("⏰ Heads-up: %s comes out in %s.", episode, lead)
+	}
+	return fmt.Sprintf("🍿 %s has been out for %s, time to watch!", episode, lead)
+}
+
+// sendDueOffsetReminders sends the due extra reminders, one run of the reminder loop.
+// Episodes airing together share their reminder, so they share the extra ones too.
+func sendDueOffsetReminders(ctx context.Context, bot *Bot, db *store.DB, now time.Time) {
+	reminders, err := listDueOffsetReminders(ctx, db, now)
+	if err != nil {
+		log.Printf("reminderLoop: listing extra reminders: %v", err)
+		return
+	}
+
+	type batchKey struct {
+		showID  int64
+		minutes int
+		due     time.Time
+	}
+	sendErrors := make(map[batchKey]error)
+	quietUsers := make(map[int64]bool)
+	for _, r := range reminders {
+		quiet, seen := quietUsers[r.UserID]
+		if !seen {
+			quiet = r.Settings.inQuietHours(now)
+			quietUsers[r.UserID] = quiet
+		}
+		if quiet {
+			continue
+		}
+		key := batchKey{r.ShowID, r.Offset.Minutes, r.Due}
+		sendErr, tried := sendErrors[key]
+		if !tried {
+			_, sendErr = bot.send(ctx, r.ChatID, formatOffsetReminder(r), ReplyOptions{
+				ParseMode: "HTML", ThreadID: r.ThreadID, Silent: r.Silent,
+			})
+			if sendErr != nil {
+				log.Printf("reminderLoop: sending the %d minute reminder of show %d: %v", r.Offset.Minutes, r.ShowID, sendErr)
+			}
+			sendErrors[key] = sendErr
+		}
+		// Failed sends are retried on the next run until the reminder leaves
+		// offsetReminderWindow, unless the chat is gone for good
+		if sendErr != nil && !isChatUnreachable(sendErr) {
+			continue
+		}
+		if err := markOffsetReminderSent(ctx, db, r, now); err != nil {
+			log.Printf("reminderLoop: marking the %d minute reminder of show %d sent: %v", r.Offset.Minutes, r.ShowID, err)
+		}
+	}
+}
+
+// handleReminderOffsetsCallback shows a show's extra reminders, with buttons to
+// remove them and add more.
+func (handler *Handler) handleReminderOffsetsCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleReminderOffsetsCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	show, err := handler.validateAndGetShow(ctx, cb.From.ID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+	return handler.showReminderOffsets(ctx, cb, show, fmt.Sprintf("%d:%s", showID, listType))
+}
+
+func (handler *Handler) showReminderOffsets(ctx context.Context, cb *tgbotapi.CallbackQuery, show *ShowProgress, callbackParam string) error {
+	offsets, err := listReminderOffsets(ctx, handler.DB, show.InternalID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing reminder offsets of show %d: %w", show.InternalID, err),
+			"Error getting the show's reminders",
+		)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Reminders for <b>%s</b>:\n• when an episode comes out\n", html.EscapeString(show.Name))
+	var rows [][][]string
+	set := make(map[int]bool)
+	for _, offset := range offsets {
+		set[offset.Minutes] = true
+		fmt.Fprintf(&b, "• %s", formatOffset(offset.Minutes))
+		if offset.Template != "" {
+			fmt.Fprintf(&b, ": %s", html.EscapeString(offset.Template))
+		}
+		b.WriteString("\n")
+		rows = append(rows, [][]string{{"🗑 " + formatOffset(offset.Minutes), fmt.Sprintf("deleteOffset:%s:%d", callbackParam, offset.Minutes)}})
+	}
+	if len(offsets) < maxReminderOffsets {
+		var presets [][]string
+		for _, minutes := range reminderOffsetPresets {
+			if !set[minutes] {
+				presets = append(presets, []string{"➕ " + formatOffset(minutes), fmt.Sprintf("addOffset:%s:%d", callbackParam, minutes)})
+			}
+		}
+		if len(presets) > 0 {
+			rows = append(rows, presets)
+		}
+		rows = append(rows, [][]string{{"✏️ Other…", "customOffset:" + callbackParam}})
+	}
+	rows = append(rows, [][]string{{"<< Back", "selectShow:" + callbackParam}})
+
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, b.String(), ReplyOptions{
+		ReplyMarkup: makeKeyboardMarkup(rows), ParseMode: "HTML", EditMessageID: cb.Message.MessageID,
+	})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleAddOffsetCallback adds a preset extra reminder with the default text.
+func (handler *Handler) handleAddOffsetCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	return handler.changeReminderOffset(ctx, cb, callbackParam, true)
+}
+
+func (handler *Handler) handleDeleteOffsetCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	return handler.changeReminderOffset(ctx, cb, callbackParam, false)
+}
+
+func (handler *Handler) changeReminderOffset(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string, add bool) error {
+	showID, listType, args, ok := parseReleaseCallback(callbackParam, 1)
+	if !ok {
+		log.Printf("changeReminderOffset: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	minutes, err := strconv.Atoi(args[0])
+	if err != nil || minutes == 0 {
+		log.Printf("changeReminderOffset: invalid offset: %s", args[0])
+		return nil
+	}
+	show, err := handler.validateAndGetShow(ctx, cb.From.ID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	if add {
+		offsets, err := listReminderOffsets(ctx, handler.DB, show.InternalID)
+		if err == nil && len(offsets) < maxReminderOffsets {
+			err = setReminderOffset(ctx, handler.DB, show.InternalID, ReminderOffset{Minutes: minutes})
+		}
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("adding reminder offset %d to show %d: %w", minutes, show.InternalID, err),
+				"Error saving the reminder",
+			)
+		}
+	} else if err := deleteReminderOffset(ctx, handler.DB, show.InternalID, minutes); err != nil {
+		return NewUserError(
+			fmt.Errorf("deleting reminder offset %d of show %d: %w", minutes, show.InternalID, err),
+			"Error removing the reminder",
+		)
+	}
+	return handler.showReminderOffsets(ctx, cb, show, fmt.Sprintf("%d:%s", showID, listType))
+}
+
+// handleCustomOffsetCallback asks for an extra reminder's offset and text.
+func (handler *Handler) handleCustomOffsetCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleCustomOffsetCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	userID := cb.From.ID
+	show, err := handler.validateAndGetShow(ctx, userID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.State = StateAwaitingOffset
+		ctx.SelectedInternalID = show.InternalID
+	})
+	keyboard := makeKeyboardMarkup([][][]string{{{"❌ Cancel", "cancel"}}})
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, fmt.Sprintf(
+		"When should the extra reminder for \"%s\" go out? Send an offset like -24h for a day before "+
+			"an episode comes out or +2h for two hours after, optionally followed by its text:\n"+
+			"-24h {show} is on tomorrow at {air_time_local}\n\n%s",
+		show.Name, reminderTemplateHelp,
+	), ReplyOptions{ReplyMarkup: keyboard})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+func (handler *Handler) acceptReminderOffset(ctx context.Context, msg *tgbotapi.Message) error {
+	userID := msg.From.ID
+	offsetArg, template, _ := strings.Cut(strings.TrimSpace(msg.Text), " ")
+	template = strings.TrimSpace(template)
+	minutes, err := parseOffset(offsetArg)
+	if err == nil && template != "" {
+		_, err = reminder.ParseTemplate(template)
+	}
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("invalid reminder offset from user %d: %w", userID, err),
+			fmt.Sprintf("I can't use that: %v. Please send it again, or press Cancel.", err),
+		)
+	}
+
+	userCtx := handler.Bot.getUserContext(userID)
+	if userCtx == nil || userCtx.SelectedInternalID == 0 {
+		handler.Bot.clearState(userID)
+		return NewUserError(
+			fmt.Errorf("no show selected for reminder offset from user %d", userID),
+			"No show selected. Please start over with /shows",
+		)
+	}
+	showID := userCtx.SelectedInternalID
+	offsets, err := listReminderOffsets(ctx, handler.DB, showID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing reminder offsets of show %d: %w", showID, err),
+			"Error saving the reminder, please try again later.",
+		)
+	}
+	replacing := false
+	for _, offset := range offsets {
+		replacing = replacing || offset.Minutes == minutes
+	}
+	if !replacing && len(offsets) >= maxReminderOffsets {
+		handler.Bot.clearState(userID)
+		return NewUserError(
+			fmt.Errorf("show %d has %d reminder offsets", showID, len(offsets)),
+			fmt.Sprintf("A show can have up to %d extra reminders. Remove one in /shows first.", maxReminderOffsets),
+		)
+	}
+	if err := setReminderOffset(ctx, handler.DB, showID, ReminderOffset{Minutes: minutes, Template: template}); err != nil {
+		return NewUserError(
+			fmt.Errorf("saving reminder offset %d of show %d: %w", minutes, showID, err),
+			"Error saving the reminder, please try again later.",
+		)
+	}
+	handler.Bot.clearState(userID)
+	handler.Bot.reply(ctx, msg.Chat.ID, fmt.Sprintf("Saved: an extra reminder %s each episode comes out. See /shows.", formatOffset(minutes)))
+	return nil
+}
@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReminderOffsets(t *testing.T) {
+	env := newSimulationEnv(t)
+	env.command("/add harbor")
+	env.press("acceptShowName:1")
+	env.press("selectEpisode:1")
+
+	env.command("/shows")
+	env.press("selectShow:1:current")
+	env.press("reminderOffsets:1:current")
+	env.press("addOffset:1:current:-1440")
+	menu := env.telegram.lastMessage(t)
+	if got := menu.Params.Get("text"); got != "Reminders for <b>Harbor Lights</b>:\n• when an episode comes out\n• 1 day before\n" {
+		t.Errorf("menu = %q", got)
+	}
+	if labels := menu.labels(t); !slices.Contains(labels, "🗑 1 day before") || slices.Contains(labels, "➕ 1 day before") {
+		t.Errorf("menu buttons = %v", labels)
+	}
+	env.press("customOffset:1:current")
+	env.text("+0h {show} is out")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "always sent") {
+		t.Errorf("offset 0 replied %q", got)
+	}
+	env.text("+2h {show} S{season}E{episode} is out, go watch")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "Saved: an extra reminder 2 hours after each episode comes out. See /shows." {
+		t.Errorf("custom offset replied %q", got)
+	}
+
+	send := func(at string) string {
+		t.Helper()
+		env.command("/admin clock " + at)
+		before := len(env.telegram.messages())
+		reminderLoopRun(t, env)
+		var sent []string
+		for _, m := range env.telegram.messages()[before:] {
+			sent = append(sent, m.Params.Get("text"))
+		}
+		return strings.Join(sent, "\n")
+	}
+	if got := send("2026-03-07T19:00:00Z"); got != "" {
+		t.Errorf("sent %q two days early", got)
+	}
+	if got := send("2026-03-07T20:00:00Z"); got != `⏰ Heads-up: <b>Harbor Lights</b> S01E02 "Low Tide" comes out in 1 day.` {
+		t.Errorf("heads-up = %q", got)
+	}
+	if got := send("2026-03-08T20:00:00Z"); !strings.Contains(got, "is coming out today") {
+		t.Errorf("reminder = %q", got)
+	}
+	if got := send("2026-03-08T22:00:00Z"); got != "Harbor Lights S1E2 is out, go watch" {
+		t.Errorf("after the episode = %q", got)
+	}
+	if got := send("2026-03-08T23:00:00Z"); got != "" {
+		t.Errorf("sent %q again", got)
+	}
+
+	env.command("/shows")
+	env.press("deleteOffset:1:current:-1440")
+	if got := queryString(t, env, `SELECT group_concat(offset_minutes) FROM reminder_offsets`); got != "120" {
+		t.Errorf("offsets after deleting = %s, want 120", got)
+	}
+}
+
+func TestOffsetReminderRetriedAfterFailedSend(t *testing.T) {
+	env := newSimulationEnv(t)
+	env.command("/add harbor")
+	env.press("acceptShowName:1")
+	env.press("selectEpisode:1")
+	env.command("/shows")
+	env.press("selectShow:1:current")
+	env.press("reminderOffsets:1:current")
+	env.press("addOffset:1:current:-1440")
+
+	env.command("/admin clock 2026-03-07T20:00:00Z")
+	env.telegram.failChat(testUserID, 500, "Internal Server Error")
+	sendDueOffsetReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if got := queryString(t, env, `SELECT COUNT(*) FROM offset_reminders`); got != "0" {
+		t.Fatalf("%s extra reminders marked sent after a failed send", got)
+	}
+
+	env.telegram.unfailChat(testUserID)
+	env.command("/admin clock 2026-03-07T20:05:00Z")
+	before := len(env.telegram.messages())
+	sendDueOffsetReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if sent := env.telegram.messages()[before:]; len(sent) != 1 || !strings.HasPrefix(sent[0].Params.Get("text"), "⏰ Heads-up") {
+		t.Errorf("retry sent %d messages, want the heads-up", len(sent))
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM offset_reminders`); got != "1" {
+		t.Errorf("%s extra reminders marked sent after the retry, want 1", got)
+	}
+}
+
+// reminderLoopRun runs one round of the reminder loop at the simulated time, minus the
+// check-ins.
+func reminderLoopRun(t *testing.T, env *testEnv) {
+	t.Helper()
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	sendDueOffsetReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+}
+
+func TestParseOffset(t *testing.T) {
+	for in, want := range map[string]int{"-24h": -1440, "+2h": 120, "90m": 90, "-1d": -1440, "-1h30m": -90} {
+		if got, err := parseOffset(in); err != nil || got != want {
+			t.Errorf("parseOffset(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0h", "30s", "-8d", "tomorrow"} {
+		if _, err := parseOffset(in); err == nil {
+			t.Errorf("parseOffset(%q) succeeded", in)
+		}
+	}
+}
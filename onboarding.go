@@ -0,0 +1,308 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// referralPayloadPrefix starts /start payloads of invite links: ref_<user id>.
+const referralPayloadPrefix = "ref_"
+
+// onboardingQuietHours is the night window offered to new users.
+const onboardingQuietHours = "23:00-08:00"
+
+// onboardingPopularShows is how many popular shows the last onboarding step offers.
+const onboardingPopularShows = 5
+
+// onboardingTimezones are the time zones offered as buttons, as label and zone
+// name. Everyone else picks UTC and sets theirs with /timezone.
+var onboardingTimezones = [][2]string{
+	{"London", "Europe/London"},
+	{"Berlin", "Europe/Berlin"},
+	{"Moscow", "Europe/Moscow"},
+	{"New York", "America/New_York"},
+	{"Chicago", "America/Chicago"},
+	{"Los Angeles", "America/Los_Angeles"},
+	{"São Paulo", "America/Sao_Paulo"},
+	{"India", "Asia/Kolkata"},
+	{"Tokyo", "Asia/Tokyo"},
+	{"Sydney", "Australia/Sydney"},
+}
+
+// OnboardingStep is a step of the wizard that walks first-time users through their
+// settings. Each step's buttons save the answer and move on to the next one.
+type OnboardingStep int
+
+const (
+	OnboardingTimezone OnboardingStep = iota
+	OnboardingQuietHours
+	OnboardingShows
+	OnboardingDone
+)
+
+func referralLink(botUsername string, userID int64) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", botUsername, referralPayloadPrefix, userID)
+}
+
+func parseReferralPayload(payload string) (int64, bool) {
+	id, found := strings.CutPrefix(payload, referralPayloadPrefix)
+	if !found {
+		return 0, false
+	}
+	userID, err := strconv.ParseInt(id, 10, 64)
+	return userID, err == nil
+}
+
+// isNewUser reports whether the user has never used the bot: no settings and no shows.
+func isNewUser(ctx context.Context, db *store.DB, userID int64) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var known bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM user_settings WHERE user_id = ?)
+			OR EXISTS (SELECT 1 FROM shows WHERE user_id = ?)
+	`, userID, userID).Scan(&known)
+	return !known, err
+}
+
+// setReferrer records who invited the user, unless someone already did.
+func setReferrer(ctx context.Context, db *store.DB, userID, chatID, referrerID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE user_settings SET referred_by = ? WHERE user_id = ? AND referred_by IS NULL
+	`, referrerID, userID)
+	return err
+}
+
+func countReferrals(ctx context.Context, db *store.DB, userID int64) (int, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM user_settings WHERE referred_by = ?`, userID).Scan(&count)
+	return count, err
+}
+
+// listPopularShows returns the shows of provider tracked by the most users.
+func listPopularShows(ctx context.Context, db *store.DB, provider string, limit int) ([]ShowSearchResult, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT provider_show_id, MIN(name), MIN(network), MIN(poster_url)
+		FROM shows
+		WHERE provider = ? AND deleted_at IS NULL
+		GROUP BY provider_show_id
+		ORDER BY COUNT(DISTINCT user_id) DESC, MIN(name)
+		LIMIT ?
+	`, provider, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shows []ShowSearchResult
+	for rows.Next() {
+		var show ShowSearchResult
+		var network, posterURL string
+		if err := rows.Scan(&show.ID, &show.Name, &network, &posterURL); err != nil {
+			return nil, err
+		}
+		if network != "" {
+			show.Network = &Network{Name: network}
+		}
+		if posterURL != "" {
+			show.Image = &Image{Original: posterURL}
+		}
+		shows = append(shows, show)
+	}
+	return shows, rows.Err()
+}
+
+// startOnboarding greets a first-time user, credits whoever invited them and asks
+// the first onboarding question.
+func (handler *Handler) startOnboarding(ctx context.Context, msg *tgbotapi.Message, payload string) error {
+	userID := msg.From.ID
+	chatID := msg.Chat.ID
+
+	if err := ensureUserSettings(ctx, handler.DB, userID, chatID); err != nil {
+		return NewUserError(
+			fmt.Errorf("creating settings for user %d: %w", userID, err),
+			"Error: can't set you up at this time",
+		)
+	}
+	if referrerID, ok := parseReferralPayload(payload); ok && referrerID != userID {
+		handler.creditReferrer(ctx, userID, chatID, referrerID)
+	}
+
+	return handler.showOnboardingStep(ctx, userID, chatID, 0, OnboardingTimezone)
+}
+
+// creditReferrer records that referrerID invited the user and lets them know.
+// Links of people who never used the bot are ignored.
+func (handler *Handler) creditReferrer(ctx context.Context, userID, chatID, referrerID int64) {
+	unknown, err := isNewUser(ctx, handler.DB, referrerID)
+	if err != nil {
+		log.Printf("creditReferrer: checking referrer %d: %v", referrerID, err)
+		return
+	}
+	if unknown {
+		return
+	}
+	if err := setReferrer(ctx, handler.DB, userID, chatID, referrerID); err != nil {
+		log.Printf("creditReferrer: recording referrer of user %d: %v", userID, err)
+		return
+	}
+	handler.Bot.reply(ctx, referrerID, "A friend joined through your invite link! 🎉")
+}
+
+// showOnboardingStep asks the question of step, editing messageID when it's set.
+// Steps with nothing to ask are skipped.
+func (handler *Handler) showOnboardingStep(ctx context.Context, userID, chatID int64, messageID int, step OnboardingStep) error {
+	opts := ReplyOptions{EditMessageID: messageID}
+
+	switch step {
+	case OnboardingTimezone:
+		var rows [][][]string
+		for i := 0; i < len(onboardingTimezones); i += 2 {
+			var row [][]string
+			for j := i; j < min(i+2, len(onboardingTimezones)); j++ {
+				row = append(row, []string{onboardingTimezones[j][0], fmt.Sprintf("onboardTimezone:%d", j+1)})
+			}
+			rows = append(rows, row)
+		}
+		rows = append(rows, [][]string{{"Somewhere else (UTC for now)", "onboardTimezone:0"}})
+		opts.ReplyMarkup = makeKeyboardMarkup(rows)
+		handler.Bot.reply(ctx, chatID, dedent(`
+		Hello! I'll remind you when new episodes of your TV shows air.
+
+		First, where are you? I use your time zone for air times and quiet hours.
+		If your city isn't listed, pick UTC and set yours later with /timezone.
+		`), opts)
+
+	case OnboardingQuietHours:
+		opts.ReplyMarkup = makeKeyboardMarkup([][][]string{
+			{{"🌙 Yes, keep nights quiet", "onboardQuiet:1"}},
+			{{"🔔 No, remind me any time", "onboardQuiet:0"}},
+		})
+		handler.Bot.reply(ctx,
+			chatID,
+			fmt.Sprintf("Should I hold reminders during the night (%s) and send them in the morning?", onboardingQuietHours),
+			opts,
+		)
+
+	case OnboardingShows:
+		shows, err := listPopularShows(ctx, handler.DB, handler.Provider.Name(), onboardingPopularShows)
+		if err != nil {
+			log.Printf("showOnboardingStep: listing popular shows: %v", err)
+		}
+		if len(shows) == 0 {
+			return handler.showOnboardingStep(ctx, userID, chatID, messageID, OnboardingDone)
+		}
+
+		var rows [][][]string
+		for i, show := range shows {
+			rows = append(rows, [][]string{{trimString(show.Name, 30), fmt.Sprintf("acceptShowName:%d", i+1)}})
+		}
+		rows = append(rows, [][]string{{"Skip", "onboardDone"}})
+		handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+			ctx.SearchResults = shows
+			ctx.State = StateAwaitingShowSelection
+		})
+		opts.ReplyMarkup = makeKeyboardMarkup(rows)
+		handler.Bot.reply(ctx, chatID, "Want to start with one of the most popular shows here? You can /add any other.", opts)
+
+	case OnboardingDone:
+		handler.Bot.clearState(userID)
+		handler.Bot.reply(ctx, chatID, dedent(`
+		You're all set! Now add the shows you watch:
+
+		/add - Add a TV show to track
+		/invite - Invite friends to the bot
+		/help - Everything else I can do
+		`), opts)
+	}
+	return nil
+}
+
+func (handler *Handler) handleOnboardTimezoneCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	idx, err := strconv.Atoi(callbackParam)
+	if err != nil || idx < 0 || idx > len(onboardingTimezones) {
+		log.Printf("handleOnboardTimezoneCallback: invalid time zone index: %s", callbackParam)
+		return nil
+	}
+
+	userID := cb.From.ID
+	msg := cb.Message
+
+	timezone := "UTC"
+	if idx > 0 {
+		timezone = onboardingTimezones[idx-1][1]
+	}
+	if err := setUserTimezone(ctx, handler.DB, userID, msg.Chat.ID, timezone); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting time zone for user %d: %w", userID, err),
+			"Error: can't save your time zone at this time",
+		)
+	}
+
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return handler.showOnboardingStep(ctx, userID, msg.Chat.ID, msg.MessageID, OnboardingQuietHours)
+}
+
+func (handler *Handler) handleOnboardQuietCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	userID := cb.From.ID
+	msg := cb.Message
+
+	quietHours := ""
+	if callbackParam == "1" {
+		quietHours = onboardingQuietHours
+	}
+	if err := setQuietHours(ctx, handler.DB, userID, msg.Chat.ID, quietHours); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting quiet hours for user %d: %w", userID, err),
+			"Error: can't save quiet hours at this time",
+		)
+	}
+
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return handler.showOnboardingStep(ctx, userID, msg.Chat.ID, msg.MessageID, OnboardingShows)
+}
+
+func (handler *Handler) handleOnboardDoneCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) error {
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return handler.showOnboardingStep(ctx, cb.From.ID, cb.Message.Chat.ID, cb.Message.MessageID, OnboardingDone)
+}
+
+// INVITE command
+
+func (handler *Handler) handleInviteCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	userID := msg.From.ID
+	count, err := countReferrals(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("counting referrals of user %d: %w", userID, err),
+			"Error: can't get your invite link at this time",
+		)
+	}
+
+	text := fmt.Sprintf(
+		"Share this link to invite friends:\n%s\n\nFriends joined so far: %d",
+		referralLink(handler.Bot.Username, userID), count,
+	)
+	handler.Bot.reply(ctx, msg.Chat.ID, text)
+	return nil
+}
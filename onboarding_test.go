@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOnboarding(t *testing.T) {
+	const friendID int64 = 2002
+	env := newTestEnv(t, testShows()...)
+	if _, err := addShow(t.Context(), env.handler.DB, friendID, "Night Shift", "tvmaze", 1, "NBC", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	env.command("/start ref_2002")
+	var friendMessages []string
+	for _, m := range env.telegram.messages() {
+		if m.Params.Get("chat_id") == "2002" {
+			friendMessages = append(friendMessages, m.Params.Get("text"))
+		}
+	}
+	if want := []string{"A friend joined through your invite link! 🎉"}; !slices.Equal(friendMessages, want) {
+		t.Errorf("friend got %q, want %q", friendMessages, want)
+	}
+	last := env.telegram.lastMessage(t)
+	if got := last.Params.Get("text"); !strings.HasPrefix(got, "Hello! I'll remind you") {
+		t.Errorf("first step = %q", got)
+	}
+	if got := last.keyboard(t); len(got) != len(onboardingTimezones)+1 || got[3] != "onboardTimezone:4" {
+		t.Errorf("time zone keyboard = %v", got)
+	}
+
+	env.press("onboardTimezone:4")
+	if got := queryString(t, env, `SELECT timezone FROM user_settings WHERE user_id = 1001`); got != "America/New_York" {
+		t.Errorf("timezone = %s", got)
+	}
+	env.press("onboardQuiet:1")
+	if got := queryString(t, env, `SELECT quiet_hours FROM user_settings WHERE user_id = 1001`); got != onboardingQuietHours {
+		t.Errorf("quiet_hours = %s", got)
+	}
+	if got, want := env.telegram.lastMessage(t).keyboard(t), []string{"acceptShowName:1", "onboardDone"}; !slices.Equal(got, want) {
+		t.Errorf("popular shows keyboard = %v, want %v", got, want)
+	}
+	env.press("acceptShowName:1")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != `TV show "Night Shift" added. Which season are you on?` {
+		t.Errorf("after picking a popular show = %q", got)
+	}
+
+	// Only the first /start runs the wizard
+	env.command("/start")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "Hello! I'm a bot") {
+		t.Errorf("second /start = %q", got)
+	}
+
+	env.commandFrom(friendID, "/invite")
+	got := env.telegram.lastMessage(t).Params.Get("text")
+	if !strings.Contains(got, "https://t.me/test_bot?start=ref_2002") || !strings.HasSuffix(got, "Friends joined so far: 1") {
+		t.Errorf("/invite = %q", got)
+	}
+}
+
+func TestOnboardingWithoutPopularShows(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+
+	// Links from people who never used the bot aren't credited
+	env.command("/start ref_2002")
+	env.press("onboardTimezone:0")
+	env.press("onboardQuiet:0")
+
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "You're all set!") {
+		t.Errorf("last step = %q", got)
+	}
+	got := queryString(t, env, `SELECT timezone || '|' || quiet_hours || '|' || COALESCE(referred_by, '') FROM user_settings`)
+	if got != "UTC||" {
+		t.Errorf("settings = %q, want UTC without quiet hours or referrer", got)
+	}
+}
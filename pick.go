@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// /pick answers "what should I watch tonight?" from the user's backlog. /tonight
+// already sets up the evening summary, so it got a name of its own. Shows are ranked
+// by how short their next episode is, how close they are to the end of a season and
+// how long their oldest unwatched episode has been waiting.
+
+// maxPickAlternatives is how many shows /pick suggests after its first pick.
+const maxPickAlternatives = 3
+
+// pickCandidate is a show with an aired episode waiting, and what /pick ranks it by.
+type pickCandidate struct {
+	Show ShowProgress
+	// Runtime is the next episode's length in minutes, 0 when unknown.
+	Runtime int
+	// SeasonLeft is how many episodes of the season are left, the next one included.
+	SeasonLeft int
+}
+
+// pickScore ranks a candidate for /pick, higher first. Each factor is worth up to
+// 30 points, and pinned shows get a nudge like they do in the queue.
+func pickScore(c pickCandidate, now time.Time) float64 {
+	var score float64
+	if c.Runtime > 0 {
+		score += max(60-float64(c.Runtime), 0) / 2
+	} else {
+		// Unknown runtimes rank like a 40-minute episode
+		score += 10
+	}
+	if c.SeasonLeft > 0 {
+		score += 30 / float64(c.SeasonLeft)
+	}
+	if c.Show.NextAirDate.Valid {
+		waitingDays := now.Sub(c.Show.NextAirDate.Time).Hours() / 24
+		score += min(max(waitingDays, 0), 60) / 2
+	}
+	if c.Show.Pinned {
+		score += 10
+	}
+	return score
+}
+
+// pickReason says in a few words why a candidate was picked.
+func pickReason(c pickCandidate, now time.Time) string {
+	var reasons []string
+	if c.Runtime > 0 {
+		reasons = append(reasons, formatWatchTime(c.Runtime))
+	}
+	if c.SeasonLeft > 0 && c.SeasonLeft <= 3 {
+		reasons = append(reasons, fmt.Sprintf("%s left in season %d",
+			pluralize(c.SeasonLeft, "episode"), c.Show.NextEpisodeSeason.Int32))
+	}
+	if c.Show.NextAirDate.Valid {
+		if days := int(now.Sub(c.Show.NextAirDate.Time).Hours() / 24); days >= 7 {
+			reasons = append(reasons, fmt.Sprintf("waiting for %s", pluralize(days, "day")))
+		}
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// getPickDetails returns the runtime of a show's next episode and how many episodes
+// of its season are left from it on.
+func getPickDetails(ctx context.Context, db *store.DB, provider, providerShowID string, season, number int32) (runtime, seasonLeft int, err error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	err = db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE((
+				SELECT runtime FROM episodes_cache WHERE provider = ? AND provider_show_id = ? AND season = ? AND number = ?
+			), 0),
+			(SELECT COUNT(*) FROM episodes_cache WHERE provider = ? AND provider_show_id = ? AND season = ? AND number >= ?)
+	`, provider, providerShowID, season, number, provider, providerShowID, season, number).Scan(&runtime, &seasonLeft)
+	return runtime, seasonLeft, err
+}
+
+// listPicks returns the user's watchable shows, best pick first.
+func listPicks(ctx context.Context, db *store.DB, userID int64, now time.Time) ([]pickCandidate, error) {
+	shows, err := listShowsWithProgress(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var picks []pickCandidate
+	for _, show := range shows {
+		if show.EpisodesWaiting == 0 || show.Archived || show.DroppedAt.Valid || !show.NextEpisodeSeason.Valid {
+			continue
+		}
+		runtime, seasonLeft, err := getPickDetails(
+			ctx, db, show.Provider, show.ProviderShowID, show.NextEpisodeSeason.Int32, show.NextEpisodeNumber.Int32)
+		if err != nil {
+			return nil, err
+		}
+		picks = append(picks, pickCandidate{Show: show, Runtime: runtime, SeasonLeft: seasonLeft})
+	}
+	sort.SliceStable(picks, func(i, j int) bool {
+		return pickScore(picks[i], now) > pickScore(picks[j], now)
+	})
+	return picks, nil
+}
+
+func formatPick(c pickCandidate, now time.Time) string {
+	line := fmt.Sprintf("<b>%s</b> S%02dE%02d", html.EscapeString(c.Show.Name),
+		c.Show.NextEpisodeSeason.Int32, c.Show.NextEpisodeNumber.Int32)
+	if c.Show.NextEpisodeTitle != "" {
+		line += fmt.Sprintf(" \"%s\"", html.EscapeString(c.Show.NextEpisodeTitle))
+	}
+	if reason := pickReason(c, now); reason != "" {
+		line += " — " + reason
+	}
+	return line
+}
+
+func formatPicks(picks []pickCandidate, now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🍿 Tonight: %s\n", formatPick(picks[0], now))
+	if len(picks) > 1 {
+		b.WriteString("\nOr else:\n")
+		for _, c := range picks[1:] {
+			fmt.Fprintf(&b, "• %s\n", formatPick(c, now))
+		}
+	}
+	return b.String()
+}
+
+// PICK command
+
+func (handler *Handler) handlePickCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	now := handler.Clock.Now()
+
+	picks, err := listPicks(ctx, handler.DB, userID, now)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing picks for user %d: %w", userID, err),
+			"Error: can't pick a show at this time",
+		)
+	}
+	if len(picks) == 0 {
+		handler.Bot.reply(ctx, chatID, "You're all caught up, nothing is waiting to be watched.")
+		return nil
+	}
+	picks = picks[:min(len(picks), maxPickAlternatives+1)]
+
+	// The buttons open the picked shows' cards like the queue does
+	shows := make([]ShowProgress, len(picks))
+	var rows [][][]string
+	for i, c := range picks {
+		shows[i] = c.Show
+		rows = append(rows, [][]string{{trimString(c.Show.Name, 40), fmt.Sprintf("selectShow:%d:queue", c.Show.InternalID)}})
+	}
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.ShowsList = shows
+	})
+	handler.Bot.reply(ctx, chatID, formatPicks(picks, now), ReplyOptions{
+		ReplyMarkup: makeKeyboardMarkup(rows), ParseMode: "HTML",
+	})
+	return nil
+}
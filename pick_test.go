@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPickScore(t *testing.T) {
+	now := time.Now()
+	waiting := func(days int) ShowProgress {
+		return ShowProgress{NextAirDate: sql.NullTime{Time: now.AddDate(0, 0, -days), Valid: true}}
+	}
+	short := pickCandidate{Show: waiting(1), Runtime: 22, SeasonLeft: 8}
+	long := pickCandidate{Show: waiting(1), Runtime: 60, SeasonLeft: 8}
+	finale := pickCandidate{Show: waiting(1), Runtime: 60, SeasonLeft: 1}
+	forgotten := pickCandidate{Show: waiting(60), Runtime: 60, SeasonLeft: 8}
+	for _, tt := range []struct {
+		name          string
+		better, worse pickCandidate
+	}{
+		{"shorter episode", short, long},
+		{"season finale", finale, long},
+		{"older episode", forgotten, long},
+	} {
+		if pickScore(tt.better, now) <= pickScore(tt.worse, now) {
+			t.Errorf("%s: scored %.1f, not above %.1f", tt.name, pickScore(tt.better, now), pickScore(tt.worse, now))
+		}
+	}
+}
+
+func TestPickCommand(t *testing.T) {
+	shows := testShows()
+	for i := range shows[1].Episodes {
+		shows[1].Episodes[i].Runtime = 22
+	}
+	env := newTestEnv(t, shows...)
+
+	env.command("/pick")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "You're all caught up, nothing is waiting to be watched." {
+		t.Errorf("/pick without shows = %q", got)
+	}
+
+	trackShow(t, env, "1")
+	env.command("/add solo")
+	env.press("acceptShowName:1")
+	env.command("/pick")
+	reply := env.telegram.lastMessage(t)
+	text := reply.Params.Get("text")
+	if want := `🍿 Tonight: <b>Solo</b> S01E01 "Episode 1.1" — 22 minutes, 2 episodes left in season 1`; !strings.HasPrefix(text, want) {
+		t.Errorf("/pick = %q, want it to start with %q", text, want)
+	}
+	if !strings.Contains(text, "• <b>Night Shift</b> S02E02") {
+		t.Errorf("/pick = %q, want Night Shift as an alternative", text)
+	}
+	if got := reply.keyboard(t); !slices.Equal(got, []string{"selectShow:2:queue", "selectShow:1:queue"}) {
+		t.Errorf("/pick keyboard = %v", got)
+	}
+	env.press("selectShow:2:queue")
+	if text := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(text, "<b>Solo</b>") {
+		t.Errorf("card opened from /pick = %q", text)
+	}
+}
@@ -0,0 +1,198 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Shows keep the network they air on and the streaming service carrying them, the
+// web channel, which for web-only shows is also their network. /shows can be
+// narrowed to one of them with buttons below the list or with /shows <platform>;
+// the choice sticks for the list until the next /shows. Tags filter the list the
+// same way, see tags.go.
+
+// maxPlatformFilters bounds the filter buttons under /shows, the platforms with
+// the most shows going first. Tags get as many.
+const maxPlatformFilters = 6
+
+// platformFiltersPerRow is how many filter buttons share a keyboard row.
+const platformFiltersPerRow = 3
+
+// showsFilter is what /shows is narrowed to, and the choices offered for it.
+type showsFilter struct {
+	Platforms []string
+	Platform  string
+	Tags      []string
+	Tag       string
+}
+
+// apply returns the shows matching both the platform and the tag.
+func (f showsFilter) apply(shows []ShowProgress) []ShowProgress {
+	return filterShowsByTag(filterShowsByPlatform(shows, f.Platform), f.Tag)
+}
+
+func setShowWebChannel(ctx context.Context, db *store.DB, showID int64, webChannel string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `UPDATE shows SET web_channel = ? WHERE id = ?`, webChannel, showID)
+	return err
+}
+
+// showPlatforms returns the network and the web channel of a show, each once.
+func showPlatforms(show ShowProgress) []string {
+	var platforms []string
+	for _, platform := range []string{show.Network, show.WebChannel} {
+		if platform != "" && !slices.ContainsFunc(platforms, func(p string) bool { return strings.EqualFold(p, platform) }) {
+			platforms = append(platforms, platform)
+		}
+	}
+	return platforms
+}
+
+// listPlatforms returns the platforms of shows, the ones with the most shows first.
+func listPlatforms(shows []ShowProgress) []string {
+	return rankLabels(shows, showPlatforms)
+}
+
+// rankLabels returns the labels found on shows, the ones on the most shows first.
+func rankLabels(shows []ShowProgress, labelsOf func(ShowProgress) []string) []string {
+	counts := make(map[string]int)
+	var labels []string
+	for _, show := range shows {
+		for _, label := range labelsOf(show) {
+			if counts[label] == 0 {
+				labels = append(labels, label)
+			}
+			counts[label]++
+		}
+	}
+	slices.SortStableFunc(labels, func(a, b string) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(a, b)
+	})
+	return labels
+}
+
+// filterShowsByPlatform returns the shows on platform, all of them when it's empty.
+func filterShowsByPlatform(shows []ShowProgress, platform string) []ShowProgress {
+	if platform == "" {
+		return shows
+	}
+	var filtered []ShowProgress
+	for _, show := range shows {
+		if slices.ContainsFunc(showPlatforms(show), func(p string) bool { return strings.EqualFold(p, platform) }) {
+			filtered = append(filtered, show)
+		}
+	}
+	return filtered
+}
+
+// filterRows returns the filter buttons for choices, calling back to action with
+// their index, none when there's nothing to choose from. selected is marked and the
+// clear button lists everything again.
+func filterRows(choices []string, selected, icon, action, clear string) [][][]string {
+	if len(choices) < 2 && selected == "" {
+		return nil
+	}
+	var buttons [][]string
+	if selected != "" {
+		buttons = append(buttons, []string{clear, action + ":"})
+	}
+	for i, choice := range choices[:min(maxPlatformFilters, len(choices))] {
+		label := icon + " " + choice
+		if strings.EqualFold(choice, selected) {
+			label = "✅ " + choice
+		}
+		buttons = append(buttons, []string{label, fmt.Sprintf("%s:%d", action, i)})
+	}
+	var rows [][][]string
+	for start := 0; start < len(buttons); start += platformFiltersPerRow {
+		rows = append(rows, buttons[start:min(start+platformFiltersPerRow, len(buttons))])
+	}
+	return rows
+}
+
+// makeCurrentShowsKeyboard is the /shows keyboard, with the platform and tag filters
+// above the What's next? button.
+func (handler *Handler) makeCurrentShowsKeyboard(shows []ShowProgress, filter showsFilter) *tgbotapi.InlineKeyboardMarkup {
+	markup := handler.makeShowsKeyboard(shows, "current")
+	filters := filterRows(filter.Platforms, filter.Platform, "📺", "showsFilter", "All")
+	filters = append(filters, filterRows(filter.Tags, filter.Tag, "🏷", "showsTag", "All tags")...)
+	if len(filters) == 0 {
+		return markup
+	}
+	last := len(markup.InlineKeyboard) - 1
+	rows := append(slices.Clone(markup.InlineKeyboard[:last]), makeKeyboardMarkup(filters).InlineKeyboard...)
+	markup.InlineKeyboard = append(rows, markup.InlineKeyboard[last])
+	return markup
+}
+
+// currentShowsTitle is the header of /shows, naming what it's narrowed to.
+func currentShowsTitle(filter showsFilter) string {
+	title := "Your current shows"
+	if filter.Platform != "" {
+		title += " on " + filter.Platform
+	}
+	if filter.Tag != "" {
+		title += " tagged " + filter.Tag
+	}
+	return title + ":"
+}
+
+// handleShowsFilterCallback narrows /shows to the platform at the index in the
+// parameter, or lists all shows again when it's empty.
+func (handler *Handler) handleShowsFilterCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	userID := cb.From.ID
+	userCtx := handler.Bot.getUserContext(userID)
+	if userCtx == nil {
+		return NewUserError(
+			fmt.Errorf("no shows in context for user %d", userID),
+			"No shows found. Please start over with /shows",
+		)
+	}
+	filter := userCtx.ShowsFilter
+	filter.Platform = ""
+	if callbackParam != "" {
+		idx, err := strconv.Atoi(callbackParam)
+		if err != nil || idx < 0 || idx >= len(filter.Platforms) {
+			log.Printf("handleShowsFilterCallback: invalid platform index: %s", callbackParam)
+			return nil
+		}
+		filter.Platform = filter.Platforms[idx]
+	}
+	return handler.showFilteredShows(ctx, cb, filter)
+}
+
+// showFilteredShows narrows the /shows message of the callback to filter.
+func (handler *Handler) showFilteredShows(ctx context.Context, cb *tgbotapi.CallbackQuery, filter showsFilter) error {
+	userID := cb.From.ID
+	shows, err := listCurrentShowsWithProgress(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing current shows for user %d: %w", userID, err),
+			"Error: can't list shows at this time",
+		)
+	}
+	shows = filter.apply(shows)
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.ShowsList = shows
+		ctx.ShowsFilter = filter
+	})
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, currentShowsTitle(filter), ReplyOptions{
+		ReplyMarkup:   handler.makeCurrentShowsKeyboard(shows, filter),
+		EditMessageID: cb.Message.MessageID,
+	})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
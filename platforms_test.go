@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestShowsPlatformFilter(t *testing.T) {
+	shows := testShows()
+	shows[0].WebChannel = &Network{Name: "Peacock"}
+	shows[1].WebChannel = &Network{Name: "Netflix"}
+	env := newTestEnv(t, shows...)
+	trackShow(t, env, "1")
+	env.command("/add solo")
+	env.press("acceptShowName:1")
+
+	env.command("/shows")
+	labels := env.telegram.lastMessage(t).labels(t)
+	if !slices.Equal(labels[len(labels)-4:], []string{"📺 NBC", "📺 Netflix", "📺 Peacock", "▶️ What's next?"}) {
+		t.Fatalf("/shows keyboard = %v, want a filter for each platform", labels)
+	}
+
+	env.press("showsFilter:1")
+	list := env.telegram.lastMessage(t)
+	if got := list.Params.Get("text"); got != "Your current shows on Netflix:" {
+		t.Errorf("filtered list title = %q", got)
+	}
+	labels = list.labels(t)
+	if !strings.HasPrefix(labels[0], "Solo") || !slices.Contains(labels, "✅ Netflix") || !slices.Contains(labels, "All") {
+		t.Errorf("list filtered to Netflix = %v", labels)
+	}
+	// The filter holds when coming back from a show
+	env.press("selectShow:2:current")
+	env.press("backToShows:current")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "Your current shows on Netflix:" {
+		t.Errorf("list after going back = %q", got)
+	}
+
+	env.command("/shows peacock")
+	list = env.telegram.lastMessage(t)
+	if got := list.Params.Get("text"); got != "Your current shows on Peacock:" {
+		t.Errorf("/shows peacock title = %q", got)
+	}
+	if labels := list.labels(t); !strings.HasPrefix(labels[0], "Night Shift") || strings.HasPrefix(labels[1], "Solo") {
+		t.Errorf("/shows peacock = %v, want only Night Shift", labels)
+	}
+	env.press("selectShow:1:current")
+	if text := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(text, "Network: NBC\nStreaming: Peacock") {
+		t.Errorf("card = %q, want the network and the streaming service", text)
+	}
+
+	env.command("/shows hbo")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "You have no current shows on hbo." {
+		t.Errorf("/shows hbo = %q", got)
+	}
+}
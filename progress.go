@@ -0,0 +1,192 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// progressUpdateRe matches messages like "watched severance s2e4", "saw the bear 3x02"
+// or "finished the bear season 3".
+var progressUpdateRe = regexp.MustCompile(
+	`(?i)^\s*(?:i\s+)?(?:just\s+)?(?:watched|finished|saw|seen)\s+(.+?)\s*,?\s+` +
+		`(?:s(\d+)\s*e(\d+)|(\d+)x(\d+)|season\s+(\d+)(?:\s*,?\s*episode\s+(\d+))?)\s*[.!]*\s*$`,
+)
+
+// ProgressUpdate is a progress update parsed from a plain message. Episode is 0
+// when the whole season was watched.
+type ProgressUpdate struct {
+	Show    string
+	Season  int
+	Episode int
+}
+
+func parseProgressUpdate(text string) (ProgressUpdate, bool) {
+	m := progressUpdateRe.FindStringSubmatch(text)
+	if m == nil {
+		return ProgressUpdate{}, false
+	}
+
+	update := ProgressUpdate{Show: m[1]}
+	switch {
+	case m[2] != "":
+		update.Season, _ = strconv.Atoi(m[2])
+		update.Episode, _ = strconv.Atoi(m[3])
+	case m[4] != "":
+		update.Season, _ = strconv.Atoi(m[4])
+		update.Episode, _ = strconv.Atoi(m[5])
+	default:
+		update.Season, _ = strconv.Atoi(m[6])
+		if m[7] != "" {
+			update.Episode, _ = strconv.Atoi(m[7])
+		}
+	}
+	if update.Season == 0 {
+		return ProgressUpdate{}, false
+	}
+	return update, true
+}
+
+// normalizeShowName lowercases name and drops punctuation and a leading "the", so
+// "the bear" matches "The Bear" and "mr robot" matches "Mr. Robot".
+func normalizeShowName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	for i, word := range words {
+		words[i] = strings.ReplaceAll(word, "'", "")
+	}
+	if len(words) > 1 && words[0] == "the" {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+var (
+	errNoShowMatch        = errors.New("no tracked show matches")
+	errAmbiguousShowMatch = errors.New("several tracked shows match")
+)
+
+// matchShow finds the tracked show a user meant by query: an exact match of the
+// normalized name wins, otherwise the only show whose name contains the query.
+func matchShow(shows []ShowProgress, query string) (*ShowProgress, error) {
+	query = normalizeShowName(query)
+	if query == "" {
+		return nil, errNoShowMatch
+	}
+
+	var partial []int
+	for i, show := range shows {
+		name := normalizeShowName(show.Name)
+		if name == query {
+			return &shows[i], nil
+		}
+		if strings.Contains(name, query) {
+			partial = append(partial, i)
+		}
+	}
+	switch len(partial) {
+	case 0:
+		return nil, errNoShowMatch
+	case 1:
+		return &shows[partial[0]], nil
+	default:
+		return nil, errAmbiguousShowMatch
+	}
+}
+
+// acceptProgressUpdate updates progress from a plain message like "watched
+// severance s2e4". It reports false when the message doesn't look like one.
+func (handler *Handler) acceptProgressUpdate(ctx context.Context, msg *tgbotapi.Message) (bool, error) {
+	update, ok := parseProgressUpdate(msg.Text)
+	if !ok {
+		return false, nil
+	}
+
+	userID := msg.From.ID
+	chatID := msg.Chat.ID
+
+	shows, err := listShowsWithProgress(ctx, handler.DB, userID)
+	if err != nil {
+		return true, NewUserError(
+			fmt.Errorf("listing shows for user %d: %w", userID, err),
+			"Error updating progress",
+		)
+	}
+	show, err := matchShow(shows, update.Show)
+	if errors.Is(err, errAmbiguousShowMatch) {
+		return true, NewUserError(
+			fmt.Errorf("progress update %q matches several shows of user %d", update.Show, userID),
+			fmt.Sprintf("Several of your shows match \"%s\", please use the full name.", update.Show),
+		)
+	}
+	if err != nil {
+		return true, NewUserError(
+			fmt.Errorf("progress update %q matches no show of user %d", update.Show, userID),
+			fmt.Sprintf("You're not tracking a show called \"%s\". See /history.", update.Show),
+		)
+	}
+
+	var episode *DBEpisode
+	if update.Episode == 0 {
+		episode, err = findSeasonFinale(ctx, handler.DB, show.Provider, show.ProviderShowID, update.Season)
+	} else {
+		episode, err = findEpisodeByNumber(ctx, handler.DB, show.Provider, show.ProviderShowID, update.Season, update.Episode)
+	}
+	if err != nil {
+		what := fmt.Sprintf("season %d", update.Season)
+		if update.Episode != 0 {
+			what = fmt.Sprintf("S%02dE%02d", update.Season, update.Episode)
+		}
+		return true, NewUserError(
+			fmt.Errorf("finding %s of show %s: %w", what, show.ProviderShowID, err),
+			fmt.Sprintf("I can't find %s of \"%s\".", what, show.Name),
+		)
+	}
+
+	// Watching an episode of a watchlisted show means the user is tracking it now
+	if show.ReminderMode == ReminderModeWatchlist {
+		if err := setShowReminderMode(ctx, handler.DB, show.InternalID, ReminderModeEpisode); err != nil {
+			return true, NewUserError(
+				fmt.Errorf("setting reminder mode for show %d: %w", show.InternalID, err),
+				"Error updating progress",
+			)
+		}
+	}
+	previousID, err := getLastWatchedEpisodeID(ctx, handler.DB, show.InternalID)
+	if err != nil {
+		log.Printf("acceptProgressUpdate: getting progress of show %d: %v", show.InternalID, err)
+	}
+	if err := updateLastWatchedEpisode(ctx, handler.DB, show.InternalID, episode.ID, handler.Clock.Now()); err != nil {
+		return true, NewUserError(
+			fmt.Errorf("updating last watched episode for show %d: %w", show.InternalID, err),
+			"Error updating progress",
+		)
+	}
+	next, err := rebuildShowReminder(ctx, handler.DB, userID, show.InternalID, chatID, updateThread(ctx, chatID), handler.Clock.Now())
+	if err != nil {
+		return true, NewUserError(
+			fmt.Errorf("rebuilding reminder for show %d: %w", show.InternalID, err),
+			"Error updating reminder",
+		)
+	}
+
+	text := fmt.Sprintf("Marked \"%s\" as watched up to S%02dE%02d.", show.Name, episode.Season, episode.Number)
+	if next != nil {
+		text += fmt.Sprintf(
+			" I'll remind you about S%02dE%02d on %s.",
+			next.Season, next.Number, next.AiredAtUTC.Format("Mon Jan 2, 15:04"),
+		)
+	}
+	handler.Bot.reply(ctx, chatID, text)
+	handler.warnAboutGap(ctx, chatID, show.InternalID, show.Name, previousID, episode.ID)
+	handler.offerSeasonRating(ctx, chatID, show.InternalID, show.Name, episode)
+	return true, nil
+}
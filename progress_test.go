@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProgressUpdate(t *testing.T) {
+	tests := []struct {
+		text   string
+		want   ProgressUpdate
+		wantOK bool
+	}{
+		{"watched severance s2e4", ProgressUpdate{"severance", 2, 4}, true},
+		{"Watched Severance S02E04", ProgressUpdate{"Severance", 2, 4}, true},
+		{"I just saw the bear 3x02!", ProgressUpdate{"the bear", 3, 2}, true},
+		{"finished the bear season 3", ProgressUpdate{"the bear", 3, 0}, true},
+		{"watched mr. robot, season 1 episode 5", ProgressUpdate{"mr. robot", 1, 5}, true},
+		{"finished 1923 season 2", ProgressUpdate{"1923", 2, 0}, true},
+		{"watched severance", ProgressUpdate{}, false},
+		{"severance s2e4", ProgressUpdate{}, false},
+		{"watched severance season 0", ProgressUpdate{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseProgressUpdate(tt.text)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("parseProgressUpdate(%q) = %+v, %v, want %+v, %v", tt.text, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestMatchShow(t *testing.T) {
+	shows := []ShowProgress{{Name: "The Bear"}, {Name: "Mr. Robot"}, {Name: "Bear Grylls"}, {Name: "Robot Wars"}}
+	tests := []struct {
+		query   string
+		want    string
+		wantErr error
+	}{
+		{"the bear", "The Bear", nil},
+		{"bear", "The Bear", nil},
+		{"mr robot", "Mr. Robot", nil},
+		{"grylls", "Bear Grylls", nil},
+		{"robot", "", errAmbiguousShowMatch},
+		{"severance", "", errNoShowMatch},
+	}
+	for _, tt := range tests {
+		got, err := matchShow(shows, tt.query)
+		if err != tt.wantErr || (got != nil && got.Name != tt.want) {
+			t.Errorf("matchShow(%q) = %v, %v, want %q, %v", tt.query, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestProgressUpdateMessage(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "1")
+
+	env.text("watched night shift s2e2")
+	got := env.telegram.lastMessage(t).Params.Get("text")
+	if !strings.HasPrefix(got, `Marked "Night Shift" as watched up to S02E02. I'll remind you about S02E03 on`) {
+		t.Errorf("reply = %q", got)
+	}
+	if got := queryString(t, env, `SELECT e.number FROM reminders r JOIN episodes_cache e ON e.id = r.episode_id`); got != "3" {
+		t.Errorf("reminder for episode %s, want 3", got)
+	}
+
+	env.text("finished night shift season 1")
+	messages := env.telegram.messages()
+	if got := messages[len(messages)-2].Params.Get("text"); got != `Marked "Night Shift" as watched up to S01E03.` {
+		t.Errorf("reply = %q", got)
+	}
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "You finished season 1") {
+		t.Errorf("last message = %q, want a rating offer", got)
+	}
+}
+
+func TestProgressUpdateErrors(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "1")
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"watched severance s1e1", `You're not tracking a show called "severance". See /history.`},
+		{"watched night shift s5e1", `I can't find S05E01 of "Night Shift".`},
+		{"finished night shift season 5", `I can't find season 5 of "Night Shift".`},
+		{"what's on tonight?", "Unexpected message received, see /help for available commands."},
+	}
+	for _, tt := range tests {
+		env.text(tt.text)
+		if got := env.telegram.lastMessage(t).Params.Get("text"); got != tt.want {
+			t.Errorf("reply to %q = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package bot
+
+import "tvreminder/bot/internal/provider"
+
+// The metadata providers live in internal/provider. Their types are used all over
+// the bot, so they keep their short names here.
+type (
+	Provider             = provider.Provider
+	AvailabilityProvider = provider.AvailabilityProvider
+	ExternalIDProvider   = provider.ExternalIDProvider
+	UpdatesProvider      = provider.UpdatesProvider
+	ShowLookupProvider   = provider.ShowLookupProvider
+	MovieProvider        = provider.MovieProvider
+
+	ShowSearchResult = provider.ShowSearchResult
+	Episode          = provider.Episode
+	Network          = provider.Network
+	Country          = provider.Country
+	Image            = provider.Image
+	Externals        = provider.Externals
+	ExternalIDs      = provider.ExternalIDs
+	WatchOption      = provider.WatchOption
+	Movie            = provider.Movie
+)
+
+var (
+	ErrShowNotFound        = provider.ErrShowNotFound
+	ErrProviderUnavailable = provider.ErrProviderUnavailable
+)
@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+const queueTitle = "Your watch queue (most urgent first):"
+
+// queuePriority scores a show for the watch queue. Pinned shows always come first,
+// then shows with more episodes waiting, with a bonus for shows that have been
+// neglected the longest so they don't get stuck at the bottom forever.
+func queuePriority(show ShowProgress, now time.Time) float64 {
+	var score float64
+	if show.Pinned {
+		score += 1000
+	}
+	score += float64(min(show.EpisodesWaiting, 10)) * 10
+
+	idleDays := 30.0
+	if show.LastWatchedAt.Valid {
+		idleDays = min(now.Sub(show.LastWatchedAt.Time).Hours()/24, 30)
+	}
+	score += idleDays
+
+	return score
+}
+
+// listQueue returns the user's shows that have aired-but-unwatched episodes, plus
+// pinned shows even when nothing is waiting yet, ordered by queuePriority.
+func listQueue(ctx context.Context, db *store.DB, userID int64, now time.Time) ([]ShowProgress, error) {
+	shows, err := listShowsWithProgress(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var queue []ShowProgress
+	for _, show := range shows {
+		if show.Archived || show.DroppedAt.Valid {
+			continue
+		}
+		if show.EpisodesWaiting > 0 || (show.Pinned && show.ReminderMode != ReminderModeWatchlist) {
+			queue = append(queue, show)
+		}
+	}
+
+	sort.SliceStable(queue, func(i, j int) bool {
+		return queuePriority(queue[i], now) > queuePriority(queue[j], now)
+	})
+
+	return queue, nil
+}
+
+// QUEUE command flow
+
+func (handler *Handler) handleQueueCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	queue, err := listQueue(ctx, handler.DB, msg.From.ID, handler.Clock.Now())
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing queue for user %d: %w", msg.From.ID, err),
+			"Error: can't build your queue at this time",
+		)
+	}
+	if len(queue) == 0 {
+		handler.Bot.reply(ctx, chatID, "You're all caught up, nothing is waiting to be watched.")
+		return nil
+	}
+	handler.Bot.withUserContext(msg.From.ID, func(ctx *UserContext) {
+		ctx.ShowsList = queue
+	})
+	inlineMarkup := handler.makeShowsKeyboard(queue, "queue")
+	handler.Bot.reply(ctx, chatID, queueTitle, ReplyOptions{ReplyMarkup: inlineMarkup})
+	return nil
+}
+
+func (handler *Handler) handleWhatsNextCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) error {
+	userID := cb.From.ID
+
+	queue, err := listQueue(ctx, handler.DB, userID, handler.Clock.Now())
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing queue for user %d: %w", userID, err),
+			"Error: can't build your queue at this time",
+		)
+	}
+	// Pinned shows with nothing aired yet sit in the queue but aren't watchable
+	next := -1
+	for i, show := range queue {
+		if show.EpisodesWaiting > 0 {
+			next = i
+			break
+		}
+	}
+	if next == -1 {
+		return NewUserError(
+			fmt.Errorf("empty queue for user %d", userID),
+			"You're all caught up, nothing is waiting to be watched.",
+		)
+	}
+
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.ShowsList = queue
+	})
+	return handler.handleSelectShowCallback(ctx, cb, fmt.Sprintf("%d:queue", next))
+}
+
+func (handler *Handler) handleTogglePinnedCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showIDStr, listType, found := strings.Cut(callbackParam, ":")
+	if !found {
+		log.Printf("handleTogglePinnedCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	showID, err := strconv.Atoi(showIDStr)
+	if err != nil {
+		log.Printf("handleTogglePinnedCallback: invalid show ID: %s", showIDStr)
+		return nil
+	}
+
+	userID := cb.From.ID
+	msg := cb.Message
+
+	show, err := handler.validateAndGetShow(ctx, userID, msg.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	if err := toggleShowPinned(ctx, handler.DB, show.InternalID); err != nil {
+		return NewUserError(
+			fmt.Errorf("toggling pin for show %d: %w", show.InternalID, err),
+			"Error pinning show",
+		)
+	}
+
+	return handler.refreshShowDetail(ctx, cb, show, listType)
+}
@@ -0,0 +1,198 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Rate limits. Every update costs a token from the sender's bucket of updates, and
+// show searches, which hit the metadata provider, also cost one from a smaller
+// bucket of searches. A user who runs out is told once to slow down; further
+// updates are dropped silently until the bucket refills, so a flood doesn't turn
+// into a flood of replies. Each time a user hits a limit it is recorded in the
+// abuse log admins can read with /admin abuse.
+
+const (
+	RateLimitUpdates  = "updates"
+	RateLimitSearches = "searches"
+)
+
+// rateLimitIdle is how long a full bucket is kept around before it's forgotten.
+const rateLimitIdle = 10 * time.Minute
+
+// abuseLogRetention is how long abuse log entries are kept.
+const abuseLogRetention = 30 * 24 * time.Hour
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	// warned is set once the user was told to slow down, until the bucket refills.
+	warned bool
+}
+
+// RateLimiter is a token bucket per user: up to burst actions at once, refilled
+// at one action per interval.
+type RateLimiter struct {
+	Kind     string
+	burst    float64
+	interval time.Duration
+
+	mu        sync.Mutex
+	buckets   map[int64]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewRateLimiter(kind string, burst int, interval time.Duration) *RateLimiter {
+	return &RateLimiter{
+		Kind:     kind,
+		burst:    float64(burst),
+		interval: interval,
+		buckets:  make(map[int64]*tokenBucket),
+	}
+}
+
+// allow takes a token from the user's bucket. When there is none, warn reports
+// whether this is the first refusal since the user was last allowed.
+func (l *RateLimiter) allow(userID int64, now time.Time) (ok, warn bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > rateLimitIdle {
+		l.sweep(now)
+	}
+
+	bucket, found := l.buckets[userID]
+	if !found {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[userID] = bucket
+	}
+	refill := float64(now.Sub(bucket.last)) / float64(l.interval)
+	bucket.tokens = min(l.burst, bucket.tokens+refill)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		warn = !bucket.warned
+		bucket.warned = true
+		return false, warn
+	}
+	bucket.tokens--
+	bucket.warned = false
+	return true, false
+}
+
+// sweep forgets the buckets that have been idle long enough to be full again.
+func (l *RateLimiter) sweep(now time.Time) {
+	for userID, bucket := range l.buckets {
+		if now.Sub(bucket.last) > rateLimitIdle {
+			delete(l.buckets, userID)
+		}
+	}
+	l.lastSweep = now
+}
+
+// checkRateLimit applies limiter to the user, telling them to slow down the first
+// time they are refused. A nil limiter allows everything.
+func (handler *Handler) checkRateLimit(ctx context.Context, limiter *RateLimiter, user *tgbotapi.User, chatID int64) bool {
+	if limiter == nil || user == nil {
+		return true
+	}
+	ok, warn := limiter.allow(user.ID, time.Now())
+	if ok || !warn {
+		return ok
+	}
+
+	log.Printf("checkRateLimit: user %d (@%s) hit the %s limit", user.ID, user.UserName, limiter.Kind)
+	if err := logAbuse(ctx, handler.DB, user.ID, user.UserName, limiter.Kind); err != nil {
+		log.Printf("checkRateLimit: logging abuse by user %d: %v", user.ID, err)
+	}
+	text := "Whoa, that's a lot of messages! Please slow down and try again in a few seconds."
+	if limiter.Kind == RateLimitSearches {
+		text = "You're searching a lot right now. Please wait a minute before the next search."
+	}
+	handler.Bot.reply(ctx, chatID, text)
+	return false
+}
+
+func logAbuse(ctx context.Context, db *store.DB, userID int64, username, kind string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	now := time.Now().UTC()
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO abuse_log (user_id, username, kind, created_at) VALUES (?, ?, ?, ?)
+	`, userID, username, kind, now.Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		DELETE FROM abuse_log WHERE created_at < ?
+	`, now.Add(-abuseLogRetention).Format(time.RFC3339))
+	return err
+}
+
+// abuseSummary is how often a user hit the rate limits.
+type abuseSummary struct {
+	UserID   int64
+	Username string
+	Updates  int
+	Searches int
+	Last     string
+}
+
+func listAbuse(ctx context.Context, db *store.DB, since time.Time, limit int) ([]abuseSummary, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT user_id, MAX(username),
+			SUM(kind = ?), SUM(kind = ?), MAX(created_at)
+		FROM abuse_log
+		WHERE created_at >= ?
+		GROUP BY user_id
+		ORDER BY COUNT(*) DESC, MAX(created_at) DESC
+		LIMIT ?
+	`, RateLimitUpdates, RateLimitSearches, since.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []abuseSummary
+	for rows.Next() {
+		var s abuseSummary
+		if err := rows.Scan(&s.UserID, &s.Username, &s.Updates, &s.Searches, &s.Last); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
+
+func formatAbuse(summaries []abuseSummary) string {
+	if len(summaries) == 0 {
+		return "Nobody hit the rate limits in the last 24 hours."
+	}
+	text := "Rate limit hits in the last 24 hours:\n"
+	for _, s := range summaries {
+		who := fmt.Sprint(s.UserID)
+		if s.Username != "" {
+			who += " @" + s.Username
+		}
+		last := s.Last
+		if t, err := time.Parse(time.RFC3339, s.Last); err == nil {
+			last = t.Format("Jan 2 15:04 MST")
+		}
+		text += fmt.Sprintf(
+			"\n%s: %s, %s, last at %s",
+			who, pluralize(s.Updates, "flood"), pluralize(s.Searches, "search burst"), last,
+		)
+	}
+	return text
+}
@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitUpdates, 2, 10*time.Second)
+	now := time.Now()
+	steps := []struct {
+		after    time.Duration
+		wantOK   bool
+		wantWarn bool
+	}{
+		{wantOK: true},
+		{wantOK: true},
+		{wantOK: false, wantWarn: true},
+		{after: time.Second, wantOK: false},
+		{after: 10 * time.Second, wantOK: true},
+		{wantOK: false, wantWarn: true},
+	}
+	for i, step := range steps {
+		now = now.Add(step.after)
+		ok, warn := limiter.allow(testUserID, now)
+		if ok != step.wantOK || warn != step.wantWarn {
+			t.Errorf("step %d: allow = %v, %v, want %v, %v", i, ok, warn, step.wantOK, step.wantWarn)
+		}
+	}
+	if ok, _ := limiter.allow(testUserID+1, now); !ok {
+		t.Error("another user was limited")
+	}
+}
+
+func TestRateLimitedUserIsLogged(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.handler.UpdateLimiter = NewRateLimiter(RateLimitUpdates, 2, time.Hour)
+	env.handler.Admins = map[int64]bool{testUserID + 1: true}
+
+	for range 4 {
+		env.command("/help")
+	}
+	var slowDowns int
+	for _, req := range env.telegram.messages() {
+		if strings.HasPrefix(req.Params.Get("text"), "Whoa") {
+			slowDowns++
+		}
+	}
+	if slowDowns != 1 {
+		t.Errorf("told the user to slow down %d times, want 1", slowDowns)
+	}
+
+	env.commandFrom(testUserID+1, "/admin abuse")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "1001: 1 flood, 0 search bursts") {
+		t.Errorf("/admin abuse = %q", got)
+	}
+	env.commandFrom(testUserID+2, "/admin abuse")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "Unknown command") {
+		t.Errorf("/admin for a non-admin = %q", got)
+	}
+}
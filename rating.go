@@ -0,0 +1,207 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+const maxRating = 5
+
+// RatedShow is a show with the average of the user's season ratings.
+type RatedShow struct {
+	Name    string
+	Rating  float64
+	Seasons int
+}
+
+type RatingStats struct {
+	Seasons int
+	Average float64
+	// Top holds the best rated shows, best first.
+	Top []RatedShow
+}
+
+// isSeasonFinale reports whether episode is the last known episode of its season.
+func isSeasonFinale(ctx context.Context, db *store.DB, episode *DBEpisode) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var later int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM episodes_cache
+		WHERE provider = ? AND provider_show_id = ? AND season = ? AND number > ?
+	`, episode.Provider, episode.ProviderShowID, episode.Season, episode.Number).Scan(&later)
+	if err != nil {
+		return false, err
+	}
+	return later == 0, nil
+}
+
+// getSeasonRating returns the user's rating of a season, 0 if it isn't rated.
+func getSeasonRating(ctx context.Context, db *store.DB, showID int64, season int) (int, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var rating int
+	err := db.QueryRowContext(ctx, `
+		SELECT rating FROM season_ratings WHERE show_id = ? AND season = ?
+	`, showID, season).Scan(&rating)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return rating, err
+}
+
+func setSeasonRating(ctx context.Context, db *store.DB, userID, showID int64, season, rating int, now time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO season_ratings (show_id, season, user_id, rating, rated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(show_id, season) DO UPDATE SET rating = excluded.rating, rated_at = excluded.rated_at
+	`, showID, season, userID, rating, now.UTC().Format(time.RFC3339))
+	return err
+}
+
+func getRatingStats(ctx context.Context, db *store.DB, userID int64, top int) (*RatingStats, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var stats RatingStats
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(AVG(rating), 0) FROM season_ratings
+		WHERE user_id = ? AND show_id IN (SELECT id FROM shows WHERE deleted_at IS NULL)
+	`, userID).Scan(&stats.Seasons, &stats.Average)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.name, AVG(r.rating) AS average, COUNT(*)
+		FROM season_ratings r
+		JOIN shows s ON s.id = r.show_id
+		WHERE r.user_id = ? AND s.deleted_at IS NULL
+		GROUP BY r.show_id
+		ORDER BY average DESC, s.name
+		LIMIT ?
+	`, userID, top)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var show RatedShow
+		if err := rows.Scan(&show.Name, &show.Rating, &show.Seasons); err != nil {
+			return nil, err
+		}
+		stats.Top = append(stats.Top, show)
+	}
+	return &stats, rows.Err()
+}
+
+// formatRating renders an average rating like "⭐4.5".
+func formatRating(rating float64) string {
+	return "⭐" + strconv.FormatFloat(rating, 'f', 1, 64)
+}
+
+// offerSeasonRating asks the user to rate the season when episode was its finale
+// and the season isn't rated yet. Failures are only logged: the progress update it
+// follows has already succeeded.
+func (handler *Handler) offerSeasonRating(ctx context.Context, chatID, showID int64, showName string, episode *DBEpisode) {
+	finale, err := isSeasonFinale(ctx, handler.DB, episode)
+	if err != nil {
+		log.Printf("offerSeasonRating: checking finale for show %d: %v", showID, err)
+		return
+	}
+	if !finale {
+		return
+	}
+	rating, err := getSeasonRating(ctx, handler.DB, showID, episode.Season)
+	if err != nil {
+		log.Printf("offerSeasonRating: getting rating for show %d: %v", showID, err)
+		return
+	}
+	if rating > 0 {
+		return
+	}
+
+	var buttons [][]string
+	for stars := 1; stars <= maxRating; stars++ {
+		buttons = append(buttons, []string{
+			fmt.Sprintf("%d⭐", stars), fmt.Sprintf("rateSeason:%d:%d:%d", showID, episode.Season, stars),
+		})
+	}
+	keyboard := makeKeyboardMarkup([][][]string{buttons})
+	handler.Bot.reply(ctx,
+		chatID,
+		fmt.Sprintf("You finished season %d of \"%s\"! How would you rate it?", episode.Season, showName),
+		ReplyOptions{ReplyMarkup: keyboard, Lasting: true},
+	)
+}
+
+func (handler *Handler) handleRateSeasonCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	parts := strings.Split(callbackParam, ":")
+	if len(parts) != 3 {
+		log.Printf("handleRateSeasonCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	showID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		log.Printf("handleRateSeasonCallback: invalid show id: %s", parts[0])
+		return nil
+	}
+	season, err := strconv.Atoi(parts[1])
+	if err != nil {
+		log.Printf("handleRateSeasonCallback: invalid season: %s", parts[1])
+		return nil
+	}
+	rating, err := strconv.Atoi(parts[2])
+	if err != nil || rating < 1 || rating > maxRating {
+		log.Printf("handleRateSeasonCallback: invalid rating: %s", parts[2])
+		return nil
+	}
+
+	userID := cb.From.ID
+	msg := cb.Message
+
+	show, err := getShowByID(ctx, handler.DB, showID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && show.UserID != userID) {
+		return NewUserError(
+			fmt.Errorf("rating show %d not tracked by user %d", showID, userID),
+			"You're not tracking this show anymore.",
+		)
+	}
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting show %d: %w", showID, err),
+			"Error saving your rating",
+		)
+	}
+
+	if err := setSeasonRating(ctx, handler.DB, userID, showID, season, rating, handler.Clock.Now()); err != nil {
+		return NewUserError(
+			fmt.Errorf("rating season %d of show %d: %w", season, showID, err),
+			"Error saving your rating",
+		)
+	}
+
+	handler.Bot.reply(ctx,
+		msg.Chat.ID,
+		fmt.Sprintf("You rated season %d of \"%s\" %s.", season, show.Name, strings.Repeat("⭐", rating)),
+		ReplyOptions{EditMessageID: msg.MessageID},
+	)
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
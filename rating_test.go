@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRateSeasonAfterFinale(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.command("/add night")
+	env.press("acceptShowName:1")
+	env.press("selectSeason:1")
+	env.press("selectEpisode:2")
+	env.command("/history")
+	env.press("selectShow:1:history")
+	env.press("markNextWatched:1:history")
+
+	offer := env.telegram.lastMessage(t)
+	if !strings.Contains(offer.Params.Get("text"), "You finished season 1") {
+		t.Fatalf("last message = %q, want a rating offer", offer.Params.Get("text"))
+	}
+	if !slices.Contains(offer.keyboard(t), "rateSeason:1:1:4") {
+		t.Fatalf("keyboard = %v, want rating buttons", offer.keyboard(t))
+	}
+	env.press("rateSeason:1:1:4")
+
+	if got := queryString(t, env, `SELECT rating FROM season_ratings WHERE show_id = 1 AND season = 1`); got != "4" {
+		t.Errorf("rating = %s, want 4", got)
+	}
+
+	env.command("/history")
+	if got := env.telegram.lastMessage(t).Params.Get("reply_markup"); !strings.Contains(got, "⭐4.0") {
+		t.Errorf("history keyboard = %s, want the rating", got)
+	}
+	env.command("/stats")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "Seasons rated: 1, on average ⭐4.0") {
+		t.Errorf("stats = %q", got)
+	}
+
+	// Re-watching the finale doesn't ask again
+	if _, err := env.handler.DB.Exec(`
+		UPDATE shows SET last_watched_episode_id = (SELECT id FROM episodes_cache WHERE season = 1 AND number = 2)
+	`); err != nil {
+		t.Fatal(err)
+	}
+	env.command("/history")
+	env.press("selectShow:1:history")
+	env.press("markNextWatched:1:history")
+	if got := env.telegram.lastMessage(t).Method; got != "editMessageText" {
+		t.Errorf("last message method = %s, want only the refreshed card", got)
+	}
+}
+
+func TestNoRatingOfferMidSeason(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "1")
+	env.command("/history")
+	env.press("selectShow:1:history")
+	env.press("markNextWatched:1:history")
+
+	for _, req := range env.telegram.messages() {
+		if strings.Contains(req.Params.Get("text"), "How would you rate it?") {
+			t.Fatalf("unexpected rating offer %q", req.Params.Get("text"))
+		}
+	}
+}
+
+func TestRateSeasonRejectsOtherUsersShow(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "3")
+	if _, err := env.handler.DB.Exec(`UPDATE shows SET user_id = 42`); err != nil {
+		t.Fatal(err)
+	}
+	env.press("rateSeason:1:2:5")
+
+	if got := queryString(t, env, `SELECT COUNT(*) FROM season_ratings`); got != "0" {
+		t.Errorf("ratings = %s, want none", got)
+	}
+}
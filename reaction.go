@@ -0,0 +1,170 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Reacting 👍 to an episode reminder marks its episode watched. The library has no
+// message_reaction updates, so they're decoded from the raw updates like topics,
+// and sent reminders are recorded to find the episode a reaction is about. Telegram
+// only sends reactions in groups where the bot is an admin.
+
+// watchedReaction is the reaction that marks an episode watched.
+const watchedReaction = "👍"
+
+// reminderMessageRetention is how long a reminder can be reacted to.
+const reminderMessageRetention = 30 * 24 * time.Hour
+
+// ReactionType is a reaction on a message; Emoji is empty for custom and paid ones.
+type ReactionType struct {
+	Type  string `json:"type"`
+	Emoji string `json:"emoji"`
+}
+
+// MessageReactionUpdated is a user's change of their reactions to a message.
+type MessageReactionUpdated struct {
+	Chat      tgbotapi.Chat `json:"chat"`
+	MessageID int           `json:"message_id"`
+	// User is nil for reactions made anonymously on behalf of a chat.
+	User        *tgbotapi.User `json:"user"`
+	OldReaction []ReactionType `json:"old_reaction"`
+	NewReaction []ReactionType `json:"new_reaction"`
+}
+
+// added reports whether the emoji is among the new reactions but wasn't before.
+func (r *MessageReactionUpdated) added(emoji string) bool {
+	has := func(reactions []ReactionType) bool {
+		return slices.ContainsFunc(reactions, func(t ReactionType) bool {
+			return t.Type == "emoji" && t.Emoji == emoji
+		})
+	}
+	return has(r.NewReaction) && !has(r.OldReaction)
+}
+
+// rawUpdate holds the fields of an update that tgbotapi.Update drops.
+type rawUpdate struct {
+	updateTopic
+	MessageReaction *MessageReactionUpdated `json:"message_reaction"`
+}
+
+// ReminderMessage is a sent episode reminder.
+type ReminderMessage struct {
+	ChatID        int64
+	MessageID     int
+	ThreadID      int
+	UserID        int64
+	ShowID        int64
+	EpisodeID     int64
+	ShowName      string
+	EpisodeSeason int
+	EpisodeNumber int
+	// Watched is set when the user's progress already covers the episode.
+	Watched bool
+}
+
+// recordReminderMessage remembers the message a reminder was sent as, with the
+// last episode it covers, and forgets the ones too old to react to.
+func recordReminderMessage(ctx context.Context, db *store.DB, r DBReminder, msg tgbotapi.Message, sentAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO reminder_messages (chat_id, message_id, thread_id, user_id, show_id, episode_id, sent_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, r.ChatID, msg.MessageID, r.ThreadID, r.UserID, r.ShowID, r.lastEpisodeID(), sentAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		DELETE FROM reminder_messages WHERE sent_at < ?
+	`, sentAt.Add(-reminderMessageRetention).UTC().Format(time.RFC3339))
+	return err
+}
+
+// getReminderMessage returns sql.ErrNoRows for messages that aren't reminders of a
+// show still on the user's list.
+func getReminderMessage(ctx context.Context, db *store.DB, chatID int64, messageID int) (*ReminderMessage, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var m ReminderMessage
+	err := db.QueryRowContext(ctx, `
+		SELECT m.chat_id, m.message_id, m.thread_id, m.user_id, m.show_id, m.episode_id, s.name, e.season, e.number,
+			COALESCE(w.season > e.season OR (w.season = e.season AND w.number >= e.number), 0)
+		FROM reminder_messages m
+		JOIN shows s ON s.id = m.show_id AND s.deleted_at IS NULL
+		JOIN episodes_cache e ON e.id = m.episode_id
+		LEFT JOIN episodes_cache w ON w.id = s.last_watched_episode_id
+		WHERE m.chat_id = ? AND m.message_id = ?
+	`, chatID, messageID).Scan(
+		&m.ChatID, &m.MessageID, &m.ThreadID, &m.UserID, &m.ShowID, &m.EpisodeID, &m.ShowName,
+		&m.EpisodeSeason, &m.EpisodeNumber, &m.Watched,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// setUpdateReaction remembers the reaction of a received update until it is handled.
+func (bot *Bot) setUpdateReaction(updateID int, reaction *MessageReactionUpdated) {
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+	if bot.updateReactions == nil {
+		bot.updateReactions = make(map[int]*MessageReactionUpdated)
+	}
+	bot.updateReactions[updateID] = reaction
+}
+
+func (bot *Bot) takeUpdateReaction(updateID int) *MessageReactionUpdated {
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+	reaction := bot.updateReactions[updateID]
+	delete(bot.updateReactions, updateID)
+	return reaction
+}
+
+// handleReaction marks the episode of a reminder watched when its user reacts 👍 to
+// it. Other reactions, and reactions to other messages, are ignored.
+func (handler *Handler) handleReaction(ctx context.Context, reaction *MessageReactionUpdated) {
+	if reaction.User == nil || !reaction.added(watchedReaction) {
+		return
+	}
+	userID := reaction.User.ID
+	chatID := reaction.Chat.ID
+
+	m, err := getReminderMessage(ctx, handler.DB, chatID, reaction.MessageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("handleReaction: getting reminder message %d in chat %d: %v", reaction.MessageID, chatID, err)
+		return
+	}
+	// In groups, only the user the reminder is for can mark it
+	if m.UserID != userID || m.Watched {
+		return
+	}
+
+	opts := ReplyOptions{ThreadID: m.ThreadID}
+	if err := updateLastWatchedEpisode(ctx, handler.DB, m.ShowID, m.EpisodeID, handler.Clock.Now()); err != nil {
+		handler.replyError(ctx, userID, chatID, NewUserError(
+			fmt.Errorf("updating progress of show %d from a reaction: %w", m.ShowID, err),
+			"Error: can't update your progress at this time",
+		), opts)
+		return
+	}
+	handler.Bot.reply(ctx, chatID, fmt.Sprintf(
+		"✅ Marked %s S%02dE%02d as watched.", m.ShowName, m.EpisodeSeason, m.EpisodeNumber,
+	), opts)
+}
@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// react delivers a reaction update as if userID changed their reactions to the
+// message from old to new.
+func (env *testEnv) react(userID int64, messageID int, old, new string) {
+	reactions := func(emoji string) []ReactionType {
+		if emoji == "" {
+			return nil
+		}
+		return []ReactionType{{Type: "emoji", Emoji: emoji}}
+	}
+	const updateID = 7
+	env.handler.Bot.setUpdateReaction(updateID, &MessageReactionUpdated{
+		Chat:        tgbotapi.Chat{ID: testUserID, Type: "private"},
+		MessageID:   messageID,
+		User:        &tgbotapi.User{ID: userID},
+		OldReaction: reactions(old),
+		NewReaction: reactions(new),
+	})
+	env.handler.handleUpdate(context.Background(), tgbotapi.Update{UpdateID: updateID})
+}
+
+func TestReactionMarksReminderWatched(t *testing.T) {
+	env := dueReminderEnv(t)
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	messageID, err := strconv.Atoi(queryString(t, env, `SELECT message_id FROM reminder_messages`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress := func() string {
+		return queryString(t, env, `
+			SELECT e.season || 'x' || e.number FROM shows s JOIN episodes_cache e ON e.id = s.last_watched_episode_id
+		`)
+	}
+
+	env.react(testUserID+1, messageID, "", "👍")
+	env.react(testUserID, messageID, "", "🔥")
+	env.react(testUserID, messageID+1, "", "👍")
+	if got := progress(); got != "2x2" {
+		t.Fatalf("progress after unrelated reactions = %s, want 2x2", got)
+	}
+
+	env.react(testUserID, messageID, "🔥", "👍")
+	if got := progress(); got != "2x3" {
+		t.Errorf("progress after 👍 = %s, want 2x3", got)
+	}
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "✅ Marked Night Shift S02E03 as watched." {
+		t.Errorf("reply = %q", got)
+	}
+}
@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// A bug in one handler shouldn't take the bot down for everyone, so a panic while
+// handling an update is recovered: it's logged with its stack, the user is told
+// something went wrong, and admins get an alert.
+
+// panicAlertBurst and panicAlertInterval limit the alerts each admin gets, so a
+// handler that panics on every update doesn't flood them.
+const (
+	panicAlertBurst    = 3
+	panicAlertInterval = 10 * time.Minute
+)
+
+var panicAlerts = NewRateLimiter("panic alerts", panicAlertBurst, panicAlertInterval)
+
+// recoverUpdate must be deferred directly by the update handler.
+func (handler *Handler) recoverUpdate(ctx context.Context, update tgbotapi.Update) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	log.Printf("handleUpdate: panic handling update %d: %v\n%s", update.UpdateID, r, debug.Stack())
+
+	var userID int64
+	if user := update.SentFrom(); user != nil {
+		userID = user.ID
+	}
+	if chat := update.FromChat(); chat != nil {
+		text := "Sorry, something went wrong. Please try again in a moment."
+		id := handler.reportIncident(ctx, userID, chat.ID, fmt.Errorf("panic handling update %d: %v", update.UpdateID, r), text)
+		handler.Bot.reply(ctx, chat.ID, text+incidentNote(id))
+	}
+	if update.CallbackQuery != nil {
+		handler.Bot.answerCallbackQuery(update.CallbackQuery.ID)
+	}
+
+	who := "unknown user"
+	if user := update.SentFrom(); user != nil {
+		who = fmt.Sprintf("user %d", user.ID)
+		if user.UserName != "" {
+			who += " @" + user.UserName
+		}
+	}
+	alert := fmt.Sprintf("⚠️ Panic handling update %d from %s: %v\nSee the logs for the stack trace.", update.UpdateID, who, r)
+	now := handler.Clock.Now()
+	for adminID := range handler.Admins {
+		if ok, _ := panicAlerts.allow(adminID, now); ok {
+			handler.Bot.reply(ctx, adminID, alert)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// panickingProvider is a provider with a bug in its search.
+type panickingProvider struct {
+	Provider
+}
+
+func (panickingProvider) SearchShow(ctx context.Context, query string) ([]ShowSearchResult, error) {
+	var results []ShowSearchResult
+	return results[:1], nil
+}
+
+func TestPanicInHandlerIsRecovered(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.handler.Provider = panickingProvider{env.handler.Provider}
+	env.handler.Admins = map[int64]bool{testUserID + 1: true}
+
+	env.command("/add night")
+
+	var apology, alert bool
+	for _, req := range env.telegram.messages() {
+		text := req.Params.Get("text")
+		switch req.Params.Get("chat_id") {
+		case "1001":
+			apology = apology || strings.HasPrefix(text, "Sorry, something went wrong")
+		case "1002":
+			alert = alert || strings.Contains(text, "Panic handling update") && strings.Contains(text, "out of range")
+		}
+	}
+	if !apology {
+		t.Error("the user wasn't told something went wrong")
+	}
+	if !alert {
+		t.Error("the admin wasn't alerted")
+	}
+
+	// The bot keeps working
+	env.command("/help")
+	if text := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(text, "/add") {
+		t.Errorf("/help after a panic = %q", text)
+	}
+}
@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// The sync loop refreshes shows in the background, which can take up to a day to
+// notice a change. The show card's Refresh data button runs the same sync for one
+// show on demand and reports what it found.
+
+// handleRefreshShowCallback re-fetches a show's episodes, reschedules reminders for
+// episodes that moved and rebuilds the user's pending reminder.
+func (handler *Handler) handleRefreshShowCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleRefreshShowCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	chatID := cb.Message.Chat.ID
+	show, err := handler.validateAndGetShow(ctx, cb.From.ID, chatID, showID, listType)
+	if err != nil {
+		return err
+	}
+	if show.Provider != handler.Provider.Name() {
+		return NewUserError(
+			fmt.Errorf("refreshing show %d from %s: provider is %s", show.InternalID, show.Provider, handler.Provider.Name()),
+			"This show comes from another show database and can't be refreshed.",
+		)
+	}
+	if !handler.checkRateLimit(ctx, handler.SearchLimiter, cb.From, chatID) {
+		handler.Bot.answerCallbackQuery(cb.ID)
+		return nil
+	}
+	handler.Bot.sendChatAction(chatID, tgbotapi.ChatTyping)
+
+	startedAt := time.Now()
+	syncCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	result, err := syncShow(syncCtx, handler.DB, handler.Provider, show.ProviderShowID, handler.Clock.Now())
+	cancel()
+	if errors.Is(err, ErrProviderUnavailable) {
+		return NewUserError(
+			fmt.Errorf("refreshing show %d: %w", show.InternalID, err),
+			"My show database isn't answering right now. Please try again in a few minutes.",
+		)
+	}
+	if errors.Is(err, ErrShowNotFound) {
+		// Its owners, this user among them, hear where it went or that it's gone
+		if _, err := relocateShow(ctx, handler.Bot, handler.DB, handler.Provider, show.ProviderShowID, handler.Clock.Now()); err != nil {
+			log.Printf("handleRefreshShowCallback: relocating show %s: %v", show.ProviderShowID, err)
+		}
+		handler.Bot.answerCallbackQuery(cb.ID)
+		return nil
+	}
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("refreshing show %d: %w", show.InternalID, err),
+			"Error refreshing the show, please try again later",
+		)
+	}
+	if err := markShowSynced(ctx, handler.DB, show.Provider, show.ProviderShowID, startedAt); err != nil {
+		log.Printf("handleRefreshShowCallback: recording sync of show %s: %v", show.ProviderShowID, err)
+	}
+	if err := clearShowMissing(ctx, handler.DB, show.Provider, show.ProviderShowID); err != nil {
+		log.Printf("handleRefreshShowCallback: clearing missing flag of show %s: %v", show.ProviderShowID, err)
+	}
+
+	// The user's own reminder goes first, so the announcements and alerts other
+	// owners get below don't repeat what the summary tells them
+	next, err := rebuildShowReminder(ctx, handler.DB, cb.From.ID, show.InternalID, chatID, updateThread(ctx, chatID), handler.Clock.Now())
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("rebuilding reminder for show %d: %w", show.InternalID, err),
+			"Error updating reminder",
+		)
+	}
+	applyScheduleChanges(ctx, handler.Bot, handler.DB, result.Changes, cb.From.ID)
+	if len(result.Added) > 0 {
+		announceNewEpisodes(ctx, handler.Bot, handler.DB, show.Provider, show.ProviderShowID, result.Added, handler.Clock.Now())
+	}
+
+	settings, err := getUserSettings(ctx, handler.DB, cb.From.ID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting settings for user %d: %w", cb.From.ID, err),
+			"Error loading your settings",
+		)
+	}
+	handler.Bot.reply(ctx, chatID, formatRefreshSummary(show.Name, result, next, settings.Location()), ReplyOptions{ParseMode: "HTML"})
+	return handler.refreshShowDetail(ctx, cb, show, listType)
+}
+
+// formatRefreshSummary describes what a refresh found and when the next reminder is.
+func formatRefreshSummary(showName string, result *syncResult, next *DBEpisode, loc *time.Location) string {
+	const layout = "Mon Jan 2, 15:04"
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🔄 Refreshed <b>%s</b>\n", html.EscapeString(showName))
+	switch {
+	case len(result.Added) == 0 && len(result.Changes) == 0:
+		sb.WriteString("Nothing changed since the last update.\n")
+	case len(result.Added) == 1:
+		sb.WriteString("1 new episode\n")
+	case len(result.Added) > 1:
+		fmt.Fprintf(&sb, "%d new episodes\n", len(result.Added))
+	}
+	for _, change := range result.Changes {
+		fmt.Fprintf(&sb, "S%02dE%02d moved from %s to %s\n",
+			change.Season, change.Number,
+			change.OldAiredAt.In(loc).Format(layout), change.NewAiredAt.In(loc).Format(layout),
+		)
+	}
+	if next == nil {
+		sb.WriteString("\nNo upcoming episode to remind you about.")
+	} else {
+		fmt.Fprintf(&sb, "\nNext reminder: S%02dE%02d, airs %s", next.Season, next.Number, next.AiredAtUTC.In(loc).Format(layout))
+	}
+	return sb.String()
+}
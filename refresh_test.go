@@ -0,0 +1,52 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRefreshShow(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	env.command("/history")
+	env.press("selectShow:1:history")
+
+	// The finale slips by two days and a fourth episode is announced
+	moved := time.Now().AddDate(0, 0, 6).UTC().Truncate(time.Minute)
+	env.tvmaze.updateShow(1, func(show *fakeShow) {
+		last := &show.Episodes[len(show.Episodes)-1]
+		last.Airdate = moved.Format("2006-01-02")
+		last.Airtime = moved.Format("15:04")
+		last.Airstamp = moved.Format(time.RFC3339)
+		show.Episodes = append(show.Episodes, makeEpisodes(1, 2, 4, time.Now().AddDate(0, 0, -10))[3])
+	})
+	before := len(env.telegram.messages())
+	env.press("refreshShow:1:history")
+
+	var summary string
+	for _, msg := range env.telegram.messages()[before:] {
+		if text := msg.Params.Get("text"); strings.HasPrefix(text, "🔄 Refreshed") {
+			summary = text
+		}
+		if text := msg.Params.Get("text"); strings.HasPrefix(text, "Schedule change") {
+			t.Errorf("refresh also sent an alert: %q", text)
+		}
+	}
+	for _, want := range []string{"<b>Night Shift</b>", "1 new episode", "S02E03 moved from", "Next reminder: S02E03"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary = %q, want it to mention %q", summary, want)
+		}
+	}
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "Night Shift") {
+		t.Errorf("card after refresh = %q, want the show card", got)
+	}
+
+	var remindAt time.Time
+	if err := env.handler.DB.QueryRow(`SELECT remind_at FROM reminders WHERE sent_at IS NULL`).Scan(&remindAt); err != nil {
+		t.Fatal(err)
+	}
+	if !remindAt.Equal(moved) {
+		t.Errorf("reminder at %s, want %s", remindAt, moved)
+	}
+}
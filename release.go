@@ -0,0 +1,252 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Episode air times come from the original network. Viewers elsewhere often get a
+// show through a streaming service that releases episodes later, so a show can be
+// switched to follow one of its watch options instead. The service is stored on the
+// show together with how far behind the original airing it runs, and reminders are
+// shifted by that delay.
+
+// releaseDelays are the delays offered for a streaming release.
+var releaseDelays = []struct {
+	Hours int
+	Label string
+}{
+	{0, "Same time as the original"},
+	{24, "A day later"},
+	{7 * 24, "A week later"},
+}
+
+// releaseTime is when an episode comes out on the release schedule the show follows.
+func releaseTime(airedAt time.Time, delayHours int) time.Time {
+	if airedAt.IsZero() {
+		return airedAt
+	}
+	return airedAt.Add(time.Duration(delayHours) * time.Hour)
+}
+
+func setShowRelease(ctx context.Context, db *store.DB, showID int64, service string, delayHours int) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE shows SET release_service = ?, release_delay_hours = ? WHERE id = ?
+	`, service, delayHours, showID)
+	return err
+}
+
+func describeRelease(network, service string, delayHours int) string {
+	if service == "" {
+		if network == "" {
+			return "original airing"
+		}
+		return "original airing on " + network
+	}
+	for _, delay := range releaseDelays {
+		if delay.Hours == delayHours {
+			return fmt.Sprintf("%s, %s", service, strings.ToLower(delay.Label))
+		}
+	}
+	return fmt.Sprintf("%s, %d hours after the original", service, delayHours)
+}
+
+// releaseServices lists the services a show can follow: the ones it streams on in
+// the user's country, or on anywhere if the user hasn't set one.
+func (handler *Handler) releaseServices(ctx context.Context, userID int64, show *DBShow) ([]string, error) {
+	settings, err := getUserSettings(ctx, handler.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	options, err := listWatchOptions(ctx, handler.DB, show.Provider, show.ProviderShowID)
+	if err != nil {
+		return nil, err
+	}
+	return watchServices(options, settings.Country), nil
+}
+
+// parseReleaseCallback splits "<showID>:<listType>[:<args>...]" callback data.
+func parseReleaseCallback(callbackParam string, args int) (int, string, []string, bool) {
+	parts := strings.Split(callbackParam, ":")
+	if len(parts) != 2+args {
+		return 0, "", nil, false
+	}
+	showID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", nil, false
+	}
+	return showID, parts[1], parts[2:], true
+}
+
+func (handler *Handler) getReleaseShow(ctx context.Context, cb *tgbotapi.CallbackQuery, showID int, listType string) (*ShowProgress, *DBShow, error) {
+	progress, err := handler.validateAndGetShow(ctx, cb.From.ID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return nil, nil, err
+	}
+	show, err := getShowByID(ctx, handler.DB, progress.InternalID)
+	if err != nil {
+		return nil, nil, NewUserError(
+			fmt.Errorf("getting show %d: %w", progress.InternalID, err),
+			"Error loading the show",
+		)
+	}
+	return progress, show, nil
+}
+
+// handleReleaseScheduleCallback lists the release schedules a show can follow.
+func (handler *Handler) handleReleaseScheduleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleReleaseScheduleCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	_, show, err := handler.getReleaseShow(ctx, cb, showID, listType)
+	if err != nil {
+		return err
+	}
+	services, err := handler.releaseServices(ctx, cb.From.ID, show)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing release services for show %d: %w", show.ID, err),
+			"Error loading release schedules",
+		)
+	}
+
+	text := fmt.Sprintf(
+		"Which release should reminders for <b>%s</b> follow?\nCurrently: %s",
+		html.EscapeString(show.Name),
+		html.EscapeString(describeRelease(show.Network, show.ReleaseService, show.ReleaseDelayHours)),
+	)
+	original := "📡 Original airing"
+	if show.Network != "" {
+		original += " on " + show.Network
+	}
+	rows := [][][]string{{{original, fmt.Sprintf("setRelease:%s:0:0", callbackParam)}}}
+	for i, service := range services {
+		rows = append(rows, [][]string{{"📺 " + service, fmt.Sprintf("releaseDelay:%s:%d", callbackParam, i+1)}})
+	}
+	if len(services) == 0 {
+		text += "\n\nI don't know where this show streams, so only the original airing is available."
+	}
+	rows = append(rows, [][]string{{"<< Back", "selectShow:" + callbackParam}})
+
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, text, ReplyOptions{
+		ReplyMarkup: makeKeyboardMarkup(rows), ParseMode: "HTML", EditMessageID: cb.Message.MessageID,
+	})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleReleaseDelayCallback asks how far behind the original airing a service runs.
+func (handler *Handler) handleReleaseDelayCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, args, ok := parseReleaseCallback(callbackParam, 1)
+	if !ok {
+		log.Printf("handleReleaseDelayCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	_, show, err := handler.getReleaseShow(ctx, cb, showID, listType)
+	if err != nil {
+		return err
+	}
+	services, err := handler.releaseServices(ctx, cb.From.ID, show)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing release services for show %d: %w", show.ID, err),
+			"Error loading release schedules",
+		)
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 || n > len(services) {
+		return NewUserError(
+			fmt.Errorf("release service %s out of range for show %d", args[0], show.ID),
+			"This service is no longer available, please pick again.",
+		)
+	}
+
+	var rows [][][]string
+	for _, delay := range releaseDelays {
+		rows = append(rows, [][]string{{delay.Label, fmt.Sprintf("setRelease:%s:%d", callbackParam, delay.Hours)}})
+	}
+	rows = append(rows, [][]string{{"<< Back", fmt.Sprintf("releaseSchedule:%d:%s", showID, listType)}})
+
+	text := fmt.Sprintf(
+		"When does %s release new episodes of <b>%s</b>, compared to the original airing?",
+		html.EscapeString(services[n-1]), html.EscapeString(show.Name),
+	)
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, text, ReplyOptions{
+		ReplyMarkup: makeKeyboardMarkup(rows), ParseMode: "HTML", EditMessageID: cb.Message.MessageID,
+	})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleSetReleaseCallback saves the release schedule and moves the pending reminder
+// to match. Service 0 is the original airing.
+func (handler *Handler) handleSetReleaseCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, args, ok := parseReleaseCallback(callbackParam, 2)
+	if !ok {
+		log.Printf("handleSetReleaseCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	progress, show, err := handler.getReleaseShow(ctx, cb, showID, listType)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Printf("handleSetReleaseCallback: invalid service: %s", args[0])
+		return nil
+	}
+	hours, err := strconv.Atoi(args[1])
+	if err != nil || hours < 0 {
+		log.Printf("handleSetReleaseCallback: invalid delay: %s", args[1])
+		return nil
+	}
+
+	service := ""
+	if n > 0 {
+		services, err := handler.releaseServices(ctx, cb.From.ID, show)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("listing release services for show %d: %w", show.ID, err),
+				"Error loading release schedules",
+			)
+		}
+		if n > len(services) {
+			return NewUserError(
+				fmt.Errorf("release service %d out of range for show %d", n, show.ID),
+				"This service is no longer available, please pick again.",
+			)
+		}
+		service = services[n-1]
+	} else {
+		hours = 0
+	}
+
+	if err := setShowRelease(ctx, handler.DB, show.ID, service, hours); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting release schedule for show %d: %w", show.ID, err),
+			"Error saving the release schedule",
+		)
+	}
+	if _, err := rebuildShowReminder(ctx, handler.DB, cb.From.ID, show.ID, cb.Message.Chat.ID, updateThread(ctx, cb.Message.Chat.ID), handler.Clock.Now()); err != nil {
+		return NewUserError(
+			fmt.Errorf("rebuilding reminder for show %d: %w", show.ID, err),
+			"Error updating reminder",
+		)
+	}
+
+	return handler.refreshShowDetail(ctx, cb, progress, listType)
+}
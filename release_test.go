@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReleaseSchedule(t *testing.T) {
+	env := newTestEnv(t, streamingShows()...)
+	env.command("/country DE")
+	trackShow(t, env, "2")
+	env.handler.DB.Exec(`
+		INSERT INTO watch_options (provider, provider_show_id, country, service, url)
+		SELECT provider, provider_show_id, 'DE', 'Joyn', '' FROM shows
+	`)
+	remindAt := func() string {
+		return queryString(t, env, `SELECT strftime('%Y-%m-%d %H:%M', substr(remind_at, 1, 19)) FROM reminders`)
+	}
+	original := remindAt()
+	env.command("/history")
+	env.press("selectShow:1:history")
+
+	env.press("releaseSchedule:1:history")
+	if got := env.telegram.lastMessage(t).keyboard(t); !strings.Contains(strings.Join(got, " "), "releaseDelay:1:history:1") {
+		t.Fatalf("schedule keyboard = %v, want the Joyn release", got)
+	}
+	env.press("releaseDelay:1:history:1")
+	env.press("setRelease:1:history:1:24")
+
+	text := env.telegram.lastMessage(t).Params.Get("text")
+	if !strings.Contains(text, "Release schedule: Joyn, a day later") {
+		t.Errorf("card %q doesn't show the release schedule", text)
+	}
+	delayed := queryString(t, env, `
+		SELECT strftime('%Y-%m-%d %H:%M', e.aired_at_utc, '+1 day')
+		FROM reminders r JOIN episodes_cache e ON e.id = r.episode_id
+	`)
+	if got := remindAt(); got != delayed || got == original {
+		t.Errorf("reminder at %s, want a day after the original %s", got, original)
+	}
+
+	env.press("setRelease:1:history:0:0")
+	if got := remindAt(); got != original {
+		t.Errorf("reminder at %s after going back to the original airing, want %s", got, original)
+	}
+}
@@ -0,0 +1,226 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Providers sometimes merge duplicate shows or remove them, and the tracked ID
+// starts answering 404. relocateShow then looks the show up by its external IDs
+// and moves everything stored under the old ID to the new one. When the show
+// can't be found, its owners are told once, so they can add it again.
+
+// episodeRefs are the columns holding episodes_cache IDs. Reminders and check-ins
+// are unique per episode, so they may already exist for the new episode.
+var episodeRefs = []struct {
+	table, column string
+	unique        bool
+}{
+	{"shows", "last_watched_episode_id", false},
+	{"group_shows", "last_watched_episode_id", false},
+	{"group_shows", "last_announced_episode_id", false},
+	{"group_members", "last_watched_episode_id", false},
+	{"trakt_pushes", "episode_id", false},
+	{"reminder_messages", "episode_id", false},
+	{"watch_log", "episode_id", true},
+	{"skipped_episodes", "episode_id", true},
+	{"premiere_hypes", "episode_id", true},
+	{"offset_reminders", "episode_id", true},
+	{"reminders", "episode_id", true},
+	{"followups", "episode_id", true},
+}
+
+// moveProviderShow moves a show from oldID to newID: it stores the new episodes,
+// points everything at the new episode with the same number and retires the old
+// ones. Old episodes without a counterpart are kept under the new ID.
+func moveProviderShow(ctx context.Context, db *store.DB, provider, oldID, newID string, episodes []Episode) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Episodes the provider carried over change show here
+	if err := writeEpisodes(ctx, tx, provider, newID, episodes); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT o.id, n.id
+		FROM episodes_cache o
+		JOIN episodes_cache n ON n.provider = o.provider AND n.provider_show_id = ?
+			AND n.season = o.season AND n.number = o.number
+		WHERE o.provider = ? AND o.provider_show_id = ?
+	`, newID, provider, oldID)
+	if err != nil {
+		return err
+	}
+	moved := make(map[int64]int64)
+	for rows.Next() {
+		var from, to int64
+		if err := rows.Scan(&from, &to); err != nil {
+			rows.Close()
+			return err
+		}
+		moved[from] = to
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for from, to := range moved {
+		for _, ref := range episodeRefs {
+			update := `UPDATE `
+			if ref.unique {
+				update += `OR IGNORE `
+			}
+			update += ref.table + ` SET ` + ref.column + ` = ? WHERE ` + ref.column + ` = ?`
+			if _, err := tx.ExecContext(ctx, update, to, from); err != nil {
+				return fmt.Errorf("moving %s.%s: %w", ref.table, ref.column, err)
+			}
+			if ref.unique {
+				// Left behind because the new episode already had one
+				if _, err := tx.ExecContext(ctx, `DELETE FROM `+ref.table+` WHERE `+ref.column+` = ?`, from); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM episodes_cache WHERE id = ?`, from); err != nil {
+			return err
+		}
+	}
+
+	for _, query := range []string{
+		`UPDATE episodes_cache SET provider_show_id = ? WHERE provider = ? AND provider_show_id = ?`,
+		`UPDATE OR IGNORE shows SET provider_show_id = ?, provider_missing_at = NULL WHERE provider = ? AND provider_show_id = ?`,
+		`UPDATE OR IGNORE group_shows SET provider_show_id = ? WHERE provider = ? AND provider_show_id = ?`,
+		`UPDATE OR REPLACE show_external_ids SET provider_show_id = ? WHERE provider = ? AND provider_show_id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, newID, provider, oldID); err != nil {
+			return err
+		}
+	}
+	// Streaming options are fetched again for the new ID
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM watch_options WHERE provider = ? AND provider_show_id = ?
+	`, provider, oldID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// missingShowOwner is a user tracking a show the provider lost.
+type missingShowOwner struct {
+	ShowID int64
+	UserID int64
+	Name   string
+}
+
+// claimMissingShowOwners returns the owners of a lost show who haven't been told
+// yet, and records that they have been.
+func claimMissingShowOwners(ctx context.Context, db *store.DB, provider, providerShowID string, now time.Time) ([]missingShowOwner, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT s.id, s.user_id, s.name FROM shows s
+		LEFT JOIN user_settings us ON us.user_id = s.user_id
+		WHERE s.provider = ? AND s.provider_show_id = ? AND s.deleted_at IS NULL
+		AND s.provider_missing_at IS NULL AND us.inactive_since IS NULL
+	`, provider, providerShowID)
+	if err != nil {
+		return nil, err
+	}
+	var owners []missingShowOwner
+	for rows.Next() {
+		var owner missingShowOwner
+		if err := rows.Scan(&owner.ShowID, &owner.UserID, &owner.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		owners = append(owners, owner)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE shows SET provider_missing_at = ?
+		WHERE provider = ? AND provider_show_id = ? AND provider_missing_at IS NULL
+	`, now.UTC().Format(time.RFC3339), provider, providerShowID); err != nil {
+		return nil, err
+	}
+	return owners, tx.Commit()
+}
+
+// clearShowMissing marks a show found again after a successful sync.
+func clearShowMissing(ctx context.Context, db *store.DB, provider, providerShowID string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE shows SET provider_missing_at = NULL
+		WHERE provider = ? AND provider_show_id = ? AND provider_missing_at IS NOT NULL
+	`, provider, providerShowID)
+	return err
+}
+
+// relocateShow handles a show the provider no longer has: it's moved to the ID the
+// provider now knows it by, or its owners are told it's gone. It returns the new
+// ID, empty when the show couldn't be found.
+func relocateShow(ctx context.Context, bot *Bot, db *store.DB, provider Provider, providerShowID string, now time.Time) (string, error) {
+	newID := 0
+	if lookup, ok := provider.(ShowLookupProvider); ok {
+		// The provider can't be asked for the IDs of a show it lost, so only
+		// cached ones help
+		ids, err := getExternalIDs(ctx, db, provider, provider.Name(), providerShowID)
+		if err != nil {
+			log.Printf("syncLoop: external IDs of missing show %s: %v", providerShowID, err)
+		}
+		if ids.TVDB != 0 || ids.IMDB != "" {
+			if newID, err = lookup.LookupShow(ctx, ids); err != nil {
+				return "", fmt.Errorf("looking up show: %w", err)
+			}
+		}
+	}
+
+	if newID != 0 && strconv.Itoa(newID) != providerShowID {
+		episodes, err := provider.FetchEpisodes(ctx, newID)
+		if err != nil {
+			return "", fmt.Errorf("fetching episodes of show %d: %w", newID, err)
+		}
+		if err := moveProviderShow(ctx, db, provider.Name(), providerShowID, strconv.Itoa(newID), episodes); err != nil {
+			return "", fmt.Errorf("moving show to %d: %w", newID, err)
+		}
+		return strconv.Itoa(newID), nil
+	}
+
+	owners, err := claimMissingShowOwners(ctx, db, provider.Name(), providerShowID, now)
+	if err != nil {
+		return "", fmt.Errorf("listing owners: %w", err)
+	}
+	for _, owner := range owners {
+		bot.reply(ctx, owner.UserID, fmt.Sprintf(
+			"⚠️ \"%s\" was removed from my show database and I couldn't find where it went, so I can't "+
+				"see its new episodes. Try adding it again with /add, then delete the old one from /shows.",
+			owner.Name,
+		))
+	}
+	return "", nil
+}
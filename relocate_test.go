@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSyncRelocatesMergedShow(t *testing.T) {
+	shows := testShows()
+	shows[0].Externals = &Externals{TheTVDB: 555}
+	env := newTestEnv(t, shows...)
+	trackShow(t, env, "2")
+	// The first sync caches the external IDs
+	syncAllShows(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Provider, env.handler.Clock)
+
+	// TVmaze merges the show into a new entry with new episode IDs
+	env.tvmaze.updateShow(1, func(show *fakeShow) {
+		show.ID = 101
+		for i := range show.Episodes {
+			show.Episodes[i].ID += 100000
+		}
+	})
+	syncAllShows(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Provider, env.handler.Clock)
+
+	if got := queryString(t, env, `SELECT provider_show_id FROM shows`); got != "101" {
+		t.Fatalf("show ID after the merge = %s, want 101", got)
+	}
+	progress := `
+		SELECT e.provider_show_id || ' ' || e.season || 'x' || e.number
+		FROM shows s JOIN episodes_cache e ON e.id = s.last_watched_episode_id
+	`
+	if got := queryString(t, env, progress); got != "101 2x2" {
+		t.Errorf("progress after the merge = %s, want 101 2x2", got)
+	}
+	reminder := `
+		SELECT e.provider_show_id || ' ' || e.season || 'x' || e.number
+		FROM reminders r JOIN episodes_cache e ON e.id = r.episode_id WHERE r.sent_at IS NULL
+	`
+	if got := queryString(t, env, reminder); got != "101 2x3" {
+		t.Errorf("reminder after the merge = %s, want 101 2x3", got)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM episodes_cache WHERE provider_show_id = '1'`); got != "0" {
+		t.Errorf("%s episodes left under the old ID", got)
+	}
+}
+
+func TestSyncReportsRemovedShowOnce(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	env.tvmaze.updateShow(1, func(show *fakeShow) { show.ID = 999 })
+
+	removed := func() int {
+		n := 0
+		for _, msg := range env.telegram.messages() {
+			if strings.Contains(msg.Params.Get("text"), "was removed from my show database") {
+				n++
+			}
+		}
+		return n
+	}
+	syncAllShows(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Provider, env.handler.Clock)
+	if n := removed(); n != 1 {
+		t.Fatalf("sent %d removal notices, want 1", n)
+	}
+	syncAllShows(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Provider, env.handler.Clock)
+	if n := removed(); n != 1 {
+		t.Errorf("sent %d removal notices after syncing again, want still 1", n)
+	}
+}
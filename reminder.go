@@ -1,48 +1,243 @@
-package main
+package bot
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"html"
 	"log"
 	"time"
-	"database/sql"
-	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
 )
 
-func reminderLoop(bot *Bot, db *sql.DB, ctx context.Context) {
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
+const maxReminderAttempts = 5
+
+// maxCaptionLength is Telegram's limit for photo captions.
+const maxCaptionLength = 1024
+
+// reminderBackoff is the delay before retrying a reminder after its n-th failed attempt.
+func reminderBackoff(attempt int) time.Duration {
+	return min(time.Minute<<(attempt-1), time.Hour)
+}
 
-	for {
-		select {
-		case <-ticker.C:
-			reminders, err := getDueReminders(db)
+// reminderQueue tells the reminder loop when something is next due.
+type reminderQueue struct {
+	db *store.DB
+}
+
+func (q reminderQueue) NextDue(ctx context.Context, now time.Time) (time.Time, error) {
+	return nextReminderDue(ctx, q.db, now)
+}
+
+// reminderSender sends the reminders and check-ins due, one run of the reminder
+// loop. Whoever changes them wakes the loop's scheduler, see Handler.Reminders.
+type reminderSender struct {
+	bot *Bot
+	db  *store.DB
+}
+
+func (s reminderSender) SendDue(ctx context.Context, now time.Time) {
+	sendDueReminders(ctx, s.bot, s.db, now)
+	sendDueOffsetReminders(ctx, s.bot, s.db, now)
+	sendDueMovieReminders(ctx, s.bot, s.db, now)
+	sendDueFollowups(ctx, s.bot, s.db, now)
+}
+
+// sendDueReminders delivers every reminder due at now.
+func sendDueReminders(ctx context.Context, bot *Bot, db *store.DB, now time.Time) {
+	reminders, err := getDueReminders(ctx, db, now)
+	if err != nil {
+		log.Printf("reminderLoop: getDueReminders error: %v", err)
+		return
+	}
+	if len(reminders) != 0 {
+		log.Printf("reminderLoop: %d reminders due", len(reminders))
+	}
+	// The same queries run for every reminder, prepare them once for the run
+	stmts := store.NewStmtCache(db)
+	defer stmts.Close()
+	for _, r := range reminders {
+		r.Templates = bot.Templates
+		if r.Muted {
+			// The user paused the show: the episode counts as reminded about, and
+			// reminders pick up with the first episode after the mute
+			log.Printf("reminderLoop: skipping reminder %d, show %d is muted", r.ID, r.ShowID)
+			if err := markReminderSent(ctx, db, r, now); err != nil {
+				log.Printf("reminderLoop: failed to mark muted reminder sent: %v", err)
+			}
+			continue
+		}
+		if r.ReminderMode == ReminderModeEpisode {
+			// Episodes airing together get one reminder instead of one ping each
+			r.Batch, err = findEpisodesAiringWith(ctx, stmts, r.EpisodeID)
 			if err != nil {
-				log.Printf("reminderLoop: getDueReminders error: %v", err)
-				continue
+				log.Printf("reminderLoop: finding episodes airing with %d: %v", r.EpisodeID, err)
+			}
+		}
+		log.Printf(
+			"reminderLoop: sending reminder chat=%d show=%q episode=%d title=%q",
+			r.ChatID, r.ShowName, r.EpisodeNumber, r.EpisodeTitle,
+		)
+		r.Targets, err = listReminderTargets(ctx, stmts, r.ShowID)
+		if err != nil {
+			log.Printf("reminderLoop: listing other chats of show %d: %v", r.ShowID, err)
+		}
+		primary, mirrors := reminderNotifiers(bot, db, r)
+		err := primary.Notify(ctx, r, now)
+		if logErr := logDelivery(ctx, stmts, r.UserID, err != nil); logErr != nil {
+			log.Printf("reminderLoop: failed to log delivery of reminder %d: %v", r.ID, logErr)
+		}
+		if err != nil {
+			handleReminderSendError(ctx, db, r, err, now)
+			continue
+		}
+		// Mirrors are best effort: the reminder already reached its main channel
+		for _, n := range mirrors {
+			if err := n.Notify(ctx, r, now); err != nil {
+				log.Printf("reminderLoop: mirroring reminder %d to %s: %v", r.ID, n.Name(), err)
 			}
-			if len(reminders) != 0 {
-				log.Printf("reminderLoop: %d reminders due", len(reminders))
+		}
+
+		if err := markReminderSent(ctx, db, r, now); err != nil {
+			log.Printf("reminderLoop: failed to mark reminder sent: %v", err)
+		}
+		if err := enqueueReminderWebhook(ctx, stmts, r); err != nil {
+			log.Printf("reminderLoop: failed to queue webhook for reminder %d: %v", r.ID, err)
+		}
+		if r.AutoAdvance && r.ReminderMode == ReminderModeEpisode {
+			// Opted in with /autoadvance: count the episode as watched instead of asking
+			if err := updateLastWatchedEpisode(ctx, db, r.ShowID, r.lastEpisodeID(), now); err != nil {
+				log.Printf("reminderLoop: failed to advance progress for reminder %d: %v", r.ID, err)
 			}
-			for _, r := range reminders {
-				log.Printf(
-					"reminderLoop: sending reminder chat=%d show=%q episode=%d title=%q",
-					r.ChatID, r.ShowName, r.EpisodeNumber, r.EpisodeTitle,
-				)
-				bot.reply(
-					r.ChatID,
-					fmt.Sprintf(
-						"Episode #%d \"%s\" of \"%s\" (season %d) is coming out today!",
-						r.EpisodeNumber, r.EpisodeTitle, r.ShowName, r.EpisodeSeason,
-					),
-				)
-
-				if err := markReminderSent(db, r); err != nil {
-					log.Printf("reminderLoop: failed to mark reminder sent: %v", err)
-				}
+		} else if err := scheduleFollowup(ctx, stmts, r, now); err != nil {
+			log.Printf("reminderLoop: failed to schedule check-in for reminder %d: %v", r.ID, err)
+		}
+	}
+}
+
+// sendReminder sends the reminder as a captioned photo when there is artwork for it,
+// falling back to plain text if Telegram can't use the image.
+func sendReminder(ctx context.Context, bot *Bot, r DBReminder, now time.Time) (tgbotapi.Message, error) {
+	text := formatReminderText(r, now)
+	opts := ReplyOptions{ParseMode: "HTML", ThreadID: r.ThreadID, Silent: r.Silent}
+	if links := reminderLinksKeyboard(r); links != nil {
+		opts.ReplyMarkup = links
+	}
+	if r.ImageURL != "" && len(text) <= maxCaptionLength {
+		msg, err := bot.sendPhoto(ctx, r.ChatID, r.ImageURL, text, opts)
+		var apiErr *tgbotapi.Error
+		if !errors.As(err, &apiErr) || apiErr.Code != 400 {
+			return msg, err
+		}
+		log.Printf("reminderLoop: photo %s rejected, sending text instead: %v", r.ImageURL, err)
+	}
+	return bot.send(ctx, r.ChatID, text, opts)
+}
+
+// formatReminderText renders a reminder due at now as an HTML message. The episode
+// summary, if any, is hidden behind a spoiler.
+func formatReminderText(r DBReminder, now time.Time) string {
+	text := fmt.Sprintf(
+		"Episode #%d \"%s\" of \"%s\" (season %d) is coming out today!",
+		r.EpisodeNumber, html.EscapeString(r.EpisodeTitle), html.EscapeString(r.ShowName), r.EpisodeSeason,
+	)
+	if n := len(r.Batch); n > 0 {
+		last := r.Batch[n-1]
+		if last.Season == r.EpisodeSeason {
+			text = fmt.Sprintf(
+				"Episodes %d–%d of \"%s\" (season %d) are coming out today!",
+				r.EpisodeNumber, last.Number, html.EscapeString(r.ShowName), r.EpisodeSeason,
+			)
+		} else {
+			text = fmt.Sprintf(
+				"Episodes S%02dE%02d–S%02dE%02d of \"%s\" are coming out today!",
+				r.EpisodeSeason, r.EpisodeNumber, last.Season, last.Number, html.EscapeString(r.ShowName),
+			)
+		}
+	}
+	if len(r.Batch) == 0 {
+		text = seasonMilestone(r) + reminderHeadline(r, text)
+	} else if r.SeasonPack {
+		text = formatSeasonPack(r, now)
+	}
+	if r.ReminderMode == ReminderModeSeason {
+		text = fmt.Sprintf(
+			"Season %d of \"%s\" is complete: the finale \"%s\" is coming out today. Time to binge!",
+			r.EpisodeSeason, html.EscapeString(r.ShowName), html.EscapeString(r.EpisodeTitle),
+		)
+	}
+	if r.ReminderMode == ReminderModeWatchlist {
+		text = fmt.Sprintf(
+			"Season %d of \"%s\" premieres today with \"%s\"!",
+			r.EpisodeSeason, html.EscapeString(r.ShowName), html.EscapeString(r.EpisodeTitle),
+		)
+	}
+	if r.OneOff {
+		text = fmt.Sprintf("🎟 \"%s\" is on today!", html.EscapeString(r.ShowName))
+	}
+	if r.EpisodeRuntime > 0 && len(r.Batch) == 0 {
+		text += fmt.Sprintf("\n⏱ %s", formatCountdown(time.Duration(r.EpisodeRuntime)*time.Minute))
+	}
+	if r.StreamingOn != "" {
+		text += fmt.Sprintf("\nStreaming on %s.", html.EscapeString(r.StreamingOn))
+	}
+	if r.Note != "" {
+		text += fmt.Sprintf("\n📝 %s", html.EscapeString(r.Note))
+	}
+	// One episode's summary would be misleading for a batch
+	if r.EpisodeSummary != "" && len(r.Batch) == 0 {
+		text += "\n\n" + spoiler(trimString(r.EpisodeSummary, 700))
+	}
+	return text
+}
+
+// seasonMilestone heads the reminder about a season premiere or finale, and is
+// empty for other episodes. The finale is the season's last known episode.
+func seasonMilestone(r DBReminder) string {
+	if r.EpisodeSeason == 0 {
+		return ""
+	}
+	premiere := r.EpisodeNumber == 1
+	finale := r.SeasonEpisodes > 0 && r.EpisodeNumber == r.SeasonEpisodes
+	switch {
+	case premiere && finale:
+		return fmt.Sprintf("🎬 Season %d premiere and finale! ", r.EpisodeSeason)
+	case premiere:
+		return fmt.Sprintf("🎬 Season %d premiere! ", r.EpisodeSeason)
+	case finale:
+		return fmt.Sprintf("🏁 Season %d finale! ", r.EpisodeSeason)
+	}
+	return ""
+}
+
+func handleReminderSendError(ctx context.Context, db *store.DB, r DBReminder, sendErr error, now time.Time) {
+	if isChatUnreachable(sendErr) {
+		log.Printf("reminderLoop: chat %d is unreachable, disabling its notifications: %v", r.ChatID, sendErr)
+		if err := disableChatNotifications(ctx, db, r.ChatID, sendErr.Error()); err != nil {
+			log.Printf("reminderLoop: failed to disable notifications for chat %d: %v", r.ChatID, err)
+		}
+		// A private chat rejecting us means the user blocked the bot or is gone
+		if r.ChatID == r.UserID {
+			if err := markUserInactive(ctx, db, r.UserID, r.ChatID, now); err != nil {
+				log.Printf("reminderLoop: failed to mark user %d inactive: %v", r.UserID, err)
 			}
-		case <-ctx.Done():
-			log.Println("reminderLoop: context cancelled, exiting")
-			return
 		}
+		return
+	}
+
+	attempts := r.Attempts + 1
+	delay := max(reminderBackoff(attempts), retryAfter(sendErr))
+	dead := attempts >= maxReminderAttempts
+	if dead {
+		log.Printf("reminderLoop: reminder %d failed %d times, giving up: %v", r.ID, attempts, sendErr)
+	} else {
+		log.Printf("reminderLoop: reminder %d failed (attempt %d), retrying in %s: %v", r.ID, attempts, delay, sendErr)
+	}
+	err := markReminderAttemptFailed(ctx, db, r.ID, attempts, now.Add(delay), sendErr.Error(), dead)
+	if err != nil {
+		log.Printf("reminderLoop: failed to record reminder %d failure: %v", r.ID, err)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,451 @@
+package bot
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"tvreminder/bot/internal/store"
+)
+
+// dueReminderEnv tracks "Night Shift" so a reminder exists for its next episode
+// and makes that reminder due now.
+func dueReminderEnv(t *testing.T) *testEnv {
+	t.Helper()
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	_, err := env.handler.DB.Exec(`UPDATE reminders SET remind_at = ?`, time.Now().UTC().Add(-time.Minute).Format(time.RFC3339))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+func countSent(env *testEnv) int {
+	n := 0
+	for _, req := range env.telegram.messages() {
+		if req.Method == "sendMessage" && strings.Contains(req.Params.Get("text"), "is coming out today") {
+			n++
+		}
+	}
+	return n
+}
+
+func queryString(t *testing.T, env *testEnv, query string) string {
+	t.Helper()
+	var value any
+	if err := env.handler.DB.QueryRow(query).Scan(&value); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	if value == nil {
+		return "NULL"
+	}
+	return fmt.Sprint(value)
+}
+
+func TestSendDueReminders(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(env *testEnv)
+		wantSent int
+		// want maps a query to its expected single value after the tick
+		want map[string]string
+	}{
+		{
+			name:     "sends and clears the reminder",
+			wantSent: 1,
+			want: map[string]string{
+				`SELECT COUNT(*) FROM reminders WHERE status = 'pending'`: "0",
+			},
+		},
+		{
+			name: "transient error schedules a retry",
+			setup: func(env *testEnv) {
+				env.telegram.failChat(testUserID, 500, "Internal Server Error")
+			},
+			want: map[string]string{
+				`SELECT attempts FROM reminders`:                                   "1",
+				`SELECT status FROM reminders`:                                     ReminderStatusPending,
+				`SELECT next_attempt_at IS NOT NULL FROM reminders`:                "1",
+				`SELECT notifications_enabled FROM shows`:                          "1",
+				`SELECT COUNT(*) FROM user_settings WHERE inactive_since NOT NULL`: "0",
+			},
+		},
+		{
+			name: "blocked bot dead-letters and marks the user inactive",
+			setup: func(env *testEnv) {
+				env.telegram.failChat(testUserID, 403, "Forbidden: bot was blocked by the user")
+			},
+			want: map[string]string{
+				`SELECT status FROM reminders`:                                     ReminderStatusFailed,
+				`SELECT notifications_enabled FROM shows`:                          "0",
+				`SELECT notifications_auto_disabled FROM shows`:                    "1",
+				`SELECT COUNT(*) FROM user_settings WHERE inactive_since NOT NULL`: "1",
+			},
+		},
+		{
+			name: "muted show is skipped",
+			setup: func(env *testEnv) {
+				env.handler.DB.Exec(`UPDATE shows SET notifications_enabled = 0`)
+			},
+			want: map[string]string{
+				`SELECT status FROM reminders`: ReminderStatusPending,
+			},
+		},
+		{
+			name: "quiet hours hold the reminder back",
+			setup: func(env *testEnv) {
+				now := time.Now().UTC()
+				window := fmt.Sprintf("%s-%s", now.Add(-time.Hour).Format("15:04"), now.Add(time.Hour).Format("15:04"))
+				if err := setQuietHours(t.Context(), env.handler.DB, testUserID, testUserID, window); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string]string{
+				`SELECT attempts FROM reminders`: "0",
+				`SELECT status FROM reminders`:   ReminderStatusPending,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := dueReminderEnv(t)
+			if tt.setup != nil {
+				tt.setup(env)
+			}
+
+			sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+
+			if got := countSent(env); got != tt.wantSent {
+				t.Errorf("sent %d reminders, want %d", got, tt.wantSent)
+			}
+			for query, want := range tt.want {
+				if got := queryString(t, env, query); got != want {
+					t.Errorf("%s = %s, want %s", query, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReactivatedUserGetsNoStaleReminders(t *testing.T) {
+	env := dueReminderEnv(t)
+	env.telegram.failChat(testUserID, 403, "Forbidden: bot was blocked by the user")
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+
+	env.telegram.unfailChat(testUserID)
+	env.command("/reminders")
+	if !slices.ContainsFunc(env.telegram.messages(), func(req sentRequest) bool {
+		return strings.HasPrefix(req.Params.Get("text"), "Welcome back!")
+	}) {
+		t.Error("no welcome back message")
+	}
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if got := countSent(env); got != 0 {
+		t.Errorf("sent %d reminders for episodes aired while the bot was blocked, want 0", got)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM reminders WHERE status = 'failed'`); got != "0" {
+		t.Errorf("%s failed reminders left after coming back", got)
+	}
+	if got := queryString(t, env, `SELECT notifications_enabled FROM shows`); got != "1" {
+		t.Errorf("notifications_enabled = %s after coming back, want 1", got)
+	}
+}
+
+func TestSendDueRemindersOnlyOnce(t *testing.T) {
+	env := dueReminderEnv(t)
+
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+
+	if got := countSent(env); got != 1 {
+		t.Errorf("sent %d reminders over two ticks, want 1", got)
+	}
+}
+
+func TestReminderBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{7, time.Hour},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := reminderBackoff(tt.attempt); got != tt.want {
+			t.Errorf("reminderBackoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestReminderArtwork(t *testing.T) {
+	tests := []struct {
+		name      string
+		image     string
+		failPhoto bool
+		wantPhoto bool
+		wantText  bool
+	}{
+		{name: "episode still as photo", image: "https://img.example/still.jpg", wantPhoto: true},
+		{name: "no artwork", wantText: true},
+		{name: "rejected photo falls back to text", image: "https://img.example/gone.jpg", failPhoto: true, wantText: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := dueReminderEnv(t)
+			if _, err := env.handler.DB.Exec(`UPDATE episodes_cache SET image_url = ?`, tt.image); err != nil {
+				t.Fatal(err)
+			}
+			if tt.failPhoto {
+				env.telegram.failMethod("sendPhoto", 400, "Bad Request: wrong file identifier/HTTP URL specified")
+			}
+
+			sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+
+			photos := env.telegram.calls("sendPhoto")
+			if got := len(photos) == 1; got != tt.wantPhoto {
+				t.Errorf("sent %d photos, want photo: %v", len(photos), tt.wantPhoto)
+			}
+			if tt.wantPhoto && photos[0].Params.Get("photo") != tt.image {
+				t.Errorf("photo = %q, want %q", photos[0].Params.Get("photo"), tt.image)
+			}
+			if got := countSent(env) == 1; got != tt.wantText {
+				t.Errorf("sent %d text reminders, want text: %v", countSent(env), tt.wantText)
+			}
+			if got := queryString(t, env, `SELECT COUNT(*) FROM reminders WHERE status = 'pending'`); got != "0" {
+				t.Errorf("pending reminders = %s, want 0", got)
+			}
+		})
+	}
+}
+
+func TestReminderCaption(t *testing.T) {
+	shows := testShows()
+	for i := range shows[0].Episodes {
+		shows[0].Episodes[i].Runtime = 52
+		shows[0].Episodes[i].Image = &Image{Original: "https://img.example/still.jpg"}
+	}
+	env := newTestEnv(t, shows...)
+	trackShow(t, env, "2")
+	env.handler.DB.Exec(`UPDATE reminders SET remind_at = ?`, time.Now().UTC().Add(-time.Minute).Format(time.RFC3339))
+
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+
+	photos := env.telegram.calls("sendPhoto")
+	if len(photos) != 1 {
+		t.Fatalf("sent %d photos, want 1", len(photos))
+	}
+	caption := photos[0].Params.Get("caption")
+	for _, want := range []string{
+		`Episode #3 "Episode 2.3" of "Night Shift" (season 2)`,
+		"⏱ 52m",
+		"<tg-spoiler>Things happen in 2.3</tg-spoiler>",
+	} {
+		if !strings.Contains(caption, want) {
+			t.Errorf("caption %q doesn't contain %q", caption, want)
+		}
+	}
+}
+
+func TestReminderIncludesNote(t *testing.T) {
+	env := dueReminderEnv(t)
+	if _, err := env.handler.DB.Exec(`UPDATE shows SET note = 'drops on Fridays'`); err != nil {
+		t.Fatal(err)
+	}
+
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "📝 drops on Fridays") {
+		t.Errorf("reminder = %q, want the note", got)
+	}
+}
+
+func TestProgressEditedBackwardsDropsStaleReminder(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	if got := queryString(t, env, `SELECT e.number FROM reminders r JOIN episodes_cache e ON e.id = r.episode_id`); got != "3" {
+		t.Fatalf("reminder for episode %s, want 3", got)
+	}
+
+	// Going back to S01E01 leaves aired episodes to watch, so nothing to remind about
+	env.press("setProgress:" + queryString(t, env, `SELECT id FROM shows`))
+	env.press("selectSeason:1")
+	env.press("selectEpisode:1")
+	if got := queryString(t, env, `SELECT COUNT(*) FROM reminders`); got != "0" {
+		t.Errorf("%s reminders left after moving progress back, want 0", got)
+	}
+
+	// Moving forward again brings it back
+	env.text("watched night shift s2e2")
+	if got := queryString(t, env, `SELECT e.number FROM reminders r JOIN episodes_cache e ON e.id = r.episode_id`); got != "3" {
+		t.Errorf("reminder for episode %s, want 3", got)
+	}
+}
+
+func TestReminderBatchesEpisodesAiringTogether(t *testing.T) {
+	now := time.Now()
+	drop := now.AddDate(0, 0, 2).UTC()
+	binge := fakeShow{ShowSearchResult: ShowSearchResult{ID: 5, Name: "Binge"}}
+	season2 := makeEpisodes(5, 2, 8, drop)
+	for i := range season2 {
+		season2[i].Airdate = drop.Format("2006-01-02")
+		season2[i].Airtime = drop.Format("15:04")
+		season2[i].Airstamp = drop.Format(time.RFC3339)
+	}
+	// The finale comes weeks later, so this isn't a season pack, see seasonpack.go
+	season2 = append(season2, makeEpisodes(5, 2, 9, drop)[8])
+	binge.Episodes = append(makeEpisodes(5, 1, 2, now.AddDate(0, -1, 0)), season2...)
+	binge.Episodes = append(binge.Episodes, makeEpisodes(5, 3, 1, drop.AddDate(0, 0, 30))...)
+
+	env := newTestEnv(t, binge)
+	env.command("/add binge")
+	env.press("acceptShowName:1")
+	env.press("selectSeason:1")
+	env.press("selectEpisode:2")
+	if _, err := env.handler.DB.Exec(`UPDATE reminders SET remind_at = ?`, now.UTC().Add(-time.Minute).Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+
+	var sent []string
+	for _, req := range env.telegram.messages() {
+		if text := req.Params.Get("text"); strings.Contains(text, "coming out today") {
+			sent = append(sent, text)
+		}
+	}
+	if len(sent) != 1 || !strings.HasPrefix(sent[0], `Episodes 1–8 of "Binge" (season 2) are coming out today!`) {
+		t.Errorf("sent %q, want one reminder for episodes 1-8", sent)
+	}
+	if got := queryString(t, env, `SELECT e.season || 'x' || e.number FROM reminders r JOIN episodes_cache e ON e.id = r.episode_id WHERE r.sent_at IS NULL`); got != "2x9" {
+		t.Errorf("next reminder is for episode %s, want 2x9", got)
+	}
+}
+
+func TestReminderAutoAdvance(t *testing.T) {
+	lastWatched := `SELECT e.number FROM shows s JOIN episodes_cache e ON e.id = s.last_watched_episode_id`
+	for _, tt := range []struct {
+		name    string
+		command string
+		want    string
+	}{
+		{"off by default", "", "2"},
+		{"on", "/autoadvance on", "3"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			env := dueReminderEnv(t)
+			if tt.command != "" {
+				env.command(tt.command)
+			}
+			sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+			if got := queryString(t, env, lastWatched); got != tt.want {
+				t.Errorf("last watched episode = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReminderNotSentTwice(t *testing.T) {
+	env := dueReminderEnv(t)
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if countSent(env) != 1 {
+		t.Fatalf("sent %d reminders, want 1", countSent(env))
+	}
+
+	// Scheduling the episode again, like after setting progress back, is a no-op
+	var showID int
+	var episodeID int64
+	err := env.handler.DB.QueryRow(`SELECT show_id, episode_id FROM reminders WHERE sent_at IS NOT NULL`).Scan(&showID, &episodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().UTC().Add(-time.Minute)
+	if err := createReminder(t.Context(), env.handler.DB, testUserID, showID, episodeID, past, testUserID, 0); err != nil {
+		t.Fatal(err)
+	}
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if countSent(env) != 1 {
+		t.Errorf("sent %d reminders, want the episode reminded about once", countSent(env))
+	}
+}
+
+func TestSeasonMilestone(t *testing.T) {
+	tests := []struct {
+		season, number, seasonEpisodes int
+		want                           string
+	}{
+		{3, 1, 10, "🎬 Season 3 premiere! "},
+		{3, 10, 10, "🏁 Season 3 finale! "},
+		{3, 1, 1, "🎬 Season 3 premiere and finale! "},
+		{3, 5, 10, ""},
+		{0, 1, 4, ""},
+	}
+	for _, tt := range tests {
+		r := DBReminder{DueReminder: store.DueReminder{EpisodeSeason: tt.season, EpisodeNumber: tt.number, SeasonEpisodes: tt.seasonEpisodes}}
+		if got := seasonMilestone(r); got != tt.want {
+			t.Errorf("seasonMilestone(S%02dE%02d of %d) = %q, want %q", tt.season, tt.number, tt.seasonEpisodes, got, tt.want)
+		}
+	}
+}
+
+func TestFinaleReminder(t *testing.T) {
+	env := dueReminderEnv(t)
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	// S02E03 is the last known episode of season 2
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, `🏁 Season 2 finale! Episode #3 "Episode 2.3"`) {
+		t.Errorf("reminder = %q, want the finale template", got)
+	}
+}
+
+func TestReminderTimesStoredInUTC(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	db := env.handler.DB
+	reminderID, _ := strconv.ParseInt(queryString(t, env, `SELECT id FROM reminders`), 10, 64)
+
+	// Later today in New York, which used to compare as due with any time that day
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	remindAt := time.Now().Add(time.Hour).In(loc)
+	if err := rescheduleReminder(t.Context(), db, reminderID, remindAt); err != nil {
+		t.Fatal(err)
+	}
+	// Concatenating reads the stored text instead of the time the driver parses
+	if got, want := queryString(t, env, `SELECT remind_at || '' FROM reminders`), remindAt.UTC().Format(time.RFC3339); got != want {
+		t.Errorf("remind_at = %s, want %s", got, want)
+	}
+	if due, err := getDueReminders(t.Context(), db, env.handler.Clock.Now()); err != nil || len(due) != 0 {
+		t.Errorf("getDueReminders = %d reminders, %v, want none due yet", len(due), err)
+	}
+
+	// Times written before are moved to UTC by their migration
+	var migration string
+	for _, m := range store.Migrations {
+		if strings.HasPrefix(m, "UPDATE reminders SET remind_at = strftime") {
+			migration = m
+		}
+	}
+	for stored, want := range map[string]string{
+		"2024-03-10 21:00:00 -0400 EDT":           "2024-03-11T01:00:00Z",
+		"2024-03-09 21:00:00.123456789 -0500 EST": "2024-03-10T02:00:00Z",
+		"2024-03-11T01:00:00Z":                    "2024-03-11T01:00:00Z",
+	} {
+		db.Exec(`UPDATE reminders SET remind_at = ?`, stored)
+		if _, err := db.Exec(migration); err != nil {
+			t.Fatal(err)
+		}
+		if got := queryString(t, env, `SELECT remind_at || '' FROM reminders`); got != want {
+			t.Errorf("%q migrated to %s, want %s", stored, got, want)
+		}
+	}
+}
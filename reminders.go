@@ -0,0 +1,309 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// maxListedReminders keeps /reminders and its keyboard within one message.
+const maxListedReminders = 20
+
+// reminderDelays are the reschedule options, as button label and delay.
+var reminderDelays = []struct {
+	Label string
+	Delay time.Duration
+}{
+	{"+1 hour", time.Hour},
+	{"+3 hours", 3 * time.Hour},
+	{"+1 day", 24 * time.Hour},
+	{"+1 week", 7 * 24 * time.Hour},
+}
+
+const pendingReminderColumns = `
+	r.id, r.user_id, r.show_id, r.episode_id, r.remind_at, r.chat_id,
+	s.name, e.title, e.number, e.season, s.reminder_mode
+`
+
+func scanPendingReminder(row rowScanner) (*DBReminder, error) {
+	var reminder DBReminder
+	err := row.Scan(
+		&reminder.ID, &reminder.UserID, &reminder.ShowID, &reminder.EpisodeID, &reminder.RemindAt,
+		&reminder.ChatID, &reminder.ShowName, &reminder.EpisodeTitle, &reminder.EpisodeNumber,
+		&reminder.EpisodeSeason, &reminder.ReminderMode,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+// listPendingReminders returns the user's reminders that are still to fire, soonest
+// first. Reminders of shows with notifications off are left out.
+func listPendingReminders(ctx context.Context, db *store.DB, userID int64) ([]DBReminder, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+pendingReminderColumns+`
+		FROM reminders r
+		JOIN shows s ON s.id = r.show_id
+		JOIN episodes_cache e ON e.id = r.episode_id
+		WHERE r.user_id = ? AND r.status = 'pending' AND r.deleted_at IS NULL AND s.notifications_enabled = 1
+		ORDER BY r.remind_at, s.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []DBReminder
+	for rows.Next() {
+		reminder, err := scanPendingReminder(rows)
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, *reminder)
+	}
+	return reminders, rows.Err()
+}
+
+// getUserReminder returns a pending reminder of the user, sql.ErrNoRows when it
+// doesn't exist or belongs to someone else.
+func getUserReminder(ctx context.Context, db *store.DB, userID, reminderID int64) (*DBReminder, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	return scanPendingReminder(db.QueryRowContext(ctx, `
+		SELECT `+pendingReminderColumns+`
+		FROM reminders r
+		JOIN shows s ON s.id = r.show_id
+		JOIN episodes_cache e ON e.id = r.episode_id
+		WHERE r.id = ? AND r.user_id = ? AND r.status = 'pending' AND r.deleted_at IS NULL
+	`, reminderID, userID))
+}
+
+// reminderSubject describes what a reminder is about, depending on the show's mode.
+func reminderSubject(reminder DBReminder) string {
+	switch reminder.ReminderMode {
+	case ReminderModeSeason:
+		return fmt.Sprintf("%s, season %d finale", reminder.ShowName, reminder.EpisodeSeason)
+	case ReminderModeWatchlist:
+		return fmt.Sprintf("%s, season %d premiere", reminder.ShowName, reminder.EpisodeSeason)
+	default:
+		return fmt.Sprintf("%s S%02dE%02d", reminder.ShowName, reminder.EpisodeSeason, reminder.EpisodeNumber)
+	}
+}
+
+func formatReminders(reminders []DBReminder, loc *time.Location) string {
+	var b strings.Builder
+	b.WriteString("<b>Your reminders</b>\n\n")
+	for i, reminder := range reminders {
+		if i == maxListedReminders {
+			fmt.Fprintf(&b, "…and %d more\n", len(reminders)-maxListedReminders)
+			break
+		}
+		fmt.Fprintf(
+			&b, "%d. %s - %s\n",
+			i+1, reminder.RemindAt.In(loc).Format("Mon Jan 2, 15:04"), html.EscapeString(reminderSubject(reminder)),
+		)
+	}
+	return b.String()
+}
+
+func makeRemindersKeyboard(reminders []DBReminder, undelivered []UndeliveredReminder) *tgbotapi.InlineKeyboardMarkup {
+	rows := makeUndeliveredKeyboardRows(undelivered)
+	for i, reminder := range reminders[:min(maxListedReminders, len(reminders))] {
+		rows = append(rows, [][]string{
+			{fmt.Sprintf("%d. 🚫 Cancel", i+1), fmt.Sprintf("cancelReminder:%d", reminder.ID)},
+			{fmt.Sprintf("%d. ⏰ Reschedule", i+1), fmt.Sprintf("rescheduleReminder:%d", reminder.ID)},
+		})
+	}
+	return makeKeyboardMarkup(rows)
+}
+
+// showReminders sends the user's reminder list, or edits messageID into it when set.
+func (handler *Handler) showReminders(ctx context.Context, userID, chatID int64, messageID int) error {
+	reminders, err := listPendingReminders(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing reminders for user %d: %w", userID, err),
+			"Error: can't list reminders at this time",
+		)
+	}
+	undelivered, err := listUndeliveredReminders(ctx, handler.DB, userID, false)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing undelivered reminders for user %d: %w", userID, err),
+			"Error: can't list reminders at this time",
+		)
+	}
+	if len(reminders) == 0 && len(undelivered) == 0 {
+		handler.Bot.reply(ctx,
+			chatID, "You have no upcoming reminders. Use /add to track a show.",
+			ReplyOptions{EditMessageID: messageID},
+		)
+		return nil
+	}
+	settings, err := getUserSettings(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting settings for user %d: %w", userID, err),
+			"Error: can't list reminders at this time",
+		)
+	}
+
+	var text string
+	if len(undelivered) > 0 {
+		text = formatUndelivered(undelivered, settings.Location()) + "\n"
+	}
+	if len(reminders) > 0 {
+		text += formatReminders(reminders, settings.Location())
+	}
+	handler.Bot.reply(ctx, chatID, text, ReplyOptions{
+		EditMessageID: messageID,
+		ParseMode:     "HTML",
+		ReplyMarkup:   makeRemindersKeyboard(reminders, undelivered),
+	})
+	return nil
+}
+
+// findUserReminder looks up the reminder a button refers to, turning a missing one
+// into a message for the user.
+func (handler *Handler) findUserReminder(ctx context.Context, userID, reminderID int64) (*DBReminder, error) {
+	reminder, err := getUserReminder(ctx, handler.DB, userID, reminderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, NewUserError(
+			fmt.Errorf("reminder %d of user %d not found", reminderID, userID),
+			"This reminder has already been sent or changed. See /reminders.",
+		)
+	}
+	if err != nil {
+		return nil, NewUserError(
+			fmt.Errorf("getting reminder %d: %w", reminderID, err),
+			"Error: can't find this reminder",
+		)
+	}
+	return reminder, nil
+}
+
+// REMINDERS command
+
+func (handler *Handler) handleRemindersCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	return handler.showReminders(ctx, msg.From.ID, msg.Chat.ID, 0)
+}
+
+// handleCancelReminderCallback drops one reminder. Like a sent one, it moves on to
+// the show's next episode, so later episodes are still reminded about.
+func (handler *Handler) handleCancelReminderCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	reminderID, err := strconv.ParseInt(callbackParam, 10, 64)
+	if err != nil {
+		log.Printf("handleCancelReminderCallback: invalid reminder id: %s", callbackParam)
+		return nil
+	}
+
+	userID := cb.From.ID
+	msg := cb.Message
+
+	reminder, err := handler.findUserReminder(ctx, userID, reminderID)
+	if err != nil {
+		return err
+	}
+	if err := markReminderSent(ctx, handler.DB, *reminder, handler.Clock.Now()); err != nil {
+		return NewUserError(
+			fmt.Errorf("cancelling reminder %d: %w", reminder.ID, err),
+			"Error cancelling the reminder",
+		)
+	}
+
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return handler.showReminders(ctx, userID, msg.Chat.ID, msg.MessageID)
+}
+
+// delayReminder returns from moved by hours. Whole days keep the time of day in
+// loc, so a reminder put off by a day across a daylight saving change still comes
+// at the hour it was due.
+func delayReminder(from time.Time, hours int, loc *time.Location) time.Time {
+	if hours%24 == 0 {
+		return from.In(loc).AddDate(0, 0, hours/24)
+	}
+	return from.Add(time.Duration(hours) * time.Hour)
+}
+
+// handleRescheduleReminderCallback offers the delays for a reminder, or moves it when
+// the callback carries one: "reminderID" or "reminderID:hours".
+func (handler *Handler) handleRescheduleReminderCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	reminderIDStr, hoursStr, found := strings.Cut(callbackParam, ":")
+	reminderID, err := strconv.ParseInt(reminderIDStr, 10, 64)
+	if err != nil {
+		log.Printf("handleRescheduleReminderCallback: invalid reminder id: %s", reminderIDStr)
+		return nil
+	}
+
+	userID := cb.From.ID
+	msg := cb.Message
+
+	reminder, err := handler.findUserReminder(ctx, userID, reminderID)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		var buttons [][]string
+		for _, delay := range reminderDelays {
+			buttons = append(buttons, []string{
+				delay.Label, fmt.Sprintf("rescheduleReminder:%d:%d", reminder.ID, int(delay.Delay.Hours())),
+			})
+		}
+		keyboard := makeKeyboardMarkup([][][]string{buttons, {{"↩️ Back", "reminders"}}})
+		handler.Bot.reply(ctx,
+			msg.Chat.ID,
+			fmt.Sprintf("How much later should I remind you about %s?", reminderSubject(*reminder)),
+			ReplyOptions{EditMessageID: msg.MessageID, ReplyMarkup: keyboard},
+		)
+		handler.Bot.answerCallbackQuery(cb.ID)
+		return nil
+	}
+
+	hours, err := strconv.Atoi(hoursStr)
+	if err != nil || hours <= 0 {
+		log.Printf("handleRescheduleReminderCallback: invalid delay: %s", hoursStr)
+		return nil
+	}
+	settings, err := getUserSettings(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting settings for user %d: %w", userID, err),
+			"Error rescheduling the reminder",
+		)
+	}
+	// Reminders held back by quiet hours may already be due; delay from now then
+	from := reminder.RemindAt
+	if now := handler.Clock.Now(); from.Before(now) {
+		from = now
+	}
+	if err := rescheduleReminder(ctx, handler.DB, reminder.ID, delayReminder(from, hours, settings.Location())); err != nil {
+		return NewUserError(
+			fmt.Errorf("rescheduling reminder %d: %w", reminder.ID, err),
+			"Error rescheduling the reminder",
+		)
+	}
+
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return handler.showReminders(ctx, userID, msg.Chat.ID, msg.MessageID)
+}
+
+func (handler *Handler) handleRemindersCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) error {
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return handler.showReminders(ctx, cb.From.ID, cb.Message.Chat.ID, cb.Message.MessageID)
+}
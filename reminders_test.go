@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemindersCommand(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.command("/reminders")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "You have no upcoming reminders. Use /add to track a show." {
+		t.Errorf("empty /reminders = %q", got)
+	}
+
+	trackShow(t, env, "2")
+	env.command("/reminders")
+	last := env.telegram.lastMessage(t)
+	if got := last.Params.Get("text"); !strings.Contains(got, "1. ") || !strings.HasSuffix(got, " - Night Shift S02E03\n") {
+		t.Errorf("/reminders = %q", got)
+	}
+	reminderID := queryString(t, env, `SELECT id FROM reminders`)
+	want := []string{"cancelReminder:" + reminderID, "rescheduleReminder:" + reminderID}
+	if got := last.keyboard(t); !slices.Equal(got, want) {
+		t.Errorf("keyboard = %v, want %v", got, want)
+	}
+}
+
+func TestRescheduleReminder(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	reminderID := queryString(t, env, `SELECT id FROM reminders`)
+	var before time.Time
+	env.handler.DB.QueryRow(`SELECT remind_at FROM reminders`).Scan(&before)
+
+	env.press("rescheduleReminder:" + reminderID)
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "How much later should I remind you about Night Shift S02E03?" {
+		t.Errorf("reschedule prompt = %q", got)
+	}
+	env.press("rescheduleReminder:" + reminderID + ":24")
+
+	var after time.Time
+	env.handler.DB.QueryRow(`SELECT remind_at FROM reminders`).Scan(&after)
+	if got := after.Sub(before); got != 24*time.Hour {
+		t.Errorf("reminder moved by %v, want 24h", got)
+	}
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "<b>Your reminders</b>") {
+		t.Errorf("after rescheduling = %q, want the list", got)
+	}
+}
+
+func TestCancelReminder(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	reminderID := queryString(t, env, `SELECT id FROM reminders`)
+
+	// Someone else's reminder can't be touched
+	env.handler.DB.Exec(`UPDATE reminders SET user_id = 2002`)
+	env.press("cancelReminder:" + reminderID)
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "This reminder has already been sent or changed. See /reminders." {
+		t.Errorf("cancelling another user's reminder = %q", got)
+	}
+	env.handler.DB.Exec(`UPDATE reminders SET user_id = 1001`)
+
+	// S02E03 is the last known episode, so nothing is left after it
+	env.press("cancelReminder:" + reminderID)
+	if got := queryString(t, env, `SELECT COUNT(*) FROM reminders WHERE sent_at IS NULL`); got != "0" {
+		t.Errorf("%s reminders left, want 0", got)
+	}
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "You have no upcoming reminders. Use /add to track a show." {
+		t.Errorf("after cancelling = %q", got)
+	}
+}
+
+func TestDelayReminderAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	// Clocks go forward on the night of March 10, 2024
+	from := time.Date(2024, time.March, 9, 21, 0, 0, 0, loc)
+	tests := []struct {
+		hours int
+		want  time.Time
+	}{
+		{3, time.Date(2024, time.March, 10, 0, 0, 0, 0, loc)},
+		{24, time.Date(2024, time.March, 10, 21, 0, 0, 0, loc)},
+		{7 * 24, time.Date(2024, time.March, 16, 21, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		if got := delayReminder(from, tt.hours, loc); !got.Equal(tt.want) {
+			t.Errorf("delayReminder(+%dh) = %v, want %v", tt.hours, got, tt.want)
+		}
+	}
+	if got := delayReminder(from, 24, loc).Sub(from); got != 23*time.Hour {
+		t.Errorf("a day across the change lasted %v, want 23h", got)
+	}
+}
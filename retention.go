@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/store"
+)
+
+// inactiveUserTables are the tables holding a user's rows by user_id, shows last,
+// and inactiveUserShowTables those holding rows of the user's shows by show_id.
+// A table added for a user or show feature belongs in one of them.
+var (
+	inactiveUserTables = []string{
+		"reminders", "group_members", "season_ratings", "movies", "webhook_deliveries", "jobs", "trakt_pushes",
+		"followups", "incidents", "reminder_messages", "watch_log", "user_chats", "abuse_log", "delivery_log",
+		"tags", "shows",
+	}
+	inactiveUserShowTables = []string{
+		"reminder_targets", "skipped_episodes", "premiere_hypes", "show_tags", "reminder_offsets", "offset_reminders",
+	}
+)
+
+// purgeInactiveUsers deletes all data of users that have been inactive (blocked the
+// bot or deleted their account) since before cutoff. It returns the number of users purged.
+func purgeInactiveUsers(ctx context.Context, db *store.DB, cutoff time.Time) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	inactive := `SELECT user_id FROM user_settings WHERE inactive_since <= ?`
+	cutoffStr := cutoff.UTC().Format(time.RFC3339)
+
+	// Rows hanging off the user's shows go first, then the user's own rows and shows
+	for _, table := range inactiveUserShowTables {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE show_id IN (SELECT id FROM shows WHERE user_id IN (`+inactive+`))`, cutoffStr); err != nil {
+			return 0, fmt.Errorf("purging %s: %w", table, err)
+		}
+	}
+	for _, table := range inactiveUserTables {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id IN (`+inactive+`)`, cutoffStr); err != nil {
+			return 0, fmt.Errorf("purging %s: %w", table, err)
+		}
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM user_settings WHERE inactive_since <= ?`, cutoffStr)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, tx.Commit()
+}
+
+// retentionLoop periodically purges users that stayed inactive for longer than retention.
+func retentionLoop(db *store.DB, retention time.Duration, clk clock.Clock, ctx context.Context) {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			purged, err := purgeInactiveUsers(ctx, db, clk.Now().Add(-retention))
+			if err != nil {
+				log.Printf("retentionLoop: purgeInactiveUsers error: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("retentionLoop: purged data of %d inactive users", purged)
+			}
+		case <-ctx.Done():
+			log.Println("retentionLoop: context cancelled, exiting")
+			return
+		}
+	}
+}
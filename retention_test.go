@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPurgeInactiveUsers(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	for _, query := range []string{
+		`INSERT INTO show_tags (show_id, tag_id) SELECT id, 1 FROM shows WHERE user_id = ?`,
+		`INSERT INTO abuse_log (user_id, kind, created_at) VALUES (?, 'flood', '2026-01-01T00:00:00Z')`,
+		`INSERT INTO delivery_log (user_id, created_at) VALUES (?, '2026-01-01T00:00:00Z')`,
+	} {
+		if _, err := env.handler.DB.Exec(query, testUserID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := markUserInactive(t.Context(), env.handler.DB, testUserID, testUserID, env.handler.Clock.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Users only just gone inactive are kept
+	if purged, err := purgeInactiveUsers(t.Context(), env.handler.DB, time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+		t.Fatalf("purgeInactiveUsers before the cutoff = %d, %v, want 0", purged, err)
+	}
+	if purged, err := purgeInactiveUsers(t.Context(), env.handler.DB, time.Now().Add(time.Hour)); err != nil || purged != 1 {
+		t.Fatalf("purgeInactiveUsers = %d, %v, want 1", purged, err)
+	}
+	for _, table := range append(append([]string{"user_settings"}, inactiveUserTables...), inactiveUserShowTables...) {
+		if got := queryString(t, env, `SELECT COUNT(*) FROM `+table); got != "0" {
+			t.Errorf("%s rows left in %s", got, table)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"tvreminder/bot/internal/store"
+)
+
+// reminderMaxSleep is how long the reminder loop sleeps at most. nextReminderDue knows
+// every due time, so this only bounds the damage of one it misses.
+const reminderMaxSleep = time.Hour
+
+// nextReminderDue returns when the next reminder, retry, extra or movie reminder or
+// check-in after now is due, or when the quiet hours holding reminders back end.
+// It returns the zero time when there's none.
+func nextReminderDue(ctx context.Context, db *store.DB, now time.Time) (time.Time, error) {
+	var next time.Time
+	consider := func(due time.Time) {
+		if !due.IsZero() && (next.IsZero() || due.Before(next)) {
+			next = due
+		}
+	}
+
+	due, err := db.NextReminderDue(ctx, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	consider(due)
+	offsetDue, err := nextOffsetReminderDue(ctx, db, now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("extra reminders: %w", err)
+	}
+	consider(offsetDue)
+	movieDue, err := nextMovieReminderDue(ctx, db, now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("movie reminders: %w", err)
+	}
+	consider(movieDue)
+	quietEnd, err := nextQuietHoursEnd(ctx, db, now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("quiet hours: %w", err)
+	}
+	consider(quietEnd)
+	return next, nil
+}
+
+// nextQuietHoursEnd returns when the first quiet hours in effect at now end, since
+// reminders due during them go out then. It returns the zero time when no user is
+// in quiet hours.
+func nextQuietHoursEnd(ctx context.Context, db *store.DB, now time.Time) (time.Time, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	// Users share a handful of time zones and windows, each is looked at once
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT COALESCE(timezone, 'UTC'), quiet_hours FROM user_settings
+		WHERE quiet_hours IS NOT NULL AND quiet_hours != '' AND inactive_since IS NULL
+	`)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer rows.Close()
+
+	var next time.Time
+	for rows.Next() {
+		var settings UserSettings
+		if err := rows.Scan(&settings.Timezone, &settings.QuietHours); err != nil {
+			return time.Time{}, err
+		}
+		end := settings.quietHoursEnd(now)
+		if !end.IsZero() && (next.IsZero() || end.Before(next)) {
+			next = end
+		}
+	}
+	return next, rows.Err()
+}
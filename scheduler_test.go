@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextReminderDue(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	db := env.handler.DB
+	now := time.Now()
+
+	if due, err := nextReminderDue(t.Context(), db, now); err != nil || !due.IsZero() {
+		t.Fatalf("nextReminderDue with no reminders = %v, %v, want the zero time", due, err)
+	}
+
+	trackShow(t, env, "2")
+	remindAt := now.Add(2 * time.Hour).UTC().Truncate(time.Second)
+	if _, err := db.Exec(`UPDATE reminders SET remind_at = ?`, remindAt.Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := nextReminderDue(t.Context(), db, now); err != nil || !due.Equal(remindAt) {
+		t.Errorf("nextReminderDue = %v, %v, want %v", due, err, remindAt)
+	}
+
+	// A failed reminder is due again at its retry
+	retryAt := now.Add(time.Hour).UTC().Truncate(time.Second)
+	if _, err := db.Exec(`UPDATE reminders SET next_attempt_at = ?`, retryAt.Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := nextReminderDue(t.Context(), db, now); err != nil || !due.Equal(retryAt) {
+		t.Errorf("nextReminderDue with a retry = %v, %v, want %v", due, err, retryAt)
+	}
+
+	// An extra reminder 90 minutes ahead of the reminder comes before the retry
+	if _, err := db.Exec(`INSERT INTO reminder_offsets (show_id, offset_minutes) SELECT id, -90 FROM shows`); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := nextReminderDue(t.Context(), db, now); err != nil || !due.Equal(remindAt.Add(-90*time.Minute)) {
+		t.Errorf("nextReminderDue with an extra reminder = %v, %v, want %v", due, err, remindAt.Add(-90*time.Minute))
+	}
+}
+
+func TestQuietHoursEnd(t *testing.T) {
+	settings := UserSettings{Timezone: "Europe/Berlin", QuietHours: "23:00-08:00"}
+	for at, want := range map[string]string{
+		"2026-03-01T23:30:00+01:00": "2026-03-02T08:00:00+01:00",
+		"2026-03-02T07:59:00+01:00": "2026-03-02T08:00:00+01:00",
+		"2026-03-02T08:00:00+01:00": "",
+		"2026-03-02T12:00:00+01:00": "",
+	} {
+		now, _ := time.Parse(time.RFC3339, at)
+		got := settings.quietHoursEnd(now)
+		if want == "" {
+			if !got.IsZero() {
+				t.Errorf("quietHoursEnd(%s) = %v, want the zero time outside quiet hours", at, got)
+			}
+			continue
+		}
+		if got.Format(time.RFC3339) != want {
+			t.Errorf("quietHoursEnd(%s) = %v, want %s", at, got, want)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// maxSuggestions is how many "Did you mean" buttons a failed search offers.
+const maxSuggestions = 3
+
+// popularShowsIndexSize bounds how many of the most tracked shows are compared
+// against a failed search.
+const popularShowsIndexSize = 500
+
+// showAbbreviations expands the short names people commonly use for shows.
+var showAbbreviations = map[string]string{
+	"got":   "Game of Thrones",
+	"hotd":  "House of the Dragon",
+	"himym": "How I Met Your Mother",
+	"twd":   "The Walking Dead",
+	"bb":    "Breaking Bad",
+	"bcs":   "Better Call Saul",
+	"tbbt":  "The Big Bang Theory",
+	"iasip": "It's Always Sunny in Philadelphia",
+	"b99":   "Brooklyn Nine-Nine",
+	"tlou":  "The Last of Us",
+	"ahs":   "American Horror Story",
+	"aot":   "Attack on Titan",
+	"snl":   "Saturday Night Live",
+	"st":    "Stranger Things",
+	"trop":  "The Rings of Power",
+	"dw":    "Doctor Who",
+	"tgp":   "The Good Place",
+}
+
+// fallbackQueries returns alternative spellings of query to retry a search with
+// when it found nothing: the expanded abbreviation and the query without
+// punctuation or a leading "the".
+func fallbackQueries(query string) []string {
+	normalized := normalizeShowName(query)
+	var candidates []string
+	if name, ok := showAbbreviations[strings.ReplaceAll(normalized, " ", "")]; ok {
+		candidates = append(candidates, name)
+	}
+	candidates = append(candidates, normalized)
+
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(query)): true}
+	var queries []string
+	for _, candidate := range candidates {
+		key := strings.ToLower(candidate)
+		if candidate == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		queries = append(queries, candidate)
+	}
+	return queries
+}
+
+// searchShowWithFallback searches for query and, when that finds nothing, for
+// each of its fallbackQueries. It returns the query that produced the results.
+func (handler *Handler) searchShowWithFallback(ctx context.Context, query string) ([]ShowSearchResult, string, error) {
+	results, err := handler.Provider.SearchShow(ctx, query)
+	if err != nil || len(results) > 0 {
+		return results, query, err
+	}
+	for _, fallback := range fallbackQueries(query) {
+		results, err := handler.Provider.SearchShow(ctx, fallback)
+		if err != nil {
+			return nil, query, err
+		}
+		if len(results) > 0 {
+			return results, fallback, nil
+		}
+	}
+	return nil, query, nil
+}
+
+// searchKnownShows finds shows by name among the ones users already track, for
+// searching while the provider is unavailable. Most tracked shows come first.
+func searchKnownShows(ctx context.Context, db *store.DB, provider, query string, limit int) ([]ShowSearchResult, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT provider_show_id, MIN(name), MAX(network), MAX(poster_url)
+		FROM shows
+		WHERE provider = ? AND deleted_at IS NULL AND name LIKE '%' || ? || '%'
+		GROUP BY provider_show_id
+		ORDER BY COUNT(DISTINCT user_id) DESC, MIN(name)
+		LIMIT ?
+	`, provider, strings.TrimSpace(query), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ShowSearchResult
+	for rows.Next() {
+		var show ShowSearchResult
+		var providerShowID, network, posterURL string
+		if err := rows.Scan(&providerShowID, &show.Name, &network, &posterURL); err != nil {
+			return nil, err
+		}
+		if show.ID, err = strconv.Atoi(providerShowID); err != nil {
+			continue
+		}
+		if network != "" {
+			show.Network = &Network{Name: network}
+		}
+		if posterURL != "" {
+			show.Image = &Image{Original: posterURL}
+		}
+		results = append(results, show)
+	}
+	return results, rows.Err()
+}
+
+// listPopularShowNames returns the names of the shows tracked by the most users.
+func listPopularShowNames(ctx context.Context, db *store.DB, limit int) ([]string, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT MIN(name)
+		FROM shows
+		WHERE deleted_at IS NULL
+		GROUP BY provider, provider_show_id
+		ORDER BY COUNT(DISTINCT user_id) DESC, MIN(name)
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// suggestShowNames picks up to maxSuggestions names close to query, closest
+// first. names is expected in order of popularity, which breaks ties.
+func suggestShowNames(query string, names []string) []string {
+	query = normalizeShowName(query)
+	if query == "" {
+		return nil
+	}
+	// Allow roughly one typo per four letters
+	maxDistance := max(1, len([]rune(query))/4)
+
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	var suggestions []suggestion
+	seen := map[string]bool{}
+	for _, name := range names {
+		normalized := normalizeShowName(name)
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		if distance := levenshtein(query, normalized); distance <= maxDistance {
+			suggestions = append(suggestions, suggestion{name, distance})
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].distance < suggestions[j].distance
+	})
+
+	var out []string
+	for _, s := range suggestions[:min(maxSuggestions, len(suggestions))] {
+		out = append(out, s.name)
+	}
+	return out
+}
+
+// offerSuggestions replies to a search that found nothing, offering similar show
+// names from the shows tracked on this bot and the known abbreviations.
+func (handler *Handler) offerSuggestions(ctx context.Context, userID, chatID int64, query string) {
+	names, err := listPopularShowNames(ctx, handler.DB, popularShowsIndexSize)
+	if err != nil {
+		log.Printf("offerSuggestions: listing popular shows: %v", err)
+	}
+	var known []string
+	for _, name := range showAbbreviations {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+	names = append(names, known...)
+
+	suggestions := suggestShowNames(query, names)
+	if len(suggestions) == 0 {
+		handler.Bot.reply(ctx, chatID, "No shows found for: "+query)
+		return
+	}
+
+	var rows [][][]string
+	for i, name := range suggestions {
+		rows = append(rows, [][]string{{trimString(name, 30), fmt.Sprintf("didYouMean:%d", i+1)}})
+	}
+	rows = append(rows, [][]string{{"❌ Cancel", "cancel"}})
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.Suggestions = suggestions
+	})
+	handler.Bot.reply(ctx,
+		chatID,
+		fmt.Sprintf("No shows found for: %s. Did you mean…", query),
+		ReplyOptions{ReplyMarkup: makeKeyboardMarkup(rows)},
+	)
+}
+
+func (handler *Handler) handleDidYouMeanCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	idx, err := strconv.Atoi(callbackParam)
+	if err != nil {
+		log.Printf("handleDidYouMeanCallback: invalid suggestion index: %s", callbackParam)
+		return nil
+	}
+
+	userID := cb.From.ID
+	userCtx := handler.Bot.getUserContext(userID)
+	if userCtx == nil || idx < 1 || idx > len(userCtx.Suggestions) {
+		handler.Bot.clearState(userID)
+		return NewUserError(
+			fmt.Errorf("session expired for user %d", userID),
+			"Session expired. Please start over with /add.",
+		)
+	}
+
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return handler.searchAndSelectShow(ctx, userCtx.Suggestions[idx-1], cb.From, cb.Message.Chat.ID)
+}
+
+// SearchFilters narrow /add results down, e.g. to tell a remake from the original.
+// Zero fields don't filter.
+type SearchFilters struct {
+	Year     int
+	Language string
+	Type     string
+	Country  string
+}
+
+func (f SearchFilters) empty() bool {
+	return f == SearchFilters{}
+}
+
+func (f SearchFilters) String() string {
+	var parts []string
+	if f.Year != 0 {
+		parts = append(parts, fmt.Sprintf("year:%d", f.Year))
+	}
+	if f.Language != "" {
+		parts = append(parts, "lang:"+f.Language)
+	}
+	if f.Type != "" {
+		parts = append(parts, "type:"+f.Type)
+	}
+	if f.Country != "" {
+		parts = append(parts, "country:"+f.Country)
+	}
+	return strings.Join(parts, " ")
+}
+
+// languageCodes maps ISO 639-1 codes to the language names TVmaze uses.
+var languageCodes = map[string]string{
+	"en": "English", "es": "Spanish", "fr": "French", "de": "German", "it": "Italian",
+	"pt": "Portuguese", "ru": "Russian", "ja": "Japanese", "ko": "Korean", "zh": "Chinese",
+	"hi": "Hindi", "tr": "Turkish", "pl": "Polish", "nl": "Dutch", "sv": "Swedish",
+	"da": "Danish", "no": "Norwegian", "fi": "Finnish", "he": "Hebrew", "ar": "Arabic",
+}
+
+// parseSearchQuery splits filters like "year:2005 lang:en type:animation country:us"
+// off a search. Words that look like filters but don't parse stay in the query.
+func parseSearchQuery(text string) (string, SearchFilters) {
+	var filters SearchFilters
+	var words []string
+	for _, word := range strings.Fields(text) {
+		key, value, found := strings.Cut(word, ":")
+		key = strings.ToLower(key)
+		switch {
+		case !found || value == "":
+			words = append(words, word)
+		case key == "year":
+			year, err := strconv.Atoi(value)
+			if err != nil || year < 1900 || year > 2100 {
+				words = append(words, word)
+				continue
+			}
+			filters.Year = year
+		case key == "lang" || key == "language":
+			filters.Language = strings.ToLower(value)
+		case key == "type":
+			filters.Type = strings.ToLower(value)
+		case key == "country":
+			filters.Country = strings.ToUpper(value)
+		default:
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " "), filters
+}
+
+// showCountry returns the code of the country the show airs in, from its network
+// or streaming platform.
+func showCountry(s ShowSearchResult) string {
+	for _, network := range []*Network{s.Network, s.WebChannel} {
+		if network != nil && network.Country != nil {
+			return network.Country.Code
+		}
+	}
+	return ""
+}
+
+func (f SearchFilters) matches(s ShowSearchResult) bool {
+	if f.Year != 0 && (s.Premiered == nil || !strings.HasPrefix(*s.Premiered, strconv.Itoa(f.Year))) {
+		return false
+	}
+	if f.Language != "" {
+		language := f.Language
+		if name, ok := languageCodes[language]; ok {
+			language = name
+		}
+		if !strings.EqualFold(s.Language, language) {
+			return false
+		}
+	}
+	if f.Type != "" && !strings.EqualFold(s.Type, f.Type) {
+		return false
+	}
+	if f.Country != "" && !strings.EqualFold(showCountry(s), f.Country) {
+		return false
+	}
+	return true
+}
+
+// applySearchFilters keeps the results matching filters, in the provider's order.
+func applySearchFilters(results []ShowSearchResult, filters SearchFilters) []ShowSearchResult {
+	if filters.empty() {
+		return results
+	}
+	var filtered []ShowSearchResult
+	for _, result := range results {
+		if filters.matches(result) {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
+
+// searchResultLabel describes a result on its button, with what tells versions of
+// a show apart: the premiere date and the country.
+func searchResultLabel(i int, s ShowSearchResult) string {
+	details := safeString(s.Premiered)
+	if country := showCountry(s); country != "" {
+		details += ", " + country
+	}
+	return fmt.Sprintf("%d. %s (%s)", i, trimString(s.Name, 25), details)
+}
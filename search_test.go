@@ -0,0 +1,179 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFallbackQueries(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"GoT", []string{"Game of Thrones"}},
+		{"Mr. Robot!", []string{"mr robot"}},
+		{"The Office", []string{"office"}},
+		{"severance", nil},
+	}
+	for _, tt := range tests {
+		if got := fallbackQueries(tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("fallbackQueries(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestShowNames(t *testing.T) {
+	names := []string{"Night Shift", "The Night Of", "Severance", "Sherlock"}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"nigth shift", []string{"Night Shift"}},
+		{"severence", []string{"Severance"}},
+		{"the night off", []string{"The Night Of"}},
+		{"sherlok", []string{"Sherlock"}},
+		{"xyzzy", nil},
+	}
+	for _, tt := range tests {
+		if got := suggestShowNames(tt.query, names); !slices.Equal(got, tt.want) {
+			t.Errorf("suggestShowNames(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchFallback(t *testing.T) {
+	shows := append(testShows(), fakeShow{ShowSearchResult: ShowSearchResult{ID: 9, Name: "Game of Thrones"}})
+	env := newTestEnv(t, shows...)
+
+	tests := []struct {
+		query        string
+		wantText     string
+		wantKeyboard []string
+	}{
+		{
+			query:        "GoT",
+			wantText:     `Showing results for "Game of Thrones". Pick the show you want to add:`,
+			wantKeyboard: []string{"acceptShowName:1", "cancel"},
+		},
+		{
+			query:        "night-shift!",
+			wantText:     `Showing results for "night shift". Pick the show you want to add:`,
+			wantKeyboard: []string{"acceptShowName:1", "cancel"},
+		},
+		{
+			query:    "xyzzy",
+			wantText: "No shows found for: xyzzy",
+		},
+	}
+	for _, tt := range tests {
+		env.command("/add " + tt.query)
+		last := env.telegram.lastMessage(t)
+		if got := last.Params.Get("text"); got != tt.wantText {
+			t.Errorf("/add %s: text = %q, want %q", tt.query, got, tt.wantText)
+		}
+		if got := last.keyboard(t); !slices.Equal(got, tt.wantKeyboard) {
+			t.Errorf("/add %s: keyboard = %v, want %v", tt.query, got, tt.wantKeyboard)
+		}
+	}
+}
+
+func TestDidYouMean(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "1")
+
+	env.command("/add nigth shift")
+	last := env.telegram.lastMessage(t)
+	if got := last.Params.Get("text"); got != "No shows found for: nigth shift. Did you mean…" {
+		t.Errorf("text = %q", got)
+	}
+	if got, want := last.keyboard(t), []string{"didYouMean:1", "cancel"}; !slices.Equal(got, want) {
+		t.Fatalf("keyboard = %v, want %v", got, want)
+	}
+
+	env.press("didYouMean:1")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "Pick the show you want to add:" {
+		t.Errorf("text after picking a suggestion = %q", got)
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		text        string
+		wantQuery   string
+		wantFilters SearchFilters
+	}{
+		{"the office", "the office", SearchFilters{}},
+		{"office year:2005 lang:en", "office", SearchFilters{Year: 2005, Language: "en"}},
+		{"Type:Animation country:jp one piece", "one piece", SearchFilters{Type: "animation", Country: "JP"}},
+		{"star trek: picard year:soon", "star trek: picard year:soon", SearchFilters{}},
+	}
+	for _, tt := range tests {
+		query, filters := parseSearchQuery(tt.text)
+		if query != tt.wantQuery || filters != tt.wantFilters {
+			t.Errorf("parseSearchQuery(%q) = %q, %+v, want %q, %+v", tt.text, query, filters, tt.wantQuery, tt.wantFilters)
+		}
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	premiered := func(date string) *string { return &date }
+	uk := &Network{Name: "BBC Two", Country: &Country{Code: "GB"}}
+	us := &Network{Name: "NBC", Country: &Country{Code: "US"}}
+	env := newTestEnv(t,
+		fakeShow{ShowSearchResult: ShowSearchResult{
+			ID: 3, Name: "The Office", Language: "English", Premiered: premiered("2001-07-09"), Network: uk,
+		}},
+		fakeShow{ShowSearchResult: ShowSearchResult{
+			ID: 4, Name: "The Office", Language: "English", Premiered: premiered("2005-03-24"), Network: us,
+		}},
+	)
+
+	env.command("/add office year:2005 lang:en")
+	msg := env.telegram.lastMessage(t)
+	if got := msg.keyboard(t); !slices.Equal(got, []string{"acceptShowName:1", "cancel"}) {
+		t.Fatalf("keyboard = %q, want only the 2005 version", got)
+	}
+	if markup := msg.Params.Get("reply_markup"); !strings.Contains(markup, "The Office (2005-03-24, US)") {
+		t.Errorf("reply_markup = %s, want the premiere and country on the button", markup)
+	}
+
+	env.command("/add office country:fr")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "No shows found for office matching country:FR" {
+		t.Errorf("reply = %q", got)
+	}
+}
+
+func TestSearchCommandBrowsesWithoutAdding(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.command("/add")
+	env.command("/search night")
+	if got, want := env.telegram.lastMessage(t).labels(t), []string{"1. Night Shift (N/A)"}; !slices.Equal(got, want) {
+		t.Fatalf("results = %v, want %v", got, want)
+	}
+
+	env.press("searchInfo:1")
+	card := env.telegram.lastMessage(t)
+	text := card.Params.Get("text")
+	for _, want := range []string{"<b>Night Shift</b>", "Network: NBC", `Next episode: S02E03 "Episode 2.3"`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("card = %q, want %q in it", text, want)
+		}
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM shows`); got != "0" {
+		t.Fatalf("browsing added %s shows", got)
+	}
+	// The /add started before is still waiting for a name
+	if got := env.handler.Bot.getState(testUserID); got != StateAwaitingShowName {
+		t.Errorf("state after /search = %v, want StateAwaitingShowName", got)
+	}
+
+	env.press("searchAdd:1")
+	if got := queryString(t, env, `SELECT name FROM shows`); got != "Night Shift" {
+		t.Errorf("added show = %q, want Night Shift", got)
+	}
+	env.press("searchInfo:1")
+	if got := env.telegram.lastMessage(t).labels(t); !slices.Contains(got, "✅ Already on your list") {
+		t.Errorf("card of a tracked show = %v", got)
+	}
+}
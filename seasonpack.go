@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	"tvreminder/bot/internal/reminder"
+)
+
+// Streaming services often release a whole season at once. When every episode of
+// a season shares one air time, the reminder for its first episode is a season
+// pack: it goes out store.SeasonPackLead ahead, covers the season in one message
+// with the operator's season pack template, and the episodes are counted as
+// reminded about.
+
+// dropDay says when a release at t is relative to now, like "tomorrow", in t's
+// time zone.
+func dropDay(t, now time.Time) string {
+	now = now.In(t.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, t.Location())
+	switch day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()); {
+	case !day.After(today):
+		return "today"
+	case day.Equal(today.AddDate(0, 0, 1)):
+		return "tomorrow"
+	default:
+		return "on " + t.Format("Mon Jan 2")
+	}
+}
+
+// formatSeasonPack is the first line of a season pack reminder.
+func formatSeasonPack(r DBReminder, now time.Time) string {
+	data := reminder.TemplateData{
+		Show:     r.ShowName,
+		Season:   r.EpisodeSeason,
+		Episodes: len(r.Batch) + 1,
+		Day:      "today",
+	}
+	if !r.AiredAt.IsZero() {
+		data.AirTimeLocal = r.AiredAt.Format("15:04")
+		data.Day = dropDay(r.AiredAt, now)
+	}
+	tmpl := r.Templates.SeasonPack
+	if tmpl == "" {
+		tmpl = reminder.DefaultSeasonPackTemplate
+	}
+	text, err := reminder.RenderSeasonPack(tmpl, data)
+	if err != nil {
+		log.Printf("reminderLoop: rendering the season pack reminder %d: %v", r.ID, err)
+		text, _ = reminder.RenderSeasonPack(reminder.DefaultSeasonPackTemplate, data)
+	}
+	return text
+}
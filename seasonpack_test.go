@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"tvreminder/bot/internal/provider"
+)
+
+func TestSeasonPackReminder(t *testing.T) {
+	env := newSimulationEnv(t)
+	drop := func(id, number int) provider.Episode {
+		return provider.Episode{ID: id, Season: 2, Number: number, Airstamp: "2026-03-06T08:00:00Z"}
+	}
+	env.handler.Provider = provider.NewFixture(provider.FixtureShow{
+		ShowSearchResult: provider.ShowSearchResult{ID: 3, Name: "Deep Water", Status: "Running"},
+		Episodes: []provider.Episode{
+			{ID: 300, Season: 1, Number: 1, Airstamp: "2025-06-01T08:00:00Z"}, drop(301, 1), drop(302, 2), drop(303, 3),
+		},
+	})
+	env.command("/add deep water")
+	env.press("acceptShowName:1")
+	env.press("selectSeason:1")
+	env.press("selectEpisode:1")
+	sent := len(env.telegram.messages())
+	if due, err := nextReminderDue(t.Context(), env.handler.DB, env.handler.Clock.Now()); err != nil || !due.Equal(time.Date(2026, 3, 5, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("nextReminderDue = %v, %v, want a day before the drop", due, err)
+	}
+
+	env.command("/admin clock 2026-03-05T07:59:00Z")
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if n := len(env.telegram.messages()) - sent; n != 1 {
+		t.Fatalf("%d messages sent more than a day before the drop, want only the /admin reply", n)
+	}
+
+	env.command("/admin clock 1m")
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if got, want := env.telegram.lastMessage(t).Params.Get("text"), "Season 2 of Deep Water (3 episodes) drops tomorrow!"; got != want {
+		t.Errorf("season pack reminder = %q, want %q", got, want)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM reminders WHERE status = 'sent'`); got != "3" {
+		t.Errorf("%s episodes reminded about, want the whole season", got)
+	}
+
+	sent = len(env.telegram.messages())
+	env.command("/admin clock 2026-03-06T08:00:00Z")
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if n := len(env.telegram.messages()) - sent; n != 1 {
+		t.Errorf("%d messages sent when the season dropped, want only the /admin reply", n)
+	}
+}
+
+func TestDropDay(t *testing.T) {
+	now := time.Date(2026, 3, 5, 23, 0, 0, 0, time.UTC)
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{at: time.Date(2026, 3, 5, 23, 30, 0, 0, time.UTC), want: "today"},
+		{at: time.Date(2026, 3, 6, 8, 0, 0, 0, time.UTC), want: "tomorrow"},
+		{at: time.Date(2026, 3, 6, 8, 0, 0, 0, time.FixedZone("UTC+2", 2*3600)), want: "today"},
+		{at: time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC), want: "on Mon Mar 9"},
+	}
+	for _, tt := range tests {
+		if got := dropDay(tt.at, now); got != tt.want {
+			t.Errorf("dropDay(%s) = %q, want %q", tt.at, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/store"
+	"tvreminder/bot/internal/telegram"
+)
+
+// newHTTPHandler routes the requests of the bot's HTTP listener. botToken checks
+// dashboard logins, signer signs dashboard sessions, clk dates the feeds.
+func newHTTPHandler(db *store.DB, botUsername, botToken string, signer *telegram.Signer, clk clock.Clock) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /feed/{token}", feedHandler(db, botUsername, clk))
+
+	d := &dashboard{db: db, botUsername: botUsername, botToken: botToken, signer: signer}
+	mux.HandleFunc("GET /dashboard", d.serveIndex)
+	mux.HandleFunc("GET /dashboard/login", d.serveLogin)
+	mux.HandleFunc("POST /dashboard/settings", d.serveSettings)
+	mux.HandleFunc("POST /dashboard/logout", d.serveLogout)
+	return mux
+}
+
+// httpServerLoop serves handler on addr until ctx is cancelled.
+func httpServerLoop(addr string, handler http.Handler, ctx context.Context) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		log.Println("httpServerLoop: context cancelled, exiting")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("httpServerLoop: shutting down: %v", err)
+		}
+	}()
+
+	log.Printf("httpServerLoop: listening on %s", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("httpServerLoop: %v", err)
+	}
+	// ListenAndServe returns as soon as Shutdown starts, wait for open requests
+	<-stopped
+}
@@ -0,0 +1,544 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+type UserSettings struct {
+	UserID               int64
+	ChatID               int64
+	MonthlyExportEnabled bool
+	LastExportAt         sql.NullTime
+	Timezone             string
+	// QuietHours is a local-time window like "23:00-08:00", empty when disabled.
+	QuietHours    string
+	ShowSummaries bool
+	// Country is an ISO 3166 alpha-2 code used for streaming availability, empty if unset.
+	Country       string
+	AirtimeAlerts bool
+	// WebhookURL receives reminder and progress events, empty when unset.
+	WebhookURL string
+	// DiscordWebhookURL receives reminders when DeliveryMode includes Discord.
+	DiscordWebhookURL string
+	DeliveryMode      string
+	// Email is where digests go once EmailVerified, depending on DigestDelivery.
+	Email          string
+	EmailVerified  bool
+	Digest         string
+	DigestDelivery string
+	LastDigestAt   sql.NullTime
+	// DigestSilent sends the Telegram digest without a sound.
+	DigestSilent bool
+	// DigestTag limits the digest to shows with that tag, empty for all shows.
+	DigestTag string
+	// ShareStats counts the user's shows in /trending, see trending.go.
+	ShareStats bool
+	// AutoAdvance marks episodes watched as soon as their reminder is sent.
+	AutoAdvance bool
+	// ReminderLinks picks the link buttons under reminders, see links.go.
+	ReminderLinks string
+	// TonightHour is the local hour of the evening summary, TonightOff when off.
+	TonightHour   int
+	LastTonightAt sql.NullTime
+	// PremiereHype announces season premieres a week ahead, see hype.go.
+	PremiereHype bool
+	// ReminderTemplate rewrites the first line of reminders, see templates.go.
+	ReminderTemplate string
+}
+
+// Location returns the user's time zone, falling back to UTC for unknown names.
+func (s *UserSettings) Location() *time.Location {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func (s *UserSettings) inQuietHours(t time.Time) bool {
+	if s.QuietHours == "" {
+		return false
+	}
+	start, end, err := parseQuietHours(s.QuietHours)
+	if err != nil {
+		return false
+	}
+	local := t.In(s.Location())
+	minute := local.Hour()*60 + local.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	// The window wraps around midnight, e.g. 23:00-08:00
+	return minute >= start || minute < end
+}
+
+// quietHoursEnd returns when the quiet hours t falls in are over, the zero time
+// when t isn't in quiet hours.
+func (s *UserSettings) quietHoursEnd(t time.Time) time.Time {
+	if !s.inQuietHours(t) {
+		return time.Time{}
+	}
+	_, end, _ := parseQuietHours(s.QuietHours)
+	local := t.In(s.Location())
+	at := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, local.Location())
+	if !at.After(local) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at
+}
+
+// parseQuietHours parses "HH:MM-HH:MM" into minutes since midnight.
+func parseQuietHours(s string) (start, end int, err error) {
+	from, to, found := strings.Cut(s, "-")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid quiet hours %q", s)
+	}
+	if start, err = parseClock(strings.TrimSpace(from)); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseClock(strings.TrimSpace(to)); err != nil {
+		return 0, 0, err
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("empty quiet hours window %q", s)
+	}
+	return start, end, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: %w", s, err)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func getUserSettings(ctx context.Context, db *store.DB, userID int64) (*UserSettings, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	settings := UserSettings{
+		UserID: userID, Timezone: "UTC", ShowSummaries: true, AirtimeAlerts: true, DeliveryMode: DeliveryTelegram,
+		DigestDelivery: DeliveryTelegram, ReminderLinks: ReminderLinksOn, TonightHour: TonightOff,
+	}
+	var monthlyExportEnabled, showSummaries, airtimeAlerts, emailVerified, autoAdvance, digestSilent, premiereHype, shareStats int
+	err := db.QueryRowContext(ctx, `
+		SELECT
+			chat_id, monthly_export_enabled, last_export_at, timezone, quiet_hours, show_summaries, country,
+			airtime_alerts, webhook_url, COALESCE(discord_webhook_url, ''), COALESCE(delivery_mode, 'telegram'),
+			COALESCE(email, ''), COALESCE(email_verified, 0), COALESCE(digest, ''), COALESCE(digest_delivery, 'telegram'),
+			last_digest_at, COALESCE(auto_advance, 0), COALESCE(reminder_links, 'on'),
+			COALESCE(tonight_hour, -1), last_tonight_at, digest_silent,
+			premiere_hype, reminder_template, digest_tag, share_stats
+		FROM user_settings
+		WHERE user_id = ?
+	`, userID).Scan(
+		&settings.ChatID, &monthlyExportEnabled, &settings.LastExportAt,
+		&settings.Timezone, &settings.QuietHours, &showSummaries, &settings.Country, &airtimeAlerts,
+		&settings.WebhookURL, &settings.DiscordWebhookURL, &settings.DeliveryMode,
+		&settings.Email, &emailVerified, &settings.Digest, &settings.DigestDelivery, &settings.LastDigestAt,
+		&autoAdvance, &settings.ReminderLinks, &settings.TonightHour, &settings.LastTonightAt,
+		&digestSilent, &premiereHype, &settings.ReminderTemplate, &settings.DigestTag, &shareStats,
+	)
+	if err == sql.ErrNoRows {
+		// Users without a settings row get the defaults
+		return &settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	settings.MonthlyExportEnabled = monthlyExportEnabled == 1
+	settings.ShowSummaries = showSummaries == 1
+	settings.AirtimeAlerts = airtimeAlerts == 1
+	settings.EmailVerified = emailVerified == 1
+	settings.AutoAdvance = autoAdvance == 1
+	settings.DigestSilent = digestSilent == 1
+	settings.PremiereHype = premiereHype == 1
+	settings.ShareStats = shareStats == 1
+	return &settings, nil
+}
+
+func ensureUserSettings(ctx context.Context, db *store.DB, userID, chatID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO user_settings (user_id, chat_id)
+		VALUES (?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET chat_id = excluded.chat_id
+	`, userID, chatID)
+	return err
+}
+
+func setMonthlyExportEnabled(ctx context.Context, db *store.DB, userID, chatID int64, enabled bool) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE user_settings SET monthly_export_enabled = ? WHERE user_id = ?
+	`, enabled, userID)
+	return err
+}
+
+func setUserTimezone(ctx context.Context, db *store.DB, userID, chatID int64, timezone string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET timezone = ? WHERE user_id = ?`, timezone, userID)
+	return err
+}
+
+func setQuietHours(ctx context.Context, db *store.DB, userID, chatID int64, quietHours string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET quiet_hours = ? WHERE user_id = ?`, quietHours, userID)
+	return err
+}
+
+func setShowSummaries(ctx context.Context, db *store.DB, userID, chatID int64, enabled bool) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET show_summaries = ? WHERE user_id = ?`, enabled, userID)
+	return err
+}
+
+// markUserInactive flags a user whose private chat rejects our messages (blocked bot,
+// deleted account). Inactive users get no reminders until they write to the bot again.
+func markUserInactive(ctx context.Context, db *store.DB, userID, chatID int64, now time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE user_settings SET inactive_since = ?
+		WHERE user_id = ? AND inactive_since IS NULL
+	`, now.UTC().Format(time.RFC3339), userID)
+	return err
+}
+
+// markUserActive clears the inactive flag once the user writes to the bot again and
+// undoes what the block did: auto-disabled shows get notifications back and the
+// shows with dead-lettered reminders get a reminder for their next episode, so
+// the episodes missed meanwhile don't all arrive at once. Reports whether the
+// user was inactive.
+func markUserActive(ctx context.Context, db *store.DB, userID int64, now time.Time) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	// Every update comes through here, so the write lock is only taken for users
+	// who actually were inactive
+	var inactive bool
+	err := db.QueryRowContext(ctx, `
+		SELECT inactive_since IS NOT NULL FROM user_settings WHERE user_id = ?
+	`, userID).Scan(&inactive)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !inactive) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE user_settings SET inactive_since = NULL
+		WHERE user_id = ? AND inactive_since IS NOT NULL
+	`, userID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE shows SET notifications_enabled = 1, notifications_auto_disabled = 0
+		WHERE user_id = ? AND notifications_auto_disabled = 1
+	`, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT show_id, MAX(chat_id), MAX(COALESCE(thread_id, 0)) FROM reminders
+		WHERE user_id = ? AND status = ? AND sent_at IS NULL
+		GROUP BY show_id
+	`, userID, ReminderStatusFailed)
+	if err != nil {
+		return false, err
+	}
+	type failedShow struct {
+		ShowID, ChatID int64
+		ThreadID       int
+	}
+	var failed []failedShow
+	for rows.Next() {
+		var r failedShow
+		if err := rows.Scan(&r.ShowID, &r.ChatID, &r.ThreadID); err != nil {
+			rows.Close()
+			return false, err
+		}
+		failed = append(failed, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	for _, r := range failed {
+		if _, err := rebuildShowReminder(ctx, db, userID, r.ShowID, r.ChatID, r.ThreadID, now); err != nil {
+			return true, fmt.Errorf("rebuilding the reminder of show %d: %w", r.ShowID, err)
+		}
+	}
+	return true, nil
+}
+
+func listMonthlyExportSubscribers(ctx context.Context, db *store.DB) ([]UserSettings, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT user_id, chat_id, last_export_at
+		FROM user_settings
+		WHERE monthly_export_enabled = 1 AND inactive_since IS NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscribers []UserSettings
+	for rows.Next() {
+		settings := UserSettings{MonthlyExportEnabled: true}
+		if err := rows.Scan(&settings.UserID, &settings.ChatID, &settings.LastExportAt); err != nil {
+			return nil, err
+		}
+		subscribers = append(subscribers, settings)
+	}
+	return subscribers, rows.Err()
+}
+
+func updateLastExportAt(ctx context.Context, db *store.DB, userID int64, exportedAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE user_settings SET last_export_at = ? WHERE user_id = ?
+	`, exportedAt.UTC().Format(time.RFC3339), userID)
+	return err
+}
+
+// TIMEZONE/QUIET commands
+
+func (handler *Handler) handleTimezoneCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	arg := strings.TrimSpace(msg.CommandArguments())
+
+	if arg == "" {
+		settings, err := getUserSettings(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting settings for user %d: %w", userID, err),
+				"Error reading your settings, please try again later.",
+			)
+		}
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf(
+			"Your time zone is %s. Use /timezone <name> to change it, e.g. /timezone Europe/Berlin.",
+			settings.Timezone,
+		))
+		return nil
+	}
+
+	loc, err := time.LoadLocation(arg)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("loading location %q: %w", arg, err),
+			fmt.Sprintf("Unknown time zone %q. Use a name like Europe/Berlin or America/New_York.", arg),
+		)
+	}
+	if err := setUserTimezone(ctx, handler.DB, userID, chatID, loc.String()); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting timezone for user %d: %w", userID, err),
+			"Error saving your time zone, please try again later.",
+		)
+	}
+	handler.Bot.reply(ctx, chatID, fmt.Sprintf(
+		"Time zone set to %s (local time now %s).", loc, handler.Clock.Now().In(loc).Format("15:04"),
+	))
+	return nil
+}
+
+func (handler *Handler) handleQuietCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	arg := strings.TrimSpace(msg.CommandArguments())
+
+	switch strings.ToLower(arg) {
+	case "":
+		settings, err := getUserSettings(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting settings for user %d: %w", userID, err),
+				"Error reading your settings, please try again later.",
+			)
+		}
+		status := "off"
+		if settings.QuietHours != "" {
+			status = fmt.Sprintf("%s (%s)", settings.QuietHours, settings.Timezone)
+		}
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf(
+			"Quiet hours: %s. Use /quiet 23:00-08:00 to set them or /quiet off to disable.", status,
+		))
+		return nil
+	case "off":
+		arg = ""
+	default:
+		start, end, err := parseQuietHours(arg)
+		if err != nil {
+			return NewUserError(err, "Usage: /quiet HH:MM-HH:MM, e.g. /quiet 23:00-08:00")
+		}
+		arg = fmt.Sprintf("%02d:%02d-%02d:%02d", start/60, start%60, end/60, end%60)
+	}
+
+	if err := setQuietHours(ctx, handler.DB, userID, chatID, arg); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting quiet hours for user %d: %w", userID, err),
+			"Error saving quiet hours, please try again later.",
+		)
+	}
+	if arg == "" {
+		handler.Bot.reply(ctx, chatID, "Quiet hours disabled.")
+	} else {
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf(
+			"Quiet hours set to %s. Reminders due in that window will arrive when it ends.", arg,
+		))
+	}
+	return nil
+}
+
+func setAutoAdvance(ctx context.Context, db *store.DB, userID, chatID int64, enabled bool) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET auto_advance = ? WHERE user_id = ?`, enabled, userID)
+	return err
+}
+
+func (handler *Handler) handleSummariesCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments()))
+
+	switch arg {
+	case "on", "off":
+		enabled := arg == "on"
+		if err := setShowSummaries(ctx, handler.DB, userID, chatID, enabled); err != nil {
+			return NewUserError(
+				fmt.Errorf("setting summaries for user %d: %w", userID, err),
+				"Error saving your settings, please try again later.",
+			)
+		}
+		if enabled {
+			handler.Bot.reply(ctx, chatID, "Episode summaries enabled. They are hidden behind a spoiler, tap to reveal.")
+		} else {
+			handler.Bot.reply(ctx, chatID, "Episode summaries disabled. No more spoilers!")
+		}
+		return nil
+	case "":
+		settings, err := getUserSettings(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting settings for user %d: %w", userID, err),
+				"Error reading your settings, please try again later.",
+			)
+		}
+		status := "off"
+		if settings.ShowSummaries {
+			status = "on"
+		}
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf(
+			"Episode summaries are %s. Use /summaries on or /summaries off to change it.", status,
+		))
+		return nil
+	default:
+		return NewUserError(
+			fmt.Errorf("invalid summaries argument: %s", arg),
+			"Usage: /summaries on|off",
+		)
+	}
+}
+
+func (handler *Handler) handleAutoAdvanceCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments()))
+
+	switch arg {
+	case "on", "off":
+		enabled := arg == "on"
+		if err := setAutoAdvance(ctx, handler.DB, userID, chatID, enabled); err != nil {
+			return NewUserError(
+				fmt.Errorf("setting auto-advance for user %d: %w", userID, err),
+				"Error saving your settings, please try again later.",
+			)
+		}
+		if enabled {
+			handler.Bot.reply(ctx, chatID, "Episodes are now marked watched as soon as I remind you about them.")
+		} else {
+			handler.Bot.reply(ctx, chatID, "Your progress only changes when you update it. I'll ask after each episode whether you watched it.")
+		}
+		return nil
+	case "":
+		settings, err := getUserSettings(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting settings for user %d: %w", userID, err),
+				"Error reading your settings, please try again later.",
+			)
+		}
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf(
+			"Auto-advance is %s. Use /autoadvance on or /autoadvance off to change it.", onOff(settings.AutoAdvance),
+		))
+		return nil
+	default:
+		return NewUserError(
+			fmt.Errorf("invalid autoadvance argument: %s", arg),
+			"Usage: /autoadvance on|off",
+		)
+	}
+}
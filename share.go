@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// sharePayloadPrefix starts /start payloads of show links: show_<provider>_<id>.
+const sharePayloadPrefix = "show_"
+
+func shareShowLink(botUsername, provider, providerShowID string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%s_%s", botUsername, sharePayloadPrefix, provider, providerShowID)
+}
+
+func parseSharePayload(payload string) (provider string, providerShowID int, err error) {
+	provider, id, found := strings.Cut(strings.TrimPrefix(payload, sharePayloadPrefix), "_")
+	if !found || provider == "" {
+		return "", 0, fmt.Errorf("invalid share payload %q", payload)
+	}
+	providerShowID, err = strconv.Atoi(id)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid show id in share payload %q: %w", payload, err)
+	}
+	return provider, providerShowID, nil
+}
+
+// getSharedShow rebuilds a search result for a show from any user's copy of it, so
+// shared links work without another search.
+func getSharedShow(ctx context.Context, db *store.DB, provider string, providerShowID int) (*ShowSearchResult, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	show := ShowSearchResult{ID: providerShowID}
+	var network, posterURL string
+	err := db.QueryRowContext(ctx, `
+		SELECT name, network, poster_url FROM shows
+		WHERE provider = ? AND provider_show_id = ? AND deleted_at IS NULL
+		LIMIT 1
+	`, provider, providerShowID).Scan(&show.Name, &network, &posterURL)
+	if err != nil {
+		return nil, err
+	}
+	if network != "" {
+		show.Network = &Network{Name: network}
+	}
+	if posterURL != "" {
+		show.Image = &Image{Original: posterURL}
+	}
+	return &show, nil
+}
+
+func (handler *Handler) handleShareShowCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showIDStr, listType, found := strings.Cut(callbackParam, ":")
+	if !found {
+		log.Printf("handleShareShowCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	showID, err := strconv.Atoi(showIDStr)
+	if err != nil {
+		log.Printf("handleShareShowCallback: invalid show ID: %s", showIDStr)
+		return nil
+	}
+
+	userID := cb.From.ID
+	chatID := cb.Message.Chat.ID
+
+	show, err := handler.validateAndGetShow(ctx, userID, chatID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	link := shareShowLink(handler.Bot.Username, show.Provider, show.ProviderShowID)
+	handler.Bot.reply(ctx, chatID, fmt.Sprintf("Send this link to a friend to recommend \"%s\":\n%s", show.Name, link))
+
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleSharedShowStart handles /start from a shared show link by adding the show
+// and asking for the user's progress right away.
+func (handler *Handler) handleSharedShowStart(ctx context.Context, msg *tgbotapi.Message, payload string) error {
+	userID := msg.From.ID
+	chatID := msg.Chat.ID
+
+	provider, providerShowID, err := parseSharePayload(payload)
+	if err != nil {
+		return NewUserError(err, "This link looks broken. Use /add to find the show.")
+	}
+	if provider != handler.Provider.Name() {
+		return NewUserError(
+			fmt.Errorf("share link for provider %s, using %s", provider, handler.Provider.Name()),
+			"This link is from a different show database. Use /add to find the show.",
+		)
+	}
+
+	show, err := getSharedShow(ctx, handler.DB, provider, providerShowID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting shared show %s/%d: %w", provider, providerShowID, err),
+			"I can't find the show from this link. Use /add to find it.",
+		)
+	}
+
+	handler.Bot.reply(ctx, chatID, fmt.Sprintf("Someone recommended \"%s\" to you!", show.Name))
+	return handler.startAddShow(ctx, userID, chatID, 0, *show)
+}
@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSharePayload(t *testing.T) {
+	tests := []struct {
+		payload      string
+		wantProvider string
+		wantID       int
+		wantErr      bool
+	}{
+		{payload: "show_tvmaze_82", wantProvider: "tvmaze", wantID: 82},
+		{payload: "show_thetvdb_81189", wantProvider: "thetvdb", wantID: 81189},
+		{payload: "show_tvmaze", wantErr: true},
+		{payload: "show__82", wantErr: true},
+		{payload: "show_tvmaze_abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		provider, id, err := parseSharePayload(tt.payload)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSharePayload(%q) err = %v, want error: %v", tt.payload, err, tt.wantErr)
+			continue
+		}
+		if provider != tt.wantProvider || id != tt.wantID {
+			t.Errorf("parseSharePayload(%q) = %q, %d", tt.payload, provider, id)
+		}
+	}
+}
+
+func TestShareShowLink(t *testing.T) {
+	const friendID int64 = 2002
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	env.command("/history")
+	env.press("selectShow:1:history")
+	env.press("shareShow:1:history")
+
+	text := env.telegram.lastMessage(t).Params.Get("text")
+	link := "https://t.me/test_bot?start=show_tvmaze_1"
+	if !strings.HasSuffix(text, link) {
+		t.Fatalf("share message %q, want link %s", text, link)
+	}
+
+	env.commandFrom(friendID, "/start show_tvmaze_1")
+
+	last := env.telegram.lastMessage(t)
+	if got := last.Params.Get("text"); got != "TV show \"Night Shift\" added. Which season are you on?" {
+		t.Errorf("friend got %q", got)
+	}
+	if got := last.Params.Get("chat_id"); got != "2002" {
+		t.Errorf("progress question sent to chat %s, want the friend's", got)
+	}
+	var shows int
+	env.handler.DB.QueryRow(`SELECT COUNT(*) FROM shows WHERE user_id = ?`, friendID).Scan(&shows)
+	if shows != 1 {
+		t.Errorf("friend tracks %d shows, want 1", shows)
+	}
+}
+
+func TestSharedLinkErrors(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    string
+	}{
+		{payload: "show_tvmaze_404", want: "I can't find the show from this link. Use /add to find it."},
+		{payload: "show_thetvdb_1", want: "This link is from a different show database. Use /add to find the show."},
+		{payload: "show_tvmaze_x", want: "This link looks broken. Use /add to find the show."},
+	}
+	for _, tt := range tests {
+		env := newTestEnv(t, testShows()...)
+		env.command("/start " + tt.payload)
+		if got := env.telegram.lastMessage(t).Params.Get("text"); got != tt.want {
+			t.Errorf("/start %s replied %q, want %q", tt.payload, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// /search looks shows up without adding them. Its results are kept apart from the
+// /add state machine, so browsing never interrupts an /add or a note in progress,
+// and a show is only added when the user presses Add on its card.
+
+// maxBrowseResults is how many shows /search lists.
+const maxBrowseResults = 5
+
+// maxShowInfoSummary bounds the summary on a show card.
+const maxShowInfoSummary = 400
+
+// showStatus describes whether a show is still running. Providers that don't send
+// a status only tell ended shows apart.
+func showStatus(s ShowSearchResult) string {
+	if s.Status != "" {
+		return s.Status
+	}
+	if s.Ended != nil && *s.Ended != "" {
+		return "Ended"
+	}
+	return ""
+}
+
+// nextAiring returns the first episode airing after now, nil when none is announced.
+func nextAiring(episodes []Episode, now time.Time) *Episode {
+	var next *Episode
+	var nextAt time.Time
+	for i, episode := range episodes {
+		airedAt, ok := episodeAirTime(episode)
+		if !ok || !airedAt.After(now) {
+			continue
+		}
+		if next == nil || airedAt.Before(nextAt) {
+			next, nextAt = &episodes[i], airedAt
+		}
+	}
+	return next
+}
+
+func userTracksShow(ctx context.Context, db *store.DB, userID int64, provider string, providerShowID int) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var n int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM shows
+		WHERE user_id = ? AND provider = ? AND provider_show_id = ? AND deleted_at IS NULL
+	`, userID, provider, strconv.Itoa(providerShowID)).Scan(&n)
+	return n > 0, err
+}
+
+// formatShowInfo renders a show card. episodes may be nil when they couldn't be
+// fetched, so the next episode is unknown.
+func formatShowInfo(show ShowSearchResult, episodes []Episode, now time.Time, loc *time.Location) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<b>%s</b>\n", html.EscapeString(show.Name))
+	if status := showStatus(show); status != "" {
+		fmt.Fprintf(&b, "Status: %s\n", html.EscapeString(status))
+	}
+	if network := show.NetworkName(); network != "" {
+		if country := showCountry(show); country != "" {
+			network += " (" + country + ")"
+		}
+		fmt.Fprintf(&b, "Network: %s\n", html.EscapeString(network))
+	}
+	if premiered := safeString(show.Premiered); premiered != "" {
+		fmt.Fprintf(&b, "Premiered: %s\n", premiered)
+	}
+	if imdbID := show.ExternalIDs().IMDB; imdbID != "" {
+		fmt.Fprintf(&b, "IMDb: <a href=\"%s\">%s</a>\n", html.EscapeString(imdbShowURL(imdbID)), html.EscapeString(imdbID))
+	}
+
+	switch next := nextAiring(episodes, now); {
+	case episodes == nil:
+		b.WriteString("Next episode: unknown right now\n")
+	case next != nil:
+		airedAt, _ := episodeAirTime(*next)
+		fmt.Fprintf(&b, "Next episode: S%02dE%02d \"%s\", %s\n",
+			next.Season, next.Number, html.EscapeString(next.Name), airedAt.In(loc).Format("Mon Jan 2, 15:04"))
+	case showStatus(show) != "Ended":
+		b.WriteString("Next episode: not announced yet\n")
+	}
+	if summary := stripHTML(show.Summary); summary != "" {
+		fmt.Fprintf(&b, "\n%s", html.EscapeString(trimString(summary, maxShowInfoSummary)))
+	}
+	return b.String()
+}
+
+// SEARCH command
+
+func (handler *Handler) handleSearchCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	query, filters := parseSearchQuery(msg.CommandArguments())
+	if query == "" {
+		handler.Bot.reply(ctx, chatID, "Usage: /search <show name>, like /search the office")
+		return nil
+	}
+	if !handler.checkRateLimit(ctx, handler.SearchLimiter, msg.From, chatID) {
+		return nil
+	}
+	handler.Bot.sendChatAction(chatID, tgbotapi.ChatTyping)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	results, usedQuery, err := handler.searchShowWithFallback(ctx, query)
+	if errors.Is(err, ErrProviderUnavailable) {
+		handler.Bot.reply(ctx, chatID, "My show database isn't answering right now. Please try again in a few minutes.")
+		return nil
+	}
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("searching show %q: %w", query, err),
+			fmt.Sprintf("Error searching show %s", query),
+		)
+	}
+	results = applySearchFilters(results, filters)
+	if len(results) == 0 {
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf("No shows found for %s", msg.CommandArguments()))
+		return nil
+	}
+	results = results[:min(maxBrowseResults, len(results))]
+
+	handler.Bot.withUserContext(msg.From.ID, func(ctx *UserContext) {
+		ctx.BrowseResults = results
+		ctx.BrowseQuery = usedQuery
+	})
+	text, keyboard := formatBrowseResults(results, usedQuery)
+	handler.Bot.reply(ctx, chatID, text, ReplyOptions{ReplyMarkup: keyboard})
+	return nil
+}
+
+func formatBrowseResults(results []ShowSearchResult, query string) (string, *tgbotapi.InlineKeyboardMarkup) {
+	var rows [][][]string
+	for i, result := range results {
+		rows = append(rows, [][]string{{searchResultLabel(i+1, result), fmt.Sprintf("searchInfo:%d", i+1)}})
+	}
+	return fmt.Sprintf("Results for \"%s\", pick one for details:", query), makeKeyboardMarkup(rows)
+}
+
+// browseResult returns the user's idx-th /search result, 1-based.
+func (handler *Handler) browseResult(userID int64, param string) (*ShowSearchResult, error) {
+	idx, err := strconv.Atoi(param)
+	userCtx := handler.Bot.getUserContext(userID)
+	if err != nil || userCtx == nil || idx < 1 || idx > len(userCtx.BrowseResults) {
+		return nil, NewUserError(
+			fmt.Errorf("no search result %q for user %d", param, userID),
+			"These results have expired. Please search again with /search.",
+		)
+	}
+	result := userCtx.BrowseResults[idx-1]
+	return &result, nil
+}
+
+// handleSearchInfoCallback shows a card for one result, with its next episode from
+// the provider.
+func (handler *Handler) handleSearchInfoCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	userID := cb.From.ID
+	show, err := handler.browseResult(userID, callbackParam)
+	if err != nil {
+		return err
+	}
+	settings, err := getUserSettings(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting settings for user %d: %w", userID, err),
+			"Error loading the show",
+		)
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	episodes, err := handler.Provider.FetchEpisodes(fetchCtx, show.ID)
+	cancel()
+	if err != nil {
+		log.Printf("handleSearchInfoCallback: fetching episodes of show %d: %v", show.ID, err)
+		episodes = nil
+	} else if episodes == nil {
+		episodes = []Episode{}
+	}
+
+	tracked, err := userTracksShow(ctx, handler.DB, userID, handler.Provider.Name(), show.ID)
+	if err != nil {
+		log.Printf("handleSearchInfoCallback: checking whether user %d tracks show %d: %v", userID, show.ID, err)
+	}
+	if !tracked {
+		duplicate, err := findTrackedDuplicate(ctx, handler.DB, userID, handler.Provider.Name(), strconv.Itoa(show.ID), show.ExternalIDs())
+		if err != nil {
+			log.Printf("handleSearchInfoCallback: looking for duplicates of show %d: %v", show.ID, err)
+		}
+		tracked = duplicate != ""
+	}
+	var rows [][][]string
+	if tracked {
+		rows = append(rows, [][]string{{"✅ Already on your list", "searchResults:"}})
+	} else {
+		rows = append(rows, [][]string{{"➕ Add", "searchAdd:" + callbackParam}})
+	}
+	rows = append(rows, [][]string{{"<< Results", "searchResults:"}})
+
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, formatShowInfo(*show, episodes, handler.Clock.Now(), settings.Location()), ReplyOptions{
+		ReplyMarkup: makeKeyboardMarkup(rows), ParseMode: "HTML", EditMessageID: cb.Message.MessageID,
+	})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+func (handler *Handler) handleSearchResultsCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) error {
+	userID := cb.From.ID
+	userCtx := handler.Bot.getUserContext(userID)
+	if userCtx == nil || len(userCtx.BrowseResults) == 0 {
+		return NewUserError(
+			fmt.Errorf("no search results for user %d", userID),
+			"These results have expired. Please search again with /search.",
+		)
+	}
+	text, keyboard := formatBrowseResults(userCtx.BrowseResults, userCtx.BrowseQuery)
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, text, ReplyOptions{ReplyMarkup: keyboard, EditMessageID: cb.Message.MessageID})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleSearchAddCallback adds a /search result like picking it in /add would.
+func (handler *Handler) handleSearchAddCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	show, err := handler.browseResult(cb.From.ID, callbackParam)
+	if err != nil {
+		return err
+	}
+	if err := handler.startAddShow(ctx, cb.From.ID, cb.Message.Chat.ID, cb.Message.MessageID, *show); err != nil {
+		return err
+	}
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Shows are listed pinned first, then in the order the user arranged them with the
+// Move up and Move down buttons, then by name, with dropped shows last. A show's
+// sort_order is NULL until the user first moves something, so shows added later go
+// to the end of their group.
+const showListOrder = `s.dropped_at IS NOT NULL, s.pinned DESC, s.sort_order IS NULL, s.sort_order, s.name`
+
+// showNeighbor returns the index of the show next to shows[idx] in direction dir
+// (-1 up, 1 down) that it can swap places with, -1 when there's none. Pinned,
+// unpinned and dropped shows stay in their own groups.
+func showNeighbor(shows []ShowProgress, idx, dir int) int {
+	other := idx + dir
+	if idx < 0 || idx >= len(shows) || other < 0 || other >= len(shows) {
+		return -1
+	}
+	if shows[other].Pinned != shows[idx].Pinned || shows[other].DroppedAt.Valid != shows[idx].DroppedAt.Valid {
+		return -1
+	}
+	return other
+}
+
+// swapShowOrder swaps the places of two of the user's shows. The user's whole list
+// is numbered in its current order first, so the swap is stable whatever filtered
+// list the two shows were neighbors in.
+func swapShowOrder(ctx context.Context, db *store.DB, userID, showID, otherID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT s.id FROM shows s WHERE s.user_id = ? AND s.deleted_at IS NULL ORDER BY `+showListOrder,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, id := range ids {
+		switch id {
+		case showID:
+			ids[i] = otherID
+		case otherID:
+			ids[i] = showID
+		}
+	}
+	for i, id := range ids {
+		if _, err := tx.ExecContext(ctx, `UPDATE shows SET sort_order = ? WHERE id = ?`, i+1, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (handler *Handler) handleMoveShowCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, args, ok := parseReleaseCallback(callbackParam, 1)
+	if !ok {
+		log.Printf("handleMoveShowCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	dir := 1
+	if args[0] == "up" {
+		dir = -1
+	}
+
+	userID := cb.From.ID
+	show, err := handler.validateAndGetShow(ctx, userID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+	shows := handler.Bot.getUserContext(userID).ShowsList
+	other := showNeighbor(shows, findShowIndex(shows, show.InternalID), dir)
+	if other == -1 {
+		// A stale card; the re-rendered one has no button for this
+		return handler.refreshShowDetail(ctx, cb, show, listType)
+	}
+	if err := swapShowOrder(ctx, handler.DB, userID, show.InternalID, shows[other].InternalID); err != nil {
+		return NewUserError(
+			fmt.Errorf("moving show %d for user %d: %w", show.InternalID, userID, err),
+			"Error moving the show",
+		)
+	}
+	return handler.refreshShowDetail(ctx, cb, show, listType)
+}
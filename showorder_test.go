@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMoveShow(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	env.command("/add solo")
+	env.press("acceptShowName:1")
+	env.handler.addJobs.wait()
+
+	order := func() []string {
+		t.Helper()
+		env.command("/history")
+		var names []string
+		for _, label := range env.telegram.lastMessage(t).labels(t) {
+			for _, name := range []string{"Night Shift", "Solo"} {
+				if strings.Contains(label, name) {
+					names = append(names, name)
+				}
+			}
+		}
+		return names
+	}
+	if got, want := order(), []string{"Night Shift", "Solo"}; !slices.Equal(got, want) {
+		t.Fatalf("initial order = %v, want %v", got, want)
+	}
+
+	env.press("selectShow:2:history")
+	labels := env.telegram.lastMessage(t).labels(t)
+	if !slices.Contains(labels, "⬆️ Move up") || slices.Contains(labels, "⬇️ Move down") {
+		t.Errorf("last show's card = %v, want only Move up", labels)
+	}
+	env.press("moveShow:2:history:up")
+	if got, want := order(), []string{"Solo", "Night Shift"}; !slices.Equal(got, want) {
+		t.Errorf("order after moving Solo up = %v, want %v", got, want)
+	}
+
+	// Pinned shows go first, and can't be moved past the unpinned ones
+	env.press("selectShow:1:history")
+	env.press("togglePinned:1:history")
+	if got, want := order(), []string{"Night Shift", "Solo"}; !slices.Equal(got, want) {
+		t.Errorf("order after pinning Night Shift = %v, want %v", got, want)
+	}
+	env.press("selectShow:1:history")
+	labels = env.telegram.lastMessage(t).labels(t)
+	if slices.Contains(labels, "⬆️ Move up") || slices.Contains(labels, "⬇️ Move down") {
+		t.Errorf("only pinned show's card = %v, want no move buttons", labels)
+	}
+}
@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Silent reminders are sent with Telegram's disable_notification, so they show up in
+// the chat without buzzing the phone. It's picked per show on the show card, and for
+// the digest with /digest silent. Reminders mirrored to other chats follow the show.
+
+func toggleShowSilent(ctx context.Context, db *store.DB, showID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE shows
+		SET silent = CASE WHEN silent = 1 THEN 0 ELSE 1 END
+		WHERE id = ?
+	`, showID)
+	return err
+}
+
+// handleToggleSilentCallback switches the show's reminders between silent and with sound.
+func (handler *Handler) handleToggleSilentCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleToggleSilentCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	show, err := handler.validateAndGetShow(ctx, cb.From.ID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	if err := toggleShowSilent(ctx, handler.DB, show.InternalID); err != nil {
+		return NewUserError(
+			fmt.Errorf("toggling silent reminders for show %d: %w", show.InternalID, err),
+			"Error updating reminders",
+		)
+	}
+	return handler.refreshShowDetail(ctx, cb, show, listType)
+}
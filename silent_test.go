@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSilentReminders(t *testing.T) {
+	env := dueReminderEnv(t)
+	env.command("/history")
+	env.press("selectShow:1:history")
+	env.press("toggleSilent:1:history")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "Reminders arrive silently") {
+		t.Errorf("show card = %q, want it silent", got)
+	}
+
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if got := countSent(env); got != 1 {
+		t.Fatalf("sent %d reminders, want 1", got)
+	}
+	if got := env.telegram.lastMessage(t).Params.Get("disable_notification"); got != "true" {
+		t.Errorf("disable_notification = %q, want true", got)
+	}
+}
+
+func TestSilentDigest(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	env.command("/digest weekly")
+	env.command("/digest silent")
+	if got, want := env.telegram.lastMessage(t).Params.Get("text"), "Digest: weekly, here, silently"; got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+
+	settings, err := getUserSettings(t.Context(), env.handler.DB, testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sendDigest(t.Context(), env.handler.Bot, env.handler.DB, nil, *settings, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := env.telegram.lastMessage(t).Params.Get("disable_notification"); got != "true" {
+		t.Errorf("disable_notification = %q, want true", got)
+	}
+}
@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/provider"
+)
+
+// Simulation mode runs the bot against a local dataset of shows instead of a
+// metadata service, with a clock that only moves when an admin says so. Setting
+// SIMULATION to the dataset's path turns it on, see provider.LoadFixture. The clock
+// starts at SIMULATION_START, else at the dataset's start, else at the real time,
+// and "/admin clock" moves it: shows can be added, reminded about and watched
+// without waiting for anything to air.
+
+// simulationFromEnv loads the dataset and starts the fake clock when SIMULATION is
+// set. It returns nils when it isn't.
+func simulationFromEnv() (*provider.Fixture, *clock.Fake, error) {
+	path := os.Getenv("SIMULATION")
+	if path == "" {
+		return nil, nil, nil
+	}
+	fixture, err := provider.LoadFixture(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid SIMULATION: %w", err)
+	}
+	start := fixture.Start
+	if s := os.Getenv("SIMULATION_START"); s != "" {
+		start, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid SIMULATION_START: %q", s)
+		}
+	}
+	if start.IsZero() {
+		start = time.Now()
+	}
+	return fixture, clock.NewFake(start), nil
+}
+
+// moveClock moves the simulation clock by arg, a duration like "90m" or "+2h", or
+// to arg when it's a time like "2026-03-01T20:00:00Z". Reminders that came due on
+// the way go out once /admin clock wakes the reminder loop.
+func moveClock(fake *clock.Fake, arg string) error {
+	if at, err := time.Parse(time.RFC3339, arg); err == nil {
+		fake.Set(at)
+	} else {
+		d, err := time.ParseDuration(strings.TrimPrefix(arg, "+"))
+		if err != nil {
+			return err
+		}
+		fake.Advance(d)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/provider"
+)
+
+// newSimulationEnv wires a test env to the simulation dataset in testdata, with the
+// clock stopped at the dataset's start and the test user as admin.
+func newSimulationEnv(t *testing.T) *testEnv {
+	t.Helper()
+	fixture, err := provider.LoadFixture(filepath.Join("testdata", "simulation.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := newTestEnv(t)
+	env.handler.Provider = fixture
+	env.handler.Clock = clock.NewFake(fixture.Start)
+	env.handler.Admins = map[int64]bool{testUserID: true}
+	return env
+}
+
+func TestSimulation(t *testing.T) {
+	env := newSimulationEnv(t)
+	env.command("/add harbor")
+	env.press("acceptShowName:1")
+	env.press("selectEpisode:1")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, `"Low Tide" is expected to air on Sun Mar 8, 20:00`) {
+		t.Fatalf("adding the show replied %q", got)
+	}
+
+	env.command("/admin clock +168h")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "Simulated time: 2026-03-08T12:00:00Z" {
+		t.Errorf("/admin clock +168h = %q", got)
+	}
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if n := countSent(env); n != 0 {
+		t.Fatalf("%d reminders sent before the episode aired", n)
+	}
+
+	env.command("/admin clock 2026-03-08T20:00:00Z")
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, `Episode #2 "Low Tide" of "Harbor Lights" (season 1) is coming out today!`) {
+		t.Fatalf("reminder = %q", got)
+	}
+
+	// The check-in comes a day later, and answering it moves on to the next episode
+	env.command("/admin clock 25h")
+	sendDueFollowups(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != `Did you watch Harbor Lights S01E02 "Low Tide"?` {
+		t.Fatalf("check-in = %q", got)
+	}
+	env.press("checkin:" + queryString(t, env, `SELECT id FROM followups`) + ":1")
+	want := time.Date(2026, 3, 15, 20, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	if got := queryString(t, env, `SELECT remind_at || ' ' || status FROM reminders WHERE status = 'pending'`); got != want+" pending" {
+		t.Errorf("next reminder = %s, want %s pending", got, want)
+	}
+}
+
+func TestAdminClockOutsideSimulation(t *testing.T) {
+	env := newTestEnv(t)
+	env.handler.Admins = map[int64]bool{testUserID: true}
+	env.command("/admin clock +1h")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "The clock only moves in simulation mode, see SIMULATION." {
+		t.Errorf("/admin clock = %q", got)
+	}
+}
@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// topRatedShows is how many shows /stats lists under "Top rated".
+const topRatedShows = 5
+
+// listedDroppedShows is how many of the shows dropped this year /stats names.
+const listedDroppedShows = 5
+
+func countUserShows(ctx context.Context, db *store.DB, userID int64) (int, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM shows WHERE user_id = ? AND deleted_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+func formatStats(shows int, watched []ShowWatchTime, dropped []DroppedShow, ratings *RatingStats) string {
+	var b strings.Builder
+	b.WriteString("<b>Your stats</b>\n\n")
+	fmt.Fprintf(&b, "Shows tracked: %d\n", shows)
+	if len(watched) > 0 {
+		var minutes, episodes int
+		for _, show := range watched {
+			minutes += show.Minutes
+			episodes += show.Episodes
+		}
+		fmt.Fprintf(&b, "Time watched: %s, %s\n", formatWatchTime(minutes), pluralize(episodes, "episode"))
+		b.WriteString("\nMost watched:\n")
+		for i, show := range watched[:min(len(watched), topWatchedShows)] {
+			fmt.Fprintf(&b, "%d. %s, %s\n", i+1, html.EscapeString(show.Name), formatWatchTime(show.Minutes))
+		}
+		b.WriteString("\n")
+	}
+	if len(dropped) > 0 {
+		fmt.Fprintf(&b, "Shows you dropped this year: %d\n", len(dropped))
+		for _, show := range dropped[:min(len(dropped), listedDroppedShows)] {
+			if show.Reason == "" {
+				fmt.Fprintf(&b, "• %s\n", html.EscapeString(show.Name))
+			} else {
+				fmt.Fprintf(&b, "• %s: <i>%s</i>\n", html.EscapeString(show.Name), html.EscapeString(show.Reason))
+			}
+		}
+		b.WriteString("\n")
+	}
+	if ratings.Seasons == 0 {
+		b.WriteString("Seasons rated: none yet, finish a season to rate it\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Seasons rated: %d, on average %s\n", ratings.Seasons, formatRating(ratings.Average))
+	b.WriteString("\nTop rated:\n")
+	for i, show := range ratings.Top {
+		fmt.Fprintf(&b, "%d. %s %s\n", i+1, html.EscapeString(show.Name), formatRating(show.Rating))
+	}
+	return b.String()
+}
+
+// STATS command
+
+func (handler *Handler) handleStatsCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	userID := msg.From.ID
+
+	shows, err := countUserShows(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("counting shows for user %d: %w", userID, err),
+			"Error getting your stats",
+		)
+	}
+	watched, err := listShowWatchTimes(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing watch times for user %d: %w", userID, err),
+			"Error getting your stats",
+		)
+	}
+	now := handler.Clock.Now()
+	dropped, err := listDroppedShows(ctx, handler.DB, userID, time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing dropped shows for user %d: %w", userID, err),
+			"Error getting your stats",
+		)
+	}
+	ratings, err := getRatingStats(ctx, handler.DB, userID, topRatedShows)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting rating stats for user %d: %w", userID, err),
+			"Error getting your stats",
+		)
+	}
+
+	handler.Bot.reply(ctx, msg.Chat.ID, formatStats(shows, watched, dropped, ratings), ReplyOptions{ParseMode: "HTML"})
+	return nil
+}
@@ -0,0 +1,683 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/reminder"
+	"tvreminder/bot/internal/store"
+)
+
+const syncInterval = 6 * time.Hour
+
+// showRefreshInterval is how long a show can go without a sync when its provider
+// reports which shows changed. It matches the window of the provider's updates, and
+// catches shows that moved or vanished, which aren't reported as changed.
+const showRefreshInterval = 7 * 24 * time.Hour
+
+// syncRetryInterval is how often shows deferred by a provider outage are retried.
+// Retries are cheap while the provider's circuit breaker is open.
+const syncRetryInterval = 2 * time.Minute
+
+// scheduleChange is a stored episode whose air time moved during a sync.
+type scheduleChange struct {
+	EpisodeID  int64
+	Season     int
+	Number     int
+	OldAiredAt time.Time
+	NewAiredAt time.Time
+}
+
+// episodeChunkSize is how many episodes are written per transaction when caching
+// a show, and so how often progress is reported.
+const episodeChunkSize = 50
+
+// storeEpisodes caches a provider's episode list. Episodes without a usable air
+// time are skipped since nothing can be scheduled for them.
+func storeEpisodes(ctx context.Context, db *store.DB, provider, providerShowID string, episodes []Episode) error {
+	return storeEpisodesWithProgress(ctx, db, provider, providerShowID, episodes, nil)
+}
+
+// storeEpisodesWithProgress is storeEpisodes writing in chunks of episodeChunkSize,
+// one transaction each, and calling progress with the number of episodes done
+// after every chunk. progress may be nil.
+func storeEpisodesWithProgress(ctx context.Context,
+	db *store.DB, provider, providerShowID string, episodes []Episode, progress func(done, total int),
+) error {
+	for start := 0; start < len(episodes); start += episodeChunkSize {
+		end := min(start+episodeChunkSize, len(episodes))
+		if err := storeEpisodeChunk(ctx, db, provider, providerShowID, episodes[start:end]); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(end, len(episodes))
+		}
+	}
+	return nil
+}
+
+func storeEpisodeChunk(ctx context.Context, db *store.DB, provider, providerShowID string, episodes []Episode) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := writeEpisodes(ctx, tx, provider, providerShowID, episodes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// dateOnlyAirHour is when episodes known only by their air date are taken to air:
+// the evening of that day, when most shows go out.
+const dateOnlyAirHour = 20
+
+// episodeAirTime returns when an episode airs. Date-only episodes come with an
+// empty airtime and an airstamp at midnight of the network's day, or without an
+// airstamp at all; they're moved to dateOnlyAirHour of that day, in the network's
+// time zone when the airstamp gives it away and in UTC otherwise. ok is false for
+// episodes without a date.
+func episodeAirTime(episode Episode) (airedAt time.Time, ok bool) {
+	airstamp, err := time.Parse(time.RFC3339, episode.Airstamp)
+	if err == nil && episode.Airtime != "" {
+		return airstamp, true
+	}
+	day, dayErr := time.Parse("2006-01-02", episode.Airdate)
+	switch {
+	case err == nil && dayErr == nil:
+		// The airstamp is midnight in the network's zone, so it's off from the
+		// UTC midnight of that day by the zone's offset
+		return airstamp.Add(dateOnlyAirHour * time.Hour), true
+	case err == nil:
+		return airstamp, true
+	case dayErr == nil:
+		return day.Add(dateOnlyAirHour * time.Hour), true
+	}
+	return time.Time{}, false
+}
+
+// writeEpisodes upserts episodes with db, which is usually a transaction. Episodes
+// without a date are skipped, see episodeAirTime.
+func writeEpisodes(ctx context.Context, db Execer, provider, providerShowID string, episodes []Episode) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	for _, episode := range episodes {
+		airstampTime, ok := episodeAirTime(episode)
+		if !ok {
+			continue
+		}
+		err := upsertEpisode(
+			ctx, db, provider, providerShowID, strconv.Itoa(episode.ID), episode.Name, episode.Season,
+			episode.Number, episode.Airdate, episode.Airtime, airstampTime, stripHTML(episode.Summary),
+			episode.Image.URL(), episode.Runtime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listTrackedProviderShows returns the IDs of all shows someone tracks with provider,
+// alone or in a watch party. Shows in the trash are included, so they're up to date
+// when restored.
+func listTrackedProviderShows(ctx context.Context, db *store.DB, provider string) ([]string, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT provider_show_id FROM shows WHERE provider = ?
+		UNION
+		SELECT provider_show_id FROM group_shows WHERE provider = ?
+	`, provider, provider)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// listEpisodeAirTimes maps provider episode IDs of a show to their stored episode.
+func listEpisodeAirTimes(ctx context.Context, db *store.DB, provider, providerShowID string) (map[string]*DBEpisode, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+episodeColumns+`
+		FROM episodes_cache
+		WHERE provider = ? AND provider_show_id = ?
+	`, provider, providerShowID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	episodes := make(map[string]*DBEpisode)
+	for rows.Next() {
+		episode, err := scanEpisode(rows)
+		if err != nil {
+			return nil, err
+		}
+		episodes[episode.ProviderEpisodeID] = episode
+	}
+	return episodes, rows.Err()
+}
+
+// syncResult is what a sync found out about a show.
+type syncResult struct {
+	// Changes are upcoming episodes whose air time moved.
+	Changes []scheduleChange
+	// Added holds the provider IDs of episodes that weren't known before.
+	Added map[string]bool
+}
+
+// syncShow refreshes a show's episodes from the provider and reports episodes
+// upcoming at now whose air time changed and episodes seen for the first time.
+func syncShow(ctx context.Context, db *store.DB, provider Provider, providerShowID string, now time.Time) (*syncResult, error) {
+	showID, err := strconv.Atoi(providerShowID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid provider show id %q: %w", providerShowID, err)
+	}
+	episodes, err := provider.FetchEpisodes(ctx, showID)
+	if err != nil {
+		return nil, fmt.Errorf("fetching episodes: %w", err)
+	}
+	known, err := listEpisodeAirTimes(ctx, db, provider.Name(), providerShowID)
+	if err != nil {
+		return nil, fmt.Errorf("listing stored episodes: %w", err)
+	}
+
+	result := &syncResult{Added: make(map[string]bool)}
+	for _, episode := range episodes {
+		old, ok := known[strconv.Itoa(episode.ID)]
+		if !ok {
+			result.Added[strconv.Itoa(episode.ID)] = true
+			continue
+		}
+		if old.AiredAtUTC.IsZero() {
+			continue
+		}
+		airedAt, ok := episodeAirTime(episode)
+		if !ok || airedAt.Equal(old.AiredAtUTC) {
+			continue
+		}
+		// Corrections to episodes that aired long ago are of no interest to anyone
+		if old.AiredAtUTC.Before(now) && airedAt.Before(now) {
+			continue
+		}
+		result.Changes = append(result.Changes, scheduleChange{
+			EpisodeID:  old.ID,
+			Season:     episode.Season,
+			Number:     episode.Number,
+			OldAiredAt: old.AiredAtUTC,
+			NewAiredAt: airedAt,
+		})
+	}
+
+	if err := storeEpisodes(ctx, db, provider.Name(), providerShowID, episodes); err != nil {
+		return nil, fmt.Errorf("storing episodes: %w", err)
+	}
+	return result, nil
+}
+
+// getExternalIDs returns a show's IDs on other services. They're cached once
+// known; on a miss TheTVDB shows are their own TVDB ID and other providers are
+// asked, the answer cached even when the provider doesn't know any.
+func getExternalIDs(ctx context.Context, db *store.DB, provider Provider, providerName, providerShowID string) (ExternalIDs, error) {
+	queryCtx, cancel := db.WithQueryTimeout(ctx)
+	var ids ExternalIDs
+	err := db.QueryRowContext(queryCtx, `
+		SELECT tvdb, imdb, tmdb FROM show_external_ids WHERE provider = ? AND provider_show_id = ?
+	`, providerName, providerShowID).Scan(&ids.TVDB, &ids.IMDB, &ids.TMDB)
+	cancel()
+	if err == nil {
+		return ids, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return ExternalIDs{}, err
+	}
+
+	if providerName == "thetvdb" {
+		id, err := strconv.Atoi(providerShowID)
+		if err != nil {
+			return ExternalIDs{}, fmt.Errorf("invalid TheTVDB ID %q", providerShowID)
+		}
+		return ExternalIDs{TVDB: id}, nil
+	}
+	external, ok := provider.(ExternalIDProvider)
+	if !ok || provider.Name() != providerName {
+		return ExternalIDs{}, nil
+	}
+	showID, err := strconv.Atoi(providerShowID)
+	if err != nil {
+		return ExternalIDs{}, fmt.Errorf("invalid %s ID %q", providerName, providerShowID)
+	}
+	ids, err = external.ExternalIDs(ctx, showID)
+	if err != nil {
+		return ExternalIDs{}, err
+	}
+	return ids, storeExternalIDs(ctx, db, providerName, providerShowID, ids)
+}
+
+// affectedReminder is a pending reminder for an episode whose schedule changed.
+type affectedReminder struct {
+	ID       int64
+	UserID   int64
+	ChatID   int64
+	ShowName string
+	Alerts   bool
+	Timezone string
+	// ReleaseDelayHours shifts the reminder from the original airing, see release.go.
+	ReleaseDelayHours int
+}
+
+func listRemindersForEpisode(ctx context.Context, db *store.DB, episodeID int64) ([]affectedReminder, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			r.id, r.user_id, r.chat_id, s.name,
+			COALESCE(us.airtime_alerts, 1) = 1 AND s.notifications_enabled = 1 AND us.inactive_since IS NULL,
+			COALESCE(us.timezone, 'UTC'), s.release_delay_hours
+		FROM reminders r
+		JOIN shows s ON s.id = r.show_id
+		LEFT JOIN user_settings us ON us.user_id = r.user_id
+		WHERE r.episode_id = ? AND r.status = ? AND r.deleted_at IS NULL
+	`, episodeID, ReminderStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []affectedReminder
+	for rows.Next() {
+		var r affectedReminder
+		if err := rows.Scan(&r.ID, &r.UserID, &r.ChatID, &r.ShowName, &r.Alerts, &r.Timezone, &r.ReleaseDelayHours); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, r)
+	}
+	return reminders, rows.Err()
+}
+
+func rescheduleReminder(ctx context.Context, db *store.DB, reminderID int64, remindAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE reminders SET remind_at = ? WHERE id = ?
+	`, remindAt.UTC().Format(time.RFC3339), reminderID)
+	return err
+}
+
+func formatScheduleChange(showName string, change scheduleChange, loc *time.Location) string {
+	const layout = "Mon Jan 2, 15:04"
+	return fmt.Sprintf(
+		"Schedule change: S%02dE%02d of \"%s\" moved from %s to %s. Your reminder has been updated.",
+		change.Season, change.Number, showName,
+		change.OldAiredAt.In(loc).Format(layout), change.NewAiredAt.In(loc).Format(layout),
+	)
+}
+
+// applyScheduleChanges moves the reminders for changed episodes and tells the users
+// who haven't switched these alerts off. quietUserID, when not 0, is a user who
+// already heard about the changes some other way.
+func applyScheduleChanges(ctx context.Context, bot *Bot, db *store.DB, changes []scheduleChange, quietUserID int64) {
+	for _, change := range changes {
+		reminders, err := listRemindersForEpisode(ctx, db, change.EpisodeID)
+		if err != nil {
+			log.Printf("syncLoop: listing reminders for episode %d: %v", change.EpisodeID, err)
+			continue
+		}
+		for _, r := range reminders {
+			if err := rescheduleReminder(ctx, db, r.ID, releaseTime(change.NewAiredAt, r.ReleaseDelayHours)); err != nil {
+				log.Printf("syncLoop: rescheduling reminder %d: %v", r.ID, err)
+				continue
+			}
+			if !r.Alerts || r.UserID == quietUserID {
+				continue
+			}
+			settings := UserSettings{Timezone: r.Timezone}
+			bot.reply(ctx, r.ChatID, formatScheduleChange(r.ShowName, change, settings.Location()))
+		}
+	}
+}
+
+// caughtUpShow is a user's show that has no pending reminder, typically because the
+// user watched everything that was announced.
+type caughtUpShow struct {
+	ID           int64
+	UserID       int64
+	ChatID       int64
+	Name         string
+	Season       sql.NullInt32
+	Number       sql.NullInt32
+	ReminderMode string
+}
+
+func listShowsWithoutReminder(ctx context.Context, db *store.DB, provider, providerShowID string) ([]caughtUpShow, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.id, s.user_id, COALESCE(us.chat_id, s.user_id), s.name, e.season, e.number, s.reminder_mode
+		FROM shows s
+		LEFT JOIN episodes_cache e ON e.id = s.last_watched_episode_id
+		LEFT JOIN user_settings us ON us.user_id = s.user_id
+		WHERE s.provider = ? AND s.provider_show_id = ?
+		AND s.deleted_at IS NULL
+		AND s.dropped_at IS NULL
+		AND s.notifications_enabled = 1
+		AND us.inactive_since IS NULL
+		AND NOT EXISTS (SELECT 1 FROM reminders r WHERE r.show_id = s.id AND r.status = ?)
+	`, provider, providerShowID, ReminderStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shows []caughtUpShow
+	for rows.Next() {
+		var show caughtUpShow
+		err := rows.Scan(
+			&show.ID, &show.UserID, &show.ChatID, &show.Name, &show.Season, &show.Number, &show.ReminderMode,
+		)
+		if err != nil {
+			return nil, err
+		}
+		shows = append(shows, show)
+	}
+	return shows, rows.Err()
+}
+
+func formatAnnouncement(showName string, next *DBEpisode, loc *time.Location) string {
+	premiere := next.AiredAtUTC.In(loc).Format("Mon Jan 2")
+	if next.Number == 1 {
+		return fmt.Sprintf(
+			"Season %d of \"%s\" announced, premieres %s. I'll remind you when it airs.",
+			next.Season, showName, premiere,
+		)
+	}
+	return fmt.Sprintf(
+		"New episode of \"%s\" announced: S%02dE%02d \"%s\" airs %s. I'll remind you when it airs.",
+		showName, next.Season, next.Number, next.Title, premiere,
+	)
+}
+
+// announceNewEpisodes tells users who had nothing left to wait for about newly added
+// episodes upcoming at now and schedules their reminders.
+func announceNewEpisodes(ctx context.Context, bot *Bot, db *store.DB, provider, providerShowID string, added map[string]bool, now time.Time) {
+	shows, err := listShowsWithoutReminder(ctx, db, provider, providerShowID)
+	if err != nil {
+		log.Printf("syncLoop: listing shows without reminder for %s: %v", providerShowID, err)
+		return
+	}
+	for _, show := range shows {
+		next, err := findNextEpisode(ctx, db, provider, providerShowID, show.Season, show.Number)
+		if err == nil && show.ReminderMode == ReminderModeWatchlist {
+			next, err = findNextPremiere(ctx, db, provider, providerShowID, next)
+		}
+		if err != nil || !added[next.ProviderEpisodeID] || !next.AiredAtUTC.After(now) {
+			// Users who are behind get nothing: their next episode is already out
+			continue
+		}
+		if _, err := rebuildShowReminder(ctx, db, show.UserID, show.ID, show.ChatID, 0, now); err != nil {
+			log.Printf("syncLoop: creating reminder for show %d: %v", show.ID, err)
+			continue
+		}
+		settings, err := getUserSettings(ctx, db, show.UserID)
+		if err != nil {
+			log.Printf("syncLoop: getting settings for user %d: %v", show.UserID, err)
+			continue
+		}
+		bot.reply(ctx, show.ChatID, formatAnnouncement(show.Name, next, settings.Location()))
+	}
+}
+
+// markShowSynced records that the show's episodes are up to date as of syncedAt.
+func markShowSynced(ctx context.Context, db *store.DB, provider, providerShowID string, syncedAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO show_syncs (provider, provider_show_id, synced_at) VALUES (?, ?, ?)
+	`, provider, providerShowID, syncedAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+// listShowSyncTimes maps the provider's shows to when they were last synced, and
+// forgets the shows nobody tracks anymore.
+func listShowSyncTimes(ctx context.Context, db *store.DB, provider string) (map[string]time.Time, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		DELETE FROM show_syncs WHERE provider = ? AND provider_show_id NOT IN (
+			SELECT provider_show_id FROM shows WHERE provider = ?
+			UNION
+			SELECT provider_show_id FROM group_shows WHERE provider = ?
+		)
+	`, provider, provider, provider)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT provider_show_id, synced_at FROM show_syncs WHERE provider = ?
+	`, provider)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	synced := make(map[string]time.Time)
+	for rows.Next() {
+		var showID, syncedAt string
+		if err := rows.Scan(&showID, &syncedAt); err != nil {
+			return nil, err
+		}
+		if t, err := time.Parse(time.RFC3339, syncedAt); err == nil {
+			synced[showID] = t
+		}
+	}
+	return synced, rows.Err()
+}
+
+// changedShows narrows showIDs down to the ones the provider changed since their
+// last sync, plus the ones not synced within showRefreshInterval. All of them are
+// returned when the provider can't tell what changed.
+func changedShows(ctx context.Context, db *store.DB, provider Provider, showIDs []string, now time.Time) []string {
+	updates, ok := provider.(UpdatesProvider)
+	if !ok {
+		return showIDs
+	}
+	updated, err := updates.UpdatedShows(ctx)
+	if err != nil {
+		log.Printf("syncLoop: listing updated shows, syncing all: %v", err)
+		return showIDs
+	}
+	synced, err := listShowSyncTimes(ctx, db, provider.Name())
+	if err != nil {
+		log.Printf("syncLoop: listing sync times, syncing all: %v", err)
+		return showIDs
+	}
+
+	var changed []string
+	for _, showID := range showIDs {
+		syncedAt, ok := synced[showID]
+		updatedAt, isUpdated := updated[showID]
+		// Same-second changes count, the sync may have missed them
+		if !ok || now.Sub(syncedAt) >= showRefreshInterval || (isUpdated && !updatedAt.Before(syncedAt)) {
+			changed = append(changed, showID)
+		}
+	}
+	return changed
+}
+
+// syncAllShows syncs every tracked show that may have changed and returns the ones
+// deferred because the provider is unavailable.
+func syncAllShows(ctx context.Context, bot *Bot, db *store.DB, provider Provider, clk clock.Clock) []string {
+	showIDs, err := listTrackedProviderShows(ctx, db, provider.Name())
+	if err != nil {
+		log.Printf("syncLoop: listing tracked shows: %v", err)
+		return nil
+	}
+	changed := changedShows(ctx, db, provider, showIDs, clk.Now())
+	if skipped := len(showIDs) - len(changed); skipped > 0 {
+		log.Printf("syncLoop: %d of %d shows unchanged since their last sync", skipped, len(showIDs))
+	}
+	return syncShows(ctx, bot, db, provider, changed, clk)
+}
+
+// syncShows syncs the shows and applies the changes. Once the provider's circuit
+// breaker opens the remaining shows are returned unsynced, to retry later.
+func syncShows(ctx context.Context, bot *Bot, db *store.DB, provider Provider, showIDs []string, clk clock.Clock) []string {
+	for i, showID := range showIDs {
+		startedAt := time.Now()
+		now := clk.Now()
+		syncCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		result, err := syncShow(syncCtx, db, provider, showID, now)
+		cancel()
+		if errors.Is(err, ErrProviderUnavailable) {
+			log.Printf("syncLoop: %s is unavailable, deferring %d shows", provider.Name(), len(showIDs)-i)
+			return showIDs[i:]
+		}
+		if errors.Is(err, ErrShowNotFound) {
+			log.Printf("syncLoop: show %s is gone from %s, looking for it", showID, provider.Name())
+			relocateCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			newID, err := relocateShow(relocateCtx, bot, db, provider, showID, now)
+			cancel()
+			if err != nil {
+				log.Printf("syncLoop: relocating show %s: %v", showID, err)
+			} else if newID != "" {
+				log.Printf("syncLoop: show %s moved to %s", showID, newID)
+			}
+			continue
+		}
+		if err != nil {
+			log.Printf("syncLoop: syncing show %s: %v", showID, err)
+			continue
+		}
+		if err := markShowSynced(ctx, db, provider.Name(), showID, startedAt); err != nil {
+			log.Printf("syncLoop: recording sync of show %s: %v", showID, err)
+		}
+		if err := clearShowMissing(ctx, db, provider.Name(), showID); err != nil {
+			log.Printf("syncLoop: clearing missing flag of show %s: %v", showID, err)
+		}
+		// Reminder links and Trakt sync need them, so they're ready by the next reminder
+		if _, err := getExternalIDs(ctx, db, provider, provider.Name(), showID); err != nil {
+			log.Printf("syncLoop: external IDs of show %s: %v", showID, err)
+		}
+		if len(result.Changes) > 0 {
+			log.Printf("syncLoop: show %s has %d schedule changes", showID, len(result.Changes))
+			applyScheduleChanges(ctx, bot, db, result.Changes, 0)
+		}
+		if len(result.Added) > 0 {
+			log.Printf("syncLoop: show %s has %d new episodes", showID, len(result.Added))
+			announceNewEpisodes(ctx, bot, db, provider.Name(), showID, result.Added, now)
+		}
+	}
+	return nil
+}
+
+// syncLoop keeps the episode cache up to date so new episodes and schedule changes
+// reach users without them re-adding shows. Shows deferred during a provider outage
+// are retried every syncRetryInterval until the provider is back. Syncing moves
+// reminders, so each sync wakes reminders.
+func syncLoop(bot *Bot, db *store.DB, provider Provider, reminders *reminder.Scheduler, clk clock.Clock, ctx context.Context) {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	retry := time.NewTicker(syncRetryInterval)
+	defer retry.Stop()
+
+	var deferred []string
+	for {
+		select {
+		case <-ticker.C:
+			deferred = syncAllShows(ctx, bot, db, provider, clk)
+			reminders.Wake()
+		case <-retry.C:
+			if len(deferred) > 0 {
+				deferred = syncShows(ctx, bot, db, provider, deferred, clk)
+				reminders.Wake()
+			}
+		case <-ctx.Done():
+			log.Println("syncLoop: context cancelled, exiting")
+			return
+		}
+	}
+}
+
+func setAirtimeAlerts(ctx context.Context, db *store.DB, userID, chatID int64, enabled bool) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET airtime_alerts = ? WHERE user_id = ?`, enabled, userID)
+	return err
+}
+
+// AIRALERTS command
+
+func (handler *Handler) handleAirAlertsCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+
+	switch arg := msg.CommandArguments(); arg {
+	case "on", "off":
+		enabled := arg == "on"
+		if err := setAirtimeAlerts(ctx, handler.DB, userID, chatID, enabled); err != nil {
+			return NewUserError(
+				fmt.Errorf("setting air time alerts for user %d: %w", userID, err),
+				"Error saving your settings, please try again later.",
+			)
+		}
+		if enabled {
+			handler.Bot.reply(ctx, chatID, "I'll let you know when an episode you're waiting for is rescheduled.")
+		} else {
+			handler.Bot.reply(ctx, chatID, "Schedule change alerts disabled. Reminders still follow the new air times.")
+		}
+		return nil
+	case "":
+		settings, err := getUserSettings(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting settings for user %d: %w", userID, err),
+				"Error reading your settings, please try again later.",
+			)
+		}
+		status := "off"
+		if settings.AirtimeAlerts {
+			status = "on"
+		}
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf(
+			"Schedule change alerts are %s. Use /airalerts on or /airalerts off to change it.", status,
+		))
+		return nil
+	default:
+		return NewUserError(
+			fmt.Errorf("invalid airalerts argument: %s", arg),
+			"Usage: /airalerts on|off",
+		)
+	}
+}
@@ -0,0 +1,225 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rescheduleEpisode moves "Night Shift" S02E03 by delay on the fake TVmaze.
+func rescheduleEpisode(env *testEnv, delay time.Duration) time.Time {
+	var newAiredAt time.Time
+	env.tvmaze.updateShow(1, func(show *fakeShow) {
+		for i, episode := range show.Episodes {
+			if episode.Season == 2 && episode.Number == 3 {
+				airedAt, _ := time.Parse(time.RFC3339, episode.Airstamp)
+				newAiredAt = airedAt.Add(delay)
+				show.Episodes[i].Airstamp = newAiredAt.Format(time.RFC3339)
+			}
+		}
+	})
+	return newAiredAt
+}
+
+func TestSyncReschedulesReminders(t *testing.T) {
+	tests := []struct {
+		name      string
+		delay     time.Duration
+		alertsOff bool
+		wantAlert bool
+	}{
+		{name: "delayed episode", delay: 14 * 24 * time.Hour, wantAlert: true},
+		{name: "alerts disabled", delay: 14 * 24 * time.Hour, alertsOff: true},
+		{name: "unchanged schedule"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, testShows()...)
+			trackShow(t, env, "2")
+			if tt.alertsOff {
+				env.command("/airalerts off")
+			}
+			newAiredAt := rescheduleEpisode(env, tt.delay)
+			sent := len(env.telegram.messages())
+
+			syncAllShows(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Provider, env.handler.Clock)
+
+			var alerts []string
+			for _, msg := range env.telegram.messages()[sent:] {
+				alerts = append(alerts, msg.Params.Get("text"))
+			}
+			if got := len(alerts) == 1; got != tt.wantAlert {
+				t.Fatalf("alerts = %q, want alert: %v", alerts, tt.wantAlert)
+			}
+			if tt.wantAlert && !strings.Contains(alerts[0], "S02E03 of \"Night Shift\" moved from") {
+				t.Errorf("alert = %q", alerts[0])
+			}
+
+			var remindAt time.Time
+			if err := env.handler.DB.QueryRow(`SELECT remind_at FROM reminders`).Scan(&remindAt); err != nil {
+				t.Fatal(err)
+			}
+			if !remindAt.Equal(newAiredAt) {
+				t.Errorf("remind_at = %s, want %s", remindAt, newAiredAt)
+			}
+		})
+	}
+}
+
+func TestSyncIgnoresPastCorrections(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	env.tvmaze.updateShow(1, func(show *fakeShow) {
+		show.Episodes[0].Airstamp = time.Now().AddDate(0, -4, 0).UTC().Format(time.RFC3339)
+	})
+
+	result, err := syncShow(t.Context(), env.handler.DB, env.handler.Provider, "1", env.handler.Clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Changes) != 0 {
+		t.Errorf("changes = %+v, want none for an episode that aired long ago", result.Changes)
+	}
+}
+
+func TestSyncAnnouncesNewSeason(t *testing.T) {
+	tests := []struct {
+		name         string
+		episode      string
+		muted        bool
+		wantAnnounce bool
+	}{
+		{name: "caught up", episode: "3", wantAnnounce: true},
+		{name: "behind", episode: "1"},
+		{name: "muted show", episode: "3", muted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, testShows()...)
+			trackShow(t, env, tt.episode)
+			if tt.muted {
+				env.handler.DB.Exec(`UPDATE shows SET notifications_enabled = 0`)
+			}
+			premiere := time.Now().AddDate(0, 1, 0)
+			env.tvmaze.updateShow(1, func(show *fakeShow) {
+				show.Episodes = append(show.Episodes, makeEpisodes(1, 3, 8, premiere)...)
+			})
+			sent := len(env.telegram.messages())
+
+			syncAllShows(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Provider, env.handler.Clock)
+
+			var announcements []string
+			for _, msg := range env.telegram.messages()[sent:] {
+				announcements = append(announcements, msg.Params.Get("text"))
+			}
+			if got := len(announcements) == 1; got != tt.wantAnnounce {
+				t.Fatalf("announcements = %q, want announcement: %v", announcements, tt.wantAnnounce)
+			}
+			if !tt.wantAnnounce {
+				return
+			}
+			want := "Season 3 of \"Night Shift\" announced, premieres " + premiere.UTC().Format("Mon Jan 2")
+			if !strings.HasPrefix(announcements[0], want) {
+				t.Errorf("announcement = %q, want prefix %q", announcements[0], want)
+			}
+			var season, number int
+			err := env.handler.DB.QueryRow(`
+				SELECT e.season, e.number FROM reminders r JOIN episodes_cache e ON e.id = r.episode_id
+			`).Scan(&season, &number)
+			if err != nil || season != 3 || number != 1 {
+				t.Errorf("reminder for S%02dE%02d (err %v), want S03E01", season, number, err)
+			}
+		})
+	}
+}
+
+func TestSyncDoesNotAnnounceTwice(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "3")
+	env.tvmaze.updateShow(1, func(show *fakeShow) {
+		show.Episodes = append(show.Episodes, makeEpisodes(1, 3, 8, time.Now().AddDate(0, 1, 0))...)
+	})
+
+	syncAllShows(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Provider, env.handler.Clock)
+	sent := len(env.telegram.messages())
+	syncAllShows(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Provider, env.handler.Clock)
+
+	if extra := len(env.telegram.messages()) - sent; extra != 0 {
+		t.Errorf("second sync sent %d more messages", extra)
+	}
+}
+
+func TestSyncSkipsUnchangedShows(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	showIDs := []string{"1"}
+	changed := func() []string {
+		return changedShows(t.Context(), env.handler.DB, env.handler.Provider, showIDs, time.Now())
+	}
+
+	if got := changed(); !slices.Equal(got, showIDs) {
+		t.Errorf("changed before the first sync = %v, want %v", got, showIDs)
+	}
+	syncAllShows(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Provider, env.handler.Clock)
+	if got := changed(); len(got) != 0 {
+		t.Errorf("changed right after syncing = %v, want none", got)
+	}
+
+	rescheduleEpisode(env, time.Hour)
+	if got := changed(); !slices.Equal(got, showIDs) {
+		t.Errorf("changed after an update = %v, want %v", got, showIDs)
+	}
+	syncAllShows(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Provider, env.handler.Clock)
+
+	_, err := env.handler.DB.Exec(`UPDATE show_syncs SET synced_at = ?`,
+		time.Now().Add(-showRefreshInterval-time.Hour).UTC().Format(time.RFC3339))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := changed(); !slices.Equal(got, showIDs) {
+		t.Errorf("changed a week after the last sync = %v, want %v", got, showIDs)
+	}
+}
+
+func TestEpisodeAirTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		episode Episode
+		want    string
+	}{
+		{
+			name:    "with air time",
+			episode: Episode{Airdate: "2024-03-01", Airtime: "21:00", Airstamp: "2024-03-02T02:00:00+00:00"},
+			want:    "2024-03-02T02:00:00Z",
+		},
+		{
+			name:    "date only, midnight in New York",
+			episode: Episode{Airdate: "2024-03-01", Airstamp: "2024-03-01T05:00:00+00:00"},
+			want:    "2024-03-02T01:00:00Z",
+		},
+		{
+			name:    "date only without airstamp",
+			episode: Episode{Airdate: "2024-03-01"},
+			want:    "2024-03-01T20:00:00Z",
+		},
+		{
+			name:    "no date",
+			episode: Episode{Airdate: "", Airstamp: ""},
+		},
+	}
+	for _, tt := range tests {
+		got, ok := episodeAirTime(tt.episode)
+		if tt.want == "" {
+			if ok {
+				t.Errorf("%s: got %v, want none", tt.name, got)
+			}
+			continue
+		}
+		if !ok || got.UTC().Format(time.RFC3339) != tt.want {
+			t.Errorf("%s: got %v (%v), want %s", tt.name, got.UTC(), ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,594 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Users tag their shows, like "comfort" or "with partner", from the show card. Each
+// tag is also a custom list: /tags opens the shows with a tag, whether they're
+// current or not, /shows gets a filter button per tag and /digest tag limits the
+// digest to a tag's shows. Tags are matched without regard to case.
+
+// maxTagLength keeps tags short enough for a filter button.
+const maxTagLength = 24
+
+// maxTags bounds the tags of a user, so the tag keyboards stay usable.
+const maxTags = 30
+
+// Tag is one of the user's tags and how many of their shows have it.
+type Tag struct {
+	ID    int64
+	Name  string
+	Shows int
+}
+
+// splitTags parses the tags of a show as listShowsWithProgress concatenates them.
+func splitTags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+	names := strings.Split(tags, ",")
+	slices.SortFunc(names, func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+	return names
+}
+
+// listShowTags returns the tags of shows, the ones on the most shows first.
+func listShowTags(shows []ShowProgress) []string {
+	return rankLabels(shows, func(show ShowProgress) []string { return show.Tags })
+}
+
+// filterShowsByTag returns the shows tagged with tag, all of them when it's empty.
+func filterShowsByTag(shows []ShowProgress, tag string) []ShowProgress {
+	if tag == "" {
+		return shows
+	}
+	var filtered []ShowProgress
+	for _, show := range shows {
+		if slices.ContainsFunc(show.Tags, func(t string) bool { return strings.EqualFold(t, tag) }) {
+			filtered = append(filtered, show)
+		}
+	}
+	return filtered
+}
+
+// parseTagName tidies up a tag typed by the user, returning why it's unusable.
+func parseTagName(text string) (string, error) {
+	name := strings.Join(strings.Fields(text), " ")
+	switch {
+	case name == "":
+		return "", errors.New("the tag is empty")
+	case strings.Contains(name, ","):
+		return "", errors.New("tags can't have commas, send one tag at a time")
+	case utf8.RuneCountInString(name) > maxTagLength:
+		return "", fmt.Errorf("tags are up to %d characters", maxTagLength)
+	}
+	return name, nil
+}
+
+func listTags(ctx context.Context, db *store.DB, userID int64) ([]Tag, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			t.id, t.name,
+			(
+				SELECT COUNT(*) FROM show_tags st JOIN shows s ON s.id = st.show_id
+				WHERE st.tag_id = t.id AND s.deleted_at IS NULL
+			)
+		FROM tags t
+		WHERE t.user_id = ?
+		ORDER BY t.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []Tag
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Shows); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// findTag returns the user's tag called name, nil when there's none.
+func findTag(ctx context.Context, db *store.DB, userID int64, name string) (*Tag, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var tag Tag
+	err := db.QueryRowContext(ctx, `
+		SELECT id, name FROM tags WHERE user_id = ? AND name = ?
+	`, userID, name).Scan(&tag.ID, &tag.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// createTag returns the user's tag called name, creating it when it's new.
+func createTag(ctx context.Context, db *store.DB, userID int64, name string) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO tags (user_id, name) VALUES (?, ?) ON CONFLICT(user_id, name) DO NOTHING
+	`, userID, name); err != nil {
+		return 0, err
+	}
+	var tagID int64
+	err := db.QueryRowContext(ctx, `SELECT id FROM tags WHERE user_id = ? AND name = ?`, userID, name).Scan(&tagID)
+	return tagID, err
+}
+
+func addShowTag(ctx context.Context, db *store.DB, showID, tagID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `INSERT OR IGNORE INTO show_tags (show_id, tag_id) VALUES (?, ?)`, showID, tagID)
+	return err
+}
+
+// toggleShowTag tags the show, or untags it when it already has the tag.
+func toggleShowTag(ctx context.Context, db *store.DB, showID, tagID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, `DELETE FROM show_tags WHERE show_id = ? AND tag_id = ?`, showID, tagID)
+	if err != nil {
+		return err
+	}
+	if removed, err := result.RowsAffected(); err != nil || removed > 0 {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO show_tags (show_id, tag_id) VALUES (?, ?)`, showID, tagID)
+	return err
+}
+
+// deleteTag removes the tag from the user's shows and from their digest.
+func deleteTag(ctx context.Context, db *store.DB, userID, tagID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE user_settings SET digest_tag = ''
+		WHERE user_id = ? AND digest_tag IN (SELECT name FROM tags WHERE id = ?)
+	`, userID, tagID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM show_tags WHERE tag_id IN (SELECT id FROM tags WHERE id = ? AND user_id = ?)`, tagID, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id = ? AND user_id = ?`, tagID, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// listTaggedShowIDs returns the user's shows with the tag.
+func listTaggedShowIDs(ctx context.Context, db *store.DB, userID int64, tag string) (map[int64]bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT st.show_id FROM show_tags st JOIN tags t ON t.id = st.tag_id
+		WHERE t.user_id = ? AND t.name = ?
+	`, userID, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shows := make(map[int64]bool)
+	for rows.Next() {
+		var showID int64
+		if err := rows.Scan(&showID); err != nil {
+			return nil, err
+		}
+		shows[showID] = true
+	}
+	return shows, rows.Err()
+}
+
+// taggedShowsTitle is the header of a tag's list.
+func taggedShowsTitle(tag string) string {
+	return fmt.Sprintf("Your shows tagged %s:", tag)
+}
+
+// parseTagID parses the tag ID callbacks carry.
+func parseTagID(param string) (int64, bool) {
+	tagID, err := strconv.ParseInt(param, 10, 64)
+	return tagID, err == nil
+}
+
+// TAGS command
+
+func (handler *Handler) handleTagsCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	text, keyboard, err := handler.tagsMessage(ctx, msg.From.ID)
+	if err != nil {
+		return err
+	}
+	handler.Bot.reply(ctx, msg.Chat.ID, text, ReplyOptions{ReplyMarkup: keyboard})
+	return nil
+}
+
+// tagsMessage lists the user's tags, each opening its shows and with a button to
+// delete it.
+func (handler *Handler) tagsMessage(ctx context.Context, userID int64) (string, any, error) {
+	tags, err := listTags(ctx, handler.DB, userID)
+	if err != nil {
+		return "", nil, NewUserError(
+			fmt.Errorf("listing tags of user %d: %w", userID, err),
+			"Error: can't list your tags at this time",
+		)
+	}
+	if len(tags) == 0 {
+		return "You have no tags yet. Open a show in /shows and press 🏷 Tags to add one.", nil, nil
+	}
+	var rows [][][]string
+	for _, tag := range tags {
+		rows = append(rows, [][]string{
+			{fmt.Sprintf("🏷 %s (%d)", tag.Name, tag.Shows), fmt.Sprintf("tagList:%d", tag.ID)},
+			{"🗑", fmt.Sprintf("deleteTag:%d", tag.ID)},
+		})
+	}
+	return "Your tags, press one to see its shows:", makeKeyboardMarkup(rows), nil
+}
+
+// handleTagListCallback lists all the user's shows with a tag.
+func (handler *Handler) handleTagListCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	tagID, ok := parseTagID(callbackParam)
+	if !ok {
+		log.Printf("handleTagListCallback: invalid tag: %s", callbackParam)
+		return nil
+	}
+	userID := cb.From.ID
+	tags, err := listTags(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing tags of user %d: %w", userID, err),
+			"Error: can't list your tags at this time",
+		)
+	}
+	i := slices.IndexFunc(tags, func(t Tag) bool { return t.ID == tagID })
+	if i == -1 {
+		return NewUserError(
+			fmt.Errorf("user %d has no tag %d", userID, tagID),
+			"That tag is gone. See /tags",
+		)
+	}
+	tag := tags[i].Name
+
+	shows, err := listShowsWithProgress(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing shows for user %d: %w", userID, err),
+			"Error: can't list shows at this time",
+		)
+	}
+	shows = filterShowsByTag(shows, tag)
+	if len(shows) == 0 {
+		handler.Bot.reply(ctx, cb.Message.Chat.ID, fmt.Sprintf("No shows are tagged %s yet.", tag))
+		handler.Bot.answerCallbackQuery(cb.ID)
+		return nil
+	}
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.ShowsList = shows
+		ctx.ListTag = tag
+	})
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, taggedShowsTitle(tag), ReplyOptions{
+		ReplyMarkup: handler.makeShowsKeyboard(shows, "tagged"),
+	})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+func (handler *Handler) handleDeleteTagCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	tagID, ok := parseTagID(callbackParam)
+	if !ok {
+		log.Printf("handleDeleteTagCallback: invalid tag: %s", callbackParam)
+		return nil
+	}
+	userID := cb.From.ID
+	if err := deleteTag(ctx, handler.DB, userID, tagID); err != nil {
+		return NewUserError(
+			fmt.Errorf("deleting tag %d of user %d: %w", tagID, userID, err),
+			"Error deleting the tag",
+		)
+	}
+	text, keyboard, err := handler.tagsMessage(ctx, userID)
+	if err != nil {
+		return err
+	}
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, text, ReplyOptions{ReplyMarkup: keyboard, EditMessageID: cb.Message.MessageID})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleShowsTagCallback narrows /shows to the tag at the index in the parameter,
+// or clears the tag when it's empty.
+func (handler *Handler) handleShowsTagCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	userID := cb.From.ID
+	userCtx := handler.Bot.getUserContext(userID)
+	if userCtx == nil {
+		return NewUserError(
+			fmt.Errorf("no shows in context for user %d", userID),
+			"No shows found. Please start over with /shows",
+		)
+	}
+	filter := userCtx.ShowsFilter
+	filter.Tag = ""
+	if callbackParam != "" {
+		idx, err := strconv.Atoi(callbackParam)
+		if err != nil || idx < 0 || idx >= len(filter.Tags) {
+			log.Printf("handleShowsTagCallback: invalid tag index: %s", callbackParam)
+			return nil
+		}
+		filter.Tag = filter.Tags[idx]
+	}
+	return handler.showFilteredShows(ctx, cb, filter)
+}
+
+// Show card
+
+// handleEditTagsCallback lists the user's tags to put on or take off the show.
+func (handler *Handler) handleEditTagsCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleEditTagsCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	show, err := handler.validateAndGetShow(ctx, cb.From.ID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+	if err := handler.showTagEditor(ctx, cb, show, showID, listType); err != nil {
+		return err
+	}
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleToggleTagCallback puts a tag on the show or takes it off.
+func (handler *Handler) handleToggleTagCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, args, ok := parseReleaseCallback(callbackParam, 1)
+	if !ok {
+		log.Printf("handleToggleTagCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	tagID, ok := parseTagID(args[0])
+	if !ok {
+		log.Printf("handleToggleTagCallback: invalid tag: %s", callbackParam)
+		return nil
+	}
+	userID := cb.From.ID
+	show, err := handler.validateAndGetShow(ctx, userID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+	tags, err := listTags(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing tags of user %d: %w", userID, err),
+			"Error updating the tags",
+		)
+	}
+	// Only the user's own tags, the callback names it by ID
+	if !slices.ContainsFunc(tags, func(t Tag) bool { return t.ID == tagID }) {
+		return NewUserError(
+			fmt.Errorf("user %d has no tag %d", userID, tagID),
+			"That tag is gone. See /tags",
+		)
+	}
+	if err := toggleShowTag(ctx, handler.DB, show.InternalID, tagID); err != nil {
+		return NewUserError(
+			fmt.Errorf("toggling tag %d for show %d: %w", tagID, show.InternalID, err),
+			"Error updating the tags",
+		)
+	}
+
+	show, listType, err = handler.reloadShowsList(ctx, userID, show, listType)
+	if err != nil {
+		return err
+	}
+	if err := handler.showTagEditor(ctx, cb, show, int(show.InternalID), listType); err != nil {
+		return err
+	}
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+func (handler *Handler) showTagEditor(ctx context.Context, cb *tgbotapi.CallbackQuery, show *ShowProgress, showID int, listType string) error {
+	tags, err := listTags(ctx, handler.DB, cb.From.ID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing tags of user %d: %w", cb.From.ID, err),
+			"Error loading your tags",
+		)
+	}
+
+	text := fmt.Sprintf("Tags of <b>%s</b>, press one to add or remove it:", html.EscapeString(show.Name))
+	if len(tags) == 0 {
+		text = fmt.Sprintf("<b>%s</b> has no tags yet, and neither do your other shows.", html.EscapeString(show.Name))
+	}
+	var rows [][][]string
+	for _, tag := range tags {
+		label := tag.Name
+		if slices.ContainsFunc(show.Tags, func(t string) bool { return strings.EqualFold(t, tag.Name) }) {
+			label = "✅ " + label
+		}
+		rows = append(rows, [][]string{{label, fmt.Sprintf("toggleTag:%d:%s:%d", showID, listType, tag.ID)}})
+	}
+	if len(tags) < maxTags {
+		rows = append(rows, [][]string{{"➕ New tag", fmt.Sprintf("newTag:%d:%s", showID, listType)}})
+	}
+	rows = append(rows, [][]string{{"<< Back", fmt.Sprintf("selectShow:%d:%s", showID, listType)}})
+
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, text, ReplyOptions{
+		ReplyMarkup: makeKeyboardMarkup(rows), ParseMode: "HTML", EditMessageID: cb.Message.MessageID,
+	})
+	return nil
+}
+
+func (handler *Handler) handleNewTagCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleNewTagCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	userID := cb.From.ID
+	chatID := cb.Message.Chat.ID
+	show, err := handler.validateAndGetShow(ctx, userID, chatID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.State = StateAwaitingTag
+		ctx.SelectedInternalID = show.InternalID
+	})
+	keyboard := makeKeyboardMarkup([][][]string{{{"❌ Cancel", "cancel"}}})
+	handler.Bot.reply(ctx,
+		chatID,
+		fmt.Sprintf("Send me a tag for \"%s\", like comfort or with partner.", show.Name),
+		ReplyOptions{ReplyMarkup: keyboard},
+	)
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+func (handler *Handler) acceptTag(ctx context.Context, msg *tgbotapi.Message) error {
+	userID := msg.From.ID
+
+	name, err := parseTagName(msg.Text)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("invalid tag from user %d: %w", userID, err),
+			fmt.Sprintf("I can't use that: %v. Please send it again, or press Cancel.", err),
+		)
+	}
+
+	userCtx := handler.Bot.getUserContext(userID)
+	if userCtx == nil || userCtx.SelectedInternalID == 0 {
+		handler.Bot.clearState(userID)
+		return NewUserError(
+			fmt.Errorf("no show selected for tag from user %d", userID),
+			"No show selected. Please start over with /shows",
+		)
+	}
+	showID := userCtx.SelectedInternalID
+	showName := "the show"
+	for _, show := range userCtx.ShowsList {
+		if show.InternalID == showID {
+			showName = fmt.Sprintf("\"%s\"", show.Name)
+			break
+		}
+	}
+
+	existing, err := findTag(ctx, handler.DB, userID, name)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("looking up tag %q of user %d: %w", name, userID, err),
+			"Error saving the tag, please try again later.",
+		)
+	}
+	if existing == nil {
+		tags, err := listTags(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("listing tags of user %d: %w", userID, err),
+				"Error saving the tag, please try again later.",
+			)
+		}
+		if len(tags) >= maxTags {
+			handler.Bot.clearState(userID)
+			return NewUserError(
+				fmt.Errorf("user %d has too many tags", userID),
+				fmt.Sprintf("You already have %d tags, delete some in /tags first.", maxTags),
+			)
+		}
+	} else {
+		// Keep the tag spelled the way it was first typed
+		name = existing.Name
+	}
+	tagID, err := createTag(ctx, handler.DB, userID, name)
+	if err == nil {
+		err = addShowTag(ctx, handler.DB, showID, tagID)
+	}
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("tagging show %d with %q: %w", showID, name, err),
+			"Error saving the tag, please try again later.",
+		)
+	}
+	handler.Bot.clearState(userID)
+	handler.Bot.reply(ctx, msg.Chat.ID, fmt.Sprintf("Tagged %s with %s. See /tags.", showName, name))
+	return nil
+}
+
+// setDigestTag limits the user's digest to shows with the tag, or lifts the limit
+// for "off".
+func (handler *Handler) setDigestTag(ctx context.Context, msg *tgbotapi.Message, settings *UserSettings, name string) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+
+	tag := ""
+	switch name {
+	case "":
+		handler.Bot.reply(ctx, chatID, "Usage: /digest tag <tag>|off")
+		return nil
+	case "off":
+	default:
+		existing, err := findTag(ctx, handler.DB, userID, name)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("looking up tag %q of user %d: %w", name, userID, err),
+				"Error: can't update your digest at this time",
+			)
+		}
+		if existing == nil {
+			handler.Bot.reply(ctx, chatID, fmt.Sprintf("You have no tag called %s, see /tags.", name))
+			return nil
+		}
+		tag = existing.Name
+	}
+
+	if err := setDigestTag(ctx, handler.DB, userID, chatID, tag); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting digest tag for user %d: %w", userID, err),
+			"Error: can't update your digest at this time",
+		)
+	}
+	settings.DigestTag = tag
+	handler.Bot.reply(ctx, chatID, describeDigest(settings))
+	return nil
+}
@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShowTags(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "1")
+	env.command("/add solo")
+	env.press("acceptShowName:1")
+
+	tag := func(showID, name string) {
+		t.Helper()
+		env.command("/shows")
+		env.press("editTags:" + showID + ":current")
+		env.press("newTag:" + showID + ":current")
+		env.text(name)
+	}
+	tag("1", "Comfort")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != `Tagged "Night Shift" with Comfort. See /tags.` {
+		t.Errorf("tagging replied %q", got)
+	}
+	tag("2", "comfort")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != `Tagged "Solo" with Comfort. See /tags.` {
+		t.Errorf("tagging with an existing tag replied %q", got)
+	}
+	tag("2", "anime, drama")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "can't have commas") {
+		t.Errorf("two tags at once replied %q", got)
+	}
+	env.text("anime")
+
+	env.command("/shows")
+	labels := env.telegram.lastMessage(t).labels(t)
+	if !slices.Equal(labels[len(labels)-3:], []string{"🏷 Comfort", "🏷 anime", "▶️ What's next?"}) {
+		t.Fatalf("/shows keyboard = %v, want a filter for each tag", labels)
+	}
+	env.press("showsTag:1")
+	list := env.telegram.lastMessage(t)
+	if got := list.Params.Get("text"); got != "Your current shows tagged anime:" {
+		t.Errorf("filtered list title = %q", got)
+	}
+	if labels := list.labels(t); !strings.HasPrefix(labels[0], "Solo") || !slices.Contains(labels, "All tags") {
+		t.Errorf("list filtered to anime = %v", labels)
+	}
+	env.press("selectShow:2:current")
+	if text := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(text, "🏷 anime, Comfort") {
+		t.Errorf("card = %q, want its tags", text)
+	}
+
+	// Taking the last tag off Night Shift leaves Solo alone in the list
+	comfortID := queryString(t, env, `SELECT id FROM tags WHERE name = 'comfort'`)
+	env.command("/shows comfort")
+	env.press("editTags:1:current")
+	if labels := env.telegram.lastMessage(t).labels(t); !slices.Contains(labels, "✅ Comfort") {
+		t.Errorf("tag editor = %v, want Comfort checked", labels)
+	}
+	env.press("toggleTag:1:current:" + comfortID)
+	if labels := env.telegram.lastMessage(t).labels(t); !slices.Contains(labels, "Comfort") {
+		t.Errorf("tag editor after untagging = %v", labels)
+	}
+
+	env.command("/tags")
+	if labels := env.telegram.lastMessage(t).labels(t); !slices.Equal(labels, []string{"🏷 anime (1)", "🗑", "🏷 Comfort (1)", "🗑"}) {
+		t.Errorf("/tags = %v", labels)
+	}
+	env.press("tagList:" + comfortID)
+	if labels := env.telegram.lastMessage(t).labels(t); len(labels) != 2 || !strings.HasPrefix(labels[0], "Solo") {
+		t.Errorf("Comfort list = %v, want only Solo", labels)
+	}
+
+	env.command("/digest weekly")
+	env.command("/digest tag anime")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "Digest: weekly, here, shows tagged anime" {
+		t.Errorf("/digest tag anime = %q", got)
+	}
+	// Night Shift's next episode airs this week, but it isn't tagged anime
+	settings, err := getUserSettings(t.Context(), env.handler.DB, testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sendDigest(t.Context(), env.handler.Bot, env.handler.DB, nil, *settings, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := env.telegram.lastMessage(t).Params.Get("text"); strings.Contains(got, "Night Shift") {
+		t.Errorf("digest of anime shows = %q", got)
+	}
+
+	animeID := queryString(t, env, `SELECT id FROM tags WHERE name = 'anime'`)
+	env.command("/tags")
+	env.press("deleteTag:" + animeID)
+	if got := queryString(t, env, `SELECT digest_tag || COUNT(*) FROM user_settings, show_tags`); got != "1" {
+		t.Errorf("digest tag and show tags after deleting = %q, want none and Comfort on Solo", got)
+	}
+}
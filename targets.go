@@ -0,0 +1,251 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// A show's reminders go to the chat it was added from, and can also go to groups
+// picked under "Also notify in…" on the show card. The bot can only offer groups it
+// has seen the user in, so every group message records the chat for its sender.
+// Extra chats are best effort, like the Discord mirror, and a group that removed
+// the bot is dropped from every show.
+
+// UserChat is a group the bot has seen the user in.
+type UserChat struct {
+	ChatID   int64
+	Title    string
+	ThreadID int
+}
+
+// ReminderTarget is an extra chat a show's reminders are sent to.
+type ReminderTarget struct {
+	ChatID   int64
+	ThreadID int
+}
+
+// rememberUserChat records that the user wrote in the group, keeping its latest
+// title and the topic they wrote in.
+func rememberUserChat(ctx context.Context, db *store.DB, userID int64, chat *tgbotapi.Chat, threadID int, seenAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO user_chats (user_id, chat_id, title, thread_id, seen_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, chat_id) DO UPDATE SET
+			title = excluded.title, thread_id = excluded.thread_id, seen_at = excluded.seen_at
+	`, userID, chat.ID, chat.Title, threadID, seenAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+func listUserChats(ctx context.Context, db *store.DB, userID int64) ([]UserChat, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT chat_id, title, thread_id FROM user_chats WHERE user_id = ? ORDER BY title, chat_id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []UserChat
+	for rows.Next() {
+		var chat UserChat
+		if err := rows.Scan(&chat.ChatID, &chat.Title, &chat.ThreadID); err != nil {
+			return nil, err
+		}
+		chats = append(chats, chat)
+	}
+	return chats, rows.Err()
+}
+
+func listReminderTargets(ctx context.Context, db QueryExecer, showID int64) ([]ReminderTarget, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT chat_id, thread_id FROM reminder_targets WHERE show_id = ? ORDER BY chat_id
+	`, showID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []ReminderTarget
+	for rows.Next() {
+		var target ReminderTarget
+		if err := rows.Scan(&target.ChatID, &target.ThreadID); err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, rows.Err()
+}
+
+// toggleReminderTarget adds the chat to the show's targets, or removes it when it
+// already is one, and reports whether it is one now.
+func toggleReminderTarget(ctx context.Context, db *store.DB, showID int64, chat UserChat) (bool, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, `
+		DELETE FROM reminder_targets WHERE show_id = ? AND chat_id = ?
+	`, showID, chat.ChatID)
+	if err != nil {
+		return false, err
+	}
+	if removed, err := result.RowsAffected(); err != nil || removed > 0 {
+		return false, err
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO reminder_targets (show_id, chat_id, thread_id) VALUES (?, ?, ?)
+	`, showID, chat.ChatID, chat.ThreadID)
+	return err == nil, err
+}
+
+// forgetChat drops a chat the bot can't post to anymore from every show and user.
+func forgetChat(ctx context.Context, db *store.DB, chatID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, `DELETE FROM reminder_targets WHERE chat_id = ?`, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `DELETE FROM user_chats WHERE chat_id = ?`, chatID)
+	return err
+}
+
+// rememberGroupSender records the group for the sender of a group message.
+func (handler *Handler) rememberGroupSender(ctx context.Context, msg *tgbotapi.Message) {
+	if msg.From == nil || (!msg.Chat.IsGroup() && !msg.Chat.IsSuperGroup()) {
+		return
+	}
+	threadID := updateThread(ctx, msg.Chat.ID)
+	if err := rememberUserChat(ctx, handler.DB, msg.From.ID, msg.Chat, threadID, handler.Clock.Now()); err != nil {
+		log.Printf("handleUpdate: remembering chat %d for user %d: %v", msg.Chat.ID, msg.From.ID, err)
+	}
+}
+
+// handleNotifyChatsCallback lists the groups the show's reminders can also go to.
+func (handler *Handler) handleNotifyChatsCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleNotifyChatsCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	progress, err := handler.validateAndGetShow(ctx, cb.From.ID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+	if err := handler.showNotifyChats(ctx, cb, progress, showID, listType); err != nil {
+		return err
+	}
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleNotifyChatCallback switches one group on or off for the show.
+func (handler *Handler) handleNotifyChatCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, args, ok := parseReleaseCallback(callbackParam, 1)
+	if !ok {
+		log.Printf("handleNotifyChatCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	chatID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		log.Printf("handleNotifyChatCallback: invalid chat: %s", callbackParam)
+		return nil
+	}
+	userID := cb.From.ID
+	progress, err := handler.validateAndGetShow(ctx, userID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+	chats, err := listUserChats(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing chats of user %d: %w", userID, err),
+			"Error loading your groups",
+		)
+	}
+	// Only chats the user was seen in, so nobody can send reminders to other groups
+	var chat *UserChat
+	for i := range chats {
+		if chats[i].ChatID == chatID {
+			chat = &chats[i]
+		}
+	}
+	if chat == nil {
+		return NewUserError(
+			fmt.Errorf("user %d isn't known in chat %d", userID, chatID),
+			"I haven't seen you in that group lately, send any command there first.",
+		)
+	}
+	if _, err := toggleReminderTarget(ctx, handler.DB, progress.InternalID, *chat); err != nil {
+		return NewUserError(
+			fmt.Errorf("toggling chat %d for show %d: %w", chatID, progress.InternalID, err),
+			"Error updating where reminders go",
+		)
+	}
+	if err := handler.showNotifyChats(ctx, cb, progress, showID, listType); err != nil {
+		return err
+	}
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+func (handler *Handler) showNotifyChats(ctx context.Context, cb *tgbotapi.CallbackQuery, progress *ShowProgress, showID int, listType string) error {
+	chats, err := listUserChats(ctx, handler.DB, cb.From.ID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing chats of user %d: %w", cb.From.ID, err),
+			"Error loading your groups",
+		)
+	}
+	targets, err := listReminderTargets(ctx, handler.DB, progress.InternalID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing reminder targets of show %d: %w", progress.InternalID, err),
+			"Error loading your groups",
+		)
+	}
+	enabled := make(map[int64]bool, len(targets))
+	for _, target := range targets {
+		enabled[target.ChatID] = true
+	}
+
+	text := fmt.Sprintf(
+		"Reminders for <b>%s</b> go to the chat you added it from. Which groups should get them too?",
+		html.EscapeString(progress.Name),
+	)
+	var rows [][][]string
+	for _, chat := range chats {
+		label := chat.Title
+		if label == "" {
+			label = strconv.FormatInt(chat.ChatID, 10)
+		}
+		if enabled[chat.ChatID] {
+			label = "✅ " + label
+		}
+		rows = append(rows, [][]string{{label, fmt.Sprintf("notifyChat:%d:%s:%d", showID, listType, chat.ChatID)}})
+	}
+	if len(chats) == 0 {
+		text += "\n\nI don't know any of your groups yet. Add me to a group and send any command there, then come back."
+	}
+	rows = append(rows, [][]string{{"<< Back", fmt.Sprintf("selectShow:%d:%s", showID, listType)}})
+
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, text, ReplyOptions{
+		ReplyMarkup: makeKeyboardMarkup(rows), ParseMode: "HTML", EditMessageID: cb.Message.MessageID,
+	})
+	return nil
+}
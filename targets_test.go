@@ -0,0 +1,41 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestRemindersAlsoGoToPickedGroups(t *testing.T) {
+	env := dueReminderEnv(t)
+	env.command("/history")
+	env.press("selectShow:1:history")
+	env.press("notifyChats:1:history")
+	if got, want := env.telegram.lastMessage(t).labels(t), []string{"<< Back"}; !slices.Equal(got, want) {
+		t.Errorf("groups before writing in one = %v, want %v", got, want)
+	}
+
+	env.groupCommand(testGroupID, &tgbotapi.User{ID: testUserID}, "/help")
+	env.press("notifyChat:1:history:-555")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "haven't seen you") {
+		t.Errorf("picking an unknown group replied %q", got)
+	}
+	env.press("notifyChats:1:history")
+	if got, want := env.telegram.lastMessage(t).labels(t), []string{"-5001", "<< Back"}; !slices.Equal(got, want) {
+		t.Errorf("groups = %v, want %v", got, want)
+	}
+	env.press("notifyChat:1:history:-5001")
+	if got, want := env.telegram.lastMessage(t).labels(t), []string{"✅ -5001", "<< Back"}; !slices.Equal(got, want) {
+		t.Errorf("groups after picking one = %v, want %v", got, want)
+	}
+
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if countSent(env) != 2 {
+		t.Fatalf("sent %d reminders, want 2", countSent(env))
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM reminder_messages WHERE chat_id = -5001`); got != "1" {
+		t.Errorf("recorded %s reminders in the group, want 1", got)
+	}
+}
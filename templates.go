@@ -0,0 +1,187 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/reminder"
+	"tvreminder/bot/internal/store"
+)
+
+// The first line of an episode reminder can be rewritten with a template, see
+// internal/reminder. A show's own template wins over the user's from /template,
+// which wins over the operator's from REMINDER_TEMPLATE. Batches, binge mode and
+// watchlist reminders keep their own wording.
+
+// renderReminderTemplate fills a template for the reminder.
+func renderReminderTemplate(text string, r DBReminder) (string, error) {
+	data := reminder.TemplateData{
+		Show:    r.ShowName,
+		Season:  r.EpisodeSeason,
+		Episode: r.EpisodeNumber,
+		Title:   r.EpisodeTitle,
+	}
+	if !r.AiredAt.IsZero() {
+		data.AirTimeLocal = r.AiredAt.Format("15:04")
+	}
+	return reminder.Render(text, data)
+}
+
+// reminderHeadline renders the reminder's template over text, keeping text when
+// there's no template or it fails.
+func reminderHeadline(r DBReminder, text string) string {
+	tmpl := r.Template
+	if tmpl == "" {
+		tmpl = r.Templates.Episode
+	}
+	if tmpl == "" {
+		return text
+	}
+	rendered, err := renderReminderTemplate(tmpl, r)
+	if err != nil {
+		log.Printf("reminderLoop: rendering the template of reminder %d: %v", r.ID, err)
+		return text
+	}
+	return rendered
+}
+
+func setUserReminderTemplate(ctx context.Context, db *store.DB, userID, chatID int64, text string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET reminder_template = ? WHERE user_id = ?`, text, userID)
+	return err
+}
+
+func setShowReminderTemplate(ctx context.Context, db *store.DB, showID int64, text string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `UPDATE shows SET reminder_template = ? WHERE id = ?`, text, showID)
+	return err
+}
+
+// reminderTemplateHelp lists the placeholders.
+const reminderTemplateHelp = "Placeholders: {show}, {season}, {episode}, {title} and {air_time_local}."
+
+// TEMPLATE command
+
+func (handler *Handler) handleTemplateCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	arg := strings.TrimSpace(msg.CommandArguments())
+
+	switch arg {
+	case "":
+		settings, err := getUserSettings(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting settings for user %d: %w", userID, err),
+				"Error reading your settings, please try again later.",
+			)
+		}
+		current := "the default"
+		if settings.ReminderTemplate != "" {
+			current = fmt.Sprintf("\"%s\"", settings.ReminderTemplate)
+		}
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf(
+			"Your reminders start with %s.\n\n%s\n\n"+
+				"/template <text> - like /template {show} S{season}E{episode} airs at {air_time_local}!\n"+
+				"/template off - back to the default\n\n"+
+				"A show can have its own text too, see its card in /shows.",
+			current, reminderTemplateHelp,
+		))
+		return nil
+	case "off":
+		arg = ""
+	default:
+		if _, err := reminder.ParseTemplate(arg); err != nil {
+			return NewUserError(
+				fmt.Errorf("invalid template from user %d: %w", userID, err),
+				fmt.Sprintf("I can't use that: %v. %s", err, reminderTemplateHelp),
+			)
+		}
+	}
+
+	if err := setUserReminderTemplate(ctx, handler.DB, userID, chatID, arg); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting template for user %d: %w", userID, err),
+			"Error saving your settings, please try again later.",
+		)
+	}
+	if arg == "" {
+		handler.Bot.reply(ctx, chatID, "Reminders are back to the default text.")
+	} else {
+		handler.Bot.reply(ctx, chatID, "Saved. Your next reminders will use it.")
+	}
+	return nil
+}
+
+// handleEditTemplateCallback asks for the show's own reminder text.
+func (handler *Handler) handleEditTemplateCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, listType, _, ok := parseReleaseCallback(callbackParam, 0)
+	if !ok {
+		log.Printf("handleEditTemplateCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	userID := cb.From.ID
+	show, err := handler.validateAndGetShow(ctx, userID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.State = StateAwaitingTemplate
+		ctx.SelectedInternalID = show.InternalID
+	})
+	keyboard := makeKeyboardMarkup([][][]string{{{"❌ Cancel", "cancel"}}})
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, fmt.Sprintf(
+		"Send me how reminders for \"%s\" should start, or \"default\" to use your usual text.\n\n%s",
+		show.Name, reminderTemplateHelp,
+	), ReplyOptions{ReplyMarkup: keyboard})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+func (handler *Handler) acceptShowTemplate(ctx context.Context, msg *tgbotapi.Message) error {
+	userID := msg.From.ID
+	text := strings.TrimSpace(msg.Text)
+	if strings.EqualFold(text, "default") {
+		text = ""
+	} else if _, err := reminder.ParseTemplate(text); err != nil {
+		return NewUserError(
+			fmt.Errorf("invalid show template from user %d: %w", userID, err),
+			fmt.Sprintf("I can't use that: %v. Please send it again, or press Cancel.", err),
+		)
+	}
+
+	userCtx := handler.Bot.getUserContext(userID)
+	if userCtx == nil || userCtx.SelectedInternalID == 0 {
+		handler.Bot.clearState(userID)
+		return NewUserError(
+			fmt.Errorf("no show selected for template from user %d", userID),
+			"No show selected. Please start over with /shows",
+		)
+	}
+	showID := userCtx.SelectedInternalID
+	if err := setShowReminderTemplate(ctx, handler.DB, showID, text); err != nil {
+		return NewUserError(
+			fmt.Errorf("saving template for show %d: %w", showID, err),
+			"Error saving the reminder text, please try again later.",
+		)
+	}
+	handler.Bot.clearState(userID)
+	if text == "" {
+		handler.Bot.reply(ctx, msg.Chat.ID, "The show's reminders are back to your usual text. See /shows.")
+	} else {
+		handler.Bot.reply(ctx, msg.Chat.ID, "Saved. The show's next reminders will use it. See /shows.")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"tvreminder/bot/internal/reminder"
+	"tvreminder/bot/internal/store"
+)
+
+func TestRenderReminderTemplate(t *testing.T) {
+	r := DBReminder{DueReminder: store.DueReminder{
+		ShowName: "Tom & Jerry", EpisodeTitle: "<Pilot>", EpisodeSeason: 2, EpisodeNumber: 5,
+		AiredAt: time.Date(2026, 3, 1, 21, 30, 0, 0, time.UTC),
+	}}
+	tests := []struct {
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{template: "{show} S{season}E{episode} \"{title}\" at {air_time_local}", want: "Tom &amp; Jerry S2E5 &#34;&lt;Pilot&gt;&#34; at 21:30"},
+		{template: "{{.Show}} {{ printf \"%s\" .Title }}", want: "{{.Show}} {{ printf &#34;%s&#34; .Title }}"},
+		{template: "New {show}! {", want: "New Tom &amp; Jerry! {"},
+		{template: "{showname} is out", wantErr: true},
+		{template: "  ", wantErr: true},
+		{template: strings.Repeat("x", reminder.MaxTemplateLength+1), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := renderReminderTemplate(tt.template, r)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("renderReminderTemplate(%q) error = %v, wantErr %v", tt.template, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("renderReminderTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestReminderTemplates(t *testing.T) {
+	env := dueReminderEnv(t)
+	env.command("/template {show} S{season}E{episode} is out today")
+	env.command("/template {oops}")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "{oops} isn't a placeholder") {
+		t.Errorf("reply = %q, want the template rejected", got)
+	}
+
+	// The show's own text wins over the user's
+	env.command("/history")
+	env.press("selectShow:1:history")
+	env.press("editTemplate:1:history")
+	env.text("{title} of {show} airs")
+	if got := queryString(t, env, `SELECT reminder_template FROM shows`); got != "{title} of {show} airs" {
+		t.Fatalf("show template = %q", got)
+	}
+
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	want := "🏁 Season 2 finale! Episode 2.3 of Night Shift airs"
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, want) {
+		t.Errorf("reminder = %q, want it to start with %q", got, want)
+	}
+}
@@ -0,0 +1,220 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// The evening summary lists what airs in the next 24 hours, once a day at an hour of
+// the user's local time. It's sent from digestLoop.
+
+// TonightOff is the TonightHour of users without the evening summary.
+const TonightOff = -1
+
+// defaultTonightHour is the local hour /tonight on picks.
+const defaultTonightHour = 18
+
+// tonightDue reports whether the user's evening summary should be sent at now: past
+// their hour, and not sent yet that day.
+func tonightDue(s UserSettings, now time.Time) bool {
+	if s.TonightHour == TonightOff {
+		return false
+	}
+	loc := s.Location()
+	local := now.In(loc)
+	if local.Hour() < s.TonightHour {
+		return false
+	}
+	if !s.LastTonightAt.Valid {
+		return true
+	}
+	last := s.LastTonightAt.Time.In(loc)
+	return last.Year() != local.Year() || last.YearDay() != local.YearDay()
+}
+
+func listTonightSubscribers(ctx context.Context, db *store.DB) ([]UserSettings, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT user_id, chat_id, timezone, tonight_hour, last_tonight_at
+		FROM user_settings
+		WHERE tonight_hour IS NOT NULL AND inactive_since IS NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscribers []UserSettings
+	for rows.Next() {
+		var settings UserSettings
+		if err := rows.Scan(
+			&settings.UserID, &settings.ChatID, &settings.Timezone, &settings.TonightHour, &settings.LastTonightAt,
+		); err != nil {
+			return nil, err
+		}
+		subscribers = append(subscribers, settings)
+	}
+	return subscribers, rows.Err()
+}
+
+// setTonightHour turns the evening summary on at the local hour, or off for TonightOff.
+func setTonightHour(ctx context.Context, db *store.DB, userID, chatID int64, hour int) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	var value any
+	if hour != TonightOff {
+		value = hour
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET tonight_hour = ? WHERE user_id = ?`, value, userID)
+	return err
+}
+
+func updateLastTonightAt(ctx context.Context, db *store.DB, userID int64, sentAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET last_tonight_at = ? WHERE user_id = ?`, sentAt, userID)
+	return err
+}
+
+// formatTonight lists episodes by local air time. Episodes airing after midnight
+// get their weekday, so "01:00" isn't mistaken for last night.
+func formatTonight(episodes []UpcomingEpisode, now time.Time, loc *time.Location) string {
+	if len(episodes) == 0 {
+		return ""
+	}
+	today := now.In(loc).YearDay()
+	var b strings.Builder
+	b.WriteString("🌙 <b>Tonight on TV</b>\n\n")
+	for i, e := range episodes {
+		if i == maxUpcomingItems {
+			fmt.Fprintf(&b, "…and %d more\n", len(episodes)-maxUpcomingItems)
+			break
+		}
+		airs := e.AiredAt.In(loc)
+		at := airs.Format("15:04")
+		if airs.YearDay() != today {
+			at = airs.Format("Mon 15:04")
+		}
+		fmt.Fprintf(&b, "%s S%02dE%02d at %s\n", html.EscapeString(e.ShowName), e.Season, e.Number, at)
+	}
+	return b.String()
+}
+
+// sendTonight sends the episodes airing in the next 24 hours. Nothing is sent when
+// nothing airs, but the day still counts as done.
+func sendTonight(ctx context.Context, bot *Bot, db *store.DB, s UserSettings, now time.Time) error {
+	episodes, err := listUpcomingEpisodes(ctx, db, s.UserID, now, now.Add(24*time.Hour))
+	if err != nil {
+		return fmt.Errorf("listing upcoming episodes: %w", err)
+	}
+	if text := formatTonight(episodes, now, s.Location()); text != "" {
+		if _, err := bot.send(ctx, s.ChatID, text, ReplyOptions{ParseMode: "HTML"}); err != nil {
+			return fmt.Errorf("sending evening summary: %w", err)
+		}
+	}
+	return updateLastTonightAt(ctx, db, s.UserID, now)
+}
+
+func sendDueTonightSummaries(ctx context.Context, bot *Bot, db *store.DB, now time.Time) {
+	subscribers, err := listTonightSubscribers(ctx, db)
+	if err != nil {
+		log.Printf("digestLoop: listTonightSubscribers error: %v", err)
+		return
+	}
+	for _, s := range subscribers {
+		if !tonightDue(s, now) {
+			continue
+		}
+		if err := sendTonight(ctx, bot, db, s, now); err != nil {
+			log.Printf("digestLoop: failed to send evening summary to user %d: %v", s.UserID, err)
+			handleTonightSendError(ctx, db, s, err, now)
+		}
+	}
+}
+
+// handleTonightSendError turns off evening summaries for chats that reject the bot,
+// like handleDigestSendError does for digests.
+func handleTonightSendError(ctx context.Context, db *store.DB, s UserSettings, sendErr error, now time.Time) {
+	if !isChatUnreachable(sendErr) {
+		return
+	}
+	if s.ChatID == s.UserID {
+		if err := markUserInactive(ctx, db, s.UserID, s.ChatID, now); err != nil {
+			log.Printf("digestLoop: failed to mark user %d inactive: %v", s.UserID, err)
+		}
+		return
+	}
+	if err := setTonightHour(ctx, db, s.UserID, s.ChatID, TonightOff); err != nil {
+		log.Printf("digestLoop: failed to disable evening summary for user %d: %v", s.UserID, err)
+	}
+}
+
+func describeTonight(settings *UserSettings) string {
+	if settings.TonightHour == TonightOff {
+		return "Evening summary: off"
+	}
+	return fmt.Sprintf("Evening summary: at %02d:00", settings.TonightHour)
+}
+
+// TONIGHT command
+
+func (handler *Handler) handleTonightCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	arg := strings.ToLower(strings.TrimSpace(msg.CommandArguments()))
+
+	settings, err := getUserSettings(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting settings for user %d: %w", userID, err),
+			"Error: can't update your evening summary at this time",
+		)
+	}
+
+	hour := settings.TonightHour
+	switch arg {
+	case "":
+		handler.Bot.reply(ctx, chatID, describeTonight(settings)+"\n\n"+dedent(`
+		/tonight on - every evening at 18:00, what airs in the next 24 hours
+		/tonight <hour> - the same at another hour, like /tonight 20
+		/tonight off - no evening summary
+		`)+fmt.Sprintf("\nTimes are in your time zone, %s (/timezone).", settings.Timezone))
+		return nil
+	case "on":
+		hour = defaultTonightHour
+	case "off":
+		hour = TonightOff
+	default:
+		hour, err = strconv.Atoi(strings.TrimSuffix(arg, ":00"))
+		if err != nil || hour < 0 || hour > 23 {
+			handler.Bot.reply(ctx, chatID, "Usage: /tonight on|off or /tonight <hour from 0 to 23>")
+			return nil
+		}
+	}
+
+	if err := setTonightHour(ctx, handler.DB, userID, chatID, hour); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting evening summary for user %d: %w", userID, err),
+			"Error: can't update your evening summary at this time",
+		)
+	}
+	settings.TonightHour = hour
+	handler.Bot.reply(ctx, chatID, describeTonight(settings))
+	return nil
+}
@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTonightSummary(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	airsAt := time.Now().Add(3 * time.Hour).UTC()
+	if _, err := env.handler.DB.Exec(`
+		UPDATE episodes_cache SET aired_at_utc = ? WHERE season = 2 AND number = 3
+	`, airsAt.Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	env.command("/tonight 0")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "Evening summary: at 00:00" {
+		t.Errorf("/tonight 0 = %q", got)
+	}
+
+	now := time.Now()
+	sendDueTonightSummaries(t.Context(), env.handler.Bot, env.handler.DB, now)
+	want := "Night Shift S02E03 at "
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "🌙 <b>Tonight on TV</b>") || !strings.Contains(got, want) {
+		t.Errorf("evening summary = %q, want it to contain %q", got, want)
+	}
+
+	sent := len(env.telegram.messages())
+	sendDueTonightSummaries(t.Context(), env.handler.Bot, env.handler.DB, now.Add(time.Minute))
+	if n := len(env.telegram.messages()); n != sent {
+		t.Errorf("sent %d more messages on the same day, want none", n-sent)
+	}
+
+	env.command("/tonight off")
+	if got := queryString(t, env, `SELECT tonight_hour FROM user_settings`); got != "NULL" {
+		t.Errorf("tonight_hour after /tonight off = %s, want NULL", got)
+	}
+}
+
+func TestTonightDue(t *testing.T) {
+	evening := time.Date(2026, 10, 19, 19, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		settings UserSettings
+		now      time.Time
+		want     bool
+	}{
+		{"off", UserSettings{TonightHour: TonightOff}, evening, false},
+		{"never sent", UserSettings{TonightHour: 18}, evening, true},
+		{"before the hour", UserSettings{TonightHour: 20}, evening, false},
+		{"sent today", UserSettings{
+			TonightHour: 18, LastTonightAt: sql.NullTime{Time: evening.Add(-time.Hour), Valid: true},
+		}, evening, false},
+		{"sent yesterday", UserSettings{
+			TonightHour: 18, LastTonightAt: sql.NullTime{Time: evening.AddDate(0, 0, -1), Valid: true},
+		}, evening, true},
+		{"local time zone", UserSettings{TonightHour: 18, Timezone: "America/New_York"}, evening, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tonightDue(tt.settings, tt.now); got != tt.want {
+				t.Errorf("tonightDue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
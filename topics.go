@@ -0,0 +1,163 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Forum topics
+//
+// The Bot API library predates topics: its Message has no message_thread_id and
+// its configs can't send one. Updates are therefore fetched raw and their topic
+// fields decoded separately, and messages for a topic are sent as hand-built
+// requests. The topic of an update from a forum travels in the context it is
+// handled with, so replies to its chat go to that topic, while loops sending to
+// the same chat aren't affected. Reminders remember the topic they were set up in.
+
+// updateTopic holds the topic fields of an update that tgbotapi.Update drops.
+type updateTopic struct {
+	Message       *topicMessage `json:"message"`
+	EditedMessage *topicMessage `json:"edited_message"`
+	CallbackQuery *struct {
+		Message *topicMessage `json:"message"`
+	} `json:"callback_query"`
+}
+
+type topicMessage struct {
+	MessageThreadID int  `json:"message_thread_id"`
+	IsTopicMessage  bool `json:"is_topic_message"`
+	Chat            struct {
+		IsForum bool `json:"is_forum"`
+	} `json:"chat"`
+}
+
+func (t updateTopic) message() *topicMessage {
+	if t.Message != nil {
+		return t.Message
+	}
+	if t.EditedMessage != nil {
+		return t.EditedMessage
+	}
+	if t.CallbackQuery != nil {
+		return t.CallbackQuery.Message
+	}
+	return nil
+}
+
+// forum reports whether the update comes from a supergroup with topics.
+func (t updateTopic) forum() bool {
+	m := t.message()
+	return m != nil && m.Chat.IsForum
+}
+
+// threadID is the topic the update was sent in. Messages in the General topic and
+// reply threads outside forums have none.
+func (t updateTopic) threadID() int {
+	if m := t.message(); m != nil && m.IsTopicMessage {
+		return m.MessageThreadID
+	}
+	return 0
+}
+
+// getUpdates long-polls for updates along with their topics and reactions.
+func (bot *Bot) getUpdates(config tgbotapi.UpdateConfig) ([]tgbotapi.Update, []rawUpdate, error) {
+	resp, err := bot.BotApi.Request(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	var updates []tgbotapi.Update
+	if err := json.Unmarshal(resp.Result, &updates); err != nil {
+		return nil, nil, fmt.Errorf("decoding updates: %w", err)
+	}
+	var raws []rawUpdate
+	if err := json.Unmarshal(resp.Result, &raws); err != nil {
+		return nil, nil, fmt.Errorf("decoding raw updates: %w", err)
+	}
+	return updates, raws, nil
+}
+
+// pollUpdates is getUpdates that gives up on the long poll when ctx is cancelled.
+// Updates of an abandoned poll are never confirmed, so Telegram hands them out
+// again after a restart.
+func (bot *Bot) pollUpdates(ctx context.Context, config tgbotapi.UpdateConfig) ([]tgbotapi.Update, []rawUpdate, error) {
+	type polled struct {
+		updates []tgbotapi.Update
+		raws    []rawUpdate
+		err     error
+	}
+	done := make(chan polled, 1)
+	go func() {
+		updates, raws, err := bot.getUpdates(config)
+		done <- polled{updates, raws, err}
+	}()
+	select {
+	case p := <-done:
+		return p.updates, p.raws, p.err
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+// sendRaw sends a request the library has no config for and decodes the message.
+func (bot *Bot) sendRaw(method string, params tgbotapi.Params) (tgbotapi.Message, error) {
+	resp, err := bot.BotApi.MakeRequest(method, params)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+	var message tgbotapi.Message
+	err = json.Unmarshal(resp.Result, &message)
+	return message, err
+}
+
+// setUpdateThread remembers the topic of a received update until it is handled.
+func (bot *Bot) setUpdateThread(updateID, threadID int) {
+	if threadID == 0 {
+		return
+	}
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+	if bot.updateThreads == nil {
+		bot.updateThreads = make(map[int]int)
+	}
+	bot.updateThreads[updateID] = threadID
+}
+
+func (bot *Bot) takeUpdateThread(updateID int) int {
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+	threadID := bot.updateThreads[updateID]
+	delete(bot.updateThreads, updateID)
+	return threadID
+}
+
+// topicKey is the context key of the chat and topic of the update being handled.
+type topicKey struct{}
+
+type updateTopicValue struct {
+	chatID   int64
+	threadID int
+}
+
+// withUpdateThread returns ctx carrying the topic of an update from the chat.
+func withUpdateThread(ctx context.Context, chatID int64, threadID int) context.Context {
+	return context.WithValue(ctx, topicKey{}, updateTopicValue{chatID, threadID})
+}
+
+// updateThread returns the topic of the update ctx handles, if it came from the
+// chat, else 0.
+func updateThread(ctx context.Context, chatID int64) int {
+	if v, ok := ctx.Value(topicKey{}).(updateTopicValue); ok && v.chatID == chatID {
+		return v.threadID
+	}
+	return 0
+}
+
+func replyThread(ctx context.Context, chatID int64, opt ReplyOptions) int {
+	if opt.ThreadID != 0 {
+		return opt.ThreadID
+	}
+	return updateThread(ctx, chatID)
+}
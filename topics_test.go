@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestUpdateTopic(t *testing.T) {
+	tests := []struct {
+		name       string
+		update     string
+		wantForum  bool
+		wantThread int
+	}{
+		{
+			name:       "topic message",
+			update:     `{"message": {"message_thread_id": 7, "is_topic_message": true, "chat": {"id": -100, "is_forum": true}}}`,
+			wantForum:  true,
+			wantThread: 7,
+		},
+		{
+			name:      "general topic",
+			update:    `{"message": {"chat": {"id": -100, "is_forum": true}}}`,
+			wantForum: true,
+		},
+		{
+			name:       "button in a topic",
+			update:     `{"callback_query": {"message": {"message_thread_id": 7, "is_topic_message": true, "chat": {"id": -100, "is_forum": true}}}}`,
+			wantForum:  true,
+			wantThread: 7,
+		},
+		{
+			name:   "reply thread outside a forum",
+			update: `{"message": {"message_thread_id": 3, "chat": {"id": -5}}}`,
+		},
+		{
+			name:   "private chat",
+			update: `{"message": {"chat": {"id": 1001}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var topic updateTopic
+			if err := json.Unmarshal([]byte(tt.update), &topic); err != nil {
+				t.Fatal(err)
+			}
+			if got := topic.forum(); got != tt.wantForum {
+				t.Errorf("forum() = %v, want %v", got, tt.wantForum)
+			}
+			if got := topic.threadID(); got != tt.wantThread {
+				t.Errorf("threadID() = %d, want %d", got, tt.wantThread)
+			}
+		})
+	}
+}
+
+func TestRemindersStayInTopic(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.ctx = withUpdateThread(t.Context(), testUserID, 7)
+	trackShow(t, env, "2")
+	if got := env.telegram.lastMessage(t).Params.Get("message_thread_id"); got != "" {
+		t.Errorf("edited message got thread %q, want none", got)
+	}
+	env.command("/help")
+	if got := env.telegram.lastMessage(t).Params.Get("message_thread_id"); got != "7" {
+		t.Errorf("reply thread = %q, want 7", got)
+	}
+	env.ctx = t.Context()
+
+	if got := queryString(t, env, `SELECT thread_id FROM reminders`); got != "7" {
+		t.Fatalf("reminder thread = %s, want 7", got)
+	}
+	env.handler.DB.Exec(`UPDATE reminders SET remind_at = ?`, time.Now().UTC().Add(-time.Minute).Format(time.RFC3339))
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if got := env.telegram.lastMessage(t).Params.Get("message_thread_id"); got != "7" {
+		t.Errorf("reminder sent to thread %q, want 7", got)
+	}
+}
+
+func TestUpdateThread(t *testing.T) {
+	ctx := withUpdateThread(t.Context(), -100, 7)
+	if got := updateThread(ctx, -100); got != 7 {
+		t.Errorf("thread of the update's chat = %d, want 7", got)
+	}
+	if got := updateThread(ctx, testUserID); got != 0 {
+		t.Errorf("thread of another chat = %d, want 0", got)
+	}
+	if got := updateThread(t.Context(), -100); got != 0 {
+		t.Errorf("thread outside an update = %d, want 0", got)
+	}
+	if got := replyThread(ctx, -100, ReplyOptions{ThreadID: 3}); got != 3 {
+		t.Errorf("explicit thread = %d, want 3", got)
+	}
+}
@@ -0,0 +1,337 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// /import also takes exports from other trackers. They don't share IDs with the
+// provider, so shows are matched by title (and year or IMDb ID when the file has
+// them), and each show's furthest watched episode becomes its progress. Two shapes
+// are understood: Simkl's JSON backup, and CSV files with a title column and
+// season and episode columns, or one holding both like "S02E05", which covers the
+// CSV exports of Simkl, MyShows and Serializd. Files listing every watched episode
+// are folded into one entry per show. Shows the user already tracks are left alone.
+
+// maxTrackerImportShows bounds the shows looked up at the provider for one file.
+const maxTrackerImportShows = 200
+
+// maxUnmatchedListed is how many unmatched titles the import reply lists.
+const maxUnmatchedListed = 15
+
+var errNotTrackerExport = errors.New("not a tracker export")
+
+// trackerEntry is a show from another tracker's export. Season and Episode are 0
+// when nothing was watched yet.
+type trackerEntry struct {
+	Title   string
+	Year    int
+	IMDB    string
+	Season  int
+	Episode int
+}
+
+// label names the entry in the import reply.
+func (e trackerEntry) label() string {
+	if e.Year != 0 {
+		return fmt.Sprintf("%s (%d)", e.Title, e.Year)
+	}
+	return e.Title
+}
+
+// trackerResult counts what a tracker import did.
+type trackerResult struct {
+	Imported int
+	// Tracked are shows the user already had.
+	Tracked   int
+	Unmatched []string
+}
+
+// parseTrackerExport reads a tracker export, returning errNotTrackerExport for
+// files it doesn't recognize or that list no shows.
+func parseTrackerExport(data []byte) ([]trackerEntry, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	var entries []trackerEntry
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		entries, err = parseSimklExport(trimmed)
+	} else {
+		entries, err = parseTrackerCSV(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries = mergeTrackerEntries(entries)
+	if len(entries) == 0 {
+		return nil, errNotTrackerExport
+	}
+	return entries, nil
+}
+
+type simklExport struct {
+	Shows []struct {
+		Show struct {
+			Title string `json:"title"`
+			Year  int    `json:"year"`
+			IDs   struct {
+				IMDB string `json:"imdb"`
+			} `json:"ids"`
+		} `json:"show"`
+		Seasons []struct {
+			Number   int `json:"number"`
+			Episodes []struct {
+				Number int `json:"number"`
+			} `json:"episodes"`
+		} `json:"seasons"`
+	} `json:"shows"`
+}
+
+func parseSimklExport(data []byte) ([]trackerEntry, error) {
+	var export simklExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, errNotTrackerExport
+	}
+	var entries []trackerEntry
+	for _, item := range export.Shows {
+		entry := trackerEntry{Title: strings.TrimSpace(item.Show.Title), Year: item.Show.Year, IMDB: item.Show.IDs.IMDB}
+		if entry.Title == "" {
+			continue
+		}
+		for _, season := range item.Seasons {
+			for _, episode := range season.Episodes {
+				entry = furthestEpisode(entry, season.Number, episode.Number)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// trackerEpisodeRe matches episodes written like "S02E05", "s2e5" or "2x05".
+var trackerEpisodeRe = regexp.MustCompile(`(?i)^s?(\d{1,3})\s*[ex]\s*(\d{1,4})$`)
+
+// trackerColumns maps the CSV header names trackers use to the entry field they hold.
+var trackerColumns = map[string]string{
+	"title": "title", "name": "title", "show": "title", "showname": "title", "showtitle": "title",
+	"series": "title", "seriesname": "title", "tvshow": "title",
+	"year": "year",
+	"imdb": "imdb", "imdbid": "imdb",
+	"season": "season", "seasonnumber": "season",
+	"episode": "episode", "episodenumber": "episode",
+	"lastepwatched": "progress", "lastepisode": "progress", "lastwatched": "progress", "progress": "progress",
+	"type": "type",
+}
+
+func parseTrackerCSV(data []byte) ([]trackerEntry, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errNotTrackerExport
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		key := strings.Map(func(r rune) rune {
+			if r == ' ' || r == '_' || r == '-' {
+				return -1
+			}
+			return r
+		}, strings.ToLower(strings.TrimSpace(name)))
+		if field, ok := trackerColumns[key]; ok {
+			if _, seen := columns[field]; !seen {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errNotTrackerExport
+	}
+
+	var entries []trackerEntry
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errNotTrackerExport
+		}
+		get := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		switch strings.ToLower(get("type")) {
+		case "movie", "movies", "film":
+			continue
+		}
+		entry := trackerEntry{Title: get("title"), IMDB: get("imdb")}
+		if entry.Title == "" {
+			continue
+		}
+		entry.Year, _ = strconv.Atoi(get("year"))
+		if m := trackerEpisodeRe.FindStringSubmatch(get("progress")); m != nil {
+			season, _ := strconv.Atoi(m[1])
+			number, _ := strconv.Atoi(m[2])
+			entry = furthestEpisode(entry, season, number)
+		}
+		if m := trackerEpisodeRe.FindStringSubmatch(get("episode")); m != nil {
+			season, _ := strconv.Atoi(m[1])
+			number, _ := strconv.Atoi(m[2])
+			entry = furthestEpisode(entry, season, number)
+		} else if season, err := strconv.Atoi(get("season")); err == nil {
+			if number, err := strconv.Atoi(get("episode")); err == nil {
+				entry = furthestEpisode(entry, season, number)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// furthestEpisode moves the entry's progress to the episode when it's further.
+// Specials don't count.
+func furthestEpisode(entry trackerEntry, season, number int) trackerEntry {
+	if season <= 0 || number <= 0 {
+		return entry
+	}
+	if season > entry.Season || (season == entry.Season && number > entry.Episode) {
+		entry.Season, entry.Episode = season, number
+	}
+	return entry
+}
+
+// mergeTrackerEntries folds entries of the same show into one with the furthest
+// progress, keeping the order shows first appear in.
+func mergeTrackerEntries(entries []trackerEntry) []trackerEntry {
+	var merged []trackerEntry
+	index := make(map[string]int)
+	for _, entry := range entries {
+		key := fmt.Sprintf("%s|%d", normalizeShowName(entry.Title), entry.Year)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, entry)
+			continue
+		}
+		if merged[i].IMDB == "" {
+			merged[i].IMDB = entry.IMDB
+		}
+		merged[i] = furthestEpisode(merged[i], entry.Season, entry.Episode)
+	}
+	return merged
+}
+
+// pickTrackerMatch picks the search result that is the entry's show: the one with
+// its IMDb ID, otherwise the first one with its exact title, from its year when
+// known. nil when none fits.
+func pickTrackerMatch(entry trackerEntry, results []ShowSearchResult) *ShowSearchResult {
+	if entry.IMDB != "" {
+		for i, result := range results {
+			if result.Externals != nil && strings.EqualFold(result.Externals.IMDB, entry.IMDB) {
+				return &results[i]
+			}
+		}
+	}
+	title := normalizeShowName(entry.Title)
+	for i, result := range results {
+		if normalizeShowName(result.Name) != title {
+			continue
+		}
+		if entry.Year != 0 && !strings.HasPrefix(safeString(result.Premiered), strconv.Itoa(entry.Year)) {
+			continue
+		}
+		return &results[i]
+	}
+	return nil
+}
+
+// importTrackerEntry adds one tracker entry, reporting whether the user already
+// tracked it. Entries the provider has no match for fail with errNoShowMatch.
+func (handler *Handler) importTrackerEntry(ctx context.Context, userID, chatID int64, entry trackerEntry) (bool, error) {
+	searchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	results, err := handler.Provider.SearchShow(searchCtx, entry.Title)
+	cancel()
+	if err != nil {
+		return false, fmt.Errorf("searching show: %w", err)
+	}
+	match := pickTrackerMatch(entry, results)
+	if match == nil {
+		return false, errNoShowMatch
+	}
+	tracked, err := userTracksShow(ctx, handler.DB, userID, handler.Provider.Name(), match.ID)
+	if err != nil || tracked {
+		return tracked, err
+	}
+
+	show := ExportedShow{
+		Name:                 match.Name,
+		Provider:             handler.Provider.Name(),
+		ProviderShowID:       strconv.Itoa(match.ID),
+		NotificationsEnabled: true,
+		Network:              match.NetworkName(),
+		PosterURL:            match.Image.URL(),
+	}
+	if entry.Season > 0 {
+		show.Season, show.Episode = &entry.Season, &entry.Episode
+	}
+	return false, handler.importShow(ctx, userID, chatID, show)
+}
+
+// importTrackerEntries adds the shows of a tracker export, up to maxTrackerImportShows.
+func (handler *Handler) importTrackerEntries(ctx context.Context, userID, chatID int64, entries []trackerEntry) *trackerResult {
+	var result trackerResult
+	for i, entry := range entries {
+		if i == maxTrackerImportShows {
+			for _, rest := range entries[i:] {
+				result.Unmatched = append(result.Unmatched, rest.label())
+			}
+			break
+		}
+		tracked, err := handler.importTrackerEntry(ctx, userID, chatID, entry)
+		switch {
+		case err != nil:
+			if !errors.Is(err, errNoShowMatch) {
+				log.Printf("importTrackerEntries: importing %q for user %d: %v", entry.Title, userID, err)
+			}
+			result.Unmatched = append(result.Unmatched, entry.label())
+		case tracked:
+			result.Tracked++
+		default:
+			result.Imported++
+		}
+	}
+	return &result
+}
+
+func formatTrackerResult(result *trackerResult) string {
+	text := fmt.Sprintf("Imported %s.", pluralize(result.Imported, "show"))
+	if result.Tracked > 0 {
+		text += fmt.Sprintf(" Skipped %s already on your list.", pluralize(result.Tracked, "show"))
+	}
+	if n := len(result.Unmatched); n > 0 {
+		text += fmt.Sprintf("\n\nI couldn't find %s, add them with /add:\n", pluralize(n, "show"))
+		for i, title := range result.Unmatched {
+			if i == maxUnmatchedListed {
+				text += fmt.Sprintf("…and %d more\n", n-maxUnmatchedListed)
+				break
+			}
+			text += "• " + title + "\n"
+		}
+	}
+	return strings.TrimRight(text, "\n") + "\n\nSee /shows."
+}
@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseTrackerExport(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []trackerEntry
+	}{
+		{
+			name: "csv with a row per episode",
+			data: "Show Name,Season,Episode\nSeverance,1,2\nSeverance,2,1\nSeverance,1,9\nThe Bear,0,1\n",
+			want: []trackerEntry{{Title: "Severance", Season: 2, Episode: 1}, {Title: "The Bear"}},
+		},
+		{
+			name: "csv with the last episode and movies",
+			data: "\ufeffSIMKL_ID,Title,Type,Year,LastEpWatched,IMDB\n1,Severance,tv,2022,s2e5,tt11280740\n2,Dune,movie,2021,,\n",
+			want: []trackerEntry{{Title: "Severance", Year: 2022, IMDB: "tt11280740", Season: 2, Episode: 5}},
+		},
+		{
+			name: "simkl json",
+			data: `{"shows": [{"show": {"title": "Severance", "year": 2022, "ids": {"imdb": "tt11280740"}},
+				"seasons": [{"number": 1, "episodes": [{"number": 1}, {"number": 9}]}, {"number": 2, "episodes": [{"number": 3}]}]}]}`,
+			want: []trackerEntry{{Title: "Severance", Year: 2022, IMDB: "tt11280740", Season: 2, Episode: 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTrackerExport([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("entries = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	for _, data := range []string{"hello", `{"version": 99, "shows": []}`, "Season,Episode\n1,2\n"} {
+		if _, err := parseTrackerExport([]byte(data)); err != errNotTrackerExport {
+			t.Errorf("parseTrackerExport(%q) error = %v, want errNotTrackerExport", data, err)
+		}
+	}
+}
+
+func TestTrackerImport(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.command("/add solo")
+	env.press("acceptShowName:1")
+
+	env.command("/import")
+	csv := "Title,Season,Episode\nNight Shift,1,2\nNight Shift,2,1\nSolo,1,1\nNo Such Show,1,1\n"
+	env.document(testUserID, "myshows.csv", []byte(csv))
+
+	want := "Imported 1 show. Skipped 1 show already on your list.\n\n" +
+		"I couldn't find 1 show, add them with /add:\n• No Such Show\n\nSee /shows."
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+	progress := `SELECT e.season || 'x' || e.number FROM shows s JOIN episodes_cache e ON e.id = s.last_watched_episode_id WHERE s.name = 'Night Shift'`
+	if got := queryString(t, env, progress); got != "2x1" {
+		t.Errorf("progress = %s, want 2x1", got)
+	}
+}
@@ -0,0 +1,279 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"tvreminder/bot/internal/provider"
+)
+
+const traktBaseURL = "https://api.trakt.tv"
+
+// traktHistoryPages bounds how many pages of history one pull reads. History comes
+// newest first, so a first pull of a long history still sees every show's latest
+// watch unless the user binge-watched thousands of episodes since.
+const (
+	traktHistoryPageSize = 100
+	traktHistoryPages    = 10
+)
+
+var (
+	// errTraktPending means the user hasn't approved the device code yet.
+	errTraktPending = errors.New("trakt: authorization pending")
+	// errTraktUnauthorized means the user's tokens were revoked or expired.
+	errTraktUnauthorized = errors.New("trakt: unauthorized")
+)
+
+// Trakt is a client of the Trakt API, used to sync watched progress both ways.
+// Users connect their account with the device flow, see handleTraktCommand.
+type Trakt struct {
+	BaseURL      string
+	ClientID     string
+	ClientSecret string
+	Client       *http.Client
+}
+
+// NewTrakt returns a Trakt client for the app. An empty baseURL means the public API.
+func NewTrakt(baseURL, clientID, clientSecret string) *Trakt {
+	if baseURL == "" {
+		baseURL = traktBaseURL
+	}
+	return &Trakt{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Client:       provider.Client("trakt"),
+	}
+}
+
+// TraktIDs identify a show on Trakt. The bot matches shows by their TheTVDB or
+// IMDb ID, which both TVmaze and TheTVDB know.
+type TraktIDs struct {
+	Trakt int    `json:"trakt,omitempty"`
+	TVDB  int    `json:"tvdb,omitempty"`
+	IMDB  string `json:"imdb,omitempty"`
+}
+
+func (ids TraktIDs) empty() bool {
+	return ids.TVDB == 0 && ids.IMDB == ""
+}
+
+// TraktToken is an OAuth token pair of a connected user.
+type TraktToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+func (t TraktToken) ExpiresAt() time.Time {
+	return time.Unix(t.CreatedAt+t.ExpiresIn, 0)
+}
+
+// TraktDeviceCode is what the user needs to approve the bot on trakt.tv.
+type TraktDeviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURL string `json:"verification_url"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+// TraktHistoryItem is one watched episode in the user's Trakt history.
+type TraktHistoryItem struct {
+	WatchedAt time.Time `json:"watched_at"`
+	Episode   struct {
+		Season int `json:"season"`
+		Number int `json:"number"`
+	} `json:"episode"`
+	Show struct {
+		Title string   `json:"title"`
+		IDs   TraktIDs `json:"ids"`
+	} `json:"show"`
+}
+
+// TraktWatch is a watched episode to add to the user's Trakt history.
+type TraktWatch struct {
+	Show      TraktIDs
+	Season    int
+	Number    int
+	WatchedAt time.Time
+}
+
+// DeviceCode starts the device flow.
+func (t *Trakt) DeviceCode(ctx context.Context) (*TraktDeviceCode, error) {
+	var code TraktDeviceCode
+	status, err := t.do(ctx, http.MethodPost, "/oauth/device/code", "", map[string]string{
+		"client_id": t.ClientID,
+	}, &code)
+	if err != nil {
+		return nil, fmt.Errorf("trakt device code: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("trakt device code: status %d", status)
+	}
+	return &code, nil
+}
+
+// PollDeviceToken exchanges an approved device code for tokens. It returns
+// errTraktPending until the user has approved it.
+func (t *Trakt) PollDeviceToken(ctx context.Context, deviceCode string) (*TraktToken, error) {
+	var token TraktToken
+	status, err := t.do(ctx, http.MethodPost, "/oauth/device/token", "", map[string]string{
+		"code":          deviceCode,
+		"client_id":     t.ClientID,
+		"client_secret": t.ClientSecret,
+	}, &token)
+	if err != nil {
+		return nil, fmt.Errorf("trakt device token: %w", err)
+	}
+	switch status {
+	case http.StatusOK:
+		return &token, nil
+	case http.StatusBadRequest, http.StatusTooManyRequests:
+		// 429 asks to slow down, which the caller's interval takes care of
+		return nil, errTraktPending
+	default:
+		// Expired, denied or already used
+		return nil, fmt.Errorf("trakt device token: status %d", status)
+	}
+}
+
+// RefreshToken trades the user's refresh token for a new token pair.
+func (t *Trakt) RefreshToken(ctx context.Context, refreshToken string) (*TraktToken, error) {
+	var token TraktToken
+	status, err := t.do(ctx, http.MethodPost, "/oauth/token", "", map[string]string{
+		"refresh_token": refreshToken,
+		"client_id":     t.ClientID,
+		"client_secret": t.ClientSecret,
+		"redirect_uri":  "urn:ietf:wg:oauth:2.0:oob",
+		"grant_type":    "refresh_token",
+	}, &token)
+	if err != nil {
+		return nil, fmt.Errorf("trakt refresh token: %w", err)
+	}
+	switch status {
+	case http.StatusOK:
+		return &token, nil
+	case http.StatusBadRequest, http.StatusUnauthorized:
+		return nil, errTraktUnauthorized
+	default:
+		return nil, fmt.Errorf("trakt refresh token: status %d", status)
+	}
+}
+
+type traktHistoryEpisode struct {
+	Number    int    `json:"number"`
+	WatchedAt string `json:"watched_at"`
+}
+
+type traktHistorySeason struct {
+	Number   int                   `json:"number"`
+	Episodes []traktHistoryEpisode `json:"episodes"`
+}
+
+type traktHistoryShow struct {
+	IDs     TraktIDs             `json:"ids"`
+	Seasons []traktHistorySeason `json:"seasons"`
+}
+
+// AddHistory adds watched episodes to the user's history.
+func (t *Trakt) AddHistory(ctx context.Context, accessToken string, watches []TraktWatch) error {
+	var shows []traktHistoryShow
+	for _, w := range watches {
+		shows = append(shows, traktHistoryShow{
+			IDs: w.Show,
+			Seasons: []traktHistorySeason{{
+				Number:   w.Season,
+				Episodes: []traktHistoryEpisode{{Number: w.Number, WatchedAt: w.WatchedAt.UTC().Format(time.RFC3339)}},
+			}},
+		})
+	}
+	status, err := t.do(ctx, http.MethodPost, "/sync/history", accessToken, map[string]any{"shows": shows}, nil)
+	if err != nil {
+		return fmt.Errorf("trakt add history: %w", err)
+	}
+	switch status {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	case http.StatusUnauthorized:
+		return errTraktUnauthorized
+	default:
+		return fmt.Errorf("trakt add history: status %d", status)
+	}
+}
+
+// History returns the episodes the user watched since startAt, newest first. A
+// zero startAt reads the whole history, up to traktHistoryPages pages.
+func (t *Trakt) History(ctx context.Context, accessToken string, startAt time.Time) ([]TraktHistoryItem, error) {
+	var history []TraktHistoryItem
+	for page := 1; page <= traktHistoryPages; page++ {
+		query := url.Values{}
+		query.Set("page", strconv.Itoa(page))
+		query.Set("limit", strconv.Itoa(traktHistoryPageSize))
+		if !startAt.IsZero() {
+			query.Set("start_at", startAt.UTC().Format(time.RFC3339))
+		}
+		var items []TraktHistoryItem
+		status, err := t.do(ctx, http.MethodGet, "/sync/history/episodes?"+query.Encode(), accessToken, nil, &items)
+		if err != nil {
+			return nil, fmt.Errorf("trakt history: %w", err)
+		}
+		switch status {
+		case http.StatusOK:
+		case http.StatusUnauthorized:
+			return nil, errTraktUnauthorized
+		default:
+			return nil, fmt.Errorf("trakt history: status %d", status)
+		}
+		history = append(history, items...)
+		if len(items) < traktHistoryPageSize {
+			break
+		}
+	}
+	return history, nil
+}
+
+// do sends a request and decodes a 2xx response into out, returning the status.
+// Other statuses are left for the caller to interpret.
+func (t *Trakt) do(ctx context.Context, method, path, accessToken string, body, out any) (int, error) {
+	var reqBody *bytes.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reqBody = bytes.NewReader(payload)
+	} else {
+		reqBody = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, t.BaseURL+path, reqBody)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("trakt-api-version", "2")
+	req.Header.Set("trakt-api-key", t.ClientID)
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 && out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
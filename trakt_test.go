@@ -0,0 +1,160 @@
+package bot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTrakt serves the device flow, and records history pushes while serving a
+// fixed history.
+type fakeTrakt struct {
+	mu      sync.Mutex
+	pushed  []traktHistoryShow
+	history []TraktHistoryItem
+}
+
+func newFakeTrakt(t *testing.T) (*fakeTrakt, *Trakt) {
+	t.Helper()
+	f := &fakeTrakt{}
+	mux := http.NewServeMux()
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer trakt-token" || r.Header.Get("trakt-api-key") != "client-id" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	mux.HandleFunc("POST /oauth/device/code", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(TraktDeviceCode{
+			DeviceCode: "device", UserCode: "ABCD1234", VerificationURL: "https://trakt.tv/activate", ExpiresIn: 600,
+		})
+	})
+	mux.HandleFunc("POST /oauth/device/token", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(TraktToken{
+			AccessToken: "trakt-token", RefreshToken: "refresh", ExpiresIn: 7776000, CreatedAt: time.Now().Unix(),
+		})
+	})
+	mux.HandleFunc("POST /sync/history", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		var body struct {
+			Shows []traktHistoryShow `json:"shows"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		f.pushed = append(f.pushed, body.Shows...)
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("GET /sync/history/episodes", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		json.NewEncoder(w).Encode(f.history)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return f, NewTrakt(server.URL, "client-id", "client-secret")
+}
+
+func (f *fakeTrakt) watched(tvdb, season, number int, at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var item TraktHistoryItem
+	item.WatchedAt = at
+	item.Show.IDs = TraktIDs{TVDB: tvdb}
+	item.Episode.Season = season
+	item.Episode.Number = number
+	f.history = append([]TraktHistoryItem{item}, f.history...)
+}
+
+// newTraktEnv returns a test env whose user has connected Trakt, with Night Shift
+// known on TheTVDB as 555.
+func newTraktEnv(t *testing.T) (*testEnv, *fakeTrakt) {
+	t.Helper()
+	shows := testShows()
+	shows[0].Externals = &Externals{TheTVDB: 555}
+	env := newTestEnv(t, shows...)
+	fake, trakt := newFakeTrakt(t)
+	env.handler.Trakt = trakt
+
+	env.command("/trakt connect")
+	env.handler.traktJobs.wait()
+	if text := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(text, "Trakt connected") {
+		t.Fatalf("after approving the code got %q", text)
+	}
+	return env, fake
+}
+
+func TestTraktConnect(t *testing.T) {
+	env, _ := newTraktEnv(t)
+
+	account, err := getTraktAccount(t.Context(), env.handler.DB, testUserID)
+	if err != nil || account == nil {
+		t.Fatalf("getTraktAccount = %v, %v, want the connected account", account, err)
+	}
+	if account.AccessToken != "trakt-token" {
+		t.Errorf("access token = %q, want trakt-token", account.AccessToken)
+	}
+
+	env.command("/trakt off")
+	if account, err := getTraktAccount(t.Context(), env.handler.DB, testUserID); err != nil || account != nil {
+		t.Errorf("getTraktAccount after /trakt off = %v, %v, want nil", account, err)
+	}
+}
+
+func TestTraktPushesWatchedEpisodes(t *testing.T) {
+	env, fake := newTraktEnv(t)
+	trackShow(t, env, "2")
+
+	syncTrakt(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Trakt, env.handler.Provider, time.Now(), false)
+
+	if len(fake.pushed) != 1 {
+		t.Fatalf("pushed %d shows, want 1", len(fake.pushed))
+	}
+	show := fake.pushed[0]
+	if show.IDs.TVDB != 555 || len(show.Seasons) != 1 || show.Seasons[0].Number != 2 ||
+		show.Seasons[0].Episodes[0].Number != 2 {
+		t.Errorf("pushed %+v, want S02E02 of TVDB show 555", show)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM trakt_pushes`); got != "0" {
+		t.Errorf("%s pushes left after syncing, want 0", got)
+	}
+}
+
+func TestTraktPullPrefersMostRecent(t *testing.T) {
+	env, fake := newTraktEnv(t)
+	trackShow(t, env, "1")
+	db := env.handler.DB
+	watchedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	db.Exec(`UPDATE shows SET last_watched_at = ?`, watchedAt.Format(time.RFC3339))
+	db.Exec(`DELETE FROM trakt_pushes`)
+
+	// Watched on Trakt before the bot's progress: the bot keeps S02E01
+	fake.watched(555, 1, 3, watchedAt.Add(-time.Minute))
+	syncTrakt(t.Context(), env.handler.Bot, db, env.handler.Trakt, env.handler.Provider, time.Now(), true)
+	progress := `SELECT e.season || 'x' || e.number FROM shows s JOIN episodes_cache e ON e.id = s.last_watched_episode_id`
+	if got := queryString(t, env, progress); got != "2x1" {
+		t.Errorf("progress after an older Trakt watch = %s, want 2x1", got)
+	}
+
+	// Watched on Trakt since: the bot moves to it, without pushing it back
+	db.Exec(`UPDATE user_settings SET trakt_synced_at = NULL`)
+	fake.watched(555, 2, 3, watchedAt.Add(time.Minute))
+	syncTrakt(t.Context(), env.handler.Bot, db, env.handler.Trakt, env.handler.Provider, time.Now(), true)
+	if got := queryString(t, env, progress); got != "2x3" {
+		t.Errorf("progress after a newer Trakt watch = %s, want 2x3", got)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM trakt_pushes`); got != "0" {
+		t.Errorf("%s pushes queued by a pull, want 0", got)
+	}
+}
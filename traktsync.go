@@ -0,0 +1,588 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/reminder"
+	"tvreminder/bot/internal/store"
+)
+
+// Trakt sync runs both ways. Episodes marked watched in the bot are queued in
+// trakt_pushes and added to the user's Trakt history by traktLoop. The loop also
+// pulls the history every traktPullInterval and moves a show's progress to the
+// latest episode watched on Trakt, but only when that watch is more recent than
+// the bot's own progress: the most recent event wins, wherever it happened.
+
+const (
+	// traktPushInterval is how soon a watch shows up on Trakt.
+	traktPushInterval = 10 * time.Second
+	traktPullInterval = 15 * time.Minute
+	// traktRefreshMargin is how long before expiry tokens are refreshed.
+	traktRefreshMargin = 24 * time.Hour
+)
+
+const maxTraktPushAttempts = 5
+
+// TraktAccount is a user's connected Trakt account.
+type TraktAccount struct {
+	UserID       int64
+	ChatID       int64
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+	// SyncedAt is when the history was last pulled, invalid before the first pull.
+	SyncedAt sql.NullTime
+}
+
+const traktAccountColumns = `
+	user_id, chat_id, trakt_access_token, trakt_refresh_token, trakt_expires_at, trakt_synced_at
+`
+
+func scanTraktAccount(row interface{ Scan(...any) error }) (*TraktAccount, error) {
+	var account TraktAccount
+	err := row.Scan(
+		&account.UserID, &account.ChatID, &account.AccessToken, &account.RefreshToken,
+		&account.ExpiresAt, &account.SyncedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// connectTrakt saves the tokens of a newly connected account. The next pull reads
+// the whole history.
+func connectTrakt(ctx context.Context, db *store.DB, userID, chatID int64, token *TraktToken) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE user_settings
+		SET trakt_access_token = ?, trakt_refresh_token = ?, trakt_expires_at = ?, trakt_synced_at = NULL
+		WHERE user_id = ?
+	`, token.AccessToken, token.RefreshToken, token.ExpiresAt().UTC().Format(time.RFC3339), userID)
+	return err
+}
+
+// saveTraktToken replaces the user's tokens after a refresh.
+func saveTraktToken(ctx context.Context, db *store.DB, userID int64, token *TraktToken) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE user_settings SET trakt_access_token = ?, trakt_refresh_token = ?, trakt_expires_at = ?
+		WHERE user_id = ?
+	`, token.AccessToken, token.RefreshToken, token.ExpiresAt().UTC().Format(time.RFC3339), userID)
+	return err
+}
+
+// disconnectTrakt forgets the user's tokens and drops the watches not pushed yet.
+func disconnectTrakt(ctx context.Context, db *store.DB, userID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE user_settings
+		SET trakt_access_token = '', trakt_refresh_token = '', trakt_expires_at = NULL, trakt_synced_at = NULL
+		WHERE user_id = ?
+	`, userID)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `DELETE FROM trakt_pushes WHERE user_id = ?`, userID)
+	return err
+}
+
+func setTraktSyncedAt(ctx context.Context, db *store.DB, userID int64, syncedAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE user_settings SET trakt_synced_at = ? WHERE user_id = ?
+	`, syncedAt.UTC().Format(time.RFC3339), userID)
+	return err
+}
+
+// getTraktAccount returns the user's Trakt account, nil when they haven't connected one.
+func getTraktAccount(ctx context.Context, db *store.DB, userID int64) (*TraktAccount, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	account, err := scanTraktAccount(db.QueryRowContext(ctx, `
+		SELECT `+traktAccountColumns+` FROM user_settings
+		WHERE user_id = ? AND trakt_refresh_token != ''
+	`, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return account, err
+}
+
+// listTraktAccounts returns the connected accounts of active users. With
+// pushesOnly, only those with watches due for pushing at now.
+func listTraktAccounts(ctx context.Context, db *store.DB, now time.Time, pushesOnly bool) ([]TraktAccount, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+traktAccountColumns+` FROM user_settings us
+		WHERE trakt_refresh_token != '' AND inactive_since IS NULL
+		AND (? = 0 OR EXISTS (
+			SELECT 1 FROM trakt_pushes p WHERE p.user_id = us.user_id AND p.next_attempt_at <= ?
+		))
+		ORDER BY user_id
+	`, pushesOnly, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []TraktAccount
+	for rows.Next() {
+		account, err := scanTraktAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, rows.Err()
+}
+
+// enqueueTraktPush queues an episode just watched at watchedAt for the owner's Trakt
+// history, due for pushing right away. It does nothing for users without a
+// connected account.
+func enqueueTraktPush(ctx context.Context, db *store.DB, showID, episodeID int64, watchedAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO trakt_pushes (user_id, show_id, episode_id, watched_at, next_attempt_at)
+		SELECT s.user_id, s.id, ?, ?, ?
+		FROM shows s
+		JOIN user_settings us ON us.user_id = s.user_id
+		WHERE s.id = ? AND us.trakt_refresh_token != ''
+	`, episodeID, watchedAt.UTC().Format(time.RFC3339), watchedAt.UTC().Format(time.RFC3339), showID)
+	return err
+}
+
+type traktPush struct {
+	ID             int64
+	Provider       string
+	ProviderShowID string
+	Season         int
+	Number         int
+	WatchedAt      time.Time
+	Attempts       int
+}
+
+func listDueTraktPushes(ctx context.Context, db *store.DB, userID int64, now time.Time) ([]traktPush, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, s.provider, s.provider_show_id, e.season, e.number, p.watched_at, p.attempts
+		FROM trakt_pushes p
+		JOIN shows s ON s.id = p.show_id
+		JOIN episodes_cache e ON e.id = p.episode_id
+		WHERE p.user_id = ? AND p.next_attempt_at <= ?
+		ORDER BY p.id
+	`, userID, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pushes []traktPush
+	for rows.Next() {
+		var p traktPush
+		if err := rows.Scan(
+			&p.ID, &p.Provider, &p.ProviderShowID, &p.Season, &p.Number, &p.WatchedAt, &p.Attempts,
+		); err != nil {
+			return nil, err
+		}
+		pushes = append(pushes, p)
+	}
+	return pushes, rows.Err()
+}
+
+func deleteTraktPush(ctx context.Context, db *store.DB, pushID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `DELETE FROM trakt_pushes WHERE id = ?`, pushID)
+	return err
+}
+
+func markTraktPushFailed(ctx context.Context, db *store.DB, pushID int64, attempts int, nextAttemptAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE trakt_pushes SET attempts = ?, next_attempt_at = ? WHERE id = ?
+	`, attempts, nextAttemptAt.UTC().Format(time.RFC3339), pushID)
+	return err
+}
+
+// showTraktIDs returns the IDs Trakt knows a show by. Empty IDs mean the show
+// can't be matched.
+func showTraktIDs(ctx context.Context, db *store.DB, provider Provider, providerName, providerShowID string) (TraktIDs, error) {
+	ids, err := getExternalIDs(ctx, db, provider, providerName, providerShowID)
+	if err != nil {
+		return TraktIDs{}, err
+	}
+	return TraktIDs{TVDB: ids.TVDB, IMDB: ids.IMDB}, nil
+}
+
+// traktSyncShow is a show whose progress a pull may move.
+type traktSyncShow struct {
+	ID             int64
+	Provider       string
+	ProviderShowID string
+	LastWatchedAt  sql.NullTime
+}
+
+// listTraktSyncShows returns the user's shows that track progress, which leaves out
+// trashed and watchlisted ones.
+func listTraktSyncShows(ctx context.Context, db *store.DB, userID int64) ([]traktSyncShow, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, provider, provider_show_id, last_watched_at FROM shows
+		WHERE user_id = ? AND deleted_at IS NULL AND reminder_mode != ?
+		ORDER BY id
+	`, userID, ReminderModeWatchlist)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shows []traktSyncShow
+	for rows.Next() {
+		var show traktSyncShow
+		if err := rows.Scan(&show.ID, &show.Provider, &show.ProviderShowID, &show.LastWatchedAt); err != nil {
+			return nil, err
+		}
+		shows = append(shows, show)
+	}
+	return shows, rows.Err()
+}
+
+// refreshTraktToken renews the account's tokens when they're about to expire.
+func refreshTraktToken(ctx context.Context, db *store.DB, trakt *Trakt, account *TraktAccount, now time.Time) error {
+	if account.ExpiresAt.Sub(now) > traktRefreshMargin {
+		return nil
+	}
+	token, err := trakt.RefreshToken(ctx, account.RefreshToken)
+	if err != nil {
+		return err
+	}
+	if err := saveTraktToken(ctx, db, account.UserID, token); err != nil {
+		return err
+	}
+	account.AccessToken = token.AccessToken
+	account.RefreshToken = token.RefreshToken
+	account.ExpiresAt = token.ExpiresAt()
+	return nil
+}
+
+// pushTraktHistory adds the account's due watches to its Trakt history in one
+// request. Failed pushes are retried with backoff and dropped after
+// maxTraktPushAttempts, as are watches of shows Trakt can't match.
+func pushTraktHistory(ctx context.Context, db *store.DB, trakt *Trakt, provider Provider, account TraktAccount, now time.Time) error {
+	pushes, err := listDueTraktPushes(ctx, db, account.UserID, now)
+	if err != nil {
+		return fmt.Errorf("listing pushes: %w", err)
+	}
+
+	var watches []TraktWatch
+	var batch []traktPush
+	for _, p := range pushes {
+		ids, err := showTraktIDs(ctx, db, provider, p.Provider, p.ProviderShowID)
+		if err != nil {
+			log.Printf("traktLoop: IDs of %s show %s: %v", p.Provider, p.ProviderShowID, err)
+			continue
+		}
+		if ids.empty() {
+			log.Printf("traktLoop: dropping push %d, %s show %s has no IDs Trakt knows", p.ID, p.Provider, p.ProviderShowID)
+			if err := deleteTraktPush(ctx, db, p.ID); err != nil {
+				log.Printf("traktLoop: deleting push %d: %v", p.ID, err)
+			}
+			continue
+		}
+		watches = append(watches, TraktWatch{Show: ids, Season: p.Season, Number: p.Number, WatchedAt: p.WatchedAt})
+		batch = append(batch, p)
+	}
+	if len(watches) == 0 {
+		return nil
+	}
+
+	pushErr := trakt.AddHistory(ctx, account.AccessToken, watches)
+	for _, p := range batch {
+		if pushErr == nil {
+			if err := deleteTraktPush(ctx, db, p.ID); err != nil {
+				log.Printf("traktLoop: deleting push %d: %v", p.ID, err)
+			}
+			continue
+		}
+		attempts := p.Attempts + 1
+		if attempts >= maxTraktPushAttempts {
+			log.Printf("traktLoop: push %d failed %d times, giving up", p.ID, attempts)
+			if err := deleteTraktPush(ctx, db, p.ID); err != nil {
+				log.Printf("traktLoop: deleting push %d: %v", p.ID, err)
+			}
+			continue
+		}
+		if err := markTraktPushFailed(ctx, db, p.ID, attempts, now.Add(reminderBackoff(attempts))); err != nil {
+			log.Printf("traktLoop: recording push %d failure: %v", p.ID, err)
+		}
+	}
+	return pushErr
+}
+
+// pullTraktHistory moves the progress of each of the user's shows to the latest
+// episode watched on Trakt since the last pull, when that watch is more recent
+// than the bot's progress.
+func pullTraktHistory(ctx context.Context, db *store.DB, trakt *Trakt, provider Provider, account TraktAccount, now time.Time) error {
+	var since time.Time
+	if account.SyncedAt.Valid {
+		since = account.SyncedAt.Time
+	}
+	history, err := trakt.History(ctx, account.AccessToken, since)
+	if err != nil {
+		return err
+	}
+
+	latestByTVDB := make(map[int]TraktHistoryItem)
+	latestByIMDB := make(map[string]TraktHistoryItem)
+	for _, item := range history {
+		if id := item.Show.IDs.TVDB; id != 0 {
+			if latest, ok := latestByTVDB[id]; !ok || item.WatchedAt.After(latest.WatchedAt) {
+				latestByTVDB[id] = item
+			}
+		}
+		if id := item.Show.IDs.IMDB; id != "" {
+			if latest, ok := latestByIMDB[id]; !ok || item.WatchedAt.After(latest.WatchedAt) {
+				latestByIMDB[id] = item
+			}
+		}
+	}
+
+	if len(history) > 0 {
+		shows, err := listTraktSyncShows(ctx, db, account.UserID)
+		if err != nil {
+			return fmt.Errorf("listing shows: %w", err)
+		}
+		for _, show := range shows {
+			ids, err := showTraktIDs(ctx, db, provider, show.Provider, show.ProviderShowID)
+			if err != nil {
+				log.Printf("traktLoop: IDs of %s show %s: %v", show.Provider, show.ProviderShowID, err)
+				continue
+			}
+			var latest TraktHistoryItem
+			if item, ok := latestByTVDB[ids.TVDB]; ok && ids.TVDB != 0 {
+				latest = item
+			}
+			if item, ok := latestByIMDB[ids.IMDB]; ok && ids.IMDB != "" && item.WatchedAt.After(latest.WatchedAt) {
+				latest = item
+			}
+			if latest.WatchedAt.IsZero() {
+				continue
+			}
+			if show.LastWatchedAt.Valid && !latest.WatchedAt.After(show.LastWatchedAt.Time) {
+				// The bot's progress is as recent, e.g. it's our own push coming back
+				continue
+			}
+			episode, err := findEpisodeByNumber(ctx, db, show.Provider, show.ProviderShowID, latest.Episode.Season, latest.Episode.Number)
+			if err != nil {
+				log.Printf("traktLoop: show %d has no S%02dE%02d: %v", show.ID, latest.Episode.Season, latest.Episode.Number, err)
+				continue
+			}
+			if err := setLastWatchedEpisode(ctx, db, show.ID, episode.ID, latest.WatchedAt, now); err != nil {
+				log.Printf("traktLoop: updating progress of show %d: %v", show.ID, err)
+			}
+		}
+	}
+	return setTraktSyncedAt(ctx, db, account.UserID, now)
+}
+
+// syncTrakt is one tick of traktLoop: it pushes due watches and, when pull is set,
+// pulls every account's history. Accounts whose tokens were revoked are
+// disconnected and their owners told.
+func syncTrakt(ctx context.Context, bot *Bot, db *store.DB, trakt *Trakt, provider Provider, now time.Time, pull bool) {
+	accounts, err := listTraktAccounts(ctx, db, now, !pull)
+	if err != nil {
+		log.Printf("traktLoop: listing accounts: %v", err)
+		return
+	}
+	for _, account := range accounts {
+		err := refreshTraktToken(ctx, db, trakt, &account, now)
+		if err == nil {
+			err = pushTraktHistory(ctx, db, trakt, provider, account, now)
+		}
+		if err == nil && pull {
+			err = pullTraktHistory(ctx, db, trakt, provider, account, now)
+		}
+		if errors.Is(err, errTraktUnauthorized) {
+			log.Printf("traktLoop: Trakt access of user %d was revoked, disconnecting", account.UserID)
+			if err := disconnectTrakt(ctx, db, account.UserID); err != nil {
+				log.Printf("traktLoop: disconnecting user %d: %v", account.UserID, err)
+			}
+			bot.reply(ctx, account.ChatID, "Your Trakt account got disconnected. Use /trakt connect to sync again.")
+		} else if err != nil {
+			log.Printf("traktLoop: syncing user %d: %v", account.UserID, err)
+		}
+	}
+}
+
+// traktLoop syncs with Trakt every traktPushInterval, pulling progress every
+// traktPullInterval. Pulled progress moves reminders, so each sync wakes reminders.
+func traktLoop(bot *Bot, db *store.DB, trakt *Trakt, provider Provider, reminders *reminder.Scheduler, clk clock.Clock, ctx context.Context) {
+	ticker := time.NewTicker(traktPushInterval)
+	defer ticker.Stop()
+
+	var lastPull time.Time
+	for {
+		select {
+		case <-ticker.C:
+			now := clk.Now()
+			pull := now.Sub(lastPull) >= traktPullInterval
+			if pull {
+				lastPull = now
+			}
+			syncTrakt(ctx, bot, db, trakt, provider, now, pull)
+			reminders.Wake()
+		case <-ctx.Done():
+			log.Println("traktLoop: context cancelled, exiting")
+			return
+		}
+	}
+}
+
+// TRAKT command
+
+func (handler *Handler) handleTraktCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	if handler.Trakt == nil {
+		handler.Bot.reply(ctx, chatID, "Trakt sync isn't available on this bot.")
+		return nil
+	}
+
+	switch arg := strings.TrimSpace(msg.CommandArguments()); arg {
+	case "":
+		account, err := getTraktAccount(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting Trakt account of user %d: %w", userID, err),
+				"Error: can't get your Trakt status at this time",
+			)
+		}
+		if account == nil {
+			handler.Bot.reply(ctx, chatID, dedent(`
+			Use /trakt connect to link your Trakt account. Episodes you mark watched here
+			show up in your Trakt history, and what you watch elsewhere moves your
+			progress here.
+			`))
+			return nil
+		}
+		text := "Your Trakt account is connected."
+		if account.SyncedAt.Valid {
+			text += fmt.Sprintf(" Last synced %s UTC.", account.SyncedAt.Time.UTC().Format("2006-01-02 15:04"))
+		}
+		handler.Bot.reply(ctx, chatID, text+"\nUse /trakt off to disconnect it.")
+		return nil
+
+	case "connect":
+		return handler.startTraktConnect(ctx, userID, chatID)
+
+	case "off":
+		if err := disconnectTrakt(ctx, handler.DB, userID); err != nil {
+			return NewUserError(
+				fmt.Errorf("disconnecting Trakt of user %d: %w", userID, err),
+				"Error: can't disconnect Trakt at this time",
+			)
+		}
+		handler.Bot.reply(ctx, chatID, "Trakt disconnected.")
+		return nil
+
+	default:
+		handler.Bot.reply(ctx, chatID, "Usage: /trakt, /trakt connect or /trakt off")
+		return nil
+	}
+}
+
+// startTraktConnect shows the user a device code to approve on trakt.tv and waits
+// for the approval in the background.
+func (handler *Handler) startTraktConnect(ctx context.Context, userID, chatID int64) error {
+	if !handler.traktJobs.begin(userID) {
+		return NewUserError(
+			fmt.Errorf("user %d is already connecting Trakt", userID),
+			"I'm still waiting for you to approve the previous code.",
+		)
+	}
+	code, err := handler.Trakt.DeviceCode(ctx)
+	if err != nil {
+		handler.traktJobs.done(userID)
+		return NewUserError(
+			fmt.Errorf("starting Trakt device flow for user %d: %w", userID, err),
+			"Error: can't reach Trakt at this time",
+		)
+	}
+	text := fmt.Sprintf(
+		"Open %s and enter the code <b>%s</b> to connect your Trakt account. The code works for %s.",
+		html.EscapeString(code.VerificationURL), html.EscapeString(code.UserCode),
+		pluralize(code.ExpiresIn/60, "minute"),
+	)
+	handler.Bot.reply(ctx, chatID, text, ReplyOptions{ParseMode: "HTML"})
+
+	go func() {
+		defer handler.traktJobs.done(userID)
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(code.ExpiresIn)*time.Second)
+		defer cancel()
+
+		token, err := handler.waitForTraktToken(ctx, code)
+		if err != nil {
+			log.Printf("startTraktConnect: waiting for user %d: %v", userID, err)
+			handler.Bot.reply(ctx, chatID, "The Trakt code expired. Use /trakt connect to try again.")
+			return
+		}
+		if err := connectTrakt(ctx, handler.DB, userID, chatID, token); err != nil {
+			log.Printf("startTraktConnect: saving token of user %d: %v", userID, err)
+			handler.Bot.reply(ctx, chatID, "Error: can't save your Trakt account at this time")
+			return
+		}
+		handler.Bot.reply(ctx, chatID, "Trakt connected! Your progress will sync in a few minutes.")
+	}()
+	return nil
+}
+
+// waitForTraktToken polls for the device code's tokens at the interval Trakt asks
+// for, until the user approves it or ctx expires.
+func (handler *Handler) waitForTraktToken(ctx context.Context, code *TraktDeviceCode) (*TraktToken, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	for {
+		token, err := handler.Trakt.PollDeviceToken(ctx, code.DeviceCode)
+		if !errors.Is(err, errTraktPending) {
+			return token, err
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/store"
+)
+
+// Deleted shows go to the trash first: the show and its reminder get a deleted_at
+// timestamp and drop out of every list, but can be restored with /trash. After
+// trashRetention the purge loop deletes them for good, along with their ratings
+// and check-ins.
+
+const trashRetention = 30 * 24 * time.Hour
+
+// TrashedShow is a show in the user's trash.
+type TrashedShow struct {
+	ID        int64
+	Name      string
+	DeletedAt time.Time
+}
+
+// trashShow moves the user's show and its reminder to the trash.
+func trashShow(ctx context.Context, db *store.DB, userID, showID int64, now time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deletedAt := now.UTC().Format(time.RFC3339)
+	result, err := tx.ExecContext(ctx, `
+		UPDATE shows SET deleted_at = ? WHERE id = ? AND user_id = ? AND deleted_at IS NULL
+	`, deletedAt, showID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE reminders SET deleted_at = ? WHERE show_id = ?`, deletedAt, showID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// untrashShow takes the user's show out of the trash. Its reminder stays in the
+// trash: it's stale by now, so the caller rebuilds it.
+func untrashShow(ctx context.Context, db *store.DB, userID, showID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE shows SET deleted_at = NULL WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL
+	`, showID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// listTrashedShows returns the shows in the user's trash, most recently deleted first.
+func listTrashedShows(ctx context.Context, db *store.DB, userID int64) ([]TrashedShow, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, name, deleted_at FROM shows
+		WHERE user_id = ? AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shows []TrashedShow
+	for rows.Next() {
+		var show TrashedShow
+		var deletedAt string
+		if err := rows.Scan(&show.ID, &show.Name, &deletedAt); err != nil {
+			return nil, err
+		}
+		show.DeletedAt, err = time.Parse(time.RFC3339, deletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("parsing deleted_at of show %d: %w", show.ID, err)
+		}
+		shows = append(shows, show)
+	}
+	return shows, rows.Err()
+}
+
+// purgeTrash deletes the shows trashed before cutoff along with everything attached
+// to them. It returns the number of shows purged.
+func purgeTrash(ctx context.Context, db *store.DB, cutoff time.Time) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	trashed := `SELECT id FROM shows WHERE deleted_at <= ?`
+	cutoffStr := cutoff.UTC().Format(time.RFC3339)
+
+	for _, table := range []string{"reminders", "followups", "season_ratings", "trakt_pushes", "watch_log", "reminder_targets", "skipped_episodes", "premiere_hypes", "show_tags", "reminder_offsets", "offset_reminders"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE show_id IN (`+trashed+`)`, cutoffStr); err != nil {
+			return 0, err
+		}
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM shows WHERE deleted_at <= ?`, cutoffStr)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, tx.Commit()
+}
+
+// trashLoop periodically empties the trash of shows deleted more than trashRetention ago.
+func trashLoop(db *store.DB, clk clock.Clock, ctx context.Context) {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			purged, err := purgeTrash(ctx, db, clk.Now().Add(-trashRetention))
+			if err != nil {
+				log.Printf("trashLoop: purgeTrash error: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("trashLoop: purged %d deleted shows", purged)
+			}
+		case <-ctx.Done():
+			log.Println("trashLoop: context cancelled, exiting")
+			return
+		}
+	}
+}
+
+// formatTrash lists the trashed shows and the days each has left.
+func formatTrash(shows []TrashedShow, now time.Time) string {
+	if len(shows) == 0 {
+		return "The trash is empty."
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Deleted shows are kept for %d days, then removed for good:\n", int(trashRetention.Hours()/24))
+	for _, show := range shows {
+		left := max(0, int(show.DeletedAt.Add(trashRetention).Sub(now).Hours()/24))
+		fmt.Fprintf(&b, "\n🗑 <b>%s</b>, %s left", html.EscapeString(show.Name), pluralize(left, "day"))
+	}
+	return b.String()
+}
+
+func trashKeyboard(shows []TrashedShow) *tgbotapi.InlineKeyboardMarkup {
+	var rows [][][]string
+	for _, show := range shows {
+		rows = append(rows, [][]string{{"↩️ Restore " + show.Name, fmt.Sprintf("restoreShow:%d", show.ID)}})
+	}
+	return makeKeyboardMarkup(rows)
+}
+
+// TRASH command
+
+func (handler *Handler) handleTrashCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	shows, err := listTrashedShows(ctx, handler.DB, msg.From.ID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing trash of user %d: %w", msg.From.ID, err),
+			"Error loading the trash",
+		)
+	}
+	opts := ReplyOptions{ParseMode: "HTML"}
+	if len(shows) > 0 {
+		opts.ReplyMarkup = trashKeyboard(shows)
+	}
+	handler.Bot.reply(ctx, msg.Chat.ID, formatTrash(shows, handler.Clock.Now()), opts)
+	return nil
+}
+
+// handleDeleteShowCallback moves a show from the detail card to the trash.
+func (handler *Handler) handleDeleteShowCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showIDStr, listType, found := strings.Cut(callbackParam, ":")
+	if !found {
+		log.Printf("handleDeleteShowCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	showID, err := strconv.Atoi(showIDStr)
+	if err != nil {
+		log.Printf("handleDeleteShowCallback: invalid show ID: %s", showIDStr)
+		return nil
+	}
+	userID := cb.From.ID
+	show, err := handler.validateAndGetShow(ctx, userID, cb.Message.Chat.ID, showID, listType)
+	if err != nil {
+		return err
+	}
+
+	if err := trashShow(ctx, handler.DB, userID, show.InternalID, handler.Clock.Now()); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return NewUserError(
+			fmt.Errorf("trashing show %d: %w", show.InternalID, err),
+			"Error deleting the show",
+		)
+	}
+
+	shows, err := handler.listShowsByType(ctx, userID, listType)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("refreshing shows list for user %d: %w", userID, err),
+			"Error refreshing shows list",
+		)
+	}
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.ShowsList = shows
+	})
+
+	text := fmt.Sprintf(
+		"<b>%s</b> moved to the trash. You can restore it from /trash within %d days.",
+		html.EscapeString(show.Name), int(trashRetention.Hours()/24),
+	)
+	rows := [][][]string{
+		{{"↩️ Undo", fmt.Sprintf("restoreShow:%d", show.InternalID)}},
+		{{"<< Back to shows list", fmt.Sprintf("backToShows:%s", listType)}},
+	}
+	handler.Bot.reply(ctx, cb.Message.Chat.ID, text, ReplyOptions{
+		ReplyMarkup: makeKeyboardMarkup(rows), ParseMode: "HTML", EditMessageID: cb.Message.MessageID,
+	})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleRestoreShowCallback takes a show out of the trash and schedules its reminder again.
+func (handler *Handler) handleRestoreShowCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showID, err := strconv.ParseInt(callbackParam, 10, 64)
+	if err != nil {
+		log.Printf("handleRestoreShowCallback: invalid show ID: %s", callbackParam)
+		return nil
+	}
+	userID := cb.From.ID
+	chatID := cb.Message.Chat.ID
+
+	err = untrashShow(ctx, handler.DB, userID, showID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return NewUserError(
+			fmt.Errorf("show %d of user %d is not in the trash", showID, userID),
+			"This show is no longer in the trash.",
+		)
+	} else if err != nil {
+		return NewUserError(
+			fmt.Errorf("restoring show %d: %w", showID, err),
+			"Error restoring the show",
+		)
+	}
+	if _, reminderChatID, threadID, err := getShowReminderChat(ctx, handler.DB, showID); err != nil {
+		log.Printf("handleRestoreShowCallback: getting reminder chat of show %d: %v", showID, err)
+	} else if _, err := rebuildShowReminder(ctx, handler.DB, userID, showID, reminderChatID, threadID, handler.Clock.Now()); err != nil {
+		log.Printf("handleRestoreShowCallback: rebuilding reminder of show %d: %v", showID, err)
+	}
+	name, err := getShowNameByID(ctx, handler.DB, showID)
+	if err != nil {
+		log.Printf("handleRestoreShowCallback: getting name of show %d: %v", showID, err)
+	}
+	text := fmt.Sprintf("<b>%s</b> is back in your /shows.", html.EscapeString(name))
+	opts := ReplyOptions{ParseMode: "HTML", EditMessageID: cb.Message.MessageID}
+	if shows, err := listTrashedShows(ctx, handler.DB, userID); err != nil {
+		log.Printf("handleRestoreShowCallback: listing trash of user %d: %v", userID, err)
+	} else if len(shows) > 0 {
+		text += "\n\n" + formatTrash(shows, handler.Clock.Now())
+		opts.ReplyMarkup = trashKeyboard(shows)
+	}
+	handler.Bot.reply(ctx, chatID, text, opts)
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
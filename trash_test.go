@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeleteAndRestoreShow(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	env.command("/history")
+	env.press("selectShow:1:history")
+	env.press("deleteShow:1:history")
+
+	if got := queryString(t, env, `SELECT COUNT(*) FROM shows WHERE deleted_at IS NULL`); got != "0" {
+		t.Fatalf("%s shows left after deleting, want 0", got)
+	}
+	reminders, err := listPendingReminders(t.Context(), env.handler.DB, testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reminders) != 0 {
+		t.Errorf("%d reminders left for a deleted show", len(reminders))
+	}
+	env.command("/history")
+	if text := env.telegram.lastMessage(t).Params.Get("text"); strings.Contains(text, "Your show history") {
+		t.Errorf("/history still lists the deleted show: %q", text)
+	}
+
+	env.command("/trash")
+	keyboard := env.telegram.lastMessage(t).keyboard(t)
+	if len(keyboard) != 1 || !strings.HasPrefix(keyboard[0], "restoreShow:") {
+		t.Fatalf("/trash keyboard = %v, want one restore button", keyboard)
+	}
+	env.press(keyboard[0])
+
+	if got := queryString(t, env, `SELECT COUNT(*) FROM shows WHERE deleted_at IS NULL`); got != "1" {
+		t.Errorf("%s shows after restoring, want 1", got)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM reminders WHERE deleted_at IS NULL`); got != "1" {
+		t.Errorf("%s reminders after restoring, want 1", got)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	env.handler.DB.Exec(`UPDATE shows SET deleted_at = ?`, time.Now().Add(-31*24*time.Hour).UTC().Format(time.RFC3339))
+
+	purged, err := purgeTrash(t.Context(), env.handler.DB, time.Now().Add(-trashRetention))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Errorf("purged %d shows, want 1", purged)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM reminders`); got != "0" {
+		t.Errorf("%s reminders left after purging, want 0", got)
+	}
+}
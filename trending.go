@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// /trending lists the shows people track the most, and the ones they added the most
+// this week. Only users who opted in with /trending on are counted, and a show is
+// only named once enough of them share it, so the lists never tell what one person
+// watches. Nothing but the show and a count leaves the aggregate queries.
+
+// minTrendingUsers is how many opted-in users must share a show before /trending
+// names it.
+const minTrendingUsers = 3
+
+// maxTrendingShows is how long each /trending list is.
+const maxTrendingShows = 5
+
+// trendingWindow is how far back "added this week" looks.
+const trendingWindow = 7 * 24 * time.Hour
+
+// TrendingShow is a show and how many opted-in users it counts.
+type TrendingShow struct {
+	Name  string
+	Users int
+}
+
+// listTrendingShows returns the shows the most opted-in users track, limited to the
+// ones they added since since when it isn't zero. Shows below minTrendingUsers are
+// left out.
+func listTrendingShows(ctx context.Context, db *store.DB, since time.Time) ([]TrendingShow, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	sinceStr := ""
+	if !since.IsZero() {
+		sinceStr = since.UTC().Format(time.DateTime)
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT MIN(s.name), COUNT(DISTINCT s.user_id) AS users
+		FROM shows s
+		JOIN user_settings u ON u.user_id = s.user_id
+		WHERE u.share_stats = 1 AND u.inactive_since IS NULL
+		AND s.deleted_at IS NULL AND s.dropped_at IS NULL
+		AND (? = '' OR s.created_at >= ?)
+		GROUP BY s.provider, s.provider_show_id
+		HAVING users >= ?
+		ORDER BY users DESC, MIN(s.name)
+		LIMIT ?
+	`, sinceStr, sinceStr, minTrendingUsers, maxTrendingShows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shows []TrendingShow
+	for rows.Next() {
+		var show TrendingShow
+		if err := rows.Scan(&show.Name, &show.Users); err != nil {
+			return nil, err
+		}
+		shows = append(shows, show)
+	}
+	return shows, rows.Err()
+}
+
+func setShareStats(ctx context.Context, db *store.DB, userID, chatID int64, enabled bool) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET share_stats = ? WHERE user_id = ?`, enabled, userID)
+	return err
+}
+
+func formatTrending(tracked, added []TrendingShow, sharing bool) string {
+	var b strings.Builder
+	b.WriteString("<b>Trending</b>\n")
+	for _, list := range []struct {
+		title string
+		shows []TrendingShow
+		verb  string
+	}{
+		{"Most tracked", tracked, "tracking"},
+		{"Most added this week", added, "added"},
+	} {
+		fmt.Fprintf(&b, "\n%s:\n", list.title)
+		if len(list.shows) == 0 {
+			b.WriteString("Not enough people share their shows yet.\n")
+		}
+		for i, show := range list.shows {
+			fmt.Fprintf(&b, "%d. %s, %d %s\n", i+1, html.EscapeString(show.Name), show.Users, list.verb)
+		}
+	}
+	if sharing {
+		b.WriteString("\nYour shows are counted, anonymously. /trending off stops that.")
+	} else {
+		b.WriteString("\nOnly people who opted in are counted. /trending on adds your shows, anonymously.")
+	}
+	return b.String()
+}
+
+// TRENDING command
+
+func (handler *Handler) handleTrendingCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+
+	switch arg := msg.CommandArguments(); arg {
+	case "on", "off":
+		enabled := arg == "on"
+		if err := setShareStats(ctx, handler.DB, userID, chatID, enabled); err != nil {
+			return NewUserError(
+				fmt.Errorf("setting trending stats for user %d: %w", userID, err),
+				"Error saving your settings, please try again later.",
+			)
+		}
+		if enabled {
+			handler.Bot.reply(ctx, chatID, fmt.Sprintf(
+				"Thanks! Your shows now count towards /trending. Only shows shared by at least %d people are named.",
+				minTrendingUsers,
+			))
+		} else {
+			handler.Bot.reply(ctx, chatID, "Your shows no longer count towards /trending.")
+		}
+		return nil
+	case "":
+	default:
+		return NewUserError(
+			fmt.Errorf("invalid trending argument: %s", arg),
+			"Usage: /trending [on|off]",
+		)
+	}
+
+	settings, err := getUserSettings(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting settings for user %d: %w", userID, err),
+			"Error: can't get trending shows at this time",
+		)
+	}
+	tracked, err := listTrendingShows(ctx, handler.DB, time.Time{})
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing trending shows: %w", err),
+			"Error: can't get trending shows at this time",
+		)
+	}
+	added, err := listTrendingShows(ctx, handler.DB, handler.Clock.Now().Add(-trendingWindow))
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing shows added this week: %w", err),
+			"Error: can't get trending shows at this time",
+		)
+	}
+	handler.Bot.reply(ctx, chatID, formatTrending(tracked, added, settings.ShareStats), ReplyOptions{ParseMode: "HTML"})
+	return nil
+}
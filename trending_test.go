@@ -0,0 +1,40 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTrendingCommand(t *testing.T) {
+	env := newTestEnv(t)
+	db := env.handler.DB
+	for _, user := range []int64{2, 3, 4, 5} {
+		db.Exec(`INSERT INTO user_settings (user_id, chat_id, share_stats) VALUES (?, ?, ?)`, user, user, user != 5)
+		db.Exec(`INSERT INTO shows (user_id, name, provider, provider_show_id) VALUES (?, 'Severance', 'tvmaze', '1')`, user)
+		db.Exec(`INSERT INTO shows (user_id, name, provider, provider_show_id, created_at)
+			VALUES (?, 'The Wire', 'tvmaze', '2', '2020-01-01 00:00:00')`, user)
+	}
+	// Two opted-in users and one who didn't opt in are too few to name the show
+	for _, user := range []int64{2, 3, 5} {
+		db.Exec(`INSERT INTO shows (user_id, name, provider, provider_show_id) VALUES (?, 'Obscure', 'tvmaze', '3')`, user)
+	}
+
+	env.command("/trending")
+	got := env.telegram.lastMessage(t).Params.Get("text")
+	want := "Most tracked:\n1. Severance, 3 tracking\n2. The Wire, 3 tracking\n\nMost added this week:\n1. Severance, 3 added\n"
+	if !strings.Contains(got, want) || strings.Contains(got, "Obscure") {
+		t.Errorf("/trending = %q, want %q", got, want)
+	}
+	if !strings.Contains(got, "/trending on adds your shows") {
+		t.Errorf("/trending = %q, want an invitation to opt in", got)
+	}
+
+	env.command("/trending on")
+	if got := queryString(t, env, `SELECT share_stats FROM user_settings WHERE user_id = 1001`); got != "1" {
+		t.Errorf("share_stats after /trending on = %s", got)
+	}
+	env.command("/trending off")
+	if got := queryString(t, env, `SELECT share_stats FROM user_settings WHERE user_id = 1001`); got != "0" {
+		t.Errorf("share_stats after /trending off = %s", got)
+	}
+}
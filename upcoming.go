@@ -0,0 +1,156 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// upcomingWindow is how far ahead /upcoming looks.
+const upcomingWindow = 30 * 24 * time.Hour
+
+// maxUpcomingItems keeps /upcoming within one message for users tracking a lot.
+const maxUpcomingItems = 30
+
+// UpcomingEpisode is an episode of one of the user's shows airing soon.
+type UpcomingEpisode struct {
+	ShowID   int64
+	ShowName string
+	Season   int
+	Number   int
+	Title    string
+	AiredAt  time.Time
+	Pinned   bool
+}
+
+// listUpcomingEpisodes returns episodes of the user's shows airing between from and
+// to, soonest first. Watchlisted shows only contribute their season premieres.
+func listUpcomingEpisodes(ctx context.Context, db *store.DB, userID int64, from, to time.Time) ([]UpcomingEpisode, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.id, s.name, e.season, e.number, e.title, e.aired_at_utc, s.pinned
+		FROM shows s
+		JOIN episodes_cache e ON e.provider = s.provider AND e.provider_show_id = s.provider_show_id
+		WHERE s.user_id = ? AND s.deleted_at IS NULL AND s.dropped_at IS NULL
+		AND e.aired_at_utc > ? AND e.aired_at_utc <= ?
+		AND (s.reminder_mode != ? OR e.number = 1)
+		ORDER BY e.aired_at_utc, `+showListOrder, userID, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339), ReminderModeWatchlist)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var episodes []UpcomingEpisode
+	for rows.Next() {
+		var episode UpcomingEpisode
+		var airedAt string
+		if err := rows.Scan(&episode.ShowID, &episode.ShowName, &episode.Season, &episode.Number, &episode.Title, &airedAt, &episode.Pinned); err != nil {
+			return nil, err
+		}
+		episode.AiredAt, err = time.Parse(time.RFC3339, airedAt)
+		if err != nil {
+			continue
+		}
+		episodes = append(episodes, episode)
+	}
+	return episodes, rows.Err()
+}
+
+type upcomingItem struct {
+	when time.Time
+	text string
+}
+
+// formatUpcoming renders upcoming episodes and unwatched movies releasing before to
+// as one list, in loc.
+func formatUpcoming(episodes []UpcomingEpisode, movies []DBMovie, now, to time.Time, loc *time.Location) string {
+	var items []upcomingItem
+	for _, e := range episodes {
+		name := html.EscapeString(e.ShowName)
+		if e.Pinned {
+			name = "📌 " + name
+		}
+		items = append(items, upcomingItem{
+			when: e.AiredAt,
+			text: fmt.Sprintf(
+				"%s - %s S%02dE%02d \"%s\"",
+				e.AiredAt.In(loc).Format("Mon Jan 2, 15:04"), name, e.Season, e.Number,
+				html.EscapeString(e.Title),
+			),
+		})
+	}
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	for _, m := range movies {
+		release, ok := m.releaseTime(loc)
+		if m.Watched || !ok || release.Before(today) || release.After(to) {
+			continue
+		}
+		items = append(items, upcomingItem{
+			when: release,
+			text: fmt.Sprintf("%s - 🎬 %s", release.Format("Mon Jan 2"), html.EscapeString(m.Title)),
+		})
+	}
+	if len(items) == 0 {
+		return ""
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].when.Before(items[j].when) })
+
+	var b strings.Builder
+	b.WriteString("<b>Coming up</b>\n\n")
+	for i, item := range items {
+		if i == maxUpcomingItems {
+			fmt.Fprintf(&b, "…and %d more\n", len(items)-maxUpcomingItems)
+			break
+		}
+		b.WriteString(item.text + "\n")
+	}
+	return b.String()
+}
+
+// UPCOMING command
+
+func (handler *Handler) handleUpcomingCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	userID := msg.From.ID
+	now := handler.Clock.Now()
+	to := now.Add(upcomingWindow)
+
+	episodes, err := listUpcomingEpisodes(ctx, handler.DB, userID, now, to)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing upcoming episodes for user %d: %w", userID, err),
+			"Error: can't list upcoming episodes at this time",
+		)
+	}
+	movies, err := listMovies(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing movies for user %d: %w", userID, err),
+			"Error: can't list upcoming episodes at this time",
+		)
+	}
+	settings, err := getUserSettings(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting settings for user %d: %w", userID, err),
+			"Error: can't list upcoming episodes at this time",
+		)
+	}
+
+	text := formatUpcoming(episodes, movies, now, to, settings.Location())
+	if text == "" {
+		handler.Bot.reply(ctx, msg.Chat.ID, "Nothing is coming out in the next 30 days.")
+		return nil
+	}
+	handler.Bot.reply(ctx, msg.Chat.ID, text, ReplyOptions{ParseMode: "HTML"})
+	return nil
+}
@@ -1,12 +1,21 @@
-package main
+package bot
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"regexp"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// makeKeyboardMarkup builds an inline keyboard from rows of {label, callback data}
+// buttons. The bot signs the callback data for the chat it sends the keyboard to,
+// see signKeyboard.
 func makeKeyboardMarkup(rows [][][]string) *tgbotapi.InlineKeyboardMarkup {
 	var inlineRows [][]tgbotapi.InlineKeyboardButton
 	for _, row := range rows {
@@ -57,3 +66,50 @@ func dedent(s string) string {
 	}
 	return strings.TrimSpace(strings.Join(lines, "\n"))
 }
+
+var htmlTagRe = regexp.MustCompile(`<[^>]*>`)
+
+// stripHTML turns provider-supplied HTML snippets (e.g. TVmaze summaries) into plain text.
+func stripHTML(s string) string {
+	return strings.TrimSpace(html.UnescapeString(htmlTagRe.ReplaceAllString(s, "")))
+}
+
+// formatCountdown renders a duration as a short human countdown like "2d 14h" or "35m".
+func formatCountdown(d time.Duration) string {
+	if d < time.Minute {
+		return "less than a minute"
+	}
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// pluralize formats a count with a naively pluralized noun, e.g. "1 episode", "4 episodes".
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// spoiler escapes s and wraps it in Telegram's HTML spoiler tag.
+func spoiler(s string) string {
+	return "<tg-spoiler>" + html.EscapeString(s) + "</tg-spoiler>"
+}
+
+// randomToken returns 16 random bytes, hex encoded, for secrets and unguessable URLs.
+func randomToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+const watchlistTitle = "Your watchlist:"
+
+func listWatchlist(ctx context.Context, db *store.DB, userID int64) ([]ShowProgress, error) {
+	shows, err := listShowsWithProgress(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var watchlist []ShowProgress
+	for _, show := range shows {
+		if show.ReminderMode == ReminderModeWatchlist && !show.Archived && !show.DroppedAt.Valid {
+			watchlist = append(watchlist, show)
+		}
+	}
+	return watchlist, nil
+}
+
+// formatPremiere describes the next known season premiere of a show for its card.
+func (handler *Handler) formatPremiere(ctx context.Context, provider, providerShowID string) string {
+	premiere, err := findNextPremiere(ctx, handler.DB, provider, providerShowID, &DBEpisode{})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "Next premiere: not announced yet\n"
+	}
+	if err != nil {
+		log.Printf("formatPremiere: finding premiere for show %s: %v", providerShowID, err)
+		return ""
+	}
+	return fmt.Sprintf(
+		"Next premiere: season %d, %s\n", premiere.Season, premiere.AiredAtUTC.Format("Mon Jan 2, 15:04"),
+	)
+}
+
+// handleWatchlistShowCallback finishes the /add flow by putting the selected show on
+// the watchlist instead of asking for progress.
+func (handler *Handler) handleWatchlistShowCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) error {
+	userID := cb.From.ID
+	msg := cb.Message
+	chatID := msg.Chat.ID
+
+	userCtx := handler.Bot.getUserContext(userID)
+	if userCtx == nil || userCtx.SelectedInternalID == 0 {
+		handler.Bot.clearState(userID)
+		return NewUserError(
+			fmt.Errorf("session expired for user %d", userID),
+			"Session expired. Please start over with /add.",
+		)
+	}
+	showID := userCtx.SelectedInternalID
+
+	if err := moveShowToWatchlist(ctx, handler.DB, showID); err != nil {
+		return NewUserError(
+			fmt.Errorf("moving show %d to the watchlist: %w", showID, err),
+			"Error adding the show to your watchlist",
+		)
+	}
+	premiere, err := rebuildShowReminder(ctx, handler.DB, userID, showID, chatID, updateThread(ctx, chatID), handler.Clock.Now())
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("rebuilding reminder for show %d: %w", showID, err),
+			"Error creating reminder",
+		)
+	}
+	showName, err := getShowNameByID(ctx, handler.DB, showID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting name of show %d: %w", showID, err),
+			"Error adding the show to your watchlist",
+		)
+	}
+
+	text := fmt.Sprintf("Added \"%s\" to your /watchlist. ", showName)
+	if premiere != nil {
+		text += fmt.Sprintf(
+			"Season %d premieres on %s, I'll remind you.",
+			premiere.Season, premiere.AiredAtUTC.Format("Mon Jan 2, 15:04"),
+		)
+	} else {
+		text += "I'll let you know when a new season is announced."
+	}
+	handler.Bot.reply(ctx, chatID, text, ReplyOptions{EditMessageID: msg.MessageID})
+	handler.Bot.clearState(userID)
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// handleStartTrackingCallback turns a watchlisted show into a fully tracked one and
+// asks for the user's progress.
+func (handler *Handler) handleStartTrackingCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	showIDStr, listType, found := strings.Cut(callbackParam, ":")
+	if !found {
+		log.Printf("handleStartTrackingCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+	showID, err := strconv.Atoi(showIDStr)
+	if err != nil {
+		log.Printf("handleStartTrackingCallback: invalid show ID: %s", showIDStr)
+		return nil
+	}
+
+	userID := cb.From.ID
+	msg := cb.Message
+	chatID := msg.Chat.ID
+
+	show, err := handler.validateAndGetShow(ctx, userID, chatID, showID, listType)
+	if err != nil {
+		return err
+	}
+	providerID, err := strconv.Atoi(show.ProviderShowID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("invalid provider show id %q: %w", show.ProviderShowID, err),
+			"Can't track this show.",
+		)
+	}
+
+	if err := setShowReminderMode(ctx, handler.DB, show.InternalID, ReminderModeEpisode); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting reminder mode for show %d: %w", show.InternalID, err),
+			"Error starting to track the show",
+		)
+	}
+	// The premiere reminder goes away until the user sets their progress
+	if _, err := rebuildShowReminder(ctx, handler.DB, userID, show.InternalID, chatID, updateThread(ctx, chatID), handler.Clock.Now()); err != nil {
+		return NewUserError(
+			fmt.Errorf("rebuilding reminder for show %d: %w", show.InternalID, err),
+			"Error updating reminder",
+		)
+	}
+
+	handler.Bot.withUserContext(userID, func(ctx *UserContext) {
+		ctx.SelectedInternalID = show.InternalID
+		ctx.SelectedProviderID = providerID
+	})
+	intro := fmt.Sprintf("Tracking \"%s\".", show.Name)
+	if err := handler.askForProgress(ctx, userID, chatID, msg.MessageID, providerID, intro); err != nil {
+		return err
+	}
+
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+// WATCHLIST command
+
+func (handler *Handler) handleWatchlistCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	watchlist, err := listWatchlist(ctx, handler.DB, msg.From.ID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing watchlist for user %d: %w", msg.From.ID, err),
+			"Error: can't list shows at this time",
+		)
+	}
+	if len(watchlist) == 0 {
+		handler.Bot.reply(ctx,
+			chatID,
+			"Your watchlist is empty. Use /add and pick \"Just add to watchlist\" to follow a show without tracking episodes.",
+		)
+		return nil
+	}
+	handler.Bot.withUserContext(msg.From.ID, func(ctx *UserContext) {
+		ctx.ShowsList = watchlist
+	})
+	inlineMarkup := handler.makeShowsKeyboard(watchlist, "watchlist")
+	handler.Bot.reply(ctx, chatID, watchlistTitle, ReplyOptions{ReplyMarkup: inlineMarkup})
+	return nil
+}
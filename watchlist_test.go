@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// renewedShow returns a show with an aired first season and a second one premiering
+// in two weeks.
+func renewedShow() fakeShow {
+	now := time.Now()
+	show := fakeShow{ShowSearchResult: ShowSearchResult{ID: 4, Name: "Renewed"}}
+	show.Episodes = append(
+		makeEpisodes(4, 1, 3, now.AddDate(0, -2, 0)),
+		makeEpisodes(4, 2, 3, now.AddDate(0, 0, 14))...,
+	)
+	return show
+}
+
+func addToWatchlist(t *testing.T, env *testEnv) {
+	t.Helper()
+	env.command("/add renewed")
+	env.press("acceptShowName:1")
+	env.press("watchlistShow:")
+}
+
+func TestWatchlistRemindsAboutPremiere(t *testing.T) {
+	env := newTestEnv(t, renewedShow())
+	addToWatchlist(t, env)
+
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "Season 2 premieres on") {
+		t.Errorf("last message = %q, want the premiere date", got)
+	}
+	if got := queryString(t, env, `
+		SELECT e.season || 'x' || e.number FROM reminders r JOIN episodes_cache e ON e.id = r.episode_id
+	`); got != "2x1" {
+		t.Errorf("reminder for %s, want the season 2 premiere", got)
+	}
+
+	// Watchlisted shows have no progress to show in the other lists
+	env.command("/shows")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "You have no current shows") {
+		t.Errorf("/shows = %q, want no current shows", got)
+	}
+	env.command("/queue")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "You're all caught up") {
+		t.Errorf("/queue = %q, want an empty queue", got)
+	}
+
+	_, err := env.handler.DB.Exec(`UPDATE reminders SET remind_at = ?`, time.Now().UTC().Add(-time.Minute).Format(time.RFC3339))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "Season 2 of \"Renewed\" premieres today") {
+		t.Errorf("reminder = %q", got)
+	}
+	// No later premiere is known, so nothing else is scheduled
+	if got := queryString(t, env, `SELECT COUNT(*) FROM reminders WHERE sent_at IS NULL`); got != "0" {
+		t.Errorf("reminders after the premiere = %s, want 0", got)
+	}
+}
+
+func TestWatchlistStartTracking(t *testing.T) {
+	env := newTestEnv(t, renewedShow())
+	addToWatchlist(t, env)
+	env.command("/watchlist")
+	env.press("selectShow:1:watchlist")
+
+	card := env.telegram.lastMessage(t)
+	if text := card.Params.Get("text"); !strings.Contains(text, "Next premiere: season 2") {
+		t.Errorf("card = %q, want the next premiere", text)
+	}
+	keyboard := card.keyboard(t)
+	if !slices.Contains(keyboard, "startTracking:1:watchlist") || slices.Contains(keyboard, "markNextWatched:1:watchlist") {
+		t.Errorf("keyboard = %v, want start tracking instead of progress buttons", keyboard)
+	}
+
+	env.press("startTracking:1:watchlist")
+	if got := env.telegram.lastMessage(t).keyboard(t); !slices.Equal(got, []string{"selectSeason:1", "selectSeason:2", "cancel"}) {
+		t.Errorf("keyboard = %v, want the season picker", got)
+	}
+	env.press("selectSeason:1")
+	env.press("selectEpisode:3")
+
+	if got := queryString(t, env, `SELECT reminder_mode FROM shows`); got != ReminderModeEpisode {
+		t.Errorf("reminder_mode = %s, want %s", got, ReminderModeEpisode)
+	}
+	env.command("/shows")
+	if got := env.telegram.lastMessage(t).Params.Get("reply_markup"); !strings.Contains(got, "Renewed") {
+		t.Errorf("/shows keyboard = %s, want the tracked show", got)
+	}
+}
@@ -0,0 +1,678 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/clock"
+	"tvreminder/bot/internal/store"
+)
+
+// GroupShow is a show a group chat watches together. The group's progress is a
+// single pointer that members advance together; each member also has their own
+// progress so the group can see who is behind.
+type GroupShow struct {
+	ID             int64
+	ChatID         int64
+	Provider       string
+	ProviderShowID string
+	Name           string
+	// Season and Episode are where the group is, unset before the first episode.
+	Season                 sql.NullInt32
+	Episode                sql.NullInt32
+	LastAnnouncedEpisodeID sql.NullInt64
+	CreatedAt              time.Time
+}
+
+type GroupMember struct {
+	UserID    int64
+	Username  string
+	FirstName string
+	Season    sql.NullInt32
+	Episode   sql.NullInt32
+}
+
+// caughtUp reports whether the member has watched at least as far as the group.
+func (member GroupMember) caughtUp(group *GroupShow) bool {
+	if !group.Season.Valid {
+		return true
+	}
+	if !member.Season.Valid {
+		return false
+	}
+	if member.Season.Int32 != group.Season.Int32 {
+		return member.Season.Int32 > group.Season.Int32
+	}
+	return member.Episode.Int32 >= group.Episode.Int32
+}
+
+// mention links to the member so Telegram notifies them.
+func (member GroupMember) mention() string {
+	if member.Username != "" {
+		return "@" + member.Username
+	}
+	name := member.FirstName
+	if name == "" {
+		name = "someone"
+	}
+	return fmt.Sprintf("<a href=\"tg://user?id=%d\">%s</a>", member.UserID, html.EscapeString(name))
+}
+
+func formatProgress(season, episode sql.NullInt32) string {
+	if !season.Valid || !episode.Valid {
+		return "not started"
+	}
+	return fmt.Sprintf("S%02dE%02d", season.Int32, episode.Int32)
+}
+
+// createGroupShow starts a watch party for a show in chatID, returning the
+// existing one if the group already watches it.
+func createGroupShow(ctx context.Context, db *store.DB, chatID int64, provider string, providerShowID int, name string, createdBy int64, now time.Time) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO group_shows (chat_id, provider, provider_show_id, name, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`, chatID, provider, providerShowID, name, createdBy, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = db.QueryRowContext(ctx, `
+		SELECT id FROM group_shows WHERE chat_id = ? AND provider = ? AND provider_show_id = ?
+	`, chatID, provider, providerShowID).Scan(&id)
+	return id, err
+}
+
+const groupShowColumns = `
+	g.id, g.chat_id, g.provider, g.provider_show_id, g.name, e.season, e.number,
+	g.last_announced_episode_id, g.created_at`
+
+func scanGroupShow(row rowScanner) (*GroupShow, error) {
+	var group GroupShow
+	var createdAt string
+	err := row.Scan(
+		&group.ID, &group.ChatID, &group.Provider, &group.ProviderShowID, &group.Name,
+		&group.Season, &group.Episode, &group.LastAnnouncedEpisodeID, &createdAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	group.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &group, nil
+}
+
+func getGroupShow(ctx context.Context, db *store.DB, groupShowID int64) (*GroupShow, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	return scanGroupShow(db.QueryRowContext(ctx, `
+		SELECT `+groupShowColumns+`
+		FROM group_shows g
+		LEFT JOIN episodes_cache e ON e.id = g.last_watched_episode_id
+		WHERE g.id = ?
+	`, groupShowID))
+}
+
+// listGroupShows returns the watch parties of a chat, or of every chat when chatID is 0.
+func listGroupShows(ctx context.Context, db *store.DB, chatID int64) ([]GroupShow, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+groupShowColumns+`
+		FROM group_shows g
+		LEFT JOIN episodes_cache e ON e.id = g.last_watched_episode_id
+		WHERE ? = 0 OR g.chat_id = ?
+		ORDER BY g.name
+	`, chatID, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []GroupShow
+	for rows.Next() {
+		group, err := scanGroupShow(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, *group)
+	}
+	return groups, rows.Err()
+}
+
+func listGroupMembers(ctx context.Context, db *store.DB, groupShowID int64) ([]GroupMember, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT m.user_id, m.username, m.first_name, e.season, e.number
+		FROM group_members m
+		LEFT JOIN episodes_cache e ON e.id = m.last_watched_episode_id
+		WHERE m.group_show_id = ?
+		ORDER BY m.joined_at, m.user_id
+	`, groupShowID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []GroupMember
+	for rows.Next() {
+		var member GroupMember
+		err := rows.Scan(&member.UserID, &member.Username, &member.FirstName, &member.Season, &member.Episode)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// joinGroupShow adds user to the watch party, refreshing their name if they are
+// already a member.
+func joinGroupShow(ctx context.Context, db *store.DB, groupShowID int64, user *tgbotapi.User) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO group_members (group_show_id, user_id, username, first_name)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(group_show_id, user_id) DO UPDATE SET
+			username = excluded.username,
+			first_name = excluded.first_name
+	`, groupShowID, user.ID, user.UserName, user.FirstName)
+	return err
+}
+
+func leaveGroupShow(ctx context.Context, db *store.DB, groupShowID, userID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `DELETE FROM group_members WHERE group_show_id = ? AND user_id = ?`, groupShowID, userID)
+	return err
+}
+
+// removeGroupShow deletes a watch party with its members.
+func removeGroupShow(ctx context.Context, db *store.DB, groupShowID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM group_members WHERE group_show_id = ?`, groupShowID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM group_shows WHERE id = ?`, groupShowID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setGroupProgress(ctx context.Context, db *store.DB, groupShowID, episodeID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `UPDATE group_shows SET last_watched_episode_id = ? WHERE id = ?`, episodeID, groupShowID)
+	return err
+}
+
+// setMemberProgressToGroup moves the member's progress to where the group is.
+func setMemberProgressToGroup(ctx context.Context, db *store.DB, groupShowID, userID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE group_members
+		SET last_watched_episode_id = (SELECT last_watched_episode_id FROM group_shows WHERE id = ?)
+		WHERE group_show_id = ? AND user_id = ?
+	`, groupShowID, groupShowID, userID)
+	return err
+}
+
+func markGroupShowAnnounced(ctx context.Context, db *store.DB, groupShowID, episodeID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `UPDATE group_shows SET last_announced_episode_id = ? WHERE id = ?`, episodeID, groupShowID)
+	return err
+}
+
+// formatWatchPartyReminder announces the group's next episode and pings the members
+// who still have to catch up with the group.
+func formatWatchPartyReminder(group *GroupShow, next *DBEpisode, members []GroupMember) string {
+	text := fmt.Sprintf(
+		"🍿 New episode of <b>%s</b> is out: S%02dE%02d \"%s\"\n",
+		html.EscapeString(group.Name), next.Season, next.Number, html.EscapeString(next.Title),
+	)
+	var behind []string
+	for _, member := range members {
+		if !member.caughtUp(group) {
+			behind = append(behind, member.mention())
+		}
+	}
+	if len(behind) == 0 {
+		return text + "Everyone is caught up, enjoy the watch party!"
+	}
+	return text + fmt.Sprintf(
+		"Still catching up to %s: %s", formatProgress(group.Season, group.Episode), strings.Join(behind, ", "),
+	)
+}
+
+// sendWatchPartyReminders tells each group about the next episode once it aired by
+// now, one tick of watchPartyLoop. Episodes that aired before the party started
+// are left to the group to catch up on.
+func sendWatchPartyReminders(ctx context.Context, bot *Bot, db *store.DB, now time.Time) {
+	groups, err := listGroupShows(ctx, db, 0)
+	if err != nil {
+		log.Printf("watchPartyLoop: listing group shows: %v", err)
+		return
+	}
+	for i := range groups {
+		group := &groups[i]
+		next, err := findNextEpisode(ctx, db, group.Provider, group.ProviderShowID, group.Season, group.Episode)
+		if err != nil {
+			continue
+		}
+		if next.AiredAtUTC.IsZero() || next.AiredAtUTC.After(now) || next.AiredAtUTC.Before(group.CreatedAt) {
+			continue
+		}
+		if group.LastAnnouncedEpisodeID.Valid && group.LastAnnouncedEpisodeID.Int64 == next.ID {
+			continue
+		}
+
+		members, err := listGroupMembers(ctx, db, group.ID)
+		if err != nil {
+			log.Printf("watchPartyLoop: listing members of group show %d: %v", group.ID, err)
+			continue
+		}
+		_, err = bot.send(ctx, group.ChatID, formatWatchPartyReminder(group, next, members), ReplyOptions{ParseMode: "HTML"})
+		if err != nil {
+			log.Printf("watchPartyLoop: sending to chat %d failed: %v", group.ChatID, err)
+			if !isChatUnreachable(err) {
+				// Retried on the next tick
+				continue
+			}
+		}
+		if err := markGroupShowAnnounced(ctx, db, group.ID, next.ID); err != nil {
+			log.Printf("watchPartyLoop: marking group show %d announced: %v", group.ID, err)
+		}
+	}
+}
+
+func watchPartyLoop(bot *Bot, db *store.DB, clk clock.Clock, ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sendWatchPartyReminders(ctx, bot, db, clk.Now())
+		case <-ctx.Done():
+			log.Println("watchPartyLoop: context cancelled, exiting")
+			return
+		}
+	}
+}
+
+// WATCHPARTY command
+
+func (handler *Handler) handleWatchPartyCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	if !msg.Chat.IsGroup() && !msg.Chat.IsSuperGroup() {
+		return NewUserError(
+			fmt.Errorf("watchparty in non-group chat %d", chatID),
+			"Watch parties happen in group chats. Add me to a group and use /watchparty <show> there.",
+		)
+	}
+
+	query := strings.TrimSpace(msg.CommandArguments())
+	if query == "" {
+		return handler.listWatchParties(ctx, chatID)
+	}
+	if !isAnonymousAdmin(msg) {
+		if err := handler.requireGroupManager(ctx, chatID, msg.From.ID); err != nil {
+			return err
+		}
+	}
+	if !handler.checkRateLimit(ctx, handler.SearchLimiter, msg.From, chatID) {
+		return nil
+	}
+
+	handler.Bot.sendChatAction(chatID, tgbotapi.ChatTyping)
+
+	searchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	results, err := handler.Provider.SearchShow(searchCtx, query)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("searching show %q: %w", query, err),
+			fmt.Sprintf("Error searching show %s", query),
+		)
+	}
+	if len(results) == 0 {
+		handler.Bot.reply(ctx, chatID, "No shows found for: "+query)
+		return nil
+	}
+
+	var rows [][][]string
+	for i := range min(5, len(results)) {
+		label := fmt.Sprintf("%d. %s (%s)", i+1, trimString(results[i].Name, 25), safeString(results[i].Premiered))
+		rows = append(rows, [][]string{{label, fmt.Sprintf("partyCreate:%d", i+1)}})
+	}
+	rows = append(rows, [][]string{{"❌ Cancel", "cancel"}})
+
+	handler.Bot.withUserContext(msg.From.ID, func(ctx *UserContext) {
+		ctx.SearchResults = results
+	})
+	handler.Bot.reply(ctx, chatID, "Pick the show to watch together:", ReplyOptions{ReplyMarkup: makeKeyboardMarkup(rows)})
+	return nil
+}
+
+func (handler *Handler) listWatchParties(ctx context.Context, chatID int64) error {
+	groups, err := listGroupShows(ctx, handler.DB, chatID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing group shows for chat %d: %w", chatID, err),
+			"Error getting watch parties, please try again later.",
+		)
+	}
+	if len(groups) == 0 {
+		handler.Bot.reply(ctx, chatID, "No watch parties in this group yet. Start one with /watchparty <show>.")
+		return nil
+	}
+
+	var rows [][][]string
+	for _, group := range groups {
+		label := fmt.Sprintf("%s (%s)", trimString(group.Name, 30), formatProgress(group.Season, group.Episode))
+		rows = append(rows, [][]string{{label, fmt.Sprintf("partyShow:%d", group.ID)}})
+	}
+	handler.Bot.reply(ctx, chatID, "Watch parties in this group:", ReplyOptions{ReplyMarkup: makeKeyboardMarkup(rows)})
+	return nil
+}
+
+func (handler *Handler) handlePartyCreateCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	searchResultIdx, err := strconv.Atoi(callbackParam)
+	if err != nil {
+		log.Printf("handlePartyCreateCallback: invalid callback parameter: %s", callbackParam)
+		return nil
+	}
+
+	userID := cb.From.ID
+	chatID := cb.Message.Chat.ID
+
+	userCtx := handler.Bot.getUserContext(userID)
+	if userCtx == nil || searchResultIdx < 1 || searchResultIdx > len(userCtx.SearchResults) {
+		return NewUserError(
+			fmt.Errorf("no search result %d for user %d", searchResultIdx, userID),
+			"No search results found. Please start over with /watchparty <show>.",
+		)
+	}
+	show := userCtx.SearchResults[searchResultIdx-1]
+	if err := handler.requireGroupManager(ctx, chatID, userID); err != nil {
+		return err
+	}
+
+	if _, err := handler.cacheEpisodes(ctx, chatID, cb.Message.MessageID, show.ID); err != nil {
+		return err
+	}
+
+	groupShowID, err := createGroupShow(ctx, handler.DB, chatID, handler.Provider.Name(), show.ID, show.Name, userID, handler.Clock.Now())
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("creating group show %d in chat %d: %w", show.ID, chatID, err),
+			"Error starting the watch party, please try again later.",
+		)
+	}
+	if err := joinGroupShow(ctx, handler.DB, groupShowID, cb.From); err != nil {
+		return NewUserError(
+			fmt.Errorf("joining group show %d for user %d: %w", groupShowID, userID, err),
+			"Error joining the watch party, please try again later.",
+		)
+	}
+
+	return handler.showWatchParty(ctx, cb, groupShowID)
+}
+
+// getChatGroupShow loads a watch party, making sure it belongs to the chat the
+// button was pressed in.
+func (handler *Handler) getChatGroupShow(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) (*GroupShow, error) {
+	groupShowID, err := strconv.ParseInt(callbackParam, 10, 64)
+	if err != nil {
+		return nil, NewUserError(
+			fmt.Errorf("invalid group show id %q: %w", callbackParam, err),
+			"Invalid watch party selection.",
+		)
+	}
+	group, err := getGroupShow(ctx, handler.DB, groupShowID)
+	if err == nil && group.ChatID != cb.Message.Chat.ID {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, NewUserError(
+			fmt.Errorf("getting group show %d for chat %d: %w", groupShowID, cb.Message.Chat.ID, err),
+			"This watch party no longer exists. See /watchparty.",
+		)
+	}
+	return group, nil
+}
+
+// showWatchParty renders the watch party card with the group's and every member's
+// progress in place of the callback's message.
+func (handler *Handler) showWatchParty(ctx context.Context, cb *tgbotapi.CallbackQuery, groupShowID int64) error {
+	chatID := cb.Message.Chat.ID
+
+	group, err := getGroupShow(ctx, handler.DB, groupShowID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting group show %d: %w", groupShowID, err),
+			"This watch party no longer exists. See /watchparty.",
+		)
+	}
+	members, err := listGroupMembers(ctx, handler.DB, group.ID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("listing members of group show %d: %w", group.ID, err),
+			"Error getting the watch party, please try again later.",
+		)
+	}
+
+	infoText := fmt.Sprintf("🍿 <b>%s</b> watch party\n\n", html.EscapeString(group.Name))
+	infoText += fmt.Sprintf("Group progress: %s\n", formatProgress(group.Season, group.Episode))
+	next, err := findNextEpisode(ctx, handler.DB, group.Provider, group.ProviderShowID, group.Season, group.Episode)
+	if err == nil {
+		infoText += fmt.Sprintf("Next episode: S%02dE%02d \"%s\"", next.Season, next.Number, html.EscapeString(next.Title))
+		if untilAir := next.AiredAtUTC.Sub(handler.Clock.Now()); untilAir > 0 {
+			infoText += fmt.Sprintf(" (airs in %s)", formatCountdown(untilAir))
+		}
+		infoText += "\n"
+	} else {
+		infoText += "Next episode: N/A\n"
+	}
+
+	infoText += "\nMembers:\n"
+	if len(members) == 0 {
+		infoText += "Nobody yet, press Join!\n"
+	}
+	for _, member := range members {
+		status := "⏳"
+		if member.caughtUp(group) {
+			status = "✅"
+		}
+		name := member.FirstName
+		if name == "" {
+			name = member.Username
+		}
+		infoText += fmt.Sprintf(
+			"%s %s: %s\n", status, html.EscapeString(name), formatProgress(member.Season, member.Episode),
+		)
+	}
+
+	id := group.ID
+	rows := [][][]string{
+		{{"▶️ We watched the next episode", fmt.Sprintf("partyNext:%d", id)}},
+		{{"✅ I'm caught up", fmt.Sprintf("partyCaughtUp:%d", id)}},
+		{{"🙋 Join", fmt.Sprintf("partyJoin:%d", id)}, {"🚪 Leave", fmt.Sprintf("partyLeave:%d", id)}},
+		{{"🗑 Remove watch party", fmt.Sprintf("partyRemove:%d", id)}},
+	}
+	handler.Bot.reply(ctx, chatID, infoText, ReplyOptions{
+		ReplyMarkup: makeKeyboardMarkup(rows), ParseMode: "HTML", EditMessageID: cb.Message.MessageID,
+	})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+func (handler *Handler) handlePartyShowCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	group, err := handler.getChatGroupShow(ctx, cb, callbackParam)
+	if err != nil {
+		return err
+	}
+	return handler.showWatchParty(ctx, cb, group.ID)
+}
+
+func (handler *Handler) handlePartyJoinCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	group, err := handler.getChatGroupShow(ctx, cb, callbackParam)
+	if err != nil {
+		return err
+	}
+	if err := joinGroupShow(ctx, handler.DB, group.ID, cb.From); err != nil {
+		return NewUserError(
+			fmt.Errorf("joining group show %d for user %d: %w", group.ID, cb.From.ID, err),
+			"Error joining the watch party, please try again later.",
+		)
+	}
+	return handler.showWatchParty(ctx, cb, group.ID)
+}
+
+func (handler *Handler) handlePartyLeaveCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	group, err := handler.getChatGroupShow(ctx, cb, callbackParam)
+	if err != nil {
+		return err
+	}
+	if err := leaveGroupShow(ctx, handler.DB, group.ID, cb.From.ID); err != nil {
+		return NewUserError(
+			fmt.Errorf("leaving group show %d for user %d: %w", group.ID, cb.From.ID, err),
+			"Error leaving the watch party, please try again later.",
+		)
+	}
+	return handler.showWatchParty(ctx, cb, group.ID)
+}
+
+// handlePartyNextCallback moves the group to its next aired episode. Whoever pressed
+// the button watched it with the group, so they are caught up.
+func (handler *Handler) handlePartyNextCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	group, err := handler.getChatGroupShow(ctx, cb, callbackParam)
+	if err != nil {
+		return err
+	}
+	if err := handler.requireGroupManager(ctx, group.ChatID, cb.From.ID); err != nil {
+		return err
+	}
+
+	next, err := findNextEpisode(ctx, handler.DB, group.Provider, group.ProviderShowID, group.Season, group.Episode)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("finding next episode for group show %d: %w", group.ID, err),
+			"There is no next episode yet.",
+		)
+	}
+	if next.AiredAtUTC.After(handler.Clock.Now()) {
+		return NewUserError(
+			fmt.Errorf("next episode %d of group show %d hasn't aired", next.ID, group.ID),
+			fmt.Sprintf("S%02dE%02d hasn't aired yet.", next.Season, next.Number),
+		)
+	}
+
+	if err := setGroupProgress(ctx, handler.DB, group.ID, next.ID); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting progress of group show %d: %w", group.ID, err),
+			"Error updating the watch party, please try again later.",
+		)
+	}
+	return handler.catchUpMember(ctx, cb, group.ID)
+}
+
+func (handler *Handler) handlePartyCaughtUpCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	group, err := handler.getChatGroupShow(ctx, cb, callbackParam)
+	if err != nil {
+		return err
+	}
+	return handler.catchUpMember(ctx, cb, group.ID)
+}
+
+// catchUpMember joins the user who pressed the button to the watch party if needed
+// and moves their progress to the group's.
+func (handler *Handler) catchUpMember(ctx context.Context, cb *tgbotapi.CallbackQuery, groupShowID int64) error {
+	userID := cb.From.ID
+	err := joinGroupShow(ctx, handler.DB, groupShowID, cb.From)
+	if err == nil {
+		err = setMemberProgressToGroup(ctx, handler.DB, groupShowID, userID)
+	}
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("updating progress of user %d in group show %d: %w", userID, groupShowID, err),
+			"Error updating your progress, please try again later.",
+		)
+	}
+	return handler.showWatchParty(ctx, cb, groupShowID)
+}
+
+// handlePartyRemoveCallback asks to confirm removing a watch party, which loses
+// everyone's progress in it.
+func (handler *Handler) handlePartyRemoveCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	group, err := handler.getChatGroupShow(ctx, cb, callbackParam)
+	if err != nil {
+		return err
+	}
+	if err := handler.requireGroupManager(ctx, group.ChatID, cb.From.ID); err != nil {
+		return err
+	}
+	rows := [][][]string{{
+		{"🗑 Yes, remove", fmt.Sprintf("partyRemoveYes:%d", group.ID)},
+		{"Cancel", fmt.Sprintf("partyShow:%d", group.ID)},
+	}}
+	text := fmt.Sprintf("Remove the %s watch party? Everyone's progress in it will be lost.", group.Name)
+	handler.Bot.reply(ctx, group.ChatID, text, ReplyOptions{
+		ReplyMarkup: makeKeyboardMarkup(rows), EditMessageID: cb.Message.MessageID,
+	})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
+
+func (handler *Handler) handlePartyRemoveYesCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, callbackParam string) error {
+	group, err := handler.getChatGroupShow(ctx, cb, callbackParam)
+	if err != nil {
+		return err
+	}
+	if err := handler.requireGroupManager(ctx, group.ChatID, cb.From.ID); err != nil {
+		return err
+	}
+	if err := removeGroupShow(ctx, handler.DB, group.ID); err != nil {
+		return NewUserError(
+			fmt.Errorf("removing group show %d: %w", group.ID, err),
+			"Error removing the watch party, please try again later.",
+		)
+	}
+	handler.Bot.reply(ctx, group.ChatID, fmt.Sprintf("Removed the %s watch party.", group.Name), ReplyOptions{
+		EditMessageID: cb.Message.MessageID,
+	})
+	handler.Bot.answerCallbackQuery(cb.ID)
+	return nil
+}
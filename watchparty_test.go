@@ -0,0 +1,164 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const testGroupID int64 = -5001
+
+var (
+	alice = &tgbotapi.User{ID: testUserID, FirstName: "Alice"}
+	bob   = &tgbotapi.User{ID: 1002, FirstName: "Bob", UserName: "bob"}
+)
+
+// startWatchParty starts a "Night Shift" watch party as Alice, has Bob join and the
+// group watch the given number of episodes. It returns the party's ID.
+func startWatchParty(t *testing.T, env *testEnv, watched int) string {
+	t.Helper()
+	env.groupCommand(testGroupID, alice, "/watchparty night")
+	env.groupPress(testGroupID, alice, "partyCreate:1")
+	id := queryString(t, env, `SELECT id FROM group_shows`)
+	env.groupPress(testGroupID, bob, "partyJoin:"+id)
+	for range watched {
+		env.groupPress(testGroupID, alice, "partyNext:"+id)
+	}
+	return id
+}
+
+func TestWatchPartyProgress(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	startWatchParty(t, env, 5)
+
+	text := env.telegram.lastMessage(t).Params.Get("text")
+	for _, want := range []string{
+		"<b>Night Shift</b> watch party",
+		"Group progress: S02E02",
+		"Next episode: S02E03",
+		"✅ Alice: S02E02",
+		"⏳ Bob: not started",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("watch party card %q does not contain %q", text, want)
+		}
+	}
+}
+
+func TestWatchPartyNextNotAired(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	startWatchParty(t, env, 6)
+
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "S02E03 hasn't aired yet." {
+		t.Errorf("text = %q", got)
+	}
+	if got := queryString(t, env, `
+		SELECT e.number FROM group_shows g JOIN episodes_cache e ON e.id = g.last_watched_episode_id
+	`); got != "2" {
+		t.Errorf("group is at episode %s, want 2", got)
+	}
+}
+
+func TestWatchPartyCaughtUp(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	id := startWatchParty(t, env, 2)
+	env.groupPress(testGroupID, bob, "partyCaughtUp:"+id)
+
+	text := env.telegram.lastMessage(t).Params.Get("text")
+	if !strings.Contains(text, "✅ Bob: S01E02") {
+		t.Errorf("watch party card %q does not show Bob caught up", text)
+	}
+}
+
+func TestWatchPartyOtherChat(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	id := startWatchParty(t, env, 0)
+	env.groupPress(-6001, bob, "partyNext:"+id)
+
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "This watch party no longer exists. See /watchparty." {
+		t.Errorf("text = %q", got)
+	}
+}
+
+func TestWatchPartyPrivateChat(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	env.command("/watchparty night")
+
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "Watch parties happen in group chats.") {
+		t.Errorf("text = %q", got)
+	}
+}
+
+func TestSendWatchPartyReminders(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	startWatchParty(t, env, 5)
+	// The party started a while ago and S02E03 has just aired
+	now := time.Now().UTC()
+	_, err := env.handler.DB.Exec(`UPDATE group_shows SET created_at = ?`, now.Add(-time.Hour).Format(time.RFC3339))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = env.handler.DB.Exec(`
+		UPDATE episodes_cache SET aired_at_utc = ? WHERE season = 2 AND number = 3
+	`, now.Add(-time.Minute).Format(time.RFC3339))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sendWatchPartyReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	sendWatchPartyReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+
+	var reminders []string
+	for _, req := range env.telegram.messages() {
+		if req.Method == "sendMessage" && strings.Contains(req.Params.Get("text"), "New episode of") {
+			reminders = append(reminders, req.Params.Get("text"))
+		}
+	}
+	if len(reminders) != 1 {
+		t.Fatalf("sent %d watch party reminders, want 1", len(reminders))
+	}
+	if !strings.Contains(reminders[0], "Still catching up to S02E02: @bob") {
+		t.Errorf("reminder %q does not mention Bob", reminders[0])
+	}
+	if strings.Contains(reminders[0], "Alice") {
+		t.Errorf("reminder %q mentions Alice, who is caught up", reminders[0])
+	}
+}
+
+func TestGroupSettingsAdminsOnly(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	id := startWatchParty(t, env, 1)
+	env.telegram.setMemberStatus(alice.ID, "creator")
+
+	env.groupPress(testGroupID, bob, "groupPerm:admins")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); got != "Only group admins can change the group settings." {
+		t.Errorf("non-admin changing settings = %q", got)
+	}
+	env.groupPress(testGroupID, alice, "groupPerm:admins")
+	env.groupCommand(testGroupID, bob, "/groupsettings")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "watch parties: only admins") {
+		t.Errorf("/groupsettings = %q", got)
+	}
+
+	for _, data := range []string{"partyNext:" + id, "partyRemoveYes:" + id} {
+		env.groupPress(testGroupID, bob, data)
+		if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "Only group admins") {
+			t.Errorf("%s by a member = %q, want it refused", data, got)
+		}
+	}
+	env.groupPress(testGroupID, bob, "partyCaughtUp:"+id)
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "✅ Bob: S01E01") {
+		t.Errorf("catching up as a member = %q", got)
+	}
+
+	env.groupPress(testGroupID, alice, "partyRemoveYes:"+id)
+	if got := queryString(t, env, `SELECT COUNT(*) FROM group_shows`); got != "0" {
+		t.Errorf("%s watch parties left after an admin removed it", got)
+	}
+	// Alice's and Bob's statuses were each looked up once
+	if calls := len(env.telegram.calls("getChatMember")); calls != 2 {
+		t.Errorf("getChatMember called %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,318 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+// Time watched adds up the runtimes of watched episodes. Progress only says how far
+// the user got, so /stats counts every episode up to it, while the yearly recap
+// needs to know when episodes were watched and uses the watch log: the episodes
+// progress moved over, with the time it moved. When a show gets its first progress
+// only the marked episode is logged, the ones before it were likely watched before
+// the show was added.
+
+// recapHour is the local hour from which the recap of the past year is sent in January.
+const recapHour = 10
+
+// topWatchedShows is how many shows /stats and the recap list by time watched.
+const topWatchedShows = 5
+
+// ShowWatchTime is the time spent on a show, Minutes counting only episodes with a
+// known runtime.
+type ShowWatchTime struct {
+	Name     string
+	Minutes  int
+	Episodes int
+}
+
+// WatchRecap sums up the watch log over a period.
+type WatchRecap struct {
+	Minutes  int
+	Episodes int
+	Shows    int
+	Top      []ShowWatchTime
+}
+
+func getLastWatchedEpisodeID(ctx context.Context, db *store.DB, showID int64) (int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var episodeID sql.NullInt64
+	err := db.QueryRowContext(ctx, `SELECT last_watched_episode_id FROM shows WHERE id = ?`, showID).Scan(&episodeID)
+	return episodeID.Int64, err
+}
+
+// logWatchedEpisodes updates the watch log after progress moved from previousID to
+// episodeID: episodes it moved over are logged at watchedAt, and the ones it moved
+// back from are dropped. Zero IDs stand for no progress.
+func logWatchedEpisodes(ctx context.Context, db *store.DB, showID, previousID, episodeID int64, watchedAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		DELETE FROM watch_log
+		WHERE show_id = ? AND episode_id NOT IN (
+			SELECT e.id FROM episodes_cache e JOIN episodes_cache n ON n.id = ?
+			WHERE e.season < n.season OR (e.season = n.season AND e.number <= n.number)
+		)
+	`, showID, episodeID)
+	if err != nil || episodeID == 0 {
+		return err
+	}
+
+	if previousID == 0 {
+		_, err = db.ExecContext(ctx, `
+			INSERT OR IGNORE INTO watch_log (show_id, episode_id, user_id, watched_at)
+			SELECT id, ?, user_id, ? FROM shows WHERE id = ?
+		`, episodeID, watchedAt.UTC().Format(time.RFC3339), showID)
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO watch_log (show_id, episode_id, user_id, watched_at)
+		SELECT s.id, e.id, s.user_id, ?
+		FROM shows s
+		JOIN episodes_cache e ON e.provider = s.provider AND e.provider_show_id = s.provider_show_id
+		JOIN episodes_cache p ON p.id = ?
+		JOIN episodes_cache n ON n.id = ?
+		WHERE s.id = ? AND e.season > 0
+			AND (e.season > p.season OR (e.season = p.season AND e.number > p.number))
+			AND (e.season < n.season OR (e.season = n.season AND e.number <= n.number))
+	`, watchedAt.UTC().Format(time.RFC3339), previousID, episodeID, showID)
+	return err
+}
+
+// listShowWatchTimes returns the time spent on each of the user's shows up to their
+// progress, most watched first. Specials aren't counted.
+func listShowWatchTimes(ctx context.Context, db *store.DB, userID int64) ([]ShowWatchTime, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.name, COALESCE(SUM(e.runtime), 0) AS minutes, COUNT(e.id)
+		FROM shows s
+		JOIN episodes_cache w ON w.id = s.last_watched_episode_id
+		JOIN episodes_cache e ON e.provider = s.provider AND e.provider_show_id = s.provider_show_id
+			AND e.season > 0 AND (e.season < w.season OR (e.season = w.season AND e.number <= w.number))
+		WHERE s.user_id = ? AND s.deleted_at IS NULL
+		GROUP BY s.id
+		ORDER BY minutes DESC, s.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shows []ShowWatchTime
+	for rows.Next() {
+		var show ShowWatchTime
+		if err := rows.Scan(&show.Name, &show.Minutes, &show.Episodes); err != nil {
+			return nil, err
+		}
+		shows = append(shows, show)
+	}
+	return shows, rows.Err()
+}
+
+// getWatchRecap sums up the user's watch log in [from, to).
+func getWatchRecap(ctx context.Context, db *store.DB, userID int64, from, to time.Time) (*WatchRecap, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.name, COALESCE(SUM(e.runtime), 0) AS minutes, COUNT(*)
+		FROM watch_log l
+		JOIN shows s ON s.id = l.show_id
+		JOIN episodes_cache e ON e.id = l.episode_id
+		WHERE l.user_id = ? AND l.watched_at >= ? AND l.watched_at < ?
+		GROUP BY l.show_id
+		ORDER BY minutes DESC, s.name
+	`, userID, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recap WatchRecap
+	for rows.Next() {
+		var show ShowWatchTime
+		if err := rows.Scan(&show.Name, &show.Minutes, &show.Episodes); err != nil {
+			return nil, err
+		}
+		recap.Minutes += show.Minutes
+		recap.Episodes += show.Episodes
+		recap.Shows++
+		if len(recap.Top) < topWatchedShows {
+			recap.Top = append(recap.Top, show)
+		}
+	}
+	return &recap, rows.Err()
+}
+
+// listRecapCandidates returns the active users who haven't got the recap of year yet
+// and may have watched something in it. Time zones are sorted out per user.
+func listRecapCandidates(ctx context.Context, db *store.DB, year int) ([]int64, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	// Widened by a day on both ends to cover every time zone
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	to := time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT l.user_id
+		FROM watch_log l
+		LEFT JOIN user_settings us ON us.user_id = l.user_id
+		WHERE l.watched_at >= ? AND l.watched_at < ?
+			AND us.inactive_since IS NULL AND COALESCE(us.last_recap_year, 0) < ?
+	`, from.Format(time.RFC3339), to.Format(time.RFC3339), year)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+func setLastRecapYear(ctx context.Context, db *store.DB, userID, chatID int64, year int) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `UPDATE user_settings SET last_recap_year = ? WHERE user_id = ?`, year, userID)
+	return err
+}
+
+// formatWatchTime renders minutes as whole hours, or minutes under an hour.
+func formatWatchTime(minutes int) string {
+	if minutes < 60 {
+		return pluralize(minutes, "minute")
+	}
+	return pluralize(int(math.Round(float64(minutes)/60)), "hour")
+}
+
+func formatRecap(recap *WatchRecap, year int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🎬 <b>Your %d in TV</b>\n\n", year)
+	if recap.Episodes == 0 {
+		fmt.Fprintf(&b, "You haven't marked any episodes watched in %d.", year)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "You watched %s of TV in %d: %s of %s.\n",
+		formatWatchTime(recap.Minutes), year, pluralize(recap.Episodes, "episode"), pluralize(recap.Shows, "show"))
+	b.WriteString("\nMost watched:\n")
+	for i, show := range recap.Top {
+		fmt.Fprintf(&b, "%d. %s, %s\n", i+1, html.EscapeString(show.Name), formatWatchTime(show.Minutes))
+	}
+	return b.String()
+}
+
+// yearBounds returns the start of year and of the next one in loc.
+func yearBounds(year int, loc *time.Location) (time.Time, time.Time) {
+	return time.Date(year, time.January, 1, 0, 0, 0, 0, loc), time.Date(year+1, time.January, 1, 0, 0, 0, 0, loc)
+}
+
+// sendDueRecaps sends the recap of the past year to users who watched something in
+// it, once their January has started.
+func sendDueRecaps(ctx context.Context, bot *Bot, db *store.DB, now time.Time) {
+	utc := now.UTC()
+	year := utc.Year() - 1
+	switch utc.Month() {
+	case time.December:
+		// January starts early east of UTC
+		year = utc.Year()
+	case time.January, time.February:
+	default:
+		return
+	}
+	userIDs, err := listRecapCandidates(ctx, db, year)
+	if err != nil {
+		log.Printf("digestLoop: listRecapCandidates error: %v", err)
+		return
+	}
+	for _, userID := range userIDs {
+		settings, err := getUserSettings(ctx, db, userID)
+		if err != nil {
+			log.Printf("digestLoop: getting settings for user %d: %v", userID, err)
+			continue
+		}
+		local := now.In(settings.Location())
+		if local.Month() != time.January || local.Hour() < recapHour || local.Year()-1 != year {
+			continue
+		}
+		from, to := yearBounds(year, settings.Location())
+		recap, err := getWatchRecap(ctx, db, userID, from, to)
+		if err != nil {
+			log.Printf("digestLoop: getting the %d recap of user %d: %v", year, userID, err)
+			continue
+		}
+		chatID := settings.ChatID
+		if chatID == 0 {
+			chatID = userID
+		}
+		if recap.Episodes > 0 {
+			if _, err := bot.send(ctx, chatID, formatRecap(recap, year), ReplyOptions{ParseMode: "HTML"}); err != nil {
+				log.Printf("digestLoop: failed to send the %d recap to user %d: %v", year, userID, err)
+				if !isChatUnreachable(err) {
+					continue
+				}
+			}
+		}
+		if err := setLastRecapYear(ctx, db, userID, chatID, year); err != nil {
+			log.Printf("digestLoop: marking the %d recap of user %d sent: %v", year, userID, err)
+		}
+	}
+}
+
+// RECAP command
+
+func (handler *Handler) handleRecapCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	userID := msg.From.ID
+	settings, err := getUserSettings(ctx, handler.DB, userID)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting settings for user %d: %w", userID, err),
+			"Error getting your recap",
+		)
+	}
+
+	year := handler.Clock.Now().In(settings.Location()).Year()
+	if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+		year, err = strconv.Atoi(arg)
+		if err != nil || year < 2000 || year > 9999 {
+			handler.Bot.reply(ctx, msg.Chat.ID, "Usage: /recap [year], like /recap 2024")
+			return nil
+		}
+	}
+
+	from, to := yearBounds(year, settings.Location())
+	recap, err := getWatchRecap(ctx, handler.DB, userID, from, to)
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("getting the %d recap of user %d: %w", year, userID, err),
+			"Error getting your recap",
+		)
+	}
+	handler.Bot.reply(ctx, msg.Chat.ID, formatRecap(recap, year), ReplyOptions{ParseMode: "HTML"})
+	return nil
+}
@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsAndRecapCountTimeWatched(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	if _, err := env.handler.DB.Exec(`UPDATE episodes_cache SET runtime = 60`); err != nil {
+		t.Fatal(err)
+	}
+	env.text("watched night shift s2e3")
+
+	env.command("/stats")
+	text := env.telegram.lastMessage(t).Params.Get("text")
+	for _, want := range []string{"Time watched: 6 hours, 6 episodes", "1. Night Shift, 6 hours"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("/stats = %q, want it to contain %q", text, want)
+		}
+	}
+
+	// The episodes before the first progress were watched before adding the show
+	env.command("/recap")
+	want := fmt.Sprintf("You watched 2 hours of TV in %d: 2 episodes of 1 show.", time.Now().Year())
+	if text := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(text, want) {
+		t.Errorf("/recap = %q, want it to contain %q", text, want)
+	}
+}
+
+func TestRecapSentInJanuary(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	trackShow(t, env, "2")
+	if _, err := env.handler.DB.Exec(`UPDATE watch_log SET watched_at = '2025-06-01T20:00:00Z'`); err != nil {
+		t.Fatal(err)
+	}
+
+	sendDueRecaps(t.Context(), env.handler.Bot, env.handler.DB, time.Date(2026, time.January, 1, 8, 0, 0, 0, time.UTC))
+	sent := func() int {
+		n := 0
+		for _, req := range env.telegram.messages() {
+			if strings.Contains(req.Params.Get("text"), "Your 2025 in TV") {
+				n++
+			}
+		}
+		return n
+	}
+	if n := sent(); n != 0 {
+		t.Errorf("%d recaps sent before %02d:00", n, recapHour)
+	}
+	for range 2 {
+		sendDueRecaps(t.Context(), env.handler.Bot, env.handler.DB, time.Date(2026, time.January, 1, 12, 0, 0, 0, time.UTC))
+	}
+	if n := sent(); n != 1 {
+		t.Errorf("%d recaps sent, want 1", n)
+	}
+}
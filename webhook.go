@@ -0,0 +1,442 @@
+package bot
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tvreminder/bot/internal/store"
+)
+
+const (
+	// WebhookEventReminder is posted when a reminder has been sent.
+	WebhookEventReminder = "reminder.sent"
+	// WebhookEventProgress is posted when the user's progress in a show changes.
+	WebhookEventProgress = "progress.updated"
+)
+
+// webhookSignatureHeader carries the hex HMAC-SHA256 of the body, keyed with the
+// user's webhook secret.
+const webhookSignatureHeader = "X-Webhook-Signature"
+
+const maxWebhookAttempts = 5
+
+// maxWebhookBatch bounds how many deliveries one tick of webhookLoop attempts.
+const maxWebhookBatch = 50
+
+type WebhookShow struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	// ProviderID is the show's ID at the provider, e.g. on TVmaze.
+	ProviderID string `json:"provider_id"`
+}
+
+type WebhookEpisode struct {
+	Season  int       `json:"season"`
+	Number  int       `json:"number"`
+	Title   string    `json:"title"`
+	AiredAt time.Time `json:"aired_at,omitzero"`
+}
+
+// WebhookEvent is the JSON body posted to webhooks.
+type WebhookEvent struct {
+	Event  string      `json:"event"`
+	UserID int64       `json:"user_id"`
+	Time   time.Time   `json:"time"`
+	Show   WebhookShow `json:"show"`
+	// Episodes are the episodes a reminder was about, or the last watched one.
+	Episodes []WebhookEpisode `json:"episodes"`
+}
+
+func webhookEpisode(episode DBEpisode) WebhookEpisode {
+	return WebhookEpisode{
+		Season: episode.Season, Number: episode.Number, Title: episode.Title, AiredAt: episode.AiredAtUTC,
+	}
+}
+
+func signWebhook(secret string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// errWebhookAddress refuses webhooks pointing into the bot's own network.
+var errWebhookAddress = errors.New("webhook URL must point to a public address")
+
+// validateWebhookURL accepts absolute https URLs only, so events never leave in
+// clear text. Hosts that are private addresses are refused right away; names are
+// checked when the webhook client connects, see newWebhookClient.
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return errors.New("webhook URL must start with https://")
+	}
+	if u.Hostname() == "localhost" {
+		return errWebhookAddress
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && !isPublicAddr(ip) {
+		return errWebhookAddress
+	}
+	return nil
+}
+
+// isPublicAddr reports whether ip is reachable on the internet, rather than
+// loopback, private, link-local (like cloud metadata services) or otherwise special.
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() &&
+		!sharedAddressSpace.Contains(ip)
+}
+
+// sharedAddressSpace is carrier-grade NAT space, private in all but name.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// newWebhookClient returns the client webhookLoop posts with. Webhook URLs are
+// whatever users typed, so it connects to public addresses only, checked after
+// resolving so a name can't lead it elsewhere, and doesn't follow redirects.
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("connecting to %s: %w", address, errWebhookAddress)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would do the connecting, out of the dialer's sight
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// setWebhook saves the user's webhook, an empty url removes it.
+func setWebhook(ctx context.Context, db *store.DB, userID, chatID int64, url, secret string) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := ensureUserSettings(ctx, db, userID, chatID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE user_settings SET webhook_url = ?, webhook_secret = ? WHERE user_id = ?
+	`, url, secret, userID)
+	if err != nil {
+		return err
+	}
+	if url == "" {
+		_, err = db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE user_id = ?`, userID)
+	}
+	return err
+}
+
+// enqueueWebhookEvent stores event for delivery by webhookLoop. It does nothing for
+// users without a webhook.
+func enqueueWebhookEvent(ctx context.Context, db Execer, event WebhookEvent) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (user_id, event, payload, next_attempt_at)
+		SELECT user_id, ?, ?, ? FROM user_settings WHERE user_id = ? AND webhook_url != ''
+	`, event.Event, string(payload), time.Now().UTC().Format(time.RFC3339), event.UserID)
+	return err
+}
+
+// enqueueProgressWebhook posts the show's current progress to the owner's webhook.
+func enqueueProgressWebhook(ctx context.Context, db *store.DB, showID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	event := WebhookEvent{Event: WebhookEventProgress, Time: time.Now().UTC()}
+	var episodeID int64
+	err := db.QueryRowContext(ctx, `
+		SELECT s.id, s.user_id, s.name, s.provider, s.provider_show_id, s.last_watched_episode_id
+		FROM shows s
+		JOIN user_settings us ON us.user_id = s.user_id
+		WHERE s.id = ? AND us.webhook_url != '' AND s.last_watched_episode_id IS NOT NULL
+	`, showID).Scan(
+		&event.Show.ID, &event.UserID, &event.Show.Name, &event.Show.Provider, &event.Show.ProviderID, &episodeID,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		// No webhook, or no progress to report
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	episode, err := scanEpisode(db.QueryRowContext(ctx, `
+		SELECT `+episodeColumns+` FROM episodes_cache WHERE id = ?
+	`, episodeID))
+	if err != nil {
+		return err
+	}
+	event.Episodes = []WebhookEpisode{webhookEpisode(*episode)}
+	return enqueueWebhookEvent(ctx, db, event)
+}
+
+// enqueueReminderWebhook posts a sent reminder, with every episode it covered, to
+// the user's webhook.
+func enqueueReminderWebhook(ctx context.Context, db ExecQuerier, r DBReminder) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	event := WebhookEvent{
+		Event:  WebhookEventReminder,
+		UserID: r.UserID,
+		Time:   time.Now().UTC(),
+		Show:   WebhookShow{ID: r.ShowID, Name: r.ShowName},
+	}
+	err := db.QueryRowContext(ctx, `
+		SELECT provider, provider_show_id FROM shows WHERE id = ?
+	`, r.ShowID).Scan(&event.Show.Provider, &event.Show.ProviderID)
+	if err != nil {
+		return err
+	}
+	episode, err := scanEpisode(db.QueryRowContext(ctx, `
+		SELECT `+episodeColumns+` FROM episodes_cache WHERE id = ?
+	`, r.EpisodeID))
+	if err != nil {
+		return err
+	}
+	event.Episodes = []WebhookEpisode{webhookEpisode(*episode)}
+	for _, episode := range r.Batch {
+		event.Episodes = append(event.Episodes, webhookEpisode(episode))
+	}
+	return enqueueWebhookEvent(ctx, db, event)
+}
+
+type webhookDelivery struct {
+	ID       int64
+	Event    string
+	Payload  string
+	Attempts int
+	URL      string
+	Secret   string
+}
+
+func listDueWebhookDeliveries(ctx context.Context, db *store.DB, now time.Time) ([]webhookDelivery, error) {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT d.id, d.event, d.payload, d.attempts, us.webhook_url, us.webhook_secret
+		FROM webhook_deliveries d
+		JOIN user_settings us ON us.user_id = d.user_id
+		WHERE d.next_attempt_at <= ? AND us.webhook_url != ''
+		ORDER BY d.id
+		LIMIT ?
+	`, now.UTC().Format(time.RFC3339), maxWebhookBatch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []webhookDelivery
+	for rows.Next() {
+		var d webhookDelivery
+		if err := rows.Scan(&d.ID, &d.Event, &d.Payload, &d.Attempts, &d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func deleteWebhookDelivery(ctx context.Context, db *store.DB, deliveryID int64) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE id = ?`, deliveryID)
+	return err
+}
+
+func markWebhookAttemptFailed(ctx context.Context, db *store.DB, deliveryID int64, attempts int, nextAttemptAt time.Time) error {
+	ctx, cancel := db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET attempts = ?, next_attempt_at = ? WHERE id = ?
+	`, attempts, nextAttemptAt.UTC().Format(time.RFC3339), deliveryID)
+	return err
+}
+
+// postWebhook sends one delivery. Any response other than 2xx is a failure.
+func postWebhook(ctx context.Context, client *http.Client, d webhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, strings.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tvreminderbot")
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set(webhookSignatureHeader, signWebhook(d.Secret, []byte(d.Payload)))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// deliverWebhooks posts every due delivery, one tick of webhookLoop. Failed ones are
+// retried with backoff and dropped after maxWebhookAttempts.
+func deliverWebhooks(ctx context.Context, db *store.DB, client *http.Client, now time.Time) {
+	deliveries, err := listDueWebhookDeliveries(ctx, db, now)
+	if err != nil {
+		log.Printf("webhookLoop: listing deliveries: %v", err)
+		return
+	}
+	for _, d := range deliveries {
+		sendErr := postWebhook(ctx, client, d)
+		if sendErr == nil {
+			if err := deleteWebhookDelivery(ctx, db, d.ID); err != nil {
+				log.Printf("webhookLoop: deleting delivery %d: %v", d.ID, err)
+			}
+			continue
+		}
+
+		attempts := d.Attempts + 1
+		if attempts >= maxWebhookAttempts {
+			log.Printf("webhookLoop: delivery %d failed %d times, giving up: %v", d.ID, attempts, sendErr)
+			if err := deleteWebhookDelivery(ctx, db, d.ID); err != nil {
+				log.Printf("webhookLoop: deleting delivery %d: %v", d.ID, err)
+			}
+			continue
+		}
+		delay := reminderBackoff(attempts)
+		log.Printf("webhookLoop: delivery %d failed (attempt %d), retrying in %s: %v", d.ID, attempts, delay, sendErr)
+		if err := markWebhookAttemptFailed(ctx, db, d.ID, attempts, now.Add(delay)); err != nil {
+			log.Printf("webhookLoop: recording delivery %d failure: %v", d.ID, err)
+		}
+	}
+}
+
+func webhookLoop(db *store.DB, client *http.Client, ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			deliverWebhooks(ctx, db, client, time.Now())
+		case <-ctx.Done():
+			log.Println("webhookLoop: context cancelled, exiting")
+			return
+		}
+	}
+}
+
+// WEBHOOK command
+
+func (handler *Handler) handleWebhookCommand(ctx context.Context, msg *tgbotapi.Message) error {
+	chatID := msg.Chat.ID
+	userID := msg.From.ID
+	arg := strings.TrimSpace(msg.CommandArguments())
+
+	switch {
+	case arg == "":
+		settings, err := getUserSettings(ctx, handler.DB, userID)
+		if err != nil {
+			return NewUserError(
+				fmt.Errorf("getting settings for user %d: %w", userID, err),
+				"Error: can't get your webhook at this time",
+			)
+		}
+		if settings.WebhookURL == "" {
+			handler.Bot.reply(ctx, chatID, dedent(`
+			No webhook set. Use /webhook <https URL> and I'll POST JSON to it whenever
+			a reminder fires or your progress changes.
+			`))
+			return nil
+		}
+		handler.Bot.reply(ctx, chatID, fmt.Sprintf(
+			"Your webhook: %s\nUse /webhook off to remove it.", settings.WebhookURL,
+		))
+		return nil
+
+	case arg == "off":
+		if err := setWebhook(ctx, handler.DB, userID, chatID, "", ""); err != nil {
+			return NewUserError(
+				fmt.Errorf("removing webhook of user %d: %w", userID, err),
+				"Error: can't remove your webhook at this time",
+			)
+		}
+		handler.Bot.reply(ctx, chatID, "Webhook removed.")
+		return nil
+	}
+
+	if err := validateWebhookURL(arg); errors.Is(err, errWebhookAddress) {
+		return NewUserError(
+			fmt.Errorf("invalid webhook URL %q: %w", arg, err),
+			"Webhooks have to go to a public address, not a local or private one.",
+		)
+	} else if err != nil {
+		return NewUserError(
+			fmt.Errorf("invalid webhook URL %q: %w", arg, err),
+			"Please send a full https:// URL, e.g. /webhook https://example.com/hook",
+		)
+	}
+	secret, err := randomToken()
+	if err != nil {
+		return NewUserError(
+			fmt.Errorf("generating webhook secret: %w", err),
+			"Error: can't set your webhook at this time",
+		)
+	}
+	if err := setWebhook(ctx, handler.DB, userID, chatID, arg, secret); err != nil {
+		return NewUserError(
+			fmt.Errorf("setting webhook of user %d: %w", userID, err),
+			"Error: can't set your webhook at this time",
+		)
+	}
+
+	text := fmt.Sprintf(
+		"Webhook set to %s.\n\nEvery request carries an %s header with the hex HMAC-SHA256 of the body, "+
+			"keyed with this secret:\n<code>%s</code>",
+		html.EscapeString(arg), webhookSignatureHeader, secret,
+	)
+	handler.Bot.reply(ctx, chatID, text, ReplyOptions{ParseMode: "HTML"})
+	return nil
+}
@@ -0,0 +1,175 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type webhookRequest struct {
+	Signature string
+	Event     WebhookEvent
+}
+
+// newWebhookServer records posted events and answers with status.
+func newWebhookServer(t *testing.T, status int) (*httptest.Server, func() []webhookRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []webhookRequest
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req := webhookRequest{Signature: r.Header.Get(webhookSignatureHeader)}
+		if err := json.Unmarshal(body, &req.Event); err != nil {
+			t.Errorf("decoding webhook body %s: %v", body, err)
+		}
+		if want := signWebhook("s3cret", body); req.Signature != want {
+			t.Errorf("signature = %s, want %s", req.Signature, want)
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []webhookRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestWebhookCommand(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.command("/webhook http://example.com/hook")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "Please send a full https:// URL") {
+		t.Errorf("/webhook with http = %q", got)
+	}
+
+	env.command("/webhook https://169.254.169.254/latest")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "Webhooks have to go to a public address") {
+		t.Errorf("/webhook with a link-local address = %q", got)
+	}
+
+	env.command("/webhook https://example.com/hook")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.Contains(got, "<code>") {
+		t.Errorf("/webhook reply = %q, want the secret", got)
+	}
+	if got := queryString(t, env, `SELECT length(webhook_secret) FROM user_settings`); got != "32" {
+		t.Errorf("secret length = %s, want 32", got)
+	}
+
+	env.command("/webhook")
+	if got := env.telegram.lastMessage(t).Params.Get("text"); !strings.HasPrefix(got, "Your webhook: https://example.com/hook") {
+		t.Errorf("/webhook = %q", got)
+	}
+
+	env.command("/webhook off")
+	if got := queryString(t, env, `SELECT webhook_url FROM user_settings`); got != "" {
+		t.Errorf("webhook_url = %q after removing it", got)
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.215.14":        true,
+		"2606:2800:21f::1":     true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"192.168.0.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"::1":                  false,
+		"fd00::1":              false,
+		"fe80::1":              false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.215.14": true,
+	} {
+		if got := isPublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("isPublicAddr(%s) = %t, want %t", addr, got, want)
+		}
+	}
+}
+
+func TestWebhookClientRefusesLocalAddresses(t *testing.T) {
+	server, requests := newWebhookServer(t, http.StatusNoContent)
+	resp, err := newWebhookClient(time.Second).Post(server.URL, "application/json", strings.NewReader("{}"))
+	if err == nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, errWebhookAddress) {
+		t.Errorf("posting to %s = %v, want it refused", server.URL, err)
+	}
+	if n := len(requests()); n != 0 {
+		t.Errorf("server got %d requests, want none", n)
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	server, requests := newWebhookServer(t, http.StatusNoContent)
+	if err := setWebhook(t.Context(), env.handler.DB, testUserID, testUserID, server.URL, "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+
+	trackShow(t, env, "2")
+	env.handler.DB.Exec(`UPDATE reminders SET remind_at = ?`, time.Now().UTC().Add(-time.Minute).Format(time.RFC3339))
+	sendDueReminders(t.Context(), env.handler.Bot, env.handler.DB, env.handler.Clock.Now())
+	deliverWebhooks(t.Context(), env.handler.DB, server.Client(), time.Now())
+
+	got := requests()
+	if len(got) != 2 {
+		t.Fatalf("got %d webhook requests, want 2", len(got))
+	}
+	progress, reminder := got[0].Event, got[1].Event
+	if progress.Event != WebhookEventProgress || progress.Show.Name != "Night Shift" ||
+		len(progress.Episodes) != 1 || progress.Episodes[0].Number != 2 {
+		t.Errorf("progress event = %+v", progress)
+	}
+	if reminder.Event != WebhookEventReminder || reminder.Show.ProviderID != "1" ||
+		len(reminder.Episodes) != 1 || reminder.Episodes[0].Number != 3 || reminder.Episodes[0].AiredAt.IsZero() {
+		t.Errorf("reminder event = %+v", reminder)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM webhook_deliveries`); got != "0" {
+		t.Errorf("%s deliveries left, want 0", got)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	env := newTestEnv(t, testShows()...)
+	server, requests := newWebhookServer(t, http.StatusInternalServerError)
+	if err := setWebhook(t.Context(), env.handler.DB, testUserID, testUserID, server.URL, "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	trackShow(t, env, "2")
+
+	now := time.Now()
+	deliverWebhooks(t.Context(), env.handler.DB, server.Client(), now)
+	if got := queryString(t, env, `SELECT attempts FROM webhook_deliveries`); got != "1" {
+		t.Errorf("attempts = %s, want 1", got)
+	}
+	// Not due again until the backoff passed
+	deliverWebhooks(t.Context(), env.handler.DB, server.Client(), now)
+	if len(requests()) != 1 {
+		t.Errorf("retried before the backoff passed")
+	}
+
+	for range maxWebhookAttempts {
+		now = now.Add(time.Hour)
+		deliverWebhooks(t.Context(), env.handler.DB, server.Client(), now)
+	}
+	if got := len(requests()); got != maxWebhookAttempts {
+		t.Errorf("got %d attempts, want %d", got, maxWebhookAttempts)
+	}
+	if got := queryString(t, env, `SELECT COUNT(*) FROM webhook_deliveries`); got != "0" {
+		t.Errorf("%s deliveries left after giving up, want 0", got)
+	}
+}